@@ -0,0 +1,59 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+func NewCatalogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "catalog",
+		Short: "Browse the catalogs of gadget images",
+	}
+
+	cmd.AddCommand(NewSearchCmd())
+	cmd.AddCommand(NewInfoCmd())
+	cmd.AddCommand(NewPushCmd())
+
+	return cmd
+}
+
+func addCatalogsFlag(cmd *cobra.Command, catalogs *[]string) {
+	cmd.Flags().StringSliceVar(
+		catalogs,
+		"catalog",
+		[]string{oci.DefaultCatalog},
+		"Catalogs to query, can be repeated",
+	)
+}
+
+// getCatalogs fetches all the given catalogs.
+func getCatalogs(ctx context.Context, catalogRefs []string, authOpts *oci.AuthOptions) ([]*oci.Catalog, error) {
+	catalogs := make([]*oci.Catalog, 0, len(catalogRefs))
+	for _, ref := range catalogRefs {
+		catalog, err := oci.GetCatalog(ctx, ref, authOpts)
+		if err != nil {
+			return nil, fmt.Errorf("getting catalog %q: %w", ref, err)
+		}
+		catalogs = append(catalogs, catalog)
+	}
+	return catalogs, nil
+}
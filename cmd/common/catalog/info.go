@@ -0,0 +1,79 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+func NewInfoCmd() *cobra.Command {
+	var authOpts oci.AuthOptions
+	var catalogRefs []string
+	cmd := &cobra.Command{
+		Use:          "info NAME|IMAGE",
+		Short:        "Show the information of a gadget from the catalogs or of a gadget image",
+		Long:         "Show the information of a gadget. The gadget is looked up by name in the catalogs, unless an image is given. Only the metadata of the image is fetched, not the whole image.",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.TODO()
+			image := args[0]
+
+			// Gadget names can't contain slashes, unlike image references
+			if !strings.Contains(image, "/") {
+				catalogs, err := getCatalogs(ctx, catalogRefs, &authOpts)
+				if err != nil {
+					return err
+				}
+				var entry *oci.CatalogEntry
+				for _, catalog := range catalogs {
+					if entry = catalog.Lookup(image); entry != nil {
+						break
+					}
+				}
+				if entry == nil {
+					return fmt.Errorf("gadget %q not found in the catalogs", image)
+				}
+				image = entry.Image
+			}
+
+			// Get the information from the image, as it could have been updated
+			// since the catalog was created
+			entry, err := oci.NewCatalogEntry(ctx, image, &authOpts)
+			if err != nil {
+				return fmt.Errorf("getting gadget information: %w", err)
+			}
+
+			fmt.Printf("Name:         %s\n", entry.Name)
+			fmt.Printf("Image:        %s\n", entry.Image)
+			fmt.Printf("Digest:       %s\n", entry.Digest)
+			fmt.Printf("Category:     %s\n", entry.Category)
+			fmt.Printf("Description:  %s\n", entry.Description)
+			fmt.Printf("Capabilities: %s\n", strings.Join(entry.Capabilities, ", "))
+			return nil
+		},
+	}
+
+	addCatalogsFlag(cmd, &catalogRefs)
+	utils.AddRegistryAuthVariablesAndFlags(cmd, &authOpts)
+	return cmd
+}
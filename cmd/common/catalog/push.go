@@ -0,0 +1,55 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+func NewPushCmd() *cobra.Command {
+	var authOpts oci.AuthOptions
+	cmd := &cobra.Command{
+		Use:          "push CATALOG IMAGE [IMAGE...]",
+		Short:        "Create a catalog with the given images and push it to a remote registry",
+		Long:         "Create a catalog with the given images and push it to a remote registry. The images have to be available in the registry, their metadata is fetched to fill the catalog.",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.TODO()
+			catalogRef := args[0]
+
+			catalog, err := oci.NewCatalog(ctx, args[1:], &authOpts)
+			if err != nil {
+				return fmt.Errorf("creating catalog: %w", err)
+			}
+
+			fmt.Printf("Pushing %s...\n", catalogRef)
+			desc, err := oci.PushCatalog(ctx, catalogRef, catalog, &authOpts)
+			if err != nil {
+				return fmt.Errorf("pushing catalog: %w", err)
+			}
+			fmt.Printf("Successfully pushed %s with %d gadgets\n", desc.String(), len(catalog.Gadgets))
+			return nil
+		},
+	}
+	utils.AddRegistryAuthVariablesAndFlags(cmd, &authOpts)
+	return cmd
+}
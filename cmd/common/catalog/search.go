@@ -0,0 +1,67 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/formatter/textcolumns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+func NewSearchCmd() *cobra.Command {
+	var authOpts oci.AuthOptions
+	var catalogRefs []string
+	cmd := &cobra.Command{
+		Use:          "search [TERM]",
+		Short:        "Search the gadgets whose name, category or description contain TERM",
+		SilenceUsage: true,
+		Args:         cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			term := ""
+			if len(args) == 1 {
+				term = args[0]
+			}
+
+			catalogs, err := getCatalogs(context.TODO(), catalogRefs, &authOpts)
+			if err != nil {
+				return err
+			}
+
+			entries := []*oci.CatalogEntry{}
+			for _, catalog := range catalogs {
+				entries = append(entries, catalog.Search(term)...)
+			}
+			if len(entries) == 0 {
+				return fmt.Errorf("no gadget found matching %q", term)
+			}
+
+			cols := columns.MustCreateColumns[oci.CatalogEntry]()
+			formatter := textcolumns.NewFormatter(cols.GetColumnMap())
+			formatter.WriteTable(os.Stdout, entries)
+			return nil
+		},
+	}
+
+	addCatalogsFlag(cmd, &catalogRefs)
+	utils.AddRegistryAuthVariablesAndFlags(cmd, &authOpts)
+	return cmd
+}
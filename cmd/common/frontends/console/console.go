@@ -75,6 +75,10 @@ func (f *frontend) Output(payload string) {
 	fmt.Fprintln(os.Stdout, payload)
 }
 
+func (f *frontend) OutputBytes(payload []byte) {
+	os.Stdout.Write(payload)
+}
+
 func (f *frontend) GetContext() context.Context {
 	return f.ctx
 }
@@ -20,10 +20,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -31,10 +34,12 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common"
+	"github.com/inspektor-gadget/inspektor-gadget/include"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
 )
 
@@ -49,10 +54,14 @@ const (
 	DEFAULT_METADATA    = "gadget.yaml"
 )
 
+var defaultPlatforms = []string{"linux/" + oci.ArchAmd64, "linux/" + oci.ArchArm64}
+
 type buildFile struct {
 	EBPFSource string `yaml:"ebpfsource"`
 	Metadata   string `yaml:"metadata"`
 	CFlags     string `yaml:"cflags"`
+	// Files shared with other gadgets, each one is stored in its own layer
+	Layers []string `yaml:"layers"`
 }
 
 type cmdOpts struct {
@@ -62,8 +71,15 @@ type cmdOpts struct {
 	image            string
 	local            bool
 	builderImage     string
+	builderChanged   bool
+	platforms        []string
+	provenance       bool
+	sbom             string
+	signingKey       string
 	updateMetadata   bool
 	validateMetadata bool
+	strict           bool
+	checkMetadata    bool
 }
 
 func NewBuildCmd() *cobra.Command {
@@ -78,9 +94,13 @@ func NewBuildCmd() *cobra.Command {
 			if opts.local && opts.builderImage != builderImage {
 				return fmt.Errorf("--local and --builder-image cannot be used at the same time")
 			}
+			if (opts.provenance || opts.sbom != "") && opts.signingKey == "" {
+				return fmt.Errorf("--provenance and --sbom require --signing-key")
+			}
 
 			fFlag := cmd.Flags().Lookup("file")
 			opts.fileChanged = fFlag.Changed
+			opts.builderChanged = cmd.Flags().Lookup("builder-image").Changed
 
 			opts.path = args[0]
 
@@ -89,23 +109,64 @@ func NewBuildCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&opts.file, "file", "f", "build.yaml", "Path to build.yaml")
-	cmd.Flags().BoolVarP(&opts.local, "local", "l", false, "Build using local tools")
+	cmd.Flags().BoolVarP(&opts.local, "local", "l", false, "Build using local tools. They are also used when Docker isn't available")
 	cmd.Flags().StringVarP(&opts.image, "tag", "t", "", "Name for the built image (format name:tag)")
 	cmd.Flags().StringVar(&opts.builderImage, "builder-image", builderImage, "Builder image to use")
 	cmd.Flags().BoolVar(&opts.updateMetadata, "update-metadata", false, "Update the metadata according to the eBPF code")
 	cmd.Flags().BoolVar(&opts.validateMetadata, "validate-metadata", true, "Validate the metadata file before building the gadget image")
+	cmd.Flags().BoolVar(&opts.strict, "strict", false, "Fail if the metadata file has unknown keys, like misspelled ones")
+	cmd.Flags().BoolVar(&opts.checkMetadata, "check-metadata", false, "Fail if the metadata file isn't up to date with the eBPF code, showing the differences")
+	cmd.Flags().StringSliceVar(&opts.platforms, "platform", defaultPlatforms, "Platforms to build the gadget for")
+	cmd.Flags().BoolVar(&opts.provenance, "provenance", false, "Attach a SLSA provenance attestation describing the build to the image")
+	cmd.Flags().StringVar(&opts.sbom, "sbom", "", "Path to a SBOM, in SPDX or CycloneDX JSON format, to attach to the image")
+	cmd.Flags().StringVar(&opts.signingKey, "signing-key", "", "Path to the PEM encoded private key the attestations are signed with")
 
 	return cmd
 }
 
+// archsFromPlatforms returns the architectures of the given platforms, in the
+// "linux/<arch>" format.
+func archsFromPlatforms(platforms []string) ([]string, error) {
+	archs := make([]string, 0, len(platforms))
+	for _, platform := range platforms {
+		platformOS, arch, ok := strings.Cut(platform, "/")
+		if !ok || platformOS != "linux" {
+			return nil, fmt.Errorf("invalid platform %q: expected linux/<arch>", platform)
+		}
+		switch arch {
+		case oci.ArchAmd64, oci.ArchArm64:
+		default:
+			return nil, fmt.Errorf("unsupported architecture %q: supported ones are %s and %s", arch,
+				oci.ArchAmd64, oci.ArchArm64)
+		}
+		archs = append(archs, arch)
+	}
+	if len(archs) == 0 {
+		return nil, errors.New("no platform specified")
+	}
+	return archs, nil
+}
+
 func runBuild(opts *cmdOpts) error {
 	conf := &buildFile{
 		EBPFSource: DEFAULT_EBPF_SOURCE,
 		Metadata:   DEFAULT_METADATA,
 	}
 
+	archs, err := archsFromPlatforms(opts.platforms)
+	if err != nil {
+		return err
+	}
+
+	// The build runs from PATH
+	if opts.sbom != "" {
+		opts.sbom, err = filepath.Abs(opts.sbom)
+		if err != nil {
+			return fmt.Errorf("getting absolute path of SBOM: %w", err)
+		}
+	}
+
 	var buildContent []byte
-	var err error
 
 	if opts.fileChanged {
 		buildContent, err = os.ReadFile(opts.file)
@@ -131,6 +192,10 @@ func runBuild(opts *cmdOpts) error {
 	}
 	defer os.RemoveAll(tmpDir)
 
+	if err := writeBuildFiles(tmpDir); err != nil {
+		return err
+	}
+
 	if opts.path != "." {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -147,25 +212,54 @@ func runBuild(opts *cmdOpts) error {
 		return fmt.Errorf("source file %q not found", conf.EBPFSource)
 	}
 
+	if !opts.local && !opts.builderChanged && localToolsAvailable() && !dockerAvailable() {
+		log.Warn("Docker is not available, building with the local tools")
+		opts.local = true
+	}
+
 	if opts.local {
-		if err := buildLocal(opts, conf, tmpDir); err != nil {
+		if err := buildLocal(opts, conf, archs, tmpDir); err != nil {
 			return err
 		}
 	} else {
-		if err := buildInContainer(opts, conf, tmpDir); err != nil {
+		if err := buildInContainer(opts, conf, archs, tmpDir); err != nil {
 			return err
 		}
 	}
 
+	objectPaths := map[string]string{}
+	for _, arch := range archs {
+		objectPaths[arch] = filepath.Join(tmpDir, arch+".bpf.o")
+	}
+
 	buildOpts := &oci.BuildGadgetImageOpts{
-		EBPFSourcePath: conf.EBPFSource,
-		EBPFObjectPaths: map[string]string{
-			oci.ArchAmd64: filepath.Join(tmpDir, oci.ArchAmd64+".bpf.o"),
-			oci.ArchArm64: filepath.Join(tmpDir, oci.ArchArm64+".bpf.o"),
-		},
+		EBPFSourcePath:   conf.EBPFSource,
+		EBPFObjectPaths:  objectPaths,
 		MetadataPath:     conf.Metadata,
+		SharedLayerPaths: conf.Layers,
 		UpdateMetadata:   opts.updateMetadata,
 		ValidateMetadata: opts.validateMetadata,
+		StrictMetadata:   opts.strict,
+		CheckMetadata:    opts.checkMetadata,
+		SBOMPath:         opts.sbom,
+	}
+	if opts.provenance {
+		buildOpts.Provenance = &oci.ProvenanceOpts{
+			BuilderID: "ig image build " + common.Version(),
+			Parameters: map[string]string{
+				"cflags":    conf.CFlags,
+				"platforms": strings.Join(opts.platforms, ","),
+			},
+		}
+		if !opts.local {
+			buildOpts.Provenance.BuilderImage = opts.builderImage
+		}
+	}
+	if opts.signingKey != "" {
+		buildOpts.SigningKey, err = oci.LoadSigningKey(opts.signingKey)
+		if err != nil {
+			return err
+		}
 	}
 
 	desc, err := oci.BuildGadgetImage(context.TODO(), buildOpts, opts.image)
@@ -178,18 +272,71 @@ func runBuild(opts *cmdOpts) error {
 	return nil
 }
 
-func buildLocal(opts *cmdOpts, conf *buildFile, output string) error {
-	makefilePath := filepath.Join(output, "Makefile")
-	if err := os.WriteFile(makefilePath, makefile, 0o644); err != nil {
+// localToolsAvailable returns true if the tools and headers used by the
+// Makefile are installed on the host.
+func localToolsAvailable() bool {
+	for _, tool := range []string{"make", "clang", "llvm-strip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return false
+		}
+	}
+	_, err := os.Stat("/usr/include/gadget")
+	return err == nil
+}
+
+// dockerAvailable returns true if the Docker daemon used to run the builder
+// container can be reached.
+func dockerAvailable() bool {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return false
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = cli.Ping(ctx)
+	return err == nil
+}
+
+// writeBuildFiles writes the Makefile and the helper headers bundled in ig to
+// the output directory, for the gadget to be built with the ones of this
+// version, both locally and in the builder container. Only the vmlinux.h
+// headers are taken from the builder image or the host.
+func writeBuildFiles(output string) error {
+	if err := os.WriteFile(filepath.Join(output, "Makefile"), makefile, 0o644); err != nil {
 		return fmt.Errorf("writing Makefile: %w", err)
 	}
 
+	err := fs.WalkDir(include.Gadget, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(output, "include", path)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0o755)
+		}
+		content, err := include.Gadget.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(dst, content, 0o644)
+	})
+	if err != nil {
+		return fmt.Errorf("writing gadget headers: %w", err)
+	}
+	return nil
+}
+
+func buildLocal(opts *cmdOpts, conf *buildFile, archs []string, output string) error {
 	buildCmd := exec.Command(
-		"make", "-f", makefilePath,
+		"make", "-f", filepath.Join(output, "Makefile"),
 		"-j", fmt.Sprintf("%d", runtime.NumCPU()),
 		"EBPFSOURCE="+conf.EBPFSource,
 		"OUTPUTDIR="+output,
+		"INCLUDEDIR="+filepath.Join(output, "include"),
 		"CFLAGS="+conf.CFlags,
+		"ARCHS="+strings.Join(archs, " "),
 	)
 	if out, err := buildCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("build script: %w: %s", err, out)
@@ -198,7 +345,7 @@ func buildLocal(opts *cmdOpts, conf *buildFile, output string) error {
 	return nil
 }
 
-func buildInContainer(opts *cmdOpts, conf *buildFile, output string) error {
+func buildInContainer(opts *cmdOpts, conf *buildFile, archs []string, output string) error {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("getting current directory: %w", err)
@@ -249,10 +396,12 @@ func buildInContainer(opts *cmdOpts, conf *buildFile, output string) error {
 		&container.Config{
 			Image: opts.builderImage,
 			Cmd: []string{
-				"make", "-f", "/Makefile", "-j", fmt.Sprintf("%d", runtime.NumCPU()),
+				"make", "-f", "/out/Makefile", "-j", fmt.Sprintf("%d", runtime.NumCPU()),
 				"EBPFSOURCE=" + filepath.Join("/work", conf.EBPFSource),
 				"OUTPUTDIR=/out",
+				"INCLUDEDIR=/out/include",
 				"CFLAGS=" + conf.CFlags,
+				"ARCHS=" + strings.Join(archs, " "),
 			},
 			User: fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
 		},
@@ -0,0 +1,49 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+func NewExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "export IMAGE [IMAGE...] DST_FILE",
+		Short:        "Export the local gadget images to a tarball with the OCI image layout",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			images := args[:len(args)-1]
+			dstFile := args[len(args)-1]
+
+			descs, err := oci.ExportGadgetImages(context.TODO(), images, dstFile)
+			if err != nil {
+				return fmt.Errorf("exporting images: %w", err)
+			}
+
+			for _, desc := range descs {
+				fmt.Printf("Successfully exported %s\n", desc.String())
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -27,6 +27,10 @@ func NewImageCmd() *cobra.Command {
 	cmd.AddCommand(NewPullCmd())
 	cmd.AddCommand(NewTagCmd())
 	cmd.AddCommand(NewListCmd())
+	cmd.AddCommand(NewExportCmd())
+	cmd.AddCommand(NewImportCmd())
+	cmd.AddCommand(NewInspectCmd())
+	cmd.AddCommand(NewLintCmd())
 
 	return cmd
 }
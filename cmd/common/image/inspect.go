@@ -0,0 +1,225 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+const outputModeTable = "table"
+
+func NewInspectCmd() *cobra.Command {
+	var authOpts oci.AuthOptions
+	var outputMode string
+	var keyPaths []string
+	cmd := &cobra.Command{
+		Use:   "inspect IMAGE",
+		Short: "Show the metadata, requirements and signature status of a gadget image",
+		Long: "Show the metadata, requirements and signature status of a gadget image without running it. " +
+			"The image is read from the local store if it's present, otherwise from the registry without pulling it.",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outputMode != outputModeTable && outputMode != utils.OutputModeJSON {
+				return utils.WrapInErrOutputModeNotSupported(outputMode)
+			}
+
+			publicKeys := make([]string, 0, len(keyPaths))
+			for _, path := range keyPaths {
+				key, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("reading public key: %w", err)
+				}
+				publicKeys = append(publicKeys, string(key))
+			}
+
+			info, err := oci.InspectGadgetImage(context.TODO(), args[0], &authOpts, publicKeys)
+			if err != nil {
+				return fmt.Errorf("inspecting image: %w", err)
+			}
+
+			if outputMode == utils.OutputModeJSON {
+				return printInspectJSON(os.Stdout, info)
+			}
+			printInspectTable(os.Stdout, info)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputMode, "output", "o", outputModeTable,
+		fmt.Sprintf("Output format (%s, %s)", outputModeTable, utils.OutputModeJSON))
+	cmd.Flags().StringSliceVar(&keyPaths, "key", nil,
+		"Path of a PEM encoded public key used to verify the cosign signature of the image, can be repeated")
+	utils.AddRegistryAuthVariablesAndFlags(cmd, &authOpts)
+	return cmd
+}
+
+func printInspectJSON(w io.Writer, info *oci.GadgetImageInfo) error {
+	// The metadata only has YAML tags, convert it to keep the same field
+	// names as in the gadget.yaml file
+	metadataYAML, err := yaml.Marshal(info.Metadata)
+	if err != nil {
+		return fmt.Errorf("marshalling metadata: %w", err)
+	}
+	metadata := map[string]any{}
+	if err := yaml.Unmarshal(metadataYAML, &metadata); err != nil {
+		return fmt.Errorf("unmarshalling metadata: %w", err)
+	}
+
+	b, err := json.MarshalIndent(struct {
+		*oci.GadgetImageInfo
+		Metadata map[string]any `json:"metadata"`
+	}{info, metadata}, "", "  ")
+	if err != nil {
+		return utils.WrapInErrMarshalOutput(err)
+	}
+	fmt.Fprintf(w, "%s\n", b)
+	return nil
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "<none>"
+	}
+	return strings.Join(values, ", ")
+}
+
+func printInspectTable(w io.Writer, info *oci.GadgetImageInfo) {
+	metadata := info.Metadata
+	architectures := orNone(info.Architectures)
+	if len(info.Architectures) == 0 {
+		architectures = "<not declared>"
+	}
+	minKernelVersion := metadata.MinKernelVersion
+	if minKernelVersion == "" {
+		minKernelVersion = "<none>"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Image:\t%s\n", info.Image)
+	fmt.Fprintf(tw, "Digest:\t%s\n", info.Digest)
+	fmt.Fprintf(tw, "Local:\t%t\n", info.Local)
+	fmt.Fprintf(tw, "Name:\t%s\n", metadata.Name)
+	fmt.Fprintf(tw, "Description:\t%s\n", metadata.Description)
+	fmt.Fprintf(tw, "Category:\t%s\n", metadata.Category)
+	fmt.Fprintf(tw, "Architectures:\t%s\n", architectures)
+	fmt.Fprintf(tw, "Capabilities:\t%s\n", orNone(metadata.Capabilities))
+	fmt.Fprintf(tw, "Min kernel version:\t%s\n", minKernelVersion)
+	fmt.Fprintf(tw, "Required features:\t%s\n", orNone(metadata.Requirements.Features))
+	fmt.Fprintf(tw, "Signature:\t%s\n", info.Signature)
+	fmt.Fprintf(tw, "Attestations:\t%s\n", orNone(info.Attestations))
+	fmt.Fprintf(tw, "Shared layers:\t%s\n", orNone(info.SharedLayers))
+	tw.Flush()
+
+	if len(metadata.Tracers) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TRACER\tMAP\tSTRUCT")
+		for _, name := range sortedKeys(metadata.Tracers) {
+			tracer := metadata.Tracers[name]
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, tracer.MapName, tracer.StructName)
+		}
+		tw.Flush()
+	}
+
+	if len(metadata.Snapshotters) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SNAPSHOTTER\tPROGRAMS\tSTRUCT")
+		for _, name := range sortedKeys(metadata.Snapshotters) {
+			snapshotter := metadata.Snapshotters[name]
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, strings.Join(snapshotter.Programs, ","), snapshotter.StructName)
+		}
+		tw.Flush()
+	}
+
+	if len(metadata.Toppers) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TOPPER\tMAP\tKEY\tVALUE")
+		for _, name := range sortedKeys(metadata.Toppers) {
+			topper := metadata.Toppers[name]
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, topper.MapName, topper.KeyStructName, topper.ValueStructName)
+		}
+		tw.Flush()
+	}
+
+	if len(metadata.Histograms) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "HISTOGRAM\tMAP\tUNIT")
+		for _, name := range sortedKeys(metadata.Histograms) {
+			h := metadata.Histograms[name]
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, h.MapName, h.Unit)
+		}
+		tw.Flush()
+	}
+
+	if len(metadata.Metrics) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "METRIC\tMAP\tTYPE\tLABELS")
+		for _, name := range sortedKeys(metadata.Metrics) {
+			metric := metadata.Metrics[name]
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, metric.MapName, metric.Type, strings.Join(metric.Labels, ","))
+		}
+		tw.Flush()
+	}
+
+	if len(metadata.Params) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PARAM\tCONSTANT\tTYPE\tDEFAULT\tDESCRIPTION")
+		for _, key := range sortedKeys(metadata.Params) {
+			p := metadata.Params[key]
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", key, p.VarName, p.Type, p.DefaultValue, p.Description)
+		}
+		tw.Flush()
+	}
+
+	if len(metadata.Structs) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "STRUCT\tFIELD\tDESCRIPTION")
+		for _, name := range sortedKeys(metadata.Structs) {
+			for _, field := range metadata.Structs[name].Fields {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", name, field.Name, field.Description)
+			}
+		}
+		tw.Flush()
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/lint"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+func NewLintCmd() *cobra.Command {
+	var authOpts oci.AuthOptions
+	var outputMode string
+	var ebpfObjectPath, metadataPath string
+	var failOnWarnings bool
+	var config lint.Config
+
+	cmd := &cobra.Command{
+		Use:   "lint [IMAGE]",
+		Short: "Check a gadget image for problems",
+		Long: "Check a gadget image for problems without running it: invalid metadata, unused maps, programs " +
+			"that aren't attached, programs without license, oversized events, fields without description, maps too " +
+			"small for what they hold and constructs that aren't portable across kernel versions. The image is pulled if it isn't in the local " +
+			"store. Use --ebpf-object and --metadata to check the files of a gadget before building its image. " +
+			"It fails if errors are found.",
+		SilenceUsage: true,
+		Args:         cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outputMode != outputModeTable && outputMode != utils.OutputModeJSON {
+				return utils.WrapInErrOutputModeNotSupported(outputMode)
+			}
+
+			var progContent, metadata []byte
+			switch {
+			case len(args) == 1 && ebpfObjectPath == "" && metadataPath == "":
+				image, err := oci.GetGadgetImage(context.TODO(), args[0], &authOpts, oci.PullImageIfNotPresent)
+				if err != nil {
+					return fmt.Errorf("getting gadget image: %w", err)
+				}
+				progContent, metadata = image.EbpfObject, image.Metadata
+			case len(args) == 0 && ebpfObjectPath != "":
+				var err error
+				progContent, err = os.ReadFile(ebpfObjectPath)
+				if err != nil {
+					return fmt.Errorf("reading eBPF object: %w", err)
+				}
+				if metadataPath != "" {
+					metadata, err = os.ReadFile(metadataPath)
+					if err != nil {
+						return fmt.Errorf("reading metadata: %w", err)
+					}
+				}
+			default:
+				return fmt.Errorf("expected either an image or --ebpf-object")
+			}
+
+			findings, err := lint.Lint(progContent, metadata, &config)
+			if err != nil {
+				return err
+			}
+
+			if outputMode == utils.OutputModeJSON {
+				if err := printLintJSON(os.Stdout, findings); err != nil {
+					return err
+				}
+			} else {
+				printLintTable(os.Stdout, findings)
+			}
+
+			errs, warnings := 0, 0
+			for _, f := range findings {
+				if f.Severity == lint.SeverityError {
+					errs++
+				} else {
+					warnings++
+				}
+			}
+			if errs > 0 || (failOnWarnings && warnings > 0) {
+				return fmt.Errorf("%d errors and %d warnings found", errs, warnings)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputMode, "output", "o", outputModeTable,
+		fmt.Sprintf("Output format (%s, %s)", outputModeTable, utils.OutputModeJSON))
+	cmd.Flags().StringVar(&ebpfObjectPath, "ebpf-object", "", "Path of the eBPF object to check instead of an image")
+	cmd.Flags().StringVar(&metadataPath, "metadata", "", "Path of the metadata file to check with --ebpf-object")
+	cmd.Flags().Uint32Var(&config.MaxEventSize, "max-event-size", lint.DefaultMaxEventSize,
+		"Size of the events, in bytes, above which they are reported")
+	cmd.Flags().BoolVar(&failOnWarnings, "fail-on-warnings", false, "Fail if warnings are found too")
+	utils.AddRegistryAuthVariablesAndFlags(cmd, &authOpts)
+	return cmd
+}
+
+func printLintJSON(w io.Writer, findings []lint.Finding) error {
+	if findings == nil {
+		findings = []lint.Finding{}
+	}
+	b, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		return utils.WrapInErrMarshalOutput(err)
+	}
+	fmt.Fprintf(w, "%s\n", b)
+	return nil
+}
+
+func printLintTable(w io.Writer, findings []lint.Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No problems found")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tCHECK\tOBJECT\tMESSAGE")
+	for _, f := range findings {
+		object := f.Object
+		if object == "" {
+			object = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Severity, f.Check, object, f.Message)
+	}
+	tw.Flush()
+}
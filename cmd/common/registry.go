@@ -29,6 +29,8 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/frontends"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/frontends/console"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	columns_json "github.com/inspektor-gadget/inspektor-gadget/pkg/columns/formatter/json"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/formatter/textcolumns"
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -189,6 +191,7 @@ func buildCommandFromGadget(
 	var outputMode string
 	var filters []string
 	var timeout int
+	var rawValues bool
 
 	var skipParams []params.ValueHint
 	if skipParamsInterface, ok := gadgetDesc.(gadgets.GadgetDescSkipParams); ok {
@@ -232,9 +235,12 @@ func buildCommandFromGadget(
 			// we need to re-enable flag parsing, as cmd.ParseFlags() would not
 			// do anything otherwise
 			cmd.DisableFlagParsing = false
+			// The params of a containerized gadget are only known once its
+			// image is read, the flags are parsed again then
+			cmd.FParseErrWhitelist.UnknownFlags = isRunGadget
 			return cmd.ParseFlags(args)
 		},
-		RunE: func(cmd *cobra.Command, _ []string) error {
+		RunE: func(cmd *cobra.Command, rawArgs []string) error {
 			// args from RunE still contains all flags, since we manually parsed them,
 			// so we need to manually pull the remaining args here
 			args := cmd.Flags().Args()
@@ -273,10 +279,44 @@ func buildCommandFromGadget(
 					return fmt.Errorf("getting gadget info: %w", err)
 				}
 
+				// Add the params of the gadget, its unknown flags are errors now
+				ebpfParams := gadgetInfo.GadgetMetadata.ParamDescs().ToParams()
+				for _, p := range *ebpfParams {
+					if cmd.Flags().Lookup(p.Key) != nil {
+						return fmt.Errorf("param %q of the gadget has the same name as a flag", p.Key)
+					}
+				}
+				AddFlags(cmd, ebpfParams, skipParams, runtime)
+				gadgetParams.Add(*ebpfParams...)
+				cmd.FParseErrWhitelist.UnknownFlags = false
+				// The filters would be appended to the ones already parsed
+				filters = nil
+				if err := cmd.ParseFlags(rawArgs); err != nil {
+					return err
+				}
+
+				// Pin the image to the digest it resolved to, so all the nodes
+				// run the same image even if the tag is updated meanwhile
+				if gadgetInfo.ImageDigest != "" && !strings.Contains(args[0], "@") {
+					log.Debugf("Running image %s@%s", gadgetInfo.ImageRef, gadgetInfo.ImageDigest)
+					args[0] = gadgetInfo.ImageRef + "@" + gadgetInfo.ImageDigest
+				}
+
 				parser, err = runGadgetDesc.CustomParser(gadgetInfo)
 				if err != nil {
 					return fmt.Errorf("calling custom parser: %w", err)
 				}
+
+				// The parser applies the default filters of the gadget, the
+				// ones of the user replace them on the same fields. They're
+				// then applied here only, not to the events of the remote
+				// nodes.
+				if len(filters) > 0 && len(gadgetInfo.DefaultFilters) > 0 {
+					filters = gadgetInfo.GadgetMetadata.Filters(filters)
+					if err := gadgetParams.Set(runTypes.DefaultFiltersParam, "false"); err != nil {
+						return fmt.Errorf("disabling default filters: %w", err)
+					}
+				}
 			}
 
 			if parser != nil {
@@ -421,7 +461,7 @@ func buildCommandFromGadget(
 				}
 			}
 
-			formatter := parser.GetTextColumnsFormatter()
+			formatter := parser.GetTextColumnsFormatter(textcolumns.WithRawValues(rawValues))
 
 			requestedStandardColumns := outputModeParams == ""
 			requestedColumns := make([]string, 0)
@@ -572,19 +612,19 @@ func buildCommandFromGadget(
 			case OutputModeJSON:
 				jsonCallback := printEventAsJSONFn(fe)
 				if isRunGadget {
-					jsonCallback = runGadgetDesc.JSONConverter(gadgetInfo, fe)
+					jsonCallback = runGadgetDesc.JSONConverter(gadgetInfo, fe, columns_json.WithRawValues(rawValues))
 				}
 				parser.SetEventCallback(jsonCallback)
 			case OutputModeJSONPretty:
 				jsonPrettyCallback := printEventAsJSONPrettyFn(fe)
 				if isRunGadget {
-					jsonPrettyCallback = runGadgetDesc.JSONPrettyConverter(gadgetInfo, fe)
+					jsonPrettyCallback = runGadgetDesc.JSONPrettyConverter(gadgetInfo, fe, columns_json.WithRawValues(rawValues))
 				}
 				parser.SetEventCallback(jsonPrettyCallback)
 			case OutputModeYAML:
 				yamlCallback := printEventAsYAMLFn(fe)
 				if isRunGadget {
-					yamlCallback = runGadgetDesc.YAMLConverter(gadgetInfo, fe)
+					yamlCallback = runGadgetDesc.YAMLConverter(gadgetInfo, fe, columns_json.WithRawValues(rawValues))
 				}
 				parser.SetEventCallback(yamlCallback)
 			}
@@ -592,6 +632,9 @@ func buildCommandFromGadget(
 			// Gadgets with parser don't return anything, they provide the
 			// output via the parser
 			_, err = runtime.RunGadget(gadgetCtx)
+			if lost := parser.LostSamples(); lost > 0 {
+				fe.Logf(logger.WarnLevel, "%d samples were lost in total while running the gadget", lost)
+			}
 			if err != nil {
 				return fmt.Errorf("running gadget: %w", err)
 			}
@@ -654,6 +697,13 @@ func buildCommandFromGadget(
                              see [https://github.com/google/re2/wiki/Syntax] for more information on the syntax
 `,
 		)
+
+		cmd.PersistentFlags().BoolVar(
+			&rawValues,
+			"raw-values",
+			false,
+			"Show the numbers with a unit, like sizes and durations, as they are instead of in a human readable form",
+		)
 	}
 
 	// Add alternative output formats available in the gadgets
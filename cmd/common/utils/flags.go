@@ -151,4 +151,11 @@ func AddRegistryAuthVariablesAndFlags(cmd *cobra.Command, authOptions *oci.AuthO
 		false,
 		"Allow connections to HTTP only registries",
 	)
+
+	cmd.Flags().StringVar(
+		&authOptions.RegistriesConfigFile,
+		"registries-config",
+		oci.DefaultRegistriesConfigFile,
+		"Path of the file with the TLS, plain HTTP and proxy settings of the registries",
+	)
 }
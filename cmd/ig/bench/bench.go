@@ -0,0 +1,292 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/bench"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/generator"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+)
+
+func NewBenchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Generate load to test the processing of the events",
+	}
+
+	cmd.AddCommand(newReplayCmd())
+	cmd.AddCommand(newSynthesizeCmd())
+	cmd.AddCommand(newGadgetCmd())
+
+	return cmd
+}
+
+// signalContext returns a context done on SIGINT or SIGTERM or, if timeout
+// isn't zero, after it.
+func signalContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	if timeout == 0 {
+		return ctx, cancel
+	}
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
+}
+
+func printStats(stats generator.Stats) {
+	fmt.Fprintf(os.Stderr, "%d events (%d errors) in %s: %.1f events/s\n",
+		stats.Events, stats.Errors, stats.Duration.Round(time.Millisecond), stats.Rate())
+}
+
+func newReplayCmd() *cobra.Command {
+	var config generator.ReplayConfig
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "replay FILE",
+		Short: "Replay the events recorded with -o json",
+		Long: "Replay the events recorded from a gadget with -o json on the standard output, one per line, " +
+			"e.g. to pipe them to a sink. They are replayed with their recorded timing, or at the given rate. " +
+			"Use - to read them from the standard input.",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("opening events: %w", err)
+				}
+				defer f.Close()
+				in = f
+			}
+
+			ctx, cancel := signalContext(timeout)
+			defer cancel()
+
+			out := bufio.NewWriter(os.Stdout)
+			stats, err := generator.Replay(ctx, in, func(event []byte) error {
+				if _, err := out.Write(event); err != nil {
+					return err
+				}
+				if err := out.WriteByte('\n'); err != nil {
+					return err
+				}
+				// Flush every event for the consumer to get them at the
+				// replay rate
+				return out.Flush()
+			}, config)
+			printStats(stats)
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Float64Var(&config.Rate, "rate", 0,
+		"Number of events replayed per second, 0 to replay them with their recorded timing")
+	cmd.Flags().Float64Var(&config.Speed, "speed", 1,
+		"Speed of the replay with the recorded timing, e.g. 2 for twice as fast, 0 for as fast as possible")
+	cmd.Flags().Uint64Var(&config.Count, "count", 0,
+		"Number of events to replay, looping over the recorded ones, 0 to replay them once")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0,
+		"Stop replaying after this duration, 0 to replay until all events are replayed")
+
+	return cmd
+}
+
+// addActivityFlags adds the flags configuring the synthesized activity.
+func addActivityFlags(cmd *cobra.Command, config *generator.SynthesizeConfig, activities *[]string) {
+	names := make([]string, 0, len(generator.Activities))
+	for _, activity := range generator.Activities {
+		names = append(names, string(activity))
+	}
+
+	cmd.Flags().StringSliceVar(activities, "activities", names,
+		fmt.Sprintf("Activities to synthesize in turn (%s)", strings.Join(names, ", ")))
+	cmd.Flags().Float64Var(&config.Rate, "rate", 100,
+		"Number of operations per second, 0 for as many as possible")
+	cmd.Flags().IntVar(&config.Namespaces, "namespaces", 1,
+		"Number of sets of namespaces to spread the activity across, 0 to use the ones of ig")
+	cmd.Flags().StringVar(&config.ExecPath, "exec-path", generator.DefaultExecPath,
+		"Program executed by the exec activity")
+}
+
+func newSynthesizeCmd() *cobra.Command {
+	var config generator.SynthesizeConfig
+	var activities []string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "synthesize",
+		Short: "Synthesize the activity traced by the gadgets",
+		Long: "Synthesize the activity traced by the gadgets, like executing programs, opening files and " +
+			"connecting to servers, at the given rate. The activity is spread across new mount and network " +
+			"namespaces to look like containers.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, activity := range activities {
+				config.Activities = append(config.Activities, generator.Activity(activity))
+			}
+
+			s, err := generator.NewSynthesizer(config)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			mntns := make([]string, 0, config.Namespaces)
+			for _, ns := range s.MountNamespaces() {
+				mntns = append(mntns, fmt.Sprint(ns))
+			}
+			fmt.Fprintf(os.Stderr, "Synthesizing activity in mount namespaces %s\n", strings.Join(mntns, ","))
+
+			ctx, cancel := signalContext(timeout)
+			defer cancel()
+
+			stats, err := s.Run(ctx)
+			printStats(stats)
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	addActivityFlags(cmd, &config, &activities)
+	cmd.Flags().Uint64Var(&config.Count, "count", 0,
+		"Number of operations to do, 0 to do them until interrupted")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0,
+		"Stop after this duration, 0 to run until interrupted or until --count operations are done")
+
+	return cmd
+}
+
+func newGadgetCmd() *cobra.Command {
+	var authOpts oci.AuthOptions
+	var config bench.Config
+	var activities []string
+	var ebpfObjectPath, metadataPath string
+
+	cmd := &cobra.Command{
+		Use:   "gadget [IMAGE]",
+		Short: "Measure the performance of a gadget against synthesized activity",
+		Long: "Run a gadget while synthesizing the activity it traces and print the sustained events per " +
+			"second, the CPU time per 10k events, the allocation rates and the percentage of lost events " +
+			"as JSON. The usage of the generator alone is measured first and subtracted. The image is " +
+			"pulled if it isn't in the local store. Use --ebpf-object and --metadata to measure the files " +
+			"of a gadget before building its image.",
+		SilenceUsage: true,
+		Args:         cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var metadata []byte
+			switch {
+			case len(args) == 1 && ebpfObjectPath == "" && metadataPath == "":
+				image, err := oci.GetGadgetImage(context.TODO(), args[0], &authOpts, oci.PullImageIfNotPresent)
+				if err != nil {
+					return fmt.Errorf("getting gadget image: %w", err)
+				}
+				config.Name = args[0]
+				config.ProgContent, metadata = image.EbpfObject, image.Metadata
+			case len(args) == 0 && ebpfObjectPath != "":
+				var err error
+				config.Name = ebpfObjectPath
+				config.ProgContent, err = os.ReadFile(ebpfObjectPath)
+				if err != nil {
+					return fmt.Errorf("reading eBPF object: %w", err)
+				}
+				if metadataPath != "" {
+					metadata, err = os.ReadFile(metadataPath)
+					if err != nil {
+						return fmt.Errorf("reading metadata: %w", err)
+					}
+				}
+			default:
+				return fmt.Errorf("expected either an image or --ebpf-object")
+			}
+
+			var err error
+			if len(metadata) == 0 {
+				// Generate the metadata from the object, like `ig run` does
+				// for the images without metadata
+				spec, err := ebpf.LoadCollectionSpecFromReader(bytes.NewReader(config.ProgContent))
+				if err != nil {
+					return fmt.Errorf("loading eBPF object: %w", err)
+				}
+				config.Metadata = &types.GadgetMetadata{}
+				if err := config.Metadata.Populate(spec); err != nil {
+					return fmt.Errorf("generating metadata: %w", err)
+				}
+			} else {
+				config.Metadata, err = types.DecodeMetadata(metadata, false)
+				if err != nil {
+					return fmt.Errorf("decoding metadata: %w", err)
+				}
+			}
+			for _, activity := range activities {
+				config.Activity.Activities = append(config.Activity.Activities, generator.Activity(activity))
+			}
+
+			ctx, cancel := signalContext(0)
+			defer cancel()
+
+			report, err := bench.Run(ctx, &config, logger.DefaultLogger())
+			if err != nil {
+				return err
+			}
+
+			b, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return utils.WrapInErrMarshalOutput(err)
+			}
+			fmt.Printf("%s\n", b)
+			return nil
+		},
+	}
+
+	addActivityFlags(cmd, &config.Activity, &activities)
+	cmd.Flags().DurationVar(&config.Duration, "duration", 10*time.Second,
+		"Duration of the measurement, the generator alone is measured for as long before")
+	cmd.Flags().Uint32Var(&config.BufferPages, "buffer-pages", 0,
+		"Size of the buffer of the gadget in pages, 0 to use the one of its metadata")
+	cmd.Flags().BoolVar(&config.SkipBaseline, "skip-baseline", false,
+		"Don't measure the generator alone, the usage reported includes it")
+	cmd.Flags().StringVar(&ebpfObjectPath, "ebpf-object", "", "Path of the eBPF object to measure instead of an image")
+	cmd.Flags().StringVar(&metadataPath, "metadata", "", "Path of the metadata file to use with --ebpf-object, generated from the object if not given")
+	utils.AddRegistryAuthVariablesAndFlags(cmd, &authOpts)
+
+	return cmd
+}
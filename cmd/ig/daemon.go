@@ -20,12 +20,16 @@ import (
 	"os/user"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	gadgetservice "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/budget"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
 )
 
@@ -39,6 +43,13 @@ func newDaemonCommand(runtime runtime.Runtime) *cobra.Command {
 
 	var socket string
 	var group string
+	var imageCacheMaxSize string
+	var imageCacheGCInterval time.Duration
+	var preloadImages []string
+	var metricsAddress string
+	var maxMemory string
+	var maxCPU string
+	var maxEventRate uint64
 
 	daemonCmd.PersistentFlags().StringVarP(
 		&group,
@@ -55,6 +66,48 @@ func newDaemonCommand(runtime runtime.Runtime) *cobra.Command {
 		"The socket to listen on for new requests. Can be a unix socket"+
 			" (unix:///path/to.socket) or a tcp socket (tcp://127.0.0.1:1234)")
 
+	daemonCmd.PersistentFlags().StringVar(
+		&imageCacheMaxSize,
+		"image-cache-max-size",
+		"",
+		"Size, e.g. 1Gi, above which the least recently used gadget images are removed from the host. Empty or 0 to disable")
+
+	daemonCmd.PersistentFlags().DurationVar(
+		&imageCacheGCInterval,
+		"image-cache-gc-interval",
+		5*time.Minute,
+		"How often the size of the gadget images cache is checked")
+
+	daemonCmd.PersistentFlags().StringSliceVar(
+		&preloadImages,
+		"preload-image",
+		[]string{},
+		"Gadget image to pull and verify at startup, so running it doesn't have to wait for the registry. Can be repeated")
+
+	daemonCmd.PersistentFlags().StringVar(
+		&metricsAddress,
+		"metrics-address",
+		"",
+		"Address, e.g. 0.0.0.0:2223, to serve the metrics of the gadgets on, like the number of events they lost. Empty to disable")
+
+	daemonCmd.PersistentFlags().StringVar(
+		&maxMemory,
+		"max-memory",
+		"",
+		"Resident memory, e.g. 512Mi, above which the daemon sheds load by sampling the events and pausing the gadgets with the lowest priority. Empty to disable")
+
+	daemonCmd.PersistentFlags().StringVar(
+		&maxCPU,
+		"max-cpu",
+		"",
+		"CPU usage, e.g. 500m or 2 cores, above which the daemon sheds load. Empty to disable")
+
+	daemonCmd.PersistentFlags().Uint64Var(
+		&maxEventRate,
+		"max-event-rate",
+		0,
+		"Number of events per second processed by all the gadgets above which the daemon sheds load. 0 to disable")
+
 	daemonCmd.RunE = func(cmd *cobra.Command, args []string) error {
 		if os.Geteuid() != 0 {
 			return fmt.Errorf("%s must be run as root to be able to run eBPF programs", filepath.Base(os.Args[0]))
@@ -77,12 +130,38 @@ func newDaemonCommand(runtime runtime.Runtime) *cobra.Command {
 			return fmt.Errorf("group %q not found", group)
 		}
 
+		var cacheMaxSize int64
+		if imageCacheMaxSize != "" {
+			quantity, err := resource.ParseQuantity(imageCacheMaxSize)
+			if err != nil {
+				return fmt.Errorf("invalid image cache max size %q: %w", imageCacheMaxSize, err)
+			}
+			if quantity.Sign() < 0 {
+				return fmt.Errorf("invalid image cache max size %q: must not be negative", imageCacheMaxSize)
+			}
+			cacheMaxSize = quantity.Value()
+		}
+		if imageCacheGCInterval <= 0 {
+			return fmt.Errorf("invalid image cache gc interval %s: must be positive", imageCacheGCInterval)
+		}
+
+		limits, err := budget.ParseLimits(maxMemory, maxCPU, maxEventRate)
+		if err != nil {
+			return err
+		}
+
 		log.Infof("starting Inspektor Gadget daemon at %q", socket)
 		service := gadgetservice.NewService(log.StandardLogger())
 		return service.Run(gadgetservice.RunConfig{
-			SocketType: socketType,
-			SocketPath: socketPath,
-			SocketGID:  gid,
+			SocketType:           socketType,
+			SocketPath:           socketPath,
+			SocketGID:            gid,
+			ImageCacheMaxSize:    cacheMaxSize,
+			ImageCacheGCInterval: imageCacheGCInterval,
+			PreloadImages:        preloadImages,
+			PreloadAuthOptions:   &oci.AuthOptions{AuthFile: oci.DefaultAuthFile},
+			MetricsListenAddress: metricsAddress,
+			Budget:               limits,
 		})
 	}
 
@@ -24,7 +24,9 @@ import (
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/environment/local"
 
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/catalog"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/image"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/ig/bench"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/ig/containers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/local"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/experimental"
@@ -38,6 +40,7 @@ import (
 	// Another blank import for the used operator
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/localmanager"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/prometheus"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/systemdresolver"
 )
 
 func main() {
@@ -64,7 +67,9 @@ func main() {
 
 	rootCmd.AddCommand(newDaemonCommand(runtime))
 	if experimental.Enabled() {
+		rootCmd.AddCommand(bench.NewBenchCmd())
 		rootCmd.AddCommand(image.NewImageCmd())
+		rootCmd.AddCommand(catalog.NewCatalogCmd())
 		rootCmd.AddCommand(common.NewLoginCmd())
 		rootCmd.AddCommand(common.NewLogoutCmd())
 	}
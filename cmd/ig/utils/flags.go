@@ -25,7 +25,6 @@ import (
 	containerutilsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -61,50 +60,45 @@ type CommonFlags struct {
 func AddCommonFlags(command *cobra.Command, commonFlags *CommonFlags) {
 	command.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		// Runtimes Configuration
-		parts := strings.Split(commonFlags.Runtimes, ",")
-
-	partsLoop:
-		for _, p := range parts {
-			runtimeName := types.String2RuntimeName(strings.TrimSpace(p))
-			socketPath := ""
-			namespace := ""
-
-			switch runtimeName {
-			case types.RuntimeNameDocker:
-				socketPath = commonFlags.RuntimesSocketPathConfig.Docker
-			case types.RuntimeNameContainerd:
-				socketPath = commonFlags.RuntimesSocketPathConfig.Containerd
-				namespace = commonFlags.ContainerdNamespace
-			case types.RuntimeNameCrio:
-				socketPath = commonFlags.RuntimesSocketPathConfig.Crio
-			case types.RuntimeNamePodman:
-				socketPath = commonFlags.RuntimesSocketPathConfig.Podman
-			default:
-				return commonutils.WrapInErrInvalidArg("--runtime / -r",
-					fmt.Errorf("runtime %q is not supported", p))
-			}
-
-			for _, r := range commonFlags.RuntimeConfigs {
-				if r.Name == runtimeName {
-					log.Infof("Ignoring duplicated runtime %q from %q",
-						runtimeName, commonFlags.Runtimes)
-					continue partsLoop
+		runtimes, err := containerutils.ParseRuntimes(
+			strings.Split(commonFlags.Runtimes, ","),
+			func(runtimeName types.RuntimeName, name string) (*containerutilsTypes.RuntimeConfig, error) {
+				socketPath := ""
+				namespace := ""
+
+				switch runtimeName {
+				case types.RuntimeNameDocker:
+					socketPath = commonFlags.RuntimesSocketPathConfig.Docker
+				case types.RuntimeNameContainerd:
+					socketPath = commonFlags.RuntimesSocketPathConfig.Containerd
+					namespace = commonFlags.ContainerdNamespace
+				case types.RuntimeNameCrio:
+					socketPath = commonFlags.RuntimesSocketPathConfig.Crio
+				case types.RuntimeNamePodman:
+					socketPath = commonFlags.RuntimesSocketPathConfig.Podman
+				default:
+					return nil, commonutils.WrapInErrInvalidArg("--runtime / -r",
+						fmt.Errorf("runtime %q is not supported", name))
 				}
-			}
 
-			r := &containerutilsTypes.RuntimeConfig{
-				Name:       runtimeName,
-				SocketPath: socketPath,
-			}
+				r := &containerutilsTypes.RuntimeConfig{
+					Name:       runtimeName,
+					SocketPath: socketPath,
+				}
 
-			if namespace != "" {
-				r.Extra = &containerutilsTypes.ExtraConfig{
-					Namespace: namespace,
+				if namespace != "" {
+					r.Extra = &containerutilsTypes.ExtraConfig{
+						Namespace: namespace,
+					}
 				}
-			}
 
-			commonFlags.RuntimeConfigs = append(commonFlags.RuntimeConfigs, r)
+				return r, nil
+			},
+		)
+		if err != nil {
+			return err
 		}
+		commonFlags.RuntimeConfigs = runtimes
 
 		// Output Mode
 		if err := commonFlags.ParseOutputConfig(); err != nil {
@@ -138,9 +132,9 @@ func AddCommonFlags(command *cobra.Command, commonFlags *CommonFlags) {
 	command.PersistentFlags().StringVarP(
 		&commonFlags.Runtimes,
 		"runtimes", "r",
-		strings.Join(containerutils.AvailableRuntimes, ","),
-		fmt.Sprintf("Container runtimes to be used separated by comma. Supported values are: %s",
-			strings.Join(containerutils.AvailableRuntimes, ", ")),
+		containerutils.AutoRuntimes,
+		fmt.Sprintf("Container runtimes to be used separated by comma. Supported values are: %s. %q uses all the runtimes whose socket is found",
+			strings.Join(containerutils.AvailableRuntimes, ", "), containerutils.AutoRuntimes),
 	)
 
 	command.PersistentFlags().IntVar(
@@ -154,7 +148,7 @@ func AddCommonFlags(command *cobra.Command, commonFlags *CommonFlags) {
 		&commonFlags.ContainerdNamespace,
 		"containerd-namespace",
 		constants.K8sContainerdNamespace,
-		"Namespace used by containerd",
+		"Namespaces used by containerd separated by comma, \"*\" to use all of them",
 	)
 }
 
@@ -17,11 +17,15 @@ package advise
 import (
 	"bufio"
 	"fmt"
+	"net"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+	networkingv1 "k8s.io/api/networking/v1"
 
 	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
@@ -41,9 +45,57 @@ var networkPolicyReportCmd = &cobra.Command{
 	RunE:  runNetworkPolicyReport,
 }
 
+var networkPolicyLiveCmd = &cobra.Command{
+	Use:   "live",
+	Short: "Generate network policies from the live network traffic",
+	Long: `Monitor the network traffic and refine the network policies as new traffic is seen.
+
+The current draft of the policies is printed when pressing Enter, and when it
+didn't change for the duration given by --stable-for.`,
+	RunE: runNetworkPolicyLive,
+}
+
+var networkPolicySimulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Report the recorded network activity that network policies would block",
+	Long: `Evaluate the recorded network activity against network policies, like the ones
+given by the report subcommand, to validate them before applying them.
+
+Only the networking.k8s.io/v1 NetworkPolicy resources are supported.`,
+	RunE: runNetworkPolicySimulate,
+}
+
 var (
-	inputFileName  string
-	outputFileName string
+	policiesFileName   string
+	inputFileName      string
+	dnsInputFileName   string
+	stableFor          time.Duration
+	outputFileName     string
+	outputDir          string
+	portRangeThreshold int
+	outputFormat       string
+	calicoOrder        float64
+	adminPriority      int32
+	mergeRules         bool
+
+	excludeSystemTraffic bool
+	excludedNamespaces   []string
+	excludedCIDRs        []string
+	sharedDNSPolicy      bool
+	nodeCIDRs            []string
+	podsInputFileName    string
+
+	labelsToIgnore         []string
+	labelsToIgnoreFileName string
+
+	coverageAnnotations bool
+	minEventCount       int
+	groupBy             string
+	policyTypes         []string
+	startTime           string
+	endTime             string
+	maxEventAge         time.Duration
+	defaultDenyPolicy   bool
 )
 
 func newNetworkPolicyCmd() *cobra.Command {
@@ -57,12 +109,185 @@ func newNetworkPolicyCmd() *cobra.Command {
 	networkPolicyMonitorCmd.PersistentFlags().StringVarP(&outputFileName, "output", "", "-", "File name output")
 
 	networkPolicyCmd.AddCommand(networkPolicyReportCmd)
-	networkPolicyReportCmd.PersistentFlags().StringVarP(&inputFileName, "input", "", "", "File with recorded network activity")
+	networkPolicyReportCmd.PersistentFlags().StringVarP(&inputFileName, "input", "", "", "File with recorded network activity, - for the standard input")
+	networkPolicyReportCmd.PersistentFlags().StringVarP(&dnsInputFileName, "dns-input", "", "",
+		"File with the DNS traffic recorded by the trace dns gadget, to allow the egress traffic by name with Cilium")
 	networkPolicyReportCmd.PersistentFlags().StringVarP(&outputFileName, "output", "", "-", "File name output")
+	networkPolicyReportCmd.PersistentFlags().StringVarP(&outputDir, "output-dir", "", "",
+		"Directory to write each policy into a file of its own, <namespace>/<name>.yaml")
+	addAdvisorFlags(networkPolicyReportCmd)
+
+	networkPolicyCmd.AddCommand(networkPolicyLiveCmd)
+	networkPolicyLiveCmd.PersistentFlags().StringVarP(&outputFileName, "output", "", "-", "File name output, overwritten with each draft")
+	networkPolicyLiveCmd.PersistentFlags().DurationVarP(&stableFor, "stable-for", "", 30*time.Second,
+		"Print the draft of the policies when it didn't change for this duration (0 to only print it on demand)")
+	addAdvisorFlags(networkPolicyLiveCmd)
+
+	networkPolicyCmd.AddCommand(networkPolicySimulateCmd)
+	networkPolicySimulateCmd.PersistentFlags().StringVarP(&inputFileName, "input", "", "", "File with recorded network activity, - for the standard input")
+	networkPolicySimulateCmd.PersistentFlags().StringVarP(&policiesFileName, "policies", "", "", "File with the network policies to simulate")
+	networkPolicySimulateCmd.PersistentFlags().StringVarP(&podsInputFileName, "pods-input", "", "",
+		"File with the pods of the cluster, as given by 'kubectl get pods -A -o yaml', to resolve the named ports of the policies")
 
 	return networkPolicyCmd
 }
 
+// addAdvisorFlags adds the flags configuring the generation of the policies
+func addAdvisorFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().IntVarP(&portRangeThreshold, "port-range-threshold", "", advisor.DefaultPortRangeThreshold,
+		"Minimum number of contiguous ports of a peer allowed by a single rule with a port range (0 to disable)")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output-format", "", advisor.OutputFormatKubernetes,
+		fmt.Sprintf("Format of the generated policies [%s]", strings.Join(advisor.OutputFormats, ", ")))
+	cmd.PersistentFlags().Float64VarP(&calicoOrder, "calico-order", "", advisor.DefaultCalicoOrder,
+		"Order of the generated Calico policies")
+	cmd.PersistentFlags().Int32VarP(&adminPriority, "admin-priority", "", advisor.DefaultAdminPriority,
+		"Priority of the generated AdminNetworkPolicy resources, from 0 to 1000")
+	cmd.PersistentFlags().BoolVarP(&mergeRules, "merge-rules", "", true,
+		"Merge the rules allowing the same peers or the same ports")
+	cmd.PersistentFlags().BoolVarP(&excludeSystemTraffic, "exclude-system-traffic", "", false,
+		"Don't generate rules for the traffic from and to the namespaces of --excluded-namespaces and the addresses of --excluded-cidrs")
+	cmd.PersistentFlags().StringSliceVarP(&excludedNamespaces, "excluded-namespaces", "", advisor.DefaultExcludedNamespaces,
+		"Namespaces excluded by --exclude-system-traffic")
+	cmd.PersistentFlags().StringSliceVarP(&excludedCIDRs, "excluded-cidrs", "", advisor.DefaultExcludedCIDRs,
+		"CIDRs excluded by --exclude-system-traffic")
+	cmd.PersistentFlags().BoolVarP(&sharedDNSPolicy, "shared-dns-policy", "", false,
+		"Allow, in the policy of each pod, the DNS servers queried by any pod of its namespace with a single rule")
+	cmd.PersistentFlags().StringSliceVarP(&nodeCIDRs, "node-cidrs", "", nil,
+		"CIDRs of the nodes, allowed instead of the addresses of the nodes and of the hostNetwork pods")
+	cmd.PersistentFlags().StringVarP(&podsInputFileName, "pods-input", "", "",
+		"File with the pods of the cluster, as given by 'kubectl get pods -A -o yaml', to allow the named container ports by name. "+
+			"With their workloads, as given by 'kubectl get pods,replicasets,deployments,statefulsets,daemonsets -A -o yaml', for --group-by owner")
+	cmd.PersistentFlags().StringVarP(&groupBy, "group-by", "", advisor.GroupByLabels,
+		fmt.Sprintf("How the pods are grouped in policies [%s]: by labels, or by the workload owning them, given by --pods-input",
+			strings.Join(advisor.GroupByStrategies, ", ")))
+	cmd.PersistentFlags().StringSliceVarP(&labelsToIgnore, "labels-to-ignore", "", nil,
+		"Labels ignored to group the pods and to select the peers, in addition to the default ones: label keys, globs like '*.argoproj.io/*' or regular expressions between slashes")
+	cmd.PersistentFlags().StringVarP(&labelsToIgnoreFileName, "labels-to-ignore-file", "", "",
+		"File with the labels to ignore, one by line, with the syntax of --labels-to-ignore")
+	cmd.PersistentFlags().BoolVarP(&coverageAnnotations, "coverage-annotations", "", false,
+		"Annotate the policies with the number of events allowed by each of their rules and when they were seen")
+	cmd.PersistentFlags().StringSliceVarP(&policyTypes, "policy-types", "", []string{"ingress", "egress"},
+		"Types of the generated policies, ingress or egress, not restricting the traffic of the other type")
+	cmd.PersistentFlags().BoolVarP(&defaultDenyPolicy, "default-deny-policy", "", false,
+		fmt.Sprintf("Add a policy named %q to each namespace denying the traffic of all its pods but the one allowed by the other policies", advisor.DefaultDenyPolicyName))
+	cmd.PersistentFlags().StringVarP(&startTime, "start-time", "", "",
+		"Only use the events seen after this time, in RFC 3339 format like 2023-11-14T22:00:00Z")
+	cmd.PersistentFlags().StringVarP(&endTime, "end-time", "", "",
+		"Only use the events seen before this time, in RFC 3339 format like 2023-11-14T23:00:00Z")
+	cmd.PersistentFlags().DurationVarP(&maxEventAge, "max-event-age", "", 0,
+		"Only use the events younger than this duration, like 72h (0 to use all the events)")
+	cmd.PersistentFlags().IntVarP(&minEventCount, "min-event-count", "", 0,
+		"Minimum number of events of a connection to the same peer, port and protocol for it to be allowed")
+}
+
+// newAdvisor returns an advisor configured by the flags of addAdvisorFlags()
+func newAdvisor() (*advisor.NetworkPolicyAdvisor, error) {
+	if portRangeThreshold < 0 {
+		return nil, fmt.Errorf("invalid --port-range-threshold %d: must be positive or 0", portRangeThreshold)
+	}
+
+	if !slices.Contains(advisor.OutputFormats, outputFormat) {
+		return nil, fmt.Errorf("invalid --output-format %q: must be one of %s", outputFormat, strings.Join(advisor.OutputFormats, ", "))
+	}
+
+	if !slices.Contains(advisor.GroupByStrategies, groupBy) {
+		return nil, fmt.Errorf("invalid --group-by %q: must be one of %s", groupBy, strings.Join(advisor.GroupByStrategies, ", "))
+	}
+	if groupBy == advisor.GroupByOwner && podsInputFileName == "" {
+		return nil, fmt.Errorf("--group-by %s requires --pods-input", advisor.GroupByOwner)
+	}
+
+	advPolicyTypes := []networkingv1.PolicyType{}
+	for _, t := range policyTypes {
+		switch strings.ToLower(t) {
+		case "ingress":
+			advPolicyTypes = append(advPolicyTypes, networkingv1.PolicyTypeIngress)
+		case "egress":
+			advPolicyTypes = append(advPolicyTypes, networkingv1.PolicyTypeEgress)
+		default:
+			return nil, fmt.Errorf("invalid --policy-types %q: must be ingress or egress", t)
+		}
+	}
+	if len(advPolicyTypes) == 0 {
+		return nil, fmt.Errorf("invalid --policy-types: at least one type is needed")
+	}
+
+	var start, end time.Time
+	if startTime != "" {
+		var err error
+		start, err = time.Parse(time.RFC3339, startTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --start-time %q: %w", startTime, err)
+		}
+	}
+	if endTime != "" {
+		var err error
+		end, err = time.Parse(time.RFC3339, endTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --end-time %q: %w", endTime, err)
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return nil, fmt.Errorf("invalid --start-time %q: must be before --end-time %q", startTime, endTime)
+	}
+	if maxEventAge < 0 {
+		return nil, fmt.Errorf("invalid --max-event-age %s: must be positive or 0", maxEventAge)
+	}
+
+	if minEventCount < 0 {
+		return nil, fmt.Errorf("invalid --min-event-count %d: must be positive or 0", minEventCount)
+	}
+
+	if adminPriority < 0 || adminPriority > 1000 {
+		return nil, fmt.Errorf("invalid --admin-priority %d: must be between 0 and 1000", adminPriority)
+	}
+
+	for _, cidr := range excludedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid --excluded-cidrs %q: %w", cidr, err)
+		}
+	}
+	for _, cidr := range nodeCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid --node-cidrs %q: %w", cidr, err)
+		}
+	}
+
+	adv := advisor.NewAdvisor()
+	adv.PortRangeThreshold = portRangeThreshold
+	adv.OutputFormat = outputFormat
+	adv.CalicoOrder = calicoOrder
+	adv.AdminPriority = adminPriority
+	adv.MergeRules = mergeRules
+	adv.ExcludeSystemTraffic = excludeSystemTraffic
+	adv.ExcludedNamespaces = excludedNamespaces
+	adv.ExcludedCIDRs = excludedCIDRs
+	adv.SharedDNSPolicy = sharedDNSPolicy
+	adv.NodeCIDRs = nodeCIDRs
+	adv.CoverageAnnotations = coverageAnnotations
+	adv.MinEventCount = minEventCount
+	adv.GroupBy = groupBy
+	adv.PolicyTypes = advPolicyTypes
+	adv.StartTime = start
+	adv.EndTime = end
+	adv.MaxEventAge = maxEventAge
+	adv.DefaultDenyPolicy = defaultDenyPolicy
+	if err := adv.IgnoreLabels(labelsToIgnore...); err != nil {
+		return nil, fmt.Errorf("invalid --labels-to-ignore: %w", err)
+	}
+	if labelsToIgnoreFileName != "" {
+		if err := adv.LoadLabelsToIgnoreFile(labelsToIgnoreFileName); err != nil {
+			return nil, fmt.Errorf("loading labels to ignore from %q: %w", labelsToIgnoreFileName, err)
+		}
+	}
+	if podsInputFileName != "" {
+		if err := adv.LoadPodsFile(podsInputFileName); err != nil {
+			return nil, fmt.Errorf("loading pods from %q: %w", podsInputFileName, err)
+		}
+	}
+	return adv, nil
+}
+
 func newWriter(file string) (*bufio.Writer, func(), error) {
 	var w *bufio.Writer
 	var closure func()
@@ -119,26 +344,59 @@ func runNetworkPolicyMonitor(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// loadInput loads the events of --input, read as they come from the standard
+// input with -
+func loadInput(adv *advisor.NetworkPolicyAdvisor) error {
+	if inputFileName == "-" {
+		if err := adv.LoadEvents(os.Stdin); err != nil {
+			return fmt.Errorf("reading the standard input: %w", err)
+		}
+		return nil
+	}
+	return adv.LoadFile(inputFileName)
+}
+
 func runNetworkPolicyReport(cmd *cobra.Command, args []string) error {
 	if inputFileName == "" {
 		return commonutils.WrapInErrMissingArgs("--input")
 	}
 
-	adv := advisor.NewAdvisor()
-	err := adv.LoadFile(inputFileName)
+	if outputDir != "" && outputFileName != "-" {
+		return fmt.Errorf("--output and --output-dir can't be used together")
+	}
+
+	adv, err := newAdvisor()
+	if err != nil {
+		return err
+	}
+	err = loadInput(adv)
 	if err != nil {
 		return err
 	}
+	if dnsInputFileName != "" {
+		err = adv.LoadDNSFile(dnsInputFileName)
+		if err != nil {
+			return err
+		}
+	}
 
 	adv.GeneratePolicies()
 
+	if outputDir != "" {
+		err = adv.WritePolicies(advisor.NewDirectoryWriter(outputDir))
+		if err != nil {
+			return fmt.Errorf("writing policies into directory %q: %w", outputDir, err)
+		}
+		return nil
+	}
+
 	w, closure, err := newWriter(outputFileName)
 	if err != nil {
 		return fmt.Errorf("creating file %q: %w", outputFileName, err)
 	}
 	defer closure()
 
-	_, err = w.Write([]byte(adv.FormatPolicies()))
+	err = adv.WritePolicies(advisor.NewStreamWriter(w))
 	if err != nil {
 		return fmt.Errorf("writing file %q: %w", outputFileName, err)
 	}
@@ -149,3 +407,160 @@ func runNetworkPolicyReport(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runNetworkPolicySimulate(cmd *cobra.Command, args []string) error {
+	if inputFileName == "" {
+		return commonutils.WrapInErrMissingArgs("--input")
+	}
+	if policiesFileName == "" {
+		return commonutils.WrapInErrMissingArgs("--policies")
+	}
+
+	adv := advisor.NewAdvisor()
+	err := loadInput(adv)
+	if err != nil {
+		return err
+	}
+	if podsInputFileName != "" {
+		err = adv.LoadPodsFile(podsInputFileName)
+		if err != nil {
+			return fmt.Errorf("loading pods from %q: %w", podsInputFileName, err)
+		}
+	}
+	policies, err := advisor.LoadPoliciesFile(policiesFileName)
+	if err != nil {
+		return fmt.Errorf("loading policies from %q: %w", policiesFileName, err)
+	}
+
+	blocked := adv.Simulate(policies)
+	for _, c := range blocked {
+		fmt.Printf("BLOCKED %s\n", c)
+	}
+	fmt.Printf("%d connections would be blocked by the %d policies\n", len(blocked), len(policies))
+	return nil
+}
+
+// liveDraft is the draft of the policies of the live mode, refined as new
+// events are received
+type liveDraft struct {
+	mu       sync.Mutex
+	adv      *advisor.NetworkPolicyAdvisor
+	newEvent bool
+
+	policies   string
+	changed    time.Time
+	printed    string
+	hasPrinted bool
+}
+
+func (d *liveDraft) addEvents(line string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	line = strings.Replace(line, "\r", "\n", -1)
+	if err := d.adv.AddEvents([]byte(line)); err != nil {
+		return err
+	}
+	d.newEvent = true
+	return nil
+}
+
+// refresh generates the policies again if new events were received since the
+// last time
+func (d *liveDraft) refresh() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.newEvent {
+		return
+	}
+	d.newEvent = false
+	d.adv.GeneratePolicies()
+	if policies := d.adv.FormatPolicies(); policies != d.policies {
+		d.policies = policies
+		d.changed = time.Now()
+	}
+}
+
+// stable tells whether the draft didn't change for the given duration since it
+// was last printed
+func (d *liveDraft) stable(duration time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.policies == "" || (d.hasPrinted && d.policies == d.printed) {
+		return false
+	}
+	return time.Since(d.changed) >= duration
+}
+
+func (d *liveDraft) print() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.printed = d.policies
+	d.hasPrinted = true
+
+	if outputFileName != "-" {
+		if err := os.WriteFile(outputFileName, []byte(d.policies), 0o644); err != nil {
+			return fmt.Errorf("writing file %q: %w", outputFileName, err)
+		}
+		fmt.Printf("\033[2K\rDraft of %s written into file %q", time.Now().Format(time.TimeOnly), outputFileName)
+		return nil
+	}
+
+	fmt.Printf("# Draft of %s\n%s---\n", time.Now().Format(time.TimeOnly), d.policies)
+	return nil
+}
+
+func runNetworkPolicyLive(cmd *cobra.Command, args []string) error {
+	adv, err := newAdvisor()
+	if err != nil {
+		return err
+	}
+	draft := &liveDraft{adv: adv, changed: time.Now()}
+
+	// Print the draft on demand
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			draft.refresh()
+			if err := draft.print(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			}
+		}
+	}()
+
+	// Print the draft when it's stable
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			draft.refresh()
+			if stableFor == 0 || !draft.stable(stableFor) {
+				continue
+			}
+			if err := draft.print(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			}
+		}
+	}()
+
+	config := &utils.TraceConfig{
+		GadgetName:       "network-graph",
+		Operation:        gadgetv1alpha1.OperationStart,
+		TraceOutputMode:  gadgetv1alpha1.TraceOutputModeStream,
+		TraceOutputState: gadgetv1alpha1.TraceStateStarted,
+		CommonFlags:      &params,
+	}
+
+	err = utils.RunTraceStreamCallback(config, func(line string, node string) {
+		if err := draft.addEvents(line); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: parsing event from node %q: %s\n", node, err)
+		}
+	})
+	if err != nil {
+		return commonutils.WrapInErrRunGadget(err)
+	}
+	return nil
+}
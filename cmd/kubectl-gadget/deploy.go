@@ -19,16 +19,19 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	yamlv2 "gopkg.in/yaml.v2"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
@@ -52,7 +55,9 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common"
 	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/budget"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/resources"
 	grpcruntime "github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/grpc"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/experimental"
@@ -84,9 +89,18 @@ var (
 	nodeSelector        string
 	experimentalVar     bool
 	skipSELinuxOpts     bool
+	registriesConfig    string
+	registryMirrors     []string
+	imageCacheMaxSize   string
+	preloadImages       []string
+	btfHubDownload      bool
+	btfHubURL           string
+	maxMemory           string
+	maxCPU              string
+	maxEventRate        uint64
 )
 
-var supportedHooks = []string{"auto", "crio", "podinformer", "nri", "fanotify", "fanotify+ebpf"}
+var supportedHooks = []string{"auto", "crio", "podinformer", "nri", "fanotify", "fanotify+ebpf", "cri-events"}
 
 func init() {
 	commonutils.AddRuntimesSocketPathFlags(deployCmd, &runtimesConfig)
@@ -162,6 +176,51 @@ func init() {
 		"skip-selinux-opts", "",
 		false,
 		"skip setting SELinux options on the gadget pod")
+	deployCmd.PersistentFlags().StringVarP(
+		&registriesConfig,
+		"registries-config", "",
+		"",
+		"file with the TLS, plain HTTP and proxy settings of the registries gadget images are pulled from")
+	deployCmd.PersistentFlags().StringSliceVarP(
+		&registryMirrors,
+		"registry-mirror", "",
+		[]string{},
+		"mirror to pull the gadget images of a registry from, in the REGISTRY=MIRROR format, e.g. ghcr.io=proxy.corp/ghcr. Can be repeated")
+	deployCmd.PersistentFlags().StringVarP(
+		&imageCacheMaxSize,
+		"image-cache-max-size", "",
+		"1Gi",
+		"maximum size of the gadget image cache on each node, e.g. 500Mi. The least recently used images are removed above it. Empty disables the garbage collection")
+	deployCmd.PersistentFlags().StringSliceVarP(
+		&preloadImages,
+		"preload-image", "",
+		[]string{},
+		"gadget image to pull and verify on every node when the gadget pods start, so running it doesn't have to wait for the registry. Can be repeated")
+	deployCmd.PersistentFlags().BoolVarP(
+		&btfHubDownload,
+		"btfhub-download", "",
+		false,
+		"download the BTF information from BTFHub on the nodes whose kernel doesn't expose it")
+	deployCmd.PersistentFlags().StringVarP(
+		&btfHubURL,
+		"btfhub-url", "",
+		"",
+		"URL of the BTFHub archive to download the BTF information from, e.g. a mirror. Empty to use the default one")
+	deployCmd.PersistentFlags().StringVarP(
+		&maxMemory,
+		"max-memory", "",
+		"",
+		"resident memory of the gadget pods, e.g. 512Mi, above which they shed load by sampling the events and pausing the gadgets with the lowest priority. Empty to disable")
+	deployCmd.PersistentFlags().StringVarP(
+		&maxCPU,
+		"max-cpu", "",
+		"",
+		"CPU usage of the gadget pods, e.g. 500m, above which they shed load. Empty to disable")
+	deployCmd.PersistentFlags().Uint64VarP(
+		&maxEventRate,
+		"max-event-rate", "",
+		0,
+		"number of events per second processed by all the gadgets of a node above which its gadget pod sheds load. 0 to disable")
 	rootCmd.AddCommand(deployCmd)
 }
 
@@ -339,6 +398,68 @@ func createAffinity(client *kubernetes.Clientset) (*v1.Affinity, error) {
 	return affinity, nil
 }
 
+// newRegistriesConfigMap creates the ConfigMap with the registries
+// configuration used by the gadget pods, from the given file and mirrors. The
+// CA files are inlined, as they aren't available in the pods.
+func newRegistriesConfigMap(path string, mirrors []string) (*v1.ConfigMap, error) {
+	config := &oci.RegistriesConfig{}
+	if path != "" {
+		var err error
+		config, err = oci.LoadRegistriesConfig(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Registries == nil {
+		config.Registries = map[string]*oci.RegistryConfig{}
+	}
+	for host, registry := range config.Registries {
+		if registry.CAFile == "" {
+			continue
+		}
+		ca, err := os.ReadFile(registry.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file of registry %q: %w", host, err)
+		}
+		registry.CA = strings.TrimSpace(registry.CA + "\n" + string(ca))
+		registry.CAFile = ""
+	}
+	for _, value := range mirrors {
+		host, mirror, ok := strings.Cut(value, "=")
+		if !ok || host == "" || mirror == "" {
+			return nil, fmt.Errorf("invalid registry mirror %q: expected REGISTRY=MIRROR", value)
+		}
+		registry, ok := config.Registries[host]
+		if !ok {
+			registry = &oci.RegistryConfig{}
+			config.Registries[host] = registry
+		}
+		registry.Mirrors = append(registry.Mirrors, mirror)
+	}
+	data, err := yamlv2.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling registries config: %w", err)
+	}
+	// Validate the result, e.g. the mirrors
+	if _, err := oci.ParseRegistriesConfig(data); err != nil {
+		return nil, err
+	}
+
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gadget-registries",
+			Namespace: utils.GadgetNamespace,
+		},
+		Data: map[string]string{
+			"registries.yaml": string(data),
+		},
+	}, nil
+}
+
 func runDeploy(cmd *cobra.Command, args []string) error {
 	found := false
 	for _, supportedHook := range supportedHooks {
@@ -356,6 +477,20 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("it's not possible to use --quiet and --debug together")
 	}
 
+	if imageCacheMaxSize != "" {
+		size, err := resource.ParseQuantity(imageCacheMaxSize)
+		if err != nil {
+			return fmt.Errorf("invalid argument %q for --image-cache-max-size: %w", imageCacheMaxSize, err)
+		}
+		if size.Sign() < 0 {
+			return fmt.Errorf("invalid argument %q for --image-cache-max-size: it can't be negative", imageCacheMaxSize)
+		}
+	}
+
+	if _, err := budget.ParseLimits(maxMemory, maxCPU, maxEventRate); err != nil {
+		return fmt.Errorf("invalid resource budget: %w", err)
+	}
+
 	objects, err := parseK8sYaml(resources.GadgetDeployment)
 	if err != nil {
 		return err
@@ -368,6 +503,21 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 
 	objects = append(objects, traceObjects...)
 
+	if registriesConfig != "" || len(registryMirrors) > 0 {
+		configMap, err := newRegistriesConfigMap(registriesConfig, registryMirrors)
+		if err != nil {
+			return err
+		}
+		// Create it before the DaemonSet, as nothing else is applied when the
+		// latter wasn't modified.
+		for i, object := range objects {
+			if _, ok := object.(*appsv1.DaemonSet); ok {
+				objects = append(objects[:i], append([]runtime.Object{configMap}, objects[i:]...)...)
+				break
+			}
+		}
+	}
+
 	config, err := utils.KubernetesConfigFlags.ToRESTConfig()
 	if err != nil {
 		return fmt.Errorf("creating RESTConfig: %w", err)
@@ -440,6 +590,20 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 					gadgetContainer.Env[i].Value = hookMode
 				case "INSPEKTOR_GADGET_OPTION_FALLBACK_POD_INFORMER":
 					gadgetContainer.Env[i].Value = strconv.FormatBool(fallbackPodInformer)
+				case "INSPEKTOR_GADGET_OPTION_IMAGE_CACHE_MAX_SIZE":
+					gadgetContainer.Env[i].Value = imageCacheMaxSize
+				case "INSPEKTOR_GADGET_OPTION_PRELOAD_IMAGES":
+					gadgetContainer.Env[i].Value = strings.Join(preloadImages, ",")
+				case "INSPEKTOR_GADGET_OPTION_MAX_MEMORY":
+					gadgetContainer.Env[i].Value = maxMemory
+				case "INSPEKTOR_GADGET_OPTION_MAX_CPU":
+					gadgetContainer.Env[i].Value = maxCPU
+				case "INSPEKTOR_GADGET_OPTION_MAX_EVENT_RATE":
+					gadgetContainer.Env[i].Value = strconv.FormatUint(maxEventRate, 10)
+				case "IG_BTFHUB_DOWNLOAD":
+					gadgetContainer.Env[i].Value = strconv.FormatBool(btfHubDownload)
+				case "IG_BTFHUB_URL":
+					gadgetContainer.Env[i].Value = btfHubURL
 				case utils.GadgetEnvironmentContainerdSocketpath:
 					gadgetContainer.Env[i].Value = runtimesConfig.Containerd
 				case utils.GadgetEnvironmentCRIOSocketpath:
@@ -16,6 +16,7 @@ package main
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -30,3 +31,21 @@ func TestPrintOnly(t *testing.T) {
 		t.Fatalf("Error while running command: %s", stdErr.String())
 	}
 }
+
+func TestNewRegistriesConfigMap(t *testing.T) {
+	configMap, err := newRegistriesConfigMap("", []string{"ghcr.io=proxy.corp/ghcr", "ghcr.io=other.corp"})
+	if err != nil {
+		t.Fatalf("Error creating ConfigMap: %s", err)
+	}
+	expected := "registries:\n  ghcr.io:\n"
+	if data := configMap.Data["registries.yaml"]; !strings.HasPrefix(data, expected) ||
+		!strings.Contains(data, "mirrors:\n    - proxy.corp/ghcr\n    - other.corp\n") {
+		t.Fatalf("Unexpected registries config:\n%s", data)
+	}
+
+	for _, mirror := range []string{"ghcr.io", "ghcr.io=https://proxy.corp", "ghcr.io=proxy"} {
+		if _, err := newRegistriesConfigMap("", []string{mirror}); err == nil {
+			t.Fatalf("Expected error for mirror %q", mirror)
+		}
+	}
+}
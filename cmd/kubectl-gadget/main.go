@@ -25,6 +25,7 @@ import (
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/environment/k8s"
 
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/common/catalog"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/advise"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -44,7 +45,7 @@ var rootCmd = &cobra.Command{
 	Short: "Collection of gadgets for Kubernetes developers",
 }
 
-var infoSkipCommands = []string{"deploy", "undeploy", "version"}
+var infoSkipCommands = []string{"deploy", "undeploy", "version", "catalog"}
 
 func init() {
 	utils.FlagInit(rootCmd)
@@ -93,6 +94,10 @@ func main() {
 
 	rootCmd.AddCommand(common.NewSyncCommand(runtime))
 
+	if experimental.Enabled() {
+		rootCmd.AddCommand(catalog.NewCatalogCmd())
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -70,10 +71,24 @@ var traceloopDeleteCmd = &cobra.Command{
 	RunE:  runTraceloopDelete,
 }
 
+var (
+	traceloopSyscallFilters []string
+	traceloopMinLatency     time.Duration
+	traceloopSince          time.Duration
+	traceloopUntil          time.Duration
+	traceloopStrace         bool
+)
+
 func init() {
 	rootCmd.AddCommand(traceloopCmd)
 	utils.AddCommonFlags(traceloopCmd, &params)
 
+	traceloopShowCmd.Flags().StringSliceVar(&traceloopSyscallFilters, traceloopTypes.ParamSyscallFilters, []string{}, "Only show the given syscalls, e.g. openat,read")
+	traceloopShowCmd.Flags().DurationVar(&traceloopMinLatency, traceloopTypes.ParamMinLatency, 0, "Only show syscalls that took longer than the given duration, e.g. 10ms")
+	traceloopShowCmd.Flags().DurationVar(&traceloopSince, traceloopTypes.ParamSince, 0, "Only show syscalls issued during the given duration, e.g. 5m")
+	traceloopShowCmd.Flags().DurationVar(&traceloopUntil, traceloopTypes.ParamUntil, 0, "Only show syscalls issued earlier than the given duration, e.g. 1m")
+	traceloopShowCmd.Flags().BoolVar(&traceloopStrace, "strace", false, "Show the syscalls in a strace-like format")
+
 	traceloopCmd.AddCommand(traceloopStartCmd)
 	traceloopCmd.AddCommand(traceloopStopCmd)
 	traceloopCmd.AddCommand(traceloopListCmd)
@@ -226,7 +241,7 @@ func runTraceloopShow(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if params.OutputMode != commonutils.OutputModeJSON {
+	if params.OutputMode != commonutils.OutputModeJSON && !traceloopStrace {
 		fmt.Println(parser.BuildColumnsHeader())
 	}
 
@@ -246,8 +261,8 @@ func runTraceloopShow(cmd *cobra.Command, args []string) error {
 				return ""
 			}
 
-			switch params.OutputMode {
-			case commonutils.OutputModeJSON:
+			switch {
+			case params.OutputMode == commonutils.OutputModeJSON:
 				b, err := json.Marshal(event)
 				if err != nil {
 					fmt.Fprint(os.Stderr, fmt.Sprint(commonutils.WrapInErrMarshalOutput(err)))
@@ -255,7 +270,9 @@ func runTraceloopShow(cmd *cobra.Command, args []string) error {
 				}
 
 				fmt.Println(string(b))
-			case commonutils.OutputModeCustomColumns:
+			case traceloopStrace:
+				fmt.Println(traceloopTypes.StraceLine(&event))
+			case params.OutputMode == commonutils.OutputModeCustomColumns:
 				fmt.Println(parser.TransformIntoColumns(&event))
 			}
 		}
@@ -316,8 +333,12 @@ func runTraceloopShow(cmd *cobra.Command, args []string) error {
 				// Nonetheless when Start()'ed, the used name was the namespaced one:
 				// https://github.com/inspektor-gadget/inspektor-gadget/blob/9532d507bbd741f6202e1945db20cb6d1471e0ac/pkg/controllers/trace_controller.go#L253
 				// So, we need to use the namespace here too.
-				"name":        fmt.Sprintf("%s/%s", trace.Namespace, trace.Name),
-				"containerID": containerID,
+				"name":                             fmt.Sprintf("%s/%s", trace.Namespace, trace.Name),
+				"containerID":                      containerID,
+				traceloopTypes.ParamSyscallFilters: strings.Join(traceloopSyscallFilters, ","),
+				traceloopTypes.ParamMinLatency:     traceloopMinLatency.String(),
+				traceloopTypes.ParamSince:          traceloopSince.String(),
+				traceloopTypes.ParamUntil:          traceloopUntil.String(),
 			},
 			AdditionalLabels: map[string]string{
 				labels.LabelType: labels.LabelCollecting,
@@ -48,8 +48,10 @@ fi
 rm -f /host/opt/nri/bin/nrigadget
 
 # This is a last resource to remove all possible pinned ebpf objects created by
-# Inspektor Gadget & Traceloop
-rm -rf /sys/fs/bpf/gadget/
+# Inspektor Gadget & Traceloop. The maps pinned by the gadgets in
+# /sys/fs/bpf/gadget/maps are kept, so their state survives the upgrades of the
+# gadget pods. Keep in sync with pkg/gadgets/consts.go.
+find /sys/fs/bpf/gadget/ -mindepth 1 -maxdepth 1 ! -name maps -exec rm -rf {} + 2>/dev/null
 rm -rf /sys/fs/bpf/straceback/
 
 echo "Cleanup completed"
@@ -106,8 +106,8 @@ if [ "$HOOK_MODE" = "crio" ] || [ "$HOOK_MODE" = "nri" ] ; then
   # For crio and nri, the gadgettracermanager process can passively wait for
   # the gRPC calls without monitoring containers itself.
   GADGET_TRACER_MANAGER_HOOK_MODE=none
-elif [ "$HOOK_MODE" = "fanotify" ] || [ "$HOOK_MODE" = "fanotify+ebpf" ] || [ "$HOOK_MODE" = "podinformer" ] ; then
-  # fanotify, fanotify+ebpf and podinformer are implemented in the
+elif [ "$HOOK_MODE" = "fanotify" ] || [ "$HOOK_MODE" = "fanotify+ebpf" ] || [ "$HOOK_MODE" = "podinformer" ] || [ "$HOOK_MODE" = "cri-events" ] ; then
+  # fanotify, fanotify+ebpf, podinformer and cri-events are implemented in the
   # gadgettracermanager process.
   GADGET_TRACER_MANAGER_HOOK_MODE="$HOOK_MODE"
 else
@@ -125,4 +125,11 @@ cd /
 rm -f /run/gadgettracermanager.socket
 rm -f /run/gadgetservice.socket
 exec /bin/gadgettracermanager -serve -hook-mode=$GADGET_TRACER_MANAGER_HOOK_MODE \
-    -controller -fallback-podinformer=$INSPEKTOR_GADGET_OPTION_FALLBACK_POD_INFORMER
+    -controller -fallback-podinformer=$INSPEKTOR_GADGET_OPTION_FALLBACK_POD_INFORMER \
+    -image-policy=/etc/ig/image-policy/policy.yaml \
+    -registries-config=/etc/ig/registries/registries.yaml \
+    -image-cache-max-size=$INSPEKTOR_GADGET_OPTION_IMAGE_CACHE_MAX_SIZE \
+    -max-memory=$INSPEKTOR_GADGET_OPTION_MAX_MEMORY \
+    -max-cpu=$INSPEKTOR_GADGET_OPTION_MAX_CPU \
+    -max-event-rate=${INSPEKTOR_GADGET_OPTION_MAX_EVENT_RATE:-0} \
+    -preload-images=$INSPEKTOR_GADGET_OPTION_PRELOAD_IMAGES
@@ -35,6 +35,7 @@ import (
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	// This is a blank include that actually imports all gadgets
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/all-gadgets"
@@ -43,10 +44,15 @@ import (
 	// The script gadget is designed only to work in k8s, hence it's not part of all-gadgets
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/script"
 
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	gadgetservice "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/budget"
+	runtracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager"
 	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/prometheus"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
 )
 
@@ -55,6 +61,17 @@ var (
 	serve               bool
 	liveness            bool
 	fallbackPodInformer bool
+	containerStateFile  string
+	imagePolicyPath     string
+	registriesConfig    string
+	importImages        string
+	imageCacheMaxSize   string
+	imageCacheGCPeriod  time.Duration
+	preloadImages       string
+	metricsAddress      string
+	maxMemory           string
+	maxCPU              string
+	maxEventRate        uint64
 	dump                string
 	hookMode            string
 	socketfile          string
@@ -74,7 +91,7 @@ var clientTimeout = 2 * time.Second
 func init() {
 	flag.StringVar(&socketfile, "socketfile", "/run/gadgettracermanager.socket", "Socket file")
 	flag.StringVar(&gadgetServiceHost, "service-host", fmt.Sprintf("tcp://127.0.0.1:%d", api.GadgetServicePort), "Socket address for gadget service")
-	flag.StringVar(&hookMode, "hook-mode", "auto", "how to get containers start/stop notifications (podinformer, fanotify, auto, none)")
+	flag.StringVar(&hookMode, "hook-mode", "auto", "how to get containers start/stop notifications (podinformer, fanotify, fanotify+ebpf, cri-events, auto, none)")
 
 	flag.BoolVar(&serve, "serve", false, "Start server")
 	flag.BoolVar(&controller, "controller", false, "Enable the controller for custom resources")
@@ -88,10 +105,22 @@ func init() {
 	flag.StringVar(&containername, "containername", "", "container name to use in add-container")
 	flag.UintVar(&containerPid, "containerpid", 0, "container PID to use in add-container")
 
+	flag.StringVar(&importImages, "import-images", "", "Import the gadget images of the given tarball with the OCI image layout")
+
 	flag.StringVar(&dump, "dump", "", "Dump state for debugging specifying the items to print: containers, traces, stacks, all")
 
 	flag.BoolVar(&liveness, "liveness", false, "Execute as client and perform liveness probe")
 	flag.BoolVar(&fallbackPodInformer, "fallback-podinformer", true, "Use pod informer as a fallback for main hook")
+	flag.StringVar(&containerStateFile, "container-state-file", containercollection.DefaultStateFile, "Path of the file where the containers are saved to keep tracking them across restarts. Empty to disable")
+	flag.StringVar(&registriesConfig, "registries-config", "", "Path of the file with the TLS, plain HTTP and proxy settings of the registries. Ignored if the file doesn't exist")
+	flag.StringVar(&imagePolicyPath, "image-policy", "", "Path of the policy restricting the gadget images that can be run. Ignored if the file doesn't exist")
+	flag.StringVar(&imageCacheMaxSize, "image-cache-max-size", "", "Size, e.g. 1Gi, above which the least recently used gadget images are removed from the node. Empty or 0 to disable")
+	flag.DurationVar(&imageCacheGCPeriod, "image-cache-gc-interval", 5*time.Minute, "How often the size of the gadget images cache is checked")
+	flag.StringVar(&preloadImages, "preload-images", "", "Comma-separated list of gadget images to pull and verify at startup")
+	flag.StringVar(&metricsAddress, "metrics-address", prometheus.DefaultListenAddr, "Address to serve the metrics of the gadgets on. Empty to disable")
+	flag.StringVar(&maxMemory, "max-memory", "", "Resident memory, e.g. 512Mi, above which the gadget service sheds load by sampling the events and pausing the gadgets with the lowest priority. Empty to disable")
+	flag.StringVar(&maxCPU, "max-cpu", "", "CPU usage, e.g. 500m or 2 cores, above which the gadget service sheds load. Empty to disable")
+	flag.Uint64Var(&maxEventRate, "max-event-rate", 0, "Number of events per second processed by all the gadgets above which the gadget service sheds load. 0 to disable")
 }
 
 func main() {
@@ -117,6 +146,17 @@ func main() {
 		}
 	}
 
+	if importImages != "" {
+		descs, err := oci.ImportGadgetImages(context.Background(), importImages)
+		if err != nil {
+			log.Fatalf("importing images: %v", err)
+		}
+		for _, desc := range descs {
+			fmt.Printf("Successfully imported %s\n", desc.String())
+		}
+		os.Exit(0)
+	}
+
 	var client pb.GadgetTracerManagerClient
 	var ctx context.Context
 	var cancel context.CancelFunc
@@ -281,6 +321,64 @@ func main() {
 			log.Fatalf("Environment variable NODE_NAME not set")
 		}
 
+		if registriesConfig != "" {
+			if _, err := os.Stat(registriesConfig); err == nil {
+				config, err := oci.LoadRegistriesConfig(registriesConfig)
+				if err != nil {
+					log.Fatalf("loading registries config %q: %v", registriesConfig, err)
+				}
+				oci.SetRegistriesConfig(config)
+				log.Infof("Registries config loaded from %s", registriesConfig)
+			}
+		}
+
+		if imagePolicyPath != "" {
+			if _, err := os.Stat(imagePolicyPath); err == nil {
+				policy, err := oci.LoadImagePolicy(imagePolicyPath)
+				if err != nil {
+					log.Fatalf("loading image policy %q: %v", imagePolicyPath, err)
+				}
+				oci.SetImagePolicy(policy)
+				log.Infof("Image policy loaded from %s", imagePolicyPath)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				// Running without the policy because it can't be read would
+				// allow any image
+				log.Fatalf("checking image policy %q: %v", imagePolicyPath, err)
+			}
+		}
+
+		var cacheMaxSize int64
+		if imageCacheMaxSize != "" {
+			quantity, err := resource.ParseQuantity(imageCacheMaxSize)
+			if err != nil {
+				log.Fatalf("invalid image cache max size %q: %v", imageCacheMaxSize, err)
+			}
+			if quantity.Sign() < 0 {
+				log.Fatalf("invalid image cache max size %q: must not be negative", imageCacheMaxSize)
+			}
+			cacheMaxSize = quantity.Value()
+		}
+		if imageCacheGCPeriod <= 0 {
+			log.Fatalf("invalid image cache gc interval %s: must be positive", imageCacheGCPeriod)
+		}
+
+		limits, err := budget.ParseLimits(maxMemory, maxCPU, maxEventRate)
+		if err != nil {
+			log.Fatalf("invalid resource budget: %v", err)
+		}
+
+		var images []string
+		for _, image := range strings.Split(preloadImages, ",") {
+			if image = strings.TrimSpace(image); image != "" {
+				images = append(images, image)
+			}
+		}
+		// Use the pull secrets of the gadget pod, like the run gadget
+		dockerConfigs, err := runtracer.GetPullSecrets(context.Background(), nil)
+		if err != nil {
+			log.Warnf("getting pull secrets to preload gadget images: %v", err)
+		}
+
 		lis, err := net.Listen("unix", socketfile)
 		if err != nil {
 			log.Fatalf("failed to listen: %v", err)
@@ -295,6 +393,7 @@ func main() {
 			NodeName:            node,
 			HookMode:            hookMode,
 			FallbackPodInformer: fallbackPodInformer,
+			StateFile:           containerStateFile,
 		})
 
 		if err != nil {
@@ -321,8 +420,17 @@ func main() {
 		}
 		go func() {
 			err := service.Run(gadgetservice.RunConfig{
-				SocketType: socketType,
-				SocketPath: socketPath,
+				SocketType:           socketType,
+				SocketPath:           socketPath,
+				ImageCacheMaxSize:    cacheMaxSize,
+				ImageCacheGCInterval: imageCacheGCPeriod,
+				PreloadImages:        images,
+				PreloadAuthOptions: &oci.AuthOptions{
+					AuthFile:      oci.DefaultAuthFile,
+					DockerConfigs: dockerConfigs,
+				},
+				MetricsListenAddress: metricsAddress,
+				Budget:               limits,
 			})
 			if err != nil {
 				log.Fatalf("starting gadget service: %v", err)
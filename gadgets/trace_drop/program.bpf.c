@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: GPL-2.0
+/* Copyright (c) 2023 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_endian.h>
+
+#include <gadget/macros.h>
+#include <gadget/maps.bpf.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define GADGET_TYPE_TRACING
+#include <gadget/sockets-map.h>
+
+#define IFNAMSIZ 16
+
+/* Define here, because there are conflicts with include files */
+#ifndef ETH_P_IP
+#define ETH_P_IP 0x0800
+#endif
+#ifndef ETH_P_IPV6
+#define ETH_P_IPV6 0x86DD
+#endif
+
+struct event {
+	gadget_timestamp_t timestamp;
+
+	struct gadget_l4endpoint_t src;
+	struct gadget_l4endpoint_t dst;
+
+	// The values of this enum change between kernel versions. The run
+	// gadget decodes it using the BTF information of the running kernel.
+	enum skb_drop_reason reason;
+
+	__u32 netns;
+	__u32 ifindex;
+	__u8 ifname[IFNAMSIZ];
+
+	mnt_ns_id_t mntns_id;
+	__u32 pid;
+	__u32 tid;
+	__u32 uid;
+	__u32 gid;
+	__u8 task[TASK_COMM_LEN];
+};
+
+// we need this to make sure the compiler doesn't remove our struct
+const struct event *unusedevent __attribute__((unused));
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+	__uint(key_size, sizeof(__u32));
+	__type(value, struct event);
+} events SEC(".maps");
+
+GADGET_TRACE_MAP(events);
+
+static __always_inline void read_ports(struct event *event,
+				       unsigned char *transport)
+{
+	struct udphdr udph;
+
+	// Source and destination ports are at the same offsets in TCP and UDP
+	// headers, so reading a udphdr is good enough for both.
+	if (bpf_probe_read_kernel(&udph, sizeof(udph), transport))
+		return;
+
+	event->src.port = bpf_ntohs(udph.source);
+	event->dst.port = bpf_ntohs(udph.dest);
+}
+
+static __always_inline int fill_endpoints(struct event *event,
+					  struct sk_buff *skb)
+{
+	unsigned char *head = BPF_CORE_READ(skb, head);
+	__u16 network_header = BPF_CORE_READ(skb, network_header);
+	__u16 transport_header = BPF_CORE_READ(skb, transport_header);
+	__u16 protocol = bpf_ntohs(BPF_CORE_READ(skb, protocol));
+	unsigned char *transport = NULL;
+	struct ipv6hdr ip6h;
+	struct iphdr iph;
+	__u8 proto;
+
+	// transport_header is set to ~0 when it was not set by the stack yet.
+	if (transport_header != (__u16)~0U)
+		transport = head + transport_header;
+
+	switch (protocol) {
+	case ETH_P_IP:
+		if (bpf_probe_read_kernel(&iph, sizeof(iph),
+					  head + network_header))
+			return -1;
+
+		event->src.l3.version = event->dst.l3.version = 4;
+		event->src.l3.addr.v4 = iph.saddr;
+		event->dst.l3.addr.v4 = iph.daddr;
+		proto = iph.protocol;
+
+		if (!transport)
+			transport = head + network_header + iph.ihl * 4;
+		break;
+	case ETH_P_IPV6:
+		if (bpf_probe_read_kernel(&ip6h, sizeof(ip6h),
+					  head + network_header))
+			return -1;
+
+		event->src.l3.version = event->dst.l3.version = 6;
+		__builtin_memcpy(event->src.l3.addr.v6, &ip6h.saddr,
+				 sizeof(event->src.l3.addr.v6));
+		__builtin_memcpy(event->dst.l3.addr.v6, &ip6h.daddr,
+				 sizeof(event->dst.l3.addr.v6));
+		// Extension headers are not parsed: if there are any, the
+		// protocol isn't reported.
+		proto = ip6h.nexthdr;
+
+		if (!transport)
+			transport = head + network_header + sizeof(ip6h);
+		break;
+	default:
+		// Not an IP packet (ARP, etc.)
+		return -1;
+	}
+
+	event->src.proto = event->dst.proto = proto;
+
+	switch (proto) {
+	case IPPROTO_TCP:
+	case IPPROTO_UDP:
+		read_ports(event, transport);
+		break;
+	}
+
+	return 0;
+}
+
+SEC("tracepoint/skb/kfree_skb")
+int ig_drop(struct trace_event_raw_kfree_skb *ctx)
+{
+	struct sk_buff *skb = ctx->skbaddr;
+	struct sockets_value *skb_val;
+	struct event event = {};
+	struct net_device *dev;
+	struct sock *sk;
+
+	// If bpf_core_enum_value fails, it will return 0 and that will not be a silent failure
+	int reason_not_specified = bpf_core_enum_value(
+		enum skb_drop_reason, SKB_DROP_REASON_NOT_SPECIFIED);
+
+	// SKB_NOT_DROPPED_YET and SKB_CONSUMED are lower than
+	// SKB_DROP_REASON_NOT_SPECIFIED: skip them as they aren't actual drops.
+	if (ctx->reason < reason_not_specified)
+		return 0;
+
+	event.timestamp = bpf_ktime_get_boot_ns();
+	event.reason = ctx->reason;
+
+	if (fill_endpoints(&event, skb))
+		return 0;
+
+	dev = BPF_CORE_READ(skb, dev);
+	if (dev) {
+		event.ifindex = BPF_CORE_READ(dev, ifindex);
+		bpf_probe_read_kernel_str(&event.ifname, sizeof(event.ifname),
+					  &dev->name);
+		event.netns = BPF_CORE_READ(dev, nd_net.net, ns.inum);
+	}
+
+	sk = BPF_CORE_READ(skb, sk);
+	if (sk) {
+		if (!event.netns)
+			event.netns = BPF_CORE_READ(sk, __sk_common.skc_net.net,
+						    ns.inum);
+
+		skb_val = gadget_socket_lookup(sk, event.netns);
+		if (skb_val != NULL) {
+			event.mntns_id = skb_val->mntns;
+			event.pid = skb_val->pid_tgid >> 32;
+			event.tid = (__u32)skb_val->pid_tgid;
+			__builtin_memcpy(&event.task, skb_val->task,
+					 sizeof(event.task));
+			event.uid = (__u32)skb_val->uid_gid;
+			event.gid = (__u32)(skb_val->uid_gid >> 32);
+		}
+	}
+
+	// Use the mount namespace of the socket to filter by container. Drops
+	// that can't be attributed to a socket are only reported when not
+	// filtering.
+	if (gadget_should_discard_mntns_id(event.mntns_id))
+		return 0;
+
+	bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &event,
+			      sizeof(event));
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -22,7 +22,7 @@
 
 struct event {
 	mnt_ns_id_t mntns_id;
-	__u64 timestamp;
+	gadget_timestamp_t timestamp;
 	__u32 pid;
 	__u32 ppid;
 	__u32 uid;
@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: GPL-2.0
+/* Copyright (c) 2023 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/filesystem.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define MAX_ENTRIES 10240
+#define FILENAME_LEN 256
+#define MAX_ANCESTORS 8
+// Big enough to keep MAX_ANCESTORS comms and their separators
+#define ANCESTRY_LEN 256
+
+#ifndef AT_EMPTY_PATH
+#define AT_EMPTY_PATH 0x1000
+#endif
+#ifndef TMPFS_MAGIC
+#define TMPFS_MAGIC 0x01021994
+#endif
+
+// Kind of fileless execution, ordered by priority: when several match, the
+// first one is reported.
+enum fileless_kind {
+	MEMFD,
+	DELETED_FILE,
+	DEV_SHM,
+	EXECVEAT_EMPTY_PATH,
+};
+
+struct event {
+	gadget_timestamp_t timestamp;
+	mnt_ns_id_t mntns_id;
+	__u32 pid;
+	__u32 ppid;
+	__u32 uid;
+	__u32 gid;
+	__u8 comm[TASK_COMM_LEN];
+	__u8 pcomm[TASK_COMM_LEN];
+
+	enum fileless_kind kind;
+	// Set when the execution was done with execveat(fd, "", ..., AT_EMPTY_PATH)
+	bool empty_path;
+	__u8 filename[FILENAME_LEN];
+	__u8 exepath[FILENAME_LEN];
+	// Comms of the ancestors of the process, starting by the parent and
+	// separated by "<"
+	__u8 ancestry[ANCESTRY_LEN];
+};
+
+// we need this to make sure the compiler doesn't remove our struct
+const struct event *unusedevent __attribute__((unused));
+
+// Threads that called execveat with AT_EMPTY_PATH
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u32);
+	__type(value, __u8);
+} empty_path_execs SEC(".maps");
+
+// The event doesn't fit in the stack, so use a map to build it
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, struct event);
+} tmp_event SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+	__uint(key_size, sizeof(__u32));
+	__type(value, struct event);
+} events SEC(".maps");
+
+GADGET_TRACE_MAP(events);
+
+SEC("tracepoint/syscalls/sys_enter_execveat")
+int ig_fl_execveat_e(struct trace_event_raw_sys_enter *ctx)
+{
+	__u32 tid = (__u32)bpf_get_current_pid_tgid();
+	int flags = (int)ctx->args[4];
+	__u8 one = 1;
+
+	if (!(flags & AT_EMPTY_PATH))
+		return 0;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	bpf_map_update_elem(&empty_path_execs, &tid, &one, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_execveat")
+int ig_fl_execveat_x(struct trace_event_raw_sys_exit *ctx)
+{
+	__u32 tid = (__u32)bpf_get_current_pid_tgid();
+
+	// Successful executions were already handled by sched_process_exec,
+	// this cleans up the failed ones.
+	bpf_map_delete_elem(&empty_path_execs, &tid);
+	return 0;
+}
+
+static __always_inline void fill_ancestry(struct event *event,
+					  struct task_struct *task)
+{
+	struct task_struct *parent = task;
+	unsigned int off = 0;
+	long len;
+
+#pragma unroll
+	for (int i = 0; i < MAX_ANCESTORS; i++) {
+		parent = BPF_CORE_READ(parent, real_parent);
+		if (!parent || BPF_CORE_READ(parent, pid) == 0)
+			break;
+
+		// Stop when the separator and a whole comm don't fit anymore, it
+		// also bounds off for the verifier
+		if (off >= ANCESTRY_LEN - TASK_COMM_LEN)
+			break;
+
+		if (i > 0) {
+			event->ancestry[off] = '<';
+			off++;
+		}
+
+		len = bpf_probe_read_kernel_str(&event->ancestry[off],
+						TASK_COMM_LEN, &parent->comm);
+		if (len <= 1)
+			break;
+		// Don't count the NUL byte, it's overwritten by the next separator
+		off += len - 1;
+	}
+}
+
+SEC("tracepoint/sched/sched_process_exec")
+int ig_fl_exec(struct trace_event_raw_sched_process_exec *ctx)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	__u64 uid_gid = bpf_get_current_uid_gid();
+	// When a thread other than the leader execs, it takes the tid of the
+	// leader: the entry was added with the tid it had before
+	__u32 old_tid = ctx->old_pid;
+	struct task_struct *task, *parent;
+	unsigned int fname_off;
+	struct file *exe_file;
+	struct dentry *dentry;
+	bool empty_path, deleted, memfd, dev_shm;
+	const unsigned char *name;
+	char prefix[9] = {};
+	struct event *event;
+	struct path f_path;
+	__u32 zero = 0;
+	char *path;
+	__u8 *found;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	found = bpf_map_lookup_elem(&empty_path_execs, &old_tid);
+	empty_path = found != NULL;
+	if (found)
+		bpf_map_delete_elem(&empty_path_execs, &old_tid);
+
+	task = (struct task_struct *)bpf_get_current_task();
+	exe_file = BPF_CORE_READ(task, mm, exe_file);
+	if (!exe_file)
+		return 0;
+
+	dentry = BPF_CORE_READ(exe_file, f_path.dentry);
+	name = BPF_CORE_READ(dentry, d_name.name);
+	bpf_probe_read_kernel_str(prefix, sizeof(prefix), name);
+	memfd = __builtin_memcmp(prefix, "memfd:", 6) == 0;
+	deleted = BPF_CORE_READ(dentry, d_inode, __i_nlink) == 0;
+
+	event = bpf_map_lookup_elem(&tmp_event, &zero);
+	if (!event)
+		return 0;
+
+	dev_shm = false;
+	event->exepath[0] = '\0';
+	if (BPF_CORE_READ(exe_file, f_inode, i_sb, s_magic) == TMPFS_MAGIC) {
+		f_path = BPF_CORE_READ(exe_file, f_path);
+		path = get_path_str(&f_path);
+		if (path) {
+			bpf_probe_read_kernel_str(event->exepath,
+						  sizeof(event->exepath), path);
+			dev_shm = __builtin_memcmp(event->exepath, "/dev/shm/",
+						   9) == 0;
+		}
+	}
+
+	if (!memfd && !deleted && !dev_shm && !empty_path)
+		return 0;
+
+	if (memfd)
+		event->kind = MEMFD;
+	else if (deleted)
+		event->kind = DELETED_FILE;
+	else if (dev_shm)
+		event->kind = DEV_SHM;
+	else
+		event->kind = EXECVEAT_EMPTY_PATH;
+	event->empty_path = empty_path;
+
+	// memfd and deleted files aren't on tmpfs, use the dentry name for them
+	if (event->exepath[0] == '\0')
+		bpf_probe_read_kernel_str(event->exepath,
+					  sizeof(event->exepath), name);
+
+	fname_off = ctx->__data_loc_filename & 0xFFFF;
+	bpf_probe_read_kernel_str(event->filename, sizeof(event->filename),
+				  (void *)ctx + fname_off);
+
+	parent = BPF_CORE_READ(task, real_parent);
+
+	event->timestamp = bpf_ktime_get_boot_ns();
+	event->mntns_id = gadget_get_mntns_id();
+	event->pid = pid_tgid >> 32;
+	event->ppid = BPF_CORE_READ(parent, tgid);
+	event->uid = (__u32)uid_gid;
+	event->gid = (__u32)(uid_gid >> 32);
+	bpf_get_current_comm(&event->comm, sizeof(event->comm));
+	BPF_CORE_READ_STR_INTO(&event->pcomm, parent, comm);
+
+	__builtin_memset(event->ancestry, 0, sizeof(event->ancestry));
+	fill_ancestry(event, task);
+
+	bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, event,
+			      sizeof(*event));
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: GPL-2.0
+/* Copyright (c) 2020 Wenbo Zhang */
+/* Copyright (c) 2023 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/filesystem.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define MAX_ENTRIES 8192
+#ifndef PATH_MAX
+#define PATH_MAX 4096
+#endif
+
+enum fsslower_op {
+	F_READ,
+	F_WRITE,
+	F_OPEN,
+	F_FSYNC,
+};
+
+struct event {
+	gadget_timestamp_t timestamp;
+	mnt_ns_id_t mntns_id;
+	__u32 pid;
+	__u32 tid;
+	__u32 uid;
+	__u32 gid;
+	__u8 comm[TASK_COMM_LEN];
+
+	enum fsslower_op op;
+	__u64 bytes;
+	__s64 offset;
+	__u64 latency_us;
+	__u8 file[PATH_MAX];
+};
+
+// Operations faster than this aren't reported. Defaults to 10ms like the
+// built-in fsslower gadget.
+const volatile __u64 min_lat_ns = 10 * 1000 * 1000;
+
+GADGET_PARAM(min_lat_ns);
+
+// we need this to make sure the compiler doesn't remove our struct
+const struct event *unusedevent __attribute__((unused));
+
+struct data {
+	__u64 ts;
+	loff_t start;
+	loff_t end;
+	struct file *fp;
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u32);
+	__type(value, struct data);
+} starts SEC(".maps");
+
+// The file path doesn't fit in the stack, so use a map to build the event
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, struct event);
+} tmp_event SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+	__uint(key_size, sizeof(__u32));
+	__type(value, struct event);
+} events SEC(".maps");
+
+GADGET_TRACE_MAP(events);
+
+static __always_inline int probe_entry(struct file *fp, loff_t start,
+				       loff_t end)
+{
+	__u32 tid = (__u32)bpf_get_current_pid_tgid();
+	struct data data;
+
+	if (!fp)
+		return 0;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	data.ts = bpf_ktime_get_ns();
+	data.start = start;
+	data.end = end;
+	data.fp = fp;
+	bpf_map_update_elem(&starts, &tid, &data, BPF_ANY);
+	return 0;
+}
+
+static __always_inline int probe_exit(void *ctx, enum fsslower_op op,
+				      ssize_t size)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	__u64 uid_gid = bpf_get_current_uid_gid();
+	__u32 tid = (__u32)pid_tgid;
+	struct path f_path;
+	struct data *datap;
+	struct event *event;
+	__u64 delta_ns;
+	__u32 zero = 0;
+	char *path;
+
+	datap = bpf_map_lookup_elem(&starts, &tid);
+	if (!datap)
+		return 0;
+
+	delta_ns = bpf_ktime_get_ns() - datap->ts;
+	if (delta_ns <= min_lat_ns)
+		goto cleanup;
+
+	event = bpf_map_lookup_elem(&tmp_event, &zero);
+	if (!event)
+		goto cleanup;
+
+	event->timestamp = bpf_ktime_get_boot_ns();
+	event->mntns_id = gadget_get_mntns_id();
+	event->pid = pid_tgid >> 32;
+	event->tid = tid;
+	event->uid = (__u32)uid_gid;
+	event->gid = (__u32)(uid_gid >> 32);
+	bpf_get_current_comm(&event->comm, sizeof(event->comm));
+
+	event->op = op;
+	event->latency_us = delta_ns / 1000;
+	event->offset = datap->start;
+	if (op == F_FSYNC)
+		event->bytes = datap->end - datap->start;
+	else if (size > 0)
+		event->bytes = size;
+	else
+		event->bytes = 0;
+
+	f_path = BPF_CORE_READ(datap->fp, f_path);
+	path = get_path_str(&f_path);
+	if (path)
+		bpf_probe_read_kernel_str(event->file, sizeof(event->file),
+					  path);
+	else
+		event->file[0] = '\0';
+
+	bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, event,
+			      sizeof(*event));
+
+cleanup:
+	bpf_map_delete_elem(&starts, &tid);
+	return 0;
+}
+
+// The VFS functions are used instead of the filesystem specific ones, so all
+// filesystems are traced at once.
+
+SEC("kprobe/vfs_read")
+int BPF_KPROBE(ig_fssl_read_e, struct file *file, char *buf, size_t count,
+	       loff_t *pos)
+{
+	loff_t start = 0;
+
+	if (pos)
+		bpf_probe_read_kernel(&start, sizeof(start), pos);
+
+	return probe_entry(file, start, 0);
+}
+
+SEC("kretprobe/vfs_read")
+int BPF_KRETPROBE(ig_fssl_read_x, ssize_t ret)
+{
+	return probe_exit(ctx, F_READ, ret);
+}
+
+SEC("kprobe/vfs_write")
+int BPF_KPROBE(ig_fssl_wr_e, struct file *file, const char *buf, size_t count,
+	       loff_t *pos)
+{
+	loff_t start = 0;
+
+	if (pos)
+		bpf_probe_read_kernel(&start, sizeof(start), pos);
+
+	return probe_entry(file, start, 0);
+}
+
+SEC("kretprobe/vfs_write")
+int BPF_KRETPROBE(ig_fssl_wr_x, ssize_t ret)
+{
+	return probe_exit(ctx, F_WRITE, ret);
+}
+
+SEC("kprobe/vfs_open")
+int BPF_KPROBE(ig_fssl_open_e, const struct path *path, struct file *file)
+{
+	return probe_entry(file, 0, 0);
+}
+
+SEC("kretprobe/vfs_open")
+int BPF_KRETPROBE(ig_fssl_open_x)
+{
+	return probe_exit(ctx, F_OPEN, 0);
+}
+
+SEC("kprobe/vfs_fsync_range")
+int BPF_KPROBE(ig_fssl_sync_e, struct file *file, loff_t start, loff_t end)
+{
+	return probe_entry(file, start, end);
+}
+
+SEC("kretprobe/vfs_fsync_range")
+int BPF_KRETPROBE(ig_fssl_sync_x)
+{
+	return probe_exit(ctx, F_FSYNC, 0);
+}
+
+char LICENSE[] SEC("license") = "GPL";
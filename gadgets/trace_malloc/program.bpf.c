@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: GPL-2.0
+/* Copyright (c) 2023 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/stacks.h>
+#include <gadget/types.h>
+
+#define MAX_ENTRIES 10240
+
+enum memop {
+	MALLOC,
+	FREE,
+	CALLOC,
+	REALLOC,
+};
+
+struct event {
+	gadget_timestamp_t timestamp;
+	mnt_ns_id_t mntns_id;
+	__u32 pid;
+	__u32 tid;
+	__u8 comm[TASK_COMM_LEN];
+
+	enum memop operation;
+	__u64 addr;
+	__u64 size;
+	// Address of the memory being reallocated, only set for realloc
+	__u64 old_addr;
+};
+
+// we need this to make sure the compiler doesn't remove our struct
+const struct event *unusedevent __attribute__((unused));
+
+// Stack the allocations are done from, in a container
+struct alloc_stack {
+	mnt_ns_id_t mntns_id;
+	__u32 pid;
+	__u8 comm[TASK_COMM_LEN];
+	gadget_user_stack_t user_stack;
+};
+
+struct alloc_stats {
+	__u64 allocs;
+	gadget_bytes_t bytes;
+};
+
+// When set, the allocations are counted by stack in alloc_stacks instead of
+// being sent as events, for flame graphs
+const volatile bool collect_stacks = false;
+
+GADGET_PARAM(collect_stacks);
+
+struct alloc_args {
+	__u64 size;
+	__u64 old_addr;
+};
+
+// Arguments of the allocations, until the functions return
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u32);
+	__type(value, struct alloc_args);
+} allocs SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+	__uint(key_size, sizeof(__u32));
+	__type(value, struct event);
+} events SEC(".maps");
+
+GADGET_TRACE_MAP(events);
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, struct alloc_stack);
+	__type(value, struct alloc_stats);
+} alloc_stacks SEC(".maps");
+
+GADGET_TOPPER(stacks, alloc_stacks);
+
+static __always_inline int gen_alloc_enter(__u64 size, __u64 old_addr)
+{
+	__u32 tid = (__u32)bpf_get_current_pid_tgid();
+	struct alloc_args args = {
+		.size = size,
+		.old_addr = old_addr,
+	};
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	bpf_map_update_elem(&allocs, &tid, &args, BPF_ANY);
+	return 0;
+}
+
+static __always_inline void submit_event(struct pt_regs *ctx, enum memop op,
+					 __u64 addr, __u64 size, __u64 old_addr)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	struct event event = {};
+
+	event.timestamp = bpf_ktime_get_boot_ns();
+	event.mntns_id = gadget_get_mntns_id();
+	event.pid = pid_tgid >> 32;
+	event.tid = (__u32)pid_tgid;
+	bpf_get_current_comm(&event.comm, sizeof(event.comm));
+
+	event.operation = op;
+	event.addr = addr;
+	event.size = size;
+	event.old_addr = old_addr;
+
+	bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &event,
+			      sizeof(event));
+}
+
+static __always_inline void count_alloc(struct pt_regs *ctx, __u64 size)
+{
+	static const struct alloc_stats zero = {};
+	struct alloc_stack key = {};
+	struct alloc_stats *stats;
+
+	key.mntns_id = gadget_get_mntns_id();
+	key.pid = bpf_get_current_pid_tgid() >> 32;
+	bpf_get_current_comm(&key.comm, sizeof(key.comm));
+	key.user_stack = gadget_get_user_stack(ctx);
+
+	stats = bpf_map_lookup_elem(&alloc_stacks, &key);
+	if (!stats) {
+		bpf_map_update_elem(&alloc_stacks, &key, &zero, BPF_NOEXIST);
+		stats = bpf_map_lookup_elem(&alloc_stacks, &key);
+		if (!stats)
+			return;
+	}
+	__sync_fetch_and_add(&stats->allocs, 1);
+	__sync_fetch_and_add(&stats->bytes, size);
+}
+
+static __always_inline int gen_alloc_exit(struct pt_regs *ctx, enum memop op)
+{
+	__u32 tid = (__u32)bpf_get_current_pid_tgid();
+	__u64 addr = PT_REGS_RC(ctx);
+	struct alloc_args *args;
+
+	args = bpf_map_lookup_elem(&allocs, &tid);
+	if (!args)
+		return 0;
+
+	// Failed allocations aren't reported
+	if (addr && collect_stacks)
+		count_alloc(ctx, args->size);
+	else if (addr)
+		submit_event(ctx, op, addr, args->size, args->old_addr);
+
+	bpf_map_delete_elem(&allocs, &tid);
+	return 0;
+}
+
+static __always_inline int gen_free_enter(struct pt_regs *ctx, __u64 addr)
+{
+	// Only the allocations are counted by stack
+	if (!addr || collect_stacks)
+		return 0;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	submit_event(ctx, FREE, addr, 0, 0);
+	return 0;
+}
+
+// jemalloc and tcmalloc export the same functions as the libc, define the
+// programs for all of them. The libraries that aren't used by a container are
+// ignored.
+#define DEFINE_ALLOCATOR_PROGS(lib)                                           \
+	SEC("uprobe/" #lib ":malloc")                                        \
+	int BPF_KPROBE(ig_##lib##_malloc_e, size_t size)                      \
+	{                                                                     \
+		return gen_alloc_enter(size, 0);                              \
+	}                                                                     \
+                                                                              \
+	SEC("uretprobe/" #lib ":malloc")                                     \
+	int BPF_KRETPROBE(ig_##lib##_malloc_x)                                \
+	{                                                                     \
+		return gen_alloc_exit(ctx, MALLOC);                           \
+	}                                                                     \
+                                                                              \
+	SEC("uprobe/" #lib ":calloc")                                        \
+	int BPF_KPROBE(ig_##lib##_calloc_e, size_t nmemb, size_t size)        \
+	{                                                                     \
+		return gen_alloc_enter(nmemb * size, 0);                      \
+	}                                                                     \
+                                                                              \
+	SEC("uretprobe/" #lib ":calloc")                                     \
+	int BPF_KRETPROBE(ig_##lib##_calloc_x)                                \
+	{                                                                     \
+		return gen_alloc_exit(ctx, CALLOC);                           \
+	}                                                                     \
+                                                                              \
+	SEC("uprobe/" #lib ":realloc")                                       \
+	int BPF_KPROBE(ig_##lib##_realloc_e, void *ptr, size_t size)          \
+	{                                                                     \
+		return gen_alloc_enter(size, (__u64)ptr);                     \
+	}                                                                     \
+                                                                              \
+	SEC("uretprobe/" #lib ":realloc")                                    \
+	int BPF_KRETPROBE(ig_##lib##_realloc_x)                               \
+	{                                                                     \
+		return gen_alloc_exit(ctx, REALLOC);                          \
+	}                                                                     \
+                                                                              \
+	SEC("uprobe/" #lib ":free")                                          \
+	int BPF_KPROBE(ig_##lib##_free_e, void *ptr)                          \
+	{                                                                     \
+		return gen_free_enter(ctx, (__u64)ptr);                       \
+	}
+
+DEFINE_ALLOCATOR_PROGS(libc)
+DEFINE_ALLOCATOR_PROGS(libjemalloc)
+DEFINE_ALLOCATOR_PROGS(libtcmalloc)
+
+char LICENSE[] SEC("license") = "GPL";
@@ -6,6 +6,7 @@
 #include <bpf/bpf_helpers.h>
 #include <bpf/bpf_core_read.h>
 
+#include <gadget/aggregation.h>
 #include <gadget/macros.h>
 #include <gadget/mntns_filter.h>
 #include <gadget/types.h>
@@ -21,7 +22,7 @@ struct args_t {
 };
 
 struct event {
-	__u64 timestamp;
+	gadget_timestamp_t timestamp;
 	/* user terminology for pid: */
 	__u32 pid;
 	__u32 uid;
@@ -59,6 +60,8 @@ struct {
 
 GADGET_TRACE_MAP(events);
 
+GADGET_AGGREGATION_MAP(struct event, 10240);
+
 static __always_inline bool valid_uid(uid_t uid)
 {
 	return uid != INVALID_UID;
@@ -149,6 +152,13 @@ static __always_inline int trace_exit(struct trace_event_raw_sys_exit *ctx)
 	event.mode = ap->mode;
 	event.ret = ret;
 	event.mntns_id = gadget_get_mntns_id();
+
+	if (gadget_aggregate) {
+		/* count the event, without the timestamp to aggregate them */
+		gadget_aggregate_event(&gadget_aggregation, &event);
+		goto cleanup;
+	}
+
 	event.timestamp = bpf_ktime_get_boot_ns();
 
 	/* emit event */
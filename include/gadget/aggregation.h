@@ -0,0 +1,64 @@
+/* SPDX-License-Identifier: (GPL-2.0 WITH Linux-syscall-note) OR Apache-2.0 */
+
+#ifndef AGGREGATION_H
+#define AGGREGATION_H
+
+#include <bpf/bpf_helpers.h>
+
+#include <gadget/buffer.h>
+
+#ifndef EEXIST
+#define EEXIST 17
+#endif
+
+// Keep in sync with AggregationMapName and AggregateConstName in
+// pkg/gadgets/consts.go
+
+// gadget_aggregate is set by Inspektor Gadget when the gadget runs with the
+// --aggregate-interval parameter. The events must then be counted with
+// gadget_aggregate_event() instead of being sent to user space. Inspektor
+// Gadget sends each distinct event with its count and clears the map on every
+// interval.
+const volatile bool gadget_aggregate = false;
+
+// GADGET_AGGREGATION_MAP declares the map the events of type event_type are
+// counted in. event_type must be the type of the events sent through the trace
+// map. The fields with values that are different for every event, like
+// timestamps, must be left to zero for the events to be aggregated.
+#define GADGET_AGGREGATION_MAP(event_type, entries) \
+	struct {                                    \
+		__uint(type, BPF_MAP_TYPE_HASH);    \
+		__uint(max_entries, entries);       \
+		__type(key, event_type);            \
+		__type(value, __u64);               \
+	} gadget_aggregation SEC(".maps");
+
+// gadget_aggregate_event increments the count of event in the aggregation map
+// declared with GADGET_AGGREGATION_MAP:
+// gadget_aggregate_event(&gadget_aggregation, &event). A lost event is counted
+// if the map is full.
+static __always_inline void gadget_aggregate_event(void *map, void *event)
+{
+	static const __u64 one = 1;
+	__u64 *count;
+	long ret;
+
+	count = bpf_map_lookup_elem(map, event);
+	if (count) {
+		__sync_fetch_and_add(count, 1);
+		return;
+	}
+
+	ret = bpf_map_update_elem(map, event, &one, BPF_NOEXIST);
+	if (ret == -EEXIST) {
+		// Added by another CPU meanwhile
+		count = bpf_map_lookup_elem(map, event);
+		if (count)
+			__sync_fetch_and_add(count, 1);
+		return;
+	}
+	if (ret)
+		gadget_count_lost_sample();
+}
+
+#endif
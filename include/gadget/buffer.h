@@ -0,0 +1,58 @@
+/* SPDX-License-Identifier: (GPL-2.0 WITH Linux-syscall-note) OR Apache-2.0 */
+
+#ifndef BUFFER_H
+#define BUFFER_H
+
+#include <bpf/bpf_helpers.h>
+
+// Keep in sync with LostSamplesMapName in pkg/gadgets/consts.go
+
+// gadget_lost_samples counts the events that couldn't be sent to user space
+// because the ring buffer was full. Events lost in perf event arrays are
+// reported by the kernel and don't need to be counted here. Inspektor Gadget
+// reads this map periodically and reports the lost events to the user.
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__type(key, __u32);
+	__type(value, __u64);
+	__uint(max_entries, 1);
+} gadget_lost_samples SEC(".maps");
+
+// gadget_count_lost_sample increments the number of lost events.
+static __always_inline void gadget_count_lost_sample()
+{
+	__u32 zero = 0;
+	__u64 *lost;
+
+	lost = bpf_map_lookup_elem(&gadget_lost_samples, &zero);
+	if (lost)
+		(*lost)++;
+}
+
+// gadget_reserve_buf reserves size bytes in the ring buffer rb, counting a
+// lost event if it's full. It returns NULL in that case.
+static __always_inline void *gadget_reserve_buf(void *rb, __u64 size)
+{
+	void *buf;
+
+	buf = bpf_ringbuf_reserve(rb, size, 0);
+	if (!buf)
+		gadget_count_lost_sample();
+
+	return buf;
+}
+
+// gadget_output_buf copies size bytes of data to the ring buffer rb,
+// counting a lost event if it's full.
+static __always_inline long gadget_output_buf(void *rb, void *data, __u64 size)
+{
+	long ret;
+
+	ret = bpf_ringbuf_output(rb, data, size, 0);
+	if (ret)
+		gadget_count_lost_sample();
+
+	return ret;
+}
+
+#endif
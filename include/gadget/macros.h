@@ -11,4 +11,47 @@
 #define GADGET_TRACE_MAP(name) \
 	const void * gadget_trace_map_##name __attribute__((unused));
 
+// GADGET_SNAPSHOTTER is used to indicate that the given BPF iterator program writes structures of
+// the given type with bpf_seq_write(). Inspektor Gadget runs the iterator, decodes the structures
+// and sends them to the user. It can be used several times with the same name for a snapshot taken
+// by several iterators.
+#define GADGET_SNAPSHOTTER(name, type, prog) \
+	const void * gadget_snapshotter_##name##___##type##___##prog __attribute__((unused)); \
+	const struct type * __gadget_snapshotter_##name##___##prog##_type __attribute__((unused));
+
+// GADGET_TOPPER is used to indicate that the given hash map holds statistics, with structures as
+// keys and values. Inspektor Gadget periodically reads the map, sends its entries with the highest
+// values to the user and clears it.
+#define GADGET_TOPPER(name, map) \
+	const void * gadget_topper_##name##___##map __attribute__((unused));
+
+// GADGET_HISTOGRAM is used to indicate that the given array or hash map holds a log2 histogram: its
+// values are arrays of counters, the slot i counting the values between 2^i and 2^(i+1)-1.
+// Inspektor Gadget reads the map and sends the distribution to the user when the gadget stops or
+// on an interval. The unit of the values must be set in the metadata.
+#define GADGET_HISTOGRAM(name, map) \
+	const void * gadget_histogram_##name##___##map __attribute__((unused));
+
+// GADGET_PARAM is used to indicate that the given const volatile variable can be set by the user:
+// Inspektor Gadget exposes it as a parameter of the gadget and rewrites the constant with its value
+// before loading the program, e.g. to only trace the process given with --targ-pid.
+#define GADGET_PARAM(name) \
+	const void * gadget_param_##name __attribute__((unused));
+
+// GADGET_FIELD_DESCRIPTION, GADGET_FIELD_WIDTH, GADGET_FIELD_TEMPLATE and GADGET_FIELD_HIDDEN set
+// the attributes of a member of the structures sent to user space with BTF decl tags, for
+// "ig image build" to write them in the metadata file, e.g.:
+//
+//	__u32 pid GADGET_FIELD_DESCRIPTION("PID of the process") GADGET_FIELD_TEMPLATE("pid");
+//
+// They require clang 14 or newer.
+#define GADGET_FIELD_DESCRIPTION(description) \
+	__attribute__((btf_decl_tag("description=" description)))
+#define GADGET_FIELD_WIDTH(width) \
+	__attribute__((btf_decl_tag("width=" #width)))
+#define GADGET_FIELD_TEMPLATE(template) \
+	__attribute__((btf_decl_tag("template=" template)))
+#define GADGET_FIELD_HIDDEN \
+	__attribute__((btf_decl_tag("hidden")))
+
 #endif /* __MACROS_H */
@@ -0,0 +1,47 @@
+/* SPDX-License-Identifier: (GPL-2.0 WITH Linux-syscall-note) OR Apache-2.0 */
+
+#ifndef STACKS_H
+#define STACKS_H
+
+#include <bpf/bpf_helpers.h>
+
+#include <gadget/types.h>
+
+// Keep in sync with StacksMapName in pkg/gadgets/consts.go
+
+// Maximum number of frames of the stacks, PERF_MAX_STACK_DEPTH by default
+#ifndef GADGET_MAX_STACK_DEPTH
+#define GADGET_MAX_STACK_DEPTH 127
+#endif
+
+// Maximum number of distinct stacks. The events with a new stack get an error
+// instead of its ID once the map is full
+#ifndef GADGET_MAX_STACKS
+#define GADGET_MAX_STACKS 10240
+#endif
+
+// Inspektor Gadget reads the frames of the stacks of the fields of type
+// gadget_kernel_stack_t and gadget_user_stack_t from this map, for them to be
+// shown with the events.
+struct {
+	__uint(type, BPF_MAP_TYPE_STACK_TRACE);
+	__uint(key_size, sizeof(__u32));
+	__uint(value_size, GADGET_MAX_STACK_DEPTH * sizeof(__u64));
+	__uint(max_entries, GADGET_MAX_STACKS);
+} gadget_stacks SEC(".maps");
+
+// gadget_get_kernel_stack returns the ID of the kernel stack of the current
+// task, or a negative error
+static __always_inline gadget_kernel_stack_t gadget_get_kernel_stack(void *ctx)
+{
+	return bpf_get_stackid(ctx, &gadget_stacks, 0);
+}
+
+// gadget_get_user_stack returns the ID of the user stack of the current task,
+// or a negative error
+static __always_inline gadget_user_stack_t gadget_get_user_stack(void *ctx)
+{
+	return bpf_get_stackid(ctx, &gadget_stacks, BPF_F_USER_STACK);
+}
+
+#endif /* STACKS_H */
@@ -28,4 +28,25 @@ struct gadget_l4endpoint_t {
 // Inode id of a mount namespace. It's used to enrich the event in user space
 typedef __u64 mnt_ns_id_t;
 
+// Inode id of a network namespace. It's used to enrich the event in user space
+typedef __u64 gadget_netns_id_t;
+
+// Time since boot in nanoseconds, as returned by bpf_ktime_get_boot_ns(). It's shown as wall time
+typedef __u64 gadget_timestamp_t;
+
+// Error number, e.g. the return value of a syscall. It's shown by name, e.g. -ENOENT
+typedef __s32 gadget_errno_t;
+
+// Number of bytes. It's shown with a human readable unit, e.g. 1.5MiB
+typedef __u64 gadget_bytes_t;
+
+// Duration in nanoseconds. It's shown with a human readable unit, e.g. 1.5ms
+typedef __u64 gadget_duration_t;
+
+// ID of a kernel or user stack in the gadget_stacks map, as returned by
+// gadget_get_kernel_stack() and gadget_get_user_stack() of gadget/stacks.h.
+// It's shown as the frames of the stack
+typedef __s32 gadget_kernel_stack_t;
+typedef __s32 gadget_user_stack_t;
+
 #endif /* __TYPES_H */
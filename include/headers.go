@@ -0,0 +1,25 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package include bundles the helper headers of the gadgets, for ig to build
+// them with the ones matching its version.
+package include
+
+import "embed"
+
+// Gadget has the headers of include/gadget, without the vmlinux.h of each
+// architecture because of their size.
+//
+//go:embed gadget/*.h
+var Gadget embed.FS
@@ -53,6 +53,10 @@ func newTopTCPCmd(ns string, cmd string, startAndStop bool, isDockerRuntime bool
 			e.SrcEndpoint.Port = 0
 			e.Sent = 0
 			e.Received = 0
+			e.SentRate = 0
+			e.ReceivedRate = 0
+			e.Retransmits = 0
+			e.RTT = 0
 
 			e.K8s.Node = ""
 			// TODO: Verify container runtime and container name
@@ -18,20 +18,15 @@
 package btfgen
 
 import (
-	"bufio"
 	"bytes"
 	_ "embed"
 	"fmt"
-	"os"
-	"path/filepath"
 	"runtime"
-	"strings"
 	"sync"
 
 	"github.com/cilium/ebpf/btf"
-	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kernelbtf"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/sys/unix"
 )
 
 var (
@@ -41,12 +36,11 @@ var (
 
 func initialize() error {
 	// If the kernel exposes BTF; nothing to do
-	_, err := btf.LoadKernelSpec()
-	if err == nil {
+	if kernelbtf.Exposed() {
 		return nil
 	}
 
-	info, err := getOSInfo()
+	info, err := kernelbtf.GetOSInfo()
 	if err != nil {
 		return err
 	}
@@ -60,11 +54,15 @@ func initialize() error {
 		goarch = "x86"
 	}
 
-	btfFile := fmt.Sprintf("btfs/%s/%s/%s/%s/%s.btf",
-		goarch, info.ID, info.VersionID, info.Arch, info.Kernel)
+	btfFile := fmt.Sprintf("btfs/%s/%s", goarch, info.BTFHubPath())
 
 	file, err := btfs.ReadFile(btfFile)
 	if err != nil {
+		// Fall back to the complete BTF from BTFHub, if enabled
+		if s := kernelbtf.KernelTypes(); s != nil {
+			spec = s
+			return nil
+		}
 		return fmt.Errorf("reading %s BTF file %w", btfFile, err)
 	}
 
@@ -78,7 +76,9 @@ func initialize() error {
 }
 
 // GetBTFSpec returns the BTF spec with kernel information for the current kernel version. If the
-// kernel exposes BTF information or if the BTF for this kernel is not found, it returns nil.
+// kernel exposes BTF information or if the BTF for this kernel is not found, it returns nil. The
+// complete BTF from BTFHub is used when the binary doesn't include the one of this kernel, see
+// kernelbtf.KernelTypes.
 func GetBTFSpec() *btf.Spec {
 	once.Do(func() {
 		err := initialize()
@@ -88,54 +88,3 @@ func GetBTFSpec() *btf.Spec {
 	})
 	return spec
 }
-
-type osInfo struct {
-	ID        string
-	VersionID string
-	Arch      string
-	Kernel    string
-}
-
-func getOSInfo() (*osInfo, error) {
-	osInfo := &osInfo{}
-
-	file, err := os.Open(filepath.Join(host.HostRoot, "/etc/os-release"))
-	if err != nil {
-		return nil, fmt.Errorf("opening file: %w", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		switch parts[0] {
-		case "ID":
-			osInfo.ID = parts[1]
-		case "VERSION_ID":
-			osInfo.VersionID = strings.Trim(parts[1], "\"")
-		}
-	}
-
-	if osInfo.ID == "" || osInfo.VersionID == "" {
-		return nil, fmt.Errorf("os-release file is incomplete")
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scanning file: %w", err)
-	}
-
-	uts := &unix.Utsname{}
-	if err := unix.Uname(uts); err != nil {
-		return nil, fmt.Errorf("calling uname: %w", err)
-	}
-
-	osInfo.Kernel = unix.ByteSliceToString(uts.Release[:])
-	osInfo.Arch = unix.ByteSliceToString(uts.Machine[:])
-
-	return osInfo, nil
-}
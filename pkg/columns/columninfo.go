@@ -75,6 +75,8 @@ type Attributes struct {
 	Tags []string `yaml:"tags"`
 	// Template defines the template that will be used. Non-typed templates will be applied first.
 	Template string `yaml:"template"`
+	// Unit of the numbers of this column, used to show them in a human readable form
+	Unit Unit `yaml:"unit"`
 }
 
 type Column[T any] struct {
@@ -275,6 +277,14 @@ func (ci *Column[T]) parseTagInfo(tagInfo []string) error {
 			if err != nil {
 				return err
 			}
+		case "unit":
+			if paramsLen == 1 {
+				return fmt.Errorf("missing unit for field %q", ci.Name)
+			}
+			if _, ok := Humanize(0, Unit(params[1])); !ok {
+				return fmt.Errorf("invalid unit %q for field %q", params[1], ci.Name)
+			}
+			ci.Unit = Unit(params[1])
 		case "template":
 			ci.useTemplate = true
 			if paramsLen < 2 || params[1] == "" {
@@ -602,13 +602,9 @@ func GetFieldAsStringExt[T any](column ColumnInternals, floatFormat byte, floatP
 		s := column.(*Column[T]).Type().Elem().Size()
 		// c strings: []char null terminated
 		if s == 1 {
+			ff := getFieldAsCStringFunc[T](column)
 			return func(entry *T) string {
-				arr := GetFieldAsArrayFunc[byte, T](column)(entry)
-				i := bytes.IndexByte(arr, 0)
-				if i != -1 {
-					arr = arr[:i]
-				}
-				return string(arr)
+				return string(ff(entry))
 			}
 		}
 
@@ -626,6 +622,78 @@ func GetFieldAsStringExt[T any](column ColumnInternals, floatFormat byte, floatP
 	}
 }
 
+// AppendFieldAsStringFunc returns a helper function that appends the string representation
+// of the value of a field of struct T to dst, like GetFieldAsStringExt does, but without
+// allocating memory for the ints, uints, floats, bools and c strings.
+func AppendFieldAsStringFunc[T any](column ColumnInternals, floatFormat byte, floatPrecision int) func(dst []byte, entry *T) []byte {
+	switch column.(*Column[T]).Kind() {
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		ff := GetFieldAsNumberFunc[int64, T](column)
+		return func(dst []byte, entry *T) []byte {
+			return strconv.AppendInt(dst, ff(entry), 10)
+		}
+	case reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64:
+		ff := GetFieldAsNumberFunc[uint64, T](column)
+		return func(dst []byte, entry *T) []byte {
+			return strconv.AppendUint(dst, ff(entry), 10)
+		}
+	case reflect.Float32, reflect.Float64:
+		ff := GetFieldAsNumberFunc[float64, T](column)
+		return func(dst []byte, entry *T) []byte {
+			return strconv.AppendFloat(dst, ff(entry), floatFormat, floatPrecision, 64)
+		}
+	case reflect.Bool:
+		ff := GetFieldFunc[bool, T](column)
+		return func(dst []byte, entry *T) []byte {
+			return strconv.AppendBool(dst, ff(entry))
+		}
+	case reflect.Array:
+		if column.(*Column[T]).Type().Elem().Size() == 1 {
+			ff := getFieldAsCStringFunc[T](column)
+			return func(dst []byte, entry *T) []byte {
+				return append(dst, ff(entry)...)
+			}
+		}
+	case reflect.String:
+		ff := GetFieldFunc[string, T](column)
+		return func(dst []byte, entry *T) []byte {
+			return append(dst, ff(entry)...)
+		}
+	}
+	ff := GetFieldAsStringExt[T](column, floatFormat, floatPrecision)
+	return func(dst []byte, entry *T) []byte {
+		return append(dst, ff(entry)...)
+	}
+}
+
+// getFieldAsCStringFunc returns a helper function to access a null terminated array of
+// bytes of a struct T. The returned slice points to the memory of the entry and is only
+// valid as long as the entry is.
+func getFieldAsCStringFunc[T any](column ColumnInternals) func(entry *T) []byte {
+	l := column.(*Column[T]).RawType().Len()
+
+	return func(entry *T) []byte {
+		entryStart := unsafe.Pointer(entry)
+		if column.(*Column[T]).getStart != nil {
+			entryStart = column.(*Column[T]).getStart(entry)
+		}
+
+		arr := unsafe.Slice((*byte)(unsafe.Add(entryStart, column.getOffset())), l)
+		if i := bytes.IndexByte(arr, 0); i != -1 {
+			arr = arr[:i]
+		}
+		return arr
+	}
+}
+
 func GetFieldAsString[T any](column ColumnInternals) func(entry *T) string {
 	return GetFieldAsStringExt[T](column, 'E', -1)
 }
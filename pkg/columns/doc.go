@@ -59,6 +59,7 @@ The parameter could be used for specific options, passing nil will use the defau
 	| hide      | none                   | specifies that this column is not to be considered by default (see custom columns)                                   |
 	| precision | int                    | specifies the precision of floats (number of decimals)                                                               |
 	| width     | int                    | defines the space allocated for the column                                                                           |
+	| unit      | bytes,ns,us,ms,packets | specifies the unit of numbers, for the formatters to show them in a human readable form, eg: 1.5MiB                  |
 
 # Virtual Columns or Custom Extractors
 
@@ -28,5 +28,8 @@ var bufpool = sync.Pool{
 type encodeState struct {
 	bytes.Buffer
 	scratch [64]byte
-	err     error
+	// str is reused to build the strings that aren't stored as such in the
+	// entries
+	str []byte
+	err error
 }
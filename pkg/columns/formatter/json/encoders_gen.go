@@ -0,0 +1,116 @@
+// Code generated by gen_encoders.go; DO NOT EDIT.
+
+package json
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+)
+
+// newEncoder returns the encoder writing key and the value of col, or nil if
+// its kind doesn't have one.
+func newEncoder[T any](col *columns.Column[T], key []byte) func(e *encodeState, t *T) {
+	switch col.Kind() {
+	case reflect.Int:
+		ff := columns.GetFieldFunc[int, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+		}
+	case reflect.Int8:
+		ff := columns.GetFieldFunc[int8, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+		}
+	case reflect.Int16:
+		ff := columns.GetFieldFunc[int16, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+		}
+	case reflect.Int32:
+		ff := columns.GetFieldFunc[int32, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+		}
+	case reflect.Int64:
+		ff := columns.GetFieldFunc[int64, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendInt(e.scratch[:0], v, 10))
+		}
+	case reflect.Uint:
+		ff := columns.GetFieldFunc[uint, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+		}
+	case reflect.Uint8:
+		ff := columns.GetFieldFunc[uint8, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+		}
+	case reflect.Uint16:
+		ff := columns.GetFieldFunc[uint16, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+		}
+	case reflect.Uint32:
+		ff := columns.GetFieldFunc[uint32, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+		}
+	case reflect.Uint64:
+		ff := columns.GetFieldFunc[uint64, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendUint(e.scratch[:0], v, 10))
+		}
+	case reflect.Float32:
+		ff := columns.GetFieldFunc[float32, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			floatEncoder(32).writeFloat(e, float64(v))
+		}
+	case reflect.Float64:
+		ff := columns.GetFieldFunc[float64, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			floatEncoder(64).writeFloat(e, v)
+		}
+	case reflect.Bool:
+		ff := columns.GetFieldFunc[bool, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			e.Write(strconv.AppendBool(e.scratch[:0], v))
+		}
+	case reflect.String:
+		ff := columns.GetFieldFunc[string, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			writeString(e, v)
+		}
+	}
+	return nil
+}
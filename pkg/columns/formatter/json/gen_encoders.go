@@ -0,0 +1,96 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build ignore
+
+// gen_encoders generates encoders_gen.go: an encoder for each kind of field
+// the JSON formatter supports, reading the field with its actual type.
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"log"
+	"os"
+	"text/template"
+)
+
+type kind struct {
+	// Name of the reflect.Kind
+	Kind string
+	// Type of the field
+	Type string
+	// Statement writing v, the value of the field, to the encodeState e
+	Write string
+}
+
+var kinds = []kind{
+	{"Int", "int", "e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))"},
+	{"Int8", "int8", "e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))"},
+	{"Int16", "int16", "e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))"},
+	{"Int32", "int32", "e.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))"},
+	{"Int64", "int64", "e.Write(strconv.AppendInt(e.scratch[:0], v, 10))"},
+	{"Uint", "uint", "e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))"},
+	{"Uint8", "uint8", "e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))"},
+	{"Uint16", "uint16", "e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))"},
+	{"Uint32", "uint32", "e.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))"},
+	{"Uint64", "uint64", "e.Write(strconv.AppendUint(e.scratch[:0], v, 10))"},
+	{"Float32", "float32", "floatEncoder(32).writeFloat(e, float64(v))"},
+	{"Float64", "float64", "floatEncoder(64).writeFloat(e, v)"},
+	{"Bool", "bool", "e.Write(strconv.AppendBool(e.scratch[:0], v))"},
+	{"String", "string", "writeString(e, v)"},
+}
+
+var tmpl = template.Must(template.New("encoders").Parse(`// Code generated by gen_encoders.go; DO NOT EDIT.
+
+package json
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+)
+
+// newEncoder returns the encoder writing key and the value of col, or nil if
+// its kind doesn't have one.
+func newEncoder[T any](col *columns.Column[T], key []byte) func(e *encodeState, t *T) {
+	switch col.Kind() {
+{{- range .}}
+	case reflect.{{.Kind}}:
+		ff := columns.GetFieldFunc[{{.Type}}, T](col)
+		return func(e *encodeState, t *T) {
+			e.Write(key)
+			v := ff(t)
+			{{.Write}}
+		}
+{{- end}}
+	}
+	return nil
+}
+`))
+
+func main() {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, kinds); err != nil {
+		log.Fatalf("executing template: %s", err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("formatting source: %s", err)
+	}
+	if err := os.WriteFile("encoders_gen.go", src, 0o644); err != nil {
+		log.Fatalf("writing encoders: %s", err)
+	}
+}
@@ -22,7 +22,7 @@ import (
 	"strconv"
 	"strings"
 	"unicode/utf8"
-	_ "unsafe"
+	"unsafe"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
 )
@@ -38,6 +38,8 @@ type Formatter[T any] struct {
 	printer func(buf *encodeState, entry *T, indent string)
 }
 
+//go:generate go run gen_encoders.go
+
 // NewFormatter returns a Formatter that will turn entries of type T into JSON representation
 func NewFormatter[T any](cols columns.ColumnMap[T], options ...Option) *Formatter[T] {
 	opts := DefaultOptions()
@@ -55,65 +57,24 @@ func NewFormatter[T any](cols columns.ColumnMap[T], options ...Option) *Formatte
 		name, _ := json.Marshal(colName)
 		key := append(name, []byte(": ")...)
 
-		var formatter func(*encodeState, *T)
-		switch col.Kind() {
-		default:
-			continue
-		case reflect.Int,
-			reflect.Int8,
-			reflect.Int16,
-			reflect.Int32,
-			reflect.Int64:
-			ff := columns.GetFieldAsNumberFunc[int64, T](col)
-			formatter = func(e *encodeState, t *T) {
-				e.Write(key)
-				b := strconv.AppendInt(e.scratch[:0], ff(t), 10)
-				e.Write(b)
-			}
-		case reflect.Uint,
-			reflect.Uint8,
-			reflect.Uint16,
-			reflect.Uint32,
-			reflect.Uint64:
-			ff := columns.GetFieldAsNumberFunc[uint64, T](col)
-			formatter = func(e *encodeState, t *T) {
-				e.Write(key)
-				b := strconv.AppendUint(e.scratch[:0], ff(t), 10)
-				e.Write(b)
-			}
-		case reflect.Bool:
-			ff := columns.GetFieldFunc[bool, T](col)
-			formatter = func(e *encodeState, t *T) {
-				e.Write(key)
-				if ff(t) {
-					e.WriteString("true")
-					return
-				}
-				e.WriteString("false")
-			}
-		case reflect.Float32:
-			ff := columns.GetFieldAsNumberFunc[float64, T](col)
-			formatter = func(e *encodeState, t *T) {
-				e.Write(key)
-				floatEncoder(32).writeFloat(e, ff(t))
-			}
-		case reflect.Float64:
-			ff := columns.GetFieldAsNumberFunc[float64, T](col)
-			formatter = func(e *encodeState, t *T) {
-				e.Write(key)
-				floatEncoder(64).writeFloat(e, ff(t))
-			}
-		case reflect.Array:
-			ff := columns.GetFieldAsString[T](col)
+		// The encoders of the basic kinds are generated, see gen_encoders.go
+		formatter := newEncoder(col, key)
+		if col.Kind() == reflect.Array {
+			ff := columns.AppendFieldAsStringFunc[T](col, 'E', -1)
 			formatter = func(e *encodeState, t *T) {
 				e.Write(key)
-				writeString(e, ff(t))
+				e.str = ff(e.str[:0], t)
+				writeString(e, unsafe.String(unsafe.SliceData(e.str), len(e.str)))
 			}
-		case reflect.String:
-			ff := columns.GetFieldFunc[string, T](col)
+		}
+		if formatter == nil {
+			continue
+		}
+
+		if hf := columns.GetFieldAsHumanizedStringFunc[T](col); hf != nil && !opts.rawValues {
 			formatter = func(e *encodeState, t *T) {
 				e.Write(key)
-				writeString(e, ff(t))
+				writeString(e, hf(t))
 			}
 		}
 
@@ -190,11 +151,13 @@ func (f *Formatter[T]) getPrinter(level int, cols []*column[T]) func(buf *encode
 			buf.Write(parentName)
 			buf.WriteString(": {")
 
-			childFuncs(buf, entry, indent+"  ")
+			childFuncs(buf, entry, childIndent(indent))
 
 			// End parent
 			if f.options.prettyPrint {
-				buf.WriteString("\n" + indent + "  }")
+				buf.WriteByte('\n')
+				buf.WriteString(indent)
+				buf.WriteString("  }")
 			} else {
 				buf.WriteByte('}')
 			}
@@ -224,15 +187,29 @@ func (f *Formatter[T]) getPrinter(level int, cols []*column[T]) func(buf *encode
 	}
 }
 
+// spaces is used to build the indentations without allocating memory
+const spaces = "                                                                "
+
+// childIndent returns the indentation of the members of an object indented
+// with indent, which is made of spaces only.
+func childIndent(indent string) string {
+	if len(indent)+2 <= len(spaces) {
+		return spaces[:len(indent)+2]
+	}
+	return indent + "  "
+}
+
 func (f *Formatter[T]) formatEntry(buf *encodeState, entry *T, indent string) {
+	buf.WriteString(indent)
 	if entry == nil {
-		buf.WriteString(indent + "null")
+		buf.WriteString("null")
 		return
 	}
-	buf.WriteString(indent + "{")
+	buf.WriteByte('{')
 	f.printer(buf, entry, indent)
 	if f.options.prettyPrint {
-		buf.WriteString("\n" + indent)
+		buf.WriteByte('\n')
+		buf.WriteString(indent)
 	}
 	buf.WriteByte('}')
 }
@@ -248,6 +225,19 @@ func (f *Formatter[T]) FormatEntry(entry *T) string {
 	return buf.String()
 }
 
+// AppendEntry appends an entry formatted like FormatEntry does to dst and returns the extended
+// buffer. It doesn't allocate memory when dst is large enough, so it's preferred when the
+// entries are written to an io.Writer.
+func (f *Formatter[T]) AppendEntry(dst []byte, entry *T) []byte {
+	buf := bufpool.Get().(*encodeState)
+	buf.Reset()
+	defer bufpool.Put(buf)
+
+	f.formatEntry(buf, entry, "")
+
+	return append(dst, buf.Bytes()...)
+}
+
 // FormatEntries returns a slice of entries as a formatted string, respecting the given formatting settings
 func (f *Formatter[T]) FormatEntries(entries []*T) string {
 	if entries == nil {
@@ -397,7 +387,107 @@ func writeString(e *encodeState, s string) {
 
 var hex = "0123456789abcdef"
 
-// use safeSet from encoding/json directly
+// safeSet holds the value true if the ASCII character with the given array
+// position can be represented inside a JSON string without any further
+// escaping.
 //
-//go:linkname safeSet encoding/json.safeSet
-var safeSet = [utf8.RuneSelf]bool{}
+// from encoding/json/tables.go, it can't be linked to as the toolchain
+// doesn't allow it anymore
+var safeSet = [utf8.RuneSelf]bool{
+	' ':      true,
+	'!':      true,
+	'"':      false,
+	'#':      true,
+	'$':      true,
+	'%':      true,
+	'&':      true,
+	'\'':     true,
+	'(':      true,
+	')':      true,
+	'*':      true,
+	'+':      true,
+	',':      true,
+	'-':      true,
+	'.':      true,
+	'/':      true,
+	'0':      true,
+	'1':      true,
+	'2':      true,
+	'3':      true,
+	'4':      true,
+	'5':      true,
+	'6':      true,
+	'7':      true,
+	'8':      true,
+	'9':      true,
+	':':      true,
+	';':      true,
+	'<':      true,
+	'=':      true,
+	'>':      true,
+	'?':      true,
+	'@':      true,
+	'A':      true,
+	'B':      true,
+	'C':      true,
+	'D':      true,
+	'E':      true,
+	'F':      true,
+	'G':      true,
+	'H':      true,
+	'I':      true,
+	'J':      true,
+	'K':      true,
+	'L':      true,
+	'M':      true,
+	'N':      true,
+	'O':      true,
+	'P':      true,
+	'Q':      true,
+	'R':      true,
+	'S':      true,
+	'T':      true,
+	'U':      true,
+	'V':      true,
+	'W':      true,
+	'X':      true,
+	'Y':      true,
+	'Z':      true,
+	'[':      true,
+	'\\':     false,
+	']':      true,
+	'^':      true,
+	'_':      true,
+	'`':      true,
+	'a':      true,
+	'b':      true,
+	'c':      true,
+	'd':      true,
+	'e':      true,
+	'f':      true,
+	'g':      true,
+	'h':      true,
+	'i':      true,
+	'j':      true,
+	'k':      true,
+	'l':      true,
+	'm':      true,
+	'n':      true,
+	'o':      true,
+	'p':      true,
+	'q':      true,
+	'r':      true,
+	's':      true,
+	't':      true,
+	'u':      true,
+	'v':      true,
+	'w':      true,
+	'x':      true,
+	'y':      true,
+	'z':      true,
+	'{':      true,
+	'|':      true,
+	'}':      true,
+	'~':      true,
+	'\u007f': true,
+}
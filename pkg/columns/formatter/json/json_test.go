@@ -150,15 +150,43 @@ func TestJSONFormatter_PrettyFormatEntries(t *testing.T) {
 	assert.Equal(t, expected, formatter.FormatEntries(testEntries))
 }
 
+func TestJSONFormatter_AppendEntry(t *testing.T) {
+	for _, options := range [][]Option{nil, {WithPrettyPrint()}} {
+		formatter := NewFormatter(testColumns, options...)
+		buf := []byte("prefix:")
+		for _, entry := range testEntries {
+			assert.Equal(t, "prefix:"+formatter.FormatEntry(entry), string(formatter.AppendEntry(buf, entry)))
+		}
+
+		buf = make([]byte, 0, 1024)
+		allocs := testing.AllocsPerRun(100, func() {
+			formatter.AppendEntry(buf[:0], testEntries[0])
+		})
+		assert.Zero(t, allocs, "AppendEntry must not allocate")
+	}
+}
+
 func BenchmarkFormatter(b *testing.B) {
 	b.StopTimer()
 	formatter := NewFormatter(testColumns)
+	b.ReportAllocs()
 	b.StartTimer()
 	for n := 0; n < b.N; n++ {
 		formatter.FormatEntry(testEntries[n%len(testEntries)])
 	}
 }
 
+func BenchmarkAppendEntry(b *testing.B) {
+	b.StopTimer()
+	formatter := NewFormatter(testColumns)
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		buf = formatter.AppendEntry(buf[:0], testEntries[n%len(testEntries)])
+	}
+}
+
 func BenchmarkNative(b *testing.B) {
 	b.StopTimer()
 	// do a dry-run to enable caching
@@ -301,3 +329,19 @@ func TestJSONFormatterDeeplyNested(t *testing.T) {
 	actual = prettyFormatter.FormatEntry(&testStruct{})
 	assert.Equal(t, expected, actual)
 }
+
+func TestJSONFormatter_Units(t *testing.T) {
+	type unitsStruct struct {
+		Name    string `column:"name"`
+		Size    uint64 `column:"size,unit:bytes"`
+		Latency int64  `column:"latency,unit:us"`
+	}
+	cols := columns.MustCreateColumns[unitsStruct]().GetColumnMap()
+	entry := &unitsStruct{"foo", 1572864, 1500}
+
+	formatter := NewFormatter(cols)
+	assert.Equal(t, `{"name": "foo", "size": "1.5MiB", "latency": "1.5ms"}`, formatter.FormatEntry(entry))
+
+	formatter = NewFormatter(cols, WithRawValues(true))
+	assert.Equal(t, `{"name": "foo", "size": 1572864, "latency": 1500}`, formatter.FormatEntry(entry))
+}
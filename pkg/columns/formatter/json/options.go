@@ -19,6 +19,9 @@ type Option func(*Options)
 type Options struct {
 	// Pretty print the JSON output
 	prettyPrint bool
+	// Write the numbers of the columns with a unit as they are instead of
+	// humanized strings
+	rawValues bool
 }
 
 func DefaultOptions() *Options {
@@ -30,3 +33,9 @@ func WithPrettyPrint() func(*Options) {
 		o.prettyPrint = true
 	}
 }
+
+func WithRawValues(rawValues bool) func(*Options) {
+	return func(o *Options) {
+		o.rawValues = rawValues
+	}
+}
@@ -37,6 +37,7 @@ type Options struct {
 	DefaultColumns []string    // defines which columns to show by default; will be set to all visible columns if nil
 	HeaderStyle    HeaderStyle // defines how column headers are decorated (e.g. uppercase/lowercase)
 	RowDivider     string      // defines the (to be repeated) string that should be used below the header
+	RawValues      bool        // if enabled, the numbers of the columns with a unit aren't humanized
 }
 
 func DefaultOptions() *Options {
@@ -83,3 +84,10 @@ func WithRowDivider(divider string) Option {
 		opts.RowDivider = divider
 	}
 }
+
+// WithRawValues sets whether the numbers of the columns with a unit should be shown as they are instead of humanized
+func WithRawValues(rawValues bool) Option {
+	return func(opts *Options) {
+		opts.RawValues = rawValues
+	}
+}
@@ -18,19 +18,60 @@ import (
 	"bytes"
 	"io"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/ellipsis"
 )
 
+var bufpool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
 func (tf *TextColumnsFormatter[T]) setFormatter(column *Column[T]) {
-	ff := columns.GetFieldAsStringExt[T](column.col, 'f', column.col.Precision)
-	column.formatter = func(entry *T) string {
-		return tf.buildFixedString(ff(entry), column.calculatedWidth, column.col.EllipsisType, column.col.Alignment)
+	ff := columns.AppendFieldAsStringFunc[T](column.col, 'f', column.col.Precision)
+	if !tf.options.RawValues {
+		if hf := columns.GetFieldAsHumanizedStringFunc[T](column.col); hf != nil {
+			ff = func(dst []byte, entry *T) []byte {
+				return append(dst, hf(entry)...)
+			}
+		}
+	}
+	column.formatter = func(dst []byte, entry *T) []byte {
+		return tf.appendFixedString(dst, ff, entry, column.calculatedWidth, column.col.EllipsisType, column.col.Alignment)
 	}
 }
 
+// appendFixedString appends the value of the field to dst with the given
+// length, like buildFixedString does. Values that need to be shortened are
+// rare, so they go through buildFixedString.
+func (tf *TextColumnsFormatter[T]) appendFixedString(dst []byte, ff func([]byte, *T) []byte, entry *T, length int, ellipsisType ellipsis.EllipsisType, alignment columns.Alignment) []byte {
+	if length <= 0 {
+		return dst
+	}
+
+	start := len(dst)
+	dst = ff(dst, entry)
+	valueLen := len(dst) - start
+	runes := utf8.RuneCount(dst[start:])
+	if runes > length {
+		return append(dst[:start], tf.buildFixedString(string(dst[start:]), length, ellipsisType, alignment)...)
+	}
+
+	fill := tf.fillString[0 : length-runes]
+	dst = append(dst, fill...)
+	if alignment != columns.AlignLeft {
+		copy(dst[start+len(fill):], dst[start:start+valueLen])
+		copy(dst[start:], fill)
+	}
+	return dst
+}
+
 func (tf *TextColumnsFormatter[T]) buildFixedString(s string, length int, ellipsisType ellipsis.EllipsisType, alignment columns.Alignment) string {
 	if length <= 0 {
 		return ""
@@ -53,14 +94,28 @@ func (tf *TextColumnsFormatter[T]) FormatEntry(entry *T) string {
 		return ""
 	}
 
-	var row strings.Builder
+	buf := bufpool.Get().(*[]byte)
+	defer bufpool.Put(buf)
+
+	*buf = tf.AppendEntry((*buf)[:0], entry)
+	return string(*buf)
+}
+
+// AppendEntry appends an entry formatted like FormatEntry does to dst and returns the extended
+// buffer. It doesn't allocate memory when dst is large enough, so it's preferred when the
+// entries are written to an io.Writer.
+func (tf *TextColumnsFormatter[T]) AppendEntry(dst []byte, entry *T) []byte {
+	if entry == nil {
+		return dst
+	}
+
 	for i, col := range tf.showColumns {
 		if i > 0 {
-			row.WriteString(tf.options.ColumnDivider)
+			dst = append(dst, tf.options.ColumnDivider...)
 		}
-		row.WriteString(col.formatter(entry))
+		dst = col.formatter(dst, entry)
 	}
-	return row.String()
+	return dst
 }
 
 // FormatHeader returns the formatted header line with all visible column names, separated by ColumnDivider
@@ -130,12 +185,12 @@ func (tf *TextColumnsFormatter[T]) WriteTable(writer io.Writer, entries []*T) er
 			return err
 		}
 	}
+	buf := bufpool.Get().(*[]byte)
+	defer bufpool.Put(buf)
+
 	for _, entry := range entries {
-		_, err = writer.Write([]byte(tf.FormatEntry(entry)))
-		if err != nil {
-			return err
-		}
-		_, err = writer.Write([]byte("\n"))
+		*buf = append(tf.AppendEntry((*buf)[:0], entry), '\n')
+		_, err = writer.Write(*buf)
 		if err != nil {
 			return err
 		}
@@ -26,7 +26,7 @@ type Column[T any] struct {
 	col             *columns.Column[T]
 	calculatedWidth int
 	treatAsFixed    bool
-	formatter       func(*T) string
+	formatter       func([]byte, *T) []byte
 }
 
 type TextColumnsFormatter[T any] struct {
@@ -68,6 +68,53 @@ func TestTextColumnsFormatter_FormatEntryAndTable(t *testing.T) {
 	})
 }
 
+func TestTextColumnsFormatter_AppendEntry(t *testing.T) {
+	entries := []*testStruct{
+		{"Alice", 32, 1.74, 1000, true},
+		// Shortened with an ellipsis
+		{"Maximilian Mustermann", 1234, 180.5, 123456789, false},
+		// Multi-byte characters
+		{"Zoë", 5, 0, 0, true},
+	}
+	expected := []string{
+		"Alice        32   1.74     1000 true    ",
+		"Maximilia… 1234 180.50 1234567… false   ",
+		"Zoë           5   0.00        0 true    ",
+	}
+	formatter := NewFormatter(testColumns)
+
+	buf := []byte("prefix:")
+	for i, entry := range entries {
+		assert.Equal(t, expected[i], formatter.FormatEntry(entry))
+		assert.Equal(t, "prefix:"+expected[i], string(formatter.AppendEntry(buf, entry)))
+	}
+
+	buf = make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		formatter.AppendEntry(buf[:0], entries[0])
+	})
+	assert.Zero(t, allocs, "AppendEntry must not allocate")
+}
+
+func BenchmarkFormatEntry(b *testing.B) {
+	formatter := NewFormatter(testColumns)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		formatter.FormatEntry(testEntries[n%(len(testEntries)-1)])
+	}
+}
+
+func BenchmarkAppendEntry(b *testing.B) {
+	formatter := NewFormatter(testColumns)
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		buf = formatter.AppendEntry(buf[:0], testEntries[n%(len(testEntries)-1)])
+	}
+}
+
 func TestTextColumnsFormatter_FormatHeader(t *testing.T) {
 	formatter := NewFormatter(testColumns)
 
@@ -296,3 +343,18 @@ func TestDynamicFields(t *testing.T) {
 	assert.Equal(t, "STR              INT32            BOOL            ", formatter.FormatHeader())
 	assert.Equal(t, "foobar           1234567890       true            ", formatter.FormatEntry(&empty{}))
 }
+
+func TestTextColumnsFormatter_Units(t *testing.T) {
+	type unitsStruct struct {
+		Size    uint64 `column:"size,width:8,unit:bytes"`
+		Packets uint32 `column:"packets,width:8,unit:packets"`
+	}
+	cols := columns.MustCreateColumns[unitsStruct]().GetColumnMap()
+	entry := &unitsStruct{1536, 12345}
+
+	formatter := NewFormatter(cols, WithAutoScale(false))
+	assert.Equal(t, "1.5KiB   12.35k  ", formatter.FormatEntry(entry))
+
+	formatter = NewFormatter(cols, WithAutoScale(false), WithRawValues(true))
+	assert.Equal(t, "1536     12345   ", formatter.FormatEntry(entry))
+}
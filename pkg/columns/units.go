@@ -0,0 +1,81 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package columns
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// Unit is the unit of the numbers of a column. The formatters show them in a
+// human readable form, e.g. 1.5MiB instead of 1572864 bytes, unless told to
+// show the raw values.
+type Unit string
+
+const (
+	UnitNone         Unit = ""
+	UnitBytes        Unit = "bytes"
+	UnitNanoseconds  Unit = "ns"
+	UnitMicroseconds Unit = "us"
+	UnitMilliseconds Unit = "ms"
+	UnitPackets      Unit = "packets"
+)
+
+var packetsSuffixes = []string{"", "k", "M", "G", "T", "P", "E"}
+
+// Humanize returns value, expressed in unit, in a human readable form: the
+// sizes with binary prefixes (1.5KiB), the durations like time.Duration does
+// (1.5ms) and the packets with decimal prefixes (1.5k). It returns false if
+// the unit isn't known.
+func Humanize(value float64, unit Unit) (string, bool) {
+	switch unit {
+	case UnitBytes:
+		return units.BytesSize(value), true
+	case UnitNanoseconds:
+		return time.Duration(value).String(), true
+	case UnitMicroseconds:
+		return time.Duration(value * float64(time.Microsecond)).String(), true
+	case UnitMilliseconds:
+		return time.Duration(value * float64(time.Millisecond)).String(), true
+	case UnitPackets:
+		return units.CustomSize("%.4g%s", value, 1000.0, packetsSuffixes), true
+	}
+	return "", false
+}
+
+// GetFieldAsHumanizedStringFunc returns a helper function returning the value
+// of a numeric field of a struct T humanized according to the unit of its
+// column, see Humanize. It returns nil if the column has no unit or isn't
+// numeric.
+func GetFieldAsHumanizedStringFunc[T any](column ColumnInternals) func(entry *T) string {
+	unit := column.(*Column[T]).Unit
+	if _, ok := Humanize(0, unit); !ok {
+		return nil
+	}
+
+	switch column.(*Column[T]).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		ff := GetFieldAsNumberFunc[float64, T](column)
+		return func(entry *T) string {
+			s, _ := Humanize(ff(entry), unit)
+			return s
+		}
+	}
+	return nil
+}
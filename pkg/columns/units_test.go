@@ -0,0 +1,71 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package columns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHumanize(t *testing.T) {
+	tests := []struct {
+		value    float64
+		unit     Unit
+		expected string
+	}{
+		{1572864, UnitBytes, "1.5MiB"},
+		{10, UnitBytes, "10B"},
+		{1500000, UnitNanoseconds, "1.5ms"},
+		{1500, UnitMicroseconds, "1.5ms"},
+		{90000, UnitMilliseconds, "1m30s"},
+		{999, UnitPackets, "999"},
+		{1200000, UnitPackets, "1.2M"},
+	}
+	for _, test := range tests {
+		s, ok := Humanize(test.value, test.unit)
+		assert.True(t, ok)
+		assert.Equal(t, test.expected, s, "%v %s", test.value, test.unit)
+	}
+
+	_, ok := Humanize(1, "furlongs")
+	assert.False(t, ok)
+}
+
+func TestUnitTag(t *testing.T) {
+	type testStruct struct {
+		Size uint64 `column:"size,unit:bytes"`
+		Name string `column:"name"`
+	}
+	cols, err := NewColumns[testStruct]()
+	require.NoError(t, err)
+
+	size, ok := cols.GetColumn("size")
+	require.True(t, ok)
+	assert.Equal(t, UnitBytes, size.Unit)
+	assert.Equal(t, "2KiB", GetFieldAsHumanizedStringFunc[testStruct](size)(&testStruct{Size: 2048}))
+
+	// Only the numbers with a known unit are humanized
+	name, ok := cols.GetColumn("name")
+	require.True(t, ok)
+	assert.Nil(t, GetFieldAsHumanizedStringFunc[testStruct](name))
+
+	type badStruct struct {
+		Size uint64 `column:"size,unit:furlongs"`
+	}
+	_, err = NewColumns[badStruct]()
+	assert.ErrorContains(t, err, `invalid unit "furlongs"`)
+}
@@ -77,6 +77,9 @@ type ContainerCollection struct {
 	closed bool
 	done   chan struct{}
 
+	// ready is closed once Initialize() added the initial containers.
+	ready chan struct{}
+
 	// functions to be called on Close()
 	cleanUpFuncs []func()
 
@@ -93,6 +96,7 @@ type ContainerCollectionOption func(*ContainerCollection) error
 // we don't use a contructor in that case.
 func (cc *ContainerCollection) Initialize(options ...ContainerCollectionOption) error {
 	cc.done = make(chan struct{})
+	cc.ready = make(chan struct{})
 
 	if cc.initialized {
 		panic("Initialize already called")
@@ -115,6 +119,7 @@ func (cc *ContainerCollection) Initialize(options ...ContainerCollectionOption)
 	cc.initialContainers = nil
 
 	cc.initialized = true
+	close(cc.ready)
 	return nil
 }
 
@@ -425,6 +430,8 @@ func (cc *ContainerCollection) EnrichByMntNs(event *eventtypes.CommonData, mount
 		event.K8s.ContainerName = container.K8s.ContainerName
 		event.K8s.PodName = container.K8s.PodName
 		event.K8s.Namespace = container.K8s.Namespace
+		event.K8s.ContainerType = container.K8s.ContainerType
+		event.K8s.Annotations = container.K8s.Annotations
 
 		event.Runtime.RuntimeName = container.Runtime.RuntimeName
 		event.Runtime.ContainerName = container.Runtime.ContainerName
@@ -453,6 +460,8 @@ func (cc *ContainerCollection) EnrichByNetNs(event *eventtypes.CommonData, netns
 		event.K8s.ContainerName = containers[0].K8s.ContainerName
 		event.K8s.PodName = containers[0].K8s.PodName
 		event.K8s.Namespace = containers[0].K8s.Namespace
+		event.K8s.ContainerType = containers[0].K8s.ContainerType
+		event.K8s.Annotations = containers[0].K8s.Annotations
 
 		event.Runtime.RuntimeName = containers[0].Runtime.RuntimeName
 		event.Runtime.ContainerName = containers[0].Runtime.ContainerName
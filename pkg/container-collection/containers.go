@@ -27,6 +27,7 @@ import (
 	"k8s.io/client-go/rest"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -71,6 +72,14 @@ type Container struct {
 
 	// when the container was removed. Useful for running cached containers.
 	deletionTimestamp time.Time
+
+	// runtimeAnnotations are the annotations reported by the container
+	// runtime client. WithAnnotationsEnrichment() selects from them.
+	runtimeAnnotations map[string]string
+
+	// runtimeEnv are the environment variables reported by the container
+	// runtime client, used when OciConfig isn't available.
+	runtimeEnv []string
 }
 
 // close releases any resources (like  file descriptors) the container is using.
@@ -87,12 +96,23 @@ func (c *Container) close() {
 
 type RuntimeMetadata struct {
 	types.BasicRuntimeMetadata `json:",inline"`
+	// Labels contains the labels of the container reported by the runtime
+	Labels map[string]string `json:"labels,omitempty"`
+	// SandboxType is set when the container runs in a sandbox, like Kata
+	// Containers or gVisor, whose processes can't be observed.
+	SandboxType SandboxType `json:"sandboxType,omitempty"`
 }
 
 type K8sMetadata struct {
 	types.BasicK8sMetadata `json:",inline"`
 	PodLabels              map[string]string `json:"podLabels,omitempty"`
 	PodUID                 string            `json:"podUID,omitempty"`
+	// ContainerType tells if the container is a main, init or ephemeral
+	// container of the pod. It's empty if unknown.
+	ContainerType types.ContainerType `json:"containerType,omitempty"`
+	// Annotations contains the annotations of the container and its pod
+	// selected with WithAnnotationsEnrichment().
+	Annotations map[string]string `json:"annotations,omitempty"`
 
 	ownerReference *metav1.OwnerReference
 }
@@ -100,11 +120,27 @@ type K8sMetadata struct {
 type K8sSelector struct {
 	types.BasicK8sMetadata
 	PodLabels map[string]string
+	// ContainerTypes restricts the selection to containers of these
+	// types. All containers are selected if it's empty.
+	ContainerTypes []types.ContainerType
+	// ExcludedContainerTypes excludes the containers of these types from
+	// the selection.
+	ExcludedContainerTypes []types.ContainerType
 }
 
 type RuntimeSelector struct {
 	// TODO: Support filtering by all the fields in BasicRuntimeMetadata
 	ContainerName string
+	// RuntimeNames restricts the selection to the containers of these
+	// runtimes. All containers are selected if it's empty.
+	RuntimeNames []types.RuntimeName
+	// Labels selects the containers by their runtime labels. All containers
+	// are selected if it's nil.
+	Labels KeyValueSelector
+	// Env selects the containers by their environment variables, taken
+	// from the OCI config or the container runtime. All containers are
+	// selected if it's nil.
+	Env KeyValueSelector
 }
 
 type ContainerSelector struct {
@@ -171,6 +207,13 @@ func ownerReferenceEnrichment(
 			}
 		}
 
+		// The mirror pods of the static pods are owned by the node
+		// running them
+		if nodeRef := k8sutil.NodeOwnerReference(ownerReferences); nodeRef != nil && highestOwnerRef == nil {
+			highestOwnerRef = nodeRef
+			break
+		}
+
 		ownerRef := getExpectedOwnerReference(ownerReferences)
 		if ownerRef == nil {
 			// None expected owner reference found
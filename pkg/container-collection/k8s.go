@@ -53,17 +53,15 @@ func NewK8sClient(nodeName string) (*K8sClient, error) {
 
 	fieldSelector := fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
 
-	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("getting node %w", err)
-	}
-
 	// Get a runtime client to talk to the container runtime handling pods in
 	// this node.
-	list := strings.SplitN(node.Status.NodeInfo.ContainerRuntimeVersion, "://", 2)
+	runtimeName, err := nodeContainerRuntime(clientset, nodeName)
+	if err != nil {
+		return nil, err
+	}
 	runtimeClient, err := containerutils.NewContainerRuntimeClient(
 		&containerutilsTypes.RuntimeConfig{
-			Name: types.String2RuntimeName(list[0]),
+			Name: runtimeName,
 		})
 	if err != nil {
 		return nil, err
@@ -77,6 +75,31 @@ func NewK8sClient(nodeName string) (*K8sClient, error) {
 	}, nil
 }
 
+// GetNodeContainerRuntime returns the name of the container runtime handling
+// the pods of the given node.
+func GetNodeContainerRuntime(nodeName string) (types.RuntimeName, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return "", err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	return nodeContainerRuntime(clientset, nodeName)
+}
+
+func nodeContainerRuntime(clientset *kubernetes.Clientset, nodeName string) (types.RuntimeName, error) {
+	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("getting node %w", err)
+	}
+
+	list := strings.SplitN(node.Status.NodeInfo.ContainerRuntimeVersion, "://", 2)
+	return types.String2RuntimeName(list[0]), nil
+}
+
 func (k *K8sClient) Close() {
 	k.runtimeClient.Close()
 }
@@ -91,6 +114,27 @@ func trimRuntimePrefix(id string) string {
 	return parts[1]
 }
 
+// containerTypeFromPod returns the type of the container with the given name
+// in the pod.
+func containerTypeFromPod(pod *v1.Pod, containerName string) types.ContainerType {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == containerName {
+			return types.ContainerTypeMain
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name == containerName {
+			return types.ContainerTypeInit
+		}
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		if c.Name == containerName {
+			return types.ContainerTypeEphemeral
+		}
+	}
+	return ""
+}
+
 // GetNonRunningContainers returns the list of containers IDs that are not running.
 func (k *K8sClient) GetNonRunningContainers(pod *v1.Pod) []string {
 	ret := []string{}
@@ -155,7 +199,8 @@ func (k *K8sClient) GetRunningContainers(pod *v1.Pod) []Container {
 					PodName:       pod.GetName(),
 					ContainerName: s.Name,
 				},
-				PodLabels: labels,
+				PodLabels:     labels,
+				ContainerType: containerTypeFromPod(pod, s.Name),
 			},
 		}
 		containers = append(containers, containerDef)
@@ -0,0 +1,49 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestContainerTypeFromPod(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "init"}},
+			Containers:     []v1.Container{{Name: "app"}, {Name: "sidecar"}},
+			EphemeralContainers: []v1.EphemeralContainer{
+				{EphemeralContainerCommon: v1.EphemeralContainerCommon{Name: "debugger"}},
+			},
+		},
+	}
+
+	table := map[string]types.ContainerType{
+		"init":     types.ContainerTypeInit,
+		"app":      types.ContainerTypeMain,
+		"sidecar":  types.ContainerTypeMain,
+		"debugger": types.ContainerTypeEphemeral,
+		"unknown":  "",
+	}
+
+	for name, expected := range table {
+		if ct := containerTypeFromPod(pod, name); ct != expected {
+			t.Fatalf("container %q: got type %q, expected %q", name, ct, expected)
+		}
+	}
+}
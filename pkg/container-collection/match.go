@@ -15,11 +15,110 @@
 package containercollection
 
 import (
+	"fmt"
 	"strings"
 
 	"golang.org/x/exp/slices"
 )
 
+// KeyValueSelector selects the containers by key/value pairs, like their
+// runtime labels or environment variables. Contrary to the label selectors of
+// Kubernetes, the keys and the values aren't restricted to the syntax of the
+// Kubernetes labels, e.g. values can contain '/'. All the requirements must be
+// satisfied.
+type KeyValueSelector []keyValueRequirement
+
+type keyValueOperator int
+
+const (
+	keyValueEquals keyValueOperator = iota
+	keyValueNotEquals
+	keyValueExists
+	keyValueDoesNotExist
+)
+
+type keyValueRequirement struct {
+	key   string
+	value string
+	op    keyValueOperator
+}
+
+// ParseKeyValueSelector parses requirements separated by comma: "key=value"
+// (or "key==value"), "key!=value", "key" to select the containers that have
+// the key and "!key" the ones that don't. Keys and values can contain any
+// character but ',', and keys can't contain '=' either.
+func ParseKeyValueSelector(selector string) (KeyValueSelector, error) {
+	var s KeyValueSelector
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		var r keyValueRequirement
+		if key, value, ok := strings.Cut(term, "="); ok {
+			r.op = keyValueEquals
+			if k, isNot := strings.CutSuffix(key, "!"); isNot {
+				r.op = keyValueNotEquals
+				key = k
+			} else {
+				value = strings.TrimPrefix(value, "=")
+			}
+			r.key, r.value = strings.TrimSpace(key), strings.TrimSpace(value)
+		} else if key, isNot := strings.CutPrefix(term, "!"); isNot {
+			r.key, r.op = strings.TrimSpace(key), keyValueDoesNotExist
+		} else {
+			r.key, r.op = term, keyValueExists
+		}
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid requirement %q: empty key", term)
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// Matches tells if the key/value pairs satisfy all the requirements. A nil
+// selector matches everything.
+func (s KeyValueSelector) Matches(set map[string]string) bool {
+	for _, r := range s {
+		value, ok := set[r.key]
+		switch r.op {
+		case keyValueEquals:
+			if !ok || value != r.value {
+				return false
+			}
+		case keyValueNotEquals:
+			// Like the label selectors of Kubernetes, a missing key satisfies
+			// "key!=value"
+			if ok && value == r.value {
+				return false
+			}
+		case keyValueExists:
+			if !ok {
+				return false
+			}
+		case keyValueDoesNotExist:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// containerEnv returns the environment variables of the container, from its
+// OCI config or, for the containers listed by the runtime client, from the
+// runtime.
+func containerEnv(c *Container) map[string]string {
+	vars := c.runtimeEnv
+	if c.OciConfig != nil && c.OciConfig.Process != nil {
+		vars = c.OciConfig.Process.Env
+	}
+	env := map[string]string{}
+	for _, e := range vars {
+		k, v, _ := strings.Cut(e, "=")
+		env[k] = v
+	}
+	return env
+}
+
 // ContainerSelectorMatches tells if a container matches the criteria in a
 // container selector.
 func ContainerSelectorMatches(s *ContainerSelector, c *Container) bool {
@@ -35,6 +134,21 @@ func ContainerSelectorMatches(s *ContainerSelector, c *Container) bool {
 	if s.Runtime.ContainerName != "" && s.Runtime.ContainerName != c.Runtime.ContainerName {
 		return false
 	}
+	if len(s.Runtime.RuntimeNames) > 0 && !slices.Contains(s.Runtime.RuntimeNames, c.Runtime.RuntimeName) {
+		return false
+	}
+	if !s.Runtime.Labels.Matches(c.Runtime.Labels) {
+		return false
+	}
+	if len(s.Runtime.Env) > 0 && !s.Runtime.Env.Matches(containerEnv(c)) {
+		return false
+	}
+	if len(s.K8s.ContainerTypes) > 0 && !slices.Contains(s.K8s.ContainerTypes, c.K8s.ContainerType) {
+		return false
+	}
+	if slices.Contains(s.K8s.ExcludedContainerTypes, c.K8s.ContainerType) {
+		return false
+	}
 	for sk, sv := range s.K8s.PodLabels {
 		if cv, ok := c.K8s.PodLabels[sk]; !ok || cv != sv {
 			return false
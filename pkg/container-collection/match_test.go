@@ -19,12 +19,21 @@ import (
 	"reflect"
 	"testing"
 
+	ocispec "github.com/opencontainers/runtime-spec/specs-go"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sTypes "k8s.io/apimachinery/pkg/types"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
+func mustParseSelector(selector string) KeyValueSelector {
+	s, err := ParseKeyValueSelector(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
 func TestSelector(t *testing.T) {
 	table := []struct {
 		description string
@@ -170,6 +179,194 @@ func TestSelector(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "Container type matches",
+			match:       true,
+			selector: &ContainerSelector{
+				K8s: K8sSelector{
+					ContainerTypes: []types.ContainerType{types.ContainerTypeMain, types.ContainerTypeEphemeral},
+				},
+			},
+			container: &Container{
+				K8s: K8sMetadata{
+					BasicK8sMetadata: types.BasicK8sMetadata{
+						PodName:       "this-pod",
+						ContainerName: "debugger",
+					},
+					ContainerType: types.ContainerTypeEphemeral,
+				},
+			},
+		},
+		{
+			description: "Container type doesn't match",
+			match:       false,
+			selector: &ContainerSelector{
+				K8s: K8sSelector{
+					ContainerTypes: []types.ContainerType{types.ContainerTypeMain},
+				},
+			},
+			container: &Container{
+				K8s: K8sMetadata{
+					BasicK8sMetadata: types.BasicK8sMetadata{
+						PodName:       "this-pod",
+						ContainerName: "init-db",
+					},
+					ContainerType: types.ContainerTypeInit,
+				},
+			},
+		},
+		{
+			description: "Container type excluded",
+			match:       false,
+			selector: &ContainerSelector{
+				K8s: K8sSelector{
+					ExcludedContainerTypes: []types.ContainerType{types.ContainerTypeInit},
+				},
+			},
+			container: &Container{
+				K8s: K8sMetadata{
+					BasicK8sMetadata: types.BasicK8sMetadata{
+						PodName:       "this-pod",
+						ContainerName: "init-db",
+					},
+					ContainerType: types.ContainerTypeInit,
+				},
+			},
+		},
+		{
+			description: "Container type not excluded",
+			match:       true,
+			selector: &ContainerSelector{
+				K8s: K8sSelector{
+					ExcludedContainerTypes: []types.ContainerType{types.ContainerTypeInit},
+				},
+			},
+			container: &Container{
+				K8s: K8sMetadata{
+					BasicK8sMetadata: types.BasicK8sMetadata{
+						PodName:       "this-pod",
+						ContainerName: "app",
+					},
+					ContainerType: types.ContainerTypeMain,
+				},
+			},
+		},
+		{
+			description: "Runtime labels match",
+			match:       true,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					Labels: mustParseSelector("app=payments"),
+				},
+			},
+			container: &Container{
+				Runtime: RuntimeMetadata{
+					Labels: map[string]string{"app": "payments", "tier": "web"},
+				},
+			},
+		},
+		{
+			description: "Runtime labels don't match",
+			match:       false,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					Labels: mustParseSelector("app=payments,tier!=web"),
+				},
+			},
+			container: &Container{
+				Runtime: RuntimeMetadata{
+					Labels: map[string]string{"app": "payments", "tier": "web"},
+				},
+			},
+		},
+		{
+			description: "Environment variables match",
+			match:       true,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					Env: mustParseSelector("ENV=prod,!DEBUG"),
+				},
+			},
+			container: &Container{
+				OciConfig: &ocispec.Spec{
+					Process: &ocispec.Process{
+						Env: []string{"PATH=/usr/bin:/bin", "ENV=prod"},
+					},
+				},
+			},
+		},
+		{
+			description: "Runtime labels with '/' match",
+			match:       true,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					Labels: mustParseSelector("com.example/data=/var/lib/data,com.example/owner"),
+				},
+			},
+			container: &Container{
+				Runtime: RuntimeMetadata{
+					Labels: map[string]string{"com.example/data": "/var/lib/data", "com.example/owner": "team"},
+				},
+			},
+		},
+		{
+			description: "Environment variables with '/' match",
+			match:       true,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					Env: mustParseSelector("PATH==/usr/bin:/bin,HOME!=/root"),
+				},
+			},
+			container: &Container{
+				OciConfig: &ocispec.Spec{
+					Process: &ocispec.Process{
+						Env: []string{"PATH=/usr/bin:/bin", "HOME=/home/app"},
+					},
+				},
+			},
+		},
+		{
+			description: "Environment variables without OCI config",
+			match:       false,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					Env: mustParseSelector("ENV=prod"),
+				},
+			},
+			container: &Container{},
+		},
+		{
+			description: "Runtime name matches",
+			match:       true,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					RuntimeNames: []types.RuntimeName{types.RuntimeNameDocker, types.RuntimeNameContainerd},
+				},
+			},
+			container: &Container{
+				Runtime: RuntimeMetadata{
+					BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+						RuntimeName: types.RuntimeNameContainerd,
+					},
+				},
+			},
+		},
+		{
+			description: "Runtime name doesn't match",
+			match:       false,
+			selector: &ContainerSelector{
+				Runtime: RuntimeSelector{
+					RuntimeNames: []types.RuntimeName{types.RuntimeNameDocker},
+				},
+			},
+			container: &Container{
+				Runtime: RuntimeMetadata{
+					BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+						RuntimeName: types.RuntimeNameCrio,
+					},
+				},
+			},
+		},
 	}
 
 	for i, entry := range table {
@@ -567,3 +764,11 @@ func TestContainerResolver(t *testing.T) {
 		t.Fatalf("Error while looking up containers in a non-existent namespace")
 	}
 }
+
+func TestParseKeyValueSelector(t *testing.T) {
+	for _, selector := range []string{"", "=value", "app=payments,", "!", "!=value"} {
+		if _, err := ParseKeyValueSelector(selector); err == nil {
+			t.Errorf("expected an error parsing %q", selector)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -26,6 +27,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -37,9 +39,12 @@ import (
 	containerhook "github.com/inspektor-gadget/inspektor-gadget/pkg/container-hook"
 	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cgroups"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cri"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/machines"
 	ociannotations "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/oci-annotations"
 	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
 	containerutilsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runcfanotify"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
@@ -52,12 +57,18 @@ func enrichContainerWithContainerData(containerData *runtimeclient.ContainerData
 	container.Runtime.ContainerName = containerData.Runtime.ContainerName
 	container.Runtime.ContainerImageName = containerData.Runtime.ContainerImageName
 	container.Runtime.ContainerImageDigest = containerData.Runtime.ContainerImageDigest
+	if containerData.Runtime.Labels != nil {
+		container.Runtime.Labels = containerData.Runtime.Labels
+	}
 
 	// Kubernetes
 	container.K8s.Namespace = containerData.K8s.Namespace
 	container.K8s.PodName = containerData.K8s.PodName
 	container.K8s.PodUID = containerData.K8s.PodUID
 	container.K8s.ContainerName = containerData.K8s.ContainerName
+	if containerData.K8s.Annotations != nil {
+		container.runtimeAnnotations = containerData.K8s.Annotations
+	}
 }
 
 func containerRuntimeEnricher(
@@ -143,7 +154,16 @@ func WithContainerRuntimeEnrichment(runtime *containerutilsTypes.RuntimeConfig)
 			return err
 		}
 
-		switch runtime.Name {
+		return WithContainerRuntimeClient(runtime.Name, runtimeClient)(cc)
+	}
+}
+
+// WithContainerRuntimeClient is like WithContainerRuntimeEnrichment() but
+// uses the given client to get the metadata of the containers, e.g. a fake
+// one in tests. The client is closed on Close().
+func WithContainerRuntimeClient(runtimeName types.RuntimeName, runtimeClient runtimeclient.ContainerRuntimeClient) ContainerCollectionOption {
+	return func(cc *ContainerCollection) error {
+		switch runtimeName {
 		case types.RuntimeNamePodman:
 			// Podman only supports runtime enrichment for initial containers otherwise it will deadlock.
 			// As a consequence, we need to ensure that new podman containers will be enriched with all
@@ -155,13 +175,13 @@ func WithContainerRuntimeEnrichment(runtime *containerutilsTypes.RuntimeConfig)
 			// unavailable and once it is up, we will start receiving the
 			// notifications for its containers thus we will be able to enrich them.
 			cc.containerEnrichers = append(cc.containerEnrichers, func(container *Container) bool {
-				return containerRuntimeEnricher(runtime.Name, runtimeClient, container)
+				return containerRuntimeEnricher(runtimeName, runtimeClient, container)
 			})
 		}
 
 		cc.cleanUpFuncs = append(cc.cleanUpFuncs, func() {
 			if err := runtimeClient.Close(); err != nil {
-				log.Warnf("failed to close container runtime %s: %s", runtime.Name, err)
+				log.Warnf("failed to close container runtime %s: %s", runtimeName, err)
 			}
 		})
 
@@ -170,21 +190,21 @@ func WithContainerRuntimeEnrichment(runtime *containerutilsTypes.RuntimeConfig)
 		if err != nil {
 			if !cc.disableContainerRuntimeWarnings {
 				log.Warnf("Runtime enricher (%s): couldn't get current containers: %s",
-					runtime.Name, err)
+					runtimeName, err)
 			}
 			return nil
 		}
 		for _, container := range containers {
 			if container.Runtime.State != runtimeclient.StateRunning {
 				log.Debugf("Runtime enricher(%s): Skip container %q (ID: %s): not running",
-					runtime.Name, container.Runtime.ContainerName, container.Runtime.ContainerID)
+					runtimeName, container.Runtime.ContainerName, container.Runtime.ContainerID)
 				continue
 			}
 
 			containerDetails, err := runtimeClient.GetContainerDetails(container.Runtime.ContainerID)
 			if err != nil {
 				log.Debugf("Runtime enricher (%s): Skip container %q (ID: %s): couldn't find container: %s",
-					runtime.Name, container.Runtime.ContainerName, container.Runtime.ContainerID, err)
+					runtimeName, container.Runtime.ContainerName, container.Runtime.ContainerID, err)
 				continue
 			}
 
@@ -197,6 +217,7 @@ func WithContainerRuntimeEnrichment(runtime *containerutilsTypes.RuntimeConfig)
 			var c Container
 			c.Pid = uint32(pid)
 			enrichContainerWithContainerData(&containerDetails.ContainerData, &c)
+			c.runtimeEnv = containerDetails.Env
 			cc.initialContainers = append(cc.initialContainers, &c)
 		}
 
@@ -437,7 +458,9 @@ func getOwnerReferences(dynamicClient dynamic.Interface,
 	return res.GetOwnerReferences(), nil
 }
 
-// WithKubernetesEnrichment automatically adds pod metadata
+// WithKubernetesEnrichment automatically adds pod metadata. The pods of the
+// node are looked up in the cache of an informer, the API server is only
+// queried for the ones that aren't in it yet.
 //
 // ContainerCollection.Initialize(WithKubernetesEnrichment())
 func WithKubernetesEnrichment(nodeName string, kubeconfig *rest.Config) ContainerCollectionOption {
@@ -454,9 +477,40 @@ func WithKubernetesEnrichment(nodeName string, kubeconfig *rest.Config) Containe
 			return fmt.Errorf("getting Kubernetes client: %w", err)
 		}
 
+		fieldSelector := fields.OneTermEqualSelector("spec.nodeName", nodeName)
+		podListWatcher := cache.NewListWatchFromClient(clientset.CoreV1().RESTClient(), "pods", "", fieldSelector)
+		podStore, podController := cache.NewInformer(podListWatcher, &v1.Pod{}, 0, cache.ResourceEventHandlerFuncs{})
+		stop := make(chan struct{})
+		cc.cleanUpFuncs = append(cc.cleanUpFuncs, func() {
+			close(stop)
+		})
+		go podController.Run(stop)
+		if !cache.WaitForCacheSync(stop, podController.HasSynced) {
+			return errors.New("syncing pod cache")
+		}
+
+		getPod := func(namespace, name string) (*v1.Pod, error) {
+			obj, exists, err := podStore.GetByKey(namespace + "/" + name)
+			if err == nil && exists {
+				return obj.(*v1.Pod), nil
+			}
+			return clientset.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		}
+
 		// Future containers
 		cc.containerEnrichers = append(cc.containerEnrichers, func(container *Container) bool {
 			if container.K8s.PodName != "" {
+				// The pod is already known, e.g. from the OCI annotations,
+				// but they don't tell the container type.
+				if container.K8s.ContainerType == "" && container.K8s.ContainerName != "" {
+					pod, err := getPod(container.K8s.Namespace, container.K8s.PodName)
+					if err != nil {
+						log.Debugf("kubernetes enricher: cannot get pod %s/%s: %s",
+							container.K8s.Namespace, container.K8s.PodName, err)
+						return true
+					}
+					container.K8s.ContainerType = containerTypeFromPod(pod, container.K8s.ContainerName)
+				}
 				return true
 			}
 
@@ -465,23 +519,19 @@ func WithKubernetesEnrichment(nodeName string, kubeconfig *rest.Config) Containe
 				return true
 			}
 
-			fieldSelector := fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
-			pods, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{
-				FieldSelector: fieldSelector,
-			})
-			if err != nil {
-				log.Errorf("kubernetes enricher: cannot fetch pods: %s", err)
-				return true
-			}
-
 			// Fill Kubernetes fields
 			namespace := ""
 			podname := ""
 			podUID := ""
 			containerName := ""
+			var containerType types.ContainerType
 			labels := make(map[string]string)
-			for _, pod := range pods.Items {
-				uid := string(pod.ObjectMeta.UID)
+			for _, obj := range podStore.List() {
+				pod := obj.(*v1.Pod)
+
+				// The cgroups and the kubelet directories of the static
+				// pods use their UID, not the one of their mirror pod.
+				uid := k8sutil.RuntimePodUID(pod)
 				// check if this container is associated to this pod
 				uidWithUnderscores := strings.ReplaceAll(uid, "-", "_")
 
@@ -516,6 +566,7 @@ func WithKubernetesEnrichment(nodeName string, kubeconfig *rest.Config) Containe
 						pattern := fmt.Sprintf("pods/%s/containers/%s/", uid, name)
 						if strings.Contains(m.Source, pattern) {
 							containerName = name
+							containerType = containerTypeFromPod(pod, name)
 							break outerLoop
 						}
 					}
@@ -526,6 +577,7 @@ func WithKubernetesEnrichment(nodeName string, kubeconfig *rest.Config) Containe
 			container.K8s.PodName = podname
 			container.K8s.PodUID = podUID
 			container.K8s.ContainerName = containerName
+			container.K8s.ContainerType = containerType
 			container.K8s.PodLabels = labels
 
 			// drop pause containers
@@ -634,6 +686,280 @@ func WithContainerFanotifyEbpf() ContainerCollectionOption {
 	}
 }
 
+// criContainer returns the container with the given ID using the CRI, or nil
+// if it can't be found or isn't a regular container, e.g. a pod sandbox.
+func criContainer(criClient *cri.CRIClient, containerID string) *Container {
+	containerDetails, err := criClient.GetContainerDetails(containerID)
+	if err != nil {
+		log.Debugf("CRI events (%s): skip container %s: %s", criClient.Name, containerID, err)
+		return nil
+	}
+
+	pid := containerDetails.Pid
+	if pid > math.MaxUint32 {
+		log.Errorf("Container PID (%d) exceeds math.MaxUint32 (%d), skipping this container", pid, math.MaxUint32)
+		return nil
+	}
+
+	container := &Container{Pid: uint32(pid)}
+	enrichContainerWithContainerData(&containerDetails.ContainerData, container)
+	return container
+}
+
+// syncCRIContainers adds the running containers that aren't in the collection
+// yet and removes the ones of the runtime that aren't running anymore. It's
+// used to catch up with the events lost while the stream was down.
+func (cc *ContainerCollection) syncCRIContainers(criClient *cri.CRIClient) {
+	containers, err := criClient.GetContainers()
+	if err != nil {
+		log.Warnf("CRI events (%s): couldn't get current containers: %s", criClient.Name, err)
+		return
+	}
+
+	running := make(map[string]struct{}, len(containers))
+	for _, c := range containers {
+		if c.Runtime.State != runtimeclient.StateRunning {
+			continue
+		}
+		running[c.Runtime.ContainerID] = struct{}{}
+
+		if cc.GetContainer(c.Runtime.ContainerID) != nil {
+			continue
+		}
+		if container := criContainer(criClient, c.Runtime.ContainerID); container != nil {
+			cc.AddContainer(container)
+		}
+	}
+
+	cc.containers.Range(func(key, value any) bool {
+		c := value.(*Container)
+		if c.Runtime.RuntimeName != criClient.Name {
+			return true
+		}
+		if _, ok := running[c.Runtime.ContainerID]; !ok {
+			cc.RemoveContainer(c.Runtime.ContainerID)
+		}
+		return true
+	})
+}
+
+// WithCRIEvents uses the CRI GetContainerEvents API of the runtime to detect
+// when containers are started and stopped and add them in the
+// ContainerCollection. It also adds the containers already running.
+//
+// Contrary to WithRuncFanotify() and WithContainerFanotifyEbpf(), it only
+// requires access to the runtime socket. It's supported by containerd >= 1.7
+// and CRI-O >= 1.26.
+//
+// ContainerCollection.Initialize(WithCRIEvents(runtime))
+func WithCRIEvents(runtime *containerutilsTypes.RuntimeConfig) ContainerCollectionOption {
+	return func(cc *ContainerCollection) error {
+		criClient, err := containerutils.NewCRIClient(runtime)
+		if err != nil {
+			return fmt.Errorf("creating CRI client: %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cc.cleanUpFuncs = append(cc.cleanUpFuncs, func() {
+			cancel()
+			criClient.Close()
+		})
+
+		// Open the stream before listing the containers, so the ones started
+		// meanwhile aren't missed. They can be reported by both.
+		events, err := criClient.OpenContainerEvents(ctx)
+		if err != nil {
+			return fmt.Errorf("opening container events: %w", err)
+		}
+
+		// Initial containers
+		containers, err := criClient.GetContainers()
+		if err != nil {
+			return fmt.Errorf("getting current containers: %w", err)
+		}
+		for _, c := range containers {
+			if c.Runtime.State != runtimeclient.StateRunning {
+				continue
+			}
+			if container := criContainer(criClient, c.Runtime.ContainerID); container != nil {
+				cc.initialContainers = append(cc.initialContainers, container)
+			}
+		}
+
+		// Future containers
+		callback := func(containerID string, eventType cri.ContainerEventType) {
+			switch eventType {
+			case cri.ContainerEventStarted:
+				if cc.GetContainer(containerID) != nil {
+					return
+				}
+				if container := criContainer(criClient, containerID); container != nil {
+					cc.AddContainer(container)
+				}
+			case cri.ContainerEventStopped:
+				cc.RemoveContainer(containerID)
+			}
+		}
+
+		go func() {
+			const maxBackoff = 30 * time.Second
+			backoff := time.Second
+
+			// Handle the events once the enrichers are set up and the initial
+			// containers added, so the ones listed too are skipped.
+			select {
+			case <-ctx.Done():
+				return
+			case <-cc.ready:
+			}
+
+			for {
+				start := time.Now()
+				var err error
+				if events != nil {
+					err = events.Watch(callback)
+					events = nil
+				} else {
+					err = criClient.WatchContainerEvents(ctx, callback)
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				if errors.Is(err, cri.ErrEventsUnsupported) {
+					log.Errorf("CRI events (%s): %s. New containers won't be detected", runtime.Name, err)
+					return
+				}
+				// Only back off when the stream keeps failing
+				if time.Since(start) > maxBackoff {
+					backoff = time.Second
+				}
+				log.Warnf("CRI events (%s): %s. Retrying in %s", runtime.Name, err, backoff)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+
+				cc.syncCRIContainers(criClient)
+			}
+		}()
+
+		return nil
+	}
+}
+
+// isMachineRuntime tells if the runtime is one of the ones detected by the
+// machines package.
+func isMachineRuntime(runtimeName types.RuntimeName) bool {
+	return runtimeName == types.RuntimeNameLXC || runtimeName == types.RuntimeNameSystemdNspawn
+}
+
+func machineContainer(m machines.Machine) *Container {
+	return &Container{
+		Runtime: RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				RuntimeName:   m.Runtime,
+				ContainerID:   m.Name,
+				ContainerName: m.Name,
+			},
+		},
+		Pid: m.Pid,
+	}
+}
+
+// hasMachines tells if the collection has any of the containers added by
+// WithMachines.
+func (cc *ContainerCollection) hasMachines() bool {
+	found := false
+	cc.containers.Range(func(key, value any) bool {
+		found = isMachineRuntime(value.(*Container).Runtime.RuntimeName)
+		return !found
+	})
+	return found
+}
+
+// syncMachines adds the machines that aren't in the collection yet and
+// removes the ones that aren't running anymore. A machine restarted with the
+// same name is replaced.
+func (cc *ContainerCollection) syncMachines() {
+	list, err := machines.List()
+	if err != nil {
+		log.Debugf("machines: listing machines: %s", err)
+		return
+	}
+
+	running := make(map[string]struct{}, len(list))
+	for _, m := range list {
+		running[m.Name] = struct{}{}
+
+		if c := cc.GetContainer(m.Name); c != nil {
+			if !isMachineRuntime(c.Runtime.RuntimeName) || c.Pid == m.Pid {
+				continue
+			}
+			cc.RemoveContainer(m.Name)
+		}
+		cc.AddContainer(machineContainer(m))
+	}
+
+	cc.containers.Range(func(key, value any) bool {
+		c := value.(*Container)
+		if !isMachineRuntime(c.Runtime.RuntimeName) {
+			return true
+		}
+		if _, ok := running[c.Runtime.ContainerID]; !ok {
+			cc.RemoveContainer(c.Runtime.ContainerID)
+		}
+		return true
+	})
+}
+
+// WithMachines adds, on a best-effort basis, the containers running a full
+// system, like LXC/LXD and systemd-nspawn ones. They aren't created by runc,
+// so the runtime hooks don't detect them. They are looked for periodically
+// using the machines registered with systemd-machined and the cgroups created
+// by those tools, only while the host has any. The machine name is used as
+// container ID and name.
+//
+// ContainerCollection.Initialize(WithMachines())
+func WithMachines() ContainerCollectionOption {
+	return func(cc *ContainerCollection) error {
+		// Initial containers
+		list, err := machines.List()
+		if err != nil {
+			log.Debugf("machines: listing machines: %s", err)
+		}
+		for _, m := range list {
+			cc.initialContainers = append(cc.initialContainers, machineContainer(m))
+		}
+
+		// Future containers
+		go func() {
+			ticker := time.NewTicker(5 * time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					// The processes are only scanned while there are
+					// machines, or some are left to remove
+					if machines.Present() || cc.hasMachines() {
+						cc.syncMachines()
+					}
+				case <-cc.done:
+					return
+				}
+			}
+		}()
+
+		return nil
+	}
+}
+
 // WithCgroupEnrichment enables an enricher to add the cgroup metadata
 func WithCgroupEnrichment() ContainerCollectionOption {
 	return func(cc *ContainerCollection) error {
@@ -649,8 +975,15 @@ func WithCgroupEnrichment() ContainerCollectionOption {
 				log.Errorf("cgroup enricher: failed to get cgroup paths on container %s: %s", container.Runtime.ContainerID, err)
 				return true
 			}
-			cgroupPathV2WithMountpoint, _ := cgroups.CgroupPathV2AddMountpoint(cgroupPathV2)
-			cgroupID, _ := cgroups.GetCgroupID(cgroupPathV2WithMountpoint)
+			// Processes in the root of the cgroup2 hierarchy, e.g. on hybrid
+			// systems where the runtime only uses the cgroup v1 ones, don't
+			// have a cgroup of their own: don't use the ID of the root.
+			var cgroupPathV2WithMountpoint string
+			var cgroupID uint64
+			if cgroupPathV2 != "" {
+				cgroupPathV2WithMountpoint, _ = cgroups.CgroupPathV2AddMountpoint(cgroupPathV2)
+				cgroupID, _ = cgroups.GetCgroupID(cgroupPathV2WithMountpoint)
+			}
 
 			container.CgroupPath = cgroupPathV2WithMountpoint
 			container.CgroupID = cgroupID
@@ -761,6 +1094,51 @@ func WithOCIConfigEnrichment() ContainerCollectionOption {
 	}
 }
 
+// WithAnnotationsEnrichment enables an enricher to add the annotations of the
+// container and its pod whose keys match one of the given glob patterns, e.g.
+// "example.com/*". The annotations are taken from the OCI config and from the
+// container runtime, so this option must be passed after the ones providing
+// them: WithOCIConfigEnrichment(), WithContainerRuntimeEnrichment(), etc.
+func WithAnnotationsEnrichment(patterns []string) ContainerCollectionOption {
+	return func(cc *ContainerCollection) error {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid annotation pattern %q: %w", pattern, err)
+			}
+		}
+		if len(patterns) == 0 {
+			return nil
+		}
+
+		cc.containerEnrichers = append(cc.containerEnrichers, func(container *Container) bool {
+			annotations := container.runtimeAnnotations
+			if container.OciConfig != nil {
+				ociAnnotations := ociannotations.Annotations(container.OciConfig.Annotations)
+				for k, v := range annotations {
+					ociAnnotations[k] = v
+				}
+				annotations = ociAnnotations
+			}
+
+			for k, v := range annotations {
+				for _, pattern := range patterns {
+					if ok, _ := path.Match(pattern, k); !ok {
+						continue
+					}
+					if container.K8s.Annotations == nil {
+						container.K8s.Annotations = make(map[string]string)
+					}
+					container.K8s.Annotations[k] = v
+					break
+				}
+			}
+
+			return true
+		})
+		return nil
+	}
+}
+
 func WithNodeName(nodeName string) ContainerCollectionOption {
 	return func(cc *ContainerCollection) error {
 		cc.nodeName = nodeName
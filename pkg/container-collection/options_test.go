@@ -15,8 +15,10 @@
 package containercollection
 
 import (
+	"reflect"
 	"testing"
 
+	ocispec "github.com/opencontainers/runtime-spec/specs-go"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -268,3 +270,66 @@ func TestGetExpectedOwnerReference(t *testing.T) {
 		}
 	}
 }
+
+func TestWithAnnotationsEnrichment(t *testing.T) {
+	table := []struct {
+		description string
+		patterns    []string
+		container   *Container
+		expected    map[string]string
+	}{
+		{
+			description: "No annotations",
+			patterns:    []string{"example.com/*"},
+			container:   &Container{},
+		},
+		{
+			description: "From OCI config",
+			patterns:    []string{"example.com/*"},
+			container: &Container{
+				OciConfig: &ocispec.Spec{
+					Annotations: map[string]string{
+						"example.com/team":      "foo",
+						"io.kubernetes.pod.uid": "abcde",
+					},
+				},
+			},
+			expected: map[string]string{"example.com/team": "foo"},
+		},
+		{
+			description: "Runtime annotations take precedence",
+			patterns:    []string{"example.com/*", "owner"},
+			container: &Container{
+				OciConfig: &ocispec.Spec{
+					Annotations: map[string]string{
+						"example.com/team": "foo",
+					},
+				},
+				runtimeAnnotations: map[string]string{
+					"example.com/team": "bar",
+					"owner":            "alice",
+					"other":            "value",
+				},
+			},
+			expected: map[string]string{"example.com/team": "bar", "owner": "alice"},
+		},
+	}
+
+	for _, entry := range table {
+		cc := &ContainerCollection{}
+		if err := WithAnnotationsEnrichment(entry.patterns)(cc); err != nil {
+			t.Fatalf("Failed test %q: %s", entry.description, err)
+		}
+		for _, enricher := range cc.containerEnrichers {
+			enricher(entry.container)
+		}
+		if !reflect.DeepEqual(entry.container.K8s.Annotations, entry.expected) {
+			t.Fatalf("Failed test %q: result %v expected %v",
+				entry.description, entry.container.K8s.Annotations, entry.expected)
+		}
+	}
+
+	if err := WithAnnotationsEnrichment([]string{"["})(&ContainerCollection{}); err == nil {
+		t.Fatalf("Invalid pattern should fail")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// SandboxType is the kind of sandbox a container runs in. The processes of
+// sandboxed containers don't run on the host kernel, so the gadgets can't
+// observe them.
+type SandboxType string
+
+const (
+	SandboxTypeKata   SandboxType = "kata"
+	SandboxTypeGVisor SandboxType = "gvisor"
+)
+
+// runtimeHandlerAnnotations contain the name of the runtime handler, e.g.
+// "kata-qemu" or "runsc", used to run the container.
+var runtimeHandlerAnnotations = []string{
+	"io.kubernetes.cri-o.RuntimeHandler",
+	"io.kubernetes.cri.runtime-handler",
+}
+
+func sandboxFromName(name string) SandboxType {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "kata"):
+		return SandboxTypeKata
+	case strings.Contains(name, "runsc"), strings.Contains(name, "gvisor"):
+		return SandboxTypeGVisor
+	}
+	return ""
+}
+
+// sandboxFromAnnotations detects the sandbox from the annotations the
+// runtimes and the sandboxes themselves add to the containers.
+func sandboxFromAnnotations(annotations map[string]string) SandboxType {
+	for _, key := range runtimeHandlerAnnotations {
+		if sandbox := sandboxFromName(annotations[key]); sandbox != "" {
+			return sandbox
+		}
+	}
+	for key := range annotations {
+		switch {
+		case strings.HasPrefix(key, "io.katacontainers."):
+			return SandboxTypeKata
+		case strings.HasPrefix(key, "dev.gvisor."):
+			return SandboxTypeGVisor
+		}
+	}
+	return ""
+}
+
+// sandboxFromExecutable detects the sandbox from the executable of the
+// container process reported by the runtime: it's the hypervisor or the shim
+// for Kata Containers and runsc for gVisor.
+func sandboxFromExecutable(exe string) SandboxType {
+	base := filepath.Base(exe)
+	switch {
+	case strings.HasPrefix(base, "qemu-system-"),
+		base == "cloud-hypervisor",
+		base == "firecracker",
+		strings.HasPrefix(base, "containerd-shim-kata"):
+		return SandboxTypeKata
+	case strings.HasPrefix(base, "runsc"):
+		return SandboxTypeGVisor
+	}
+	return ""
+}
+
+func detectSandbox(container *Container) SandboxType {
+	if container.OciConfig != nil {
+		if sandbox := sandboxFromAnnotations(container.OciConfig.Annotations); sandbox != "" {
+			return sandbox
+		}
+	}
+	if sandbox := sandboxFromAnnotations(container.runtimeAnnotations); sandbox != "" {
+		return sandbox
+	}
+
+	if container.Pid == 0 {
+		return ""
+	}
+	exe, err := os.Readlink(filepath.Join(host.HostProcFs, fmt.Sprint(container.Pid), "exe"))
+	if err != nil {
+		return ""
+	}
+	return sandboxFromExecutable(exe)
+}
+
+// WithSandboxDetection enables an enricher to detect the containers running
+// in a sandbox, like Kata Containers or gVisor, and set their
+// Runtime.SandboxType. It must be used after the enrichers providing the OCI
+// config and the runtime annotations.
+//
+// ContainerCollection.Initialize(WithSandboxDetection())
+func WithSandboxDetection() ContainerCollectionOption {
+	return func(cc *ContainerCollection) error {
+		cc.containerEnrichers = append(cc.containerEnrichers, func(container *Container) bool {
+			if container.Runtime.SandboxType == "" {
+				container.Runtime.SandboxType = detectSandbox(container)
+			}
+			return true
+		})
+		return nil
+	}
+}
+
+// SandboxWarning returns the warning to show to the users tracing the
+// container, or an empty string if it isn't sandboxed.
+func (c *Container) SandboxWarning() string {
+	if c.Runtime.SandboxType == "" {
+		return ""
+	}
+
+	name := c.K8s.ContainerName
+	if c.K8s.PodName != "" {
+		name = fmt.Sprintf("%s/%s/%s", c.K8s.Namespace, c.K8s.PodName, c.K8s.ContainerName)
+	} else if c.Runtime.ContainerName != "" {
+		name = c.Runtime.ContainerName
+	}
+
+	return fmt.Sprintf("container %q runs in a %s sandbox: its processes can't be observed and no events will be reported for it",
+		name, c.Runtime.SandboxType)
+}
+
+// SubscribeToSandboxedContainers calls warn with the SandboxWarning() of the
+// sandboxed containers matching the selector: the current ones and the ones
+// added until Unsubscribe(key) is called. It does nothing and returns false
+// if the collection has no pubsub.
+func (cc *ContainerCollection) SubscribeToSandboxedContainers(key interface{}, selector ContainerSelector, warn func(msg string)) bool {
+	if cc.pubsub == nil {
+		return false
+	}
+
+	containers := cc.Subscribe(key, selector, func(event PubSubEvent) {
+		if event.Type != EventTypeAddContainer {
+			return
+		}
+		if msg := event.Container.SandboxWarning(); msg != "" {
+			warn(msg)
+		}
+	})
+	for _, container := range containers {
+		if msg := container.SandboxWarning(); msg != "" {
+			warn(msg)
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"testing"
+
+	ocispec "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestDetectSandbox(t *testing.T) {
+	table := []struct {
+		description string
+		container   *Container
+		expected    SandboxType
+	}{
+		{
+			description: "Regular container",
+			container: &Container{
+				OciConfig: &ocispec.Spec{
+					Annotations: map[string]string{"io.kubernetes.cri.container-type": "container"},
+				},
+			},
+		},
+		{
+			description: "CRI-O runtime handler",
+			container: &Container{
+				OciConfig: &ocispec.Spec{
+					Annotations: map[string]string{"io.kubernetes.cri-o.RuntimeHandler": "kata-qemu"},
+				},
+			},
+			expected: SandboxTypeKata,
+		},
+		{
+			description: "Kata annotations",
+			container: &Container{
+				runtimeAnnotations: map[string]string{"io.katacontainers.config.hypervisor.default_memory": "2048"},
+			},
+			expected: SandboxTypeKata,
+		},
+		{
+			description: "gVisor annotations",
+			container: &Container{
+				OciConfig: &ocispec.Spec{
+					Annotations: map[string]string{"dev.gvisor.spec.mount.data.type": "tmpfs"},
+				},
+			},
+			expected: SandboxTypeGVisor,
+		},
+	}
+
+	for _, entry := range table {
+		if sandbox := detectSandbox(entry.container); sandbox != entry.expected {
+			t.Fatalf("Failed test %q: got %q, expected %q", entry.description, sandbox, entry.expected)
+		}
+	}
+}
+
+func TestSandboxFromExecutable(t *testing.T) {
+	table := map[string]SandboxType{
+		"/usr/bin/qemu-system-x86_64":            SandboxTypeKata,
+		"/opt/kata/bin/cloud-hypervisor":         SandboxTypeKata,
+		"/usr/local/bin/containerd-shim-kata-v2": SandboxTypeKata,
+		"/usr/local/bin/runsc":                   SandboxTypeGVisor,
+		"/usr/sbin/nginx":                        "",
+	}
+
+	for exe, expected := range table {
+		if sandbox := sandboxFromExecutable(exe); sandbox != expected {
+			t.Fatalf("%q: got %q, expected %q", exe, sandbox, expected)
+		}
+	}
+}
+
+func TestSandboxWarning(t *testing.T) {
+	c := &Container{
+		K8s: K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{
+				Namespace:     "default",
+				PodName:       "mypod",
+				ContainerName: "mycontainer",
+			},
+		},
+	}
+	if msg := c.SandboxWarning(); msg != "" {
+		t.Fatalf("unexpected warning for a regular container: %s", msg)
+	}
+
+	c.Runtime.SandboxType = SandboxTypeKata
+	expected := `container "default/mypod/mycontainer" runs in a kata sandbox: its processes can't be observed and no events will be reported for it`
+	if msg := c.SandboxWarning(); msg != expected {
+		t.Fatalf("got %q, expected %q", msg, expected)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+)
+
+// DefaultStateFile is where the gadget daemon saves the containers of the
+// collection by default. /run is cleared on reboot, like the containers.
+const DefaultStateFile = "/run/ig/containers.json"
+
+// stateSaveInterval is how often the state is saved when it changed
+const stateSaveInterval = time.Second
+
+// state is the content of the state file
+type state struct {
+	Containers []*Container `json:"containers"`
+}
+
+// saveState writes the containers of the collection to the file. The file is
+// replaced atomically so a crash while writing it doesn't corrupt it.
+func (cc *ContainerCollection) saveState(path string) error {
+	s := state{Containers: []*Container{}}
+	cc.ContainerRange(func(c *Container) {
+		if c.Runtime.ContainerID != "" {
+			s.Containers = append(s.Containers, c)
+		}
+	})
+
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshaling containers: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadState returns the containers saved in the file, or none if it doesn't
+// exist.
+func loadState(path string) ([]*Container, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var s state
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return s.Containers, nil
+}
+
+// isStillRunning tells if the saved container still runs: its process exists
+// and is in the same mount namespace, so its PID wasn't reused by another
+// process.
+func isStillRunning(container *Container) bool {
+	if container.Pid == 0 || container.Mntns == 0 {
+		return false
+	}
+	mntns, err := containerutils.GetMntNs(int(container.Pid))
+	if err != nil {
+		return false
+	}
+	return mntns == container.Mntns
+}
+
+// WithPersistentState saves the containers of the collection to the given
+// file while it runs and when it's closed, and reloads them on Initialize().
+// This way, the containers detected before a restart keep being tracked and
+// their events enriched even if the runtime hooks missed them.
+//
+// The saved containers are reconciled with the ones the runtime reports: the
+// runtime ones take precedence, and the saved containers whose process isn't
+// running anymore are discarded. For this reason, this option must be passed
+// after the ones providing the initial containers.
+//
+// ContainerCollection.Initialize(WithPersistentState(path))
+func WithPersistentState(path string) ContainerCollectionOption {
+	return func(cc *ContainerCollection) error {
+		saved, err := loadState(path)
+		if err != nil {
+			// A corrupted state must not prevent the collection to start
+			log.Warnf("persistent state: ignoring saved containers: %s", err)
+		}
+
+		known := make(map[string]struct{}, len(cc.initialContainers))
+		for _, c := range cc.initialContainers {
+			known[c.Runtime.ContainerID] = struct{}{}
+		}
+		restored := 0
+		for _, c := range saved {
+			if _, ok := known[c.Runtime.ContainerID]; ok {
+				continue
+			}
+			if !isStillRunning(c) {
+				log.Debugf("persistent state: discarding container %s: not running anymore", c.Runtime.ContainerID)
+				continue
+			}
+			cc.initialContainers = append(cc.initialContainers, c)
+			restored++
+		}
+		if restored > 0 {
+			log.Infof("persistent state: restored %d containers from %s", restored, path)
+		}
+
+		var dirty atomic.Bool
+		// Keep the periodic and the final saves from writing at the same
+		// time
+		var saveMu sync.Mutex
+		save := func() {
+			saveMu.Lock()
+			defer saveMu.Unlock()
+			if err := cc.saveState(path); err != nil {
+				log.Warnf("persistent state: saving containers: %s", err)
+			}
+		}
+
+		if cc.pubsub == nil {
+			cc.pubsub = NewGadgetPubSub()
+		}
+		cc.pubsub.Subscribe("persistentstate", func(PubSubEvent) {
+			dirty.Store(true)
+		}, nil)
+
+		// Save at most once per interval, after the changes are done:
+		// removed containers are only deleted once their event is published.
+		go func() {
+			ticker := time.NewTicker(stateSaveInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					if dirty.Swap(false) {
+						save()
+					}
+				case <-cc.done:
+					return
+				}
+			}
+		}()
+
+		// The containers are still in the collection when the clean up
+		// functions are called
+		cc.cleanUpFuncs = append(cc.cleanUpFuncs, save)
+
+		return nil
+	}
+}
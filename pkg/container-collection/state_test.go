@@ -0,0 +1,96 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func newStateContainer(id string, pid uint32, mntns uint64) *Container {
+	return &Container{
+		Runtime: RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				ContainerID: id,
+			},
+		},
+		K8s: K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{
+				Namespace: "default",
+				PodName:   "pod-" + id,
+			},
+		},
+		Pid:   pid,
+		Mntns: mntns,
+	}
+}
+
+func TestPersistentState(t *testing.T) {
+	mntns, err := containerutils.GetMntNs(os.Getpid())
+	if err != nil {
+		t.Skipf("getting mount namespace: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "containers.json")
+
+	// Save the containers of a first collection on Close()
+	cc := &ContainerCollection{}
+	require.NoError(t, cc.Initialize(WithPersistentState(path)))
+	cc.AddContainer(newStateContainer("running", uint32(os.Getpid()), mntns))
+	cc.AddContainer(newStateContainer("reused-pid", uint32(os.Getpid()), mntns+1))
+	cc.AddContainer(newStateContainer("exited", 0, mntns))
+	cc.AddContainer(newStateContainer("runtime", uint32(os.Getpid()), mntns))
+	cc.Close()
+
+	saved, err := loadState(path)
+	require.NoError(t, err)
+	require.Len(t, saved, 4)
+
+	// The runtime reports one of the containers again
+	fromRuntime := newStateContainer("runtime", uint32(os.Getpid()), mntns)
+	fromRuntime.K8s.PodName = "from-runtime"
+	withRuntime := func(cc *ContainerCollection) error {
+		cc.initialContainers = append(cc.initialContainers, fromRuntime)
+		return nil
+	}
+
+	cc = &ContainerCollection{}
+	require.NoError(t, cc.Initialize(withRuntime, WithPersistentState(path)))
+	defer cc.Close()
+
+	require.Equal(t, 2, cc.ContainerLen())
+	require.NotNil(t, cc.GetContainer("running"))
+	require.Equal(t, "pod-running", cc.GetContainer("running").K8s.PodName)
+	require.Equal(t, "from-runtime", cc.GetContainer("runtime").K8s.PodName)
+}
+
+func TestPersistentStateCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "containers.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	cc := &ContainerCollection{}
+	require.NoError(t, cc.Initialize(WithPersistentState(path)))
+	require.Equal(t, 0, cc.ContainerLen())
+	cc.Close()
+
+	saved, err := loadState(path)
+	require.NoError(t, err)
+	require.Empty(t, saved)
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutils provides a ContainerCollection whose containers are added
+// and removed by the test, to unit test the code using Inspektor Gadget's
+// enrichment without a container runtime. The containers are made up: their
+// namespaces don't exist and they don't have processes, unless given.
+package testutils
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// firstFakeNs is the first namespace inode given to the fake containers. It's
+// above the 32 bits inode numbers used by the kernel so the fake namespaces
+// never match real ones.
+const firstFakeNs = 1 << 32
+
+var lastID atomic.Uint64
+
+// ContainerOption are options to pass to NewContainer using the functional
+// option code pattern.
+type ContainerOption func(*containercollection.Container)
+
+// WithK8s sets the Kubernetes metadata of the container. By default, the
+// container isn't part of a pod.
+func WithK8s(namespace, podName, containerName string) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.K8s.Namespace = namespace
+		c.K8s.PodName = podName
+		c.K8s.ContainerName = containerName
+	}
+}
+
+// WithPodLabels sets the labels of the pod of the container.
+func WithPodLabels(labels map[string]string) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.K8s.PodLabels = labels
+	}
+}
+
+// WithRuntime sets the container runtime of the container, docker by default.
+func WithRuntime(runtimeName types.RuntimeName) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.Runtime.RuntimeName = runtimeName
+	}
+}
+
+// WithLabels sets the runtime labels of the container.
+func WithLabels(labels map[string]string) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.Runtime.Labels = labels
+	}
+}
+
+// WithContainerID sets the ID of the container instead of generating one.
+func WithContainerID(id string) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.Runtime.ContainerID = id
+	}
+}
+
+// WithPid sets the PID of the container, e.g. the one of a process started by
+// the test.
+func WithPid(pid uint32) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.Pid = pid
+	}
+}
+
+// WithMntns sets the mount namespace of the container instead of generating
+// one.
+func WithMntns(mntns uint64) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.Mntns = mntns
+	}
+}
+
+// WithNetns sets the network namespace of the container instead of
+// generating one, e.g. to share the one of another container of the pod.
+func WithNetns(netns uint64) ContainerOption {
+	return func(c *containercollection.Container) {
+		c.Netns = netns
+	}
+}
+
+// NewContainer returns a container with the given name and options. Its ID
+// and namespaces are generated, unique to the process, if they aren't given.
+func NewContainer(name string, options ...ContainerOption) *containercollection.Container {
+	id := lastID.Add(1)
+	c := &containercollection.Container{
+		Runtime: containercollection.RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				RuntimeName:   types.RuntimeNameDocker,
+				ContainerName: name,
+				ContainerID:   fmt.Sprintf("%064x", id),
+			},
+		},
+		Mntns: firstFakeNs + 2*id,
+		Netns: firstFakeNs + 2*id + 1,
+	}
+	for _, o := range options {
+		o(c)
+	}
+	return c
+}
+
+// ContainerCollection is a containercollection.ContainerCollection whose
+// containers are added and removed by the test. It records the notifications
+// of its subscribers.
+type ContainerCollection struct {
+	containercollection.ContainerCollection
+
+	t testing.TB
+
+	mu     sync.Mutex
+	events []containercollection.PubSubEvent
+}
+
+// NewContainerCollection returns an initialized ContainerCollection, with
+// pubsub enabled and the given options, e.g. WithContainerRuntimeClient() with
+// a fake runtime client. It's closed at the end of the test.
+func NewContainerCollection(t testing.TB, options ...containercollection.ContainerCollectionOption) *ContainerCollection {
+	t.Helper()
+
+	cc := &ContainerCollection{t: t}
+	opts := append([]containercollection.ContainerCollectionOption{
+		containercollection.WithPubSub(cc.record),
+	}, options...)
+	require.NoError(t, cc.Initialize(opts...), "initializing container collection")
+	t.Cleanup(cc.Close)
+
+	return cc
+}
+
+func (cc *ContainerCollection) record(event containercollection.PubSubEvent) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.events = append(cc.events, event)
+}
+
+// Add creates a container with NewContainer() and adds it to the collection.
+// It returns the container, as changed by the enrichers of the collection,
+// and fails the test if an enricher dropped it.
+func (cc *ContainerCollection) Add(name string, options ...ContainerOption) *containercollection.Container {
+	cc.t.Helper()
+
+	c := NewContainer(name, options...)
+	cc.AddContainer(c)
+	require.NotNil(cc.t, cc.GetContainer(c.Runtime.ContainerID), "container %q dropped by the enrichers", name)
+	return c
+}
+
+// Remove removes the container from the collection.
+func (cc *ContainerCollection) Remove(c *containercollection.Container) {
+	cc.RemoveContainer(c.Runtime.ContainerID)
+}
+
+// Events returns the notifications sent to the subscribers so far, in order.
+func (cc *ContainerCollection) Events() []containercollection.PubSubEvent {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	return append([]containercollection.PubSubEvent{}, cc.events...)
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
+	containerutilstestutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/testutils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestContainerCollection(t *testing.T) {
+	cc := NewContainerCollection(t)
+
+	c1 := cc.Add("c1", WithK8s("ns", "pod", "c1"))
+	c2 := cc.Add("c2", WithK8s("ns", "pod", "c2"), WithNetns(c1.Netns))
+	require.NotEqual(t, c1.Runtime.ContainerID, c2.Runtime.ContainerID)
+	require.NotEqual(t, c1.Mntns, c2.Mntns)
+
+	require.Equal(t, c2, cc.LookupContainerByMntns(c2.Mntns))
+	require.ElementsMatch(t, []*containercollection.Container{c1, c2}, cc.LookupContainersByNetns(c1.Netns))
+	require.Equal(t, map[string]uint64{"c1": c1.Mntns, "c2": c2.Mntns}, cc.LookupMntnsByPod("ns", "pod"))
+
+	ev := types.CommonData{}
+	cc.EnrichByMntNs(&ev, c1.Mntns)
+	require.Equal(t, "pod", ev.K8s.PodName)
+	require.Equal(t, "c1", ev.Runtime.ContainerName)
+
+	cc.Remove(c1)
+	require.Nil(t, cc.LookupContainerByMntns(c1.Mntns))
+
+	events := cc.Events()
+	require.Len(t, events, 3)
+	require.Equal(t, containercollection.EventTypeAddContainer, events[0].Type)
+	require.Equal(t, containercollection.EventTypeRemoveContainer, events[2].Type)
+	require.Equal(t, c1, events[2].Container)
+}
+
+func TestContainerCollectionWithFakeRuntime(t *testing.T) {
+	runtime := containerutilstestutils.NewFakeRuntimeClient(types.RuntimeNameContainerd)
+	runtime.AddContainer(&runtimeclient.ContainerDetailsData{
+		ContainerData: runtimeclient.ContainerData{
+			Runtime: runtimeclient.RuntimeContainerData{
+				BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+					ContainerID:        "initial",
+					ContainerName:      "initial",
+					ContainerImageName: "docker.io/library/busybox:latest",
+				},
+			},
+		},
+		Pid: 1,
+		Env: []string{"ENV=prod", "PATH=/usr/bin"},
+	})
+	runtime.AddContainer(&runtimeclient.ContainerDetailsData{
+		ContainerData: runtimeclient.ContainerData{
+			Runtime: runtimeclient.RuntimeContainerData{
+				BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+					ContainerID:   "exited",
+					ContainerName: "exited",
+				},
+				State: runtimeclient.StateExited,
+			},
+		},
+	})
+
+	// Cleanups run in reverse order, i.e. after closing the collection
+	t.Cleanup(func() {
+		require.True(t, runtime.Closed())
+	})
+	cc := NewContainerCollection(t, containercollection.WithContainerRuntimeClient(types.RuntimeNameContainerd, runtime))
+
+	// Only the running containers are added at initialization
+	initial := cc.GetContainer("initial")
+	require.NotNil(t, initial)
+	require.Equal(t, types.RuntimeNameContainerd, initial.Runtime.RuntimeName)
+	require.Equal(t, "docker.io/library/busybox:latest", initial.Runtime.ContainerImageName)
+	require.Nil(t, cc.GetContainer("exited"))
+
+	// The environment of the initial containers comes from the runtime
+	env, err := containercollection.ParseKeyValueSelector("ENV=prod")
+	require.NoError(t, err)
+	selector := &containercollection.ContainerSelector{
+		Runtime: containercollection.RuntimeSelector{Env: env},
+	}
+	require.True(t, containercollection.ContainerSelectorMatches(selector, initial))
+
+	// The new containers are enriched with the runtime
+	runtime.AddContainer(&runtimeclient.ContainerDetailsData{
+		ContainerData: runtimeclient.ContainerData{
+			Runtime: runtimeclient.RuntimeContainerData{
+				BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+					ContainerID:        "new",
+					ContainerName:      "new",
+					ContainerImageName: "docker.io/library/nginx:latest",
+				},
+			},
+		},
+	})
+	c := cc.Add("", WithContainerID("new"))
+	require.Equal(t, "new", c.Runtime.ContainerName)
+	require.Equal(t, "docker.io/library/nginx:latest", c.Runtime.ContainerImageName)
+
+	// The containers aren't dropped when the runtime is unavailable
+	runtime.SetError(errors.New("unavailable"))
+	c = cc.Add("other", WithContainerID("other"))
+	require.Empty(t, c.Runtime.ContainerImageName)
+}
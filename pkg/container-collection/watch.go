@@ -0,0 +1,224 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoPubSub is returned by Watch() when the collection wasn't initialized
+// with WithPubSub() or another option enabling the notifications.
+var ErrNoPubSub = errors.New("container collection has no pubsub: use WithPubSub()")
+
+// WatchOptions are the options of Watch()
+type WatchOptions struct {
+	// Selector selects the containers to watch, e.g. by namespace, labels
+	// or runtime. All the containers are watched if it's empty.
+	Selector ContainerSelector
+
+	// ResyncPeriod is how often the watched containers are reconciled with
+	// the ones of the collection and passed to OnResync. 0 disables it.
+	ResyncPeriod time.Duration
+}
+
+// ContainerEventHandler are the callbacks of Watch(). All of them are
+// optional. They are never called concurrently and, for a given container,
+// OnAdd is always called before OnRemove.
+type ContainerEventHandler struct {
+	// OnAdd is called for each container matching the selector. initial
+	// is true for the containers already in the collection when Watch() is
+	// called.
+	OnAdd func(container *Container, initial bool)
+
+	// OnRemove is called for each container passed to OnAdd when it's
+	// removed from the collection.
+	OnRemove func(container *Container)
+
+	// OnSynced is called once all the initial containers were passed to
+	// OnAdd, before any other event.
+	OnSynced func()
+
+	// OnResync is called every WatchOptions.ResyncPeriod with the watched
+	// containers, after calling OnAdd and OnRemove for the ones whose events
+	// were missed, if any.
+	OnResync func(containers []*Container)
+}
+
+// Watcher is a watch started by Watch()
+type Watcher struct {
+	cc       *ContainerCollection
+	selector ContainerSelector
+	handler  ContainerEventHandler
+
+	// mu serializes the calls to the handler
+	mu sync.Mutex
+	// containers are the containers passed to OnAdd and not yet to
+	// OnRemove, by ID
+	containers map[string]*Container
+	// removed are the IDs of the containers removed since the last
+	// resync. They can still be in the collection for a short time as
+	// they're deleted after their event is published.
+	removed map[string]struct{}
+	stopped bool
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Watch calls the handler with the containers of the collection matching the
+// selector of the options and with the ones added and removed until Stop() is
+// called on the returned Watcher. It's meant for the programs using the
+// collection as a library to react to the container lifecycle.
+//
+// The current containers are passed to OnAdd and OnSynced is called before
+// Watch() returns. The events are then delivered synchronously: the container
+// isn't added to the collection until OnAdd returns.
+func (cc *ContainerCollection) Watch(opts WatchOptions, handler ContainerEventHandler) (*Watcher, error) {
+	if cc.pubsub == nil {
+		return nil, ErrNoPubSub
+	}
+
+	w := &Watcher{
+		cc:         cc,
+		selector:   opts.Selector,
+		handler:    handler,
+		containers: make(map[string]*Container),
+		removed:    make(map[string]struct{}),
+		done:       make(chan struct{}),
+	}
+
+	// Hold the lock until the initial containers are delivered so that
+	// the events published in the meantime wait for it.
+	w.mu.Lock()
+	initial := cc.Subscribe(w, w.selector, w.notify)
+	for _, c := range initial {
+		w.add(c, true)
+	}
+	if handler.OnSynced != nil {
+		handler.OnSynced()
+	}
+	w.mu.Unlock()
+
+	if opts.ResyncPeriod > 0 {
+		go w.resyncLoop(opts.ResyncPeriod)
+	}
+
+	return w, nil
+}
+
+func (w *Watcher) notify(event PubSubEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return
+	}
+
+	switch event.Type {
+	case EventTypeAddContainer:
+		delete(w.removed, event.Container.Runtime.ContainerID)
+		w.add(event.Container, false)
+	case EventTypeRemoveContainer:
+		w.removed[event.Container.Runtime.ContainerID] = struct{}{}
+		w.remove(event.Container)
+	}
+}
+
+// add passes the container to OnAdd unless it was already, e.g. when it's
+// both in the initial list and in an event published at the same time.
+func (w *Watcher) add(c *Container, initial bool) {
+	if _, ok := w.containers[c.Runtime.ContainerID]; ok {
+		return
+	}
+	w.containers[c.Runtime.ContainerID] = c
+	if w.handler.OnAdd != nil {
+		w.handler.OnAdd(c, initial)
+	}
+}
+
+func (w *Watcher) remove(c *Container) {
+	if _, ok := w.containers[c.Runtime.ContainerID]; !ok {
+		return
+	}
+	delete(w.containers, c.Runtime.ContainerID)
+	if w.handler.OnRemove != nil {
+		w.handler.OnRemove(c)
+	}
+}
+
+// resync reconciles the watched containers with the ones of the collection.
+func (w *Watcher) resync() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return
+	}
+
+	current := []*Container{}
+	ids := map[string]struct{}{}
+	for _, c := range w.cc.GetContainersBySelector(&w.selector) {
+		if _, ok := w.removed[c.Runtime.ContainerID]; ok {
+			continue
+		}
+		current = append(current, c)
+		ids[c.Runtime.ContainerID] = struct{}{}
+	}
+	w.removed = make(map[string]struct{})
+
+	for id, c := range w.containers {
+		if _, ok := ids[id]; !ok {
+			w.remove(c)
+		}
+	}
+	for _, c := range current {
+		w.add(c, false)
+	}
+
+	if w.handler.OnResync != nil {
+		w.handler.OnResync(current)
+	}
+}
+
+func (w *Watcher) resyncLoop(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.resync()
+		case <-w.done:
+			return
+		case <-w.cc.done:
+			return
+		}
+	}
+}
+
+// Stop stops the watch. The handler isn't called anymore once it returns, so
+// it must not be called from the handler.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		w.cc.Unsubscribe(w)
+		close(w.done)
+
+		w.mu.Lock()
+		w.stopped = true
+		w.mu.Unlock()
+	})
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func newWatchContainer(id, namespace string, mntns uint64) *Container {
+	return &Container{
+		Runtime: RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				ContainerID: id,
+				RuntimeName: types.RuntimeNameContainerd,
+			},
+		},
+		K8s: K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{
+				Namespace: namespace,
+			},
+		},
+		Mntns: mntns,
+	}
+}
+
+func TestWatch(t *testing.T) {
+	cc := &ContainerCollection{}
+	_, err := cc.Watch(WatchOptions{}, ContainerEventHandler{})
+	require.ErrorIs(t, err, ErrNoPubSub)
+
+	require.NoError(t, cc.Initialize(WithPubSub()))
+	defer cc.Close()
+
+	cc.AddContainer(newWatchContainer("initial", "ns1", 1))
+	cc.AddContainer(newWatchContainer("other-namespace", "ns2", 2))
+
+	events := []string{}
+	handler := ContainerEventHandler{
+		OnAdd: func(c *Container, initial bool) {
+			if initial {
+				events = append(events, "initial "+c.Runtime.ContainerID)
+			} else {
+				events = append(events, "add "+c.Runtime.ContainerID)
+			}
+		},
+		OnRemove: func(c *Container) {
+			events = append(events, "remove "+c.Runtime.ContainerID)
+		},
+		OnSynced: func() {
+			events = append(events, "synced")
+		},
+	}
+	opts := WatchOptions{
+		Selector: ContainerSelector{
+			K8s: K8sSelector{
+				BasicK8sMetadata: types.BasicK8sMetadata{Namespace: "ns1"},
+			},
+			Runtime: RuntimeSelector{
+				RuntimeNames: []types.RuntimeName{types.RuntimeNameContainerd},
+			},
+		},
+	}
+	w, err := cc.Watch(opts, handler)
+	require.NoError(t, err)
+
+	cc.AddContainer(newWatchContainer("new", "ns1", 3))
+	cc.AddContainer(newWatchContainer("new-other-namespace", "ns2", 4))
+	cc.RemoveContainer("initial")
+	w.Stop()
+	cc.RemoveContainer("new")
+
+	require.Equal(t, []string{"initial initial", "synced", "add new", "remove initial"}, events)
+}
+
+func TestWatchResync(t *testing.T) {
+	cc := &ContainerCollection{}
+	require.NoError(t, cc.Initialize(WithPubSub()))
+	defer cc.Close()
+
+	added := make(chan string, 10)
+	removed := make(chan string, 10)
+	resynced := make(chan []*Container, 10)
+	handler := ContainerEventHandler{
+		OnAdd: func(c *Container, initial bool) {
+			added <- c.Runtime.ContainerID
+		},
+		OnRemove: func(c *Container) {
+			removed <- c.Runtime.ContainerID
+		},
+		OnResync: func(containers []*Container) {
+			// Don't block the resyncs once the test is done
+			select {
+			case resynced <- containers:
+			default:
+			}
+		},
+	}
+	w, err := cc.Watch(WatchOptions{ResyncPeriod: 10 * time.Millisecond}, handler)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	// Simulate the events the watcher missed
+	missed := newWatchContainer("missed", "ns1", 5)
+	cc.containers.Store(missed.Runtime.ContainerID, missed)
+
+	select {
+	case id := <-added:
+		require.Equal(t, "missed", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missed container not added on resync")
+	}
+	// OnResync is called after OnAdd
+	for containers := range resynced {
+		if len(containers) == 1 {
+			break
+		}
+	}
+
+	cc.containers.Delete(missed.Runtime.ContainerID)
+	select {
+	case id := <-removed:
+		require.Equal(t, "missed", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missed container not removed on resync")
+	}
+}
@@ -15,7 +15,6 @@
 package cgroups
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -27,7 +26,19 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
 )
 
+// CgroupPathV2AddMountpoint returns the path of a cgroup v2 in the mounted
+// cgroup2 hierarchy, wherever it's mounted: /sys/fs/cgroup on unified systems,
+// usually /sys/fs/cgroup/unified on hybrid ones.
 func CgroupPathV2AddMountpoint(path string) (string, error) {
+	loadHierarchies()
+	if pathWithMountpoint, ok := cgroupPathV2AddMountpointFromMounts(hierarchies.mounts, path); ok {
+		if _, err := os.Stat(pathWithMountpoint); err != nil {
+			return "", fmt.Errorf("accessing cgroup %q: %w", path, err)
+		}
+		return pathWithMountpoint, nil
+	}
+
+	// The mounts couldn't be read: try the usual mountpoints
 	pathWithMountpoint := filepath.Join("/sys/fs/cgroup/unified", path)
 	if _, err := os.Stat(pathWithMountpoint); os.IsNotExist(err) {
 		pathWithMountpoint = filepath.Join("/sys/fs/cgroup", path)
@@ -53,30 +64,47 @@ func GetCgroupID(pathWithMountpoint string) (uint64, error) {
 
 // GetCgroupPaths returns the cgroup1 and cgroup2 paths of a process.
 // It does not include the "/sys/fs/cgroup/{unified,systemd,}" prefix.
+// The cgroup1 path is the one of the systemd hierarchy, whatever its ID is,
+// or of the pids or memory controllers on systems without systemd.
 func GetCgroupPaths(pid int) (string, string, error) {
-	cgroupPathV1 := ""
+	cgroupFile, err := os.Open(filepath.Join(host.HostProcFs, fmt.Sprint(pid), "cgroup"))
+	if err != nil {
+		return "", "", fmt.Errorf("parsing cgroup: %w", err)
+	}
+	defer cgroupFile.Close()
+
+	entries, err := parseCgroupFile(cgroupFile)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing cgroup: %w", err)
+	}
+
+	cgroupPathV1, cgroupPathV2 := cgroupPathsFromEntries(entries)
+	if cgroupPathV2 == "" && cgroupPathV1 == "" {
+		return "", "", fmt.Errorf("cgroup path not found in /proc/PID/cgroup")
+	}
+
+	return cgroupPathV1, cgroupPathV2, nil
+}
+
+func cgroupPathsFromEntries(entries []Entry) (string, string) {
 	cgroupPathV2 := ""
-	if cgroupFile, err := os.Open(filepath.Join(host.HostProcFs, fmt.Sprint(pid), "cgroup")); err == nil {
-		defer cgroupFile.Close()
-		reader := bufio.NewReader(cgroupFile)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				break
-			}
-			if strings.HasPrefix(line, "1:name=systemd:") {
-				cgroupPathV1 = strings.TrimPrefix(line, "1:name=systemd:")
-				cgroupPathV1 = strings.TrimSuffix(cgroupPathV1, "\n")
-				continue
-			}
-			if strings.HasPrefix(line, "0::") {
-				cgroupPathV2 = strings.TrimPrefix(line, "0::")
-				cgroupPathV2 = strings.TrimSuffix(cgroupPathV2, "\n")
-				continue
-			}
+	v1Paths := map[string]string{}
+	for _, entry := range entries {
+		if entry.ID == 0 && len(entry.Controllers) == 0 {
+			cgroupPathV2 = entry.Path
+			continue
+		}
+		for _, c := range entry.Controllers {
+			v1Paths[c] = entry.Path
+		}
+	}
+
+	cgroupPathV1 := ""
+	for _, c := range []string{"name=systemd", "pids", "memory"} {
+		if path, ok := v1Paths[c]; ok {
+			cgroupPathV1 = path
+			break
 		}
-	} else {
-		return "", "", fmt.Errorf("parsing cgroup: %w", err)
 	}
 
 	if cgroupPathV1 == "/" {
@@ -87,9 +115,37 @@ func GetCgroupPaths(pid int) (string, string, error) {
 		cgroupPathV2 = ""
 	}
 
-	if cgroupPathV2 == "" && cgroupPathV1 == "" {
-		return "", "", fmt.Errorf("cgroup path not found in /proc/PID/cgroup")
+	return cgroupPathV1, cgroupPathV2
+}
+
+// SystemdUnit returns the systemd unit and slice a cgroup path belongs to,
+// e.g. "sshd.service" and "system.slice" for "/system.slice/sshd.service".
+// The innermost ones are returned for nested units like user services.
+func SystemdUnit(cgroupPath string) (unit string, slice string) {
+	for _, part := range strings.Split(cgroupPath, "/") {
+		switch {
+		case strings.HasSuffix(part, ".slice"):
+			slice = part
+			unit = ""
+		case strings.HasSuffix(part, ".service"), strings.HasSuffix(part, ".scope"):
+			unit = part
+		}
 	}
+	return unit, slice
+}
 
-	return cgroupPathV1, cgroupPathV2, nil
+// GetSystemdUnit returns the systemd unit and slice of a process.
+func GetSystemdUnit(pid int) (string, string, error) {
+	cgroupPathV1, cgroupPathV2, err := GetCgroupPaths(pid)
+	if err != nil {
+		return "", "", err
+	}
+
+	cgroupPath := cgroupPathV2
+	if cgroupPath == "" {
+		cgroupPath = cgroupPathV1
+	}
+
+	unit, slice := SystemdUnit(cgroupPath)
+	return unit, slice, nil
 }
@@ -0,0 +1,179 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroups
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	table := []struct {
+		description string
+		cgroupPath  string
+		unit        string
+		slice       string
+	}{
+		{
+			description: "Empty path",
+		},
+		{
+			description: "System service",
+			cgroupPath:  "/system.slice/sshd.service",
+			unit:        "sshd.service",
+			slice:       "system.slice",
+		},
+		{
+			description: "Init scope",
+			cgroupPath:  "/init.scope",
+			unit:        "init.scope",
+		},
+		{
+			description: "User session",
+			cgroupPath:  "/user.slice/user-1000.slice/session-3.scope",
+			unit:        "session-3.scope",
+			slice:       "user-1000.slice",
+		},
+		{
+			description: "User service",
+			cgroupPath:  "/user.slice/user-1000.slice/user@1000.service/app.slice/foo.service",
+			unit:        "foo.service",
+			slice:       "app.slice",
+		},
+		{
+			description: "Slice without unit",
+			cgroupPath:  "/kubepods.slice/kubepods-besteffort.slice",
+			slice:       "kubepods-besteffort.slice",
+		},
+		{
+			description: "Sub-cgroup of a service",
+			cgroupPath:  "/system.slice/containerd.service/payload",
+			unit:        "containerd.service",
+			slice:       "system.slice",
+		},
+	}
+
+	for _, entry := range table {
+		unit, slice := SystemdUnit(entry.cgroupPath)
+		if unit != entry.unit || slice != entry.slice {
+			t.Fatalf("Failed test %q: got unit %q slice %q, expected unit %q slice %q",
+				entry.description, unit, slice, entry.unit, entry.slice)
+		}
+	}
+}
+
+const hybridMountInfo = `24 30 0:22 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+25 24 0:23 / /sys/fs/cgroup ro,nosuid,nodev,noexec shared:8 - tmpfs tmpfs ro,mode=755
+26 25 0:24 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate
+27 25 0:25 / /sys/fs/cgroup/systemd rw,nosuid,nodev,noexec,relatime shared:10 - cgroup cgroup rw,xattr,name=systemd
+28 25 0:26 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:11 - cgroup cgroup rw,cpu,cpuacct
+29 25 0:27 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:12 - cgroup cgroup rw,memory
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(hybridMountInfo))
+	if err != nil {
+		t.Fatalf("parsing mountinfo: %s", err)
+	}
+
+	expected := []Mount{
+		{Version: 2, Mountpoint: "/sys/fs/cgroup/unified", Root: "/"},
+		{Version: 1, Mountpoint: "/sys/fs/cgroup/systemd", Root: "/", Controllers: []string{"name=systemd"}},
+		{Version: 1, Mountpoint: "/sys/fs/cgroup/cpu,cpuacct", Root: "/", Controllers: []string{"cpu", "cpuacct"}},
+		{Version: 1, Mountpoint: "/sys/fs/cgroup/memory", Root: "/", Controllers: []string{"memory"}},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("got %+v, expected %+v", mounts, expected)
+	}
+
+	if mode := detectMode(mounts); mode != ModeHybrid {
+		t.Fatalf("got mode %s, expected %s", mode, ModeHybrid)
+	}
+	if mode := detectMode(mounts[:1]); mode != ModeUnified {
+		t.Fatalf("got mode %s, expected %s", mode, ModeUnified)
+	}
+	if mode := detectMode(mounts[1:]); mode != ModeLegacy {
+		t.Fatalf("got mode %s, expected %s", mode, ModeLegacy)
+	}
+	if mode := detectMode(nil); mode != ModeUnknown {
+		t.Fatalf("got mode %s, expected %s", mode, ModeUnknown)
+	}
+}
+
+func TestCgroupPathsFromEntries(t *testing.T) {
+	table := []struct {
+		description string
+		content     string
+		v1          string
+		v2          string
+	}{
+		{
+			description: "Unified",
+			content:     "0::/system.slice/docker-abc.scope\n",
+			v2:          "/system.slice/docker-abc.scope",
+		},
+		{
+			description: "Hybrid",
+			content: "12:memory:/system.slice/docker-abc.scope\n" +
+				"1:name=systemd:/system.slice/docker-abc.scope\n" +
+				"0::/system.slice/docker-abc.scope\n",
+			v1: "/system.slice/docker-abc.scope",
+			v2: "/system.slice/docker-abc.scope",
+		},
+		{
+			description: "Hybrid with systemd hierarchy not being the first one",
+			content: "3:memory:/docker/abc\n" +
+				"2:name=systemd:/docker/abc\n" +
+				"1:cpu,cpuacct:/docker/abc\n" +
+				"0::/\n",
+			v1: "/docker/abc",
+		},
+		{
+			description: "Legacy without systemd",
+			content: "2:memory:/docker/abc\n" +
+				"1:pids:/docker/abc\n",
+			v1: "/docker/abc",
+		},
+	}
+
+	for _, entry := range table {
+		entries, err := parseCgroupFile(strings.NewReader(entry.content))
+		if err != nil {
+			t.Fatalf("Failed test %q: %s", entry.description, err)
+		}
+		v1, v2 := cgroupPathsFromEntries(entries)
+		if v1 != entry.v1 || v2 != entry.v2 {
+			t.Fatalf("Failed test %q: got v1 %q v2 %q, expected v1 %q v2 %q",
+				entry.description, v1, v2, entry.v1, entry.v2)
+		}
+	}
+}
+
+func TestAddMountpoint(t *testing.T) {
+	mounts := []Mount{
+		{Version: 1, Mountpoint: "/sys/fs/cgroup/memory", Root: "/", Controllers: []string{"memory"}},
+		{Version: 2, Mountpoint: "/sys/fs/cgroup", Root: "/kubepods.slice/pod1"},
+	}
+
+	path, ok := cgroupPathV2AddMountpointFromMounts(mounts, "/kubepods.slice/pod1/container1")
+	if !ok || path != "/sys/fs/cgroup/container1" {
+		t.Fatalf("got %q (%v), expected %q", path, ok, "/sys/fs/cgroup/container1")
+	}
+
+	if path, ok := cgroupPathV2AddMountpointFromMounts(mounts, "/system.slice/sshd.service"); ok {
+		t.Fatalf("cgroup outside of the mounted part of the hierarchy shouldn't be found: %q", path)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Mode is the layout of the cgroup hierarchies of the system.
+type Mode int
+
+const (
+	ModeUnknown Mode = iota
+	// ModeLegacy only has cgroup v1 hierarchies.
+	ModeLegacy
+	// ModeHybrid has cgroup v1 hierarchies for the controllers and the
+	// cgroup v2 one, usually on /sys/fs/cgroup/unified.
+	ModeHybrid
+	// ModeUnified only has the cgroup v2 hierarchy.
+	ModeUnified
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeLegacy:
+		return "legacy"
+	case ModeHybrid:
+		return "hybrid"
+	case ModeUnified:
+		return "unified"
+	default:
+		return "unknown"
+	}
+}
+
+// Mount is a mounted cgroup hierarchy.
+type Mount struct {
+	// Version is 1 or 2.
+	Version int
+	// Mountpoint is where the hierarchy is mounted.
+	Mountpoint string
+	// Root is the cgroup of the hierarchy mounted on Mountpoint, "/" unless
+	// only part of the hierarchy is visible, e.g. in a container.
+	Root string
+	// Controllers are the controllers of a cgroup v1 hierarchy, including
+	// named ones like "name=systemd".
+	Controllers []string
+}
+
+// Entry is an entry of /proc/<pid>/cgroup.
+type Entry struct {
+	ID          int
+	Controllers []string
+	Path        string
+}
+
+// parseMountInfo returns the cgroup hierarchies from a mountinfo file.
+func parseMountInfo(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// 30 23 0:26 / /sys/fs/cgroup/systemd rw,nosuid shared:9 - cgroup cgroup rw,xattr,name=systemd
+		pre, post, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		preFields := strings.Fields(pre)
+		postFields := strings.Fields(post)
+		if len(preFields) < 5 || len(postFields) < 3 {
+			continue
+		}
+
+		m := Mount{
+			Root:       preFields[3],
+			Mountpoint: preFields[4],
+		}
+		switch postFields[0] {
+		case "cgroup2":
+			m.Version = 2
+		case "cgroup":
+			m.Version = 1
+			for _, opt := range strings.Split(postFields[2], ",") {
+				switch {
+				case opt == "rw", opt == "ro", opt == "xattr", opt == "clone_children",
+					strings.HasPrefix(opt, "release_agent="):
+				default:
+					m.Controllers = append(m.Controllers, opt)
+				}
+			}
+		default:
+			continue
+		}
+		mounts = append(mounts, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
+// parseCgroupFile returns the entries of a /proc/<pid>/cgroup file.
+func parseCgroupFile(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		id, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+
+		entry := Entry{
+			ID:   id,
+			Path: parts[2],
+		}
+		if parts[1] != "" {
+			entry.Controllers = strings.Split(parts[1], ",")
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func detectMode(mounts []Mount) Mode {
+	v1, v2 := false, false
+	for _, m := range mounts {
+		switch m.Version {
+		case 1:
+			v1 = true
+		case 2:
+			v2 = true
+		}
+	}
+
+	switch {
+	case v1 && v2:
+		return ModeHybrid
+	case v2:
+		return ModeUnified
+	case v1:
+		return ModeLegacy
+	default:
+		return ModeUnknown
+	}
+}
+
+var hierarchies struct {
+	once   sync.Once
+	mode   Mode
+	mounts []Mount
+}
+
+func loadHierarchies() {
+	hierarchies.once.Do(func() {
+		file, err := os.Open("/proc/self/mountinfo")
+		if err != nil {
+			return
+		}
+		defer file.Close()
+
+		mounts, err := parseMountInfo(file)
+		if err != nil {
+			return
+		}
+		hierarchies.mounts = mounts
+		hierarchies.mode = detectMode(mounts)
+	})
+}
+
+// GetMode returns the layout of the cgroup hierarchies mounted on the system.
+func GetMode() Mode {
+	loadHierarchies()
+	return hierarchies.mode
+}
+
+// addMountpoint returns the path of a cgroup in the first mount of the
+// mounts matching the filter, taking into account the part of the hierarchy
+// that is mounted.
+func addMountpoint(mounts []Mount, path string, match func(m *Mount) bool) (string, bool) {
+	for i := range mounts {
+		m := &mounts[i]
+		if !match(m) {
+			continue
+		}
+		if m.Root == "/" {
+			return filepath.Join(m.Mountpoint, path), true
+		}
+		if path == m.Root || strings.HasPrefix(path, m.Root+"/") {
+			return filepath.Join(m.Mountpoint, strings.TrimPrefix(path, m.Root)), true
+		}
+	}
+	return "", false
+}
+
+// cgroupPathV2AddMountpointFromMounts returns the path of a cgroup v2 in the
+// cgroup2 hierarchy found in mounts.
+func cgroupPathV2AddMountpointFromMounts(mounts []Mount, path string) (string, bool) {
+	return addMountpoint(mounts, path, func(m *Mount) bool {
+		return m.Version == 2
+	})
+}
+
+// CgroupPathV1AddMountpoint returns the path of a cgroup v1 in the hierarchy
+// of the given controller, e.g. "memory" or "name=systemd".
+func CgroupPathV1AddMountpoint(controller, path string) (string, error) {
+	loadHierarchies()
+
+	pathWithMountpoint, ok := addMountpoint(hierarchies.mounts, path, func(m *Mount) bool {
+		if m.Version != 1 {
+			return false
+		}
+		for _, c := range m.Controllers {
+			if c == controller {
+				return true
+			}
+		}
+		return false
+	})
+	if !ok {
+		return "", fmt.Errorf("cgroup v1 hierarchy of %q not mounted", controller)
+	}
+	return pathWithMountpoint, nil
+}
@@ -17,6 +17,7 @@ package containerd
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/containerd/containerd"
@@ -27,6 +28,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cgroups"
 	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
 	containerutilsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
@@ -38,19 +40,48 @@ const (
 	LabelK8sContainerName         = "io.kubernetes.container.name"
 	LabelK8sContainerdKind        = "io.cri-containerd.kind"
 	LabelK8sContainerdKindSandbox = "sandbox"
+	LabelNerdctlName              = "nerdctl/name"
+
+	// AllNamespaces can be used as namespace to look for the containers in
+	// all the containerd namespaces, also the ones created later.
+	AllNamespaces = "*"
 )
 
 var log = logrus.WithField("container-client", "containerd")
 
 type ContainerdClient struct {
 	client *containerd.Client
-	ctx    context.Context
+	// namespaces contains the containerd namespaces where the containers
+	// are looked for. All the namespaces are used when it's nil.
+	namespaces []string
 }
 
-func NewContainerdClient(socketPath string, config *containerutilsTypes.ExtraConfig) (runtimeclient.ContainerRuntimeClient, error) {
-	if socketPath == "" {
-		socketPath = runtimeclient.ContainerdDefaultSocketPath
+// parseNamespaces parses a comma-separated list of containerd namespaces. It
+// returns nil if all namespaces have to be used.
+func parseNamespaces(namespace string) []string {
+	ret := []string{}
+	for _, ns := range strings.Split(namespace, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == AllNamespaces {
+			return nil
+		}
+		if ns != "" {
+			ret = append(ret, ns)
+		}
+	}
+
+	if len(ret) == 0 {
+		return []string{constants.K8sContainerdNamespace}
 	}
+	return ret
+}
+
+// NewContainerdClient creates a client for containerd. config.Namespace is a
+// comma-separated list of the containerd namespaces to use, "*" meaning all
+// of them. It defaults to k8s.io.
+func NewContainerdClient(socketPath string, config *containerutilsTypes.ExtraConfig) (runtimeclient.ContainerRuntimeClient, error) {
+	socketPath = runtimeclient.ResolveSocketPath(socketPath, runtimeclient.ContainerdDefaultSocketPath,
+		runtimeclient.RootlessContainerdSocketPaths)
 	namespace := constants.K8sContainerdNamespace
 	if config != nil && config.Namespace != "" {
 		namespace = config.Namespace
@@ -72,17 +103,25 @@ func NewContainerdClient(socketPath string, config *containerutilsTypes.ExtraCon
 		return nil, err
 	}
 
-	containerdCtx := namespaces.WithNamespace(
-		context.TODO(),
-		namespace,
-	)
-
 	return &ContainerdClient{
-		client: client,
-		ctx:    containerdCtx,
+		client:     client,
+		namespaces: parseNamespaces(namespace),
 	}, nil
 }
 
+// getNamespaces returns the namespaces to look for containers in.
+func (c *ContainerdClient) getNamespaces() ([]string, error) {
+	if c.namespaces != nil {
+		return c.namespaces, nil
+	}
+
+	nss, err := c.client.NamespaceService().List(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+	return nss, nil
+}
+
 func (c *ContainerdClient) Close() error {
 	if c.client != nil {
 		return c.client.Close()
@@ -91,48 +130,57 @@ func (c *ContainerdClient) Close() error {
 }
 
 func (c *ContainerdClient) GetContainers() ([]*runtimeclient.ContainerData, error) {
-	containers, err := c.client.Containers(c.ctx)
+	nss, err := c.getNamespaces()
 	if err != nil {
-		return nil, fmt.Errorf("listing containers: %w", err)
+		return nil, err
 	}
 
-	ret := make([]*runtimeclient.ContainerData, 0, len(containers))
-	for _, container := range containers {
-		if c.isSandboxContainer(container) {
-			log.Debugf("container %q is a sandbox container. Temporary skipping it", container.ID())
-			continue
-		}
+	ret := []*runtimeclient.ContainerData{}
+	for _, ns := range nss {
+		ctx := namespaces.WithNamespace(context.TODO(), ns)
 
-		task, err := c.getContainerTask(container)
+		containers, err := c.client.Containers(ctx)
 		if err != nil {
-			log.Debugf("getting containerTask for container %q: %s", container.ID(), err)
-			continue
+			return nil, fmt.Errorf("listing containers in namespace %q: %w", ns, err)
 		}
 
-		containerData, err := c.taskAndContainerToContainerData(task, container)
-		if err != nil {
-			log.Debugf("creating containerData for container %q: %s", container.ID(), err)
-			continue
+		for _, container := range containers {
+			if c.isSandboxContainer(ctx, container) {
+				log.Debugf("container %q is a sandbox container. Temporary skipping it", container.ID())
+				continue
+			}
+
+			task, err := c.getContainerTask(ctx, container)
+			if err != nil {
+				log.Debugf("getting containerTask for container %q: %s", container.ID(), err)
+				continue
+			}
+
+			containerData, err := c.taskAndContainerToContainerData(ctx, task, container)
+			if err != nil {
+				log.Debugf("creating containerData for container %q: %s", container.ID(), err)
+				continue
+			}
+
+			ret = append(ret, containerData)
 		}
-
-		ret = append(ret, containerData)
 	}
 
 	return ret, nil
 }
 
 func (c *ContainerdClient) GetContainer(containerID string) (*runtimeclient.ContainerData, error) {
-	container, err := c.getContainer(containerID)
+	ctx, container, err := c.getContainer(containerID)
 	if err != nil {
 		return nil, err
 	}
 
-	labels, err := container.Labels(c.ctx)
+	labels, err := container.Labels(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("listing labels of container %q: %w", container.ID(), err)
 	}
 
-	image, err := container.Image(c.ctx)
+	image, err := container.Image(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting image details %q: %w", container.ID(), err)
 	}
@@ -153,6 +201,7 @@ func (c *ContainerdClient) GetContainer(containerID string) (*runtimeclient.Cont
 		},
 	}
 	runtimeclient.EnrichWithK8sMetadata(containerData, labels)
+	containerData.Runtime.Labels = labels
 	return containerData, nil
 }
 
@@ -162,7 +211,7 @@ func (c *ContainerdClient) GetContainerDetails(containerID string) (*runtimeclie
 		return nil, err
 	}
 
-	containerData, container, task, err := c.getContainerDataAndContainerAndTask(containerID)
+	ctx, containerData, container, task, err := c.getContainerDataAndContainerAndTask(containerID)
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +219,7 @@ func (c *ContainerdClient) GetContainerDetails(containerID string) (*runtimeclie
 		return nil, fmt.Errorf("got zero pid")
 	}
 
-	spec, err := container.Spec(c.ctx)
+	spec, err := container.Spec(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting spec for container %q: %w", containerID, err)
 	}
@@ -184,47 +233,93 @@ func (c *ContainerdClient) GetContainerDetails(containerID string) (*runtimeclie
 		}
 	}
 
-	return &runtimeclient.ContainerDetailsData{
+	containerDetailsData := &runtimeclient.ContainerDetailsData{
 		ContainerData: *containerData,
 		Pid:           int(task.pid),
-		CgroupsPath:   spec.Linux.CgroupsPath,
 		Mounts:        mountData,
-	}, nil
+	}
+	if len(spec.Annotations) > 0 {
+		containerDetailsData.K8s.Annotations = spec.Annotations
+	}
+	if spec.Linux != nil {
+		containerDetailsData.CgroupsPath = spec.Linux.CgroupsPath
+	}
+	if spec.Process != nil {
+		containerDetailsData.Env = spec.Process.Env
+	}
+
+	// With the systemd cgroup driver, used by rootless containerd, the spec
+	// contains "slice:prefix:name" instead of a path. Take the path the
+	// container is actually in (e.g. delegated under user.slice) from
+	// /proc/<pid>/cgroup.
+	if !strings.HasPrefix(containerDetailsData.CgroupsPath, "/") {
+		log.Debugf("cgroups path %q of container %q is not a path. Trying /proc/%d/cgroup as a fallback",
+			containerDetailsData.CgroupsPath, containerID, containerDetailsData.Pid)
+
+		cgroupPathV1, cgroupPathV2, err := cgroups.GetCgroupPaths(containerDetailsData.Pid)
+		if err == nil {
+			cgroupsPath := cgroupPathV1
+			if cgroupsPath == "" {
+				cgroupsPath = cgroupPathV2
+			}
+			containerDetailsData.CgroupsPath = cgroupsPath
+		} else {
+			log.Warnf("failed to get cgroups info of container %s from /proc/%d/cgroup: %s",
+				containerID, containerDetailsData.Pid, err)
+		}
+	}
+
+	return containerDetailsData, nil
 }
 
-func (c *ContainerdClient) getContainerDataAndContainerAndTask(containerID string) (*runtimeclient.ContainerData, containerd.Container, *containerTask, error) {
-	container, err := c.getContainer(containerID)
+func (c *ContainerdClient) getContainerDataAndContainerAndTask(containerID string) (context.Context, *runtimeclient.ContainerData, containerd.Container, *containerTask, error) {
+	ctx, container, err := c.getContainer(containerID)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	task, err := c.getContainerTask(container)
+	task, err := c.getContainerTask(ctx, container)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	containerData, err := c.taskAndContainerToContainerData(task, container)
+	containerData, err := c.taskAndContainerToContainerData(ctx, task, container)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	return containerData, container, task, nil
+	return ctx, containerData, container, task, nil
 }
 
 // getContainer returns the corresponding container.Container instance to
-// the given id
-func (c *ContainerdClient) getContainer(id string) (containerd.Container, error) {
-	container, err := c.client.LoadContainer(c.ctx, id)
+// the given id, along with a context for the namespace it belongs to.
+func (c *ContainerdClient) getContainer(id string) (context.Context, containerd.Container, error) {
+	nss, err := c.getNamespaces()
 	if err != nil {
-		return nil, fmt.Errorf("loading container with id %q: %w", id, err)
+		return nil, nil, err
 	}
 
-	if c.isSandboxContainer(container) {
-		log.Debugf("container %q is a sandbox container. Temporary skipping it", container.ID())
-		return nil, runtimeclient.ErrPauseContainer
+	for _, ns := range nss {
+		ctx := namespaces.WithNamespace(context.TODO(), ns)
+
+		container, err := c.client.LoadContainer(ctx, id)
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("loading container with id %q in namespace %q: %w", id, ns, err)
+		}
+
+		if c.isSandboxContainer(ctx, container) {
+			log.Debugf("container %q is a sandbox container. Temporary skipping it", container.ID())
+			return nil, nil, runtimeclient.ErrPauseContainer
+		}
+
+		return ctx, container, nil
 	}
 
-	return container, nil
+	return nil, nil, fmt.Errorf("loading container with id %q from namespaces %s: %w",
+		id, strings.Join(nss, ", "), errdefs.ErrNotFound)
 }
 
 // containerTask represents the task information for a given container.
@@ -236,8 +331,8 @@ type containerTask struct {
 // getContainerTask returns the containerTask information for a given container.
 // If the container is not running yet, it returns a containerTask with status
 // StateCreated and pid 0.
-func (c *ContainerdClient) getContainerTask(container containerd.Container) (*containerTask, error) {
-	task, err := container.Task(c.ctx, nil)
+func (c *ContainerdClient) getContainerTask(ctx context.Context, container containerd.Container) (*containerTask, error) {
+	task, err := container.Task(ctx, nil)
 	if err != nil {
 		// According to nerdctl, if there is no task, we can assume the
 		// container was just created but it is not running yet:
@@ -255,7 +350,7 @@ func (c *ContainerdClient) getContainerTask(container containerd.Container) (*co
 		return t, nil
 	}
 
-	containerdStatus, err := task.Status(c.ctx)
+	containerdStatus, err := task.Status(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting status of task for container %q: %w", container.ID(), err)
 	}
@@ -268,13 +363,13 @@ func (c *ContainerdClient) getContainerTask(container containerd.Container) (*co
 
 // Constructs a ContainerData from a containerTask and containerd.Container
 // The extra containerd.Container parameter saves an additional call to the API
-func (c *ContainerdClient) taskAndContainerToContainerData(task *containerTask, container containerd.Container) (*runtimeclient.ContainerData, error) {
-	labels, err := container.Labels(c.ctx)
+func (c *ContainerdClient) taskAndContainerToContainerData(ctx context.Context, task *containerTask, container containerd.Container) (*runtimeclient.ContainerData, error) {
+	labels, err := container.Labels(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("listing labels of container %q: %w", container.ID(), err)
 	}
 
-	image, err := container.Image(c.ctx)
+	image, err := container.Image(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting image of container %q: %w", container.ID(), err)
 	}
@@ -294,12 +389,13 @@ func (c *ContainerdClient) taskAndContainerToContainerData(task *containerTask,
 		},
 	}
 	runtimeclient.EnrichWithK8sMetadata(containerData, labels)
+	containerData.Runtime.Labels = labels
 	return containerData, nil
 }
 
 // Checks if the K8s Label for the Containerkind equals to sandbox
-func (c *ContainerdClient) isSandboxContainer(container containerd.Container) bool {
-	labels, err := container.Labels(c.ctx)
+func (c *ContainerdClient) isSandboxContainer(ctx context.Context, container containerd.Container) bool {
+	labels, err := container.Labels(ctx)
 	if err != nil {
 		return false
 	}
@@ -327,11 +423,15 @@ func processStatusStateToRuntimeClientState(status containerd.ProcessStatus) str
 
 // getContainerName returns the name of the container. If the container is
 // managed by Kubernetes, it returns the name of the container as defined in
-// Kubernetes. Otherwise, it returns the container ID.
+// Kubernetes. If it's managed by nerdctl, it returns the name given to
+// nerdctl. Otherwise, it returns the container ID.
 func getContainerName(container containerd.Container, labels map[string]string) string {
 	if k8sName, ok := labels[LabelK8sContainerName]; ok {
 		return k8sName
 	}
+	if nerdctlName, ok := labels[LabelNerdctlName]; ok {
+		return nerdctlName
+	}
 
 	return container.ID()
 }
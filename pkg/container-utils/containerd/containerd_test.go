@@ -70,3 +70,11 @@ func TestNamespace(t *testing.T) {
 	require.NotNil(t, containers)
 	require.Len(t, containers, 0)
 }
+
+func TestParseNamespaces(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []string{"k8s.io"}, parseNamespaces(""))
+	require.Equal(t, []string{"k8s.io", "default", "moby"}, parseNamespaces("k8s.io, default,moby,"))
+	require.Nil(t, parseNamespaces("k8s.io,*"))
+}
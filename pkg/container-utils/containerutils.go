@@ -25,8 +25,10 @@ import (
 	"syscall"
 
 	ocispec "github.com/opencontainers/runtime-spec/specs-go"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/containerd"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cri"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/crio"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/docker"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/podman"
@@ -75,6 +77,152 @@ func NewContainerRuntimeClient(runtime *containerutilsTypes.RuntimeConfig) (runt
 	}
 }
 
+// AutoRuntimes can be used instead of a list of runtimes to use all the
+// container runtimes detected on the host, see DetectRuntimes.
+const AutoRuntimes = "auto"
+
+type runtimeSockets struct {
+	env         string
+	defaultPath string
+	rootless    func() []string
+}
+
+var runtimesSockets = map[types.RuntimeName]runtimeSockets{
+	types.RuntimeNameDocker: {
+		env:         "INSPEKTOR_GADGET_DOCKER_SOCKETPATH",
+		defaultPath: runtimeclient.DockerDefaultSocketPath,
+		rootless:    runtimeclient.RootlessDockerSocketPaths,
+	},
+	types.RuntimeNameContainerd: {
+		env:         "INSPEKTOR_GADGET_CONTAINERD_SOCKETPATH",
+		defaultPath: runtimeclient.ContainerdDefaultSocketPath,
+		rootless:    runtimeclient.RootlessContainerdSocketPaths,
+	},
+	types.RuntimeNameCrio: {
+		env:         "INSPEKTOR_GADGET_CRIO_SOCKETPATH",
+		defaultPath: runtimeclient.CrioDefaultSocketPath,
+		rootless:    func() []string { return nil },
+	},
+	types.RuntimeNamePodman: {
+		env:         "INSPEKTOR_GADGET_PODMAN_SOCKETPATH",
+		defaultPath: runtimeclient.PodmanDefaultSocketPath,
+		rootless:    runtimeclient.RootlessPodmanSocketPaths,
+	},
+}
+
+// DetectRuntimes returns the runtimes, among the given ones, whose socket
+// exists on the host. When the socket path of a runtime isn't changed, a
+// configuration is also returned for each of its rootless instances, so all of
+// them are tracked at the same time.
+func DetectRuntimes(runtimes []*containerutilsTypes.RuntimeConfig) []*containerutilsTypes.RuntimeConfig {
+	detected := []*containerutilsTypes.RuntimeConfig{}
+
+	for _, runtime := range runtimes {
+		sockets, ok := runtimesSockets[runtime.Name]
+		if !ok {
+			continue
+		}
+
+		socketPath := runtime.SocketPath
+		if envsp := os.Getenv(sockets.env); envsp != "" && socketPath == "" {
+			socketPath = filepath.Join(host.HostRoot, envsp)
+		}
+		if socketPath == "" {
+			socketPath = sockets.defaultPath
+		}
+
+		if _, err := os.Stat(socketPath); err == nil {
+			log.Debugf("Runtime %s detected: %s", runtime.Name, socketPath)
+			detected = append(detected, runtime)
+		}
+
+		if socketPath != sockets.defaultPath {
+			continue
+		}
+
+		for _, rootless := range sockets.rootless() {
+			log.Debugf("Rootless runtime %s detected: %s", runtime.Name, rootless)
+			r := *runtime
+			r.SocketPath = rootless
+			detected = append(detected, &r)
+		}
+	}
+
+	return detected
+}
+
+// ParseRuntimes returns the configuration of the runtimes in names, created
+// by newConfig, skipping the duplicated ones. If names is AutoRuntimes, all
+// the available runtimes detected on the host are used, or all of them if
+// none is detected since their sockets could be created later.
+func ParseRuntimes(
+	names []string,
+	newConfig func(runtimeName types.RuntimeName, name string) (*containerutilsTypes.RuntimeConfig, error),
+) ([]*containerutilsTypes.RuntimeConfig, error) {
+	autoDetect := len(names) == 1 && strings.TrimSpace(names[0]) == AutoRuntimes
+	if autoDetect {
+		names = AvailableRuntimes
+	}
+
+	runtimes := []*containerutilsTypes.RuntimeConfig{}
+namesLoop:
+	for _, name := range names {
+		runtimeName := types.String2RuntimeName(strings.TrimSpace(name))
+		for _, r := range runtimes {
+			if r.Name == runtimeName {
+				log.Infof("Ignoring duplicated runtime %q from %v", runtimeName, names)
+				continue namesLoop
+			}
+		}
+
+		r, err := newConfig(runtimeName, name)
+		if err != nil {
+			return nil, err
+		}
+		runtimes = append(runtimes, r)
+	}
+
+	if !autoDetect {
+		return runtimes, nil
+	}
+	if detected := DetectRuntimes(runtimes); len(detected) > 0 {
+		return detected, nil
+	}
+	log.Debugf("No container runtime detected, using %v", names)
+	return runtimes, nil
+}
+
+// NewCRIClient creates a CRI client for the runtimes implementing it. Unlike
+// NewContainerRuntimeClient, it also uses CRI for containerd.
+func NewCRIClient(runtime *containerutilsTypes.RuntimeConfig) (*cri.CRIClient, error) {
+	socketPath := runtime.SocketPath
+
+	switch runtime.Name {
+	case types.RuntimeNameContainerd:
+		if envsp := os.Getenv("INSPEKTOR_GADGET_CONTAINERD_SOCKETPATH"); envsp != "" && socketPath == "" {
+			socketPath = filepath.Join(host.HostRoot, envsp)
+		}
+		if socketPath == "" {
+			socketPath = runtimeclient.ContainerdDefaultSocketPath
+		}
+	case types.RuntimeNameCrio:
+		if envsp := os.Getenv("INSPEKTOR_GADGET_CRIO_SOCKETPATH"); envsp != "" && socketPath == "" {
+			socketPath = filepath.Join(host.HostRoot, envsp)
+		}
+		if socketPath == "" {
+			socketPath = runtimeclient.CrioDefaultSocketPath
+		}
+	default:
+		return nil, fmt.Errorf("container runtime %s doesn't implement CRI", runtime.Name)
+	}
+
+	criClient, err := cri.NewCRIClient(runtime.Name, socketPath, crio.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &criClient, nil
+}
+
 func getNamespaceInode(pid int, nsType string) (uint64, error) {
 	fileinfo, err := os.Stat(filepath.Join(host.HostProcFs, fmt.Sprint(pid), "ns", nsType))
 	if err != nil {
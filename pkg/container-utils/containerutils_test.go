@@ -15,6 +15,7 @@
 package containerutils
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -68,6 +69,89 @@ func TestNewContainerRuntimeClient(t *testing.T) {
 	}
 }
 
+func TestDetectRuntimes(t *testing.T) {
+	t.Parallel()
+
+	existingSocketPath := filepath.Join(t.TempDir(), "existing-socket")
+	require.Nil(t, os.WriteFile(existingSocketPath, nil, 0o600))
+	nonExistingSocketPath := filepath.Join(t.TempDir(), "non-existing-socket")
+
+	runtimes := []*containerutilsTypes.RuntimeConfig{
+		{Name: types.RuntimeNameDocker, SocketPath: existingSocketPath},
+		{Name: types.RuntimeNameContainerd, SocketPath: nonExistingSocketPath},
+		{Name: types.RuntimeNamePodman, SocketPath: existingSocketPath},
+		{Name: types.RuntimeNameCrio, SocketPath: nonExistingSocketPath},
+	}
+
+	detected := DetectRuntimes(runtimes)
+	require.Equal(t, []*containerutilsTypes.RuntimeConfig{runtimes[0], runtimes[2]}, detected)
+}
+
+func TestParseRuntimes(t *testing.T) {
+	t.Parallel()
+
+	existingSocketPath := filepath.Join(t.TempDir(), "existing-socket")
+	require.Nil(t, os.WriteFile(existingSocketPath, nil, 0o600))
+	nonExistingSocketPath := filepath.Join(t.TempDir(), "non-existing-socket")
+
+	newConfig := func(socketPaths map[types.RuntimeName]string) func(types.RuntimeName, string) (*containerutilsTypes.RuntimeConfig, error) {
+		return func(runtimeName types.RuntimeName, name string) (*containerutilsTypes.RuntimeConfig, error) {
+			socketPath, ok := socketPaths[runtimeName]
+			if !ok {
+				return nil, fmt.Errorf("runtime %q is not supported", name)
+			}
+			return &containerutilsTypes.RuntimeConfig{Name: runtimeName, SocketPath: socketPath}, nil
+		}
+	}
+	allNonExisting := map[types.RuntimeName]string{
+		types.RuntimeNameDocker:     nonExistingSocketPath,
+		types.RuntimeNameContainerd: nonExistingSocketPath,
+		types.RuntimeNameCrio:       nonExistingSocketPath,
+		types.RuntimeNamePodman:     nonExistingSocketPath,
+	}
+
+	t.Run("List", func(t *testing.T) {
+		t.Parallel()
+
+		runtimes, err := ParseRuntimes([]string{"docker", " containerd", "docker"}, newConfig(allNonExisting))
+		require.Nil(t, err)
+		require.Equal(t, []*containerutilsTypes.RuntimeConfig{
+			{Name: types.RuntimeNameDocker, SocketPath: nonExistingSocketPath},
+			{Name: types.RuntimeNameContainerd, SocketPath: nonExistingSocketPath},
+		}, runtimes)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ParseRuntimes([]string{"foo"}, newConfig(allNonExisting))
+		require.ErrorContains(t, err, `runtime "foo" is not supported`)
+	})
+
+	t.Run("AutoDetected", func(t *testing.T) {
+		t.Parallel()
+
+		runtimes, err := ParseRuntimes([]string{AutoRuntimes}, newConfig(map[types.RuntimeName]string{
+			types.RuntimeNameDocker:     nonExistingSocketPath,
+			types.RuntimeNameContainerd: nonExistingSocketPath,
+			types.RuntimeNameCrio:       existingSocketPath,
+			types.RuntimeNamePodman:     nonExistingSocketPath,
+		}))
+		require.Nil(t, err)
+		require.Equal(t, []*containerutilsTypes.RuntimeConfig{
+			{Name: types.RuntimeNameCrio, SocketPath: existingSocketPath},
+		}, runtimes)
+	})
+
+	t.Run("AutoNoneDetected", func(t *testing.T) {
+		t.Parallel()
+
+		runtimes, err := ParseRuntimes([]string{AutoRuntimes}, newConfig(allNonExisting))
+		require.Nil(t, err)
+		require.Len(t, runtimes, len(AvailableRuntimes))
+	})
+}
+
 func TestParseOCIState(t *testing.T) {
 	t.Parallel()
 
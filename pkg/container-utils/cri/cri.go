@@ -113,7 +113,10 @@ func (c *CRIClient) GetContainer(containerID string) (*runtimeclient.ContainerDa
 			len(containers), containerID, containers)
 	}
 
-	return CRIContainerToContainerData(c.Name, containers[0]), nil
+	containerData := CRIContainerToContainerData(c.Name, containers[0])
+	c.addPodAnnotations(containerData, containers[0].GetPodSandboxId())
+
+	return containerData, nil
 }
 
 func (c *CRIClient) GetContainerDetails(containerID string) (*runtimeclient.ContainerDetailsData, error) {
@@ -132,7 +135,65 @@ func (c *CRIClient) GetContainerDetails(containerID string) (*runtimeclient.Cont
 		return nil, err
 	}
 
-	return parseContainerDetailsData(c.Name, res.Status, res.Info)
+	containerDetailsData, err := parseContainerDetailsData(c.Name, res.Status, res.Info)
+	if err != nil {
+		return nil, err
+	}
+	c.addPodAnnotations(&containerDetailsData.ContainerData, sandboxIDFromExtraInfo(res.Info))
+
+	return containerDetailsData, nil
+}
+
+// addPodAnnotations adds the annotations of the pod sandbox to the ones of the
+// container. Failing to get them isn't fatal as they are only used to enrich
+// the container.
+func (c *CRIClient) addPodAnnotations(containerData *runtimeclient.ContainerData, sandboxID string) {
+	if sandboxID == "" {
+		return
+	}
+
+	res, err := c.client.PodSandboxStatus(context.Background(), &runtime.PodSandboxStatusRequest{
+		PodSandboxId: sandboxID,
+	})
+	if err != nil {
+		log.Debugf("CRIClient: getting status of pod sandbox %q: %s", sandboxID, err)
+		return
+	}
+
+	// Annotations of the container take precedence.
+	containerData.K8s.Annotations = mergeAnnotations(res.GetStatus().GetAnnotations(), containerData.K8s.Annotations)
+}
+
+// sandboxIDFromExtraInfo returns the ID of the pod sandbox of the container
+// from the extra information returned by ContainerStatus(), supporting both
+// formats handled by parseExtraInfo.
+func sandboxIDFromExtraInfo(extraInfo map[string]string) string {
+	if info, ok := extraInfo["info"]; ok {
+		var infoContent struct {
+			SandboxID string `json:"sandboxID"`
+		}
+		if err := json.Unmarshal([]byte(info), &infoContent); err != nil {
+			return ""
+		}
+		return infoContent.SandboxID
+	}
+
+	return extraInfo["sandboxID"]
+}
+
+// mergeAnnotations returns a map with the annotations of all the given maps.
+// Later maps take precedence. It returns nil if there are no annotations.
+func mergeAnnotations(annotations ...map[string]string) map[string]string {
+	var ret map[string]string
+	for _, a := range annotations {
+		for k, v := range a {
+			if ret == nil {
+				ret = make(map[string]string)
+			}
+			ret[k] = v
+		}
+	}
+	return ret
 }
 
 func (c *CRIClient) Close() error {
@@ -180,6 +241,10 @@ func parseExtraInfo(extraInfo map[string]string,
 		Linux *struct {
 			CgroupsPath string `json:"cgroupsPath,omitempty"`
 		} `json:"linux,omitempty" platform:"linux"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+		Process     *struct {
+			Env []string `json:"env,omitempty"`
+		} `json:"process,omitempty"`
 	}
 	type InfoContent struct {
 		Pid         int                `json:"pid"`
@@ -245,6 +310,13 @@ func parseExtraInfo(extraInfo map[string]string,
 		if runtimeSpec.Linux != nil {
 			containerDetailsData.CgroupsPath = runtimeSpec.Linux.CgroupsPath
 		}
+		if runtimeSpec.Process != nil {
+			containerDetailsData.Env = runtimeSpec.Process.Env
+		}
+		// Annotations reported by the CRI take precedence over the ones
+		// of the OCI spec.
+		containerDetailsData.K8s.Annotations = mergeAnnotations(runtimeSpec.Annotations,
+			containerDetailsData.K8s.Annotations)
 		if len(runtimeSpec.Mounts) > 0 {
 			containerDetailsData.Mounts = make([]runtimeclient.ContainerMountData, len(runtimeSpec.Mounts))
 			for i, specMount := range runtimeSpec.Mounts {
@@ -284,6 +356,7 @@ type CRIContainer interface {
 	GetState() runtime.ContainerState
 	GetMetadata() *runtime.ContainerMetadata
 	GetLabels() map[string]string
+	GetAnnotations() map[string]string
 	GetImage() *runtime.ImageSpec
 	GetImageRef() string
 }
@@ -307,10 +380,14 @@ func CRIContainerToContainerData(runtimeName types.RuntimeName, container CRICon
 			},
 			State: containerStatusStateToRuntimeClientState(container.GetState()),
 		},
+		K8s: runtimeclient.K8sContainerData{
+			Annotations: mergeAnnotations(container.GetAnnotations()),
+		},
 	}
 
 	// Fill K8S information.
 	runtimeclient.EnrichWithK8sMetadata(containerData, container.GetLabels())
+	containerData.Runtime.Labels = container.GetLabels()
 
 	// CRI-O does not use the same container name of Kubernetes as containerd.
 	// Instead, it uses a composed name as Docker does, but such name is not
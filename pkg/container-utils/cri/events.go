@@ -0,0 +1,92 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	runtime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// ErrEventsUnsupported is returned by WatchContainerEvents when the runtime
+// doesn't implement the GetContainerEvents API.
+var ErrEventsUnsupported = errors.New("container events not supported by the runtime")
+
+// ContainerEventType is the type of the events reported by
+// WatchContainerEvents.
+type ContainerEventType int
+
+const (
+	ContainerEventStarted ContainerEventType = iota
+	ContainerEventStopped
+)
+
+// ContainerEvents is a stream of container events opened with
+// OpenContainerEvents.
+type ContainerEvents struct {
+	stream runtime.RuntimeService_GetContainerEventsClient
+}
+
+// OpenContainerEvents opens a stream of the container events of the runtime
+// with the CRI GetContainerEvents API, available since containerd v1.7 and
+// CRI-O v1.26. The events are kept by the stream until they're received with
+// Watch, so a listing of the containers done after opening it doesn't miss
+// any. The stream is closed when ctx is done.
+func (c *CRIClient) OpenContainerEvents(ctx context.Context) (*ContainerEvents, error) {
+	stream, err := c.client.GetContainerEvents(ctx, &runtime.GetEventsRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return nil, ErrEventsUnsupported
+		}
+		return nil, fmt.Errorf("getting container events: %w", err)
+	}
+	return &ContainerEvents{stream: stream}, nil
+}
+
+// Watch calls callback for each container that started or stopped. It blocks
+// until the context of the stream is done or the stream fails.
+func (e *ContainerEvents) Watch(callback func(containerID string, eventType ContainerEventType)) error {
+	for {
+		event, err := e.stream.Recv()
+		if err != nil {
+			if status.Code(err) == codes.Unimplemented {
+				return ErrEventsUnsupported
+			}
+			return fmt.Errorf("receiving container events: %w", err)
+		}
+
+		switch event.GetContainerEventType() {
+		case runtime.ContainerEventType_CONTAINER_STARTED_EVENT:
+			callback(event.GetContainerId(), ContainerEventStarted)
+		case runtime.ContainerEventType_CONTAINER_STOPPED_EVENT,
+			runtime.ContainerEventType_CONTAINER_DELETED_EVENT:
+			callback(event.GetContainerId(), ContainerEventStopped)
+		}
+	}
+}
+
+// WatchContainerEvents opens a stream of container events and watches it, see
+// OpenContainerEvents and Watch.
+func (c *CRIClient) WatchContainerEvents(ctx context.Context, callback func(containerID string, eventType ContainerEventType)) error {
+	events, err := c.OpenContainerEvents(ctx)
+	if err != nil {
+		return err
+	}
+	return events.Watch(callback)
+}
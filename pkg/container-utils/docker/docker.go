@@ -46,9 +46,8 @@ type DockerClient struct {
 }
 
 func NewDockerClient(socketPath string) (runtimeclient.ContainerRuntimeClient, error) {
-	if socketPath == "" {
-		socketPath = runtimeclient.DockerDefaultSocketPath
-	}
+	socketPath = runtimeclient.ResolveSocketPath(socketPath, runtimeclient.DockerDefaultSocketPath,
+		runtimeclient.RootlessDockerSocketPaths)
 
 	cli, err := client.NewClientWithOpts(
 		client.WithAPIVersionNegotiation(),
@@ -186,6 +185,8 @@ func (c *DockerClient) GetContainerDetails(containerID string) (*runtimeclient.C
 
 	// Fill K8S information.
 	runtimeclient.EnrichWithK8sMetadata(&containerDetailsData.ContainerData, containerJSON.Config.Labels)
+	containerDetailsData.Runtime.Labels = containerJSON.Config.Labels
+	containerDetailsData.Env = containerJSON.Config.Env
 
 	// Try to get cgroups information from /proc/<pid>/cgroup as a fallback.
 	// However, don't fail if such a file is not available, as it would prevent the
@@ -251,6 +252,7 @@ func DockerContainerToContainerData(container *dockertypes.Container) *runtimecl
 
 	// Fill K8S information.
 	runtimeclient.EnrichWithK8sMetadata(containerData, container.Labels)
+	containerData.Runtime.Labels = container.Labels
 
 	return containerData
 }
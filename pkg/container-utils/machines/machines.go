@@ -0,0 +1,325 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package machines detects, on a best-effort basis, the containers running a
+// full system, like LXC/LXD and systemd-nspawn ones. They aren't created by
+// runc, so the runtime hooks don't see them.
+package machines
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cgroups"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// Machine is a container running a full system.
+type Machine struct {
+	Name    string
+	Runtime types.RuntimeName
+	// Pid is the leader of the machine, i.e. its init process.
+	Pid uint32
+}
+
+type machineKey struct {
+	runtime types.RuntimeName
+	name    string
+}
+
+// List returns the machines running on the host. It uses the machines
+// registered with systemd-machined, the same ones listed by "machinectl
+// list", and the cgroups created by LXC and systemd-nspawn.
+func List() ([]Machine, error) {
+	machines := map[machineKey]Machine{}
+
+	registered, err := machinedMachines(filepath.Join(host.HostRoot, "/run/systemd/machines"))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range registered {
+		machines[machineKey{m.Runtime, m.Name}] = m
+	}
+
+	fromCgroups, err := cgroupMachines()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range fromCgroups {
+		key := machineKey{m.Runtime, m.Name}
+		if _, ok := machines[key]; !ok {
+			machines[key] = m
+		}
+	}
+
+	ret := make([]Machine, 0, len(machines))
+	for _, m := range machines {
+		ret = append(ret, m)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+
+	return ret, nil
+}
+
+// parseMachinedState parses the state file systemd-machined keeps for each
+// registered machine. Only containers are returned, not virtual machines.
+func parseMachinedState(r io.Reader) (Machine, bool) {
+	var m Machine
+	var class string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "NAME":
+			m.Name = value
+		case "SERVICE":
+			m.Runtime = machinedServiceRuntime(value)
+		case "CLASS":
+			class = value
+		case "LEADER":
+			pid, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return Machine{}, false
+			}
+			m.Pid = uint32(pid)
+		}
+	}
+	if scanner.Err() != nil || class != "container" || m.Name == "" || m.Pid == 0 {
+		return Machine{}, false
+	}
+	if m.Runtime == "" {
+		m.Runtime = machinedServiceRuntime("")
+	}
+
+	return m, true
+}
+
+// machinedServiceRuntime returns the runtime of a machine registered by the
+// given service, e.g. "nspawn" for systemd-nspawn, named like the runtime
+// detected from the cgroups of the machine, so both are the same machine.
+func machinedServiceRuntime(service string) types.RuntimeName {
+	switch service {
+	case "", "nspawn":
+		return types.RuntimeNameSystemdNspawn
+	case "lxc":
+		return types.RuntimeNameLXC
+	}
+	return types.RuntimeName(service)
+}
+
+func machinedMachines(dir string) ([]Machine, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	machines := []Machine{}
+	for _, entry := range entries {
+		// "unit:<name>" entries are links to the machine files
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		m, ok := parseMachinedState(f)
+		f.Close()
+		if ok {
+			machines = append(machines, m)
+		}
+	}
+
+	return machines, nil
+}
+
+// unescapeUnitName reverts the escaping done by systemd on the instance part
+// of unit names, e.g. "my\x2dmachine" is "my-machine".
+func unescapeUnitName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) && name[i+1] == 'x' {
+			if b, err := strconv.ParseUint(name[i+2:i+4], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
+// machineFromCgroup returns the machine a cgroup belongs to, based on the
+// names used by LXC and systemd-nspawn:
+//   - /lxc.payload.<name>/... for LXC >= 4.0 and LXD
+//   - /lxc.payload/<name>/... and /lxc/<name>/... for older versions
+//   - /machine.slice/systemd-nspawn@<name>.service/... for systemd-nspawn
+func machineFromCgroup(path string) (machineKey, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, "lxc.payload."):
+			return machineKey{types.RuntimeNameLXC, strings.TrimPrefix(part, "lxc.payload.")}, true
+		case (part == "lxc.payload" || part == "lxc") && i+1 < len(parts):
+			return machineKey{types.RuntimeNameLXC, parts[i+1]}, true
+		case strings.HasPrefix(part, "systemd-nspawn@") && strings.HasSuffix(part, ".service"):
+			name := strings.TrimSuffix(strings.TrimPrefix(part, "systemd-nspawn@"), ".service")
+			return machineKey{types.RuntimeNameSystemdNspawn, unescapeUnitName(name)}, true
+		}
+	}
+	return machineKey{}, false
+}
+
+// isMachineCgroup tells if name, a cgroup of the root of a hierarchy or of
+// machine.slice, is one created by LXC or systemd-nspawn for a machine.
+func isMachineCgroup(name string) bool {
+	return name == "lxc" || name == "lxc.payload" || strings.HasPrefix(name, "lxc.payload.") ||
+		strings.HasPrefix(name, "systemd-nspawn@")
+}
+
+// cgroupRoots returns the mountpoints of the hierarchies the cgroups of the
+// machines are looked for in.
+func cgroupRoots() []string {
+	roots := []string{}
+	if root, err := cgroups.CgroupPathV2AddMountpoint("/"); err == nil {
+		roots = append(roots, root)
+	}
+	for _, controller := range []string{"name=systemd", "pids"} {
+		if root, err := cgroups.CgroupPathV1AddMountpoint(controller, "/"); err == nil {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// Present tells, without scanning the processes, if there can be machines
+// running: systemd-machined has containers registered, or LXC or
+// systemd-nspawn created the cgroups of a machine. It's cheap enough to be
+// checked before every List().
+func Present() bool {
+	registered, _ := machinedMachines(filepath.Join(host.HostRoot, "/run/systemd/machines"))
+	if len(registered) > 0 {
+		return true
+	}
+
+	for _, root := range cgroupRoots() {
+		for _, dir := range []string{root, filepath.Join(root, "machine.slice")} {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() && isMachineCgroup(entry.Name()) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// parentPid returns the parent of a process.
+func parentPid(pid int) (int, error) {
+	content, err := os.ReadFile(filepath.Join(host.HostProcFs, fmt.Sprint(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// The command can contain spaces and parentheses, skip it
+	idx := strings.LastIndexByte(string(content), ')')
+	if idx == -1 {
+		return 0, fmt.Errorf("invalid stat file for pid %d", pid)
+	}
+	fields := strings.Fields(string(content[idx+1:]))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid stat file for pid %d", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
+
+// cgroupMachines looks for the processes in the cgroups of machines. The
+// leader of a machine is its process whose parent is outside of the machine.
+func cgroupMachines() ([]Machine, error) {
+	entries, err := os.ReadDir(host.HostProcFs)
+	if err != nil {
+		return nil, err
+	}
+
+	pids := map[machineKey]map[int]struct{}{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		cgroupPathV1, cgroupPathV2, err := cgroups.GetCgroupPaths(pid)
+		if err != nil {
+			continue
+		}
+		cgroupPath := cgroupPathV2
+		if cgroupPath == "" {
+			cgroupPath = cgroupPathV1
+		}
+		key, ok := machineFromCgroup(cgroupPath)
+		if !ok {
+			continue
+		}
+		if pids[key] == nil {
+			pids[key] = map[int]struct{}{}
+		}
+		pids[key][pid] = struct{}{}
+	}
+
+	machines := []Machine{}
+	for key, set := range pids {
+		leader := 0
+		for pid := range set {
+			ppid, err := parentPid(pid)
+			if err != nil {
+				continue
+			}
+			if _, ok := set[ppid]; ok {
+				continue
+			}
+			if leader == 0 || pid < leader {
+				leader = pid
+			}
+		}
+		if leader == 0 {
+			continue
+		}
+		machines = append(machines, Machine{
+			Name:    key.name,
+			Runtime: key.runtime,
+			Pid:     uint32(leader),
+		})
+	}
+
+	return machines, nil
+}
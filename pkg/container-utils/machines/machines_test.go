@@ -0,0 +1,85 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machines
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestMachineFromCgroup(t *testing.T) {
+	table := []struct {
+		path    string
+		ok      bool
+		runtime types.RuntimeName
+		name    string
+	}{
+		{path: "/lxc.payload.web/init.scope", ok: true, runtime: types.RuntimeNameLXC, name: "web"},
+		{path: "/lxc.payload/web", ok: true, runtime: types.RuntimeNameLXC, name: "web"},
+		{path: "/lxc/web/system.slice/cron.service", ok: true, runtime: types.RuntimeNameLXC, name: "web"},
+		{path: "/lxc.monitor.web", ok: false},
+		{
+			path:    "/machine.slice/systemd-nspawn@my\\x2dmachine.service/payload/system.slice",
+			ok:      true,
+			runtime: types.RuntimeNameSystemdNspawn,
+			name:    "my-machine",
+		},
+		{path: "/system.slice/docker-abc.scope", ok: false},
+		{path: "/", ok: false},
+	}
+
+	for _, entry := range table {
+		key, ok := machineFromCgroup(entry.path)
+		if ok != entry.ok {
+			t.Fatalf("%q: got %v, expected %v", entry.path, ok, entry.ok)
+		}
+		if ok && (key.runtime != entry.runtime || key.name != entry.name) {
+			t.Fatalf("%q: got %s/%s, expected %s/%s", entry.path, key.runtime, key.name, entry.runtime, entry.name)
+		}
+	}
+}
+
+func TestParseMachinedState(t *testing.T) {
+	state := `# This is private data. Do not parse.
+NAME=debian
+SCOPE=machine-debian.scope
+SERVICE=nspawn
+ROOT=/var/lib/machines/debian
+ID=0123456789abcdef0123456789abcdef
+LEADER=4242
+CLASS=container
+`
+	m, ok := parseMachinedState(strings.NewReader(state))
+	if !ok {
+		t.Fatalf("parsing state failed")
+	}
+	expected := Machine{Name: "debian", Runtime: types.RuntimeNameSystemdNspawn, Pid: 4242}
+	if m != expected {
+		t.Fatalf("got %+v, expected %+v", m, expected)
+	}
+
+	lxc := strings.Replace(state, "SERVICE=nspawn", "SERVICE=lxc", 1)
+	m, ok = parseMachinedState(strings.NewReader(lxc))
+	if !ok || m.Runtime != types.RuntimeNameLXC {
+		t.Fatalf("got %+v, expected an LXC machine", m)
+	}
+
+	vm := strings.Replace(state, "CLASS=container", "CLASS=vm", 1)
+	if _, ok := parseMachinedState(strings.NewReader(vm)); ok {
+		t.Fatalf("virtual machines shouldn't be returned")
+	}
+}
@@ -15,11 +15,16 @@
 package ociannotations
 
 import (
+	"encoding/json"
 	"errors"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
+// crioAnnotations is the annotation where cri-o stores the annotations
+// Kubernetes gave to the container, encoded as JSON.
+const crioAnnotations = "io.kubernetes.cri-o.Annotations"
+
 // ErrUnsupportedContainerRuntime is used for unsupported container runtime
 var ErrUnsupportedContainerRuntime = errors.New("unsupported container runtime")
 
@@ -64,3 +69,25 @@ func NewResolverFromAnnotations(annotations map[string]string) (Resolver, error)
 
 	return nil, ErrUnsupportedContainerRuntime
 }
+
+// Annotations returns the annotations of a container given the annotations of
+// its OCI config. The annotations cri-o encodes in a single annotation are
+// decoded and take precedence.
+func Annotations(annotations map[string]string) map[string]string {
+	ret := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		ret[k] = v
+	}
+
+	if encoded, ok := annotations[crioAnnotations]; ok {
+		var decoded map[string]string
+		if err := json.Unmarshal([]byte(encoded), &decoded); err == nil {
+			delete(ret, crioAnnotations)
+			for k, v := range decoded {
+				ret[k] = v
+			}
+		}
+	}
+
+	return ret
+}
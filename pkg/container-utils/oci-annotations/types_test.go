@@ -98,3 +98,46 @@ func TestNewResolverFromAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]string
+	}{
+		{
+			name:        "plain annotations",
+			annotations: map[string]string{"team": "foo"},
+			want:        map[string]string{"team": "foo"},
+		},
+		{
+			name: "cri-o encoded annotations",
+			annotations: map[string]string{
+				crioContainerManagerAnnotation: "cri-o",
+				crioAnnotations:                `{"team":"bar","owner":"alice"}`,
+				"team":                         "foo",
+			},
+			want: map[string]string{
+				crioContainerManagerAnnotation: "cri-o",
+				"team":                         "bar",
+				"owner":                        "alice",
+			},
+		},
+		{
+			name: "invalid cri-o encoded annotations",
+			annotations: map[string]string{
+				crioAnnotations: "{",
+			},
+			want: map[string]string{
+				crioAnnotations: "{",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Annotations(tt.annotations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Annotations() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
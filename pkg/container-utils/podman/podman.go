@@ -17,6 +17,7 @@ package podman
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -25,6 +26,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cgroups"
 	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
@@ -40,9 +42,8 @@ type PodmanClient struct {
 }
 
 func NewPodmanClient(socketPath string) runtimeclient.ContainerRuntimeClient {
-	if socketPath == "" {
-		socketPath = runtimeclient.PodmanDefaultSocketPath
-	}
+	socketPath = runtimeclient.ResolveSocketPath(socketPath, runtimeclient.PodmanDefaultSocketPath,
+		runtimeclient.RootlessPodmanSocketPaths)
 
 	return &PodmanClient{
 		client: http.Client{
@@ -77,27 +78,50 @@ func (p *PodmanClient) listContainers(containerID string) ([]*runtimeclient.Cont
 	}
 
 	var containers []struct {
-		ID    string   `json:"Id"`
-		Names []string `json:"Names"`
-		State string   `json:"State"`
+		ID      string            `json:"Id"`
+		Names   []string          `json:"Names"`
+		Image   string            `json:"Image"`
+		State   string            `json:"State"`
+		Labels  map[string]string `json:"Labels"`
+		IsInfra bool              `json:"IsInfra"`
 	}
 	if err = json.NewDecoder(resp.Body).Decode(&containers); err != nil {
 		return nil, fmt.Errorf("decoding containers: %w", err)
 	}
 
-	ret := make([]*runtimeclient.ContainerData, len(containers))
-	for i, c := range containers {
-		ret[i] = &runtimeclient.ContainerData{
+	ret := make([]*runtimeclient.ContainerData, 0, len(containers))
+	for _, c := range containers {
+		// Skip the infra containers of the pods, they are the equivalent of
+		// the pause containers.
+		if c.IsInfra {
+			continue
+		}
+
+		var name string
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+
+		containerData := &runtimeclient.ContainerData{
 			Runtime: runtimeclient.RuntimeContainerData{
 				BasicRuntimeMetadata: types.BasicRuntimeMetadata{
-					ContainerID:   c.ID,
-					ContainerName: c.Names[0],
-					RuntimeName:   types.RuntimeNamePodman,
+					ContainerID:        c.ID,
+					ContainerName:      name,
+					RuntimeName:        types.RuntimeNamePodman,
+					ContainerImageName: c.Image,
 				},
 				State: containerStatusStateToRuntimeClientState(c.State),
 			},
 		}
+		runtimeclient.EnrichWithK8sMetadata(containerData, c.Labels)
+		containerData.Runtime.Labels = c.Labels
+
+		ret = append(ret, containerData)
 	}
+	if containerID != "" && len(containers) != 0 && len(ret) == 0 {
+		return nil, runtimeclient.ErrPauseContainer
+	}
+
 	return ret, nil
 }
 
@@ -121,6 +145,11 @@ func (p *PodmanClient) GetContainer(containerID string) (*runtimeclient.Containe
 }
 
 func (p *PodmanClient) GetContainerDetails(containerID string) (*runtimeclient.ContainerDetailsData, error) {
+	containerID, err := runtimeclient.ParseContainerID(types.RuntimeNamePodman, containerID)
+	if err != nil {
+		return nil, err
+	}
+
 	resp, err := p.client.Get(fmt.Sprintf(containerInspectURL, containerID))
 	if err != nil {
 		return nil, fmt.Errorf("inspecting container %q: %w", containerID, err)
@@ -132,33 +161,79 @@ func (p *PodmanClient) GetContainerDetails(containerID string) (*runtimeclient.C
 	}
 
 	var container struct {
-		ID    string `json:"Id"`
-		Name  string `json:"Name"`
-		State struct {
+		ID        string `json:"Id"`
+		Name      string `json:"Name"`
+		ImageName string `json:"ImageName"`
+		State     struct {
 			Status     string `json:"Status"`
 			Pid        int    `json:"Pid"`
 			CgroupPath string `json:"CgroupPath"`
 		} `json:"State"`
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+			Env    []string          `json:"Env"`
+		} `json:"Config"`
+		Mounts []struct {
+			Source      string `json:"Source"`
+			Destination string `json:"Destination"`
+		} `json:"Mounts"`
 	}
 
 	if err = json.NewDecoder(resp.Body).Decode(&container); err != nil {
 		return nil, fmt.Errorf("decoding container %q: %w", containerID, err)
 	}
 
-	return &runtimeclient.ContainerDetailsData{
+	if container.State.Pid == 0 {
+		return nil, errors.New("got zero pid")
+	}
+
+	containerDetailsData := &runtimeclient.ContainerDetailsData{
 		ContainerData: runtimeclient.ContainerData{
 			Runtime: runtimeclient.RuntimeContainerData{
 				BasicRuntimeMetadata: types.BasicRuntimeMetadata{
-					ContainerID:   container.ID,
-					ContainerName: container.Name,
-					RuntimeName:   types.RuntimeNamePodman,
+					ContainerID:        container.ID,
+					ContainerName:      container.Name,
+					RuntimeName:        types.RuntimeNamePodman,
+					ContainerImageName: container.ImageName,
 				},
 				State: containerStatusStateToRuntimeClientState(container.State.Status),
 			},
 		},
 		Pid:         container.State.Pid,
 		CgroupsPath: container.State.CgroupPath,
-	}, nil
+	}
+	if len(container.Mounts) > 0 {
+		containerDetailsData.Mounts = make([]runtimeclient.ContainerMountData, len(container.Mounts))
+		for i, containerMount := range container.Mounts {
+			containerDetailsData.Mounts[i] = runtimeclient.ContainerMountData{
+				Destination: containerMount.Destination,
+				Source:      containerMount.Source,
+			}
+		}
+	}
+
+	// Fill K8S information, set for instance by "podman kube play".
+	runtimeclient.EnrichWithK8sMetadata(&containerDetailsData.ContainerData, container.Config.Labels)
+	containerDetailsData.Runtime.Labels = container.Config.Labels
+	containerDetailsData.Env = container.Config.Env
+
+	// Rootless podman doesn't always provide the cgroup path, try to get it
+	// from /proc/<pid>/cgroup as a fallback.
+	if containerDetailsData.CgroupsPath == "" {
+		cgroupPathV1, cgroupPathV2, err := cgroups.GetCgroupPaths(containerDetailsData.Pid)
+		if err == nil {
+			cgroupsPath := cgroupPathV1
+			if cgroupsPath == "" {
+				cgroupsPath = cgroupPathV2
+			}
+			containerDetailsData.CgroupsPath = cgroupsPath
+		} else {
+			log.Warnf("failed to get cgroups info of container %s from /proc/%d/cgroup: %s",
+				containerID, containerDetailsData.Pid, err)
+		}
+	}
+
+	return containerDetailsData, nil
 }
 
 func (p *PodmanClient) Close() error {
@@ -167,11 +242,11 @@ func (p *PodmanClient) Close() error {
 
 func containerStatusStateToRuntimeClientState(containerState string) string {
 	switch containerState {
-	case "created":
+	case "created", "configured", "initialized":
 		return runtimeclient.StateCreated
 	case "running":
 		return runtimeclient.StateRunning
-	case "exited":
+	case "exited", "stopped":
 		return runtimeclient.StateExited
 	case "dead":
 		return runtimeclient.StateExited
@@ -0,0 +1,95 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+const (
+	listResponse = `[
+	{"Id": "infra", "Names": ["pod-infra"], "State": "running", "IsInfra": true},
+	{"Id": "abc", "Names": ["mycontainer"], "Image": "docker.io/library/busybox:latest", "State": "running",
+	 "Labels": {"io.kubernetes.pod.name": "mypod", "io.kubernetes.pod.namespace": "default"}}
+]`
+	inspectResponse = `{
+	"Id": "abc",
+	"Name": "mycontainer",
+	"ImageName": "docker.io/library/busybox:latest",
+	"State": {"Status": "running", "Pid": 42, "CgroupPath": "/user.slice/libpod-abc.scope"},
+	"Config": {"Labels": {"io.kubernetes.container.name": "c"}},
+	"Mounts": [{"Source": "/tmp", "Destination": "/data"}]
+}`
+)
+
+func newFakePodman(t *testing.T) string {
+	socketPath := filepath.Join(t.TempDir(), "podman.sock")
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v4.0.0/libpod/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filters") != "" {
+			w.Write([]byte(`[{"Id": "infra", "Names": ["pod-infra"], "State": "running", "IsInfra": true}]`))
+			return
+		}
+		w.Write([]byte(listResponse))
+	})
+	mux.HandleFunc("/v4.0.0/libpod/containers/abc/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(inspectResponse))
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+
+	return socketPath
+}
+
+func TestPodmanClient(t *testing.T) {
+	t.Parallel()
+
+	client := NewPodmanClient(newFakePodman(t))
+	defer client.Close()
+
+	containers, err := client.GetContainers()
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	require.Equal(t, "abc", containers[0].Runtime.ContainerID)
+	require.Equal(t, "mycontainer", containers[0].Runtime.ContainerName)
+	require.Equal(t, "docker.io/library/busybox:latest", containers[0].Runtime.ContainerImageName)
+	require.Equal(t, types.RuntimeNamePodman, containers[0].Runtime.RuntimeName)
+	require.Equal(t, runtimeclient.StateRunning, containers[0].Runtime.State)
+	require.Equal(t, "mypod", containers[0].K8s.PodName)
+	require.Equal(t, "default", containers[0].K8s.Namespace)
+
+	_, err = client.GetContainer("infra")
+	require.ErrorIs(t, err, runtimeclient.ErrPauseContainer)
+
+	details, err := client.GetContainerDetails("podman://abc")
+	require.NoError(t, err)
+	require.Equal(t, 42, details.Pid)
+	require.Equal(t, "/user.slice/libpod-abc.scope", details.CgroupsPath)
+	require.Equal(t, "c", details.K8s.ContainerName)
+	require.Equal(t, []runtimeclient.ContainerMountData{{Source: "/tmp", Destination: "/data"}}, details.Mounts)
+}
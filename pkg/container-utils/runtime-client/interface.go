@@ -37,6 +37,10 @@ type K8sContainerData struct {
 
 	// Unique identifier of pod running the container.
 	PodUID string
+
+	// Annotations of the container and its pod, when the runtime provides
+	// them. The annotations of the container take precedence.
+	Annotations map[string]string
 }
 
 type RuntimeContainerData struct {
@@ -44,6 +48,9 @@ type RuntimeContainerData struct {
 
 	// Current state of the container.
 	State string
+
+	// Labels of the container.
+	Labels map[string]string
 }
 
 // ContainerData contains container information returned from the container
@@ -71,6 +78,9 @@ type ContainerDetailsData struct {
 
 	// List of mounts in the container.
 	Mounts []ContainerMountData
+
+	// Environment variables of the container, in the "key=value" form.
+	Env []string
 }
 
 // ContainerMountData contains mount information in ContainerData.
@@ -0,0 +1,47 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtimeclient
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ResolveSocketPath returns the socket to use to connect to a runtime. If
+// socketPath is empty or the default one and the latter doesn't exist, the
+// socket of a rootless instance of the runtime is used if there is one.
+func ResolveSocketPath(socketPath, defaultSocketPath string, rootlessSocketPaths func() []string) string {
+	if socketPath == "" {
+		socketPath = defaultSocketPath
+	}
+
+	if socketPath != defaultSocketPath {
+		return socketPath
+	}
+	if _, err := os.Stat(socketPath); err == nil {
+		return socketPath
+	}
+
+	rootless := rootlessSocketPaths()
+	if len(rootless) == 0 {
+		return socketPath
+	}
+	if len(rootless) > 1 {
+		log.Warnf("Multiple rootless sockets found (%v). Taking the first one", rootless)
+	}
+	log.Debugf("Socket %q not found, using rootless socket %q", socketPath, rootless[0])
+	return rootless[0]
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package runtimeclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// runtimeDirs returns the runtime directories of the users, i.e.
+// /run/user/<uid>, as seen from the host.
+func runtimeDirs() []string {
+	dirs, _ := filepath.Glob(filepath.Join(host.HostRoot, "/run/user/*"))
+	return dirs
+}
+
+// RootlessDockerSocketPaths returns the sockets of the Docker daemons run by
+// regular users, i.e. $XDG_RUNTIME_DIR/docker.sock.
+func RootlessDockerSocketPaths() []string {
+	paths := []string{}
+	for _, dir := range runtimeDirs() {
+		path := filepath.Join(dir, "docker.sock")
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// RootlessContainerdSocketPaths returns the sockets of the containerd daemons
+// run by regular users with containerd-rootless.sh. The socket is only
+// visible from the mount namespace created by RootlessKit, so it's accessed
+// through the root of its child process.
+func RootlessContainerdSocketPaths() []string {
+	paths := []string{}
+	for _, dir := range runtimeDirs() {
+		content, err := os.ReadFile(filepath.Join(dir, "containerd-rootless", "child_pid"))
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			continue
+		}
+
+		// dir is /run/user/<uid> from the host, take the path without the
+		// host root to look for it in the child mount namespace.
+		uid := filepath.Base(dir)
+		path := filepath.Join(host.HostProcFs, fmt.Sprint(pid), "root", "run", "user", uid, "containerd", "containerd.sock")
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// RootlessPodmanSocketPaths returns the sockets of the podman services run by
+// regular users, i.e. $XDG_RUNTIME_DIR/podman/podman.sock.
+func RootlessPodmanSocketPaths() []string {
+	paths := []string{}
+	for _, dir := range runtimeDirs() {
+		path := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtimeclient_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
+)
+
+func TestResolveSocketPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "default.sock")
+	require.NoError(t, os.WriteFile(existing, nil, 0o600))
+	missing := filepath.Join(dir, "missing.sock")
+
+	rootless := func() []string { return []string{"/run/user/1000/rootless.sock"} }
+	none := func() []string { return nil }
+
+	tests := []struct {
+		name          string
+		socketPath    string
+		defaultPath   string
+		rootlessPaths func() []string
+		expected      string
+	}{
+		{
+			name:          "custom",
+			socketPath:    "/custom.sock",
+			defaultPath:   missing,
+			rootlessPaths: rootless,
+			expected:      "/custom.sock",
+		},
+		{
+			name:          "default_exists",
+			socketPath:    existing,
+			defaultPath:   existing,
+			rootlessPaths: rootless,
+			expected:      existing,
+		},
+		{
+			name:          "default_missing",
+			socketPath:    "",
+			defaultPath:   missing,
+			rootlessPaths: rootless,
+			expected:      "/run/user/1000/rootless.sock",
+		},
+		{
+			name:          "no_rootless",
+			socketPath:    missing,
+			defaultPath:   missing,
+			rootlessPaths: none,
+			expected:      missing,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected,
+				runtimeclient.ResolveSocketPath(test.socketPath, test.defaultPath, test.rootlessPaths))
+		})
+	}
+}
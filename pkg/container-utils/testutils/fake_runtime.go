@@ -0,0 +1,160 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// ErrFakeRuntimeClosed is returned by the calls to a FakeRuntimeClient after
+// it was closed.
+var ErrFakeRuntimeClosed = errors.New("fake container runtime client closed")
+
+// FakeRuntimeClient is an in-memory runtimeclient.ContainerRuntimeClient whose
+// containers are added and removed by the test, to test the code using a
+// container runtime without running one.
+type FakeRuntimeClient struct {
+	name types.RuntimeName
+
+	mu         sync.Mutex
+	containers map[string]*runtimeclient.ContainerDetailsData
+	err        error
+	closed     bool
+}
+
+// NewFakeRuntimeClient returns a FakeRuntimeClient without containers. The
+// containers added to it are reported as run by the given runtime.
+func NewFakeRuntimeClient(name types.RuntimeName) *FakeRuntimeClient {
+	return &FakeRuntimeClient{
+		name:       name,
+		containers: make(map[string]*runtimeclient.ContainerDetailsData),
+	}
+}
+
+// AddContainer adds the container, or replaces the one with the same ID. Its
+// runtime name is set to the one of the client and its state defaults to
+// running.
+func (f *FakeRuntimeClient) AddContainer(container *runtimeclient.ContainerDetailsData) {
+	c := *container
+	c.Runtime.RuntimeName = f.name
+	if c.Runtime.State == "" {
+		c.Runtime.State = runtimeclient.StateRunning
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.containers[c.Runtime.ContainerID] = &c
+}
+
+// RemoveContainer removes the container with the given ID, if any.
+func (f *FakeRuntimeClient) RemoveContainer(containerID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.containers, containerID)
+}
+
+// SetError makes all the calls fail with err, e.g. to test what happens when
+// the runtime is unavailable, until it's called again with nil.
+func (f *FakeRuntimeClient) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.err = err
+}
+
+// Closed tells if Close() has been called.
+func (f *FakeRuntimeClient) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.closed
+}
+
+func (f *FakeRuntimeClient) checkErr() error {
+	if f.closed {
+		return ErrFakeRuntimeClosed
+	}
+	return f.err
+}
+
+func (f *FakeRuntimeClient) GetContainers() ([]*runtimeclient.ContainerData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkErr(); err != nil {
+		return nil, err
+	}
+
+	ret := make([]*runtimeclient.ContainerData, 0, len(f.containers))
+	for _, c := range f.containers {
+		data := c.ContainerData
+		ret = append(ret, &data)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Runtime.ContainerID < ret[j].Runtime.ContainerID
+	})
+	return ret, nil
+}
+
+func (f *FakeRuntimeClient) GetContainer(containerID string) (*runtimeclient.ContainerData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkErr(); err != nil {
+		return nil, err
+	}
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("container %q not found", containerID)
+	}
+	data := c.ContainerData
+	return &data, nil
+}
+
+func (f *FakeRuntimeClient) GetContainerDetails(containerID string) (*runtimeclient.ContainerDetailsData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkErr(); err != nil {
+		return nil, err
+	}
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("container %q not found", containerID)
+	}
+	if c.Runtime.State != runtimeclient.StateRunning {
+		return nil, fmt.Errorf("container %q is not running, its state is %q", containerID, c.Runtime.State)
+	}
+	details := *c
+	return &details, nil
+}
+
+func (f *FakeRuntimeClient) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return nil
+}
@@ -132,8 +132,7 @@ func deleteTrace(name string, t interface{}) {
 		traceSingleton.users--
 		if traceSingleton.users == 0 {
 			trace.helpers.Unsubscribe(genPubSubKey(name))
-			traceSingleton.tracer.Close()
-			traceSingleton.tracer = nil
+			closeTracer()
 		}
 	}
 }
@@ -386,6 +385,28 @@ func getContainerOwnerReference(c *containercollection.Container) *metav1.OwnerR
 	return ownerRef
 }
 
+// closeTracer closes the tracer once it doesn't have any user, the syscalls it
+// recorded aren't needed anymore. Must be called with traceSingleton.mu held.
+func closeTracer() {
+	if err := traceSingleton.tracer.Unpin(); err != nil {
+		log.Warnf("Failed to unpin seccomp map: %s", err)
+	}
+	traceSingleton.tracer.Close()
+	traceSingleton.tracer = nil
+}
+
+// resume starts again a trace that was started before the gadget pod
+// restarted. The tracer reuses its pinned map, so the syscalls recorded until
+// the restart aren't lost.
+func (t *Trace) resume(trace *gadgetv1alpha1.Trace) {
+	if t.started || trace.Status.State != gadgetv1alpha1.TraceStateStarted {
+		return
+	}
+
+	log.Infof("Resuming seccomp trace %s/%s", trace.ObjectMeta.Namespace, trace.ObjectMeta.Name)
+	t.Start(trace)
+}
+
 func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	trace.Status.Output = ""
 	if t.started {
@@ -440,6 +461,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 }
 
 func (t *Trace) Generate(trace *gadgetv1alpha1.Trace) {
+	t.resume(trace)
 	if traceSingleton.tracer == nil {
 		log.Errorf("Seccomp tracer is nil")
 		return
@@ -560,6 +582,7 @@ func (t *Trace) Generate(trace *gadgetv1alpha1.Trace) {
 }
 
 func (t *Trace) Stop(trace *gadgetv1alpha1.Trace) {
+	t.resume(trace)
 	if !t.started {
 		trace.Status.OperationError = "Not started"
 		return
@@ -572,8 +595,7 @@ func (t *Trace) Stop(trace *gadgetv1alpha1.Trace) {
 
 	traceSingleton.users--
 	if traceSingleton.users == 0 {
-		traceSingleton.tracer.Close()
-		traceSingleton.tracer = nil
+		closeTracer()
 	}
 
 	t.started = false
@@ -353,6 +353,18 @@ func (t *Trace) Collect(trace *gadgetv1alpha1.Trace) {
 		return
 	}
 
+	filter, err := types.ParseFilter(
+		trace.Spec.Parameters[types.ParamSyscallFilters],
+		trace.Spec.Parameters[types.ParamMinLatency],
+		trace.Spec.Parameters[types.ParamSince],
+		trace.Spec.Parameters[types.ParamUntil],
+	)
+	if err != nil {
+		trace.Status.OperationError = fmt.Sprintf("Invalid filter: %s", err)
+
+		return
+	}
+
 	traceUnique.Lock()
 	events, err := traceUnique.tracer.Read(containerID)
 	traceUnique.Unlock()
@@ -362,6 +374,8 @@ func (t *Trace) Collect(trace *gadgetv1alpha1.Trace) {
 		return
 	}
 
+	events = types.FilterEvents(events, filter)
+
 	traceName := gadgets.TraceName(trace.ObjectMeta.Namespace, trace.ObjectMeta.Name)
 	r, err := json.Marshal(events)
 	if err != nil {
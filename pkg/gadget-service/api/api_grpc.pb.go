@@ -21,6 +21,7 @@ type GadgetManagerClient interface {
 	GetInfo(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
 	GetGadgetInfo(ctx context.Context, in *GetGadgetInfoRequest, opts ...grpc.CallOption) (*GetGadgetInfoResponse, error)
 	RunGadget(ctx context.Context, opts ...grpc.CallOption) (GadgetManager_RunGadgetClient, error)
+	GetImageCacheUsage(ctx context.Context, in *ImageCacheUsageRequest, opts ...grpc.CallOption) (*ImageCacheUsageResponse, error)
 }
 
 type gadgetManagerClient struct {
@@ -80,6 +81,15 @@ func (x *gadgetManagerRunGadgetClient) Recv() (*GadgetEvent, error) {
 	return m, nil
 }
 
+func (c *gadgetManagerClient) GetImageCacheUsage(ctx context.Context, in *ImageCacheUsageRequest, opts ...grpc.CallOption) (*ImageCacheUsageResponse, error) {
+	out := new(ImageCacheUsageResponse)
+	err := c.cc.Invoke(ctx, "/api.GadgetManager/GetImageCacheUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GadgetManagerServer is the server API for GadgetManager service.
 // All implementations must embed UnimplementedGadgetManagerServer
 // for forward compatibility
@@ -87,6 +97,7 @@ type GadgetManagerServer interface {
 	GetInfo(context.Context, *InfoRequest) (*InfoResponse, error)
 	GetGadgetInfo(context.Context, *GetGadgetInfoRequest) (*GetGadgetInfoResponse, error)
 	RunGadget(GadgetManager_RunGadgetServer) error
+	GetImageCacheUsage(context.Context, *ImageCacheUsageRequest) (*ImageCacheUsageResponse, error)
 	mustEmbedUnimplementedGadgetManagerServer()
 }
 
@@ -103,6 +114,9 @@ func (UnimplementedGadgetManagerServer) GetGadgetInfo(context.Context, *GetGadge
 func (UnimplementedGadgetManagerServer) RunGadget(GadgetManager_RunGadgetServer) error {
 	return status.Errorf(codes.Unimplemented, "method RunGadget not implemented")
 }
+func (UnimplementedGadgetManagerServer) GetImageCacheUsage(context.Context, *ImageCacheUsageRequest) (*ImageCacheUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetImageCacheUsage not implemented")
+}
 func (UnimplementedGadgetManagerServer) mustEmbedUnimplementedGadgetManagerServer() {}
 
 // UnsafeGadgetManagerServer may be embedded to opt out of forward compatibility for this service.
@@ -178,6 +192,24 @@ func (x *gadgetManagerRunGadgetServer) Recv() (*GadgetControlRequest, error) {
 	return m, nil
 }
 
+func _GadgetManager_GetImageCacheUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageCacheUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GadgetManagerServer).GetImageCacheUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.GadgetManager/GetImageCacheUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GadgetManagerServer).GetImageCacheUsage(ctx, req.(*ImageCacheUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // GadgetManager_ServiceDesc is the grpc.ServiceDesc for GadgetManager service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -193,6 +225,10 @@ var GadgetManager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetGadgetInfo",
 			Handler:    _GadgetManager_GetGadgetInfo_Handler,
 		},
+		{
+			MethodName: "GetImageCacheUsage",
+			Handler:    _GadgetManager_GetImageCacheUsage_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
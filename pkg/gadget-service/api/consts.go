@@ -28,3 +28,9 @@ const (
 	GadgetServicePort   = 8080
 	DefaultDaemonPath   = "unix:///var/run/ig/ig.socket"
 )
+
+const (
+	// ParamPriority is the runtime parameter giving the priority of a gadget
+	// when the daemon sheds load. It's sent with the "runtime." prefix.
+	ParamPriority = "priority"
+)
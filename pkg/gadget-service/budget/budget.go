@@ -0,0 +1,402 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package budget keeps the gadget daemon within the memory, CPU and event rate
+// limits given by the user. When the daemon goes above one of them, it sheds
+// load step by step: it first processes only a sample of the events of all the
+// gadgets, sampling more and more sparsely, then pauses the gadgets with the
+// lowest priority, which stop reading their events. The steps are undone in
+// the reverse order once the usage stays low enough. The gadgets are told what
+// was shed through their logger and the metrics.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+const (
+	// checkInterval is how often the usage of the daemon is compared to the
+	// limits
+	checkInterval = time.Second
+
+	// maxSampling is the sampling factor above which gadgets are paused
+	// instead of sampling their events more sparsely
+	maxSampling = 64
+
+	// lowWatermark is the fraction of the limits the usage must stay under,
+	// during recoverChecks consecutive checks, to undo a step. It avoids
+	// flapping between shedding and recovering.
+	lowWatermark  = 0.8
+	recoverChecks = 5
+)
+
+// Limits are the resources the daemon may use. A zero value disables the
+// corresponding limit.
+type Limits struct {
+	// MaxMemory is the resident memory of the daemon in bytes
+	MaxMemory uint64
+	// MaxCPU is the CPU time used by the daemon per second, in cores
+	MaxCPU float64
+	// MaxEventRate is the number of events per second processed by all the
+	// gadgets
+	MaxEventRate uint64
+}
+
+// Enabled returns whether at least one limit is set.
+func (l Limits) Enabled() bool {
+	return l.MaxMemory != 0 || l.MaxCPU != 0 || l.MaxEventRate != 0
+}
+
+// ParseLimits parses the limits as given on the command line: quantities like
+// 512Mi for the memory and 500m or 2 for the CPU. Empty strings disable the
+// limit.
+func ParseLimits(maxMemory, maxCPU string, maxEventRate uint64) (Limits, error) {
+	limits := Limits{MaxEventRate: maxEventRate}
+	if maxMemory != "" {
+		quantity, err := resource.ParseQuantity(maxMemory)
+		if err != nil {
+			return Limits{}, fmt.Errorf("invalid max memory %q: %w", maxMemory, err)
+		}
+		if quantity.Sign() < 0 {
+			return Limits{}, fmt.Errorf("invalid max memory %q: must not be negative", maxMemory)
+		}
+		limits.MaxMemory = uint64(quantity.Value())
+	}
+	if maxCPU != "" {
+		quantity, err := resource.ParseQuantity(maxCPU)
+		if err != nil {
+			return Limits{}, fmt.Errorf("invalid max CPU %q: %w", maxCPU, err)
+		}
+		if quantity.Sign() < 0 {
+			return Limits{}, fmt.Errorf("invalid max CPU %q: must not be negative", maxCPU)
+		}
+		limits.MaxCPU = float64(quantity.MilliValue()) / 1000
+	}
+	return limits, nil
+}
+
+// Budget tracks the gadget instances running in the daemon and sheds their
+// load when the daemon goes above its limits.
+type Budget struct {
+	limits Limits
+	logger logger.Logger
+
+	// readUsage returns the current resident memory of the daemon and the CPU
+	// time it used so far
+	readUsage func() (uint64, time.Duration, error)
+
+	mu        sync.Mutex
+	instances map[*Instance]struct{}
+	// Paused instances, in the order they were paused
+	paused []*Instance
+	// Sampling factor applied to the instances that aren't paused
+	sampling uint64
+	// Number of consecutive checks under the low watermark
+	calmChecks int
+	// Number of instances registered so far, to order the ones with the same
+	// priority
+	registered uint64
+
+	lastCheck time.Time
+	lastCPU   time.Duration
+	// Events processed by all the instances since the last check
+	events atomic.Uint64
+}
+
+// New returns a budget enforcing limits. Shedding only starts once Run is
+// called.
+func New(limits Limits, logger logger.Logger) *Budget {
+	return &Budget{
+		limits:    limits,
+		logger:    logger,
+		readUsage: readProcessUsage,
+		instances: map[*Instance]struct{}{},
+		sampling:  1,
+	}
+}
+
+// Run compares the usage of the daemon to the limits every second, shedding or
+// recovering load, until ctx is done.
+func (b *Budget) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	b.check(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.check(now)
+		}
+	}
+}
+
+// Register adds a gadget instance to the budget. name identifies the gadget in
+// the metrics, and the messages telling what was shed are sent to logger.
+// Instances with a lower priority are paused first, the most recent one first
+// among those with the same priority. Release must be called once the gadget
+// stops.
+func (b *Budget) Register(name string, priority int, logger logger.Logger) *Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.registered++
+	i := &Instance{
+		budget:         b,
+		name:           name,
+		priority:       priority,
+		order:          b.registered,
+		logger:         logger,
+		sampledCounter: shedEventsTotal.WithLabelValues(name, shedReasonSampling),
+		pausedCounter:  shedEventsTotal.WithLabelValues(name, shedReasonPaused),
+	}
+	i.sampling.Store(b.sampling)
+	if b.sampling > 1 {
+		logger.Warnf("the daemon is over its resource budget: only 1 of every %d events is processed", b.sampling)
+	}
+	b.instances[i] = struct{}{}
+	return i
+}
+
+func (b *Budget) release(i *Instance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.instances, i)
+	for idx, paused := range b.paused {
+		if paused == i {
+			b.paused = append(b.paused[:idx], b.paused[idx+1:]...)
+			break
+		}
+	}
+	pausedInstances.Set(float64(len(b.paused)))
+	i.resume()
+}
+
+// pressure returns the highest ratio of usage to limit and the name of the
+// resource it's for.
+func (b *Budget) pressure(now time.Time) (float64, string, error) {
+	memory, cpu, err := b.readUsage()
+	if err != nil {
+		return 0, "", err
+	}
+	events := b.events.Swap(0)
+
+	elapsed := now.Sub(b.lastCheck).Seconds()
+	lastCPU := b.lastCPU
+	first := b.lastCheck.IsZero()
+	b.lastCheck = now
+	b.lastCPU = cpu
+	if first || elapsed <= 0 {
+		return 0, "", nil
+	}
+
+	pressure, limit := 0.0, ""
+	update := func(ratio float64, name string) {
+		if ratio > pressure {
+			pressure, limit = ratio, name
+		}
+	}
+	if b.limits.MaxMemory != 0 {
+		update(float64(memory)/float64(b.limits.MaxMemory), "memory")
+	}
+	if b.limits.MaxCPU != 0 {
+		update((cpu-lastCPU).Seconds()/elapsed/b.limits.MaxCPU, "CPU")
+	}
+	if b.limits.MaxEventRate != 0 {
+		update(float64(events)/elapsed/float64(b.limits.MaxEventRate), "event rate")
+	}
+	return pressure, limit, nil
+}
+
+func (b *Budget) check(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pressure, limit, err := b.pressure(now)
+	if err != nil {
+		b.logger.Warnf("reading resource usage: %v", err)
+		return
+	}
+	budgetPressure.Set(pressure)
+
+	switch {
+	case pressure > 1:
+		b.calmChecks = 0
+		b.shed(limit)
+	case pressure < lowWatermark:
+		b.calmChecks++
+		if b.calmChecks >= recoverChecks {
+			b.calmChecks = 0
+			b.recover()
+		}
+	default:
+		b.calmChecks = 0
+	}
+}
+
+// shed takes the next step to reduce the load: sampling the events more
+// sparsely, up to maxSampling, then pausing the instance with the lowest
+// priority.
+func (b *Budget) shed(limit string) {
+	if b.sampling < maxSampling {
+		b.setSampling(b.sampling * 2)
+		b.logger.Warnf("over the %s budget: processing 1 of every %d events", limit, b.sampling)
+		for i := range b.instances {
+			if !i.isPaused() {
+				i.logger.Warnf("the daemon is over its %s budget: only 1 of every %d events is processed", limit, b.sampling)
+			}
+		}
+		return
+	}
+
+	candidates := make([]*Instance, 0, len(b.instances))
+	for i := range b.instances {
+		if !i.isPaused() {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		b.logger.Warnf("over the %s budget with all the gadgets paused", limit)
+		return
+	}
+	sort.Slice(candidates, func(x, y int) bool {
+		if candidates[x].priority != candidates[y].priority {
+			return candidates[x].priority < candidates[y].priority
+		}
+		return candidates[x].order > candidates[y].order
+	})
+
+	i := candidates[0]
+	i.pause()
+	b.paused = append(b.paused, i)
+	pausedInstances.Set(float64(len(b.paused)))
+	b.logger.Warnf("over the %s budget: pausing gadget %s with priority %d", limit, i.name, i.priority)
+	i.logger.Warnf("the daemon is over its %s budget: gadget paused, its events aren't read and are lost", limit)
+}
+
+// recover undoes the last step taken by shed.
+func (b *Budget) recover() {
+	if len(b.paused) > 0 {
+		i := b.paused[len(b.paused)-1]
+		b.paused = b.paused[:len(b.paused)-1]
+		pausedInstances.Set(float64(len(b.paused)))
+		i.sampling.Store(b.sampling)
+		i.resume()
+		b.logger.Infof("back under budget: resuming gadget %s", i.name)
+		i.logger.Infof("the daemon is back under its resource budget: gadget resumed")
+		return
+	}
+
+	if b.sampling > 1 {
+		b.setSampling(b.sampling / 2)
+		b.logger.Infof("back under budget: processing 1 of every %d events", b.sampling)
+		for i := range b.instances {
+			if b.sampling == 1 {
+				i.logger.Infof("the daemon is back under its resource budget: all the events are processed")
+			} else {
+				i.logger.Infof("the daemon is back under its resource budget: 1 of every %d events is processed", b.sampling)
+			}
+		}
+	}
+}
+
+func (b *Budget) setSampling(sampling uint64) {
+	b.sampling = sampling
+	samplingFactor.Set(float64(sampling))
+	for i := range b.instances {
+		i.sampling.Store(sampling)
+	}
+}
+
+// Instance is a gadget instance registered in a budget.
+type Instance struct {
+	budget   *Budget
+	name     string
+	priority int
+	order    uint64
+	logger   logger.Logger
+
+	sampling atomic.Uint64
+	// resumed is closed when the instance is resumed. It's nil while the
+	// instance isn't paused.
+	resumed atomic.Pointer[chan struct{}]
+	// Events received from the gadget, admitted or not
+	seen atomic.Uint64
+	// Events dropped
+	shed atomic.Uint64
+
+	sampledCounter counter
+	pausedCounter  counter
+}
+
+func (i *Instance) isPaused() bool {
+	return i.resumed.Load() != nil
+}
+
+func (i *Instance) pause() {
+	resumed := make(chan struct{})
+	i.resumed.Store(&resumed)
+}
+
+func (i *Instance) resume() {
+	if resumed := i.resumed.Swap(nil); resumed != nil {
+		close(*resumed)
+	}
+}
+
+// Admit returns whether the event the gadget just emitted must be processed or
+// dropped to shed load. It must be called for every event, before enriching
+// it, from the goroutine reading the events of the gadget: while the instance
+// is paused, it blocks until the instance is resumed or ctx is done, for the
+// gadget to stop reading its events. Those are then lost in the kernel, and
+// reported as such by the gadget.
+func (i *Instance) Admit(ctx context.Context) bool {
+	n := i.seen.Add(1)
+	if resumed := i.resumed.Load(); resumed != nil {
+		select {
+		case <-*resumed:
+		case <-ctx.Done():
+			i.shed.Add(1)
+			i.pausedCounter.Inc()
+			return false
+		}
+	}
+	if sampling := i.sampling.Load(); sampling > 1 && n%sampling != 0 {
+		i.shed.Add(1)
+		i.sampledCounter.Inc()
+		return false
+	}
+	i.budget.events.Add(1)
+	return true
+}
+
+// Release removes the instance from the budget and reports the number of
+// events that were dropped, if any.
+func (i *Instance) Release() {
+	i.budget.release(i)
+	if shed := i.shed.Load(); shed > 0 {
+		i.logger.Warnf("%d of %d events were dropped to keep the daemon within its resource budget", shed, i.seen.Load())
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("512Mi", "500m", 1000)
+	require.NoError(t, err)
+	require.Equal(t, Limits{MaxMemory: 512 << 20, MaxCPU: 0.5, MaxEventRate: 1000}, limits)
+	require.True(t, limits.Enabled())
+
+	limits, err = ParseLimits("", "", 0)
+	require.NoError(t, err)
+	require.False(t, limits.Enabled())
+
+	_, err = ParseLimits("foo", "", 0)
+	require.Error(t, err)
+	_, err = ParseLimits("", "-1", 0)
+	require.Error(t, err)
+}
+
+func admitted(i *Instance, events int) int {
+	// The paused instances don't wait to be resumed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	n := 0
+	for j := 0; j < events; j++ {
+		if i.Admit(ctx) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBudgetShedAndRecover(t *testing.T) {
+	memory := uint64(0)
+	b := New(Limits{MaxMemory: 100}, logger.DefaultLogger())
+	b.readUsage = func() (uint64, time.Duration, error) {
+		return memory, 0, nil
+	}
+
+	now := time.Now()
+	tick := func() {
+		now = now.Add(checkInterval)
+		b.check(now)
+	}
+	tick()
+
+	low := b.Register("low", -1, logger.DefaultLogger())
+	defer low.Release()
+	high := b.Register("high", 1, logger.DefaultLogger())
+	defer high.Release()
+
+	require.Equal(t, 64, admitted(low, 64))
+
+	// Over budget: the sampling doubles on each check
+	memory = 200
+	tick()
+	require.Equal(t, 32, admitted(low, 64))
+	require.Equal(t, 32, admitted(high, 64))
+	for b.sampling < maxSampling {
+		tick()
+	}
+	require.Equal(t, 1, admitted(high, 64))
+
+	// Then the instance with the lowest priority is paused
+	tick()
+	require.True(t, low.isPaused())
+	require.False(t, high.isPaused())
+	require.Equal(t, 0, admitted(low, 64))
+	tick()
+	require.True(t, high.isPaused())
+
+	// Admitting an event of a paused instance waits for it to be resumed
+	admittedHigh := make(chan bool)
+	go func() {
+		admittedHigh <- high.Admit(context.Background())
+	}()
+
+	// Between the watermarks, nothing changes
+	memory = 90
+	for j := 0; j < 2*recoverChecks; j++ {
+		tick()
+	}
+	require.True(t, high.isPaused())
+	select {
+	case <-admittedHigh:
+		t.Fatal("event of a paused instance admitted")
+	default:
+	}
+
+	// Under the low watermark, the steps are undone in the reverse order
+	memory = 10
+	for j := 0; j < recoverChecks; j++ {
+		tick()
+	}
+	require.False(t, high.isPaused())
+	<-admittedHigh
+	require.True(t, low.isPaused())
+	for j := 0; j < recoverChecks; j++ {
+		tick()
+	}
+	require.False(t, low.isPaused())
+	require.Equal(t, uint64(maxSampling), b.sampling)
+	for b.sampling > 1 {
+		tick()
+	}
+	require.Equal(t, 64, admitted(low, 64))
+	require.Equal(t, 64, admitted(high, 64))
+}
+
+func TestBudgetEventRate(t *testing.T) {
+	b := New(Limits{MaxEventRate: 10}, logger.DefaultLogger())
+	b.readUsage = func() (uint64, time.Duration, error) {
+		return 0, 0, nil
+	}
+
+	now := time.Now()
+	b.check(now)
+
+	i := b.Register("gadget", 0, logger.DefaultLogger())
+	admitted(i, 20)
+	b.check(now.Add(time.Second))
+	require.Equal(t, uint64(2), b.sampling)
+
+	// The dropped events don't count in the rate
+	admitted(i, 20)
+	b.check(now.Add(2 * time.Second))
+	require.Equal(t, uint64(2), b.sampling)
+
+	// Released instances are removed from the budget
+	i.Release()
+	require.Empty(t, b.instances)
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package budget
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	shedReasonSampling = "sampling"
+	shedReasonPaused   = "paused"
+)
+
+// counter is the part of prometheus.Counter used by the instances
+type counter interface {
+	Inc()
+}
+
+var (
+	shedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gadget_shed_events_total",
+		Help: "Number of events dropped to keep the daemon within its resource budget, by sampling or because the gadget was paused",
+	}, []string{"gadget", "reason"})
+
+	samplingFactor = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gadget_budget_sampling_factor",
+		Help: "Only 1 of every this many events of the gadgets is processed to keep the daemon within its resource budget",
+	})
+
+	pausedInstances = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gadget_budget_paused_instances",
+		Help: "Number of gadget instances paused to keep the daemon within its resource budget",
+	})
+
+	budgetPressure = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gadget_budget_pressure",
+		Help: "Highest ratio of the resource usage of the daemon to its limit, above 1 when load is being shed",
+	})
+)
@@ -0,0 +1,51 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package budget
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// readProcessUsage returns the resident memory of the process, read from
+// /proc/self/statm, and the CPU time it used so far.
+func readProcessUsage() (uint64, time.Duration, error) {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("unexpected content of /proc/self/statm: %q", data)
+	}
+	residentPages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing resident pages: %w", err)
+	}
+
+	var rusage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &rusage); err != nil {
+		return 0, 0, fmt.Errorf("getting CPU usage: %w", err)
+	}
+	cpu := time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano())
+
+	return residentPages * uint64(os.Getpagesize()), cpu, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgetservice
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/parser"
+)
+
+var (
+	lostSamplesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gadget_lost_samples_total",
+		Help: "Number of events lost by the kernel because the buffer of the gadget was full",
+	}, []string{"gadget"})
+
+	droppedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gadget_dropped_events_total",
+		Help: "Number of events dropped because the client didn't read them fast enough",
+	}, []string{"gadget"})
+)
+
+// metricsGadgetLabel returns the value of the gadget label of the metrics: the
+// image of the gadgets run from an image, the category and name of the other
+// ones.
+func metricsGadgetLabel(request *api.GadgetRunRequest, isRunGadget bool) string {
+	if isRunGadget && len(request.Args) > 0 {
+		return request.Args[0]
+	}
+	return request.GadgetCategory + "/" + request.GadgetName
+}
+
+// countLostSamples adds the events reported as lost by ev to the metrics of
+// the gadget.
+func countLostSamples(gadget string, ev any) {
+	getter, ok := ev.(parser.LostSamplesGetter)
+	if !ok || getter.GetLostSamples() == 0 {
+		return
+	}
+	lostSamplesTotal.WithLabelValues(gadget).Add(float64(getter.GetLostSamples()))
+}
+
+// isLostSamples returns whether ev reports lost events. Those are never shed.
+func isLostSamples(ev any) bool {
+	getter, ok := ev.(parser.LostSamplesGetter)
+	return ok && getter.GetLostSamples() > 0
+}
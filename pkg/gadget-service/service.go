@@ -22,6 +22,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -32,10 +33,13 @@ import (
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/budget"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	runTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/prometheus"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/local"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/experimental"
@@ -52,14 +56,37 @@ type RunConfig struct {
 	// If SocketGID != 0 and a unix socket is used, the ownership of that socket
 	// will be changed to the given SocketGID
 	SocketGID int
+
+	// If ImageCacheMaxSize != 0, the least recently used gadget images are
+	// removed from the local store every ImageCacheGCInterval when the size of
+	// the store is above ImageCacheMaxSize bytes
+	ImageCacheMaxSize    int64
+	ImageCacheGCInterval time.Duration
+
+	// PreloadImages are pulled and verified in the background when the
+	// service starts, using PreloadAuthOptions to access the registries
+	PreloadImages      []string
+	PreloadAuthOptions *oci.AuthOptions
+
+	// If MetricsListenAddress isn't empty, the metrics of the gadgets, like
+	// the number of events they lost, are served on it
+	MetricsListenAddress string
+
+	// If Budget has a limit set, the events of the gadgets are sampled and
+	// the gadgets with the lowest priority paused when the daemon goes above
+	// it
+	Budget budget.Limits
 }
 
 type Service struct {
 	api.UnimplementedGadgetManagerServer
-	listener net.Listener
-	runtime  runtime.Runtime
-	logger   logger.Logger
-	servers  map[*grpc.Server]struct{}
+	listener          net.Listener
+	runtime           runtime.Runtime
+	logger            logger.Logger
+	servers           map[*grpc.Server]struct{}
+	imageCacheMaxSize int64
+	budget            *budget.Budget
+	stopBackground    context.CancelFunc
 }
 
 func NewService(defaultLogger logger.Logger) *Service {
@@ -110,6 +137,28 @@ func (s *Service) GetGadgetInfo(ctx context.Context, req *api.GetGadgetInfoReque
 	}, nil
 }
 
+func (s *Service) GetImageCacheUsage(ctx context.Context, req *api.ImageCacheUsageRequest) (*api.ImageCacheUsageResponse, error) {
+	usage, err := oci.GetCacheUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting image cache usage: %w", err)
+	}
+
+	images := make([]*api.CachedImage, 0, len(usage.Images))
+	for _, image := range usage.Images {
+		images = append(images, &api.CachedImage{
+			References: image.References,
+			Digest:     image.Digest,
+			Size:       image.Size,
+			LastUsed:   image.LastUsed.UnixNano(),
+		})
+	}
+	return &api.ImageCacheUsageResponse{
+		Size:    usage.Size,
+		MaxSize: s.imageCacheMaxSize,
+		Images:  images,
+	}, nil
+}
+
 func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 	ctrl, err := runGadget.Recv()
 	if err != nil {
@@ -157,11 +206,26 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 		return fmt.Errorf("setting parameters: %w", err)
 	}
 
-	if c, ok := gadgetDesc.(runTypes.RunGadgetDesc); ok {
+	priority := 0
+	if value := request.Params["runtime."+api.ParamPriority]; value != "" {
+		priority, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid priority %q: %w", value, err)
+		}
+	}
+
+	c, isRunGadget := gadgetDesc.(runTypes.RunGadgetDesc)
+	if isRunGadget {
 		gadgetInfo, err := s.runtime.GetGadgetInfo(runGadget.Context(), gadgetDesc, gadgetParams, request.Args)
 		if err != nil {
 			return fmt.Errorf("getting gadget info: %w", err)
 		}
+		// The params of the gadget are only known once its image is read
+		ebpfParams := gadgetInfo.GadgetMetadata.ParamDescs().ToParams()
+		if err := ebpfParams.CopyFromMap(request.Params, ""); err != nil {
+			return fmt.Errorf("setting gadget parameters: %w", err)
+		}
+		gadgetParams.Add(*ebpfParams...)
 		parser, err = c.CustomParser(gadgetInfo)
 		if err != nil {
 			return fmt.Errorf("calling custom parser: %w", err)
@@ -174,14 +238,25 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 	seq := uint32(0)
 	var seqLock sync.Mutex
 
+	var budgetInstance *budget.Instance
+
 	if parser != nil {
 		outputDone := make(chan bool)
 		defer func() {
 			outputDone <- true
 		}()
 
+		gadgetLabel := metricsGadgetLabel(request, isRunGadget)
+
+		if s.budget != nil {
+			budgetInstance = s.budget.Register(gadgetLabel, priority, logger)
+			defer budgetInstance.Release()
+		}
+
 		parser.SetLogCallback(logger.Logf)
 		parser.SetEventCallback(func(ev any) {
+			countLostSamples(gadgetLabel, ev)
+
 			// Marshal messages to JSON
 			// Normally, it would be better to have this in the pump below rather than marshaling events that
 			// would be dropped anyway. However, we're optimistic that this occurs rarely and instead prevent using
@@ -201,6 +276,7 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 			select {
 			case outputBuffer <- event:
 			default:
+				droppedEventsTotal.WithLabelValues(gadgetLabel).Inc()
 			}
 			seqLock.Unlock()
 		})
@@ -247,6 +323,16 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 	)
 	defer gadgetCtx.Cancel()
 
+	if budgetInstance != nil {
+		// Shed load before the events are enriched. The admission must stop
+		// waiting once the gadget stops, including on timeout.
+		admissionCtx, cancel := gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
+		defer cancel()
+		parser.SetEventAdmission(func(ev any) bool {
+			return isLostSamples(ev) || budgetInstance.Admit(admissionCtx)
+		})
+	}
+
 	// Handle commands sent by the client
 	go func() {
 		defer func() {
@@ -351,6 +437,33 @@ func (s *Service) Run(runConfig RunConfig, serverOptions ...grpc.ServerOption) e
 		return fmt.Errorf("invalid socket type: %s", runConfig.SocketType)
 	}
 
+	// Tasks running in the background until the service is closed
+	var ctx context.Context
+	ctx, s.stopBackground = context.WithCancel(context.Background())
+
+	if runConfig.ImageCacheMaxSize != 0 {
+		s.imageCacheMaxSize = runConfig.ImageCacheMaxSize
+		go oci.RunGarbageCollector(ctx, runConfig.ImageCacheMaxSize, runConfig.ImageCacheGCInterval)
+	}
+
+	if runConfig.Budget.Enabled() {
+		s.budget = budget.New(runConfig.Budget, s.logger)
+		go s.budget.Run(ctx)
+	}
+
+	if runConfig.MetricsListenAddress != "" {
+		prometheus.ServeMetrics(runConfig.MetricsListenAddress, prometheus.DefaultMetricsPath)
+	}
+
+	if len(runConfig.PreloadImages) > 0 {
+		go func() {
+			err := oci.PreloadGadgetImages(ctx, runConfig.PreloadImages, runConfig.PreloadAuthOptions)
+			if err != nil {
+				s.logger.Warnf("preloading gadget images: %v", err)
+			}
+		}()
+	}
+
 	server := grpc.NewServer(serverOptions...)
 	api.RegisterGadgetManagerServer(server, s)
 
@@ -360,6 +473,9 @@ func (s *Service) Run(runConfig RunConfig, serverOptions ...grpc.ServerOption) e
 }
 
 func (s *Service) Close() {
+	if s.stopBackground != nil {
+		s.stopBackground()
+	}
 	for server := range s.servers {
 		server.Stop()
 		delete(s.servers, server)
@@ -0,0 +1,303 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// The following types are the subset of the AdminNetworkPolicy and
+// BaselineAdminNetworkPolicy resources (policy.networking.k8s.io/v1alpha1)
+// used by the advisor. They are defined here to avoid depending on the
+// network-policy-api module.
+
+type AdminNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec AdminNetworkPolicySpec `json:"spec"`
+}
+
+type AdminNetworkPolicySpec struct {
+	Priority int32                     `json:"priority"`
+	Subject  AdminNetworkPolicySubject `json:"subject"`
+	Ingress  []AdminNetworkPolicyRule  `json:"ingress,omitempty"`
+	Egress   []AdminNetworkPolicyRule  `json:"egress,omitempty"`
+}
+
+type BaselineAdminNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec BaselineAdminNetworkPolicySpec `json:"spec"`
+}
+
+type BaselineAdminNetworkPolicySpec struct {
+	Subject AdminNetworkPolicySubject `json:"subject"`
+	Ingress []AdminNetworkPolicyRule  `json:"ingress,omitempty"`
+	Egress  []AdminNetworkPolicyRule  `json:"egress,omitempty"`
+}
+
+type AdminNetworkPolicySubject struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+}
+
+// AdminNetworkPolicyRule is an ingress or egress rule, with the peers in From
+// or To respectively
+type AdminNetworkPolicyRule struct {
+	Name   string                   `json:"name"`
+	Action string                   `json:"action"`
+	From   []AdminNetworkPolicyPeer `json:"from,omitempty"`
+	To     []AdminNetworkPolicyPeer `json:"to,omitempty"`
+	Ports  []AdminNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+type AdminNetworkPolicyPeer struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+	Networks   []string              `json:"networks,omitempty"`
+}
+
+type AdminNetworkPolicyPort struct {
+	PortNumber *AdminNetworkPolicyPortNumber `json:"portNumber,omitempty"`
+	PortRange  *AdminNetworkPolicyPortRange  `json:"portRange,omitempty"`
+	NamedPort  *string                       `json:"namedPort,omitempty"`
+}
+
+type AdminNetworkPolicyPortNumber struct {
+	Protocol string `json:"protocol"`
+	Port     int32  `json:"port"`
+}
+
+type AdminNetworkPolicyPortRange struct {
+	Protocol string `json:"protocol"`
+	Start    int32  `json:"start"`
+	End      int32  `json:"end"`
+}
+
+// DefaultAdminPriority is the priority of the generated AdminNetworkPolicy
+// resources. The policies with the lowest priority are applied first.
+const DefaultAdminPriority = 50
+
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+func namespaceSelector(namespace string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{namespaceNameLabel: namespace},
+	}
+}
+
+// adminRules aggregates the rules of the network policies of a namespace by
+// the namespace or the CIDR of their peers, ignoring the pod labels
+type adminRules map[string]*AdminNetworkPolicyRule
+
+func (r adminRules) add(namespace string, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort, ingress bool) {
+	for _, peer := range peers {
+		var name string
+		var adminPeer AdminNetworkPolicyPeer
+		switch {
+		case peer.IPBlock != nil:
+			// The ingress rules can only select pods by their
+			// namespace
+			if ingress {
+				continue
+			}
+			name = "allow-to-" + peer.IPBlock.CIDR
+			adminPeer.Networks = []string{peer.IPBlock.CIDR}
+		default:
+			ns := namespace
+			if peer.NamespaceSelector != nil {
+				ns = peer.NamespaceSelector.MatchLabels[namespaceNameLabel]
+			}
+			name = "allow-to-" + ns
+			if ingress {
+				name = "allow-from-" + ns
+			}
+			adminPeer.Namespaces = namespaceSelector(ns)
+		}
+
+		rule, ok := r[name]
+		if !ok {
+			rule = &AdminNetworkPolicyRule{Name: name, Action: "Allow"}
+			if ingress {
+				rule.From = []AdminNetworkPolicyPeer{adminPeer}
+			} else {
+				rule.To = []AdminNetworkPolicyPeer{adminPeer}
+			}
+			r[name] = rule
+		}
+		for _, port := range ports {
+			rule.Ports = appendAdminPort(rule.Ports, port)
+		}
+	}
+}
+
+// appendAdminPort adds the given port to ports if it isn't there yet
+func appendAdminPort(ports []AdminNetworkPolicyPort, port networkingv1.NetworkPolicyPort) []AdminNetworkPolicyPort {
+	adminPort := AdminNetworkPolicyPort{}
+	switch {
+	case port.Port.Type == intstr.String:
+		name := port.Port.StrVal
+		adminPort.NamedPort = &name
+	case port.EndPort != nil:
+		adminPort.PortRange = &AdminNetworkPolicyPortRange{
+			Protocol: string(*port.Protocol),
+			Start:    int32(port.Port.IntValue()),
+			End:      *port.EndPort,
+		}
+	default:
+		adminPort.PortNumber = &AdminNetworkPolicyPortNumber{
+			Protocol: string(*port.Protocol),
+			Port:     int32(port.Port.IntValue()),
+		}
+	}
+	for _, p := range ports {
+		if adminPortKey(p) == adminPortKey(adminPort) {
+			return ports
+		}
+	}
+	ports = append(ports, adminPort)
+	sort.Slice(ports, func(i, j int) bool {
+		return adminPortKey(ports[i]) < adminPortKey(ports[j])
+	})
+	return ports
+}
+
+func adminPortKey(p AdminNetworkPolicyPort) string {
+	if p.NamedPort != nil {
+		return *p.NamedPort
+	}
+	if p.PortRange != nil {
+		return fmt.Sprintf("%s/%05d-%05d", p.PortRange.Protocol, p.PortRange.Start, p.PortRange.End)
+	}
+	return fmt.Sprintf("%s/%05d", p.PortNumber.Protocol, p.PortNumber.Port)
+}
+
+func (r adminRules) sorted() []AdminNetworkPolicyRule {
+	rules := make([]AdminNetworkPolicyRule, 0, len(r))
+	for _, rule := range r {
+		rules = append(rules, *rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+	return rules
+}
+
+// adminPolicies aggregates the network policies generated by
+// GeneratePolicies() by namespace. They give an AdminNetworkPolicy for each
+// namespace allowing the traffic of its pods, and a BaselineAdminNetworkPolicy
+// denying the rest of the traffic of these namespaces.
+func adminPolicies(policies []networkingv1.NetworkPolicy, priority int32) []metav1.Object {
+	ingress := map[string]adminRules{}
+	egress := map[string]adminRules{}
+	// Only the traffic of the types of the policies is denied
+	policyTypes := map[networkingv1.PolicyType]struct{}{}
+	for _, p := range policies {
+		for _, t := range p.Spec.PolicyTypes {
+			policyTypes[t] = struct{}{}
+		}
+		if ingress[p.Namespace] == nil {
+			ingress[p.Namespace] = adminRules{}
+			egress[p.Namespace] = adminRules{}
+		}
+		for _, r := range p.Spec.Ingress {
+			ingress[p.Namespace].add(p.Namespace, r.From, r.Ports, true)
+		}
+		for _, r := range p.Spec.Egress {
+			egress[p.Namespace].add(p.Namespace, r.To, r.Ports, false)
+		}
+	}
+	if len(ingress) == 0 {
+		return nil
+	}
+
+	namespaces := make([]string, 0, len(ingress))
+	for ns := range ingress {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	ret := []metav1.Object{}
+	for _, ns := range namespaces {
+		ret = append(ret, &AdminNetworkPolicy{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "AdminNetworkPolicy",
+				APIVersion: "policy.networking.k8s.io/v1alpha1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: ns + "-network",
+			},
+			Spec: AdminNetworkPolicySpec{
+				Priority: priority,
+				Subject:  AdminNetworkPolicySubject{Namespaces: namespaceSelector(ns)},
+				Ingress:  ingress[ns].sorted(),
+				Egress:   egress[ns].sorted(),
+			},
+		})
+	}
+
+	// There can be a single BaselineAdminNetworkPolicy, named default
+	baseline := &BaselineAdminNetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "BaselineAdminNetworkPolicy",
+			APIVersion: "policy.networking.k8s.io/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+		Spec: BaselineAdminNetworkPolicySpec{
+			Subject: AdminNetworkPolicySubject{
+				Namespaces: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      namespaceNameLabel,
+							Operator: metav1.LabelSelectorOpIn,
+							Values:   namespaces,
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, ok := policyTypes[networkingv1.PolicyTypeIngress]; ok {
+		baseline.Spec.Ingress = []AdminNetworkPolicyRule{
+			{
+				Name:   "deny-all",
+				Action: "Deny",
+				From:   []AdminNetworkPolicyPeer{{Namespaces: &metav1.LabelSelector{}}},
+			},
+		}
+	}
+	if _, ok := policyTypes[networkingv1.PolicyTypeEgress]; ok {
+		baseline.Spec.Egress = []AdminNetworkPolicyRule{
+			{
+				Name:   "deny-all",
+				Action: "Deny",
+				To: []AdminNetworkPolicyPeer{
+					{Namespaces: &metav1.LabelSelector{}},
+					{Networks: []string{"0.0.0.0/0", "::/0"}},
+				},
+			},
+		}
+	}
+	ret = append(ret, baseline)
+
+	return ret
+}
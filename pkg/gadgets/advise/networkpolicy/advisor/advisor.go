@@ -18,17 +18,23 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
+	"golang.org/x/exp/slices"
 	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	k8syaml "sigs.k8s.io/yaml"
 
+	dnstypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
@@ -39,17 +45,176 @@ var defaultLabelsToIgnore = map[string]struct{}{
 	"pod-template-hash":        {},
 }
 
+// Formats of the policies given by FormatPolicies()
+const (
+	// OutputFormatKubernetes gives networking.k8s.io/v1 NetworkPolicy
+	// resources
+	OutputFormatKubernetes = "kubernetes"
+	// OutputFormatCilium gives cilium.io/v2 CiliumNetworkPolicy resources
+	OutputFormatCilium = "cilium"
+	// OutputFormatCalico gives projectcalico.org/v3 NetworkPolicy resources
+	OutputFormatCalico = "calico"
+	// OutputFormatCalicoGlobal gives projectcalico.org/v3
+	// GlobalNetworkPolicy resources
+	OutputFormatCalicoGlobal = "calico-global"
+	// OutputFormatAdmin gives policy.networking.k8s.io/v1alpha1
+	// AdminNetworkPolicy resources for each namespace and a
+	// BaselineAdminNetworkPolicy
+	OutputFormatAdmin = "admin"
+)
+
+var OutputFormats = []string{
+	OutputFormatKubernetes,
+	OutputFormatCilium,
+	OutputFormatCalico,
+	OutputFormatCalicoGlobal,
+	OutputFormatAdmin,
+}
+
+// DefaultDenyPolicyName is the name of the policies denying the traffic of all
+// the pods of a namespace by default. A numbered suffix is added to it if
+// another policy of the namespace has it.
+const DefaultDenyPolicyName = "default-deny"
+
+// DefaultPolicyTypes are the types of the generated policies: ingress and
+// egress
+var DefaultPolicyTypes = []networkingv1.PolicyType{
+	networkingv1.PolicyTypeIngress,
+	networkingv1.PolicyTypeEgress,
+}
+
+// DefaultPortRangeThreshold is the number of contiguous ports of a peer above
+// which they are allowed by a single rule with a port range
+const DefaultPortRangeThreshold = 10
+
 type NetworkPolicyAdvisor struct {
 	Events []types.Event
 
+	// DNSEvents are the events of the trace dns gadget, giving the names
+	// the pods connect to
+	DNSEvents []dnstypes.Event
+
+	// LabelsToIgnore are the keys of the labels ignored to group the pods
+	// and to select the peers. IgnoreLabels() also adds patterns.
 	LabelsToIgnore map[string]struct{}
 
+	// PortRangeThreshold is the minimum number of contiguous ports of a peer,
+	// with the same protocol, allowed by a single rule using endPort instead
+	// of a rule for each port. 0 disables the port ranges.
+	PortRangeThreshold int
+
+	// OutputFormat is the format of the policies given by FormatPolicies(),
+	// one of OutputFormats
+	OutputFormat string
+
+	// CalicoOrder is the order of the Calico policies
+	CalicoOrder float64
+
+	// AdminPriority is the priority of the AdminNetworkPolicy resources
+	AdminPriority int32
+
+	// MergeRules merges the rules allowing the same peers or the same ports
+	MergeRules bool
+
+	// ExcludeSystemTraffic excludes the traffic from and to the namespaces
+	// of ExcludedNamespaces and to the addresses of ExcludedCIDRs
+	ExcludeSystemTraffic bool
+	ExcludedNamespaces   []string
+	ExcludedCIDRs        []string
+
+	// SharedDNSPolicy allows, in the policy of each pod, the DNS queries to
+	// the DNS servers queried by any pod of its namespace, with a single rule
+	// shared by all of them, instead of the ones of the pod only
+	SharedDNSPolicy bool
+
+	// NodeCIDRs are the CIDRs of the nodes, allowed instead of the address
+	// of a node or of a hostNetwork pod. Without them, the policies
+	// allowing these addresses are annotated with a warning.
+	NodeCIDRs []string
+
+	// Pods are the pods of the cluster. The ports of the rules matching a
+	// named container port of the pod they apply to are given by name.
+	Pods []v1.Pod
+
+	// Workloads are the ReplicaSets, Deployments, StatefulSets and
+	// DaemonSets owning Pods, used to group the pods by workload with
+	// GroupByOwner
+	Workloads []metav1.Object
+
+	// GroupBy is how the pods are grouped in policies, one of
+	// GroupByStrategies
+	GroupBy string
+
+	// PolicyTypes are the types of the generated policies, with only the
+	// rules of these types. The other traffic isn't restricted. Empty
+	// means both.
+	PolicyTypes []networkingv1.PolicyType
+
+	// StartTime and EndTime restrict the policies to the events seen
+	// between them, when they aren't zero. MaxEventAge restricts them to
+	// the events younger than it, when it isn't zero, for the connections
+	// recorded long ago not to be allowed anymore. The events without
+	// timestamp are always used.
+	StartTime   time.Time
+	EndTime     time.Time
+	MaxEventAge time.Duration
+
+	// DefaultDenyPolicy adds a policy to each namespace, named
+	// DefaultDenyPolicyName, denying the ingress and egress traffic of all
+	// its pods but the one allowed by the other policies, whatever
+	// PolicyTypes is. Without it, the pods without policy aren't
+	// restricted.
+	DefaultDenyPolicy bool
+
+	// CoverageAnnotations annotates the policies with the number of events
+	// allowed by each of their rules and when they were seen
+	CoverageAnnotations bool
+
+	// MinEventCount is the minimum number of events of a connection, with
+	// the same peer, port and protocol, for it to be allowed. The
+	// connections seen fewer times, like one-off connections, are dropped.
+	MinEventCount int
+
+	// MaxSeenEvents is the number of events AddEvent() remembers to skip
+	// their duplicates, DefaultMaxSeenEvents by default. 0 disables it.
+	MaxSeenEvents int
+
 	Policies []networkingv1.NetworkPolicy
+
+	// fqdns are the names resolved to the CIDRs of the egress rules of the
+	// policies, by namespace/name of the policy and by CIDR
+	fqdns map[string]map[string][]string
+
+	// nodeIPs are the addresses of the nodes of the events
+	nodeIPs map[string]struct{}
+
+	// labelsToIgnorePatterns are the patterns given to IgnoreLabels()
+	labelsToIgnorePatterns []*regexp.Regexp
+
+	// seenEvents are the last events added with AddEvent(), to skip their
+	// duplicates
+	seenEvents *seenEvents
+
+	// namedPorts are the named container ports of Pods
+	namedPorts namedPorts
+
+	// workloads are the workloads owning Pods
+	workloads workloads
 }
 
 func NewAdvisor() *NetworkPolicyAdvisor {
 	return &NetworkPolicyAdvisor{
-		LabelsToIgnore: defaultLabelsToIgnore,
+		LabelsToIgnore:     defaultLabelsToIgnore,
+		PortRangeThreshold: DefaultPortRangeThreshold,
+		OutputFormat:       OutputFormatKubernetes,
+		CalicoOrder:        DefaultCalicoOrder,
+		AdminPriority:      DefaultAdminPriority,
+		MergeRules:         true,
+		GroupBy:            GroupByLabels,
+		PolicyTypes:        DefaultPolicyTypes,
+		ExcludedNamespaces: DefaultExcludedNamespaces,
+		ExcludedCIDRs:      DefaultExcludedCIDRs,
+		MaxSeenEvents:      DefaultMaxSeenEvents,
 	}
 }
 
@@ -62,12 +227,125 @@ func (a *NetworkPolicyAdvisor) LoadFile(filename string) error {
 }
 
 func (a *NetworkPolicyAdvisor) LoadBuffer(buf []byte) error {
+	events, err := parseEvents[types.Event](buf)
+	if err != nil {
+		return err
+	}
+	// All the events of a recording are counted, even the identical ones
+	a.Events = events
+	a.seenEvents = nil
+	return nil
+}
+
+// AddEvents adds the events of buf to the ones already loaded, for the
+// policies to be refined as new events are seen
+func (a *NetworkPolicyAdvisor) AddEvents(buf []byte) error {
+	events, err := parseEvents[types.Event](buf)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		a.AddEvent(e)
+	}
+	return nil
+}
+
+// AddEvent adds an event to the ones already loaded. The duplicates of the
+// last MaxSeenEvents events added with AddEvent(), as sent again by a stream or
+// read from overlapping recordings, are skipped: it returns false for them.
+// The events are told apart by the fields of the rules they allow and by their
+// timestamp, so the ones without timestamp are always added.
+func (a *NetworkPolicyAdvisor) AddEvent(e types.Event) bool {
+	if key := eventKey(e); key != "" {
+		if a.seenEvents == nil {
+			a.seenEvents = newSeenEvents(a.MaxSeenEvents)
+		}
+		if !a.seenEvents.add(key) {
+			return false
+		}
+	}
+	a.Events = append(a.Events, e)
+	return true
+}
+
+// LoadEvents adds the events read from r, until its end, to the ones already
+// loaded. They are read as they come, either as a JSON array or as one JSON
+// event per line like the output of the monitor subcommand.
+func (a *NetworkPolicyAdvisor) LoadEvents(r io.Reader) error {
+	br := bufio.NewReader(r)
+	array, err := startsWithArray(br)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(br)
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	for count := 1; ; count++ {
+		if array && !dec.More() {
+			break
+		}
+		var e types.Event
+		err := dec.Decode(&e)
+		if !array && errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("parsing event %d: %w", count, err)
+		}
+		a.AddEvent(e)
+	}
+	return nil
+}
+
+// startsWithArray tells whether the next character of r, other than spaces, is
+// the start of a JSON array
+func startsWithArray(r *bufio.Reader) (bool, error) {
+	for {
+		c, err := r.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch c[0] {
+		case ' ', '\t', '\r', '\n':
+			r.ReadByte()
+		default:
+			return c[0] == '[', nil
+		}
+	}
+}
+
+// LoadDNSFile loads the events of the trace dns gadget, for the names resolved
+// by the pods to be used by the policies allowing egress traffic by FQDN
+func (a *NetworkPolicyAdvisor) LoadDNSFile(filename string) error {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return a.LoadDNSBuffer(buf)
+}
+
+func (a *NetworkPolicyAdvisor) LoadDNSBuffer(buf []byte) error {
+	events, err := parseEvents[dnstypes.Event](buf)
+	if err != nil {
+		return err
+	}
+	a.DNSEvents = events
+	return nil
+}
+
+func parseEvents[T any](buf []byte) ([]T, error) {
 	/* Try to read the file as an array */
-	events := []types.Event{}
+	events := []T{}
 	err := json.Unmarshal(buf, &events)
 	if err == nil {
-		a.Events = events
-		return nil
+		return events, nil
 	}
 
 	/* If it fails, read by line */
@@ -75,7 +353,7 @@ func (a *NetworkPolicyAdvisor) LoadBuffer(buf []byte) error {
 	line := 0
 	scanner := bufio.NewScanner(bytes.NewReader(buf))
 	for scanner.Scan() {
-		event := types.Event{}
+		var event T
 		text := strings.TrimSpace(scanner.Text())
 		if len(text) == 0 {
 			continue
@@ -83,18 +361,16 @@ func (a *NetworkPolicyAdvisor) LoadBuffer(buf []byte) error {
 		line++
 		err = json.Unmarshal([]byte(text), &event)
 		if err != nil {
-			return fmt.Errorf("parsing line %d: %w", line, err)
+			return nil, fmt.Errorf("parsing line %d: %w", line, err)
 		}
 		events = append(events, event)
 	}
 
 	if err := scanner.Err(); err != nil {
-		return err
+		return nil, err
 	}
 
-	a.Events = events
-
-	return nil
+	return events, nil
 }
 
 /* labelFilteredKeyList returns a sorted list of label keys but without the labels to
@@ -103,7 +379,7 @@ func (a *NetworkPolicyAdvisor) LoadBuffer(buf []byte) error {
 func (a *NetworkPolicyAdvisor) labelFilteredKeyList(labels map[string]string) []string {
 	keys := make([]string, 0, len(labels))
 	for k := range labels {
-		if _, ok := a.LabelsToIgnore[k]; ok {
+		if a.ignoredLabel(k) {
 			continue
 		}
 		keys = append(keys, k)
@@ -116,7 +392,7 @@ func (a *NetworkPolicyAdvisor) labelFilteredKeyList(labels map[string]string) []
 func (a *NetworkPolicyAdvisor) labelFilter(labels map[string]string) map[string]string {
 	ret := map[string]string{}
 	for k := range labels {
-		if _, ok := a.LabelsToIgnore[k]; ok {
+		if a.ignoredLabel(k) {
 			continue
 		}
 		ret[k] = labels[k]
@@ -147,20 +423,47 @@ func (a *NetworkPolicyAdvisor) localPodKey(e types.Event) (ret string) {
 	return e.K8s.Namespace + ":" + a.labelKeyString(e.PodLabels)
 }
 
+/* eventProtocol returns the protocol of the event as used in network
+ * policies. The events of other protocols can't be allowed by them.
+ */
+func eventProtocol(e types.Event) (v1.Protocol, bool) {
+	switch protocol := v1.Protocol(strings.ToUpper(e.Proto)); protocol {
+	case v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP:
+		return protocol, true
+	}
+	return "", false
+}
+
+/* networkPeerKey returns a key that can be used to group the connections to
+ * the same peer, port and protocol together:
+ * kind:namespace:label1=value1,label2=value2:port/protocol
+ */
 func (a *NetworkPolicyAdvisor) networkPeerKey(e types.Event) (ret string) {
+	protocol, _ := eventProtocol(e)
+	return fmt.Sprintf("%s:%d/%s", a.peerKey(e), e.Port, protocol)
+}
+
+/* peerKey returns a key that can be used to group the connections to the same
+ * peer together, whatever their port:
+ * kind:namespace:label1=value1,label2=value2
+ */
+func (a *NetworkPolicyAdvisor) peerKey(e types.Event) (ret string) {
 	if e.DstEndpoint.Kind == eventtypes.EndpointKindPod {
-		ret = string(e.DstEndpoint.Kind) + ":" + e.DstEndpoint.Namespace + ":" + a.labelKeyString(e.DstEndpoint.PodLabels)
+		ret = string(e.DstEndpoint.Kind) + ":" + e.DstEndpoint.Namespace + ":" + a.labelKeyString(a.peerLabels(e))
 	} else if e.DstEndpoint.Kind == eventtypes.EndpointKindService {
 		ret = string(e.DstEndpoint.Kind) + ":" + e.DstEndpoint.Namespace + ":" + a.labelKeyString(e.DstEndpoint.PodLabels)
 	} else if e.DstEndpoint.Kind == eventtypes.EndpointKindRaw {
-		ret = string(e.DstEndpoint.Kind) + ":" + e.DstEndpoint.Addr
+		ret = string(e.DstEndpoint.Kind) + ":" + a.rawCIDR(e.DstEndpoint.Addr)
 	}
-	return fmt.Sprintf("%s:%d", ret, e.Port)
+	return
 }
 
 func (a *NetworkPolicyAdvisor) eventToRule(e types.Event) (ports []networkingv1.NetworkPolicyPort, peers []networkingv1.NetworkPolicyPeer) {
 	port := intstr.FromInt(int(e.Port))
-	protocol := v1.Protocol(strings.ToUpper(e.Proto))
+	if name := a.namedPorts.name(e); name != "" {
+		port = intstr.FromString(name)
+	}
+	protocol, _ := eventProtocol(e)
 	ports = []networkingv1.NetworkPolicyPort{
 		{
 			Port:     &port,
@@ -170,7 +473,7 @@ func (a *NetworkPolicyAdvisor) eventToRule(e types.Event) (ports []networkingv1.
 	if e.DstEndpoint.Kind == eventtypes.EndpointKindPod {
 		peers = []networkingv1.NetworkPolicyPeer{
 			{
-				PodSelector: &metav1.LabelSelector{MatchLabels: a.labelFilter(e.DstEndpoint.PodLabels)},
+				PodSelector: &metav1.LabelSelector{MatchLabels: a.peerLabels(e)},
 			},
 		}
 		if e.K8s.Namespace != e.DstEndpoint.Namespace {
@@ -209,7 +512,7 @@ func (a *NetworkPolicyAdvisor) eventToRule(e types.Event) (ports []networkingv1.
 			peers = []networkingv1.NetworkPolicyPeer{
 				{
 					IPBlock: &networkingv1.IPBlock{
-						CIDR: e.DstEndpoint.Addr + "/32",
+						CIDR: a.rawCIDR(e.DstEndpoint.Addr),
 					},
 				},
 			}
@@ -220,14 +523,159 @@ func (a *NetworkPolicyAdvisor) eventToRule(e types.Event) (ports []networkingv1.
 	return
 }
 
+// rule is a rule of a network policy, for ingress or egress traffic
+type rule struct {
+	ports []networkingv1.NetworkPolicyPort
+	peers []networkingv1.NetworkPolicyPeer
+
+	coverage coverage
+}
+
+/* eventsToRules returns the rules allowing the connections of the events. The
+ * contiguous ports of the same peer and protocol are allowed by a single rule
+ * when there are at least PortRangeThreshold of them. The coverages of the
+ * events are by networkPeerKey().
+ */
+func (a *NetworkPolicyAdvisor) eventsToRules(events map[string]types.Event, coverages map[string]*coverage) []rule {
+	// The events of each peer and protocol, and their ports
+	groups := map[string][]types.Event{}
+	for _, e := range events {
+		protocol, _ := eventProtocol(e)
+		key := a.peerKey(e) + "/" + string(protocol)
+		groups[key] = append(groups[key], e)
+	}
+
+	rules := []rule{}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Port < group[j].Port
+		})
+		for start, end := 0, 0; start < len(group); start = end + 1 {
+			end = start
+			for end+1 < len(group) && group[end+1].Port == group[end].Port+1 {
+				end++
+			}
+
+			if a.PortRangeThreshold > 0 && end-start+1 >= a.PortRangeThreshold {
+				ports, peers := a.eventToRule(group[start])
+				if len(peers) > 0 {
+					// A port range can't start with a named
+					// port
+					startPort := intstr.FromInt(int(group[start].Port))
+					endPort := int32(group[end].Port)
+					ports[0].Port = &startPort
+					ports[0].EndPort = &endPort
+					r := rule{ports: ports, peers: peers}
+					for _, e := range group[start : end+1] {
+						r.coverage.merge(*coverages[a.networkPeerKey(e)])
+					}
+					rules = append(rules, r)
+				}
+				continue
+			}
+			for _, e := range group[start : end+1] {
+				ports, peers := a.eventToRule(e)
+				if len(peers) > 0 {
+					rules = append(rules, rule{ports: ports, peers: peers, coverage: *coverages[a.networkPeerKey(e)]})
+				}
+			}
+		}
+	}
+	if a.MergeRules {
+		rules = mergeRules(rules)
+	}
+	return rules
+}
+
+func portKey(p networkingv1.NetworkPolicyPort) string {
+	if p.Port.Type == intstr.String {
+		return fmt.Sprintf("%s/%s", *p.Protocol, p.Port.StrVal)
+	}
+	endPort := int32(0)
+	if p.EndPort != nil {
+		endPort = *p.EndPort
+	}
+	return fmt.Sprintf("%s/%05d-%05d", *p.Protocol, p.Port.IntValue(), endPort)
+}
+
+func peerYAML(p networkingv1.NetworkPolicyPeer) string {
+	yamlOutput, _ := k8syaml.Marshal(p)
+	return string(yamlOutput)
+}
+
+func portsKey(ports []networkingv1.NetworkPolicyPort) string {
+	keys := make([]string, 0, len(ports))
+	for _, p := range ports {
+		keys = append(keys, portKey(p))
+	}
+	return strings.Join(keys, ",")
+}
+
+func peersKey(peers []networkingv1.NetworkPolicyPeer) string {
+	keys := make([]string, 0, len(peers))
+	for _, p := range peers {
+		keys = append(keys, peerYAML(p))
+	}
+	return strings.Join(keys, "---\n")
+}
+
+/* mergeRules merges the rules with the same peers, combining their ports, and
+ * then the rules with the same ports, combining their peers. As the rules
+ * given by eventsToRules() have a single peer, each peer ends up in a single
+ * rule.
+ */
+func mergeRules(rules []rule) []rule {
+	byPeers := map[string]*rule{}
+	for _, r := range rules {
+		key := peersKey(r.peers)
+		merged, ok := byPeers[key]
+		if !ok {
+			byPeers[key] = &rule{peers: r.peers}
+			merged = byPeers[key]
+		}
+		merged.coverage.merge(r.coverage)
+		for _, p := range r.ports {
+			if !slices.ContainsFunc(merged.ports, func(q networkingv1.NetworkPolicyPort) bool {
+				return portKey(p) == portKey(q)
+			}) {
+				merged.ports = append(merged.ports, p)
+			}
+		}
+	}
+
+	byPorts := map[string]*rule{}
+	for _, r := range byPeers {
+		sort.Slice(r.ports, func(i, j int) bool {
+			return portKey(r.ports[i]) < portKey(r.ports[j])
+		})
+		key := portsKey(r.ports)
+		merged, ok := byPorts[key]
+		if !ok {
+			byPorts[key] = &rule{ports: r.ports}
+			merged = byPorts[key]
+		}
+		merged.coverage.merge(r.coverage)
+		merged.peers = append(merged.peers, r.peers...)
+	}
+
+	ret := make([]rule, 0, len(byPorts))
+	for _, r := range byPorts {
+		sort.Slice(r.peers, func(i, j int) bool {
+			return peerYAML(r.peers[i]) < peerYAML(r.peers[j])
+		})
+		ret = append(ret, *r)
+	}
+	return ret
+}
+
 func sortIngressRules(rules []networkingv1.NetworkPolicyIngressRule) []networkingv1.NetworkPolicyIngressRule {
 	sort.Slice(rules, func(i, j int) bool {
 		ri, rj := rules[i], rules[j]
 
 		// No need to support all network policies, but only the ones
-		// generated by eventToRule()
-		if len(ri.Ports) != 1 || len(rj.Ports) != 1 {
-			panic("rules with multiple ports")
+		// generated by eventsToRules(), whose ports are sorted
+		if len(ri.Ports) == 0 || len(rj.Ports) == 0 {
+			panic("rules without ports")
 		}
 		if ri.Ports[0].Protocol == nil || rj.Ports[0].Protocol == nil {
 			panic("rules without protocol")
@@ -252,9 +700,9 @@ func sortEgressRules(rules []networkingv1.NetworkPolicyEgressRule) []networkingv
 		ri, rj := rules[i], rules[j]
 
 		// No need to support all network policies, but only the ones
-		// generated by eventToRule()
-		if len(ri.Ports) != 1 || len(rj.Ports) != 1 {
-			panic("rules with multiple ports")
+		// generated by eventsToRules(), whose ports are sorted
+		if len(ri.Ports) == 0 || len(rj.Ports) == 0 {
+			panic("rules without ports")
 		}
 		if ri.Ports[0].Protocol == nil || rj.Ports[0].Protocol == nil {
 			panic("rules without protocol")
@@ -274,82 +722,265 @@ func sortEgressRules(rules []networkingv1.NetworkPolicyEgressRule) []networkingv
 	return rules
 }
 
+// policyEvent tells whether the event is a connection of a pod that network
+// policies can allow or block
+func policyEvent(e types.Event) bool {
+	if e.Type != eventtypes.NORMAL {
+		return false
+	}
+	if e.PktType != "HOST" && e.PktType != "OUTGOING" {
+		return false
+	}
+	// ignore events on the host netns
+	if e.K8s.HostNetwork {
+		return false
+	}
+	if _, ok := eventProtocol(e); !ok {
+		return false
+	}
+
+	// Kubernetes Network Policies can't block traffic from a pod's own
+	// resident node. Therefore we must not generate a network policy in
+	// that case.
+	if e.PktType == "HOST" && e.PodHostIP == e.DstEndpoint.Addr {
+		return false
+	}
+	return true
+}
+
+// inTimeWindow tells whether the event with the given timestamp was seen in
+// the time window given by StartTime, EndTime and MaxEventAge
+func (a *NetworkPolicyAdvisor) inTimeWindow(timestamp eventtypes.Time, now time.Time) bool {
+	if timestamp == 0 {
+		return true
+	}
+	t := time.Unix(0, int64(timestamp))
+	if !a.StartTime.IsZero() && t.Before(a.StartTime) {
+		return false
+	}
+	if !a.EndTime.IsZero() && t.After(a.EndTime) {
+		return false
+	}
+	if a.MaxEventAge != 0 && now.Sub(t) > a.MaxEventAge {
+		return false
+	}
+	return true
+}
+
+// eventPolicyType returns the type of the rules allowing the connection of the
+// event
+func eventPolicyType(e types.Event) networkingv1.PolicyType {
+	if e.PktType == "HOST" {
+		return networkingv1.PolicyTypeIngress
+	}
+	return networkingv1.PolicyTypeEgress
+}
+
+// policyTypes returns the types of the generated policies, ingress first
+func (a *NetworkPolicyAdvisor) policyTypes() []networkingv1.PolicyType {
+	ret := []networkingv1.PolicyType{}
+	for _, t := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress} {
+		if a.generatesPolicyType(t) {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func (a *NetworkPolicyAdvisor) generatesPolicyType(t networkingv1.PolicyType) bool {
+	return len(a.PolicyTypes) == 0 || slices.Contains(a.PolicyTypes, t)
+}
+
+/* defaultDenyPolicies returns the policies denying the traffic of all the pods
+ * of the namespaces of the policies, but the one allowed by them. They deny
+ * both the ingress and egress traffic whatever the types of the other policies
+ * are, for no traffic to be allowed without a rule.
+ */
+func (a *NetworkPolicyAdvisor) defaultDenyPolicies() []networkingv1.NetworkPolicy {
+	// Names of the policies of each namespace
+	namespaces := map[string]map[string]struct{}{}
+	for _, p := range a.Policies {
+		if namespaces[p.Namespace] == nil {
+			namespaces[p.Namespace] = map[string]struct{}{}
+		}
+		namespaces[p.Namespace][p.Name] = struct{}{}
+	}
+
+	policies := []networkingv1.NetworkPolicy{}
+	for namespace, names := range namespaces {
+		policies = append(policies, networkingv1.NetworkPolicy{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "networking.k8s.io/v1",
+				Kind:       "NetworkPolicy",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      defaultDenyPolicyName(names),
+				Namespace: namespace,
+				Labels:    map[string]string{},
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{
+					networkingv1.PolicyTypeIngress,
+					networkingv1.PolicyTypeEgress,
+				},
+			},
+		})
+	}
+	return policies
+}
+
+// defaultDenyPolicyName returns DefaultDenyPolicyName, with a numbered suffix
+// if one of the other policies of the namespace, given by name, already has
+// it.
+func defaultDenyPolicyName(names map[string]struct{}) string {
+	name := DefaultDenyPolicyName
+	for i := 2; ; i++ {
+		if _, ok := names[name]; !ok {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d", DefaultDenyPolicyName, i)
+	}
+}
+
+// GeneratePolicies generates the policies from all the events loaded, it can
+// be called again when new events are added.
 func (a *NetworkPolicyAdvisor) GeneratePolicies() {
+	a.Policies = nil
+	a.fqdns = map[string]map[string][]string{}
+	a.nodeIPs = nodeIPs(a.Events)
+	a.namedPorts = newNamedPorts(a.Pods)
+	a.workloads = newWorkloads(a.Pods, a.Workloads)
+	now := time.Now()
+
+	// The names resolved out of the time window aren't used either
+	dnsEvents := make([]dnstypes.Event, 0, len(a.DNSEvents))
+	for _, e := range a.DNSEvents {
+		if a.inTimeWindow(e.Timestamp, now) {
+			dnsEvents = append(dnsEvents, e)
+		}
+	}
+	answers := newDNSAnswers(dnsEvents)
+
 	eventsBySource := map[string][]types.Event{}
+	// targets are the first event of each policy target, including the
+	// pods only seen querying DNS servers with SharedDNSPolicy
+	targets := map[string]types.Event{}
+	dnsQueries := map[string][]types.Event{}
+	dnsCoverage := map[string]*coverage{}
 	for _, e := range a.Events {
-		if e.Type != eventtypes.NORMAL {
+		if !policyEvent(e) || !a.generatesPolicyType(eventPolicyType(e)) {
 			continue
 		}
-		if e.PktType != "HOST" && e.PktType != "OUTGOING" {
+		if !a.inTimeWindow(e.Timestamp, now) {
 			continue
 		}
-		// ignore events on the host netns
-		if e.K8s.HostNetwork {
+
+		// The DNS queries are allowed by the shared policies, even to
+		// the excluded DNS servers
+		if a.SharedDNSPolicy && isDNS(e) {
+			if !a.excludedNamespace(e.K8s.Namespace) {
+				dnsQueries[e.K8s.Namespace] = append(dnsQueries[e.K8s.Namespace], e)
+				key, _, _ := a.policyTarget(e)
+				if _, ok := targets[key]; !ok {
+					targets[key] = e
+				}
+				if dnsCoverage[key] == nil {
+					dnsCoverage[key] = &coverage{}
+				}
+				dnsCoverage[key].add(e)
+			}
 			continue
 		}
-
-		// Kubernetes Network Policies can't block traffic from a pod's
-		// own resident node. Therefore we must not generate a network
-		// policy in that case.
-		if e.PktType == "HOST" && e.PodHostIP == e.DstEndpoint.Addr {
+		if a.excluded(e) {
 			continue
 		}
 
-		key := a.localPodKey(e)
+		key, _, _ := a.policyTarget(e)
+		if _, ok := targets[key]; !ok {
+			targets[key] = e
+		}
 		if _, ok := eventsBySource[key]; ok {
 			eventsBySource[key] = append(eventsBySource[key], e)
 		} else {
 			eventsBySource[key] = []types.Event{e}
 		}
 	}
+	sharedDNSRules := a.sharedDNSRules(dnsQueries)
 
-	for _, events := range eventsBySource {
+	for key, target := range targets {
+		events := eventsBySource[key]
 		egressNetworkPeer := map[string]types.Event{}
 		ingressNetworkPeer := map[string]types.Event{}
+		egressCoverage := map[string]*coverage{}
+		ingressCoverage := map[string]*coverage{}
 		for _, e := range events {
 			key := a.networkPeerKey(e)
 			if e.PktType == "OUTGOING" {
 				if _, ok := egressNetworkPeer[key]; ok {
+					egressCoverage[key].add(e)
 					continue
 				}
 
 				egressNetworkPeer[key] = e
+				egressCoverage[key] = &coverage{}
+				egressCoverage[key].add(e)
 			} else if e.PktType == "HOST" {
 				if _, ok := ingressNetworkPeer[key]; ok {
+					ingressCoverage[key].add(e)
 					continue
 				}
 
 				ingressNetworkPeer[key] = e
+				ingressCoverage[key] = &coverage{}
+				ingressCoverage[key].add(e)
+			}
+		}
+		// The connections seen less than MinEventCount times aren't
+		// allowed
+		for key, c := range egressCoverage {
+			if c.count < a.MinEventCount {
+				delete(egressNetworkPeer, key)
 			}
 		}
+		for key, c := range ingressCoverage {
+			if c.count < a.MinEventCount {
+				delete(ingressNetworkPeer, key)
+			}
+		}
+
 		egressPolicies := []networkingv1.NetworkPolicyEgressRule{}
-		for _, p := range egressNetworkPeer {
-			ports, peers := a.eventToRule(p)
-			if len(peers) > 0 {
-				rule := networkingv1.NetworkPolicyEgressRule{
-					Ports: ports,
-					To:    peers,
-				}
-				egressPolicies = append(egressPolicies, rule)
+		egressRuleCoverage := map[string]coverage{}
+		for _, r := range a.eventsToRules(egressNetworkPeer, egressCoverage) {
+			rule := networkingv1.NetworkPolicyEgressRule{
+				Ports: r.ports,
+				To:    r.peers,
+			}
+			egressPolicies = append(egressPolicies, rule)
+			egressRuleCoverage[ruleKey(r.ports, r.peers)] = r.coverage
+		}
+		if rule, ok := sharedDNSRules[target.K8s.Namespace]; ok {
+			egressPolicies = append(egressPolicies, rule)
+			if c := dnsCoverage[key]; c != nil {
+				egressRuleCoverage[ruleKey(rule.Ports, rule.To)] = *c
 			}
 		}
 		ingressPolicies := []networkingv1.NetworkPolicyIngressRule{}
-		for _, p := range ingressNetworkPeer {
-			ports, peers := a.eventToRule(p)
-			if len(peers) > 0 {
-				rule := networkingv1.NetworkPolicyIngressRule{
-					Ports: ports,
-					From:  peers,
-				}
-				ingressPolicies = append(ingressPolicies, rule)
+		ingressRuleCoverage := map[string]coverage{}
+		for _, r := range a.eventsToRules(ingressNetworkPeer, ingressCoverage) {
+			rule := networkingv1.NetworkPolicyIngressRule{
+				Ports: r.ports,
+				From:  r.peers,
 			}
+			ingressPolicies = append(ingressPolicies, rule)
+			ingressRuleCoverage[ruleKey(r.ports, r.peers)] = r.coverage
 		}
-
-		name := events[0].K8s.PodName
-		if events[0].PodOwner != "" {
-			name = events[0].PodOwner
+		if len(egressPolicies) == 0 && len(ingressPolicies) == 0 && a.MinEventCount > 0 {
+			// All the connections of the pods were dropped
+			continue
 		}
-		name += "-network"
+
+		_, name, podLabels := a.policyTarget(target)
 		policy := networkingv1.NetworkPolicy{
 			TypeMeta: metav1.TypeMeta{
 				APIVersion: "networking.k8s.io/v1",
@@ -357,35 +988,99 @@ func (a *NetworkPolicyAdvisor) GeneratePolicies() {
 			},
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      name,
-				Namespace: events[0].K8s.Namespace,
+				Namespace: target.K8s.Namespace,
 				Labels:    map[string]string{},
 			},
 			Spec: networkingv1.NetworkPolicySpec{
-				PodSelector: metav1.LabelSelector{MatchLabels: a.labelFilter(events[0].PodLabels)},
-				PolicyTypes: []networkingv1.PolicyType{"Ingress", "Egress"},
+				PodSelector: metav1.LabelSelector{MatchLabels: podLabels},
+				PolicyTypes: a.policyTypes(),
 				Ingress:     sortIngressRules(ingressPolicies),
 				Egress:      sortEgressRules(egressPolicies),
 			},
 		}
+		if warning := a.nodeWarning(egressNetworkPeer, ingressNetworkPeer); warning != "" {
+			policy.Annotations = map[string]string{WarningsAnnotation: warning}
+		}
+		if a.CoverageAnnotations {
+			if policy.Annotations == nil {
+				policy.Annotations = map[string]string{}
+			}
+			policy.Annotations[CoverageAnnotation] = coverageAnnotation(policy.Spec.Ingress, policy.Spec.Egress,
+				ingressRuleCoverage, egressRuleCoverage)
+		}
 		a.Policies = append(a.Policies, policy)
+
+		for _, e := range egressNetworkPeer {
+			if e.DstEndpoint.Kind != eventtypes.EndpointKindRaw {
+				continue
+			}
+			names := answers.names(e)
+			if len(names) == 0 {
+				continue
+			}
+			key := policy.Namespace + "/" + policy.Name
+			if a.fqdns[key] == nil {
+				a.fqdns[key] = map[string][]string{}
+			}
+			cidr := a.rawCIDR(e.DstEndpoint.Addr)
+			a.fqdns[key][cidr] = mergeNames(a.fqdns[key][cidr], names)
+		}
+	}
+
+	if a.DefaultDenyPolicy {
+		a.Policies = append(a.Policies, a.defaultDenyPolicies()...)
 	}
 
 	sort.Slice(a.Policies, func(i, j int) bool {
-		return a.Policies[i].Name < a.Policies[j].Name
+		if a.Policies[i].Name != a.Policies[j].Name {
+			return a.Policies[i].Name < a.Policies[j].Name
+		}
+		return a.Policies[i].Namespace < a.Policies[j].Namespace
 	})
 }
 
-func (a *NetworkPolicyAdvisor) FormatPolicies() (out string) {
-	for i, p := range a.Policies {
+// formattedPolicies returns the policies in the format given by OutputFormat
+func (a *NetworkPolicyAdvisor) formattedPolicies() []metav1.Object {
+	if a.OutputFormat == OutputFormatAdmin {
+		return adminPolicies(a.Policies, a.AdminPriority)
+	}
+
+	policies := make([]metav1.Object, 0, len(a.Policies))
+	for i := range a.Policies {
+		var policy metav1.Object = &a.Policies[i]
+		switch a.OutputFormat {
+		case OutputFormatCilium:
+			p := ciliumPolicy(a.Policies[i], a.fqdns[a.Policies[i].Namespace+"/"+a.Policies[i].Name])
+			policy = &p
+		case OutputFormatCalico:
+			p := calicoPolicy(a.Policies[i], a.CalicoOrder, false)
+			policy = &p
+		case OutputFormatCalicoGlobal:
+			p := calicoPolicy(a.Policies[i], a.CalicoOrder, true)
+			policy = &p
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// WritePolicies writes the policies, in the format given by OutputFormat, with
+// the given writer
+func (a *NetworkPolicyAdvisor) WritePolicies(w PolicyWriter) error {
+	for _, p := range a.formattedPolicies() {
 		yamlOutput, err := k8syaml.Marshal(p)
 		if err != nil {
-			continue
+			return fmt.Errorf("marshalling policy %q: %w", p.GetName(), err)
 		}
-		sep := "---\n"
-		if i == len(a.Policies)-1 {
-			sep = ""
+		if err := w.WritePolicy(p.GetNamespace(), p.GetName(), yamlOutput); err != nil {
+			return fmt.Errorf("writing policy %q: %w", p.GetName(), err)
 		}
-		out += fmt.Sprintf("%s%s", string(yamlOutput), sep)
 	}
-	return
+	return nil
+}
+
+func (a *NetworkPolicyAdvisor) FormatPolicies() string {
+	var out strings.Builder
+	a.WritePolicies(NewStreamWriter(&out))
+	return out.String()
 }
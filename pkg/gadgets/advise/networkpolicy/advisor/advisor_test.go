@@ -15,11 +15,36 @@
 package advisor
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
+// goldenSuffixes are the suffixes of the golden files of the input files, by
+// output format. Only the policies of the formats with a golden file are
+// checked.
+var goldenSuffixes = map[string]string{
+	OutputFormatKubernetes:   ".golden",
+	OutputFormatCilium:       ".cilium.golden",
+	OutputFormatCalico:       ".calico.golden",
+	OutputFormatCalicoGlobal: ".calico-global.golden",
+	OutputFormatAdmin:        ".admin.golden",
+}
+
 func TestLoad(t *testing.T) {
 	match, err := filepath.Glob("testdata/*.input")
 	if err != nil {
@@ -27,25 +52,603 @@ func TestLoad(t *testing.T) {
 	}
 
 	for _, inputFile := range match {
+		for _, format := range OutputFormats {
+			goldenFile := inputFile[:len(inputFile)-len(".input")] + goldenSuffixes[format]
+			goldenOutputBytes, err := os.ReadFile(goldenFile)
+			if errors.Is(err, fs.ErrNotExist) && format != OutputFormatKubernetes {
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			goldenOutput := string(goldenOutputBytes)
+
+			a := NewAdvisor()
+			a.OutputFormat = format
+
+			err = a.LoadFile(inputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dnsFile := inputFile[:len(inputFile)-len(".input")] + ".dns"
+			if _, err := os.Stat(dnsFile); err == nil {
+				err = a.LoadDNSFile(dnsFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			podsFile := inputFile[:len(inputFile)-len(".input")] + ".pods"
+			if _, err := os.Stat(podsFile); err == nil {
+				err = a.LoadPodsFile(podsFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			a.GeneratePolicies()
+			generatedOuput := a.FormatPolicies()
+
+			if generatedOuput != goldenOutput {
+				t.Errorf("Unexpected %s policy from %s:\n%s\nExpected:\n%s\n", format, inputFile, generatedOuput, goldenOutput)
+			}
+		}
+	}
+}
+
+func TestWritePoliciesDirectory(t *testing.T) {
+	a := NewAdvisor()
+	err := a.LoadFile("testdata/cluster-wide.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+
+	dir := t.TempDir()
+	err = a.WritePolicies(NewDirectoryWriter(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []string{
+		filepath.Join(dir, "monitoring", "prometheus-network.yaml"),
+		filepath.Join(dir, "shop", "cart-network.yaml"),
+		filepath.Join(dir, "shop", "frontend-network.yaml"),
+		filepath.Join(dir, "shop", "redis-network.yaml"),
+	}
+	if strings.Join(files, "\n") != strings.Join(expectedFiles, "\n") {
+		t.Fatalf("Unexpected files:\n%s\nExpected:\n%s\n", strings.Join(files, "\n"), strings.Join(expectedFiles, "\n"))
+	}
+
+	// Writing the policies in a single file or in the directory gives the
+	// same policies
+	var policies []string
+	for _, file := range files {
+		policy, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policies = append(policies, string(policy))
+	}
+	expectedPolicies := strings.Split(a.FormatPolicies(), "---\n")
+	sort.Strings(policies)
+	sort.Strings(expectedPolicies)
+	if strings.Join(policies, "---\n") != strings.Join(expectedPolicies, "---\n") {
+		t.Errorf("Unexpected policies in %s", dir)
+	}
+}
+
+func TestSharedDNSPolicy(t *testing.T) {
+	a := NewAdvisor()
+	a.ExcludeSystemTraffic = true
+	a.SharedDNSPolicy = true
+
+	err := a.LoadFile("testdata/system-traffic.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+	generatedOuput := a.FormatPolicies()
+
+	goldenOutputBytes, err := os.ReadFile("testdata/system-traffic.shared-dns.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goldenOutput := string(goldenOutputBytes)
+
+	if generatedOuput != goldenOutput {
+		t.Errorf("Unexpected policy:\n%s\nExpected:\n%s\n", generatedOuput, goldenOutput)
+	}
+}
+
+func TestNodeCIDRs(t *testing.T) {
+	a := NewAdvisor()
+	a.NodeCIDRs = []string{"192.168.49.0/24"}
+
+	err := a.LoadFile("testdata/host-network.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+
+	for _, p := range a.Policies {
+		if _, ok := p.Annotations[WarningsAnnotation]; ok {
+			t.Errorf("Unexpected warning in policy %s: %s", p.Name, p.Annotations[WarningsAnnotation])
+		}
+	}
+	generatedOuput := a.FormatPolicies()
+	if strings.Count(generatedOuput, "cidr: 192.168.49.0/24") != 1 {
+		t.Errorf("Expected the node CIDR to be allowed once:\n%s", generatedOuput)
+	}
+	if strings.Contains(generatedOuput, "cidr: 192.168.49.2/32") {
+		t.Errorf("Unexpected rule allowing the address of a node:\n%s", generatedOuput)
+	}
+}
+
+func TestIgnoreLabels(t *testing.T) {
+	a := NewAdvisor()
+	err := a.IgnoreLabels("statefulset.kubernetes.io/pod-name", "*.argoproj.io/*", "/-hash$/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, ignored := range map[string]bool{
+		"app":                                false,
+		"pod-template-hash":                  true,
+		"statefulset.kubernetes.io/pod-name": true,
+		"workflows.argoproj.io/workflow":     true,
+		"rollouts-pod-template-hash":         true,
+		"argoproj.io/instance":               false,
+		"app.kubernetes.io/name":             false,
+	} {
+		if a.ignoredLabel(key) != ignored {
+			t.Errorf("Expected label %q to be ignored: %t", key, ignored)
+		}
+	}
+
+	if _, ok := defaultLabelsToIgnore["statefulset.kubernetes.io/pod-name"]; ok {
+		t.Errorf("Unexpected change of the default labels to ignore")
+	}
+
+	if err := a.IgnoreLabels("/(/"); err == nil {
+		t.Errorf("Expected an error with an invalid regular expression")
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	match, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The advised policies allow all the connections they are generated
+	// from
+	for _, inputFile := range match {
+		a := NewAdvisor()
+		err = a.LoadFile(inputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		podsFile := inputFile[:len(inputFile)-len(".input")] + ".pods"
+		if _, err := os.Stat(podsFile); err == nil {
+			err = a.LoadPodsFile(podsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		a.GeneratePolicies()
+
+		policies, err := LoadPoliciesBuffer([]byte(a.FormatPolicies()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(policies) != len(a.Policies) {
+			t.Fatalf("Unexpected number of policies loaded from %s: %d instead of %d", inputFile, len(policies), len(a.Policies))
+		}
+		for _, c := range a.Simulate(policies) {
+			t.Errorf("Unexpected connection of %s blocked: %s", inputFile, c)
+		}
+	}
+
+	// Without the egress rules of the policy of the frontend, its
+	// connections are blocked, and only these ones
+	a := NewAdvisor()
+	err = a.LoadFile("testdata/cluster-wide.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+	policies := []networkingv1.NetworkPolicy{}
+	for _, p := range a.Policies {
+		if p.Name == "frontend-network" {
+			p.Spec.Egress = nil
+		}
+		policies = append(policies, p)
+	}
+	blocked := a.Simulate(policies)
+	if len(blocked) == 0 {
+		t.Fatalf("Expected connections of the frontend to be blocked")
+	}
+	for _, c := range blocked {
+		if c.Ingress || c.Event.K8s.PodName != "frontend" {
+			t.Errorf("Unexpected connection blocked: %s", c)
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	a := NewAdvisor()
+	a.CoverageAnnotations = true
+	err := a.LoadFile("testdata/coverage.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+
+	expected := "egress[0]: 4 events from 2023-11-14T22:13:20Z to 2023-11-14T23:13:20Z"
+	if len(a.Policies) == 0 || a.Policies[0].Annotations[CoverageAnnotation] != expected {
+		t.Fatalf("Unexpected coverage of the policies:\n%s\nExpected:\n%s\n", a.FormatPolicies(), expected)
+	}
+
+	// The connections to port 9090 were seen once only
+	a.MinEventCount = 2
+	a.GeneratePolicies()
+	expected = "egress[0]: 3 events from 2023-11-14T22:13:20Z to 2023-11-14T23:13:20Z"
+	if a.Policies[0].Annotations[CoverageAnnotation] != expected {
+		t.Errorf("Unexpected coverage of the policies:\n%s\nExpected:\n%s\n", a.FormatPolicies(), expected)
+	}
+	if strings.Contains(a.FormatPolicies(), "port: 9090") {
+		t.Errorf("Unexpected rule of a connection seen once:\n%s", a.FormatPolicies())
+	}
+
+	// No policy is generated for the pods without any connection left
+	a.MinEventCount = 4
+	a.GeneratePolicies()
+	if len(a.Policies) != 0 {
+		t.Errorf("Unexpected policies:\n%s", a.FormatPolicies())
+	}
+}
+
+func TestLoadEvents(t *testing.T) {
+	expected := NewAdvisor()
+	err := expected.LoadFile("testdata/cluster-wide.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the events with a timestamp can be told apart from the ones sent
+	// again
+	for i := range expected.Events {
+		expected.Events[i].Timestamp = eventtypes.Time(i + 1)
+	}
+	expected.GeneratePolicies()
+
+	lines := &bytes.Buffer{}
+	enc := json.NewEncoder(lines)
+	for _, e := range expected.Events {
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	array, err := json.Marshal(expected.Events)
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	for name, input := range map[string][]byte{
+		"lines": lines.Bytes(),
+		"array": array,
+	} {
 		a := NewAdvisor()
+		// The events read again are duplicates
+		for i := 0; i < 2; i++ {
+			err = a.LoadEvents(bytes.NewReader(input))
+			if err != nil {
+				t.Fatalf("Loading %s: %s", name, err)
+			}
+		}
+		if len(a.Events) != len(expected.Events) {
+			t.Errorf("Unexpected number of events loaded from %s: %d instead of %d", name, len(a.Events), len(expected.Events))
+		}
+		a.GeneratePolicies()
+		if a.FormatPolicies() != expected.FormatPolicies() {
+			t.Errorf("Unexpected policies from %s:\n%s\nExpected:\n%s\n", name, a.FormatPolicies(), expected.FormatPolicies())
+		}
+	}
+
+	a := NewAdvisor()
+	if a.LoadEvents(strings.NewReader("{}\n{")) == nil {
+		t.Errorf("Expected an error with a truncated event")
+	}
+}
+
+func TestAddEventDuplicates(t *testing.T) {
+	event := types.Event{
+		Event: eventtypes.Event{
+			CommonData: eventtypes.CommonData{
+				K8s: eventtypes.K8sMetadata{
+					BasicK8sMetadata: eventtypes.BasicK8sMetadata{Namespace: "default", PodName: "client"},
+				},
+			},
+		},
+		PktType: "OUTGOING",
+		Proto:   "tcp",
+		Port:    80,
+	}
+
+	// Without timestamp, the identical events are different connections
+	a := NewAdvisor()
+	for i := 0; i < 3; i++ {
+		if !a.AddEvent(event) {
+			t.Errorf("Event without timestamp %d skipped", i)
+		}
+	}
+
+	// The same goes for the ones of a recording, all of them are counted
+	buf := &bytes.Buffer{}
+	for i := 0; i < 3; i++ {
+		if err := json.NewEncoder(buf).Encode(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a = NewAdvisor()
+	a.MinEventCount = 3
+	if err := a.LoadBuffer(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Events) != 3 {
+		t.Errorf("Unexpected number of events loaded: %d instead of 3", len(a.Events))
+	}
+
+	// Only the last MaxSeenEvents events are remembered
+	a = NewAdvisor()
+	a.MaxSeenEvents = 2
+	for i := 1; i <= 3; i++ {
+		event.Timestamp = eventtypes.Time(i)
+		if !a.AddEvent(event) {
+			t.Errorf("Event %d skipped", i)
+		}
+	}
+	if a.AddEvent(event) {
+		t.Errorf("Duplicate of the last event added")
+	}
+	event.Timestamp = 1
+	if !a.AddEvent(event) {
+		t.Errorf("Forgotten event skipped")
+	}
+	if len(a.seenEvents.keys) != 2 {
+		t.Errorf("Unexpected number of events remembered: %d instead of 2", len(a.seenEvents.keys))
+	}
+}
+
+func TestGroupByOwner(t *testing.T) {
+	a := NewAdvisor()
+	a.GroupBy = GroupByOwner
+	err := a.LoadFile("testdata/workloads.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.LoadPodsFile("testdata/workloads.pods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+	generatedOuput := a.FormatPolicies()
+
+	goldenOutputBytes, err := os.ReadFile("testdata/workloads.owner.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goldenOutput := string(goldenOutputBytes)
+
+	if generatedOuput != goldenOutput {
+		t.Errorf("Unexpected policy:\n%s\nExpected:\n%s\n", generatedOuput, goldenOutput)
+	}
+
+	// Without the workloads, the pods are selected by the labels common
+	// to the pods of the same workload
+	a.Workloads = nil
+	a.GeneratePolicies()
+	if generatedOuput := a.FormatPolicies(); generatedOuput != goldenOutput {
+		t.Errorf("Unexpected policy without the workloads:\n%s\nExpected:\n%s\n", generatedOuput, goldenOutput)
+	}
+}
 
-		err := a.LoadFile(inputFile)
+func TestPolicyTypes(t *testing.T) {
+	for _, policyType := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress} {
+		a := NewAdvisor()
+		a.PolicyTypes = []networkingv1.PolicyType{policyType}
+		err := a.LoadFile("testdata/cluster-wide.input")
 		if err != nil {
 			t.Fatal(err)
 		}
 		a.GeneratePolicies()
-		generatedOuput := a.FormatPolicies()
+		if len(a.Policies) == 0 {
+			t.Fatalf("No %s policies generated", policyType)
+		}
+
+		for _, p := range a.Policies {
+			if len(p.Spec.PolicyTypes) != 1 || p.Spec.PolicyTypes[0] != policyType {
+				t.Errorf("Unexpected types of %s policy %s: %v", policyType, p.Name, p.Spec.PolicyTypes)
+			}
+			if policyType == networkingv1.PolicyTypeIngress && (len(p.Spec.Egress) > 0 || len(p.Spec.Ingress) == 0) {
+				t.Errorf("Unexpected rules of ingress policy %s", p.Name)
+			}
+			if policyType == networkingv1.PolicyTypeEgress && (len(p.Spec.Ingress) > 0 || len(p.Spec.Egress) == 0) {
+				t.Errorf("Unexpected rules of egress policy %s", p.Name)
+			}
+		}
 
-		goldenFile := inputFile[:len(inputFile)-len(".input")] + ".golden"
-		goldenOutputBytes, err := os.ReadFile(goldenFile)
+		// The baseline doesn't deny the traffic of the other type
+		a.OutputFormat = OutputFormatAdmin
+		policies := a.formattedPolicies()
+		baseline := policies[len(policies)-1].(*BaselineAdminNetworkPolicy)
+		if (len(baseline.Spec.Ingress) > 0) != (policyType == networkingv1.PolicyTypeIngress) ||
+			(len(baseline.Spec.Egress) > 0) != (policyType == networkingv1.PolicyTypeEgress) {
+			t.Errorf("Unexpected baseline of %s policies:\n%s", policyType, a.FormatPolicies())
+		}
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		startTime   time.Time
+		endTime     time.Time
+		maxEventAge time.Duration
+		ports       []string
+	}{
+		{
+			name:  "all",
+			ports: []string{"8080", "9090"},
+		},
+		{
+			name:      "start",
+			startTime: time.Unix(1700000900, 0),
+			ports:     []string{"8080", "9090"},
+		},
+		{
+			name:    "end",
+			endTime: time.Unix(1700000700, 0),
+			ports:   []string{"8080"},
+		},
+		{
+			name:        "max-age",
+			maxEventAge: time.Since(time.Unix(1700001800, 0)),
+			ports:       []string{"8080"},
+		},
+		{
+			name:      "none",
+			startTime: time.Unix(1700003700, 0),
+		},
+	} {
+		a := NewAdvisor()
+		a.StartTime = test.startTime
+		a.EndTime = test.endTime
+		a.MaxEventAge = test.maxEventAge
+		err := a.LoadFile("testdata/coverage.input")
 		if err != nil {
 			t.Fatal(err)
 		}
-		goldenOutput := string(goldenOutputBytes)
+		a.GeneratePolicies()
 
-		if generatedOuput != goldenOutput {
-			t.Errorf("Unexpected policy from %s:\n%s\nExpected:\n%s\n", inputFile, generatedOuput, goldenOutput)
+		ports := []string{}
+		for _, p := range a.Policies {
+			if p.Name != "client-network" {
+				continue
+			}
+			for _, r := range p.Spec.Egress {
+				for _, port := range r.Ports {
+					ports = append(ports, port.Port.String())
+				}
+			}
 		}
+		if strings.Join(ports, ",") != strings.Join(test.ports, ",") {
+			t.Errorf("%s: unexpected ports allowed: %v instead of %v", test.name, ports, test.ports)
+		}
+	}
+}
+
+func TestTimeWindowDNS(t *testing.T) {
+	a := NewAdvisor()
+	// After api.stripe.com was resolved but before the connections to it
+	a.StartTime = time.Unix(1700000001, 700000000)
+	if err := a.LoadFile("testdata/fqdn.input"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadDNSFile("testdata/fqdn.dns"); err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+
+	for policy, cidrs := range a.fqdns {
+		for cidr, names := range cidrs {
+			if len(names) > 0 {
+				t.Errorf("%s: unexpected names for %s resolved out of the time window: %v", policy, cidr, names)
+			}
+		}
+	}
+}
+
+func TestDefaultDenyPolicy(t *testing.T) {
+	a := NewAdvisor()
+	a.DefaultDenyPolicy = true
+	err := a.LoadFile("testdata/cluster-wide.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+
+	namespaces := []string{}
+	for _, p := range a.Policies {
+		if p.Name != DefaultDenyPolicyName {
+			continue
+		}
+		namespaces = append(namespaces, p.Namespace)
+		if len(p.Spec.PodSelector.MatchLabels) != 0 || len(p.Spec.Ingress) != 0 || len(p.Spec.Egress) != 0 ||
+			len(p.Spec.PolicyTypes) != 2 {
+			t.Errorf("Unexpected default deny policy in namespace %s: %+v", p.Namespace, p.Spec)
+		}
+	}
+	if strings.Join(namespaces, ",") != "monitoring,shop" {
+		t.Errorf("Unexpected namespaces of the default deny policies: %v", namespaces)
+	}
+
+	// The other policies allow the traffic they are generated from, but
+	// none is allowed with the default deny policies only
+	if blocked := a.Simulate(a.Policies); len(blocked) != 0 {
+		t.Errorf("Unexpected connections blocked: %v", blocked)
+	}
+	defaultDeny := []networkingv1.NetworkPolicy{}
+	for _, p := range a.Policies {
+		if p.Name == DefaultDenyPolicyName {
+			defaultDeny = append(defaultDeny, p)
+		}
+	}
+	if blocked := a.Simulate(defaultDeny); len(blocked) == 0 {
+		t.Errorf("Expected connections blocked by the default deny policies")
+	}
+}
+
+func TestDefaultDenyPolicyTypesAndName(t *testing.T) {
+	a := NewAdvisor()
+	a.DefaultDenyPolicy = true
+	a.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}
+	err := a.LoadFile("testdata/cluster-wide.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.GeneratePolicies()
+
+	// The default deny policies deny both types of traffic even if only
+	// egress policies are generated
+	found := false
+	for _, p := range a.Policies {
+		if p.Name != DefaultDenyPolicyName {
+			continue
+		}
+		found = true
+		if len(p.Spec.PolicyTypes) != 2 {
+			t.Errorf("Unexpected types of the default deny policy in namespace %s: %v", p.Namespace, p.Spec.PolicyTypes)
+		}
+	}
+	if !found {
+		t.Fatalf("No default deny policy generated")
+	}
+
+	// The name of the other policies isn't reused
+	a.Policies = []networkingv1.NetworkPolicy{
+		{ObjectMeta: metav1.ObjectMeta{Name: DefaultDenyPolicyName, Namespace: "shop"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: DefaultDenyPolicyName + "-2", Namespace: "shop"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "cart-network", Namespace: "monitoring"}},
+	}
+	names := map[string]string{}
+	for _, p := range a.defaultDenyPolicies() {
+		names[p.Namespace] = p.Name
+	}
+	expected := map[string]string{"shop": DefaultDenyPolicyName + "-3", "monitoring": DefaultDenyPolicyName}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Unexpected names of the default deny policies: %v instead of %v", names, expected)
 	}
 }
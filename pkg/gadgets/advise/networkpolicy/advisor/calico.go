@@ -0,0 +1,209 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// The following types are the subset of the NetworkPolicy and
+// GlobalNetworkPolicy resources of Calico (projectcalico.org/v3) used by the
+// advisor. They are defined here to avoid depending on Calico.
+
+type CalicoNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec CalicoPolicySpec `json:"spec"`
+}
+
+type CalicoPolicySpec struct {
+	Order *float64 `json:"order,omitempty"`
+	// Selector selects the pods the policy applies to
+	Selector string `json:"selector"`
+	// NamespaceSelector selects the namespaces of the pods a
+	// GlobalNetworkPolicy applies to
+	NamespaceSelector string       `json:"namespaceSelector,omitempty"`
+	Types             []string     `json:"types"`
+	Ingress           []CalicoRule `json:"ingress,omitempty"`
+	Egress            []CalicoRule `json:"egress,omitempty"`
+}
+
+type CalicoRule struct {
+	Action      string            `json:"action"`
+	Protocol    string            `json:"protocol,omitempty"`
+	Source      *CalicoEntityRule `json:"source,omitempty"`
+	Destination *CalicoEntityRule `json:"destination,omitempty"`
+}
+
+type CalicoEntityRule struct {
+	Nets              []string             `json:"nets,omitempty"`
+	Selector          string               `json:"selector,omitempty"`
+	NamespaceSelector string               `json:"namespaceSelector,omitempty"`
+	Ports             []intstr.IntOrString `json:"ports,omitempty"`
+}
+
+// DefaultCalicoOrder is the order of the generated Calico policies. Calico
+// applies the policies with the lowest order first.
+const DefaultCalicoOrder = 1000
+
+// calicoNamespaceLabel is the label Calico gives to the namespaces with their
+// name
+const calicoNamespaceLabel = "projectcalico.org/name"
+
+// calicoSelector returns the Calico selector expression matching the given
+// labels
+func calicoSelector(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "all()"
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	expressions := make([]string, 0, len(keys))
+	for _, k := range keys {
+		expressions = append(expressions, fmt.Sprintf("%s == '%s'", k, labels[k]))
+	}
+	return strings.Join(expressions, " && ")
+}
+
+func calicoNamespaceSelector(namespace string) string {
+	return calicoSelector(map[string]string{calicoNamespaceLabel: namespace})
+}
+
+// calicoPeers returns the Calico entities of the peers of a Kubernetes network
+// policy rule: one for each pod selector and one for all the CIDRs. The peers
+// without namespace selector are in the namespace of the policy, it has to be
+// set explicitly for GlobalNetworkPolicy resources.
+func calicoPeers(peers []networkingv1.NetworkPolicyPeer, namespace string, global bool) []CalicoEntityRule {
+	entities := []CalicoEntityRule{}
+	nets := []string{}
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			nets = append(nets, peer.IPBlock.CIDR)
+			continue
+		}
+		entity := CalicoEntityRule{}
+		if peer.PodSelector != nil {
+			entity.Selector = calicoSelector(peer.PodSelector.MatchLabels)
+		}
+		if peer.NamespaceSelector != nil {
+			entity.NamespaceSelector = calicoSelector(peer.NamespaceSelector.MatchLabels)
+			// The namespace selectors generated by eventToRule()
+			// only select the namespace by its name
+			if ns, ok := peer.NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"]; ok {
+				entity.NamespaceSelector = calicoNamespaceSelector(ns)
+			}
+		} else if global {
+			entity.NamespaceSelector = calicoNamespaceSelector(namespace)
+		}
+		entities = append(entities, entity)
+	}
+	if len(nets) > 0 {
+		entities = append(entities, CalicoEntityRule{Nets: nets})
+	}
+	return entities
+}
+
+// calicoRules returns the Calico rules of a Kubernetes network policy rule. A
+// Calico rule has a single protocol and a single entity, there is a rule for
+// each of them.
+func calicoRules(ports []networkingv1.NetworkPolicyPort, peers []CalicoEntityRule, ingress bool) []CalicoRule {
+	protocols := []string{}
+	portsByProtocol := map[string][]intstr.IntOrString{}
+	for _, port := range ports {
+		calicoPort := *port.Port
+		if port.EndPort != nil {
+			calicoPort = intstr.FromString(fmt.Sprintf("%d:%d", port.Port.IntValue(), *port.EndPort))
+		}
+		protocol := string(*port.Protocol)
+		if _, ok := portsByProtocol[protocol]; !ok {
+			protocols = append(protocols, protocol)
+		}
+		portsByProtocol[protocol] = append(portsByProtocol[protocol], calicoPort)
+	}
+
+	rules := []CalicoRule{}
+	for _, protocol := range protocols {
+		for _, peer := range peers {
+			rule := CalicoRule{
+				Action:      "Allow",
+				Protocol:    protocol,
+				Destination: &CalicoEntityRule{},
+			}
+			if ingress {
+				source := peer
+				rule.Source = &source
+			} else {
+				*rule.Destination = peer
+			}
+			rule.Destination.Ports = portsByProtocol[protocol]
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// calicoPolicy converts a network policy generated by GeneratePolicies() to a
+// Calico NetworkPolicy, or GlobalNetworkPolicy if global is set
+func calicoPolicy(p networkingv1.NetworkPolicy, order float64, global bool) CalicoNetworkPolicy {
+	policy := CalicoNetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "NetworkPolicy",
+			APIVersion: "projectcalico.org/v3",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        p.Name,
+			Namespace:   p.Namespace,
+			Annotations: p.Annotations,
+		},
+		Spec: CalicoPolicySpec{
+			Order:    &order,
+			Selector: calicoSelector(p.Spec.PodSelector.MatchLabels),
+		},
+	}
+	for _, policyType := range p.Spec.PolicyTypes {
+		policy.Spec.Types = append(policy.Spec.Types, string(policyType))
+	}
+	if global {
+		// GlobalNetworkPolicy resources aren't namespaced, the name of
+		// the namespace avoids the collisions between the policies of
+		// the different namespaces
+		policy.Kind = "GlobalNetworkPolicy"
+		policy.Name = p.Namespace + "-" + p.Name
+		policy.Namespace = ""
+		policy.Spec.NamespaceSelector = calicoNamespaceSelector(p.Namespace)
+	}
+
+	for _, r := range p.Spec.Ingress {
+		peers := calicoPeers(r.From, p.Namespace, global)
+		policy.Spec.Ingress = append(policy.Spec.Ingress, calicoRules(r.Ports, peers, true)...)
+	}
+	for _, r := range p.Spec.Egress {
+		peers := calicoPeers(r.To, p.Namespace, global)
+		policy.Spec.Egress = append(policy.Spec.Egress, calicoRules(r.Ports, peers, false)...)
+	}
+
+	return policy
+}
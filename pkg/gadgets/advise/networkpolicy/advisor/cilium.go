@@ -0,0 +1,207 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The following types are the subset of the CiliumNetworkPolicy resource
+// (cilium.io/v2) used by the advisor. They are defined here to avoid depending
+// on Cilium.
+
+type CiliumNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec CiliumRule `json:"spec"`
+}
+
+type CiliumRule struct {
+	EndpointSelector metav1.LabelSelector `json:"endpointSelector"`
+	Ingress          []CiliumIngressRule  `json:"ingress,omitempty"`
+	Egress           []CiliumEgressRule   `json:"egress,omitempty"`
+}
+
+type CiliumIngressRule struct {
+	FromEndpoints []metav1.LabelSelector `json:"fromEndpoints,omitempty"`
+	FromCIDR      []string               `json:"fromCIDR,omitempty"`
+	ToPorts       []CiliumPortRule       `json:"toPorts,omitempty"`
+}
+
+type CiliumEgressRule struct {
+	ToEndpoints []metav1.LabelSelector `json:"toEndpoints,omitempty"`
+	ToCIDR      []string               `json:"toCIDR,omitempty"`
+	ToFQDNs     []CiliumFQDNSelector   `json:"toFQDNs,omitempty"`
+	ToPorts     []CiliumPortRule       `json:"toPorts,omitempty"`
+}
+
+type CiliumPortRule struct {
+	Ports []CiliumPortProtocol `json:"ports"`
+	Rules *CiliumL7Rules       `json:"rules,omitempty"`
+}
+
+type CiliumPortProtocol struct {
+	Port     string `json:"port"`
+	EndPort  int32  `json:"endPort,omitempty"`
+	Protocol string `json:"protocol"`
+}
+
+type CiliumL7Rules struct {
+	DNS []CiliumFQDNSelector `json:"dns,omitempty"`
+}
+
+type CiliumFQDNSelector struct {
+	MatchName    string `json:"matchName,omitempty"`
+	MatchPattern string `json:"matchPattern,omitempty"`
+}
+
+const (
+	// ciliumNamespaceLabel is the label Cilium gives to the endpoints with
+	// their namespace
+	ciliumNamespaceLabel = "k8s:io.kubernetes.pod.namespace"
+
+	dnsPort = 53
+)
+
+// ciliumSelector returns the Cilium endpoint selector matching the pods of a
+// Kubernetes network policy peer
+func ciliumSelector(peer networkingv1.NetworkPolicyPeer) metav1.LabelSelector {
+	selector := metav1.LabelSelector{MatchLabels: map[string]string{}}
+	if peer.PodSelector != nil {
+		for k, v := range peer.PodSelector.MatchLabels {
+			selector.MatchLabels[k] = v
+		}
+	}
+	if peer.NamespaceSelector != nil {
+		// The namespace selectors generated by eventToRule() only
+		// select the namespace by its name
+		if ns, ok := peer.NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"]; ok {
+			selector.MatchLabels[ciliumNamespaceLabel] = ns
+		}
+	}
+	if len(selector.MatchLabels) == 0 {
+		selector.MatchLabels = nil
+	}
+	return selector
+}
+
+// ciliumPeers splits the peers of a Kubernetes network policy rule in the
+// endpoints, the CIDRs and the FQDNs of a Cilium rule. The CIDRs with names in
+// fqdns are selected by these names.
+func ciliumPeers(peers []networkingv1.NetworkPolicyPeer, fqdns map[string][]string) (endpoints []metav1.LabelSelector, cidrs []string, names []string) {
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			if cidrNames, ok := fqdns[peer.IPBlock.CIDR]; ok {
+				names = mergeNames(names, cidrNames)
+				continue
+			}
+			cidrs = append(cidrs, peer.IPBlock.CIDR)
+			continue
+		}
+		endpoints = append(endpoints, ciliumSelector(peer))
+	}
+	return
+}
+
+// ciliumPorts returns the Cilium port rules of the ports of a Kubernetes
+// network policy rule. The DNS queries are allowed through the DNS proxy of
+// Cilium, for it to see the names resolved by the pods and to allow them to be
+// used by toFQDNs rules.
+func ciliumPorts(ports []networkingv1.NetworkPolicyPort, egress bool) []CiliumPortRule {
+	rules := []CiliumPortRule{}
+	for _, port := range ports {
+		portProtocol := CiliumPortProtocol{
+			Port:     port.Port.String(),
+			Protocol: string(*port.Protocol),
+		}
+		if port.EndPort != nil {
+			portProtocol.EndPort = *port.EndPort
+		}
+		rule := CiliumPortRule{Ports: []CiliumPortProtocol{portProtocol}}
+		if egress && port.EndPort == nil && port.Port.IntValue() == dnsPort {
+			rule.Rules = &CiliumL7Rules{
+				DNS: []CiliumFQDNSelector{{MatchPattern: "*"}},
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ciliumPolicy converts a network policy generated by GeneratePolicies() to a
+// CiliumNetworkPolicy. The CIDRs with names in fqdns are allowed by toFQDNs
+// rules.
+func ciliumPolicy(p networkingv1.NetworkPolicy, fqdns map[string][]string) CiliumNetworkPolicy {
+	policy := CiliumNetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CiliumNetworkPolicy",
+			APIVersion: "cilium.io/v2",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        p.Name,
+			Namespace:   p.Namespace,
+			Annotations: p.Annotations,
+		},
+		Spec: CiliumRule{
+			EndpointSelector: p.Spec.PodSelector,
+		},
+	}
+
+	for _, r := range p.Spec.Ingress {
+		endpoints, cidrs, _ := ciliumPeers(r.From, nil)
+		policy.Spec.Ingress = append(policy.Spec.Ingress, CiliumIngressRule{
+			FromEndpoints: endpoints,
+			FromCIDR:      cidrs,
+			ToPorts:       ciliumPorts(r.Ports, false),
+		})
+	}
+	for _, r := range p.Spec.Egress {
+		endpoints, cidrs, names := ciliumPeers(r.To, fqdns)
+		if len(endpoints) > 0 || len(cidrs) > 0 {
+			policy.Spec.Egress = append(policy.Spec.Egress, CiliumEgressRule{
+				ToEndpoints: endpoints,
+				ToCIDR:      cidrs,
+				ToPorts:     ciliumPorts(r.Ports, true),
+			})
+		}
+		// Cilium doesn't support toFQDNs with other peers in the same
+		// rule
+		if len(names) > 0 {
+			fqdnSelectors := make([]CiliumFQDNSelector, 0, len(names))
+			for _, name := range names {
+				fqdnSelectors = append(fqdnSelectors, CiliumFQDNSelector{MatchName: name})
+			}
+			policy.Spec.Egress = append(policy.Spec.Egress, CiliumEgressRule{
+				ToFQDNs: fqdnSelectors,
+				ToPorts: ciliumPorts(r.Ports, true),
+			})
+		}
+	}
+
+	// An empty rule enables the default deny of Cilium for the traffic
+	// without rules, as the Kubernetes network policy does
+	for _, policyType := range p.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeIngress && len(policy.Spec.Ingress) == 0 {
+			policy.Spec.Ingress = []CiliumIngressRule{{}}
+		}
+		if policyType == networkingv1.PolicyTypeEgress && len(policy.Spec.Egress) == 0 {
+			policy.Spec.Egress = []CiliumEgressRule{{}}
+		}
+	}
+
+	return policy
+}
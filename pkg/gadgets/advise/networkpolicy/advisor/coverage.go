@@ -0,0 +1,88 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// CoverageAnnotation is the annotation of the policies with the number of
+// events allowed by each of their rules and when they were seen, for the
+// reviewers to tell the rules of the regular traffic from the ones of one-off
+// connections
+const CoverageAnnotation = "advisor.inspektor-gadget.io/coverage"
+
+// coverage is the number of events allowed by a rule and the time span they
+// were seen over
+type coverage struct {
+	count int
+	first eventtypes.Time
+	last  eventtypes.Time
+}
+
+func (c *coverage) add(e types.Event) {
+	c.merge(coverage{count: 1, first: e.Timestamp, last: e.Timestamp})
+}
+
+func (c *coverage) merge(o coverage) {
+	if o.first != 0 && (c.first == 0 || o.first < c.first) {
+		c.first = o.first
+	}
+	if o.last > c.last {
+		c.last = o.last
+	}
+	c.count += o.count
+}
+
+func formatTime(t eventtypes.Time) string {
+	return time.Unix(0, int64(t)).UTC().Format(time.RFC3339)
+}
+
+func (c coverage) String() string {
+	events := fmt.Sprintf("%d events", c.count)
+	if c.count == 1 {
+		events = "1 event"
+	}
+	if c.first == 0 {
+		return events
+	}
+	return fmt.Sprintf("%s from %s to %s", events, formatTime(c.first), formatTime(c.last))
+}
+
+func ruleKey(ports []networkingv1.NetworkPolicyPort, peers []networkingv1.NetworkPolicyPeer) string {
+	return portsKey(ports) + "|" + peersKey(peers)
+}
+
+// coverageAnnotation returns the coverage of the rules of a policy, by rule,
+// given the coverages of the rules by ruleKey()
+func coverageAnnotation(ingress []networkingv1.NetworkPolicyIngressRule, egress []networkingv1.NetworkPolicyEgressRule,
+	ingressCoverages, egressCoverages map[string]coverage,
+) string {
+	lines := []string{}
+	for i, r := range ingress {
+		lines = append(lines, fmt.Sprintf("ingress[%d]: %s", i, ingressCoverages[ruleKey(r.Ports, r.From)]))
+	}
+	for i, r := range egress {
+		lines = append(lines, fmt.Sprintf("egress[%d]: %s", i, egressCoverages[ruleKey(r.Ports, r.To)]))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+)
+
+// DefaultMaxSeenEvents is the default number of events AddEvent() remembers to
+// skip their duplicates
+const DefaultMaxSeenEvents = 100000
+
+// seenEvents are the keys of the last events added, up to max, to tell the
+// events sent again apart from the new ones. The oldest keys are forgotten
+// first.
+type seenEvents struct {
+	max  int
+	keys map[string]struct{}
+	// order of the keys, used as a ring buffer once it has max keys
+	order []string
+	next  int
+}
+
+func newSeenEvents(max int) *seenEvents {
+	return &seenEvents{
+		max:  max,
+		keys: map[string]struct{}{},
+	}
+}
+
+// add adds key and returns false if it was already seen
+func (s *seenEvents) add(key string) bool {
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	if s.max <= 0 {
+		return true
+	}
+	if len(s.order) < s.max {
+		s.order = append(s.order, key)
+	} else {
+		delete(s.keys, s.order[s.next])
+		s.order[s.next] = key
+		s.next = (s.next + 1) % s.max
+	}
+	s.keys[key] = struct{}{}
+	return true
+}
+
+// eventKey returns the key identifying a connection seen at a given time: the
+// fields the rules of the policies are made of, i.e. the pod, the direction,
+// the port, the protocol and the peer, and the timestamp. The events without
+// timestamp can't be told apart from the other connections with the same rule,
+// so they don't have a key and are never skipped.
+func eventKey(e types.Event) string {
+	if e.Timestamp == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d|%s|%s/%s|%s|%s/%d|%s:%s/%s/%s", e.Timestamp, e.K8s.Node,
+		e.K8s.Namespace, e.K8s.PodName, e.PktType, e.Proto, e.Port,
+		e.DstEndpoint.Kind, e.DstEndpoint.Namespace, e.DstEndpoint.Name, e.DstEndpoint.Addr)
+}
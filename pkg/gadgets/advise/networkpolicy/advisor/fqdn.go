@@ -0,0 +1,83 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"sort"
+	"strings"
+
+	dnstypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// dnsAnswer is a name resolved by a pod to an address
+type dnsAnswer struct {
+	name      string
+	timestamp eventtypes.Time
+}
+
+// dnsAnswers are the names resolved by the pods, by namespace/pod/address
+type dnsAnswers map[string][]dnsAnswer
+
+func dnsAnswerKey(namespace, pod, addr string) string {
+	return namespace + "/" + pod + "/" + addr
+}
+
+func newDNSAnswers(events []dnstypes.Event) dnsAnswers {
+	answers := dnsAnswers{}
+	for _, e := range events {
+		if e.Type != eventtypes.NORMAL || e.Qr != dnstypes.DNSPktTypeResponse {
+			continue
+		}
+		name := strings.TrimSuffix(e.DNSName, ".")
+		if name == "" {
+			continue
+		}
+		for _, addr := range e.Addresses {
+			key := dnsAnswerKey(e.K8s.Namespace, e.K8s.PodName, addr)
+			answers[key] = append(answers[key], dnsAnswer{name: name, timestamp: e.Timestamp})
+		}
+	}
+	return answers
+}
+
+// names returns the names the pod of the event resolved to the address it
+// connects to. When the events have timestamps, only the names resolved
+// before the connection are used.
+func (d dnsAnswers) names(e types.Event) []string {
+	var names []string
+	for _, answer := range d[dnsAnswerKey(e.K8s.Namespace, e.K8s.PodName, e.DstEndpoint.Addr)] {
+		if answer.timestamp != 0 && e.Timestamp != 0 && answer.timestamp > e.Timestamp {
+			continue
+		}
+		names = mergeNames(names, []string{answer.name})
+	}
+	return names
+}
+
+// mergeNames returns the sorted union of the given names
+func mergeNames(names, others []string) []string {
+	for _, name := range others {
+		i := sort.SearchStrings(names, name)
+		if i < len(names) && names[i] == name {
+			continue
+		}
+		names = append(names, "")
+		copy(names[i+1:], names[i:])
+		names[i] = name
+	}
+	return names
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// labelPattern returns the regular expression matching the label keys of a
+// pattern given to IgnoreLabels(), or nil if the pattern is a plain label key
+func labelPattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+		return re, nil
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return nil, nil
+	}
+
+	// The wildcards of the globs match any character, including the
+	// slashes of the prefixes of the keys
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$"), nil
+}
+
+// IgnoreLabels adds patterns to the labels ignored to group the pods and to
+// select the peers. A pattern is a label key, a glob like "*.argoproj.io/*"
+// or a regular expression between slashes like "/-hash$/".
+func (a *NetworkPolicyAdvisor) IgnoreLabels(patterns ...string) error {
+	// Don't modify the map of the default labels, shared by the advisors
+	labelsToIgnore := make(map[string]struct{}, len(a.LabelsToIgnore)+len(patterns))
+	for k := range a.LabelsToIgnore {
+		labelsToIgnore[k] = struct{}{}
+	}
+
+	for _, pattern := range patterns {
+		re, err := labelPattern(pattern)
+		if err != nil {
+			return err
+		}
+		if re == nil {
+			labelsToIgnore[pattern] = struct{}{}
+			continue
+		}
+		a.labelsToIgnorePatterns = append(a.labelsToIgnorePatterns, re)
+	}
+	a.LabelsToIgnore = labelsToIgnore
+	return nil
+}
+
+// LoadLabelsToIgnoreFile adds the patterns of the labels to ignore of a file,
+// one by line. The empty lines and the ones starting with # are skipped.
+func (a *NetworkPolicyAdvisor) LoadLabelsToIgnoreFile(filename string) error {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return a.IgnoreLabels(patterns...)
+}
+
+// ignoredLabel tells whether the label with the given key is ignored
+func (a *NetworkPolicyAdvisor) ignoredLabel(key string) bool {
+	if _, ok := a.LabelsToIgnore[key]; ok {
+		return true
+	}
+	for _, re := range a.labelsToIgnorePatterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
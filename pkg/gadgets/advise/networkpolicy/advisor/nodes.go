@@ -0,0 +1,101 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// WarningsAnnotation is the annotation of the policies with the warnings about
+// their rules, to be reviewed before applying them
+const WarningsAnnotation = "advisor.inspektor-gadget.io/warnings"
+
+// nodeIPs returns the addresses of the nodes the pods of the events run on
+func nodeIPs(events []types.Event) map[string]struct{} {
+	ips := map[string]struct{}{}
+	for _, e := range events {
+		if e.PodHostIP != "" {
+			ips[e.PodHostIP] = struct{}{}
+		}
+	}
+	return ips
+}
+
+// nodeCIDR returns the CIDR of NodeCIDRs containing addr, if any
+func (a *NetworkPolicyAdvisor) nodeCIDR(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return ""
+	}
+	for _, cidr := range a.NodeCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err == nil && ipNet.Contains(ip) {
+			return cidr
+		}
+	}
+	return ""
+}
+
+// isNode tells whether addr is the address of a node, which is also the one of
+// the hostNetwork pods running on it
+func (a *NetworkPolicyAdvisor) isNode(addr string) bool {
+	if _, ok := a.nodeIPs[addr]; ok {
+		return true
+	}
+	return a.nodeCIDR(addr) != ""
+}
+
+// rawCIDR returns the CIDR of the ipBlock allowing a raw address: the CIDR of
+// the nodes for the addresses of the nodes, as the hostNetwork pods can run on
+// any of them.
+func (a *NetworkPolicyAdvisor) rawCIDR(addr string) string {
+	if cidr := a.nodeCIDR(addr); cidr != "" {
+		return cidr
+	}
+	return addr + "/32"
+}
+
+// nodeWarning returns the warning about the rules of the events allowing
+// addresses of nodes without the CIDR of the nodes, if any
+func (a *NetworkPolicyAdvisor) nodeWarning(events ...map[string]types.Event) string {
+	addrs := map[string]struct{}{}
+	for _, peers := range events {
+		for _, e := range peers {
+			if e.DstEndpoint.Kind != eventtypes.EndpointKindRaw {
+				continue
+			}
+			if a.isNode(e.DstEndpoint.Addr) && a.nodeCIDR(e.DstEndpoint.Addr) == "" {
+				addrs[e.DstEndpoint.Addr] = struct{}{}
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return ""
+	}
+
+	sorted := make([]string, 0, len(addrs))
+	for addr := range addrs {
+		sorted = append(sorted, addr)
+	}
+	sort.Strings(sorted)
+	return fmt.Sprintf("%s: addresses of nodes or of hostNetwork pods, which change with the node the pods run on. "+
+		"Allow the CIDR of the nodes instead.", strings.Join(sorted, ", "))
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// namedPorts are the named container ports of the pods, by namespace/name of
+// the pod and by protocol/port
+type namedPorts map[string]map[string]string
+
+func namedPortKey(protocol v1.Protocol, port int32) string {
+	if protocol == "" {
+		protocol = v1.ProtocolTCP
+	}
+	return fmt.Sprintf("%s/%d", protocol, port)
+}
+
+func newNamedPorts(pods []v1.Pod) namedPorts {
+	ports := namedPorts{}
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == "" {
+					continue
+				}
+				if ports[key] == nil {
+					ports[key] = map[string]string{}
+				}
+				ports[key][namedPortKey(port.Protocol, port.ContainerPort)] = port.Name
+			}
+		}
+	}
+	return ports
+}
+
+// name returns the name of the container port of the event on the pod the
+// policy rule applies the port to: the destination pod for the egress traffic,
+// and the local pod for the ingress traffic. It returns an empty string when
+// the port isn't named.
+func (n namedPorts) name(e types.Event) string {
+	protocol, ok := eventProtocol(e)
+	if !ok {
+		return ""
+	}
+
+	var key string
+	switch e.PktType {
+	case "OUTGOING":
+		// The ports of the services can differ from the ones of their
+		// pods
+		if e.DstEndpoint.Kind != eventtypes.EndpointKindPod {
+			return ""
+		}
+		key = e.DstEndpoint.Namespace + "/" + e.DstEndpoint.Name
+	case "HOST":
+		key = e.K8s.Namespace + "/" + e.K8s.PodName
+	default:
+		return ""
+	}
+	return n[key][namedPortKey(protocol, int32(e.Port))]
+}
+
+// LoadPodsFile loads the pods of the cluster, as given by "kubectl get pods -A
+// -o yaml", for the ports of the policies to be named after the container
+// ports of the pods. The workloads of the pods in the same list, as given by
+// "kubectl get pods,replicasets,deployments,statefulsets,daemonsets -A -o
+// yaml", are loaded too.
+func (a *NetworkPolicyAdvisor) LoadPodsFile(filename string) error {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return a.LoadPodsBuffer(buf)
+}
+
+func (a *NetworkPolicyAdvisor) LoadPodsBuffer(buf []byte) error {
+	list := struct {
+		Items []json.RawMessage `json:"items"`
+	}{}
+	if err := k8syaml.Unmarshal(buf, &list); err != nil {
+		return fmt.Errorf("parsing pods: %w", err)
+	}
+
+	a.Pods = nil
+	a.Workloads = nil
+	for i, item := range list.Items {
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(item, &typeMeta); err != nil {
+			return fmt.Errorf("parsing item %d: %w", i+1, err)
+		}
+
+		var obj metav1.Object
+		switch typeMeta.Kind {
+		case "Pod":
+			var pod v1.Pod
+			if err := json.Unmarshal(item, &pod); err != nil {
+				return fmt.Errorf("parsing item %d: %w", i+1, err)
+			}
+			a.Pods = append(a.Pods, pod)
+			continue
+		case "ReplicaSet":
+			obj = &appsv1.ReplicaSet{}
+		case "Deployment":
+			obj = &appsv1.Deployment{}
+		case "StatefulSet":
+			obj = &appsv1.StatefulSet{}
+		case "DaemonSet":
+			obj = &appsv1.DaemonSet{}
+		default:
+			// The other resources aren't needed
+			continue
+		}
+		if err := json.Unmarshal(item, obj); err != nil {
+			return fmt.Errorf("parsing item %d: %w", i+1, err)
+		}
+		a.Workloads = append(a.Workloads, obj)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// BlockedConnection is a connection of the events that the simulated policies
+// would block
+type BlockedConnection struct {
+	// Event is the first event of the connection
+	Event types.Event
+	// Ingress tells whether the connection is blocked by the ingress rules
+	// of the policies of the local pod, instead of the egress ones
+	Ingress bool
+	// Count is the number of events of the connection
+	Count int
+}
+
+func (c BlockedConnection) String() string {
+	e := c.Event
+	protocol, _ := eventProtocol(e)
+	peer := e.DstEndpoint.Addr
+	if e.DstEndpoint.Kind != eventtypes.EndpointKindRaw {
+		peer = e.DstEndpoint.Namespace + "/" + e.DstEndpoint.Name
+		if e.DstEndpoint.Name == "" {
+			peer = e.DstEndpoint.Namespace + "/" + e.DstEndpoint.Addr
+		}
+	}
+	pod := e.K8s.Namespace + "/" + e.K8s.PodName
+	if c.Ingress {
+		return fmt.Sprintf("ingress %s %s -> %s %s/%d (%d events)", e.DstEndpoint.Kind, peer, pod, protocol, e.Port, c.Count)
+	}
+	return fmt.Sprintf("egress %s -> %s %s %s/%d (%d events)", pod, e.DstEndpoint.Kind, peer, protocol, e.Port, c.Count)
+}
+
+// LoadPoliciesFile loads the NetworkPolicy resources of a YAML stream, like
+// the one given by FormatPolicies(), to be simulated by Simulate()
+func LoadPoliciesFile(filename string) ([]networkingv1.NetworkPolicy, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadPoliciesBuffer(buf)
+}
+
+func LoadPoliciesBuffer(buf []byte) ([]networkingv1.NetworkPolicy, error) {
+	policies := []networkingv1.NetworkPolicy{}
+	for i, doc := range bytes.Split(append([]byte("\n"), buf...), []byte("\n---")) {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		var typeMeta metav1.TypeMeta
+		if err := k8syaml.Unmarshal(doc, &typeMeta); err != nil {
+			return nil, fmt.Errorf("parsing document %d: %w", i+1, err)
+		}
+		if typeMeta.Kind != "NetworkPolicy" || typeMeta.APIVersion != "networking.k8s.io/v1" {
+			return nil, fmt.Errorf("document %d: unsupported resource %s %s, only networking.k8s.io/v1 NetworkPolicy is supported",
+				i+1, typeMeta.APIVersion, typeMeta.Kind)
+		}
+		var policy networkingv1.NetworkPolicy
+		if err := k8syaml.Unmarshal(doc, &policy); err != nil {
+			return nil, fmt.Errorf("parsing document %d: %w", i+1, err)
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// Simulate evaluates the events loaded against the given policies and returns
+// the connections they would block, grouped by local pod, peer, port and
+// protocol. The namespaces are only known by their name: the namespace
+// selectors are matched against the kubernetes.io/metadata.name label.
+func (a *NetworkPolicyAdvisor) Simulate(policies []networkingv1.NetworkPolicy) []BlockedConnection {
+	a.namedPorts = newNamedPorts(a.Pods)
+
+	blocked := map[string]*BlockedConnection{}
+	keys := []string{}
+	for _, e := range a.Events {
+		if !policyEvent(e) {
+			continue
+		}
+		ingress := e.PktType == "HOST"
+		if a.allowed(policies, e, ingress) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s:%s:%s", e.K8s.Namespace, e.K8s.PodName, e.PktType, a.networkPeerKey(e))
+		if c, ok := blocked[key]; ok {
+			c.Count++
+			continue
+		}
+		blocked[key] = &BlockedConnection{Event: e, Ingress: ingress, Count: 1}
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	ret := make([]BlockedConnection, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, *blocked[key])
+	}
+	return ret
+}
+
+// allowed tells whether the policies allow the connection of the event, in the
+// given direction for the local pod
+func (a *NetworkPolicyAdvisor) allowed(policies []networkingv1.NetworkPolicy, e types.Event, ingress bool) bool {
+	// No need to allow the connections to localhost
+	if e.DstEndpoint.Kind == eventtypes.EndpointKindRaw && e.DstEndpoint.Addr == "127.0.0.1" {
+		return true
+	}
+
+	policyType := networkingv1.PolicyTypeEgress
+	if ingress {
+		policyType = networkingv1.PolicyTypeIngress
+	}
+
+	// The pods not selected by any policy of this type aren't isolated
+	isolated := false
+	for _, p := range policies {
+		if p.Namespace != e.K8s.Namespace || !hasPolicyType(p, policyType) {
+			continue
+		}
+		if !selectorMatches(&p.Spec.PodSelector, e.PodLabels) {
+			continue
+		}
+		isolated = true
+
+		if ingress {
+			for _, r := range p.Spec.Ingress {
+				if a.ruleMatches(p.Namespace, r.From, r.Ports, e) {
+					return true
+				}
+			}
+		} else {
+			for _, r := range p.Spec.Egress {
+				if a.ruleMatches(p.Namespace, r.To, r.Ports, e) {
+					return true
+				}
+			}
+		}
+	}
+	return !isolated
+}
+
+// hasPolicyType tells whether the policy applies to the traffic of the given
+// type. Without policyTypes, policies apply to ingress, and to egress if they
+// have egress rules.
+func hasPolicyType(p networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	if len(p.Spec.PolicyTypes) == 0 {
+		return policyType == networkingv1.PolicyTypeIngress || len(p.Spec.Egress) > 0
+	}
+	for _, t := range p.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(set))
+}
+
+// ruleMatches tells whether a rule of a policy of the given namespace matches
+// the peer and the port of the event
+func (a *NetworkPolicyAdvisor) ruleMatches(namespace string, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort, e types.Event) bool {
+	if len(ports) > 0 {
+		portMatches := false
+		for _, port := range ports {
+			if a.portMatches(port, e) {
+				portMatches = true
+				break
+			}
+		}
+		if !portMatches {
+			return false
+		}
+	}
+
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if peerMatches(namespace, peer, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *NetworkPolicyAdvisor) portMatches(port networkingv1.NetworkPolicyPort, e types.Event) bool {
+	protocol, _ := eventProtocol(e)
+	portProtocol := v1.ProtocolTCP
+	if port.Protocol != nil {
+		portProtocol = *port.Protocol
+	}
+	if portProtocol != protocol {
+		return false
+	}
+
+	switch {
+	case port.Port == nil:
+		return true
+	case port.Port.Type == intstr.String:
+		return a.namedPorts.name(e) == port.Port.StrVal
+	case port.EndPort != nil:
+		return int32(e.Port) >= port.Port.IntVal && int32(e.Port) <= *port.EndPort
+	default:
+		return int32(e.Port) == port.Port.IntVal
+	}
+}
+
+func peerMatches(namespace string, peer networkingv1.NetworkPolicyPeer, e types.Event) bool {
+	if peer.IPBlock != nil {
+		return ipBlockMatches(peer.IPBlock, e.DstEndpoint.Addr)
+	}
+	if e.DstEndpoint.Kind == eventtypes.EndpointKindRaw {
+		return false
+	}
+
+	if peer.NamespaceSelector != nil {
+		namespaceLabels := map[string]string{namespaceNameLabel: e.DstEndpoint.Namespace}
+		if !selectorMatches(peer.NamespaceSelector, namespaceLabels) {
+			return false
+		}
+	} else if e.DstEndpoint.Namespace != namespace {
+		return false
+	}
+	return peer.PodSelector == nil || selectorMatches(peer.PodSelector, e.DstEndpoint.PodLabels)
+}
+
+func ipBlockMatches(ipBlock *networkingv1.IPBlock, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	_, ipNet, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil || !ipNet.Contains(ip) {
+		return false
+	}
+	for _, except := range ipBlock.Except {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err == nil && exceptNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
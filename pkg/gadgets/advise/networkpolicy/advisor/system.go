@@ -0,0 +1,118 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"net"
+	"sort"
+
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// DefaultExcludedNamespaces are the namespaces of the infrastructure of the
+// cluster, like kube-dns or metrics-server, whose traffic is excluded by
+// ExcludeSystemTraffic
+var DefaultExcludedNamespaces = []string{
+	"gadget",
+	"kube-node-lease",
+	"kube-public",
+	"kube-system",
+}
+
+// DefaultExcludedCIDRs are the addresses of the infrastructure of the nodes,
+// like node-local DNS, whose traffic is excluded by ExcludeSystemTraffic
+var DefaultExcludedCIDRs = []string{
+	"169.254.20.10/32",
+}
+
+// excluded tells whether the event is traffic from or to the excluded
+// namespaces or addresses
+func (a *NetworkPolicyAdvisor) excluded(e types.Event) bool {
+	return a.excludedNamespace(e.K8s.Namespace) || a.excludedPeer(e)
+}
+
+func (a *NetworkPolicyAdvisor) excludedNamespace(namespace string) bool {
+	return a.ExcludeSystemTraffic && slices.Contains(a.ExcludedNamespaces, namespace)
+}
+
+// excludedPeer tells whether the peer of the event is in the excluded
+// namespaces or addresses
+func (a *NetworkPolicyAdvisor) excludedPeer(e types.Event) bool {
+	if !a.ExcludeSystemTraffic {
+		return false
+	}
+	switch e.DstEndpoint.Kind {
+	case eventtypes.EndpointKindPod, eventtypes.EndpointKindService:
+		return a.excludedNamespace(e.DstEndpoint.Namespace)
+	case eventtypes.EndpointKindRaw:
+		ip := net.ParseIP(e.DstEndpoint.Addr)
+		for _, cidr := range a.ExcludedCIDRs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err == nil && ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDNS tells whether the event is a DNS query of a pod
+func isDNS(e types.Event) bool {
+	return e.PktType == "OUTGOING" && e.Port == dnsPort
+}
+
+/* sharedDNSRules returns, by namespace, the rules allowing the pods of the
+ * namespace to query the DNS servers queried by any of them, with both UDP and
+ * TCP. The DNS queries of the events are given by namespace.
+ */
+func (a *NetworkPolicyAdvisor) sharedDNSRules(queries map[string][]types.Event) map[string]networkingv1.NetworkPolicyEgressRule {
+	rules := map[string]networkingv1.NetworkPolicyEgressRule{}
+	for namespace, events := range queries {
+		peers := []networkingv1.NetworkPolicyPeer{}
+		keys := map[string]struct{}{}
+		for _, e := range events {
+			key := a.peerKey(e)
+			if _, ok := keys[key]; ok {
+				continue
+			}
+			keys[key] = struct{}{}
+			_, eventPeers := a.eventToRule(e)
+			peers = append(peers, eventPeers...)
+		}
+		if len(peers) == 0 {
+			continue
+		}
+		sort.Slice(peers, func(i, j int) bool {
+			return peerYAML(peers[i]) < peerYAML(peers[j])
+		})
+
+		port := intstr.FromInt(dnsPort)
+		tcp, udp := v1.ProtocolTCP, v1.ProtocolUDP
+		rules[namespace] = networkingv1.NetworkPolicyEgressRule{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Port: &port, Protocol: &tcp},
+				{Port: &port, Protocol: &udp},
+			},
+			To: peers,
+		}
+	}
+	return rules
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/network/types"
+)
+
+// Strategies to group the pods in policies
+const (
+	// GroupByLabels gives a policy for each set of labels of the pods,
+	// without LabelsToIgnore
+	GroupByLabels = "labels"
+	// GroupByOwner gives a policy for each workload owning the pods, like
+	// a Deployment, a StatefulSet or a DaemonSet, selecting them by the
+	// labels of its pod template. The pods without a known owner are
+	// grouped by labels.
+	GroupByOwner = "owner"
+)
+
+var GroupByStrategies = []string{
+	GroupByLabels,
+	GroupByOwner,
+}
+
+// workload is the controller owning pods
+type workload struct {
+	kind string
+	name string
+	// templateLabels are the labels of the pod template of the workload.
+	// If the workload isn't loaded, they are the labels common to all its
+	// pods.
+	templateLabels map[string]string
+}
+
+// workloads are the workloads owning the pods, by namespace/name of the pod
+type workloads map[string]workload
+
+func objectKey(kind, namespace, name string) string {
+	return kind + ":" + namespace + "/" + name
+}
+
+func newWorkloads(pods []v1.Pod, objects []metav1.Object) workloads {
+	byName := map[string]metav1.Object{}
+	for _, obj := range objects {
+		byName[objectKey(workloadKind(obj), obj.GetNamespace(), obj.GetName())] = obj
+	}
+
+	ret := workloads{}
+	// The labels common to the pods of the workloads, by objectKey()
+	commonLabels := map[string]map[string]string{}
+	for i := range pods {
+		pod := &pods[i]
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil {
+			continue
+		}
+		w := workload{kind: owner.Kind, name: owner.Name}
+
+		// The pods of Deployments are owned by their ReplicaSets, named
+		// after the Deployment and the hash of the pod template
+		if w.kind == "ReplicaSet" {
+			if rs, ok := byName[objectKey(w.kind, pod.Namespace, w.name)]; ok {
+				if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil && rsOwner.Kind == "Deployment" {
+					w = workload{kind: rsOwner.Kind, name: rsOwner.Name}
+				}
+			} else if hash := pod.Labels["pod-template-hash"]; hash != "" && strings.HasSuffix(w.name, "-"+hash) {
+				w = workload{kind: "Deployment", name: strings.TrimSuffix(w.name, "-"+hash)}
+			}
+		}
+
+		key := objectKey(w.kind, pod.Namespace, w.name)
+		if obj, ok := byName[key]; ok {
+			w.templateLabels = templateLabels(obj)
+		} else {
+			commonLabels[key] = intersectLabels(commonLabels[key], pod.Labels)
+		}
+		ret[pod.Namespace+"/"+pod.Name] = w
+	}
+
+	for podKey, w := range ret {
+		if w.templateLabels == nil {
+			namespace, _, _ := strings.Cut(podKey, "/")
+			w.templateLabels = commonLabels[objectKey(w.kind, namespace, w.name)]
+			ret[podKey] = w
+		}
+	}
+	return ret
+}
+
+// intersectLabels returns the labels of both common and labels, or labels if
+// common is nil
+func intersectLabels(common, labels map[string]string) map[string]string {
+	ret := map[string]string{}
+	for k, v := range labels {
+		if c, ok := common[k]; common == nil || (ok && c == v) {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+func workloadKind(obj metav1.Object) string {
+	switch obj.(type) {
+	case *appsv1.ReplicaSet:
+		return "ReplicaSet"
+	case *appsv1.Deployment:
+		return "Deployment"
+	case *appsv1.StatefulSet:
+		return "StatefulSet"
+	case *appsv1.DaemonSet:
+		return "DaemonSet"
+	}
+	return ""
+}
+
+func templateLabels(obj metav1.Object) map[string]string {
+	switch w := obj.(type) {
+	case *appsv1.ReplicaSet:
+		return w.Spec.Template.Labels
+	case *appsv1.Deployment:
+		return w.Spec.Template.Labels
+	case *appsv1.StatefulSet:
+		return w.Spec.Template.Labels
+	case *appsv1.DaemonSet:
+		return w.Spec.Template.Labels
+	}
+	return nil
+}
+
+// policyTarget returns the key grouping the pod of the event with the other
+// pods of the same policy, the name of the policy and the labels selecting
+// these pods
+func (a *NetworkPolicyAdvisor) policyTarget(e types.Event) (key, name string, labels map[string]string) {
+	if a.GroupBy == GroupByOwner {
+		if w, ok := a.workloads[e.K8s.Namespace+"/"+e.K8s.PodName]; ok {
+			return e.K8s.Namespace + ":" + w.kind + "/" + w.name, w.name + "-network", a.labelFilter(w.templateLabels)
+		}
+	}
+
+	name = e.K8s.PodName
+	if e.PodOwner != "" {
+		name = e.PodOwner
+	}
+	return a.localPodKey(e), name + "-network", a.labelFilter(e.PodLabels)
+}
+
+// peerLabels returns the labels selecting the peer pod of the event: the ones
+// of the pod template of its workload with GroupByOwner, as for the policy of
+// the peer
+func (a *NetworkPolicyAdvisor) peerLabels(e types.Event) map[string]string {
+	if a.GroupBy == GroupByOwner {
+		if w, ok := a.workloads[e.DstEndpoint.Namespace+"/"+e.DstEndpoint.Name]; ok {
+			return a.labelFilter(w.templateLabels)
+		}
+	}
+	return a.labelFilter(e.DstEndpoint.PodLabels)
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PolicyWriter writes the policies given by WritePolicies()
+type PolicyWriter interface {
+	// WritePolicy writes the YAML of a policy. The namespace is empty for
+	// the cluster-scoped policies.
+	WritePolicy(namespace, name string, policy []byte) error
+}
+
+type streamWriter struct {
+	w     io.Writer
+	count int
+}
+
+// NewStreamWriter returns a PolicyWriter writing the policies into w, as a
+// single YAML stream
+func NewStreamWriter(w io.Writer) PolicyWriter {
+	return &streamWriter{w: w}
+}
+
+func (s *streamWriter) WritePolicy(namespace, name string, policy []byte) error {
+	if s.count > 0 {
+		if _, err := io.WriteString(s.w, "---\n"); err != nil {
+			return err
+		}
+	}
+	s.count++
+	_, err := s.w.Write(policy)
+	return err
+}
+
+type directoryWriter struct {
+	dir string
+}
+
+// NewDirectoryWriter returns a PolicyWriter writing each policy into a file of
+// its own in dir: <namespace>/<name>.yaml, or <name>.yaml for the
+// cluster-scoped policies. The existing files are overwritten.
+func NewDirectoryWriter(dir string) PolicyWriter {
+	return &directoryWriter{dir: dir}
+}
+
+func (d *directoryWriter) WritePolicy(namespace, name string, policy []byte) error {
+	dir := filepath.Join(d.dir, namespace)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".yaml"), policy, 0o644)
+}
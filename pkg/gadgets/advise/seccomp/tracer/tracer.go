@@ -18,11 +18,13 @@ package tracer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfgen"
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
@@ -54,10 +56,16 @@ type Tracer struct {
 	containers map[*containercollection.Container][]string
 }
 
+// Name under which the map of the tracers created with NewTracer is pinned
+const pinName = "advise/seccomp-profile"
+
+// NewTracer creates a tracer whose map is pinned, so the syscalls it recorded
+// are kept if the daemon restarts and the next tracer continues from them.
+// Unpin must be called to drop them once they aren't needed anymore.
 func NewTracer() (*Tracer, error) {
 	t := &Tracer{}
 
-	if err := t.install(); err != nil {
+	if err := t.install(gadgets.PinnedMapsPath(pinName)); err != nil {
 		t.Close()
 		return nil, err
 	}
@@ -65,7 +73,9 @@ func NewTracer() (*Tracer, error) {
 	return t, nil
 }
 
-func (t *Tracer) install() error {
+// install loads and attaches the eBPF program. If pinPath isn't empty, the map
+// is pinned there.
+func (t *Tracer) install(pinPath string) error {
 	spec, err := loadSeccomp()
 	if err != nil {
 		return fmt.Errorf("loading asset: %w", err)
@@ -77,7 +87,23 @@ func (t *Tracer) install() error {
 		},
 	}
 
-	if err := spec.LoadAndAssign(&t.objs, &opts); err != nil {
+	if pinPath != "" {
+		spec.Maps["syscalls_per_mntns"].Pinning = ebpf.PinByName
+		if _, err := gadgets.PinMaps(spec, &opts, pinPath); err != nil {
+			return err
+		}
+	}
+
+	err = spec.LoadAndAssign(&t.objs, &opts)
+	if pinPath != "" && errors.Is(err, ebpf.ErrMapIncompatible) {
+		// The map of the previous version can't be reused
+		log.Warnf("dropping the seccomp map pinned in %s: %v", pinPath, err)
+		if err := gadgets.RemovePinnedMaps(spec, pinPath); err != nil {
+			return fmt.Errorf("removing pinned map: %w", err)
+		}
+		err = spec.LoadAndAssign(&t.objs, &opts)
+	}
+	if err != nil {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
@@ -131,6 +157,15 @@ func (t *Tracer) Delete(mntns uint64) {
 	t.objs.SyscallsPerMntns.Delete(mntns)
 }
 
+// Unpin removes the pinned map of the tracer, the syscalls recorded are lost
+// when the tracer is closed.
+func (t *Tracer) Unpin() error {
+	if t.objs.SyscallsPerMntns == nil {
+		return nil
+	}
+	return t.objs.SyscallsPerMntns.Unpin()
+}
+
 // Close closes the tracer
 // TODO: Unexport this function when the refactoring is done
 func (t *Tracer) Close() {
@@ -149,7 +184,7 @@ func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
 
 func (t *Tracer) RunWithResult(gadgetCtx gadgets.GadgetContext) ([]byte, error) {
 	defer t.Close()
-	if err := t.install(); err != nil {
+	if err := t.install(""); err != nil {
 		return nil, fmt.Errorf("installing tracer: %w", err)
 	}
 
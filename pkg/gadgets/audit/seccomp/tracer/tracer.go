@@ -29,6 +29,7 @@ import (
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/seccomp/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -40,7 +41,7 @@ type Tracer struct {
 	eventCallback func(*types.Event)
 
 	objs   auditseccompObjects
-	reader *perf.Reader
+	reader *eventreader.PerfReader
 
 	// progLink links the BPF program to the tracepoint.
 	// A reference is kept so it can be closed it explicitly, otherwise
@@ -82,7 +83,7 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf spec: %w", err)
 	}
 
-	t.reader, err = perf.NewReader(t.objs.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = eventreader.NewPerfReader(t.objs.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("getting a perf reader: %w", err)
 	}
@@ -110,8 +111,7 @@ func (t *Tracer) run() {
 		}
 
 		if record.LostSamples > 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			t.eventCallback(types.Base(eventtypes.LostSamples(record.LostSamples)))
 			continue
 		}
 
@@ -17,6 +17,10 @@ package gadgets
 const (
 	PinPath = "/sys/fs/bpf/gadget"
 
+	// Directory where the gadgets pin the maps whose state must survive
+	// restarts of the daemon. Keep in sync with gadget-container/cleanup.sh.
+	MapsPinPath = PinPath + "/maps"
+
 	// The Trace custom resource is preferably in the "gadget" namespace
 	TraceDefaultNamespace = "gadget"
 
@@ -42,6 +46,35 @@ const (
 	// Keep in sync with pkg/gadgets/common/types.h
 	L4EndpointTypeName = "gadget_l4endpoint_t"
 
-	// Name of the type to store a mount namespace inode id
+	// Names of the types to store a mount and a network namespace inode id.
+	// Keep in sync with include/gadget/types.h
 	MntNsIdTypeName = "mnt_ns_id_t"
+	NetNsIdTypeName = "gadget_netns_id_t"
+
+	// Names of the types that gadgets should use to store a timestamp as
+	// returned by bpf_ktime_get_boot_ns(), an error number, a number of bytes
+	// and a duration in nanoseconds, for them to be shown properly.
+	// Keep in sync with include/gadget/types.h
+	TimestampTypeName = "gadget_timestamp_t"
+	ErrnoTypeName     = "gadget_errno_t"
+	BytesTypeName     = "gadget_bytes_t"
+	DurationTypeName  = "gadget_duration_t"
+
+	// Names of the types that gadgets should use to store the ID of a kernel
+	// or user stack, and of the stack trace map they are stored in, for them
+	// to be shown as the frames of the stacks.
+	// Keep in sync with include/gadget/types.h and include/gadget/stacks.h
+	KernelStackTypeName = "gadget_kernel_stack_t"
+	UserStackTypeName   = "gadget_user_stack_t"
+	StacksMapName       = "gadget_stacks"
+
+	// Name of the per CPU map counting the events lost by the gadget.
+	// Keep in sync with the name used in include/gadget/buffer.h.
+	LostSamplesMapName = "gadget_lost_samples"
+
+	// Name of the hash map the events are counted in when the gadget runs in
+	// aggregation mode, and of the constant enabling it.
+	// Keep in sync with the names used in include/gadget/aggregation.h.
+	AggregationMapName = "gadget_aggregation"
+	AggregateConstName = "gadget_aggregate"
 )
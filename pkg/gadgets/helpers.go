@@ -32,6 +32,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfgen"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kernelbtf"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -198,7 +199,7 @@ func DetectBpfKtimeGetBootNs() bool {
 	bpfKtimeGetBootNsOnce.Do(func() {
 		bpfKtimeGetBootNsExists = false
 
-		btfSpec, err := btf.LoadKernelSpec()
+		btfSpec, err := kernelbtf.Spec()
 		if err != nil {
 			return
 		}
@@ -21,6 +21,9 @@ import (
 	"syscall"
 )
 
+// ipprotoSCTP is IPPROTO_SCTP, which syscall doesn't define on every OS
+const ipprotoSCTP = 132
+
 func ProtoString(proto int) string {
 	// proto definitions:
 	// https://www.iana.org/assignments/protocol-numbers/protocol-numbers.xhtml
@@ -30,6 +33,8 @@ func ProtoString(proto int) string {
 		protoStr = "TCP"
 	case syscall.IPPROTO_UDP:
 		protoStr = "UDP"
+	case ipprotoSCTP:
+		protoStr = "SCTP"
 	}
 	return protoStr
 }
@@ -0,0 +1,243 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventreader provides readers of perf and ring buffers that read the
+// events in batches. When the event rate is low, the readers wait for the
+// kernel to notify new events, as the cilium/ebpf readers do. When it's high,
+// they stop waiting for notifications and instead poll the buffers at an
+// interval adapted to the rate, so each wakeup processes a batch of events
+// instead of a few of them.
+package eventreader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+const (
+	// targetBatchSize is the number of events the poll interval is adapted
+	// to read at each wakeup.
+	targetBatchSize = 128
+
+	// Bounds of the poll interval. The buffers are polled at least every
+	// minPollInterval, to limit the CPU usage, and at most every
+	// maxPollInterval, to limit the latency of the events and the risk of
+	// filling the buffers. The readers wait for notifications when the rate is
+	// too low to read targetBatchSize events every maxPollInterval.
+	minPollInterval = time.Millisecond
+	maxPollInterval = 50 * time.Millisecond
+)
+
+// nextPollInterval returns how long to wait before reading the buffers again,
+// after records events were read and elapsed time passed since the previous
+// batch. Zero means waiting for the next notification of the kernel. The
+// interval is reset to its minimum when events were lost.
+func nextPollInterval(records int, elapsed time.Duration, lost bool) time.Duration {
+	if records == 0 {
+		return 0
+	}
+	if lost {
+		return minPollInterval
+	}
+
+	interval := time.Duration(int64(elapsed) * targetBatchSize / int64(records))
+	switch {
+	case interval > maxPollInterval:
+		return 0
+	case interval < minPollInterval:
+		return minPollInterval
+	default:
+		return interval
+	}
+}
+
+// batcher keeps track of the batches of events of a reader and decides when
+// to read the next one.
+type batcher struct {
+	// draining is true while the events of the current batch are read
+	draining bool
+	records  int
+	lost     bool
+	last     time.Time
+	interval time.Duration
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newBatcher() *batcher {
+	return &batcher{
+		last: time.Now(),
+		done: make(chan struct{}),
+	}
+}
+
+// deadline returns the deadline of the next read: events of the current batch
+// are read without blocking, the next batch is waited for when not polling.
+func (b *batcher) deadline() time.Time {
+	if b.draining || b.interval > 0 {
+		// A deadline in the past makes the reads non-blocking
+		return time.Unix(0, 1)
+	}
+	return time.Time{}
+}
+
+// add records an event was read.
+func (b *batcher) add(lost bool) {
+	b.draining = true
+	b.records++
+	b.lost = b.lost || lost
+}
+
+// endBatch is called once all the available events were read. It adapts the
+// poll interval and waits for it to pass. It returns false if the reader was
+// closed in the meantime.
+func (b *batcher) endBatch() bool {
+	if b.draining || b.interval > 0 {
+		now := time.Now()
+		b.interval = nextPollInterval(b.records, now.Sub(b.last), b.lost)
+		b.last = now
+		b.draining = false
+		b.records = 0
+		b.lost = false
+	}
+
+	if b.interval == 0 {
+		return true
+	}
+
+	timer := time.NewTimer(b.interval)
+	defer timer.Stop()
+	select {
+	case <-b.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (b *batcher) close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+}
+
+// PerfReader reads the events of a perf event array in batches.
+type PerfReader struct {
+	reader   *perf.Reader
+	batcher  *batcher
+	deadline time.Time
+}
+
+// NewPerfReader creates a reader of the perf event array with per CPU buffers
+// of perCPUBuffer bytes.
+func NewPerfReader(array *ebpf.Map, perCPUBuffer int) (*PerfReader, error) {
+	reader, err := perf.NewReader(array, perCPUBuffer)
+	if err != nil {
+		return nil, err
+	}
+	return &PerfReader{
+		reader:  reader,
+		batcher: newBatcher(),
+	}, nil
+}
+
+// Read returns the next record. It returns an error wrapping perf.ErrClosed
+// once the reader is closed.
+func (r *PerfReader) Read() (perf.Record, error) {
+	for {
+		if deadline := r.batcher.deadline(); !deadline.Equal(r.deadline) {
+			r.reader.SetDeadline(deadline)
+			r.deadline = deadline
+		}
+
+		record, err := r.reader.Read()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if !r.batcher.endBatch() {
+				return perf.Record{}, fmt.Errorf("perf ringbuffer: %w", perf.ErrClosed)
+			}
+			continue
+		}
+		if err != nil {
+			return record, err
+		}
+
+		r.batcher.add(record.LostSamples > 0)
+		return record, nil
+	}
+}
+
+// Close frees the resources of the reader and interrupts Read.
+func (r *PerfReader) Close() error {
+	r.batcher.close()
+	return r.reader.Close()
+}
+
+// RingbufReader reads the events of a ring buffer in batches.
+type RingbufReader struct {
+	reader   *ringbuf.Reader
+	batcher  *batcher
+	deadline time.Time
+}
+
+// NewRingbufReader creates a reader of the ring buffer.
+func NewRingbufReader(ringbufMap *ebpf.Map) (*RingbufReader, error) {
+	reader, err := ringbuf.NewReader(ringbufMap)
+	if err != nil {
+		return nil, err
+	}
+	return &RingbufReader{
+		reader:  reader,
+		batcher: newBatcher(),
+	}, nil
+}
+
+// Read returns the next record. It returns an error wrapping ringbuf.ErrClosed
+// once the reader is closed.
+func (r *RingbufReader) Read() (ringbuf.Record, error) {
+	for {
+		if deadline := r.batcher.deadline(); !deadline.Equal(r.deadline) {
+			r.reader.SetDeadline(deadline)
+			r.deadline = deadline
+		}
+
+		record, err := r.reader.Read()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if !r.batcher.endBatch() {
+				return ringbuf.Record{}, fmt.Errorf("ringbuffer: %w", ringbuf.ErrClosed)
+			}
+			continue
+		}
+		if err != nil {
+			return record, err
+		}
+
+		// Reserve failures aren't reported to the reader
+		r.batcher.add(false)
+		return record, nil
+	}
+}
+
+// Close frees the resources of the reader and interrupts Read.
+func (r *RingbufReader) Close() error {
+	r.batcher.close()
+	return r.reader.Close()
+}
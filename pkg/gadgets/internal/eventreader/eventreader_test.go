@@ -0,0 +1,102 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventreader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextPollInterval(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		records  int
+		elapsed  time.Duration
+		lost     bool
+		expected time.Duration
+	}{
+		"no_events": {
+			records:  0,
+			elapsed:  time.Second,
+			expected: 0,
+		},
+		"low_rate": {
+			records:  10,
+			elapsed:  time.Second,
+			expected: 0,
+		},
+		"adapted_to_rate": {
+			// 12800 events per second
+			records:  64,
+			elapsed:  5 * time.Millisecond,
+			expected: 10 * time.Millisecond,
+		},
+		"highest_rate": {
+			records:  100000,
+			elapsed:  10 * time.Millisecond,
+			expected: minPollInterval,
+		},
+		"lost_events": {
+			records:  64,
+			elapsed:  5 * time.Millisecond,
+			lost:     true,
+			expected: minPollInterval,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, nextPollInterval(test.records, test.elapsed, test.lost))
+		})
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	t.Parallel()
+
+	b := newBatcher()
+	require.True(t, b.deadline().IsZero(), "waiting for the first event")
+
+	b.add(false)
+	require.False(t, b.deadline().IsZero(), "draining the batch")
+
+	// A large batch in a short time switches to polling
+	b.last = time.Now()
+	for i := 0; i < 10*targetBatchSize; i++ {
+		b.add(false)
+	}
+	require.True(t, b.endBatch())
+	require.Greater(t, b.interval, time.Duration(0))
+	require.False(t, b.deadline().IsZero(), "polling")
+
+	// Nothing was read after waiting, go back to waiting for notifications
+	require.True(t, b.endBatch())
+	require.Equal(t, time.Duration(0), b.interval)
+	require.True(t, b.deadline().IsZero())
+
+	// Closing interrupts the wait
+	b.last = time.Now()
+	for i := 0; i < 10*targetBatchSize; i++ {
+		b.add(false)
+	}
+	b.close()
+	require.False(t, b.endBatch())
+}
@@ -49,6 +49,7 @@ import (
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/socketenricher"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/rawsock"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
@@ -74,7 +75,7 @@ type Tracer[Event any] struct {
 	dispatcherMap  *ebpf.Map
 	collection     *ebpf.Collection
 	prog           *ebpf.Program
-	perfRd         *perf.Reader
+	perfRd         *eventreader.PerfReader
 
 	// key: network namespace inode number
 	// value: Tracelet
@@ -226,7 +227,7 @@ func (t *Tracer[Event]) Run(
 		return fmt.Errorf("creating BPF collection: %w", err)
 	}
 
-	t.perfRd, err = perf.NewReader(t.collection.Maps[bpfPerfMapName], gadgets.PerfBufferPages*os.Getpagesize())
+	t.perfRd, err = eventreader.NewPerfReader(t.collection.Maps[bpfPerfMapName], gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("getting a perf reader: %w", err)
 	}
@@ -324,8 +325,7 @@ func (t *Tracer[Event]) listen(
 		}
 
 		if record.LostSamples != 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventHandler(baseEvent(types.Warn(msg)))
+			t.eventHandler(baseEvent(types.LostSamples(record.LostSamples)))
 			continue
 		}
 
@@ -16,7 +16,6 @@ package socketenricher
 
 import (
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/cilium/ebpf"
@@ -27,6 +26,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
 	bpfiterns "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/bpf-iter-ns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/shared"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target $TARGET -cc clang -cflags ${CFLAGS} socketenricher ./bpf/socket-enricher.bpf.c -- -I./bpf/
@@ -42,31 +42,53 @@ const (
 // This makes it possible for network gadgets to access that information and
 // display it directly from the BPF code. Example of such code in the dns and
 // sni gadgets.
+//
+// The eBPF programs and map are loaded once and shared by all the gadgets
+// running at the same time, they're removed when the last one closes its
+// SocketEnricher.
 type SocketEnricher struct {
+	enricher *enricher
+	release  func()
+}
+
+type enricher struct {
 	objs     socketenricherObjects
 	objsIter socketsiterObjects
 	links    []link.Link
 
-	closeOnce sync.Once
-	done      chan bool
+	done chan bool
 }
 
+var sharedEnricher = shared.New(newEnricher, (*enricher).close)
+
 func (se *SocketEnricher) SocketsMap() *ebpf.Map {
-	return se.objs.GadgetSockets
+	return se.enricher.objs.GadgetSockets
 }
 
 func NewSocketEnricher() (*SocketEnricher, error) {
-	se := &SocketEnricher{}
+	e, release, err := sharedEnricher.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SocketEnricher{
+		enricher: e,
+		release:  release,
+	}, nil
+}
+
+func newEnricher() (*enricher, error) {
+	se := &enricher{}
 
 	if err := se.start(); err != nil {
-		se.Close()
+		se.close()
 		return nil, err
 	}
 
 	return se, nil
 }
 
-func (se *SocketEnricher) start() error {
+func (se *enricher) start() error {
 	specIter, err := loadSocketsiter()
 	if err != nil {
 		return fmt.Errorf("loading socketsiter asset: %w", err)
@@ -203,7 +225,7 @@ func (se *SocketEnricher) start() error {
 	return nil
 }
 
-func (se *SocketEnricher) cleanupDeletedSockets(cleanupIter *link.Iter) {
+func (se *enricher) cleanupDeletedSockets(cleanupIter *link.Iter) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 	for {
@@ -219,23 +241,27 @@ func (se *SocketEnricher) cleanupDeletedSockets(cleanupIter *link.Iter) {
 	}
 }
 
-func (se *SocketEnricher) cleanupDeletedSocketsNow(cleanupIter *link.Iter) error {
+func (se *enricher) cleanupDeletedSocketsNow(cleanupIter *link.Iter) error {
 	// No need to change pidns for this iterator because cleanupIter is an
 	// iterator on a map, not on tasks.
 	_, err := bpfiterns.ReadOnCurrentPidNs(cleanupIter)
 	return err
 }
 
+// Close releases the socket enricher, it's removed if no other gadget uses it.
 func (se *SocketEnricher) Close() {
-	se.closeOnce.Do(func() {
-		if se.done != nil {
-			close(se.done)
-		}
-	})
+	se.release()
+}
+
+func (se *enricher) close() {
+	if se.done != nil {
+		close(se.done)
+	}
 
 	for _, l := range se.links {
 		gadgets.CloseLink(l)
 	}
 	se.links = nil
 	se.objs.Close()
+	se.objsIter.Close()
 }
@@ -42,6 +42,46 @@ func TestSocketEnricherCreate(t *testing.T) {
 	if tracer == nil {
 		t.Fatal("Returned tracer was nil")
 	}
+	tracer.Close()
+}
+
+func TestSocketEnricherShared(t *testing.T) {
+	t.Parallel()
+
+	utilstest.RequireRoot(t)
+	utilstest.HostInit(t)
+
+	tracer1, err := NewSocketEnricher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tracer1.Close)
+
+	tracer2, err := NewSocketEnricher()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tracer1.SocketsMap() != tracer2.SocketsMap() {
+		t.Fatal("Socket enrichers running at the same time don't share their map")
+	}
+
+	// Closing one of them must not affect the other one
+	tracer2.Close()
+	if _, err := tracer1.SocketsMap().Info(); err != nil {
+		t.Fatalf("Map closed while still used: %v", err)
+	}
+}
+
+// newUnsharedSocketEnricher returns a socket enricher that isn't shared with
+// the other tests, so it only knows the sockets found by the iterators when it
+// starts.
+func newUnsharedSocketEnricher() (*SocketEnricher, error) {
+	e, err := newEnricher()
+	if err != nil {
+		return nil, err
+	}
+	return &SocketEnricher{enricher: e, release: e.close}, nil
 }
 
 func TestSocketEnricherStopIdempotent(t *testing.T) {
@@ -266,7 +306,7 @@ func TestSocketEnricherBind(t *testing.T) {
 			})
 
 			// Start the late tracer after the event has been generated
-			lateTracer, err := NewSocketEnricher()
+			lateTracer, err := newUnsharedSocketEnricher()
 			if err != nil {
 				t.Fatal(err)
 			}
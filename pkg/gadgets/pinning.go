@@ -0,0 +1,87 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package gadgets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/cilium/ebpf"
+)
+
+var invalidPinNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// PinnedMapsPath returns the directory where the gadget identified by names,
+// e.g. its repository and an instance key, pins its maps. They're kept there
+// when the gadget stops, so the next instance of the gadget, possibly in a new
+// daemon, reuses them with the state they accumulated.
+func PinnedMapsPath(names ...string) string {
+	elems := []string{MapsPinPath}
+	for _, name := range names {
+		elems = append(elems, invalidPinNameChars.ReplaceAllString(name, "_"))
+	}
+	return filepath.Join(elems...)
+}
+
+// PinMaps makes the maps of spec declared with the pinning attribute of libbpf
+// (LIBBPF_PIN_BY_NAME) be pinned in path, or reused from there if they exist
+// already, when the collection is loaded with opts. It returns whether any map
+// is pinned. If path is empty, the maps aren't pinned and start without state.
+func PinMaps(spec *ebpf.CollectionSpec, opts *ebpf.CollectionOptions, path string) (bool, error) {
+	pinned := false
+	for _, m := range spec.Maps {
+		if m.Pinning != ebpf.PinByName {
+			continue
+		}
+		if path == "" {
+			m.Pinning = ebpf.PinNone
+			continue
+		}
+		pinned = true
+	}
+	if !pinned {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return false, fmt.Errorf("creating folder for pinning bpf maps: %w", err)
+	}
+	opts.Maps.PinPath = path
+
+	return true, nil
+}
+
+// RemovePinnedMaps removes the maps of spec pinned in path, dropping their
+// state. Maps still used by a loaded collection keep working until it's closed.
+func RemovePinnedMaps(spec *ebpf.CollectionSpec, path string) error {
+	var errs []error
+	for _, m := range spec.Maps {
+		if m.Pinning != ebpf.PinByName {
+			continue
+		}
+		if err := os.Remove(filepath.Join(path, m.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	// Only succeeds if there isn't anything else in it
+	os.Remove(path)
+
+	return errors.Join(errs...)
+}
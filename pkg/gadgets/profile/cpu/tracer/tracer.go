@@ -223,10 +223,11 @@ func (t *Tracer) collectResult() ([]byte, error) {
 		return keysCounts[i].value != keysCounts[j].value
 	})
 
-	kAllSyms, err := kallsyms.NewKAllSyms()
+	kAllSyms, release, err := kallsyms.NewSharedKAllSyms()
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 
 	reports := make([]types.Report, len(keysCounts))
 	for i, keyVal := range keysCounts {
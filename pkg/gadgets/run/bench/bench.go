@@ -0,0 +1,279 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+// Package bench measures the performance of the event pipeline of a gadget:
+// the gadget runs while the generator synthesizes the activity it traces, and
+// its throughput, CPU and memory usage and lost events are reported, to
+// compare them release over release.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/tracer"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/generator"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	tracercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/tracer-collection"
+)
+
+type Config struct {
+	// Name of the gadget, only used in the report
+	Name string
+
+	ProgContent []byte
+	Metadata    *types.GadgetMetadata
+
+	// BufferPages overrides the size of the buffer of the gadget when it
+	// isn't 0, to find the size that doesn't lose events
+	BufferPages uint32
+
+	// Duration of the measurement
+	Duration time.Duration
+
+	// Activity synthesized while the gadget runs. Its Count is ignored,
+	// the activity lasts Duration.
+	Activity generator.SynthesizeConfig
+
+	// SkipBaseline doesn't measure the usage of the generator alone, to
+	// subtract it from the usage of the gadget
+	SkipBaseline bool
+}
+
+// Usage is the resources used by the process during a measurement.
+type Usage struct {
+	// CPUSeconds is the user and system CPU time
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// AllocatedBytes is the memory allocated on the heap
+	AllocatedBytes uint64 `json:"allocatedBytes"`
+	// Allocations is the number of heap objects allocated
+	Allocations uint64 `json:"allocations"`
+}
+
+func (u Usage) sub(o Usage) Usage {
+	ret := Usage{CPUSeconds: u.CPUSeconds - o.CPUSeconds}
+	if ret.CPUSeconds < 0 {
+		ret.CPUSeconds = 0
+	}
+	if u.AllocatedBytes > o.AllocatedBytes {
+		ret.AllocatedBytes = u.AllocatedBytes - o.AllocatedBytes
+	}
+	if u.Allocations > o.Allocations {
+		ret.Allocations = u.Allocations - o.Allocations
+	}
+	return ret
+}
+
+// scale returns the usage for a measurement of d instead of from.
+func (u Usage) scale(from, d time.Duration) Usage {
+	if from <= 0 {
+		return Usage{}
+	}
+	f := d.Seconds() / from.Seconds()
+	return Usage{
+		CPUSeconds:     u.CPUSeconds * f,
+		AllocatedBytes: uint64(float64(u.AllocatedBytes) * f),
+		Allocations:    uint64(float64(u.Allocations) * f),
+	}
+}
+
+func readUsage() (Usage, error) {
+	var rusage unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &rusage); err != nil {
+		return Usage{}, fmt.Errorf("getting CPU usage: %w", err)
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	cpu := time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano())
+	return Usage{
+		CPUSeconds:     cpu.Seconds(),
+		AllocatedBytes: memStats.TotalAlloc,
+		Allocations:    memStats.Mallocs,
+	}, nil
+}
+
+// Report is the result of a benchmark. The usage and the rates only account
+// for the gadget: the usage of the generator alone, measured first, is
+// subtracted unless it's skipped.
+type Report struct {
+	Gadget          string  `json:"gadget"`
+	DurationSeconds float64 `json:"durationSeconds"`
+
+	// Operations is the number of operations synthesized
+	Operations uint64 `json:"operations"`
+	// Events is the number of events received from the gadget
+	Events uint64 `json:"events"`
+	// LostEvents is the number of events reported as lost by the gadget
+	LostEvents uint64 `json:"lostEvents"`
+
+	EventsPerSecond float64 `json:"eventsPerSecond"`
+	// DropPercent is the percentage of the events sent by the gadget that
+	// were lost
+	DropPercent            float64 `json:"dropPercent"`
+	CPUSecondsPer10kEvents float64 `json:"cpuSecondsPer10kEvents"`
+	AllocatedBytesPerSec   float64 `json:"allocatedBytesPerSecond"`
+	AllocationsPerSec      float64 `json:"allocationsPerSecond"`
+	AllocatedBytesPerEvent float64 `json:"allocatedBytesPerEvent"`
+
+	// Usage is the usage of the gadget
+	Usage Usage `json:"usage"`
+	// Baseline is the usage of the generator alone, for the same duration
+	Baseline *Usage `json:"baseline,omitempty"`
+}
+
+// Run runs the benchmark. Loading the gadget and synthesizing the activity
+// require root.
+func Run(ctx context.Context, config *Config, logger logger.Logger) (*Report, error) {
+	if config.Duration <= 0 {
+		return nil, errors.New("the duration must be positive")
+	}
+
+	activity := config.Activity
+	activity.Count = 0
+	s, err := generator.NewSynthesizer(activity)
+	if err != nil {
+		return nil, fmt.Errorf("creating generator: %w", err)
+	}
+	defer s.Close()
+
+	report := &Report{Gadget: config.Name}
+
+	var baseline Usage
+	if !config.SkipBaseline {
+		u, d, _, err := measure(ctx, s, config.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("measuring generator: %w", err)
+		}
+		baseline = u.scale(d, config.Duration)
+		report.Baseline = &baseline
+	}
+
+	mountnsMap, err := newMountnsMap(s.MountNamespaces())
+	if err != nil {
+		return nil, err
+	}
+	defer mountnsMap.Close()
+
+	pinPath := gadgets.PinnedMapsPath(fmt.Sprintf("bench/%d", os.Getpid()))
+	defer os.RemoveAll(pinPath)
+
+	var events, lost atomic.Uint64
+	standalone, err := tracer.NewStandalone(&tracer.Config{
+		ProgContent: config.ProgContent,
+		Metadata:    config.Metadata,
+		MountnsMap:  mountnsMap,
+		BufferPages: config.BufferPages,
+		PinPath:     pinPath,
+	}, logger, func(ev *types.Event) {
+		if n := ev.GetLostSamples(); n > 0 {
+			lost.Add(n)
+			return
+		}
+		events.Add(1)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("running gadget: %w", err)
+	}
+
+	u, d, stats, err := measure(ctx, s, config.Duration)
+	// Stop the gadget before reading the counters, for the last events and
+	// the lost ones to be counted
+	standalone.Close()
+	if err != nil {
+		return nil, fmt.Errorf("measuring gadget: %w", err)
+	}
+	u = u.sub(baseline.scale(config.Duration, d))
+
+	report.Operations = stats.Events
+	report.Events = events.Load()
+	report.LostEvents = lost.Load()
+	report.setUsage(u, d)
+
+	return report, nil
+}
+
+// setUsage sets the usage of the gadget during d and the rates derived from
+// it and from the counters of events.
+func (r *Report) setUsage(u Usage, d time.Duration) {
+	r.DurationSeconds = d.Seconds()
+	r.Usage = u
+	if sent := r.Events + r.LostEvents; sent > 0 {
+		r.DropPercent = 100 * float64(r.LostEvents) / float64(sent)
+	}
+	if r.Events > 0 {
+		r.CPUSecondsPer10kEvents = u.CPUSeconds * 10000 / float64(r.Events)
+		r.AllocatedBytesPerEvent = float64(u.AllocatedBytes) / float64(r.Events)
+	}
+	if d > 0 {
+		r.EventsPerSecond = float64(r.Events) / d.Seconds()
+		r.AllocatedBytesPerSec = float64(u.AllocatedBytes) / d.Seconds()
+		r.AllocationsPerSec = float64(u.Allocations) / d.Seconds()
+	}
+}
+
+// measure synthesizes the activity for d and returns the usage of the
+// process meanwhile, with the actual duration.
+func measure(ctx context.Context, s *generator.Synthesizer, d time.Duration) (Usage, time.Duration, generator.Stats, error) {
+	before, err := readUsage()
+	if err != nil {
+		return Usage{}, 0, generator.Stats{}, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	stats, err := s.Run(runCtx)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return Usage{}, 0, stats, err
+	}
+
+	after, err := readUsage()
+	if err != nil {
+		return Usage{}, 0, stats, err
+	}
+	return after.sub(before), stats.Duration, stats, nil
+}
+
+// newMountnsMap returns a map of the given mount namespaces, to filter the
+// events of the gadget by them.
+func newMountnsMap(mntns []uint64) (*ebpf.Map, error) {
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       gadgets.MntNsFilterMapName,
+		Type:       ebpf.Hash,
+		KeySize:    8,
+		ValueSize:  4,
+		MaxEntries: tracercollection.MaxContainersPerNode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating mount namespaces map: %w", err)
+	}
+	for _, ns := range mntns {
+		if err := m.Put(ns, uint32(1)); err != nil {
+			m.Close()
+			return nil, fmt.Errorf("adding mount namespace %d: %w", ns, err)
+		}
+	}
+	return m, nil
+}
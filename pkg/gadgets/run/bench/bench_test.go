@@ -0,0 +1,54 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsage(t *testing.T) {
+	gadget := Usage{CPUSeconds: 3, AllocatedBytes: 3000, Allocations: 30}
+	baseline := Usage{CPUSeconds: 1, AllocatedBytes: 1000, Allocations: 10}
+
+	require.Equal(t, Usage{CPUSeconds: 2, AllocatedBytes: 2000, Allocations: 20}, gadget.sub(baseline))
+	// The noise of the measurements mustn't make the usage negative
+	require.Equal(t, Usage{}, baseline.sub(gadget))
+
+	require.Equal(t, Usage{CPUSeconds: 2, AllocatedBytes: 2000, Allocations: 20},
+		baseline.scale(time.Second, 2*time.Second))
+	require.Equal(t, Usage{}, baseline.scale(0, time.Second))
+}
+
+func TestReportUsage(t *testing.T) {
+	r := &Report{Events: 20000, LostEvents: 5000}
+	r.setUsage(Usage{CPUSeconds: 0.5, AllocatedBytes: 4 << 20, Allocations: 40000}, 2*time.Second)
+
+	require.Equal(t, 2.0, r.DurationSeconds)
+	require.Equal(t, 10000.0, r.EventsPerSecond)
+	require.Equal(t, 20.0, r.DropPercent)
+	require.Equal(t, 0.25, r.CPUSecondsPer10kEvents)
+	require.Equal(t, float64(2<<20), r.AllocatedBytesPerSec)
+	require.Equal(t, 20000.0, r.AllocationsPerSec)
+	require.InDelta(t, 209.7, r.AllocatedBytesPerEvent, 0.1)
+
+	// Without events, the rates per event aren't defined
+	r = &Report{}
+	r.setUsage(Usage{CPUSeconds: 1}, time.Second)
+	require.Zero(t, r.DropPercent)
+	require.Zero(t, r.CPUSecondsPer10kEvents)
+}
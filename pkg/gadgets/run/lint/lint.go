@@ -0,0 +1,389 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint performs static checks on the eBPF object and the metadata of
+// a gadget, to find the mistakes that would make it fail or behave badly once
+// deployed, without running it.
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+)
+
+type Severity string
+
+const (
+	// SeverityError is for the findings that make the gadget fail to run
+	SeverityError Severity = "error"
+
+	// SeverityWarning is for the findings that make the gadget behave
+	// badly or be hard to use
+	SeverityWarning Severity = "warning"
+)
+
+// Names of the checks
+const (
+	CheckMetadata          = "metadata"
+	CheckUnreferencedMap   = "unreferenced-map"
+	CheckUnattachedProgram = "unattached-program"
+	CheckLicense           = "license"
+	CheckEventSize         = "event-size"
+	CheckFieldDescription  = "field-description"
+	CheckCORE              = "core"
+	CheckMapSize           = "map-size"
+)
+
+// DefaultMaxEventSize is the size of events above which they are reported as
+// oversized, in bytes. Bigger events can't be built on the eBPF stack and
+// increase the chances of losing them.
+const DefaultMaxEventSize = 4096
+
+// maxPerfEventSize is the maximum size of an event sent with a perf event
+// array: the size of a perf record, with its header, has 16 bits.
+const maxPerfEventSize = 0xffff - 16
+
+// Finding is a problem found in a gadget.
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	// Object is the map, program, struct or field the finding is about
+	Object  string `json:"object,omitempty"`
+	Message string `json:"message"`
+}
+
+type Config struct {
+	// MaxEventSize is the size of events above which they are reported,
+	// DefaultMaxEventSize if it's zero
+	MaxEventSize uint32
+}
+
+// Lint checks the gadget made of the given eBPF object and metadata. The
+// findings are sorted by severity, check, object and message.
+func Lint(progContent, metadata []byte, config *Config) ([]Finding, error) {
+	spec, err := ebpf.LoadCollectionSpecFromReader(bytes.NewReader(progContent))
+	if err != nil {
+		return nil, fmt.Errorf("loading eBPF object: %w", err)
+	}
+	if config == nil {
+		config = &Config{}
+	}
+	return lintSpec(spec, metadata, config), nil
+}
+
+type linter struct {
+	spec     *ebpf.CollectionSpec
+	metadata *types.GadgetMetadata
+	config   *Config
+	findings []Finding
+}
+
+func (l *linter) report(check string, severity Severity, object string, format string, args ...any) {
+	l.findings = append(l.findings, Finding{
+		Check:    check,
+		Severity: severity,
+		Object:   object,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func lintSpec(spec *ebpf.CollectionSpec, metadata []byte, config *Config) []Finding {
+	l := &linter{spec: spec, config: config}
+
+	l.checkMetadata(metadata)
+	l.checkMaps()
+	l.checkPrograms()
+	if l.metadata != nil {
+		l.checkEventSizes()
+		l.checkFieldDescriptions()
+		l.checkMapSizes()
+	}
+
+	severities := map[Severity]int{SeverityError: 0, SeverityWarning: 1}
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if a.Severity != b.Severity {
+			return severities[a.Severity] < severities[b.Severity]
+		}
+		if a.Check != b.Check {
+			return a.Check < b.Check
+		}
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Message < b.Message
+	})
+	return l.findings
+}
+
+// checkMetadata decodes the metadata, reporting the unknown keys, and
+// validates it against the eBPF object.
+func (l *linter) checkMetadata(metadata []byte) {
+	if len(bytes.TrimSpace(metadata)) == 0 {
+		l.report(CheckMetadata, SeverityError, "", "the gadget has no metadata")
+		return
+	}
+
+	m, err := types.DecodeMetadata(metadata, false)
+	if err != nil {
+		l.report(CheckMetadata, SeverityError, "", "decoding metadata: %s", err)
+		return
+	}
+	l.metadata = m
+
+	// Most likely misspelled, otherwise silently ignored
+	unknownKeys, err := types.UnknownKeys(metadata)
+	if err != nil {
+		l.report(CheckMetadata, SeverityError, "", "decoding metadata: %s", err)
+		return
+	}
+	for _, key := range unknownKeys {
+		l.report(CheckMetadata, SeverityError, "", "unknown key %s", key)
+	}
+
+	if err := m.Validate(l.spec); err != nil {
+		var merr *multierror.Error
+		if errors.As(err, &merr) {
+			for _, err := range merr.Errors {
+				l.report(CheckMetadata, SeverityError, "", "%s", err)
+			}
+		} else {
+			l.report(CheckMetadata, SeverityError, "", "%s", err)
+		}
+	}
+
+	// Leftovers of the metadata generated by `ig image build --update-metadata`
+	if strings.HasPrefix(m.Name, "TODO") {
+		l.report(CheckMetadata, SeverityWarning, "name", "the gadget name isn't set")
+	}
+	if m.Description == "" || strings.HasPrefix(m.Description, "TODO") {
+		l.report(CheckMetadata, SeverityWarning, "description", "the gadget description isn't set")
+	}
+	for name, h := range m.Histograms {
+		if strings.HasPrefix(h.Unit, "TODO") {
+			l.report(CheckMetadata, SeverityWarning, name, "the unit of the histogram isn't set")
+		}
+	}
+	for key, p := range m.Params {
+		if p.Description == "" || strings.HasPrefix(p.Description, "TODO") {
+			l.report(CheckMetadata, SeverityWarning, key, "the description of the param isn't set")
+		}
+	}
+}
+
+// checkMaps reports the maps no program uses. They are created for nothing
+// and usually are leftovers of removed code.
+func (l *linter) checkMaps() {
+	referenced := map[string]bool{}
+	for _, p := range l.spec.Programs {
+		for _, ins := range p.Instructions {
+			if ins.IsLoadFromMap() && ins.Reference() != "" {
+				referenced[ins.Reference()] = true
+			}
+		}
+	}
+
+	for name, m := range l.spec.Maps {
+		// Sections, like .rodata, are created from the global variables
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if referenced[name] {
+			continue
+		}
+		// The inner maps are used through their outer map
+		if isInnerMap(l.spec, m) {
+			continue
+		}
+		l.report(CheckUnreferencedMap, SeverityWarning, name, "map isn't used by any program")
+	}
+}
+
+func isInnerMap(spec *ebpf.CollectionSpec, inner *ebpf.MapSpec) bool {
+	for _, m := range spec.Maps {
+		if m.InnerMap != nil && m.InnerMap.Name == inner.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// attached tells if the program is attached when the gadget runs.
+// Keep aligned with installTracer() in pkg/gadgets/run/tracer.
+func attached(p *ebpf.ProgramSpec) bool {
+	switch {
+	case strings.HasPrefix(p.SectionName, "uprobe/"), strings.HasPrefix(p.SectionName, "uretprobe/"):
+		return true
+	case p.Type == ebpf.Kprobe && (strings.HasPrefix(p.SectionName, "kprobe/") || strings.HasPrefix(p.SectionName, "kretprobe/")):
+		return true
+	case p.Type == ebpf.TracePoint && strings.HasPrefix(p.SectionName, "tracepoint/"):
+		return true
+	case p.Type == ebpf.SocketFilter && strings.HasPrefix(p.SectionName, "socket"):
+		return true
+	}
+	return false
+}
+
+// readsKernelMemory tells if the program can read kernel memory, where the
+// layout of the structures changes across kernel versions.
+func readsKernelMemory(p *ebpf.ProgramSpec) bool {
+	switch p.Type {
+	case ebpf.Kprobe, ebpf.TracePoint, ebpf.RawTracepoint, ebpf.Tracing, ebpf.LSM, ebpf.PerfEvent:
+		return true
+	}
+	return false
+}
+
+func (l *linter) checkPrograms() {
+	if l.spec.Types == nil && len(l.spec.Programs) > 0 {
+		l.report(CheckCORE, SeverityError, "", "the eBPF object has no BTF information, it must be compiled with -g")
+	}
+
+	// The iterators are run by the snapshotters
+	snapshotterPrograms := map[string]bool{}
+	if l.metadata != nil {
+		for _, snapshotter := range l.metadata.Snapshotters {
+			for _, progName := range snapshotter.Programs {
+				snapshotterPrograms[progName] = true
+			}
+		}
+	}
+
+	for name, p := range l.spec.Programs {
+		if !attached(p) && !(snapshotterPrograms[name] && types.IsIterator(p)) {
+			l.report(CheckUnattachedProgram, SeverityWarning, name,
+				"program in section %q of type %s isn't attached by Inspektor Gadget", p.SectionName, p.Type)
+		}
+
+		if p.License == "" {
+			l.report(CheckLicense, SeverityError, name,
+				"program has no license, the GPL-only helpers can't be used: add char LICENSE[] SEC(\"license\") = \"GPL\";")
+		}
+
+		if p.KernelVersion != 0 {
+			l.report(CheckCORE, SeverityWarning, name,
+				"program is built for the kernel version %d.%d.%d, it's ignored by the kernels using CO-RE",
+				p.KernelVersion>>16, (p.KernelVersion>>8)&0xff, p.KernelVersion&0xff)
+		}
+
+		if readsKernelMemory(p) {
+			l.checkCORERelocations(name, p)
+		}
+	}
+}
+
+// checkCORERelocations reports the programs reading kernel memory with
+// bpf_probe_read*() without any CO-RE relocation: the offsets they read at
+// were computed against the headers of a single kernel version.
+func (l *linter) checkCORERelocations(name string, p *ebpf.ProgramSpec) {
+	probeRead := false
+	for _, ins := range p.Instructions {
+		if btf.CORERelocationMetadata(&ins) != nil {
+			return
+		}
+		if ins.IsBuiltinCall() {
+			switch asm.BuiltinFunc(ins.Constant) {
+			case asm.FnProbeRead, asm.FnProbeReadStr, asm.FnProbeReadKernel, asm.FnProbeReadKernelStr:
+				probeRead = true
+			}
+		}
+	}
+	if probeRead {
+		l.report(CheckCORE, SeverityWarning, name,
+			"program reads kernel memory without CO-RE relocations, use BPF_CORE_READ() and vmlinux.h")
+	}
+}
+
+// checkEventSizes reports the events that are expensive or impossible to
+// send.
+func (l *linter) checkEventSizes() {
+	maxSize := l.config.MaxEventSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxEventSize
+	}
+
+	for _, tracer := range l.metadata.Tracers {
+		m, ok := l.spec.Maps[tracer.MapName]
+		if !ok {
+			continue
+		}
+		event, ok := m.Value.(*btf.Struct)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case m.Type == ebpf.PerfEventArray && event.Size > maxPerfEventSize:
+			l.report(CheckEventSize, SeverityError, event.Name,
+				"event of %d bytes is too big to be sent with a perf event array, the maximum is %d bytes",
+				event.Size, maxPerfEventSize)
+		case event.Size > maxSize:
+			l.report(CheckEventSize, SeverityWarning, event.Name,
+				"event of %d bytes is bigger than %d bytes", event.Size, maxSize)
+		}
+	}
+}
+
+// checkFieldDescriptions reports the fields without description, shown to
+// the users by the help of the gadgets, and the members of the structures
+// missing from the metadata, that aren't shown at all.
+func (l *linter) checkFieldDescriptions() {
+	builtinColumns := types.GetColumns()
+	for structName, s := range l.metadata.Structs {
+		described := map[string]bool{}
+		for _, field := range s.Fields {
+			described[field.Name] = true
+			if field.Description == "" || strings.HasPrefix(field.Description, "TODO") {
+				l.report(CheckFieldDescription, SeverityWarning, structName+"."+field.Name, "field has no description")
+			}
+		}
+
+		var btfStruct *btf.Struct
+		if l.spec.Types == nil || l.spec.Types.TypeByName(structName, &btfStruct) != nil {
+			continue
+		}
+		replaced := types.ReplacedByEnrichment(s, btfStruct)
+		for _, member := range types.FlattenMembers(btfStruct, s.Depth()) {
+			// Keep aligned with populateStruct() in pkg/gadgets/run/types
+			if types.IsEventTimestamp(member) || replaced[member.Name] {
+				continue
+			}
+			if _, ok := builtinColumns.GetColumn(member.Name); ok {
+				continue
+			}
+			if member.Name != "" && !described[member.Name] {
+				l.report(CheckFieldDescription, SeverityWarning, structName+"."+member.Name,
+					"member isn't in the metadata, it won't be shown")
+			}
+		}
+	}
+}
+
+// checkMapSizes reports the maps that are likely too small for what they
+// hold, losing entries, or that use a lot of memory on the big nodes.
+func (l *linter) checkMapSizes() {
+	for _, w := range l.metadata.MapSizeWarnings(l.spec) {
+		l.report(CheckMapSize, SeverityWarning, w.MapName, "%s", w.Message)
+	}
+}
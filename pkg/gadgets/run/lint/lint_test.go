@@ -0,0 +1,135 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+)
+
+func findingsOf(findings []Finding, check string) []Finding {
+	ret := []Finding{}
+	for _, f := range findings {
+		if f.Check == check {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func TestLintObject(t *testing.T) {
+	prog, err := os.ReadFile("../../../../testdata/validate_metadata1.o")
+	require.NoError(t, err)
+
+	metadata := []byte(`
+name: test
+description: test gadget
+tracers:
+  events:
+    mapName: events
+    structName: event
+structs:
+  event:
+    fields:
+    - name: pid
+      description: PID of the process
+    - name: comm
+`)
+
+	findings, err := Lint(prog, metadata, nil)
+	require.NoError(t, err)
+	require.Equal(t, []Finding{
+		{CheckFieldDescription, SeverityWarning, "event.comm", "field has no description"},
+		{CheckFieldDescription, SeverityWarning, "event.filename", "member isn't in the metadata, it won't be shown"},
+		{CheckUnreferencedMap, SeverityWarning, "map_without_btf", "map isn't used by any program"},
+		{CheckUnreferencedMap, SeverityWarning, "myhashmap", "map isn't used by any program"},
+		{CheckUnreferencedMap, SeverityWarning, "wrong_value_map", "map isn't used by any program"},
+	}, findings)
+
+	// The metadata is decoded strictly
+	findings, err = Lint(prog, append(metadata, []byte("unknown: true\n")...), nil)
+	require.NoError(t, err)
+	metadataFindings := findingsOf(findings, CheckMetadata)
+	require.Len(t, metadataFindings, 1)
+	require.Equal(t, SeverityError, metadataFindings[0].Severity)
+	require.Contains(t, metadataFindings[0].Message, "unknown")
+
+	findings, err = Lint(prog, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []Finding{{CheckMetadata, SeverityError, "", "the gadget has no metadata"}}, findingsOf(findings, CheckMetadata))
+}
+
+func TestLintPrograms(t *testing.T) {
+	event := &btf.Struct{Name: "event", Size: 8192}
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"events": {Name: "events", Type: ebpf.RingBuf, Value: event},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{
+			"no_core": {
+				Type:        ebpf.Kprobe,
+				SectionName: "kprobe/vfs_read",
+				Instructions: asm.Instructions{
+					asm.LoadMapPtr(asm.R1, 0).WithReference("events"),
+					asm.FnProbeReadKernel.Call(),
+					asm.Return(),
+				},
+			},
+			"unattached": {
+				Type:          ebpf.XDP,
+				SectionName:   "xdp",
+				License:       "GPL",
+				KernelVersion: 5<<16 | 10<<8,
+				Instructions:  asm.Instructions{asm.Return()},
+			},
+		},
+	}
+	metadata := []byte(`
+name: test
+description: test gadget
+tracers:
+  events:
+    mapName: events
+    structName: event
+`)
+
+	findings := lintSpec(spec, metadata, &Config{})
+	require.Equal(t, []Finding{
+		{CheckCORE, SeverityError, "", "the eBPF object has no BTF information, it must be compiled with -g"},
+		{CheckLicense, SeverityError, "no_core", `program has no license, the GPL-only helpers can't be used: add char LICENSE[] SEC("license") = "GPL";`},
+		{CheckMetadata, SeverityError, "", `tracer "events" references unknown struct "event"`},
+		{CheckCORE, SeverityWarning, "no_core", "program reads kernel memory without CO-RE relocations, use BPF_CORE_READ() and vmlinux.h"},
+		{CheckCORE, SeverityWarning, "unattached", "program is built for the kernel version 5.10.0, it's ignored by the kernels using CO-RE"},
+		{CheckEventSize, SeverityWarning, "event", "event of 8192 bytes is bigger than 4096 bytes"},
+		{CheckUnattachedProgram, SeverityWarning, "unattached", `program in section "xdp" of type XDP isn't attached by Inspektor Gadget`},
+	}, findings)
+
+	// The threshold of the event size can be changed
+	findings = lintSpec(spec, metadata, &Config{MaxEventSize: 16384})
+	require.Empty(t, findingsOf(findings, CheckEventSize))
+
+	// Perf event arrays can't send big events
+	spec.Maps["events"].Type = ebpf.PerfEventArray
+	event.Size = 70000
+	findings = lintSpec(spec, metadata, &Config{})
+	require.Equal(t, []Finding{
+		{CheckEventSize, SeverityError, "event", "event of 70000 bytes is too big to be sent with a perf event array, the maximum is 65519 bytes"},
+	}, findingsOf(findings, CheckEventSize))
+}
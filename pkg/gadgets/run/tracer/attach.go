@@ -0,0 +1,92 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+// Where tracefs is usually mounted
+var tracingPaths = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// hostKernel looks for the functions in /proc/kallsyms and for the
+// tracepoints in tracefs. The targets are assumed to exist when they can't be
+// read, for the attach errors to tell what's wrong.
+type hostKernel struct {
+	kallsyms    *kallsyms.KAllSyms
+	tracingPath string
+}
+
+// newHostKernel returns a hostKernel and the function releasing it
+func newHostKernel(logger logger.Logger) (*hostKernel, func()) {
+	k := &hostKernel{}
+	release := func() {}
+
+	symbols, releaseSymbols, err := kallsyms.NewSharedKAllSyms()
+	if err != nil {
+		logger.Debugf("reading kallsyms, the functions of the kprobes won't be checked: %v", err)
+	} else {
+		k.kallsyms = symbols
+		release = releaseSymbols
+	}
+
+	for _, path := range tracingPaths {
+		if _, err := os.Stat(filepath.Join(path, "events")); err == nil {
+			k.tracingPath = path
+			break
+		}
+	}
+	if k.tracingPath == "" {
+		logger.Debugf("tracefs not found, the tracepoints won't be checked")
+	}
+
+	return k, release
+}
+
+func (k *hostKernel) hasFunction(name string) bool {
+	return k.kallsyms == nil || k.kallsyms.SymbolExists(name)
+}
+
+func (k *hostKernel) hasTracepoint(category, name string) bool {
+	if k.tracingPath == "" {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(k.tracingPath, "events", category, name))
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// kernelVersion returns the version of the running kernel, nil if it can't be
+// parsed
+func kernelVersion() *version.Version {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return nil
+	}
+	v, err := version.ParseGeneric(unix.ByteSliceToString(uts.Release[:]))
+	if err != nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,238 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// readInteger returns the integer of the given size stored in data, sign
+// extended if it's signed.
+func readInteger(data []byte, size uint32, signed bool) uint64 {
+	ptr := unsafe.Pointer(&data[0])
+	switch size {
+	case 1:
+		if signed {
+			return uint64(int64(*(*int8)(ptr)))
+		}
+		return uint64(*(*uint8)(ptr))
+	case 2:
+		if signed {
+			return uint64(int64(*(*int16)(ptr)))
+		}
+		return uint64(*(*uint16)(ptr))
+	case 4:
+		if signed {
+			return uint64(int64(*(*int32)(ptr)))
+		}
+		return uint64(*(*uint32)(ptr))
+	case 8:
+		return *(*uint64)(ptr)
+	}
+	return 0
+}
+
+// formatErrno returns the name of the error number, e.g. ENOENT, prefixed with
+// '-' when it's negative, like the return values of the syscalls.
+func formatErrno(value uint64, signed bool) string {
+	sign := ""
+	if signed && int64(value) < 0 {
+		sign = "-"
+		value = uint64(-int64(value))
+	}
+	if name, ok := errnoNames[value]; ok {
+		return sign + name
+	}
+	return sign + strconv.FormatUint(value, 10)
+}
+
+// kindFormatter returns the function showing the integers of the given kind.
+// The kinds with a unit, like bytes, are shown by the formatters instead.
+func kindFormatter(kind types.FieldKind) (func(value uint64, signed bool) string, error) {
+	switch kind {
+	case types.KindErrno:
+		return formatErrno, nil
+	case types.KindReturnValue:
+		return func(value uint64, signed bool) string {
+			if int64(value) < 0 {
+				return formatErrno(value, signed)
+			}
+			return strconv.FormatInt(int64(value), 10)
+		}, nil
+	case types.KindTimestampNs:
+		return func(value uint64, _ bool) string {
+			if value == 0 {
+				return ""
+			}
+			// Converted to wall time when the event was received
+			return eventtypes.Time(value).String()
+		}, nil
+	case types.KindL3Endpoint:
+		return func(value uint64, _ bool) string {
+			// In network byte order, the first byte of the address is the
+			// first one in memory
+			ip := make(net.IP, 4)
+			*(*uint32)(unsafe.Pointer(&ip[0])) = uint32(value)
+			return ip.String()
+		}, nil
+	}
+	return nil, fmt.Errorf("kind %q isn't supported for integers", kind)
+}
+
+// rawErrnoSuffix is appended to the names of the columns of the error numbers
+// to get the ones of their raw values
+const rawErrnoSuffix = "_raw"
+
+// addKindColumn adds a virtual column that shows the value of the given kind
+// stored at the offset of the raw event given by getOffset, if the event has
+// it, and a hidden one with the raw number for the error numbers. The
+// endpoints stored in a gadget_l3endpoint_t or a gadget_l4endpoint_t are
+// handled with the enrichment of the event instead.
+func addKindColumn(cols *columns.Columns[types.Event], attrs columns.Attributes, kind types.FieldKind, typ btf.Type, getOffset func(*types.Event) (uint32, bool)) error {
+	switch typedMember := btf.UnderlyingType(typ).(type) {
+	case *btf.Array:
+		if kind != types.KindL3Endpoint || typedMember.Nelems != 16 {
+			return fmt.Errorf("kind %q isn't supported for arrays", kind)
+		}
+		return cols.AddColumn(attrs, func(ev *types.Event) any {
+			offset, ok := getOffset(ev)
+			if !ok || uint32(len(ev.RawData)) < offset+16 {
+				return ""
+			}
+			return net.IP(ev.RawData[offset : offset+16]).String()
+		})
+	case *btf.Int:
+		format, err := kindFormatter(kind)
+		if err != nil {
+			return err
+		}
+		size := typedMember.Size
+		signed := typedMember.Encoding == btf.Signed
+		if err := cols.AddColumn(attrs, func(ev *types.Event) any {
+			offset, ok := getOffset(ev)
+			if !ok || uint32(len(ev.RawData)) < offset+size {
+				return ""
+			}
+			return format(readInteger(ev.RawData[offset:], size, signed), signed)
+		}); err != nil {
+			return err
+		}
+		if kind != types.KindErrno && kind != types.KindReturnValue {
+			return nil
+		}
+		rawName := attrs.Name + rawErrnoSuffix
+		if _, ok := cols.GetColumn(rawName); ok {
+			return nil
+		}
+		// The error numbers are also given as they are, e.g. for the JSON
+		// output to be processed
+		return cols.AddColumn(columns.Attributes{
+			Name:  rawName,
+			Width: columns.MaxCharsInt64,
+			Order: attrs.Order,
+		}, func(ev *types.Event) any {
+			offset, ok := getOffset(ev)
+			if !ok || uint32(len(ev.RawData)) < offset+size {
+				return int64(0)
+			}
+			return int64(readInteger(ev.RawData[offset:], size, signed))
+		})
+	}
+	return fmt.Errorf("kind %q isn't supported for %s", kind, typ)
+}
+
+// getFieldFlags returns the names of the bits of the field, given by the
+// metadata or by the values of its enum, nil if it doesn't have any.
+func getFieldFlags(field *types.Field, typ btf.Type) []types.Flag {
+	if !field.FlagsEnum {
+		return field.Flags
+	}
+
+	enum := getEnum(typ)
+	if enum == nil {
+		return nil
+	}
+	flags := make([]types.Flag, 0, len(enum.Values))
+	for _, v := range enum.Values {
+		flags = append(flags, types.Flag{Name: v.Name, Value: v.Value})
+	}
+	return flags
+}
+
+// formatFlags returns the names of the flags set in value joined with '|', in
+// the order of flags. The bits without name are shown in hexadecimal.
+func formatFlags(value uint64, flags []types.Flag) string {
+	names := []string{}
+	rest := value
+	zeroName := ""
+	for _, flag := range flags {
+		mask := flag.Mask
+		if mask == 0 {
+			mask = flag.Value
+		}
+		if mask == 0 {
+			// Name of the value without any bit set
+			zeroName = flag.Name
+			continue
+		}
+		if value&mask == flag.Value {
+			names = append(names, flag.Name)
+			rest &^= mask
+		}
+	}
+	if rest != 0 {
+		names = append(names, fmt.Sprintf("%#x", rest))
+	}
+	if len(names) == 0 {
+		if zeroName != "" {
+			return zeroName
+		}
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// addFlagsColumn adds a virtual column that shows the names of the flags set
+// in the integer or enum stored at the offset of the raw event given by
+// getOffset, if the event has it.
+func addFlagsColumn(cols *columns.Columns[types.Event], attrs columns.Attributes, flags []types.Flag, typ btf.Type, getOffset func(*types.Event) (uint32, bool)) error {
+	var size uint32
+	switch typedMember := btf.UnderlyingType(typ).(type) {
+	case *btf.Int:
+		size = typedMember.Size
+	case *btf.Enum:
+		size = typedMember.Size
+	default:
+		return fmt.Errorf("flags aren't supported for %s", typ)
+	}
+
+	return cols.AddColumn(attrs, func(ev *types.Event) any {
+		offset, ok := getOffset(ev)
+		if !ok || uint32(len(ev.RawData)) < offset+size {
+			return ""
+		}
+		return formatFlags(readInteger(ev.RawData[offset:], size, false), flags)
+	})
+}
@@ -15,30 +15,40 @@
 package tracer
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
+	"github.com/docker/go-units"
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/util/version"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	columnssort "github.com/inspektor-gadget/inspektor-gadget/pkg/columns/sort"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
-// getAnyMapElem returns any element of a map. If the map is empty, it returns nil, nil.
-func getAnyMapElem[K comparable, V any](m map[K]V) (*K, *V) {
-	for k, v := range m {
-		return &k, &v
-	}
-	return nil, nil
-}
-
-func getEventTypeBTF(progContent []byte, metadata *types.GadgetMetadata) (*btf.Struct, error) {
-	spec, err := loadSpec(progContent)
-	if err != nil {
-		return nil, err
+// getEventTypesBTF returns the types of the events sent by the tracers, the
+// snapshotters and the toppers of the gadget, by name. The types of the
+// variants of the tracers are named by variantEventType. The events of the
+// histograms don't have any, and the metrics don't send events.
+func getEventTypesBTF(spec *ebpf.CollectionSpec, metadata *types.GadgetMetadata) (map[string]*btf.Struct, error) {
+	if len(metadata.Tracers) == 0 && len(metadata.Snapshotters) == 0 && len(metadata.Toppers) == 0 &&
+		len(metadata.Histograms) == 0 && len(metadata.Metrics) == 0 {
+		return nil, fmt.Errorf("the gadget doesn't provide any compatible way to show information")
 	}
 
-	switch {
-	case len(metadata.Tracers) > 0:
-		_, tracer := getAnyMapElem(metadata.Tracers)
+	eventTypes := make(map[string]*btf.Struct, len(metadata.Tracers))
+	for name, tracer := range metadata.Tracers {
 		traceMap := spec.Maps[tracer.MapName]
 		if traceMap == nil {
 			return nil, fmt.Errorf("BPF map %q not found", tracer.MapName)
@@ -53,8 +63,541 @@ func getEventTypeBTF(progContent []byte, metadata *types.GadgetMetadata) (*btf.S
 			return nil, fmt.Errorf("value of BPF map %q is not a structure", traceMap.Name)
 		}
 
-		return valueStruct, nil
-	default:
-		return nil, fmt.Errorf("the gadget doesn't provide any compatible way to show information")
+		eventTypes[name] = valueStruct
+
+		for _, value := range sortedKeys(tracer.Variants) {
+			structName := tracer.Variants[value]
+			if spec.Types == nil {
+				return nil, fmt.Errorf("the eBPF object doesn't have BTF information")
+			}
+			var btfStruct *btf.Struct
+			if err := spec.Types.TypeByName(structName, &btfStruct); err != nil {
+				return nil, fmt.Errorf("looking for struct %q of tracer %q: %w", structName, name, err)
+			}
+			eventTypes[variantEventType(name, structName)] = btfStruct
+		}
+	}
+
+	for name, snapshotter := range metadata.Snapshotters {
+		if spec.Types == nil {
+			return nil, fmt.Errorf("the eBPF object doesn't have BTF information")
+		}
+
+		var btfStruct *btf.Struct
+		if err := spec.Types.TypeByName(snapshotter.StructName, &btfStruct); err != nil {
+			return nil, fmt.Errorf("looking for struct %q of snapshotter %q: %w", snapshotter.StructName, name, err)
+		}
+
+		eventTypes[name] = btfStruct
+	}
+
+	for name, topper := range metadata.Toppers {
+		statsMap := spec.Maps[topper.MapName]
+		if statsMap == nil {
+			return nil, fmt.Errorf("BPF map %q not found", topper.MapName)
+		}
+
+		key, ok := statsMap.Key.(*btf.Struct)
+		if !ok {
+			return nil, fmt.Errorf("key of BPF map %q is not a structure", statsMap.Name)
+		}
+		value, ok := statsMap.Value.(*btf.Struct)
+		if !ok {
+			return nil, fmt.Errorf("value of BPF map %q is not a structure", statsMap.Name)
+		}
+
+		eventTypes[name] = topperEventType(key, value)
+	}
+
+	return eventTypes, nil
+}
+
+// variantEventType returns the name of the type of the events of the tracer
+// tracerName with the struct structName, one of its variants
+func variantEventType(tracerName, structName string) string {
+	return tracerName + "/" + structName
+}
+
+// isVariantEventType tells whether name is the type of the events of a variant
+// of a tracer, as named by variantEventType
+func isVariantEventType(metadata *types.GadgetMetadata, name string) bool {
+	for tracerName, tracer := range metadata.Tracers {
+		for _, structName := range tracer.Variants {
+			if name == variantEventType(tracerName, structName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventTypeName returns the name of the type of ev in the types returned by
+// getEventTypesBTF
+func eventTypeName(ev *types.Event) string {
+	if ev.Variant != "" {
+		return variantEventType(ev.Tracer, ev.Variant)
+	}
+	return ev.Tracer
+}
+
+// eventVariants tells the struct of the events of a tracer with variants from
+// their discriminator.
+type eventVariants struct {
+	discriminator btf.Member
+	size          uint32
+	signed        bool
+	structs       map[uint64]string
+}
+
+func newEventVariants(tracer types.Tracer, eventType *btf.Struct) (*eventVariants, error) {
+	discriminator, structs, err := types.TracerVariants(tracer, eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &eventVariants{discriminator: discriminator, structs: structs}
+	switch typ := btf.UnderlyingType(discriminator.Type).(type) {
+	case *btf.Int:
+		v.size, v.signed = typ.Size, typ.Encoding == btf.Signed
+	case *btf.Enum:
+		v.size, v.signed = typ.Size, typ.Signed
 	}
+	return v, nil
+}
+
+// structName returns the struct of the event in data, empty if the value of
+// its discriminator has none.
+func (v *eventVariants) structName(data []byte) string {
+	d := memberData(v.discriminator, data)
+	if d == nil {
+		return ""
+	}
+	return v.structs[readInteger(d, v.size, v.signed)]
+}
+
+// tracerVariants returns how the struct of the events of the tracer tracerName
+// is told and the types of its variants by struct name, given the types
+// returned by getEventTypesBTF. It returns nil if the tracer doesn't have
+// variants.
+func tracerVariants(metadata *types.GadgetMetadata, tracerName string, eventTypes map[string]*btf.Struct) (*eventVariants, map[string]*btf.Struct, error) {
+	tracer, ok := metadata.Tracers[tracerName]
+	if !ok || len(tracer.Variants) == 0 {
+		return nil, nil, nil
+	}
+
+	variants, err := newEventVariants(tracer, eventTypes[tracerName])
+	if err != nil {
+		return nil, nil, fmt.Errorf("variants of tracer %q: %w", tracerName, err)
+	}
+	variantTypes := make(map[string]*btf.Struct, len(tracer.Variants))
+	for _, structName := range tracer.Variants {
+		variantTypes[structName] = eventTypes[variantEventType(tracerName, structName)]
+	}
+	return variants, variantTypes, nil
+}
+
+// topperValueOffset returns the offset of the value in the events of a topper,
+// made of the key followed by the value, aligned to 8 bytes.
+func topperValueOffset(key *btf.Struct) uint32 {
+	return (key.Size + 7) &^ 7
+}
+
+// topperEventType returns the type of the events of a topper, with the members
+// of the key followed by the ones of the value.
+func topperEventType(key, value *btf.Struct) *btf.Struct {
+	valueOffset := topperValueOffset(key)
+
+	members := make([]btf.Member, 0, len(key.Members)+len(value.Members))
+	members = append(members, key.Members...)
+	for _, member := range value.Members {
+		member.Offset += btf.Bits(valueOffset * 8)
+		members = append(members, member)
+	}
+
+	return &btf.Struct{
+		Name:    key.Name + "+" + value.Name,
+		Size:    valueOffset + value.Size,
+		Members: members,
+	}
+}
+
+// getEventFields returns the fields of the events sent by the tracer,
+// snapshotter or topper name, of the given type.
+func getEventFields(metadata *types.GadgetMetadata, name string, eventType *btf.Struct) ([]types.Field, error) {
+	structNames := []string{eventType.Name}
+	if topper, ok := metadata.Toppers[name]; ok {
+		structNames = []string{topper.KeyStructName, topper.ValueStructName}
+	}
+
+	var fields []types.Field
+	for _, structName := range structNames {
+		s, ok := metadata.Structs[structName]
+		if !ok {
+			return nil, fmt.Errorf("struct %s not found in gadget metadata", structName)
+		}
+		fields = append(fields, s.Fields...)
+	}
+	return fields, nil
+}
+
+// topperSortBy returns the fields the entries of topper are sorted by by
+// default: the ones given by the metadata, or the fields of the value in
+// descending order.
+func topperSortBy(metadata *types.GadgetMetadata, topper types.Topper) []string {
+	if len(topper.SortBy) > 0 {
+		return topper.SortBy
+	}
+
+	sortBy := []string{}
+	for _, field := range metadata.Structs[topper.ValueStructName].Fields {
+		sortBy = append(sortBy, "-"+field.Name)
+	}
+	return sortBy
+}
+
+// tracerBufferPages returns the number of pages of the buffer of tracer given
+// by the metadata, 0 if it doesn't set it. A size in bytes is rounded up to a
+// power of 2 pages of pageSize bytes.
+func tracerBufferPages(tracer types.Tracer, pageSize int) (uint32, error) {
+	if tracer.BufferSize == "" {
+		return tracer.BufferPages, nil
+	}
+	size, err := units.RAMInBytes(tracer.BufferSize)
+	if err != nil {
+		return 0, fmt.Errorf("parsing buffer size: %w", err)
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("buffer size %s must be positive", tracer.BufferSize)
+	}
+	pages := (size + int64(pageSize) - 1) / int64(pageSize)
+	if pages > 1<<31 {
+		return 0, fmt.Errorf("buffer size %s is too big", tracer.BufferSize)
+	}
+	return uint32(1) << bits.Len32(uint32(pages)-1), nil
+}
+
+// maxBufferSize is the size, in bytes, of the biggest buffer: the size of ring
+// buffers is a power of 2 that must fit in 32 bits.
+const maxBufferSize = 1 << 31
+
+// checkBufferPages checks that a buffer of the given number of pages of
+// pageSize bytes isn't bigger than maxBufferSize.
+func checkBufferPages(pages uint32, pageSize int) error {
+	if size := uint64(pages) * uint64(pageSize); size > maxBufferSize {
+		return fmt.Errorf("buffer of %d pages (%s) is bigger than %s",
+			pages, units.BytesSize(float64(size)), units.BytesSize(maxBufferSize))
+	}
+	return nil
+}
+
+// defaultTopInterval is how often the entries of the toppers are sent when
+// neither the user nor the metadata set the interval
+const defaultTopInterval = time.Second
+
+// topperInterval returns how often the entries of topper are sent: interval,
+// set by the user, or the one given by the metadata when it's 0.
+func topperInterval(interval time.Duration, topper types.Topper) (time.Duration, error) {
+	if interval != 0 {
+		return interval, nil
+	}
+	if topper.Interval == "" {
+		return defaultTopInterval, nil
+	}
+	interval, err := time.ParseDuration(topper.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("parsing interval: %w", err)
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("interval %s must be positive", topper.Interval)
+	}
+	return interval, nil
+}
+
+// topEntries sorts the entries by the given columns and returns the first
+// maxRows of them, all of them if maxRows is 0.
+func topEntries(cols columns.ColumnMap[types.Event], entries []*types.Event, sortBy []string, maxRows int) []*types.Event {
+	columnssort.SortEntries(cols, entries, sortBy)
+	if maxRows > 0 && len(entries) > maxRows {
+		entries = entries[:maxRows]
+	}
+	return entries
+}
+
+// stackFrames returns the frames of a stack, from the innermost one, given the
+// instruction pointers read from the stacks map. They end at the first 0 when
+// the stack has less frames than the map can hold. A negative id is the error
+// returned by bpf_get_stackid().
+func stackFrames(id int32, ips []uint64, symbolize func(ip uint64) string) []string {
+	if id < 0 {
+		return []string{"[" + formatErrno(uint64(int64(id)), true) + "]"}
+	}
+	frames := []string{}
+	for _, ip := range ips {
+		if ip == 0 {
+			break
+		}
+		frames = append(frames, symbolize(ip))
+	}
+	return frames
+}
+
+// addHistogramSlots adds the counters of value, of slotSize bytes each, to
+// slots.
+func addHistogramSlots(slots []uint64, value []byte, slotSize uint32) {
+	for i := range slots {
+		offset := uint32(i) * slotSize
+		if offset+slotSize > uint32(len(value)) {
+			return
+		}
+		switch slotSize {
+		case 4:
+			slots[i] += uint64(*(*uint32)(unsafe.Pointer(&value[offset])))
+		case 8:
+			slots[i] += *(*uint64)(unsafe.Pointer(&value[offset]))
+		}
+	}
+}
+
+// memberData returns the bytes of member in data, nil if data is too short
+func memberData(member btf.Member, data []byte) []byte {
+	size, err := btf.Sizeof(member.Type)
+	offset := int(member.Offset.Bytes())
+	if err != nil || offset+size > len(data) {
+		return nil
+	}
+	return data[offset : offset+size]
+}
+
+// nsID returns the namespace ID given by member in data, 0 if member is nil
+func nsID(member *btf.Member, data []byte) uint64 {
+	if member == nil {
+		return 0
+	}
+	data = memberData(*member, data)
+	if data == nil {
+		return 0
+	}
+	return readInteger(data, uint32(len(data)), false)
+}
+
+// metricLabelValue returns the value of the label of a metric given by member
+// in the key of an entry of its map: the number of the integers, the name of
+// the values of the enums and the strings up to their first NUL.
+func metricLabelValue(member btf.Member, key []byte) string {
+	data := memberData(member, key)
+	if data == nil {
+		return ""
+	}
+
+	switch typ := btf.UnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		if typ.Encoding == btf.Bool {
+			return strconv.FormatBool(data[0] != 0)
+		}
+		v := readInteger(data, typ.Size, typ.Encoding == btf.Signed)
+		if typ.Encoding == btf.Signed {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatUint(v, 10)
+	case *btf.Enum:
+		v := readInteger(data, typ.Size, typ.Signed)
+		for _, value := range typ.Values {
+			if value.Value == v {
+				return value.Name
+			}
+		}
+		if typ.Signed {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatUint(v, 10)
+	case *btf.Array:
+		if i := bytes.IndexByte(data, 0); i != -1 {
+			data = data[:i]
+		}
+		return string(data)
+	}
+	return ""
+}
+
+// metricValue returns the counter held by member in the value of an entry of
+// the map of a metric
+func metricValue(member btf.Member, value []byte) float64 {
+	data := memberData(member, value)
+	if data == nil {
+		return 0
+	}
+	i, ok := btf.UnderlyingType(member.Type).(*btf.Int)
+	if !ok {
+		return 0
+	}
+	v := readInteger(data, i.Size, i.Encoding == btf.Signed)
+	if i.Encoding == btf.Signed {
+		return float64(int64(v))
+	}
+	return float64(v)
+}
+
+// metricSample is the sum of the counters of the entries of the map of a
+// metric with the same labels
+type metricSample struct {
+	labels []string
+	value  float64
+}
+
+// addMetricSample adds value to the sample of samples with the given labels,
+// keyed by their values joined.
+func addMetricSample(samples map[string]*metricSample, labels []string, value float64) {
+	id := strings.Join(labels, "\x00")
+	sample, ok := samples[id]
+	if !ok {
+		sample = &metricSample{labels: labels}
+		samples[id] = sample
+	}
+	sample.value += value
+}
+
+// paramConstants returns the values given to the constants of the eBPF program
+// by the parameters of the gadget, by name. The constants of the parameters
+// that aren't in gadgetParams keep the value of the eBPF program.
+func paramConstants(metadata *types.GadgetMetadata, gadgetParams *params.Params) map[string]any {
+	consts := map[string]any{}
+	for _, key := range sortedKeys(metadata.Params) {
+		p := gadgetParams.Get(key)
+		if p == nil {
+			continue
+		}
+		consts[metadata.Params[key].VarName] = p.AsAny()
+	}
+	return consts
+}
+
+// resizeMaps sets the maximum number of entries of the maps of spec to the one
+// given by the metadata, if any, multiplied by scale for the maps declared as
+// scalable. A scale of 0 leaves them unscaled.
+func resizeMaps(spec *ebpf.CollectionSpec, metadata *types.GadgetMetadata, scale float64) error {
+	for _, name := range sortedKeys(metadata.Maps) {
+		mapSpec := spec.Maps[name]
+		if mapSpec == nil {
+			return fmt.Errorf("map %q not found", name)
+		}
+
+		maxEntries := metadata.MapMaxEntries(mapSpec)
+		if metadata.Maps[name].Scalable && scale != 0 && scale != 1 {
+			scaled := math.Ceil(float64(maxEntries) * scale)
+			if scaled > math.MaxUint32 {
+				return fmt.Errorf("map %q: %d entries scaled by %g don't fit in 32 bits", name, maxEntries, scale)
+			}
+			maxEntries = uint32(scaled)
+		}
+		mapSpec.MaxEntries = maxEntries
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkRequirements checks that the running kernel, of version kernelVersion,
+// has what the gadget needs, for it to fail with a clear error instead of
+// when loading it. hasFeature tells whether the kernel supports one of
+// types.BPFFeatures. The version isn't checked if it's nil.
+func checkRequirements(metadata *types.GadgetMetadata, kernelVersion *version.Version, hasFeature func(feature string) bool) error {
+	if kernelVersion != nil && metadata.MinKernelVersion != "" {
+		minVersion, err := version.ParseGeneric(metadata.MinKernelVersion)
+		if err != nil {
+			return fmt.Errorf("parsing minimum kernel version: %w", err)
+		}
+		if kernelVersion.LessThan(minVersion) {
+			return fmt.Errorf("this gadget needs kernel >= %s, running %s", metadata.MinKernelVersion, kernelVersion)
+		}
+	}
+
+	var result error
+	for _, feature := range metadata.Requirements.Features {
+		if hasFeature(feature) {
+			continue
+		}
+		if minVersion, ok := types.BPFFeatures[feature]; ok {
+			result = multierror.Append(result, fmt.Errorf("this gadget needs kernel >= %s (%s)", minVersion, feature))
+		} else {
+			result = multierror.Append(result, fmt.Errorf("this gadget needs the unknown feature %q", feature))
+		}
+	}
+	return result
+}
+
+// kernelTargets tells which functions and tracepoints the running kernel has
+type kernelTargets interface {
+	hasFunction(name string) bool
+	hasTracepoint(category, name string) bool
+}
+
+// resolveAttachTargets returns the targets the kprobes, kretprobes and
+// tracepoints of spec are attached to, by program name: the ones of their
+// sections or, when the kernel doesn't have them, the first of their
+// alternatives in the metadata it has. It fails listing the programs none of
+// whose targets were found, instead of failing when attaching them.
+func resolveAttachTargets(spec *ebpf.CollectionSpec, metadata *types.GadgetMetadata, kernel kernelTargets, kernelVersion *version.Version) (map[string]string, error) {
+	var result error
+	targets := map[string]string{}
+
+	for _, name := range sortedKeys(spec.Programs) {
+		prog := spec.Programs[name]
+
+		var kind string
+		var exists func(target string) bool
+		switch {
+		case types.IsKprobe(prog):
+			kind = "function"
+			exists = kernel.hasFunction
+		case types.IsTracepoint(prog):
+			kind = "tracepoint"
+			exists = func(target string) bool {
+				category, event, ok := strings.Cut(target, "/")
+				return ok && kernel.hasTracepoint(category, event)
+			}
+		default:
+			continue
+		}
+
+		if exists(prog.AttachTo) {
+			targets[name] = prog.AttachTo
+			continue
+		}
+
+		var alternatives []types.AttachTarget
+		if metadata != nil {
+			alternatives = metadata.Programs[name].Alternatives
+		}
+		tried := []string{}
+		for _, alternative := range alternatives {
+			if !alternative.Matches(kernelVersion) {
+				continue
+			}
+			if exists(alternative.Target) {
+				targets[name] = alternative.Target
+				break
+			}
+			tried = append(tried, alternative.Target)
+		}
+		if _, ok := targets[name]; ok {
+			continue
+		}
+
+		if len(tried) == 0 {
+			result = multierror.Append(result, fmt.Errorf("%s %q of program %q not found in the kernel", kind, prog.AttachTo, name))
+		} else {
+			result = multierror.Append(result, fmt.Errorf("%s %q of program %q not found in the kernel, nor its alternatives %s",
+				kind, prog.AttachTo, name, strings.Join(tried, ", ")))
+		}
+	}
+
+	return targets, result
 }
@@ -0,0 +1,154 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/histogram"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// histogramReader sends the distribution of a histogram of the gadget.
+type histogramReader struct {
+	// Name of the histogram in the metadata
+	name    string
+	mapName string
+	unit    histogram.Unit
+	// Size and number of the counters of each value of the map
+	slotSize uint32
+	nSlots   uint32
+
+	histogramMap *ebpf.Map
+}
+
+// handleHistograms prepares the histograms of the gadget.
+func (t *Tracer) handleHistograms() error {
+	t.histogramReaders = nil
+	for _, name := range sortedKeys(t.config.Metadata.Histograms) {
+		h := t.config.Metadata.Histograms[name]
+
+		histogramMap := t.spec.Maps[h.MapName]
+		if histogramMap == nil {
+			return fmt.Errorf("map %q not found", h.MapName)
+		}
+		slotSize, nSlots, err := types.HistogramSlots(histogramMap.Value)
+		if err != nil {
+			return fmt.Errorf("value of BPF map %q: %w", h.MapName, err)
+		}
+
+		t.histogramReaders = append(t.histogramReaders, &histogramReader{
+			name:     name,
+			mapName:  h.MapName,
+			unit:     histogram.Unit(h.Unit),
+			slotSize: slotSize,
+			nSlots:   nSlots,
+		})
+	}
+
+	return nil
+}
+
+// readHistogram returns the sum of the counters of all the entries of the map
+// of r. When reset is set, the counters are cleared, for the next read to
+// only account for the values counted meanwhile.
+func readHistogram(r *histogramReader, reset bool) ([]uint64, error) {
+	slots := make([]uint64, r.nSlots)
+	add := func(key, value []byte) {
+		addHistogramSlots(slots, value, r.slotSize)
+	}
+
+	// The entries of the arrays can't be deleted, they are zeroed instead
+	if reset && r.histogramMap.Type() != ebpf.Array {
+		return slots, drainMap(r.histogramMap, add)
+	}
+
+	key := make([]byte, r.histogramMap.KeySize())
+	value := make([]byte, r.histogramMap.ValueSize())
+	zero := make([]byte, r.histogramMap.ValueSize())
+	iter := r.histogramMap.Iterate()
+	for iter.Next(key, value) {
+		add(key, value)
+		if reset && r.histogramMap.Type() == ebpf.Array {
+			if err := r.histogramMap.Put(key, zero); err != nil {
+				return nil, fmt.Errorf("resetting entry: %w", err)
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating map: %w", err)
+	}
+	return slots, nil
+}
+
+// sendHistograms sends the distribution of every histogram of the gadget.
+func (t *Tracer) sendHistograms(reset bool) error {
+	for _, r := range t.histogramReaders {
+		slots, err := readHistogram(r, reset)
+		if err != nil {
+			return fmt.Errorf("reading histogram %q from map %q: %w", r.name, r.mapName, err)
+		}
+
+		t.sendEvent(&types.Event{
+			Event: eventtypes.Event{
+				Type: eventtypes.NORMAL,
+			},
+			Tracer: r.name,
+			Histogram: &histogram.Histogram{
+				Unit:      r.unit,
+				Intervals: histogram.NewIntervalsFromExp2Slots(slots),
+			},
+		})
+	}
+	return nil
+}
+
+// runHistograms sends the distribution of the histograms on every histogram
+// interval, if it's set, until done is closed. The last distribution is sent
+// before returning.
+func (t *Tracer) runHistograms(logger logger.Logger, done <-chan struct{}) {
+	if t.config.HistogramInterval == 0 {
+		<-done
+		if err := t.sendHistograms(false); err != nil {
+			logger.Warnf("%v", err)
+		}
+		return
+	}
+
+	ticker := time.NewTicker(t.config.HistogramInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			if err := t.sendHistograms(true); err != nil {
+				logger.Warnf("%v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.sendHistograms(true); err != nil {
+				logger.Warnf("%v", err)
+				return
+			}
+		}
+	}
+}
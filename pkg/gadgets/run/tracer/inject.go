@@ -0,0 +1,141 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+)
+
+// Inject sends data, an event encoded the way the eBPF programs of the gadget
+// do, through the ring buffer or the perf buffer of the tracer tracerName, for
+// it to be read and decoded like the events of the programs. tracerName can be
+// empty when the gadget has a single tracer.
+func (s *Standalone) Inject(tracerName string, data []byte) error {
+	r, err := s.tracer.traceReader(tracerName)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("injecting an empty event")
+	}
+	traceMap := s.tracer.collection.Maps[r.mapName]
+	if traceMap == nil {
+		return fmt.Errorf("map %q not found", r.mapName)
+	}
+
+	// The program reads the event from an array map: the events can be bigger
+	// than its stack
+	dataMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "ig_inject",
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  uint32(len(data)),
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("creating map of the event: %w", err)
+	}
+	defer dataMap.Close()
+	if err := dataMap.Put(uint32(0), data); err != nil {
+		return fmt.Errorf("writing event: %w", err)
+	}
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         "ig_inject",
+		Type:         ebpf.SocketFilter,
+		License:      "GPL",
+		Instructions: injectInstructions(traceMap, dataMap, len(data)),
+	})
+	if err != nil {
+		return fmt.Errorf("loading program injecting the event: %w", err)
+	}
+	defer prog.Close()
+
+	// Socket filters are run with a packet, at least as big as an ethernet
+	// header
+	ret, err := prog.Run(&ebpf.RunOptions{Data: make([]byte, 14)})
+	if err != nil {
+		return fmt.Errorf("running program injecting the event: %w", err)
+	}
+	if ret != 0 {
+		return fmt.Errorf("sending event to map %q: error %d", r.mapName, int32(ret))
+	}
+
+	return nil
+}
+
+// injectInstructions returns a program sending the value of dataMap, of size
+// bytes, to traceMap, a ring buffer or a perf event array. It returns the
+// result of the helper sending it.
+func injectInstructions(traceMap, dataMap *ebpf.Map, size int) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		// Look up the event at key 0
+		asm.StoreImm(asm.RFP, -4, 0, asm.Word),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -4),
+		asm.LoadMapPtr(asm.R1, dataMap.FD()),
+		asm.FnMapLookupElem.Call(),
+		asm.JNE.Imm(asm.R0, 0, "send"),
+		asm.Mov.Imm(asm.R0, -1),
+		asm.Return(),
+	}
+
+	var send asm.Instructions
+	if traceMap.Type() == ebpf.RingBuf {
+		send = asm.Instructions{
+			asm.LoadMapPtr(asm.R1, traceMap.FD()),
+			asm.Mov.Reg(asm.R2, asm.R0),
+			asm.Mov.Imm(asm.R3, int32(size)),
+			asm.Mov.Imm(asm.R4, 0),
+			asm.FnRingbufOutput.Call(),
+		}
+	} else {
+		send = asm.Instructions{
+			asm.Mov.Reg(asm.R1, asm.R6),
+			asm.LoadMapPtr(asm.R2, traceMap.FD()),
+			// BPF_F_CURRENT_CPU
+			asm.LoadImm(asm.R3, 0xffffffff, asm.DWord),
+			asm.Mov.Reg(asm.R4, asm.R0),
+			asm.Mov.Imm(asm.R5, int32(size)),
+			asm.FnPerfEventOutput.Call(),
+		}
+	}
+	send[0] = send[0].WithSymbol("send")
+
+	return append(append(insns, send...), asm.Return())
+}
+
+// traceReader returns the reader of the tracer tracerName, or of the only
+// tracer of the gadget if tracerName is empty.
+func (t *Tracer) traceReader(tracerName string) (*traceReader, error) {
+	if tracerName == "" {
+		if len(t.traceReaders) != 1 {
+			return nil, fmt.Errorf("the gadget has %d tracers, one must be chosen", len(t.traceReaders))
+		}
+		return t.traceReaders[0], nil
+	}
+	for _, r := range t.traceReaders {
+		if r.name == tracerName {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("tracer %q not found", tracerName)
+}
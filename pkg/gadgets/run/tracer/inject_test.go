@@ -0,0 +1,94 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+)
+
+func TestInjectInstructions(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	data := []byte("synthetic event")
+
+	dataMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  uint32(len(data)),
+		MaxEntries: 1,
+	})
+	require.NoError(t, err)
+	defer dataMap.Close()
+	require.NoError(t, dataMap.Put(uint32(0), data))
+
+	run := func(traceMap *ebpf.Map) {
+		prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+			Type:         ebpf.SocketFilter,
+			License:      "GPL",
+			Instructions: injectInstructions(traceMap, dataMap, len(data)),
+		})
+		require.NoError(t, err)
+		defer prog.Close()
+
+		ret, err := prog.Run(&ebpf.RunOptions{Data: make([]byte, 14)})
+		require.NoError(t, err)
+		require.Zero(t, ret)
+	}
+
+	t.Run("ringbuf", func(t *testing.T) {
+		traceMap, err := ebpf.NewMap(&ebpf.MapSpec{
+			Type:       ebpf.RingBuf,
+			MaxEntries: uint32(os.Getpagesize()),
+		})
+		require.NoError(t, err)
+		defer traceMap.Close()
+
+		reader, err := ringbuf.NewReader(traceMap)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		run(traceMap)
+		record, err := reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, data, record.RawSample)
+	})
+
+	t.Run("perf", func(t *testing.T) {
+		traceMap, err := ebpf.NewMap(&ebpf.MapSpec{
+			Type: ebpf.PerfEventArray,
+		})
+		require.NoError(t, err)
+		defer traceMap.Close()
+
+		reader, err := perf.NewReader(traceMap, os.Getpagesize())
+		require.NoError(t, err)
+		defer reader.Close()
+
+		run(traceMap)
+		record, err := reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, data, record.RawSample[:len(data)])
+	})
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+// metricReader exports the counters of a map of the gadget as a Prometheus
+// metric.
+type metricReader struct {
+	// Name of the metric in the metadata
+	name      string
+	mapName   string
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	// Members of the keys giving the values of the labels, in the order of
+	// the labels of desc
+	labelMembers []btf.Member
+	valueMember  btf.Member
+
+	countersMap *ebpf.Map
+}
+
+// handleMetrics prepares the metrics of the gadget. They are labelled by the
+// image of the gadget, for the ones of the different gadgets not to collide.
+func (t *Tracer) handleMetrics() error {
+	t.metricReaders = nil
+	for _, name := range sortedKeys(t.config.Metadata.Metrics) {
+		metric := t.config.Metadata.Metrics[name]
+
+		countersMap := t.spec.Maps[metric.MapName]
+		if countersMap == nil {
+			return fmt.Errorf("map %q not found", metric.MapName)
+		}
+		key, ok := countersMap.Key.(*btf.Struct)
+		if !ok {
+			return fmt.Errorf("key of BPF map %q is not a structure", metric.MapName)
+		}
+		labelMembers, err := types.MetricLabelMembers(metric, key)
+		if err != nil {
+			return fmt.Errorf("labels of metric %q: %w", name, err)
+		}
+		valueMember, err := types.MetricValueMember(metric, countersMap.Value)
+		if err != nil {
+			return fmt.Errorf("value of metric %q: %w", name, err)
+		}
+
+		labels := make([]string, 0, len(labelMembers))
+		for _, member := range labelMembers {
+			labels = append(labels, types.MetricLabelName(member.Name))
+		}
+		valueType := prometheus.CounterValue
+		if metric.Type == types.MetricTypeGauge {
+			valueType = prometheus.GaugeValue
+		}
+		help := metric.Description
+		if help == "" {
+			help = fmt.Sprintf("Counters of the map %s of the gadget", metric.MapName)
+		}
+
+		t.metricReaders = append(t.metricReaders, &metricReader{
+			name:         name,
+			mapName:      metric.MapName,
+			desc:         prometheus.NewDesc(name, help, labels, prometheus.Labels{"gadget": t.config.ImageName}),
+			valueType:    valueType,
+			labelMembers: labelMembers,
+			valueMember:  valueMember,
+		})
+	}
+
+	return nil
+}
+
+// readMetric returns the samples of the metric of r, summing the counters of
+// the entries of its map with the same labels.
+func readMetric(r *metricReader) (map[string]*metricSample, error) {
+	samples := map[string]*metricSample{}
+
+	key := make([]byte, r.countersMap.KeySize())
+	value := make([]byte, r.countersMap.ValueSize())
+	iter := r.countersMap.Iterate()
+	for iter.Next(key, value) {
+		labels := make([]string, 0, len(r.labelMembers))
+		for _, member := range r.labelMembers {
+			labels = append(labels, metricLabelValue(member, key))
+		}
+		addMetricSample(samples, labels, metricValue(r.valueMember, value))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating map: %w", err)
+	}
+	return samples, nil
+}
+
+// metricsCollector reads the maps of the metrics of the gadget when the
+// metrics endpoint is scraped.
+type metricsCollector struct {
+	readers []*metricReader
+}
+
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, r := range c.readers {
+		ch <- r.desc
+	}
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range c.readers {
+		samples, err := readMetric(r)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(r.desc,
+				fmt.Errorf("reading metric %q from map %q: %w", r.name, r.mapName, err))
+			continue
+		}
+		for _, sample := range samples {
+			ch <- prometheus.MustNewConstMetric(r.desc, r.valueType, sample.value, sample.labels...)
+		}
+	}
+}
+
+// registerMetrics exports the metrics of the gadget until the returned
+// function is called. They aren't exported when another instance of the
+// gadget already does.
+func (t *Tracer) registerMetrics(logger logger.Logger) func() {
+	collector := &metricsCollector{readers: t.metricReaders}
+	if err := prometheus.DefaultRegisterer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			logger.Warnf("the metrics of gadget %q are already exported by another instance of it", t.config.ImageName)
+		} else {
+			logger.Warnf("exporting metrics: %v", err)
+		}
+		return func() {}
+	}
+	return func() {
+		prometheus.DefaultRegisterer.Unregister(collector)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const ParamPullSecret = "pull-secret"
+
+// Environment variables set in the gadget pod using the downward API
+const (
+	podNameEnv      = "TRACELOOP_POD_NAME"
+	podNamespaceEnv = "TRACELOOP_POD_NAMESPACE"
+)
+
+// GetPullSecrets returns the docker configs of the given pull secrets and of
+// the imagePullSecrets of the gadget pod and its service account, like the
+// kubelet does to pull the container images. The secrets are looked up in the
+// namespace of the gadget pod. It returns nothing when not running in the
+// gadget pod.
+func GetPullSecrets(ctx context.Context, names []string) ([][]byte, error) {
+	podName := os.Getenv(podNameEnv)
+	namespace := os.Getenv(podNamespaceEnv)
+
+	config, err := rest.InClusterConfig()
+	if err != nil || podName == "" || namespace == "" {
+		if len(names) > 0 {
+			return nil, fmt.Errorf("%s is only supported when running in Kubernetes", ParamPullSecret)
+		}
+		return nil, nil
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating Kubernetes client: %w", err)
+	}
+
+	explicit := map[string]struct{}{}
+	for _, name := range names {
+		explicit[name] = struct{}{}
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		log.Debugf("getting gadget pod to look for its pull secrets: %v", err)
+	} else {
+		for _, ref := range pod.Spec.ImagePullSecrets {
+			names = append(names, ref.Name)
+		}
+		sa, err := client.CoreV1().ServiceAccounts(namespace).Get(ctx, pod.Spec.ServiceAccountName, metav1.GetOptions{})
+		if err != nil {
+			log.Debugf("getting service account %q to look for its pull secrets: %v", pod.Spec.ServiceAccountName, err)
+		} else {
+			for _, ref := range sa.ImagePullSecrets {
+				names = append(names, ref.Name)
+			}
+		}
+	}
+
+	configs := [][]byte{}
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			// Only fail for the secrets requested by the user
+			if _, ok := explicit[name]; ok {
+				return nil, fmt.Errorf("getting pull secret %q: %w", name, err)
+			}
+			log.Debugf("getting pull secret %q: %v", name, err)
+			continue
+		}
+		config, err := dockerConfigFromSecret(secret)
+		if err != nil {
+			if _, ok := explicit[name]; ok {
+				return nil, err
+			}
+			log.Debugf("%v", err)
+			continue
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+// dockerConfigFromSecret returns the docker config file stored in the secret.
+// The legacy .dockercfg format, only containing the "auths" section, is
+// converted.
+func dockerConfigFromSecret(secret *v1.Secret) ([]byte, error) {
+	switch secret.Type {
+	case v1.SecretTypeDockerConfigJson:
+		if config, ok := secret.Data[v1.DockerConfigJsonKey]; ok {
+			return config, nil
+		}
+	case v1.SecretTypeDockercfg:
+		if auths, ok := secret.Data[v1.DockerConfigKey]; ok {
+			return []byte(fmt.Sprintf(`{"auths":%s}`, auths)), nil
+		}
+	default:
+		return nil, fmt.Errorf("secret %q has type %q: expected %q or %q", secret.Name, secret.Type,
+			v1.SecretTypeDockerConfigJson, v1.SecretTypeDockercfg)
+	}
+	return nil, fmt.Errorf("secret %q doesn't contain a docker config", secret.Name)
+}
+
+func parsePullSecrets(value string) []string {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"errors"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kernelbtf"
+)
+
+// featureProbes tell whether the running kernel supports the features of
+// types.BPFFeatures
+var featureProbes = map[string]func() error{
+	"ringbuf": func() error {
+		return features.HaveMapType(ebpf.RingBuf)
+	},
+	"bpf_loop": func() error {
+		return features.HaveProgramHelper(ebpf.Kprobe, asm.FnLoop)
+	},
+	"bpf_ktime_get_boot_ns": func() error {
+		return features.HaveProgramHelper(ebpf.Kprobe, asm.FnKtimeGetBootNs)
+	},
+	"co-re": func() error {
+		_, err := kernelbtf.Spec()
+		if errors.Is(err, btf.ErrNotSupported) || errors.Is(err, os.ErrNotExist) {
+			return ebpf.ErrNotSupported
+		}
+		return err
+	},
+	"fentry": func() error {
+		return features.HaveProgramType(ebpf.Tracing)
+	},
+	"task_storage": func() error {
+		return features.HaveMapType(ebpf.TaskStorage)
+	},
+}
+
+// hasFeature tells whether the running kernel supports the given feature. The
+// features that can't be probed, or whose probe fails for another reason, are
+// assumed to be supported, for the loading errors to tell what's wrong.
+func hasFeature(feature string) bool {
+	probe, ok := featureProbes[feature]
+	if !ok {
+		return true
+	}
+	return !errors.Is(probe(), ebpf.ErrNotSupported)
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+)
+
+func TestFeatureProbes(t *testing.T) {
+	t.Parallel()
+
+	for feature := range types.BPFFeatures {
+		require.Contains(t, featureProbes, feature, "feature %q doesn't have a probe", feature)
+	}
+	for feature := range featureProbes {
+		require.Contains(t, types.BPFFeatures, feature, "probe of the unknown feature %q", feature)
+	}
+}
@@ -15,16 +15,19 @@
 package tracer
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"math/bits"
+	"os"
 	"reflect"
+	"strconv"
+	"sync"
+	"time"
 	"unsafe"
 
-	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 	k8syaml "sigs.k8s.io/yaml"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
@@ -33,6 +36,8 @@ import (
 	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/histogram"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kernelbtf"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
@@ -41,6 +46,15 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/experimental"
 )
 
+const (
+	ParamPullPolicy        = "pull-policy"
+	ParamBufferPages       = "buffer-pages"
+	ParamAggregateInterval = "aggregate-interval"
+	ParamHistogramInterval = "histogram-interval"
+	ParamMapEntriesScale   = "map-entries-scale"
+	ParamPinKey            = "pin-key"
+)
+
 type GadgetDesc struct{}
 
 func (g *GadgetDesc) Name() string {
@@ -69,6 +83,143 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			DefaultValue: oci.DefaultAuthFile,
 			TypeHint:     params.TypeString,
 		},
+		{
+			Key:          "registries-config",
+			Title:        "Registries config",
+			Description:  "Path of the file with the TLS, plain HTTP and proxy settings of the registries",
+			DefaultValue: oci.DefaultRegistriesConfigFile,
+			TypeHint:     params.TypeString,
+		},
+		{
+			Key:         ParamPullSecret,
+			Title:       "Pull secrets",
+			Description: "Comma-separated list of secrets, in the namespace of Inspektor Gadget, to pull the image from a private registry. Only supported in Kubernetes",
+			TypeHint:    params.TypeString,
+		},
+		{
+			Key:            ParamPullPolicy,
+			Title:          "Pull policy",
+			Description:    "When to pull the image: Always, to get the latest version of the tag, IfNotPresent or Never",
+			DefaultValue:   oci.PullImageIfNotPresent,
+			PossibleValues: oci.PullPolicies,
+			TypeHint:       params.TypeString,
+		},
+		{
+			Key:   ParamBufferPages,
+			Title: "Buffer pages",
+			Description: "Number of memory pages of the buffer the events are read from: per CPU for a perf event array, in total for a ring buffer. " +
+				"Bigger buffers use more memory but lose fewer events on busy nodes. 0 to use the default of the gadget",
+			DefaultValue: "0",
+			TypeHint:     params.TypeUint32,
+			Validator: func(value string) error {
+				pages, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return err
+				}
+				if pages != 0 && bits.OnesCount64(pages) != 1 {
+					return fmt.Errorf("must be a power of 2")
+				}
+				return checkBufferPages(uint32(pages), os.Getpagesize())
+			},
+		},
+		{
+			Key:   ParamMapEntriesScale,
+			Title: "Map entries scale",
+			Description: "Factor the maximum number of entries of the maps declared as scalable by the gadget is multiplied by. " +
+				"Bigger maps use more memory but drop fewer entries on busy nodes",
+			DefaultValue: "1",
+			TypeHint:     params.TypeFloat64,
+			Validator: func(value string) error {
+				scale, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return err
+				}
+				if scale <= 0 {
+					return fmt.Errorf("must be positive")
+				}
+				return nil
+			},
+		},
+		{
+			Key:   ParamPinKey,
+			Title: "Pin key",
+			Description: "Keep the state of the maps declared with the pinning attribute by the gadget across runs, under this key. " +
+				"The runs of the same repository with the same key share it. Empty to start every run without state",
+			TypeHint: params.TypeString,
+			Validator: func(value string) error {
+				if value == "." || value == ".." {
+					return fmt.Errorf("invalid key %q", value)
+				}
+				return nil
+			},
+		},
+		{
+			Key:   ParamAggregateInterval,
+			Title: "Aggregate interval",
+			Description: "Count the events in the kernel and send each distinct event with its count on this interval instead of sending every event. " +
+				"Only supported by the gadgets declaring an aggregation map. 0 to send every event",
+			DefaultValue: "0",
+			TypeHint:     params.TypeDuration,
+			Validator: func(value string) error {
+				interval, err := time.ParseDuration(value)
+				if err != nil {
+					return err
+				}
+				if interval < 0 {
+					return fmt.Errorf("must not be negative")
+				}
+				return nil
+			},
+		},
+		{
+			Key:          gadgets.ParamInterval,
+			Title:        "Interval",
+			Description:  "Interval the entries of the toppers are sent on. Only used by the gadgets with toppers. 0 to use the default of each topper",
+			DefaultValue: "0",
+			TypeHint:     params.TypeDuration,
+			Validator: func(value string) error {
+				interval, err := time.ParseDuration(value)
+				if err != nil {
+					return err
+				}
+				if interval < 0 {
+					return fmt.Errorf("must not be negative")
+				}
+				return nil
+			},
+		},
+		{
+			Key:          gadgets.ParamMaxRows,
+			Title:        "Max Rows",
+			Description:  "Maximum number of entries sent by each topper on every interval. 0 to send all of them",
+			DefaultValue: "50",
+			TypeHint:     params.TypeUint32,
+		},
+		{
+			Key:   gadgets.ParamSortBy,
+			Title: "Sort By",
+			Description: "Sort the entries of the toppers and snapshotters by these fields. Join multiple fields with ','. Prefix a field with '-' to sort in descending order. " +
+				"Empty to use the default order of each of them",
+			TypeHint: params.TypeString,
+		},
+		{
+			Key:   ParamHistogramInterval,
+			Title: "Histogram interval",
+			Description: "Send the distributions of the histograms and clear them on this interval. " +
+				"Only used by the gadgets with histograms. 0 to send them once the gadget stops",
+			DefaultValue: "0",
+			TypeHint:     params.TypeDuration,
+			Validator: func(value string) error {
+				interval, err := time.ParseDuration(value)
+				if err != nil {
+					return err
+				}
+				if interval < 0 {
+					return fmt.Errorf("must not be negative")
+				}
+				return nil
+			},
+		},
 		{
 			Key:          types.ValidateMetadataParam,
 			Title:        "Validate metadata",
@@ -76,6 +227,13 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			DefaultValue: "true",
 			TypeHint:     params.TypeBool,
 		},
+		{
+			Key:          types.DefaultFiltersParam,
+			Title:        "Default filters",
+			Description:  "Apply the default filters declared in the gadget metadata to its events",
+			DefaultValue: "true",
+			TypeHint:     params.TypeBool,
+		},
 	}
 }
 
@@ -84,15 +242,24 @@ func (g *GadgetDesc) Parser() parser.Parser {
 }
 
 func getGadgetInfo(params *params.Params, args []string, logger logger.Logger) (*types.GadgetInfo, error) {
+	dockerConfigs, err := GetPullSecrets(context.TODO(), parsePullSecrets(params.Get(ParamPullSecret).AsString()))
+	if err != nil {
+		return nil, fmt.Errorf("getting pull secrets: %w", err)
+	}
 	authOpts := &oci.AuthOptions{
-		AuthFile: params.Get("authfile").AsString(),
+		AuthFile:             params.Get("authfile").AsString(),
+		DockerConfigs:        dockerConfigs,
+		RegistriesConfigFile: params.Get("registries-config").AsString(),
 	}
-	gadget, err := oci.GetGadgetImage(context.TODO(), args[0], authOpts)
+	gadget, err := oci.GetGadgetImage(context.TODO(), args[0], authOpts, params.Get(ParamPullPolicy).AsString())
 	if err != nil {
 		return nil, fmt.Errorf("getting gadget image: %w", err)
 	}
+	logger.Debugf("image %q resolved to %s", gadget.Ref, gadget.Digest)
 
 	ret := &types.GadgetInfo{
+		ImageRef:       gadget.Ref,
+		ImageDigest:    gadget.Digest,
 		ProgContent:    gadget.EbpfObject,
 		GadgetMetadata: &types.GadgetMetadata{},
 	}
@@ -110,8 +277,9 @@ func getGadgetInfo(params *params.Params, args []string, logger logger.Logger) (
 	} else {
 		validate := params.Get(types.ValidateMetadataParam).AsBool()
 
-		if err := yaml.Unmarshal(gadget.Metadata, &ret.GadgetMetadata); err != nil {
-			return nil, fmt.Errorf("unmarshaling metadata: %w", err)
+		ret.GadgetMetadata, err = types.DecodeMetadata(gadget.Metadata, false)
+		if err != nil {
+			return nil, fmt.Errorf("decoding metadata: %w", err)
 		}
 
 		if err := ret.GadgetMetadata.Validate(spec); err != nil {
@@ -123,6 +291,25 @@ func getGadgetInfo(params *params.Params, args []string, logger logger.Logger) (
 		}
 	}
 
+	// The parameters of the gadget are given along with the ones of this one
+	runParamDescs := (&GadgetDesc{}).ParamDescs()
+	for key := range ret.GadgetMetadata.Params {
+		if runParamDescs.Get(key) != nil {
+			return nil, fmt.Errorf("param %q of the gadget has the same key as a param of the run gadget", key)
+		}
+	}
+
+	if params.Get(ParamAggregateInterval).AsDuration() != 0 {
+		if _, ok := spec.Maps[gadgets.AggregationMapName]; !ok {
+			return nil, fmt.Errorf("gadget doesn't support aggregation: map %q not found", gadgets.AggregationMapName)
+		}
+		ret.Aggregated = true
+	}
+
+	if params.Get(types.DefaultFiltersParam).AsBool() {
+		ret.DefaultFilters = ret.GadgetMetadata.DefaultFilters
+	}
+
 	return ret, nil
 }
 
@@ -139,19 +326,12 @@ func getUnderlyingType(tf *btf.Typedef) (btf.Type, error) {
 	}
 }
 
-func loadSpec(progContent []byte) (*ebpf.CollectionSpec, error) {
-	progReader := bytes.NewReader(progContent)
-	spec, err := ebpf.LoadCollectionSpecFromReader(progReader)
-	if err != nil {
-		return nil, fmt.Errorf("loading spec: %w", err)
-	}
-	return spec, err
-}
-
 func getType(typ btf.Type) reflect.Type {
-	switch typedMember := typ.(type) {
+	switch typedMember := btf.UnderlyingType(typ).(type) {
 	case *btf.Array:
-		arrType := getSimpleType(typedMember.Type)
+		// The arrays of chars, given as arrays of (u)int8, are shown as
+		// NUL-terminated strings by the columns
+		arrType := getSimpleType(btf.UnderlyingType(typedMember.Type))
 		if arrType == nil {
 			return nil
 		}
@@ -199,6 +379,29 @@ func getSimpleType(typ btf.Type) reflect.Type {
 		case 8:
 			return reflect.TypeOf(float64(0))
 		}
+	case *btf.Enum:
+		if typedMember.Signed {
+			switch typedMember.Size {
+			case 1:
+				return reflect.TypeOf(int8(0))
+			case 2:
+				return reflect.TypeOf(int16(0))
+			case 4:
+				return reflect.TypeOf(int32(0))
+			case 8:
+				return reflect.TypeOf(int64(0))
+			}
+		}
+		switch typedMember.Size {
+		case 1:
+			return reflect.TypeOf(uint8(0))
+		case 2:
+			return reflect.TypeOf(uint16(0))
+		case 4:
+			return reflect.TypeOf(uint32(0))
+		case 8:
+			return reflect.TypeOf(uint64(0))
+		}
 	case *btf.Typedef:
 		typ, _ := getUnderlyingType(typedMember)
 		return getSimpleType(typ)
@@ -207,6 +410,99 @@ func getSimpleType(typ btf.Type) reflect.Type {
 	return nil
 }
 
+// getEnum returns the enum type of a member, or nil if the member is not an enum.
+func getEnum(typ btf.Type) *btf.Enum {
+	switch typedMember := typ.(type) {
+	case *btf.Enum:
+		return typedMember
+	case *btf.Typedef:
+		typ, _ := getUnderlyingType(typedMember)
+		return getEnum(typ)
+	}
+
+	return nil
+}
+
+// getEnumValueNames returns a map from the values of the enum to their names. The values of an
+// enum defined by the kernel (like skb_drop_reason) can change between kernel versions, so the
+// kernel's BTF information is preferred over the one in the eBPF object when available. The
+// kernel's BTF information is parsed once and shared by all the enums, see kernelbtf.Spec.
+func getEnumValueNames(enum *btf.Enum) map[uint64]string {
+	source := enum
+	if enum.Name != "" {
+		if kernelSpec, err := kernelbtf.Spec(); err == nil {
+			var kernelEnum *btf.Enum
+			if err := kernelSpec.TypeByName(enum.Name, &kernelEnum); err == nil {
+				source = kernelEnum
+			}
+		}
+	}
+
+	return enumValueNames(enum, source)
+}
+
+// enumValueNames returns a map from the values of enum to their names, taken from source. The
+// events are laid out as described by the gadget's BTF information, so the values are keyed with
+// the size and the signedness of enum, regardless of the ones of source.
+func enumValueNames(enum, source *btf.Enum) map[uint64]string {
+	names := make(map[uint64]string, len(source.Values))
+	for _, v := range source.Values {
+		names[enumValue(enum, v.Value)] = v.Name
+	}
+
+	return names
+}
+
+// enumValue returns v truncated to the size of enum and, if enum is signed, sign-extended to 64
+// bits, the way the BTF information stores the values of the signed enums.
+func enumValue(enum *btf.Enum, v uint64) uint64 {
+	if enum.Size == 0 || enum.Size >= 8 {
+		return v
+	}
+	bits := enum.Size * 8
+	v &= 1<<bits - 1
+	if enum.Signed && v&(1<<(bits-1)) != 0 {
+		v |= ^uint64(0) << bits
+	}
+	return v
+}
+
+// addEnumColumn adds a virtual column that shows the name of the enum value stored at the offset
+// of the raw event given by getOffset, if the event has it.
+func addEnumColumn(cols *columns.Columns[types.Event], attrs columns.Attributes, enum *btf.Enum, getOffset func(*types.Event) (uint32, bool)) error {
+	names := getEnumValueNames(enum)
+	size := enum.Size
+
+	return cols.AddColumn(attrs, func(ev *types.Event) any {
+		offset, ok := getOffset(ev)
+		if !ok || uint32(len(ev.RawData)) < offset+size {
+			return ""
+		}
+
+		var value uint64
+		data := unsafe.Pointer(&ev.RawData[offset])
+		switch size {
+		case 1:
+			value = uint64(*(*uint8)(data))
+		case 2:
+			value = uint64(*(*uint16)(data))
+		case 4:
+			value = uint64(*(*uint32)(data))
+		case 8:
+			value = *(*uint64)(data)
+		}
+		value = enumValue(enum, value)
+
+		if name, ok := names[value]; ok {
+			return name
+		}
+		if enum.Signed {
+			return fmt.Sprintf("UNKNOWN (%d)", int64(value))
+		}
+		return fmt.Sprintf("UNKNOWN (%d)", value)
+	})
+}
+
 func addL3EndpointColumns(
 	cols *columns.Columns[types.Event],
 	name string,
@@ -295,6 +591,8 @@ func field2ColumnAttrs(field *types.Field) columns.Attributes {
 	if fieldAttrs.Template != "" {
 		attrs.Template = fieldAttrs.Template
 	}
+	// Only used by the formatters for the numbers
+	attrs.Unit = columns.Unit(fieldAttrs.GetUnit())
 
 	switch fieldAttrs.Alignment {
 	case types.AlignmentLeft:
@@ -316,102 +614,264 @@ func field2ColumnAttrs(field *types.Field) columns.Attributes {
 }
 
 func (g *GadgetDesc) getColumns(info *types.GadgetInfo) (*columns.Columns[types.Event], error) {
-	gadgetMetadata := info.GadgetMetadata
-	eventType, err := getEventTypeBTF(info.ProgContent, gadgetMetadata)
+	spec, err := loadSpec(info.ProgContent)
+	if err != nil {
+		return nil, err
+	}
+	eventTypes, err := getEventTypesBTF(spec, info.GadgetMetadata)
 	if err != nil {
 		return nil, fmt.Errorf("getting value struct: %w", err)
 	}
+	return newColumns(info.GadgetMetadata, eventTypes, info.Aggregated)
+}
 
-	eventStruct, ok := gadgetMetadata.Structs[eventType.Name]
-	if !ok {
-		return nil, fmt.Errorf("struct %s not found in gadget metadata", eventType.Name)
+// eventField is a field shown in a column, with the member holding it in the
+// event type of each tracer having it.
+type eventField struct {
+	field   types.Field
+	members map[string]btf.Member
+}
+
+// sameMemberType tells if the members can be shown in the same column.
+func sameMemberType(a, b btf.Member) bool {
+	return a.Type.TypeName() == b.Type.TypeName() &&
+		getType(a.Type) == getType(b.Type) &&
+		(getEnum(a.Type) == nil) == (getEnum(b.Type) == nil)
+}
+
+// newColumns returns the columns of the events of the gadget, given the types
+// of the events of its tracers, snapshotters and toppers. The fields with the
+// same name in the structs of several of them, or of the variants of a tracer,
+// are shown in the same column, empty for the events of the ones not having
+// them. The events of the histograms don't have fields, their distribution is
+// shown after the columns.
+func newColumns(gadgetMetadata *types.GadgetMetadata, eventTypes map[string]*btf.Struct, aggregated bool) (*columns.Columns[types.Event], error) {
+	tracerNames := sortedKeys(eventTypes)
+	variants := 0
+	for _, name := range tracerNames {
+		if isVariantEventType(gadgetMetadata, name) {
+			variants++
+		}
+	}
+	multipleTypes := len(tracerNames) > 1
+	multipleTracers := len(tracerNames)-variants+len(gadgetMetadata.Histograms) > 1
+
+	eventFields := []*eventField{}
+	eventFieldsByName := map[string]*eventField{}
+
+	for _, tracerName := range tracerNames {
+		eventType := eventTypes[tracerName]
+		fields, err := getEventFields(gadgetMetadata, tracerName, eventType)
+		if err != nil {
+			return nil, err
+		}
+
+		members := map[string]btf.Member{}
+		for _, member := range types.FlattenMembers(eventType, 0) {
+			members[member.Name] = member
+		}
+
+		for _, field := range fields {
+			member, ok := members[field.Name]
+			if !ok {
+				continue
+			}
+
+			ef, ok := eventFieldsByName[field.Name]
+			if !ok {
+				ef = &eventField{field: field, members: map[string]btf.Member{}}
+				eventFields = append(eventFields, ef)
+				eventFieldsByName[field.Name] = ef
+			} else {
+				for otherTracer, other := range ef.members {
+					if !sameMemberType(member, other) {
+						return nil, fmt.Errorf("field %q has different types in tracers %q and %q",
+							field.Name, otherTracer, tracerName)
+					}
+					break
+				}
+			}
+			ef.members[tracerName] = member
+		}
 	}
 
 	cols := types.GetColumns()
 
-	members := map[string]btf.Member{}
-	for _, member := range eventType.Members {
-		members[member.Name] = member
+	if multipleTracers {
+		err := cols.AddColumn(columns.Attributes{
+			Name:    "tracer",
+			Width:   16,
+			Visible: true,
+			Order:   999,
+		}, func(e *types.Event) any {
+			return e.Tracer
+		})
+		if err != nil {
+			return nil, fmt.Errorf("adding tracer column: %w", err)
+		}
 	}
 
-	fields := []columns.DynamicField{}
+	if variants > 0 {
+		err := cols.AddColumn(columns.Attributes{
+			Name:    "variant",
+			Width:   16,
+			Visible: true,
+			Order:   999,
+		}, func(e *types.Event) any {
+			return e.Variant
+		})
+		if err != nil {
+			return nil, fmt.Errorf("adding variant column: %w", err)
+		}
+	}
 
-	l3endpointCounter := 0
-	l4endpointCounter := 0
+	for i, ef := range eventFields {
+		// All the members have the same type, take any as reference
+		var member btf.Member
+		offsets := make(map[string]uint32, len(ef.members))
+		for tracerName, m := range ef.members {
+			member = m
+			offsets[tracerName] = m.Offset.Bytes()
+		}
 
-	for i, field := range eventStruct.Fields {
-		member := members[field.Name]
+		// getOffset returns the offset of the field in the raw data of the
+		// event, if its tracer has it
+		getOffset := func(e *types.Event) (uint32, bool) {
+			offset, ok := offsets[eventTypeName(e)]
+			return offset, ok
+		}
+		if !multipleTypes {
+			// Don't depend on the tracer of the events, which isn't set by
+			// older versions
+			offset := member.Offset.Bytes()
+			getOffset = func(*types.Event) (uint32, bool) {
+				return offset, true
+			}
+		}
 
-		attrs := field2ColumnAttrs(&field)
+		attrs := field2ColumnAttrs(&ef.field)
 		attrs.Order = 1000 + i
 
+		// Show the endpoints like the built-in gadgets, and the values of the
+		// other kinds with their templates, when the metadata doesn't tell
+		// how, as generated by older versions
+		defaultTemplate := func(kind types.FieldKind) {
+			if ef.field.Attributes.Template == "" && ef.field.Attributes.Width == 0 {
+				attrs.Template = types.GetKindColumnTemplate(kind)
+			}
+		}
+
 		switch typedMember := member.Type.(type) {
 		case *btf.Struct:
 			switch typedMember.Name {
 			case gadgets.L3EndpointTypeName:
-				// Take the value here, otherwise it'll use the wrong value after
-				// it's increased
-				index := l3endpointCounter
-				// Add the column that is enriched
-				eventtypes.MustAddVirtualL3EndpointColumn(cols, attrs, func(e *types.Event) eventtypes.L3Endpoint {
-					if len(e.L3Endpoints) == 0 {
-						return eventtypes.L3Endpoint{}
+				defaultTemplate(types.KindL3Endpoint)
+				name := member.Name
+				getEndpoint := func(e *types.Event) eventtypes.L3Endpoint {
+					for _, endpoint := range e.L3Endpoints {
+						if endpoint.Name == name {
+							return endpoint.L3Endpoint
+						}
 					}
-					return e.L3Endpoints[index].L3Endpoint
-				})
+					return eventtypes.L3Endpoint{}
+				}
+				// Add the column that is enriched
+				eventtypes.MustAddVirtualL3EndpointColumn(cols, attrs, getEndpoint)
 				// Add a single column for each field in the endpoint
-				addL3EndpointColumns(cols, member.Name, func(e *types.Event) eventtypes.L3Endpoint {
-					if len(e.L3Endpoints) == 0 {
-						return eventtypes.L3Endpoint{}
-					}
-					return e.L3Endpoints[index].L3Endpoint
-				})
-				l3endpointCounter++
+				addL3EndpointColumns(cols, member.Name, getEndpoint)
 				continue
 			case gadgets.L4EndpointTypeName:
-				// Take the value here, otherwise it'll use the wrong value after
-				// it's increased
-				index := l4endpointCounter
-				// Add the column that is enriched
-				eventtypes.MustAddVirtualL4EndpointColumn(cols, attrs, func(e *types.Event) eventtypes.L4Endpoint {
-					if len(e.L4Endpoints) == 0 {
-						return eventtypes.L4Endpoint{}
+				defaultTemplate(types.KindL4Endpoint)
+				name := member.Name
+				getEndpoint := func(e *types.Event) eventtypes.L4Endpoint {
+					for _, endpoint := range e.L4Endpoints {
+						if endpoint.Name == name {
+							return endpoint.L4Endpoint
+						}
 					}
-					return e.L4Endpoints[index].L4Endpoint
-				})
+					return eventtypes.L4Endpoint{}
+				}
+				// Add the column that is enriched
+				eventtypes.MustAddVirtualL4EndpointColumn(cols, attrs, getEndpoint)
 				// Add a single column for each field in the endpoint
-				addL4EndpointColumns(cols, member.Name, func(e *types.Event) eventtypes.L4Endpoint {
-					if len(e.L4Endpoints) == 0 {
-						return eventtypes.L4Endpoint{}
-					}
-					return e.L4Endpoints[index].L4Endpoint
-				})
-				l4endpointCounter++
+				addL4EndpointColumns(cols, member.Name, getEndpoint)
 				continue
 			}
 		}
 
+		if flags := getFieldFlags(&ef.field, member.Type); len(flags) != 0 {
+			if err := addFlagsColumn(cols, attrs, flags, member.Type, getOffset); err != nil {
+				return nil, fmt.Errorf("adding column %q: %w", member.Name, err)
+			}
+			continue
+		}
+
+		// The frames of the stacks are read when the events are received
+		if types.IsStackKind(ef.field.Attributes.Kind) {
+			name := member.Name
+			if err := cols.AddColumn(attrs, func(ev *types.Event) any {
+				return ev.FoldedStack(name)
+			}); err != nil {
+				return nil, fmt.Errorf("adding stack column %q: %w", member.Name, err)
+			}
+			continue
+		}
+
+		if kind := ef.field.Attributes.Kind; kind != types.KindNone && types.KindUnit(kind) == types.UnitNone {
+			defaultTemplate(kind)
+			if err := addKindColumn(cols, attrs, kind, member.Type, getOffset); err != nil {
+				return nil, fmt.Errorf("adding column %q: %w", member.Name, err)
+			}
+			continue
+		}
+
+		if enum := getEnum(member.Type); enum != nil && !ef.field.Attributes.RawValue {
+			if err := addEnumColumn(cols, attrs, enum, getOffset); err != nil {
+				return nil, fmt.Errorf("adding enum column %q: %w", member.Name, err)
+			}
+			continue
+		}
+
 		rType := getType(member.Type)
 		if rType == nil {
 			continue
 		}
 
+		// Read for the events of the tracers without the field
+		zero := make([]byte, rType.Size()+1)
+		size := uint32(rType.Size())
+		base := func(ev *types.Event) unsafe.Pointer {
+			offset, ok := getOffset(ev)
+			if !ok || uint32(len(ev.RawData)) < offset+size {
+				return unsafe.Pointer(&zero[0])
+			}
+			return unsafe.Pointer(&ev.RawData[offset])
+		}
+
 		field := columns.DynamicField{
 			Attributes: &attrs,
 			Template:   attrs.Template,
 			Type:       rType,
-			Offset:     uintptr(member.Offset.Bytes()),
 		}
-
-		fields = append(fields, field)
+		if err := cols.AddFields([]columns.DynamicField{field}, base); err != nil {
+			return nil, fmt.Errorf("adding fields: %w", err)
+		}
 	}
 
-	base := func(ev *types.Event) unsafe.Pointer {
-		return unsafe.Pointer(&ev.RawData[0])
-	}
-	if err := cols.AddFields(fields, base); err != nil {
-		return nil, fmt.Errorf("adding fields: %w", err)
+	if aggregated {
+		err := cols.AddColumn(columns.Attributes{
+			Name:      "count",
+			Width:     10,
+			Alignment: columns.AlignRight,
+			Order:     1000 + len(eventFields),
+		}, func(e *types.Event) any {
+			return e.Count
+		})
+		if err != nil {
+			return nil, fmt.Errorf("adding count column: %w", err)
+		}
 	}
+
 	return cols, nil
 }
 
@@ -421,7 +881,12 @@ func (g *GadgetDesc) CustomParser(info *types.GadgetInfo) (parser.Parser, error)
 		return nil, fmt.Errorf("getting columns: %w", err)
 	}
 
-	return parser.NewParser[types.Event](cols), nil
+	p := parser.NewParser[types.Event](cols)
+	if err := p.SetFilters(info.DefaultFilters); err != nil {
+		return nil, fmt.Errorf("setting default filters: %w", err)
+	}
+
+	return p, nil
 }
 
 func (g *GadgetDesc) customJsonParser(info *types.GadgetInfo, options ...columns_json.Option) (*columns_json.Formatter[types.Event], error) {
@@ -432,11 +897,73 @@ func (g *GadgetDesc) customJsonParser(info *types.GadgetInfo, options ...columns
 	return columns_json.NewFormatter(cols.ColumnMap, options...), nil
 }
 
-func jsonConverterFn(formatter *columns_json.Formatter[types.Event], printer types.Printer) func(ev any) {
+// logSpecialEvent logs the message of the events that don't carry data of the
+// gadget, like the reports of lost events, as they can't be formatted with its
+// columns. It returns false for the other events.
+func logSpecialEvent(ev *types.Event, printer types.Printer) bool {
+	switch ev.Type {
+	case eventtypes.ERR:
+		printer.Logf(logger.ErrorLevel, "%s", ev.Message)
+	case eventtypes.WARN:
+		printer.Logf(logger.WarnLevel, "%s", ev.Message)
+	case eventtypes.DEBUG:
+		printer.Logf(logger.DebugLevel, "%s", ev.Message)
+	case eventtypes.INFO:
+		printer.Logf(logger.InfoLevel, "%s", ev.Message)
+	default:
+		return false
+	}
+	return true
+}
+
+// histogramJSON returns the JSON encoding of an event of a histogram, as its
+// distribution isn't in the columns.
+func histogramJSON(ev *types.Event, indent string) (string, error) {
+	h := struct {
+		Tracer    string               `json:"tracer,omitempty"`
+		Histogram *histogram.Histogram `json:"histogram"`
+	}{
+		Tracer:    ev.Tracer,
+		Histogram: ev.Histogram,
+	}
+	b, err := json.MarshalIndent(h, "", indent)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func jsonConverterFn(formatter *columns_json.Formatter[types.Event], printer types.Printer, indent string) func(ev any) {
+	// The events are formatted into the same buffer when the printer
+	// supports it, for them not to be allocated. The events of several nodes
+	// can be converted at the same time.
+	bytesPrinter, _ := printer.(types.BytesPrinter)
+	var mu sync.Mutex
+	var buf []byte
+
 	return func(ev any) {
 		switch typ := ev.(type) {
 		case *types.Event:
-			printer.Output(formatter.FormatEntry(typ))
+			if logSpecialEvent(typ, printer) {
+				return
+			}
+			if typ.Histogram != nil {
+				eventJson, err := histogramJSON(typ, indent)
+				if err != nil {
+					printer.Logf(logger.WarnLevel, "marshaling histogram: %s", err)
+					return
+				}
+				printer.Output(eventJson)
+				return
+			}
+			if bytesPrinter == nil {
+				printer.Output(formatter.FormatEntry(typ))
+				return
+			}
+			mu.Lock()
+			buf = append(formatter.AppendEntry(buf[:0], typ), '\n')
+			bytesPrinter.OutputBytes(buf)
+			mu.Unlock()
 		case []*types.Event:
 			printer.Output(formatter.FormatEntries(typ))
 		default:
@@ -445,26 +972,26 @@ func jsonConverterFn(formatter *columns_json.Formatter[types.Event], printer typ
 	}
 }
 
-func (g *GadgetDesc) JSONConverter(info *types.GadgetInfo, printer types.Printer) func(ev any) {
-	formatter, err := g.customJsonParser(info)
+func (g *GadgetDesc) JSONConverter(info *types.GadgetInfo, printer types.Printer, options ...columns_json.Option) func(ev any) {
+	formatter, err := g.customJsonParser(info, options...)
 	if err != nil {
 		printer.Logf(logger.WarnLevel, "creating json formatter: %s", err)
 		return nil
 	}
-	return jsonConverterFn(formatter, printer)
+	return jsonConverterFn(formatter, printer, "")
 }
 
-func (g *GadgetDesc) JSONPrettyConverter(info *types.GadgetInfo, printer types.Printer) func(ev any) {
-	formatter, err := g.customJsonParser(info, columns_json.WithPrettyPrint())
+func (g *GadgetDesc) JSONPrettyConverter(info *types.GadgetInfo, printer types.Printer, options ...columns_json.Option) func(ev any) {
+	formatter, err := g.customJsonParser(info, append(options, columns_json.WithPrettyPrint())...)
 	if err != nil {
 		printer.Logf(logger.WarnLevel, "creating json formatter: %s", err)
 		return nil
 	}
-	return jsonConverterFn(formatter, printer)
+	return jsonConverterFn(formatter, printer, "  ")
 }
 
-func (g *GadgetDesc) YAMLConverter(info *types.GadgetInfo, printer types.Printer) func(ev any) {
-	formatter, err := g.customJsonParser(info)
+func (g *GadgetDesc) YAMLConverter(info *types.GadgetInfo, printer types.Printer, options ...columns_json.Option) func(ev any) {
+	formatter, err := g.customJsonParser(info, options...)
 	if err != nil {
 		printer.Logf(logger.WarnLevel, "creating json formatter: %s", err)
 		return nil
@@ -473,6 +1000,17 @@ func (g *GadgetDesc) YAMLConverter(info *types.GadgetInfo, printer types.Printer
 		var eventJson string
 		switch typ := ev.(type) {
 		case *types.Event:
+			if logSpecialEvent(typ, printer) {
+				return
+			}
+			if typ.Histogram != nil {
+				var err error
+				if eventJson, err = histogramJSON(typ, ""); err != nil {
+					printer.Logf(logger.WarnLevel, "marshaling histogram: %s", err)
+					return
+				}
+				break
+			}
 			eventJson = formatter.FormatEntry(typ)
 		case []*types.Event:
 			eventJson = formatter.FormatEntries(typ)
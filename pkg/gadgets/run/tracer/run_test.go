@@ -0,0 +1,1069 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	columns_json "github.com/inspektor-gadget/inspektor-gadget/pkg/columns/formatter/json"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/histogram"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestGetColumnsAggregated(t *testing.T) {
+	progContent, err := os.ReadFile("../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o")
+	require.NoError(t, err)
+
+	spec, err := loadSpec(progContent)
+	require.NoError(t, err)
+
+	info := &types.GadgetInfo{
+		ProgContent:    progContent,
+		GadgetMetadata: &types.GadgetMetadata{},
+	}
+	require.NoError(t, info.GadgetMetadata.Populate(spec))
+
+	g := &GadgetDesc{}
+
+	cols, err := g.getColumns(info)
+	require.NoError(t, err)
+	_, ok := cols.GetColumn("count")
+	require.False(t, ok, "count column must only be added in aggregation mode")
+
+	info.Aggregated = true
+	cols, err = g.getColumns(info)
+	require.NoError(t, err)
+	col, ok := cols.GetColumn("count")
+	require.True(t, ok)
+	require.Equal(t, uint64(42), col.Get(&types.Event{Count: 42}).Interface())
+}
+
+func TestCustomParserDefaultFilters(t *testing.T) {
+	progContent, err := os.ReadFile("../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o")
+	require.NoError(t, err)
+
+	spec, err := loadSpec(progContent)
+	require.NoError(t, err)
+
+	info := &types.GadgetInfo{
+		ProgContent:    progContent,
+		GadgetMetadata: &types.GadgetMetadata{},
+		DefaultFilters: []string{"k8s.namespace:!kube-system"},
+	}
+	require.NoError(t, info.GadgetMetadata.Populate(spec))
+
+	p, err := (&GadgetDesc{}).CustomParser(info)
+	require.NoError(t, err)
+
+	var namespaces []string
+	p.SetEventCallback(func(ev *types.Event) {
+		namespaces = append(namespaces, ev.K8s.Namespace)
+	})
+	handler := p.EventHandlerFunc().(func(*types.Event))
+
+	for _, namespace := range []string{"default", "kube-system"} {
+		ev := &types.Event{}
+		ev.K8s.Namespace = namespace
+		handler(ev)
+	}
+	require.Equal(t, []string{"default"}, namespaces)
+}
+
+func TestGetColumnsMultipleTracers(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	s32 := &btf.Int{Name: "__s32", Size: 4, Encoding: btf.Signed}
+	eventTypes := map[string]*btf.Struct{
+		"connect": {Name: "connect_event", Size: 8, Members: []btf.Member{
+			{Name: "pid", Type: u32, Offset: 0},
+			{Name: "fd", Type: s32, Offset: 32},
+		}},
+		"close": {Name: "close_event", Size: 12, Members: []btf.Member{
+			{Name: "ret", Type: s32, Offset: 0},
+			{Name: "pid", Type: u32, Offset: 64},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{
+			"connect": {MapName: "connects", StructName: "connect_event"},
+			"close":   {MapName: "closes", StructName: "close_event"},
+		},
+		Structs: map[string]types.Struct{
+			"connect_event": {Fields: []types.Field{{Name: "pid"}, {Name: "fd"}}},
+			"close_event":   {Fields: []types.Field{{Name: "ret"}, {Name: "pid"}}},
+		},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	connect := &types.Event{Tracer: "connect", RawData: make([]byte, 8)}
+	binary.LittleEndian.PutUint32(connect.RawData[0:], 1234)
+	binary.LittleEndian.PutUint32(connect.RawData[4:], 3)
+	closeEv := &types.Event{Tracer: "close", RawData: make([]byte, 12)}
+	binary.LittleEndian.PutUint32(closeEv.RawData[8:], 5678)
+
+	get := func(name string, ev *types.Event) string {
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		return columns.GetFieldAsString[types.Event](col)(ev)
+	}
+
+	// The fields with the same name share a column
+	require.Equal(t, "connect", get("tracer", connect))
+	require.Equal(t, "1234", get("pid", connect))
+	require.Equal(t, "3", get("fd", connect))
+	require.Equal(t, "0", get("ret", connect))
+
+	require.Equal(t, "close", get("tracer", closeEv))
+	require.Equal(t, "5678", get("pid", closeEv))
+	require.Equal(t, "0", get("fd", closeEv))
+
+	// The fields with the same name must have the same type
+	eventTypes["close"].Members[1].Type = s32
+	_, err = newColumns(metadata, eventTypes, false)
+	require.ErrorContains(t, err, `field "pid" has different types`)
+}
+
+func TestGetColumnsVariants(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	kind := &btf.Enum{Name: "kind", Size: 4, Values: []btf.EnumValue{{Name: "EXEC", Value: 1}, {Name: "EXIT", Value: 2}}}
+	header := &btf.Struct{Name: "header", Size: 8, Members: []btf.Member{
+		{Name: "kind", Type: kind, Offset: 0},
+		{Name: "pid", Type: u32, Offset: 32},
+	}}
+	exec := &btf.Struct{Name: "exec_event", Size: 12, Members: []btf.Member{
+		{Name: "kind", Type: kind, Offset: 0},
+		{Name: "pid", Type: u32, Offset: 32},
+		{Name: "ppid", Type: u32, Offset: 64},
+	}}
+	exit := &btf.Struct{Name: "exit_event", Size: 16, Members: []btf.Member{
+		{Name: "kind", Type: kind, Offset: 0},
+		{Name: "pid", Type: u32, Offset: 32},
+		{Name: "code", Type: u32, Offset: 96},
+	}}
+	eventTypes := map[string]*btf.Struct{
+		"events":            header,
+		"events/exec_event": exec,
+		"events/exit_event": exit,
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{
+			"events": {
+				MapName:       "events",
+				StructName:    "header",
+				Discriminator: "kind",
+				Variants:      map[string]string{"EXEC": "exec_event", "2": "exit_event"},
+			},
+		},
+		Structs: map[string]types.Struct{
+			"header":     {Fields: []types.Field{{Name: "kind"}, {Name: "pid"}}},
+			"exec_event": {Fields: []types.Field{{Name: "kind"}, {Name: "pid"}, {Name: "ppid"}}},
+			"exit_event": {Fields: []types.Field{{Name: "kind"}, {Name: "pid"}, {Name: "code"}}},
+		},
+	}
+
+	variants, variantTypes, err := tracerVariants(metadata, "events", eventTypes)
+	require.NoError(t, err)
+	require.Equal(t, exit, variantTypes["exit_event"])
+
+	newEvent := func(size int, kind, pid, last uint32) *types.Event {
+		ev := &types.Event{Tracer: "events", RawData: make([]byte, size)}
+		binary.LittleEndian.PutUint32(ev.RawData[0:], kind)
+		binary.LittleEndian.PutUint32(ev.RawData[4:], pid)
+		binary.LittleEndian.PutUint32(ev.RawData[size-4:], last)
+		ev.Variant = variants.structName(ev.RawData)
+		return ev
+	}
+	execEv := newEvent(12, 1, 1234, 1)
+	exitEv := newEvent(16, 2, 1234, 137)
+	otherEv := newEvent(8, 3, 5678, 5678)
+	require.Equal(t, "exec_event", execEv.Variant)
+	require.Equal(t, "exit_event", exitEv.Variant)
+	require.Equal(t, "", otherEv.Variant)
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	get := func(name string, ev *types.Event) string {
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		return columns.GetFieldAsString[types.Event](col)(ev)
+	}
+
+	// The variants of a single tracer don't need the tracer column
+	_, ok := cols.GetColumn("tracer")
+	require.False(t, ok)
+
+	// The fields of the variants share the columns of the same name
+	require.Equal(t, "exec_event", get("variant", execEv))
+	require.Equal(t, "EXEC", get("kind", execEv))
+	require.Equal(t, "1234", get("pid", execEv))
+	require.Equal(t, "1", get("ppid", execEv))
+	require.Equal(t, "0", get("code", execEv))
+
+	require.Equal(t, "EXIT", get("kind", exitEv))
+	require.Equal(t, "137", get("code", exitEv))
+	require.Equal(t, "0", get("ppid", exitEv))
+
+	require.Equal(t, "", get("variant", otherEv))
+	require.Equal(t, "5678", get("pid", otherEv))
+	require.Equal(t, "0", get("ppid", otherEv))
+}
+
+func TestTopEntries(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	key := &btf.Struct{Name: "file_id", Size: 4, Members: []btf.Member{
+		{Name: "pid", Type: u32, Offset: 0},
+	}}
+	value := &btf.Struct{Name: "file_stats", Size: 16, Members: []btf.Member{
+		{Name: "reads", Type: u64, Offset: 0},
+		{Name: "read_bytes", Type: u64, Offset: 64},
+	}}
+	topper := types.Topper{MapName: "stats", KeyStructName: "file_id", ValueStructName: "file_stats"}
+	metadata := &types.GadgetMetadata{
+		Toppers: map[string]types.Topper{"files": topper},
+		Structs: map[string]types.Struct{
+			"file_id":    {Fields: []types.Field{{Name: "pid"}}},
+			"file_stats": {Fields: []types.Field{{Name: "reads"}, {Name: "read_bytes"}}},
+		},
+	}
+
+	// The value is aligned after the key
+	eventType := topperEventType(key, value)
+	require.Equal(t, uint32(24), eventType.Size)
+	require.Equal(t, uint32(8), topperValueOffset(key))
+
+	cols, err := newColumns(metadata, map[string]*btf.Struct{"files": eventType}, false)
+	require.NoError(t, err)
+
+	entry := func(pid uint32, reads, readBytes uint64) *types.Event {
+		ev := &types.Event{Tracer: "files", RawData: make([]byte, eventType.Size)}
+		binary.LittleEndian.PutUint32(ev.RawData[0:], pid)
+		binary.LittleEndian.PutUint64(ev.RawData[8:], reads)
+		binary.LittleEndian.PutUint64(ev.RawData[16:], readBytes)
+		return ev
+	}
+	pids := func(entries []*types.Event) []string {
+		col, ok := cols.GetColumn("pid")
+		require.True(t, ok)
+		ret := []string{}
+		for _, ev := range entries {
+			ret = append(ret, columns.GetFieldAsString[types.Event](col)(ev))
+		}
+		return ret
+	}
+	entries := func() []*types.Event {
+		return []*types.Event{entry(1, 10, 100), entry(2, 30, 50), entry(3, 20, 300)}
+	}
+
+	// By default, the fields of the value in descending order
+	sortBy := topperSortBy(metadata, topper)
+	require.Equal(t, []string{"-reads", "-read_bytes"}, sortBy)
+	require.Equal(t, []string{"2", "3"}, pids(topEntries(cols.GetColumnMap(), entries(), sortBy, 2)))
+
+	require.Equal(t, []string{"3", "1", "2"}, pids(topEntries(cols.GetColumnMap(), entries(), []string{"-read_bytes"}, 0)))
+
+	topper.SortBy = []string{"pid"}
+	require.Equal(t, []string{"1", "2", "3"}, pids(topEntries(cols.GetColumnMap(), entries(), topperSortBy(metadata, topper), 0)))
+}
+
+func TestTopperInterval(t *testing.T) {
+	interval, err := topperInterval(0, types.Topper{})
+	require.NoError(t, err)
+	require.Equal(t, defaultTopInterval, interval)
+
+	interval, err = topperInterval(0, types.Topper{Interval: "5s"})
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, interval)
+
+	// The interval set by the user wins
+	interval, err = topperInterval(2*time.Second, types.Topper{Interval: "5s"})
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, interval)
+
+	_, err = topperInterval(0, types.Topper{Interval: "foo"})
+	require.Error(t, err)
+}
+
+func TestHistogramEvents(t *testing.T) {
+	// The counters of all the entries are summed
+	slots := make([]uint64, 3)
+	value := make([]byte, 12)
+	binary.LittleEndian.PutUint32(value[0:], 1)
+	binary.LittleEndian.PutUint32(value[8:], 5)
+	addHistogramSlots(slots, value, 4)
+	addHistogramSlots(slots, value, 4)
+	require.Equal(t, []uint64{2, 0, 10}, slots)
+
+	ev := &types.Event{
+		Tracer: "latency",
+		Histogram: &histogram.Histogram{
+			Unit:      histogram.UnitMicroseconds,
+			Intervals: histogram.NewIntervalsFromExp2Slots(slots),
+		},
+	}
+	require.Equal(t, strings.Split(strings.TrimSuffix(ev.Histogram.String(), "\n"), "\n"), ev.ExtraLines())
+	require.Nil(t, (&types.Event{}).ExtraLines())
+
+	eventJson, err := histogramJSON(ev, "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"tracer":"latency","histogram":{"unit":"µs","intervals":[`+
+		`{"count":2,"start":0,"end":1},{"count":0,"start":2,"end":3},{"count":10,"start":4,"end":7}]}}`, eventJson)
+
+	// The histograms are told apart from the other events
+	metadata := &types.GadgetMetadata{
+		Histograms: map[string]types.Histogram{"latency": {MapName: "latencies", Unit: "µs"}},
+		Structs:    map[string]types.Struct{"event": {}},
+	}
+	eventTypes := map[string]*btf.Struct{"events": {Name: "event"}}
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+	_, ok := cols.GetColumn("tracer")
+	require.True(t, ok)
+}
+
+func TestParamConstants(t *testing.T) {
+	metadata := &types.GadgetMetadata{
+		Params: map[string]types.Param{
+			"targ-pid": {VarName: "targ_pid", Type: params.TypeUint32, DefaultValue: "0"},
+			"targ-tid": {VarName: "targ_tid", Type: params.TypeUint32, DefaultValue: "0"},
+		},
+	}
+	gadgetParams := metadata.ParamDescs().ToParams()
+	require.NoError(t, gadgetParams.Set("targ-pid", "42"))
+
+	require.Equal(t, map[string]any{"targ_pid": uint32(42), "targ_tid": uint32(0)}, paramConstants(metadata, gadgetParams))
+
+	// The constants of the params not given keep the value of the eBPF program
+	require.Empty(t, paramConstants(metadata, &params.Params{}))
+}
+
+func TestGetColumnsFieldKinds(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	s32 := &btf.Int{Name: "__s32", Size: 4, Encoding: btf.Signed}
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 48, Members: []btf.Member{
+			{Name: "ret", Type: &btf.Typedef{Name: "gadget_errno_t", Type: s32}, Offset: 0},
+			{Name: "saddr", Type: u32, Offset: 32},
+			{Name: "bytes", Type: &btf.Typedef{Name: "gadget_bytes_t", Type: u64}, Offset: 64},
+			{Name: "latency", Type: &btf.Typedef{Name: "gadget_duration_t", Type: u64}, Offset: 128},
+			{Name: "ts", Type: &btf.Typedef{Name: "gadget_timestamp_t", Type: u64}, Offset: 192},
+			{Name: "err", Type: u32, Offset: 256},
+			{Name: "fd", Type: s32, Offset: 320},
+			{Name: "count", Type: s32, Offset: 352},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{
+			"event": {Fields: []types.Field{
+				{Name: "ret", Attributes: types.FieldAttributes{Kind: types.KindErrno}},
+				{Name: "saddr", Attributes: types.FieldAttributes{Kind: types.KindL3Endpoint}},
+				{Name: "bytes", Attributes: types.FieldAttributes{Kind: types.KindBytes}},
+				{Name: "latency", Attributes: types.FieldAttributes{Kind: types.KindDuration}},
+				{Name: "ts", Attributes: types.FieldAttributes{Kind: types.KindTimestampNs}},
+				{Name: "err", Attributes: types.FieldAttributes{Kind: types.KindErrno}},
+				{Name: "fd", Attributes: types.FieldAttributes{Kind: types.KindReturnValue}},
+				{Name: "count", Attributes: types.FieldAttributes{Kind: types.KindReturnValue}},
+			}},
+		},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 48)}
+	binary.LittleEndian.PutUint32(ev.RawData[0:], uint32(0xffffffff-1)) // -ENOENT
+	copy(ev.RawData[4:], []byte{10, 0, 0, 1})
+	binary.LittleEndian.PutUint64(ev.RawData[8:], 1536)
+	binary.LittleEndian.PutUint64(ev.RawData[16:], 1500000)
+	binary.LittleEndian.PutUint32(ev.RawData[32:], 1000)
+	binary.LittleEndian.PutUint32(ev.RawData[40:], uint32(0xffffffff-12)) // -EACCES
+	binary.LittleEndian.PutUint32(ev.RawData[44:], 13)
+
+	get := func(name string) string {
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		// Like the formatters do
+		if humanize := columns.GetFieldAsHumanizedStringFunc[types.Event](col); humanize != nil {
+			return humanize(ev)
+		}
+		return columns.GetFieldAsString[types.Event](col)(ev)
+	}
+
+	require.Equal(t, "-ENOENT", get("ret"))
+	require.Equal(t, "10.0.0.1", get("saddr"))
+	require.Equal(t, "1.5KiB", get("bytes"))
+	require.Equal(t, "1.5ms", get("latency"))
+	require.Equal(t, "", get("ts"))
+	require.Equal(t, "1000", get("err"))
+	require.Equal(t, "-EACCES", get("fd"))
+	require.Equal(t, "13", get("count"))
+
+	// The raw error numbers are in hidden columns
+	for name, value := range map[string]string{"ret_raw": "-2", "err_raw": "1000", "fd_raw": "-13", "count_raw": "13"} {
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		require.False(t, col.Visible)
+		require.Equal(t, value, get(name))
+	}
+
+	// The kinds are only supported for the values they make sense for
+	metadata.Structs["event"].Fields[1].Attributes.Kind = types.KindL4Endpoint
+	_, err = newColumns(metadata, eventTypes, false)
+	require.ErrorContains(t, err, `kind "l4endpoint" isn't supported for integers`)
+}
+
+func TestGetColumnsEnum(t *testing.T) {
+	state := &btf.Enum{Size: 4, Values: []btf.EnumValue{
+		{Name: "TCP_ESTABLISHED", Value: 1},
+		{Name: "TCP_SYN_SENT", Value: 2},
+	}}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 4, Members: []btf.Member{
+			{Name: "state", Type: state, Offset: 0},
+		}},
+	}
+	fields := []types.Field{{Name: "state"}}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{"event": {Fields: fields}},
+	}
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 4)}
+	binary.LittleEndian.PutUint32(ev.RawData, 2)
+	get := func() string {
+		cols, err := newColumns(metadata, eventTypes, false)
+		require.NoError(t, err)
+		col, ok := cols.GetColumn("state")
+		require.True(t, ok)
+		return columns.GetFieldAsString[types.Event](col)(ev)
+	}
+
+	require.Equal(t, "TCP_SYN_SENT", get())
+
+	// The raw value is kept when asked to
+	fields[0].Attributes.RawValue = true
+	require.Equal(t, "2", get())
+}
+
+func TestGetColumnsSignedEnum(t *testing.T) {
+	minusOne := int64(-1)
+	result := &btf.Enum{Size: 2, Signed: true, Values: []btf.EnumValue{
+		{Name: "RESULT_OK", Value: 0},
+		{Name: "RESULT_ERROR", Value: uint64(minusOne)},
+	}}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 2, Members: []btf.Member{
+			{Name: "result", Type: result, Offset: 0},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{"event": {Fields: []types.Field{{Name: "result"}}}},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+	col, ok := cols.GetColumn("result")
+	require.True(t, ok)
+	get := columns.GetFieldAsString[types.Event](col)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 2)}
+	binary.LittleEndian.PutUint16(ev.RawData, uint16(0xffff))
+	require.Equal(t, "RESULT_ERROR", get(ev))
+
+	binary.LittleEndian.PutUint16(ev.RawData, uint16(0xfffe))
+	require.Equal(t, "UNKNOWN (-2)", get(ev))
+}
+
+func TestEnumValueNames(t *testing.T) {
+	minusOne := int64(-1)
+	enum := &btf.Enum{Name: "result", Size: 2, Signed: true}
+
+	// An enum with the same name but a different signedness, like one in the kernel's BTF
+	// information, must not change how the values of the gadget's enum are looked up
+	unsigned := &btf.Enum{Name: "result", Size: 4, Values: []btf.EnumValue{
+		{Name: "RESULT_OK", Value: 0},
+		{Name: "RESULT_ERROR", Value: 0xffffffff},
+	}}
+	names := enumValueNames(enum, unsigned)
+	require.Equal(t, "RESULT_OK", names[0])
+	require.Equal(t, "RESULT_ERROR", names[uint64(minusOne)])
+
+	signed := &btf.Enum{Name: "result", Size: 2, Signed: true, Values: []btf.EnumValue{
+		{Name: "RESULT_ERROR", Value: uint64(minusOne)},
+	}}
+	names = enumValueNames(&btf.Enum{Name: "result", Size: 2}, signed)
+	require.Equal(t, "RESULT_ERROR", names[0xffff])
+}
+
+func TestGetColumnsFlags(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	sockFlags := &btf.Enum{Size: 4, Values: []btf.EnumValue{
+		{Name: "SOCK_NONE", Value: 0},
+		{Name: "SOCK_DEAD", Value: 1},
+		{Name: "SOCK_DONE", Value: 2},
+	}}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 8, Members: []btf.Member{
+			{Name: "flags", Type: u32, Offset: 0},
+			{Name: "sk_flags", Type: sockFlags, Offset: 32},
+		}},
+	}
+	openFlags := []types.Flag{
+		{Name: "O_RDONLY", Value: 0, Mask: 0x3},
+		{Name: "O_WRONLY", Value: 1, Mask: 0x3},
+		{Name: "O_RDWR", Value: 2, Mask: 0x3},
+		{Name: "O_CLOEXEC", Value: 0x80000},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{"event": {Fields: []types.Field{
+			{Name: "flags", Flags: openFlags},
+			{Name: "sk_flags", FlagsEnum: true},
+		}}},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 8)}
+	get := func(name string, value uint32) string {
+		binary.LittleEndian.PutUint32(ev.RawData[0:], value)
+		binary.LittleEndian.PutUint32(ev.RawData[4:], value)
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok)
+		return columns.GetFieldAsString[types.Event](col)(ev)
+	}
+
+	require.Equal(t, "O_RDONLY|O_CLOEXEC", get("flags", 0x80000))
+	require.Equal(t, "O_RDWR", get("flags", 2))
+	// The bits without name are shown too
+	require.Equal(t, "O_WRONLY|0x40", get("flags", 0x41))
+
+	require.Equal(t, "SOCK_NONE", get("sk_flags", 0))
+	require.Equal(t, "SOCK_DEAD|SOCK_DONE", get("sk_flags", 3))
+}
+
+func TestGetColumnsNestedStructs(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	task := &btf.Struct{Name: "task_info", Size: 8, Members: []btf.Member{
+		{Name: "pid", Type: u32, Offset: 0},
+		{Name: "tid", Type: u32, Offset: 32},
+	}}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 12, Members: []btf.Member{
+			{Name: "uid", Type: u32, Offset: 0},
+			{Name: "task", Type: task, Offset: 32},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{
+			"event": {Fields: []types.Field{{Name: "uid"}, {Name: "task.pid"}, {Name: "task.tid"}}},
+		},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 12)}
+	binary.LittleEndian.PutUint32(ev.RawData[0:], 1000)
+	binary.LittleEndian.PutUint32(ev.RawData[4:], 42)
+	binary.LittleEndian.PutUint32(ev.RawData[8:], 43)
+
+	for name, expected := range map[string]string{"uid": "1000", "task.pid": "42", "task.tid": "43"} {
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		require.Equal(t, expected, columns.GetFieldAsString[types.Event](col)(ev))
+	}
+}
+
+func TestGetColumnsCharArrays(t *testing.T) {
+	char := &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 48, Members: []btf.Member{
+			{Name: "comm", Type: &btf.Array{Type: char, Nelems: 16}, Offset: 0},
+			{Name: "path", Type: &btf.Typedef{Name: "path_t", Type: &btf.Array{Type: &btf.Const{Type: char}, Nelems: 32}}, Offset: 128},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{"event": {Fields: []types.Field{{Name: "comm"}, {Name: "path"}}}},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 48)}
+	copy(ev.RawData[0:], "cat\x00garbage")
+	copy(ev.RawData[16:], "/etc/passwd")
+
+	for name, expected := range map[string]string{"comm": "cat", "path": "/etc/passwd"} {
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		require.Equal(t, expected, columns.GetFieldAsString[types.Event](col)(ev))
+	}
+}
+
+func TestGetColumnsL4Endpoint(t *testing.T) {
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 24, Members: []btf.Member{
+			{Name: "dst", Type: &btf.Struct{Name: "gadget_l4endpoint_t", Size: 24}, Offset: 0},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{"event": {Fields: []types.Field{{Name: "dst"}}}},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	// Without attributes, the endpoint is shown like by the built-in gadgets
+	col, ok := cols.GetColumn("dst")
+	require.True(t, ok)
+	require.Equal(t, 40, col.Width)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 24)}
+	ev.L4Endpoints = append(ev.L4Endpoints, types.L4Endpoint{Name: "dst"})
+	ev.L4Endpoints[0].Addr = "10.0.0.1"
+	ev.L4Endpoints[0].Version = 4
+	ev.L4Endpoints[0].Port = 80
+
+	// Enrich the endpoint like the KubeIPResolver operator does
+	endpoints := ev.GetEndpoints()
+	require.Len(t, endpoints, 1)
+	endpoints[0].Kind = "svc"
+	endpoints[0].Namespace = "default"
+	endpoints[0].Name = "nginx"
+
+	for name, expected := range map[string]string{
+		"dst":           "s/default/nginx:80",
+		"dst.namespace": "default",
+		"dst.name":      "nginx",
+		"dst.kind":      "svc",
+		"dst.port":      "80",
+	} {
+		col, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		require.Equal(t, expected, columns.GetFieldAsString[types.Event](col)(ev))
+	}
+}
+
+func TestGetColumnsUnits(t *testing.T) {
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 16, Members: []btf.Member{
+			{Name: "packets", Type: u64, Offset: 0},
+			{Name: "latency", Type: u64, Offset: 64},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{
+			"event": {Fields: []types.Field{
+				{Name: "packets", Attributes: types.FieldAttributes{Unit: types.UnitPackets}},
+				{Name: "latency", Attributes: types.FieldAttributes{Unit: types.UnitMicroseconds}},
+			}},
+		},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 16)}
+	binary.LittleEndian.PutUint64(ev.RawData[0:], 1200)
+	binary.LittleEndian.PutUint64(ev.RawData[8:], 2500)
+
+	formatter := columns_json.NewFormatter(cols.ColumnMap)
+	require.Contains(t, formatter.FormatEntry(ev), `"packets": "1.2k", "latency": "2.5ms"`)
+	formatter = columns_json.NewFormatter(cols.ColumnMap, columns_json.WithRawValues(true))
+	require.Contains(t, formatter.FormatEntry(ev), `"packets": 1200, "latency": 2500`)
+}
+
+func TestGetColumnsStacks(t *testing.T) {
+	s32 := &btf.Int{Name: "__s32", Size: 4, Encoding: btf.Signed}
+	eventTypes := map[string]*btf.Struct{
+		"events": {Name: "event", Size: 4, Members: []btf.Member{
+			{Name: "kstack", Type: s32, Offset: 0},
+		}},
+	}
+	metadata := &types.GadgetMetadata{
+		Tracers: map[string]types.Tracer{"events": {MapName: "events", StructName: "event"}},
+		Structs: map[string]types.Struct{
+			"event": {Fields: []types.Field{
+				{Name: "kstack", Attributes: types.FieldAttributes{Kind: types.KindKernelStack}},
+			}},
+		},
+	}
+
+	cols, err := newColumns(metadata, eventTypes, false)
+	require.NoError(t, err)
+
+	ev := &types.Event{Tracer: "events", RawData: make([]byte, 4), Stacks: []types.Stack{
+		{Name: "kstack", Frames: []string{"vfs_read", "ksys_read", "do_syscall_64"}},
+	}}
+	col, ok := cols.GetColumn("kstack")
+	require.True(t, ok)
+	require.Equal(t, "do_syscall_64;ksys_read;vfs_read", columns.GetFieldAsString[types.Event](col)(ev))
+	require.Equal(t, []string{"kstack:", "\tvfs_read", "\tksys_read", "\tdo_syscall_64"}, ev.ExtraLines())
+}
+
+func TestTracerBufferPages(t *testing.T) {
+	pages, err := tracerBufferPages(types.Tracer{BufferPages: 64}, 4096)
+	require.NoError(t, err)
+	require.Equal(t, uint32(64), pages)
+
+	pages, err = tracerBufferPages(types.Tracer{}, 4096)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), pages)
+
+	pages, err = tracerBufferPages(types.Tracer{BufferSize: "1MiB"}, 4096)
+	require.NoError(t, err)
+	require.Equal(t, uint32(256), pages)
+
+	// Rounded up to a power of 2 pages
+	pages, err = tracerBufferPages(types.Tracer{BufferSize: "100KiB"}, 4096)
+	require.NoError(t, err)
+	require.Equal(t, uint32(32), pages)
+	pages, err = tracerBufferPages(types.Tracer{BufferSize: "1"}, 16384)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), pages)
+
+	_, err = tracerBufferPages(types.Tracer{BufferSize: "lots"}, 4096)
+	require.Error(t, err)
+}
+
+func TestCheckBufferPages(t *testing.T) {
+	require.NoError(t, checkBufferPages(0, 4096))
+	require.NoError(t, checkBufferPages(1<<19, 4096))
+	require.ErrorContains(t, checkBufferPages(1<<20, 4096), "is bigger than")
+	require.ErrorContains(t, checkBufferPages(1<<31, 65536), "is bigger than")
+}
+
+func TestBufferPagesValidator(t *testing.T) {
+	for _, desc := range (&GadgetDesc{}).ParamDescs() {
+		if desc.Key != ParamBufferPages {
+			continue
+		}
+		require.NoError(t, desc.Validator("0"))
+		require.NoError(t, desc.Validator("256"))
+		require.ErrorContains(t, desc.Validator("100"), "power of 2")
+		require.ErrorContains(t, desc.Validator("lots"), "invalid syntax")
+		require.ErrorContains(t, desc.Validator("4294967296"), "out of range")
+		return
+	}
+	t.Fatalf("parameter %q not found", ParamBufferPages)
+}
+
+func TestResizeMaps(t *testing.T) {
+	newSpec := func() *ebpf.CollectionSpec {
+		return &ebpf.CollectionSpec{
+			Maps: map[string]*ebpf.MapSpec{
+				"counts": {Name: "counts", Type: ebpf.Hash, MaxEntries: 1024},
+				"pids":   {Name: "pids", Type: ebpf.Hash, MaxEntries: 1024},
+				"other":  {Name: "other", Type: ebpf.Hash, MaxEntries: 1024},
+			},
+		}
+	}
+	metadata := &types.GadgetMetadata{
+		Maps: map[string]types.Map{
+			"counts": {Scalable: true},
+			"pids":   {MaxEntries: 100, Scalable: true},
+		},
+	}
+
+	spec := newSpec()
+	require.NoError(t, resizeMaps(spec, metadata, 1))
+	require.Equal(t, uint32(1024), spec.Maps["counts"].MaxEntries)
+	require.Equal(t, uint32(100), spec.Maps["pids"].MaxEntries)
+	require.Equal(t, uint32(1024), spec.Maps["other"].MaxEntries)
+
+	// Rounded up
+	spec = newSpec()
+	require.NoError(t, resizeMaps(spec, metadata, 2.5))
+	require.Equal(t, uint32(2560), spec.Maps["counts"].MaxEntries)
+	require.Equal(t, uint32(250), spec.Maps["pids"].MaxEntries)
+	require.Equal(t, uint32(1024), spec.Maps["other"].MaxEntries)
+
+	spec = newSpec()
+	require.NoError(t, resizeMaps(spec, metadata, 0.001))
+	require.Equal(t, uint32(2), spec.Maps["counts"].MaxEntries)
+	require.Equal(t, uint32(1), spec.Maps["pids"].MaxEntries)
+
+	require.ErrorContains(t, resizeMaps(newSpec(), metadata, 1e7), `map "counts": 1024 entries scaled by 1e+07 don't fit in 32 bits`)
+
+	metadata.Maps["foo"] = types.Map{Scalable: true}
+	require.ErrorContains(t, resizeMaps(newSpec(), metadata, 1), `map "foo" not found`)
+}
+
+func TestPinnedMapsPath(t *testing.T) {
+	path, err := pinnedMapsPath("ghcr.io/inspektor-gadget/gadget/mygadget:latest", "")
+	require.NoError(t, err)
+	require.Empty(t, path)
+
+	path, err = pinnedMapsPath("ghcr.io/inspektor-gadget/gadget/mygadget:v1@sha256:"+strings.Repeat("a", 64), "node-a")
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/bpf/gadget/maps/ghcr.io_inspektor-gadget_gadget_mygadget/node-a", path)
+
+	// Updating the image keeps the state of the same key
+	updated, err := pinnedMapsPath("ghcr.io/inspektor-gadget/gadget/mygadget:v2", "node-a")
+	require.NoError(t, err)
+	require.Equal(t, path, updated)
+
+	other, err := pinnedMapsPath("ghcr.io/inspektor-gadget/gadget/mygadget:v1", "node-b")
+	require.NoError(t, err)
+	require.NotEqual(t, path, other)
+}
+
+func TestCheckRequirements(t *testing.T) {
+	supported := map[string]bool{"ringbuf": true, "co-re": true}
+	hasFeature := func(feature string) bool {
+		return supported[feature]
+	}
+	v := version.MustParseGeneric("5.10.0")
+
+	metadata := &types.GadgetMetadata{
+		MinKernelVersion: "5.8",
+		Requirements:     types.Requirements{Features: []string{"ringbuf", "co-re"}},
+	}
+	require.NoError(t, checkRequirements(metadata, v, hasFeature))
+
+	metadata.MinKernelVersion = "5.15"
+	require.EqualError(t, checkRequirements(metadata, v, hasFeature), "this gadget needs kernel >= 5.15, running 5.10.0")
+	// The version of the kernel isn't always known
+	require.NoError(t, checkRequirements(metadata, nil, hasFeature))
+
+	metadata.MinKernelVersion = ""
+	metadata.Requirements.Features = []string{"ringbuf", "bpf_loop"}
+	require.ErrorContains(t, checkRequirements(metadata, v, hasFeature), "this gadget needs kernel >= 5.17 (bpf_loop)")
+}
+
+func TestStackFrames(t *testing.T) {
+	symbolize := func(ip uint64) string {
+		return map[uint64]string{0x10: "vfs_read", 0x20: "ksys_read"}[ip]
+	}
+
+	require.Equal(t, []string{"vfs_read", "ksys_read"}, stackFrames(1, []uint64{0x10, 0x20, 0, 0}, symbolize))
+	require.Equal(t, []string{}, stackFrames(1, []uint64{0, 0}, symbolize))
+	// bpf_get_stackid() failed
+	require.Equal(t, []string{"[-EEXIST]"}, stackFrames(-17, []uint64{0x10}, symbolize))
+}
+
+type fakeKernel struct {
+	functions   map[string]bool
+	tracepoints map[string]bool
+}
+
+func (k *fakeKernel) hasFunction(name string) bool {
+	return k.functions[name]
+}
+
+func (k *fakeKernel) hasTracepoint(category, name string) bool {
+	return k.tracepoints[category+"/"+name]
+}
+
+func TestMetricSamples(t *testing.T) {
+	u16 := &btf.Int{Name: "__u16", Size: 2, Encoding: btf.Unsigned}
+	s32 := &btf.Int{Name: "__s32", Size: 4, Encoding: btf.Signed}
+	u64 := &btf.Int{Name: "__u64", Size: 8, Encoding: btf.Unsigned}
+	comm := btf.Member{Name: "comm", Type: &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Char}, Nelems: 4}, Offset: 0}
+	proto := btf.Member{Name: "proto", Type: &btf.Enum{Name: "proto", Size: 2, Values: []btf.EnumValue{{Name: "TCP", Value: 6}}}, Offset: 32}
+	port := btf.Member{Name: "port", Type: u16, Offset: 48}
+	ret := btf.Member{Name: "ret", Type: s32, Offset: 64}
+	value := btf.Member{Name: "count", Type: u64, Offset: 64}
+
+	newKey := func(comm string, proto, port uint16, ret int32) []byte {
+		key := make([]byte, 12)
+		copy(key, comm)
+		binary.LittleEndian.PutUint16(key[4:], proto)
+		binary.LittleEndian.PutUint16(key[6:], port)
+		binary.LittleEndian.PutUint32(key[8:], uint32(ret))
+		return key
+	}
+	newValue := func(count uint64) []byte {
+		value := make([]byte, 16)
+		binary.LittleEndian.PutUint64(value[8:], count)
+		return value
+	}
+
+	key := newKey("cat", 6, 80, -2)
+	require.Equal(t, "cat", metricLabelValue(comm, key))
+	require.Equal(t, "TCP", metricLabelValue(proto, key))
+	require.Equal(t, "80", metricLabelValue(port, key))
+	require.Equal(t, "-2", metricLabelValue(ret, key))
+	require.Equal(t, "17", metricLabelValue(proto, newKey("cat", 17, 80, 0)))
+	require.Equal(t, float64(42), metricValue(value, newValue(42)))
+	require.Equal(t, float64(42), metricValue(btf.Member{Type: u64}, newValue(42)[8:]))
+
+	// The entries with the same labels are summed
+	samples := map[string]*metricSample{}
+	entries := [][]byte{newKey("cat", 6, 80, 0), newKey("cat", 6, 443, 0), newKey("ls", 6, 80, 0)}
+	for i, key := range entries {
+		labels := []string{metricLabelValue(comm, key)}
+		addMetricSample(samples, labels, metricValue(value, newValue(uint64(i+1))))
+	}
+	require.Len(t, samples, 2)
+	require.Equal(t, float64(3), samples["cat"].value)
+	require.Equal(t, []string{"ls"}, samples["ls"].labels)
+	require.Equal(t, float64(3), samples["ls"].value)
+}
+
+func TestResolveAttachTargets(t *testing.T) {
+	spec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"ig_open":  {Type: ebpf.Kprobe, SectionName: "kprobe/do_sys_open", AttachTo: "do_sys_open"},
+			"ig_exit":  {Type: ebpf.TracePoint, SectionName: "tracepoint/sched/sched_process_exit", AttachTo: "sched/sched_process_exit"},
+			"ig_other": {Type: ebpf.SocketFilter, SectionName: "socket1"},
+		},
+	}
+	metadata := &types.GadgetMetadata{
+		Programs: map[string]types.Program{
+			"ig_open": {
+				Alternatives: []types.AttachTarget{
+					{Target: "do_sys_openat3", MaxKernelVersion: "5.5"},
+					{Target: "do_sys_openat2", MinKernelVersion: "5.6"},
+				},
+			},
+		},
+	}
+	kernel := &fakeKernel{
+		functions:   map[string]bool{"do_sys_open": true, "do_sys_openat2": true, "do_sys_openat3": true},
+		tracepoints: map[string]bool{"sched/sched_process_exit": true},
+	}
+
+	// The targets of the sections are used when the kernel has them
+	targets, err := resolveAttachTargets(spec, metadata, kernel, version.MustParseGeneric("5.15"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"ig_open": "do_sys_open",
+		"ig_exit": "sched/sched_process_exit",
+	}, targets)
+
+	// The first alternative for the kernel version otherwise
+	delete(kernel.functions, "do_sys_open")
+	targets, err = resolveAttachTargets(spec, metadata, kernel, version.MustParseGeneric("5.15"))
+	require.NoError(t, err)
+	require.Equal(t, "do_sys_openat2", targets["ig_open"])
+	targets, err = resolveAttachTargets(spec, metadata, kernel, version.MustParseGeneric("5.4"))
+	require.NoError(t, err)
+	require.Equal(t, "do_sys_openat3", targets["ig_open"])
+
+	// The missing targets are all reported
+	delete(kernel.functions, "do_sys_openat2")
+	delete(kernel.tracepoints, "sched/sched_process_exit")
+	_, err = resolveAttachTargets(spec, metadata, kernel, version.MustParseGeneric("5.15"))
+	require.ErrorContains(t, err, `function "do_sys_open" of program "ig_open" not found in the kernel, nor its alternatives do_sys_openat2`)
+	require.ErrorContains(t, err, `tracepoint "sched/sched_process_exit" of program "ig_exit" not found in the kernel`)
+}
+
+type stringPrinter struct {
+	lines []string
+}
+
+func (p *stringPrinter) Output(payload string) {
+	p.lines = append(p.lines, payload)
+}
+
+func (p *stringPrinter) Logf(logger.Level, string, ...any) {}
+
+type bytesPrinter struct {
+	stringPrinter
+}
+
+func (p *bytesPrinter) OutputBytes(payload []byte) {
+	p.lines = append(p.lines, strings.TrimSuffix(string(payload), "\n"))
+}
+
+func newConverterEvents() (*columns_json.Formatter[types.Event], []*types.Event) {
+	formatter := columns_json.NewFormatter(types.GetColumns().GetColumnMap())
+	events := []*types.Event{
+		{WithMountNsID: eventtypes.WithMountNsID{MountNsID: 4026531840}},
+		{WithMountNsID: eventtypes.WithMountNsID{MountNsID: 4026532287}},
+	}
+	return formatter, events
+}
+
+func TestJSONConverter(t *testing.T) {
+	formatter, events := newConverterEvents()
+
+	// The events are printed the same way whether the printer takes them as
+	// bytes or not
+	sp := &stringPrinter{}
+	bp := &bytesPrinter{}
+	for _, p := range []types.Printer{sp, bp} {
+		convert := jsonConverterFn(formatter, p, "")
+		for _, ev := range events {
+			convert(ev)
+		}
+	}
+	require.Len(t, sp.lines, len(events))
+	require.Equal(t, sp.lines, bp.lines)
+	for i, ev := range events {
+		require.Equal(t, formatter.FormatEntry(ev), bp.lines[i])
+	}
+}
+
+type discardPrinter struct{}
+
+func (discardPrinter) Output(string) {}
+
+func (discardPrinter) Logf(logger.Level, string, ...any) {}
+
+type discardBytesPrinter struct {
+	discardPrinter
+}
+
+func (discardBytesPrinter) OutputBytes([]byte) {}
+
+// BenchmarkJSONConverter compares the events formatted with FormatEntry, for
+// the printers taking strings, and with AppendEntry, for the ones taking bytes
+func BenchmarkJSONConverter(b *testing.B) {
+	formatter, events := newConverterEvents()
+
+	for _, bc := range []struct {
+		name    string
+		printer types.Printer
+	}{
+		{name: "FormatEntry", printer: discardPrinter{}},
+		{name: "AppendEntry", printer: discardBytesPrinter{}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			convert := jsonConverterFn(formatter, bc.printer, "")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				convert(events[n%len(events)])
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/cilium/ebpf"
+)
+
+// Number of collection specs kept in memory. The specs of the gadgets run less
+// recently are evicted first.
+const specCacheSize = 32
+
+type specCacheEntry struct {
+	once sync.Once
+	spec *ebpf.CollectionSpec
+	err  error
+}
+
+// specCache stores the collection specs of the eBPF programs already loaded,
+// indexed by the digest of their content. A single gadget run parses the
+// program several times (to get the gadget info, the columns, the event type
+// and to run it) and a daemon runs the same gadgets again and again.
+var specCache = struct {
+	sync.Mutex
+	entries map[[sha256.Size]byte]*specCacheEntry
+	// digests of the entries, from the least to the most recently used
+	lru [][sha256.Size]byte
+}{
+	entries: make(map[[sha256.Size]byte]*specCacheEntry),
+}
+
+// getSpecCacheEntry returns the entry for digest, creating it if needed, and
+// marks it as the most recently used one.
+func getSpecCacheEntry(digest [sha256.Size]byte) *specCacheEntry {
+	specCache.Lock()
+	defer specCache.Unlock()
+
+	for i, d := range specCache.lru {
+		if d == digest {
+			specCache.lru = append(specCache.lru[:i], specCache.lru[i+1:]...)
+			break
+		}
+	}
+	specCache.lru = append(specCache.lru, digest)
+
+	entry, ok := specCache.entries[digest]
+	if !ok {
+		entry = &specCacheEntry{}
+		specCache.entries[digest] = entry
+	}
+
+	for len(specCache.lru) > specCacheSize {
+		delete(specCache.entries, specCache.lru[0])
+		specCache.lru = specCache.lru[1:]
+	}
+
+	return entry
+}
+
+// removeSpecCacheEntry removes entry from the cache, if it's still there.
+func removeSpecCacheEntry(digest [sha256.Size]byte, entry *specCacheEntry) {
+	specCache.Lock()
+	defer specCache.Unlock()
+
+	if specCache.entries[digest] != entry {
+		return
+	}
+	delete(specCache.entries, digest)
+	for i, d := range specCache.lru {
+		if d == digest {
+			specCache.lru = append(specCache.lru[:i], specCache.lru[i+1:]...)
+			break
+		}
+	}
+}
+
+// loadSpec returns the collection spec of the eBPF program. The parsed specs
+// are cached, the caller gets a copy it's free to modify. The BTF types are
+// shared between the copies and must not be modified.
+func loadSpec(progContent []byte) (*ebpf.CollectionSpec, error) {
+	digest := sha256.Sum256(progContent)
+	entry := getSpecCacheEntry(digest)
+	entry.once.Do(func() {
+		entry.spec, entry.err = ebpf.LoadCollectionSpecFromReader(bytes.NewReader(progContent))
+	})
+	if entry.err != nil {
+		// Don't keep the failures, they are cheap to get again
+		removeSpecCacheEntry(digest, entry)
+		return nil, fmt.Errorf("loading spec: %w", entry.err)
+	}
+	return entry.spec.Copy(), nil
+}
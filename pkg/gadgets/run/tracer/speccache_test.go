@@ -0,0 +1,76 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"crypto/sha256"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSpec(t *testing.T) {
+	progContent, err := os.ReadFile("../../../../testdata/validate_metadata1.o")
+	require.NoError(t, err)
+
+	spec1, err := loadSpec(progContent)
+	require.NoError(t, err)
+	require.NotEmpty(t, spec1.Maps)
+
+	// Modifying a spec must not affect the ones returned later
+	for name, m := range spec1.Maps {
+		m.MaxEntries = 12345
+		delete(spec1.Maps, name)
+	}
+
+	spec2, err := loadSpec(progContent)
+	require.NoError(t, err)
+	require.NotEmpty(t, spec2.Maps)
+	for _, m := range spec2.Maps {
+		require.NotEqual(t, uint32(12345), m.MaxEntries)
+	}
+
+	// The types are parsed only once
+	require.Same(t, spec1.Types, spec2.Types)
+
+	_, err = loadSpec([]byte("not an ELF file"))
+	require.Error(t, err)
+	specCache.Lock()
+	_, ok := specCache.entries[sha256.Sum256([]byte("not an ELF file"))]
+	specCache.Unlock()
+	require.False(t, ok, "failures must not be cached")
+}
+
+func TestSpecCacheEviction(t *testing.T) {
+	var first [sha256.Size]byte
+	first[0] = 1
+	getSpecCacheEntry(first)
+
+	for i := 0; i < specCacheSize; i++ {
+		var digest [sha256.Size]byte
+		digest[0] = 2
+		digest[1] = byte(i)
+		getSpecCacheEntry(digest)
+	}
+
+	specCache.Lock()
+	defer specCache.Unlock()
+
+	require.Len(t, specCache.entries, specCacheSize)
+	require.Len(t, specCache.lru, specCacheSize)
+	_, ok := specCache.entries[first]
+	require.False(t, ok, "least recently used entry must be evicted")
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+// stackReader reads the frames of the stacks referenced by the events from the
+// gadget_stacks map. The kernel frames are shown by the name of their
+// function, the user ones by their address.
+type stackReader struct {
+	stacksMap *ebpf.Map
+	// nil if /proc/kallsyms can't be read
+	kallsyms *kallsyms.KAllSyms
+	release  func()
+}
+
+func newStackReader(logger logger.Logger, stacksMap *ebpf.Map) *stackReader {
+	r := &stackReader{
+		stacksMap: stacksMap,
+		release:   func() {},
+	}
+
+	symbols, release, err := kallsyms.NewSharedKAllSyms()
+	if err != nil {
+		logger.Warnf("reading kallsyms, the kernel stacks will be shown by address: %v", err)
+	} else {
+		r.kallsyms = symbols
+		r.release = release
+	}
+
+	return r
+}
+
+func (r *stackReader) symbolize(user bool) func(ip uint64) string {
+	if user || r.kallsyms == nil {
+		return func(ip uint64) string {
+			return fmt.Sprintf("%#x", ip)
+		}
+	}
+	return r.kallsyms.LookupByInstructionPointer
+}
+
+// frames returns the frames of the stack with the given ID, from the innermost
+// one
+func (r *stackReader) frames(id int32, user bool) []string {
+	ips := make([]uint64, r.stacksMap.ValueSize()/8)
+	if id >= 0 {
+		if err := r.stacksMap.Lookup(uint32(id), ips); err != nil {
+			return []string{"[not found]"}
+		}
+	}
+	return stackFrames(id, ips, r.symbolize(user))
+}
+
+func (r *stackReader) close() {
+	r.release()
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/networktracer"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+// Standalone is a gadget run outside of the rest of Inspektor Gadget, e.g. by
+// the tests of the gadget: its programs are attached to the whole host, without
+// enriching the events with the containers.
+type Standalone struct {
+	tracer *Tracer
+	stop   func()
+}
+
+// NewStandalone loads and attaches the gadget given by config.ProgContent and
+// config.Metadata, and calls handler with its events until Close is called.
+// The events are filtered by config.MountnsMap, a hash map of the mount
+// namespaces to trace, if it's set.
+func NewStandalone(config *Config, logger logger.Logger, handler func(*types.Event)) (*Standalone, error) {
+	networkTracer, err := networktracer.NewTracer[types.Event]()
+	if err != nil {
+		return nil, fmt.Errorf("creating network tracer: %w", err)
+	}
+
+	configCopy := *config
+	t := &Tracer{
+		config:        &configCopy,
+		eventCallback: handler,
+		networkTracer: networkTracer,
+		uprobeTracer:  newUprobeTracer(),
+	}
+
+	t.spec, err = loadSpec(config.ProgContent)
+	if err != nil {
+		networkTracer.Close()
+		return nil, err
+	}
+
+	stop, err := t.start(logger)
+	if err != nil {
+		networkTracer.Close()
+		return nil, err
+	}
+	return &Standalone{tracer: t, stop: stop}, nil
+}
+
+// Close sends the last events counted by the gadget and detaches it.
+func (s *Standalone) Close() {
+	s.stop()
+	s.tracer.Stop()
+	s.tracer.networkTracer.Close()
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+// topper sends the entries with the highest values of the stats map of a
+// topper of the gadget.
+type topper struct {
+	// Name of the topper in the metadata
+	name    string
+	mapName string
+	// Type of the events, made of the key followed by the value
+	eventType   *btf.Struct
+	valueOffset uint32
+	// Fields the entries are sorted by
+	sortBy []string
+	// How often the entries are sent
+	interval time.Duration
+
+	statsMap *ebpf.Map
+}
+
+// handleToppers prepares the toppers of the gadget, with the columns their
+// entries are sorted by.
+func (t *Tracer) handleToppers() error {
+	t.toppers = nil
+	if len(t.config.Metadata.Toppers) == 0 {
+		return nil
+	}
+
+	eventTypes, err := getEventTypesBTF(t.spec, t.config.Metadata)
+	if err != nil {
+		return err
+	}
+	cols, err := newColumns(t.config.Metadata, eventTypes, false)
+	if err != nil {
+		return fmt.Errorf("getting columns: %w", err)
+	}
+	t.topColumns = cols.GetColumnMap()
+
+	for _, name := range sortedKeys(t.config.Metadata.Toppers) {
+		metadataTopper := t.config.Metadata.Toppers[name]
+
+		// Checked by getEventTypesBTF()
+		key := t.spec.Maps[metadataTopper.MapName].Key.(*btf.Struct)
+
+		sortBy := t.config.SortBy
+		if len(sortBy) == 0 {
+			sortBy = topperSortBy(t.config.Metadata, metadataTopper)
+		}
+
+		interval, err := topperInterval(t.config.TopInterval, metadataTopper)
+		if err != nil {
+			return fmt.Errorf("topper %q: %w", name, err)
+		}
+
+		t.toppers = append(t.toppers, &topper{
+			name:        name,
+			mapName:     metadataTopper.MapName,
+			eventType:   eventTypes[name],
+			valueOffset: topperValueOffset(key),
+			sortBy:      sortBy,
+			interval:    interval,
+		})
+	}
+
+	return nil
+}
+
+// flushTopper sends the entries of the stats map of tp with the highest
+// values and removes all the entries from the map, for the next interval to
+// only account for what happens during it.
+func (t *Tracer) flushTopper(tp *topper, cb func(data []byte) *types.Event) error {
+	entries := []*types.Event{}
+	err := drainMap(tp.statsMap, func(key, value []byte) {
+		data := make([]byte, tp.eventType.Size)
+		copy(data, key)
+		copy(data[tp.valueOffset:], value)
+		entries = append(entries, cb(data))
+	})
+	if err != nil {
+		return fmt.Errorf("reading map %q: %w", tp.mapName, err)
+	}
+
+	for _, ev := range topEntries(t.topColumns, entries, tp.sortBy, t.config.TopMaxRows) {
+		t.sendEvent(ev)
+	}
+	return nil
+}
+
+// runToppers sends the top entries of each topper on its interval until done
+// is closed. The last entries are sent before returning.
+func (t *Tracer) runToppers(logger logger.Logger, done <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, tp := range t.toppers {
+		wg.Add(1)
+		go func(tp *topper) {
+			defer wg.Done()
+			t.runTopper(logger, tp, done)
+		}(tp)
+	}
+	wg.Wait()
+}
+
+func (t *Tracer) runTopper(logger logger.Logger, tp *topper, done <-chan struct{}) {
+	ticker := time.NewTicker(tp.interval)
+	defer ticker.Stop()
+
+	cb := t.processEventFunc(logger, tp.name, tp.eventType)
+
+	for {
+		select {
+		case <-done:
+			if err := t.flushTopper(tp, cb); err != nil {
+				logger.Warnf("flushing topper %q: %v", tp.name, err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.flushTopper(tp, cb); err != nil {
+				logger.Warnf("flushing topper %q: %v", tp.name, err)
+				return
+			}
+		}
+	}
+}
@@ -17,11 +17,14 @@
 package tracer
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/cilium/ebpf"
@@ -29,14 +32,21 @@ import (
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/perf"
 	"github.com/cilium/ebpf/ringbuf"
+	"github.com/distribution/reference"
+	"github.com/docker/go-units"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/networktracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/socketenricher"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kernelbtf"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+	bpfiterns "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/bpf-iter-ns"
 )
 
 // keep aligned with pkg/gadgets/common/types.h
@@ -56,27 +66,120 @@ type Config struct {
 	ProgContent []byte
 	Metadata    *types.GadgetMetadata
 	MountnsMap  *ebpf.Map
+	// BufferPages overrides the size of the buffer of the tracer given by the
+	// metadata when it isn't 0
+	BufferPages uint32
+	// MapEntriesScale multiplies the maximum number of entries of the maps
+	// declared as scalable by the metadata, when it isn't 0
+	MapEntriesScale float64
+	// PinPath is where the maps declared with the pinning attribute are
+	// pinned. They aren't pinned when it's empty
+	PinPath string
+	// AggregateInterval is how often the events counted in the kernel are
+	// sent when it isn't 0. Every event is sent otherwise
+	AggregateInterval time.Duration
+	// TopInterval is how often the entries of the toppers are sent, the
+	// default of each topper if it's 0
+	TopInterval time.Duration
+	// TopMaxRows is the maximum number of entries sent by each topper on
+	// every interval, 0 for all of them
+	TopMaxRows int
+	// SortBy are the fields the entries of the toppers and snapshotters are
+	// sorted by, the default ones of each of them if it's empty
+	SortBy []string
+	// HistogramInterval is how often the distributions of the histograms are
+	// sent, and their counters cleared, when it isn't 0. They are only sent
+	// when the gadget stops otherwise
+	HistogramInterval time.Duration
+	// Constants are the values given to the constants of the eBPF program by
+	// the parameters of the gadget, by name
+	Constants map[string]any
+	// ImageName is the image of the gadget, the metrics exported from its
+	// counters maps are labelled with it
+	ImageName string
 }
 
+// lostSamplesInterval is how often the counter of events lost by the eBPF
+// program is read
+const lostSamplesInterval = time.Second
+
 type Tracer struct {
 	config        *Config
 	eventCallback func(*types.Event)
+	// Events are sent from the goroutines reading the buffer and the lost
+	// samples counter
+	eventCallbackMu sync.Mutex
 
 	spec       *ebpf.CollectionSpec
 	collection *ebpf.Collection
-	// Type describing the format the gadget uses
-	eventType *btf.Struct
 
 	socketEnricher *socketenricher.SocketEnricher
 	networkTracer  *networktracer.Tracer[types.Event]
-
-	// Tracers related
-	ringbufReader *ringbuf.Reader
-	perfReader    *perf.Reader
+	uprobeTracer   *uprobeTracer
+
+	// Tracers related, sorted by name
+	traceReaders []*traceReader
+	// Counter of the events the eBPF program couldn't send, if the gadget
+	// defines it
+	lostSamplesMap *ebpf.Map
+	// Map the events are counted in, in aggregation mode
+	aggregationMap *ebpf.Map
+	// Reader of the stacks referenced by the events, if the gadget has a
+	// stacks map
+	stackReader *stackReader
+	// Toppers related, sorted by name, with the columns their entries are
+	// sorted by
+	toppers    []*topper
+	topColumns columns.ColumnMap[types.Event]
+	// Histograms related, sorted by name
+	histogramReaders []*histogramReader
+	// Metrics related, sorted by name
+	metricReaders []*metricReader
 
 	links []link.Link
 }
 
+// traceReader reads the events of a tracer of the gadget from its map.
+type traceReader struct {
+	// Name of the tracer in the metadata
+	name    string
+	mapName string
+	// Type describing the format the tracer uses
+	eventType *btf.Struct
+	// When the tracer sends several structs, how they are told apart and
+	// their types by name
+	variants     *eventVariants
+	variantTypes map[string]*btf.Struct
+
+	ringbufReader *eventreader.RingbufReader
+	perfReader    *eventreader.PerfReader
+	// Number of pages of each per CPU buffer of the perf reader
+	perfBufferPages uint32
+	// Effective size of the buffer, e.g. "256KiB (64 pages) per CPU"
+	bufferSize string
+	// Number of events the perf buffers lost so far, reported when the tracer
+	// stops
+	lostSamples uint64
+}
+
+// describeBufferSize returns the effective size of the buffer of the tracer
+func (r *traceReader) describeBufferSize(m *ebpf.Map) string {
+	if r.perfReader != nil {
+		size := int64(r.perfBufferPages) * int64(os.Getpagesize())
+		return fmt.Sprintf("%s (%d pages) per CPU", units.BytesSize(float64(size)), r.perfBufferPages)
+	}
+	return fmt.Sprintf("%s (%d pages)", units.BytesSize(float64(m.MaxEntries())), m.MaxEntries()/uint32(os.Getpagesize()))
+}
+
+func (r *traceReader) close() {
+	if r.ringbufReader != nil {
+		r.ringbufReader.Close()
+	}
+	if r.perfReader != nil {
+		r.perfReader.Close()
+	}
+}
+
 func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
 	// FIXME: Ideally, we should have one networktracer.NewTracer per socket
 	//        filter program. But in NewInstance(), we don't have access to
@@ -96,6 +199,7 @@ func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
 	tracer := &Tracer{
 		config:        &Config{},
 		networkTracer: networkTracer,
+		uprobeTracer:  newUprobeTracer(),
 	}
 	return tracer, nil
 }
@@ -117,59 +221,112 @@ func (t *Tracer) Stop() {
 		gadgets.CloseLink(l)
 	}
 	t.links = nil
-
-	if t.ringbufReader != nil {
-		t.ringbufReader.Close()
+	t.uprobeTracer.Close()
+	if t.stackReader != nil {
+		t.stackReader.close()
+		t.stackReader = nil
 	}
-	if t.perfReader != nil {
-		t.perfReader.Close()
+
+	for _, r := range t.traceReaders {
+		r.close()
 	}
 	if t.socketEnricher != nil {
 		t.socketEnricher.Close()
 	}
 }
 
-func (t *Tracer) handleTracers() (string, error) {
-	_, tracer := getAnyMapElem(t.config.Metadata.Tracers)
-
-	traceMap := t.spec.Maps[tracer.MapName]
-	if traceMap == nil {
-		return "", fmt.Errorf("map %q not found", tracer.MapName)
+func (t *Tracer) handleTracers() error {
+	eventTypes, err := getEventTypesBTF(t.spec, t.config.Metadata)
+	if err != nil {
+		return err
 	}
 
-	// Almost same hack as in https://github.com/solo-io/bumblebee/blob/c2422b5bab66754b286d062317e244f02a431dac/pkg/loader/loader.go#L114-L120
-	// TODO: Remove it?
-	switch traceMap.Type {
-	case ebpf.RingBuf:
-		traceMap.ValueSize = 0
-	case ebpf.PerfEventArray:
-		traceMap.KeySize = 4
-		traceMap.ValueSize = 4
+	t.traceReaders = nil
+	for _, name := range sortedKeys(t.config.Metadata.Tracers) {
+		tracer := t.config.Metadata.Tracers[name]
+
+		traceMap := t.spec.Maps[tracer.MapName]
+		if traceMap == nil {
+			return fmt.Errorf("map %q not found", tracer.MapName)
+		}
+
+		r := &traceReader{
+			name:      name,
+			mapName:   tracer.MapName,
+			eventType: eventTypes[name],
+		}
+		r.variants, r.variantTypes, err = tracerVariants(t.config.Metadata, name, eventTypes)
+		if err != nil {
+			return err
+		}
+
+		// The size given by the user takes precedence over the one of the gadget
+		bufferPages, err := tracerBufferPages(tracer, os.Getpagesize())
+		if err != nil {
+			return fmt.Errorf("tracer %q: %w", name, err)
+		}
+		if t.config.BufferPages != 0 {
+			bufferPages = t.config.BufferPages
+		}
+		if err := checkBufferPages(bufferPages, os.Getpagesize()); err != nil {
+			return fmt.Errorf("tracer %q: %w", name, err)
+		}
+
+		// Almost same hack as in https://github.com/solo-io/bumblebee/blob/c2422b5bab66754b286d062317e244f02a431dac/pkg/loader/loader.go#L114-L120
+		// TODO: Remove it?
+		switch traceMap.Type {
+		case ebpf.RingBuf:
+			traceMap.ValueSize = 0
+			// Otherwise keep the size declared in the eBPF program
+			if bufferPages != 0 {
+				traceMap.MaxEntries = bufferPages * uint32(os.Getpagesize())
+			}
+		case ebpf.PerfEventArray:
+			traceMap.KeySize = 4
+			traceMap.ValueSize = 4
+			r.perfBufferPages = gadgets.PerfBufferPages
+			if bufferPages != 0 {
+				r.perfBufferPages = bufferPages
+			}
+		}
+
+		t.traceReaders = append(t.traceReaders, r)
 	}
 
-	return tracer.MapName, nil
+	return nil
 }
 
-func (t *Tracer) installTracer() error {
+func (t *Tracer) installTracer(logger logger.Logger) error {
 	var err error
-	var tracerMapName string
 
 	mapReplacements := map[string]*ebpf.Map{}
 	consts := map[string]interface{}{}
-
-	t.eventType, err = getEventTypeBTF(t.config.ProgContent, t.config.Metadata)
-	if err != nil {
-		return err
+	for name, value := range t.config.Constants {
+		consts[name] = value
 	}
 
-	switch {
-	case len(t.config.Metadata.Tracers) > 0:
-		tracerMapName, err = t.handleTracers()
-		if err != nil {
-			return fmt.Errorf("handling trace programs: %w", err)
+	if t.config.Metadata != nil {
+		if err := checkRequirements(t.config.Metadata, kernelVersion(), hasFeature); err != nil {
+			return fmt.Errorf("checking requirements: %w", err)
 		}
 	}
 
+	if err := t.handleTracers(); err != nil {
+		return fmt.Errorf("handling trace programs: %w", err)
+	}
+
+	if err := t.handleToppers(); err != nil {
+		return fmt.Errorf("handling toppers: %w", err)
+	}
+
+	if err := t.handleHistograms(); err != nil {
+		return fmt.Errorf("handling histograms: %w", err)
+	}
+
+	if err := t.handleMetrics(); err != nil {
+		return fmt.Errorf("handling metrics: %w", err)
+	}
+
 	// Handle special maps like mount ns filter, socket enricher, etc.
 	for _, m := range t.spec.Maps {
 		switch m.Name {
@@ -192,50 +349,130 @@ func (t *Tracer) installTracer() error {
 		}
 	}
 
+	if t.config.AggregateInterval != 0 {
+		m := t.spec.Maps[gadgets.AggregationMapName]
+		if m == nil {
+			return fmt.Errorf("map %q not found", gadgets.AggregationMapName)
+		}
+		if m.Type != ebpf.Hash {
+			return fmt.Errorf("map %q must be a hash map, got %s", gadgets.AggregationMapName, m.Type)
+		}
+		// The events of the different tracers, or of the variants of a
+		// tracer, can't be told apart in the aggregation map
+		if len(t.traceReaders) != 1 || t.traceReaders[0].variants != nil {
+			return fmt.Errorf("aggregation is only supported by the gadgets with a single tracer without variants")
+		}
+		eventType := t.traceReaders[0].eventType
+		if m.KeySize != eventType.Size || m.ValueSize != 8 {
+			return fmt.Errorf("map %q must have the event type %s as key and a 64 bits counter as value",
+				gadgets.AggregationMapName, eventType.Name)
+		}
+		consts[gadgets.AggregateConstName] = true
+	}
+
+	if t.config.Metadata != nil {
+		if err := resizeMaps(t.spec, t.config.Metadata, t.config.MapEntriesScale); err != nil {
+			return fmt.Errorf("resizing maps: %w", err)
+		}
+	}
+
 	if err := t.spec.RewriteConstants(consts); err != nil {
 		return fmt.Errorf("rewriting constants: %w", err)
 	}
 
+	// Check the attach targets before loading the programs, for the verifier
+	// or the attach errors not to hide the missing ones
+	kernel, releaseKernel := newHostKernel(logger)
+	attachTargets, err := resolveAttachTargets(t.spec, t.config.Metadata, kernel, kernelVersion())
+	releaseKernel()
+	if err != nil {
+		return fmt.Errorf("checking attach targets: %w", err)
+	}
+
 	// Load the ebpf objects
 	opts := ebpf.CollectionOptions{
 		MapReplacements: mapReplacements,
+		Programs: ebpf.ProgramOptions{
+			KernelTypes: kernelbtf.KernelTypes(),
+		},
+	}
+	pinned, err := gadgets.PinMaps(t.spec, &opts, t.config.PinPath)
+	if err != nil {
+		return err
 	}
 	t.collection, err = ebpf.NewCollectionWithOptions(t.spec, opts)
+	if pinned && errors.Is(err, ebpf.ErrMapIncompatible) {
+		// A new version of the gadget changed the definition of the maps, their
+		// state can't be reused
+		logger.Warnf("dropping the state of the maps pinned in %s: %v", t.config.PinPath, err)
+		if err := gadgets.RemovePinnedMaps(t.spec, t.config.PinPath); err != nil {
+			return fmt.Errorf("removing pinned maps: %w", err)
+		}
+		t.collection, err = ebpf.NewCollectionWithOptions(t.spec, opts)
+	}
 	if err != nil {
 		return fmt.Errorf("create BPF collection: %w", err)
 	}
 
+	t.lostSamplesMap = t.collection.Maps[gadgets.LostSamplesMapName]
+	for _, tp := range t.toppers {
+		tp.statsMap = t.collection.Maps[tp.mapName]
+	}
+	for _, r := range t.histogramReaders {
+		r.histogramMap = t.collection.Maps[r.mapName]
+	}
+	for _, r := range t.metricReaders {
+		r.countersMap = t.collection.Maps[r.mapName]
+	}
+	if t.config.AggregateInterval != 0 {
+		t.aggregationMap = t.collection.Maps[gadgets.AggregationMapName]
+	}
+	if m := t.collection.Maps[gadgets.StacksMapName]; m != nil {
+		t.stackReader = newStackReader(logger, m)
+	}
+
 	// Some logic before loading the programs
-	if tracerMapName != "" {
-		m := t.collection.Maps[tracerMapName]
+	for _, r := range t.traceReaders {
+		m := t.collection.Maps[r.mapName]
 		switch m.Type() {
 		case ebpf.RingBuf:
-			t.ringbufReader, err = ringbuf.NewReader(t.collection.Maps[tracerMapName])
+			r.ringbufReader, err = eventreader.NewRingbufReader(m)
 		case ebpf.PerfEventArray:
-			t.perfReader, err = perf.NewReader(t.collection.Maps[tracerMapName], gadgets.PerfBufferPages*os.Getpagesize())
+			r.perfReader, err = eventreader.NewPerfReader(m, int(r.perfBufferPages)*os.Getpagesize())
 		}
 		if err != nil {
-			return fmt.Errorf("create BPF map reader: %w", err)
+			return fmt.Errorf("create BPF map reader for tracer %q: %w", r.name, err)
 		}
+		r.bufferSize = r.describeBufferSize(m)
+		logger.Debugf("tracer %q: buffer of %s", r.name, r.bufferSize)
 	}
 
 	// Attach programs
 	socketFilterFound := false
+	uprobeProgs := []*uprobeProg{}
 	for progName, p := range t.spec.Programs {
-		if p.Type == ebpf.Kprobe && strings.HasPrefix(p.SectionName, "kprobe/") {
-			l, err := link.Kprobe(p.AttachTo, t.collection.Programs[progName], nil)
+		uprobeProg, err := parseUprobeProg(progName, p, t.config.Metadata)
+		if err != nil {
+			return err
+		}
+
+		if uprobeProg != nil {
+			// They are attached to the containers by the uprobe tracer
+			uprobeProgs = append(uprobeProgs, uprobeProg)
+		} else if p.Type == ebpf.Kprobe && strings.HasPrefix(p.SectionName, "kprobe/") {
+			l, err := link.Kprobe(attachTargets[progName], t.collection.Programs[progName], nil)
 			if err != nil {
 				return fmt.Errorf("attach BPF program %q: %w", progName, err)
 			}
 			t.links = append(t.links, l)
 		} else if p.Type == ebpf.Kprobe && strings.HasPrefix(p.SectionName, "kretprobe/") {
-			l, err := link.Kretprobe(p.AttachTo, t.collection.Programs[progName], nil)
+			l, err := link.Kretprobe(attachTargets[progName], t.collection.Programs[progName], nil)
 			if err != nil {
 				return fmt.Errorf("attach BPF program %q: %w", progName, err)
 			}
 			t.links = append(t.links, l)
 		} else if p.Type == ebpf.TracePoint && strings.HasPrefix(p.SectionName, "tracepoint/") {
-			parts := strings.Split(p.AttachTo, "/")
+			parts := strings.Split(attachTargets[progName], "/")
 			l, err := link.Tracepoint(parts[0], parts[1], t.collection.Programs[progName], nil)
 			if err != nil {
 				return fmt.Errorf("attach BPF program %q: %w", progName, err)
@@ -253,16 +490,28 @@ func (t *Tracer) installTracer() error {
 		}
 	}
 
+	if len(uprobeProgs) > 0 {
+		if err := t.uprobeTracer.load(uprobeProgs, t.collection); err != nil {
+			return fmt.Errorf("attaching uprobes: %w", err)
+		}
+	}
+
 	return nil
 }
 
-// processEventFunc returns a callback that parses a binary encoded event in data, enriches and
-// returns it.
-func (t *Tracer) processEventFunc(gadgetCtx gadgets.GadgetContext) func(data []byte) *types.Event {
-	typ := t.eventType
-
-	var mntNsIdstart uint32
-	mountNsIdFound := false
+// processEventFunc returns a callback that parses a binary encoded event of the given tracer in
+// data, enriches and returns it.
+func (t *Tracer) processEventFunc(logger logger.Logger, tracerName string, typ *btf.Struct) func(data []byte) *types.Event {
+	// The container and the pod of the events are found from their
+	// namespaces
+	var gadgetStruct types.Struct
+	if t.config.Metadata != nil {
+		gadgetStruct = t.config.Metadata.Structs[typ.Name]
+	}
+	mntnsMember, netnsMember, err := types.EnrichmentMembers(gadgetStruct, typ)
+	if err != nil {
+		logger.Warnf("the events of %s won't be enriched: %v", typ.Name, err)
+	}
 
 	type endpointType int
 
@@ -280,40 +529,71 @@ func (t *Tracer) processEventFunc(gadgetCtx gadgets.GadgetContext) func(data []b
 
 	endpointDefs := []endpointDef{}
 
-	// The same same data structure is always sent, so we can precalculate the offsets for
-	// different fields like mount ns id, endpoints, etc.
-	for _, member := range typ.Members {
-		switch member.Type.TypeName() {
-		case gadgets.MntNsIdTypeName:
-			typDef, ok := member.Type.(*btf.Typedef)
-			if !ok {
+	// The timestamps are converted to wall time here, as the boot time of the
+	// node isn't known where they are shown
+	timestampStarts := []uint32{}
+	// The frames of the stacks are read here, as the stacks map is only
+	// available on the node
+	type stackDef struct {
+		name  string
+		start uint32
+		user  bool
+	}
+	stackDefs := []stackDef{}
+	stackReader := t.stackReader
+	flatMembers := types.FlattenMembers(typ, 0)
+	if t.config.Metadata != nil {
+		members := map[string]btf.Member{}
+		for _, member := range flatMembers {
+			members[member.Name] = member
+		}
+		// The fields of the events of the toppers are the ones of their key
+		// and value structs
+		fields, _ := getEventFields(t.config.Metadata, tracerName, typ)
+		for _, field := range fields {
+			member, ok := members[field.Name]
+			if ok && types.IsStackKind(field.Attributes.Kind) && stackReader != nil {
+				if intM, ok := btf.UnderlyingType(member.Type).(*btf.Int); !ok || intM.Size != 4 {
+					logger.Warnf("stack %s is not a 32 bits integer", member.Name)
+					continue
+				}
+				stackDefs = append(stackDefs, stackDef{
+					name:  member.Name,
+					start: member.Offset.Bytes(),
+					user:  field.Attributes.Kind == types.KindUserStack,
+				})
 				continue
 			}
-
-			underlying, err := getUnderlyingType(typDef)
-			if err != nil {
+			if !ok || field.Attributes.Kind != types.KindTimestampNs {
 				continue
 			}
-
-			intM, ok := underlying.(*btf.Int)
-			if !ok {
+			if intM, ok := btf.UnderlyingType(member.Type).(*btf.Int); !ok || intM.Size != 8 {
+				logger.Warnf("timestamp %s is not a 64 bits integer", member.Name)
 				continue
 			}
+			timestampStarts = append(timestampStarts, member.Offset.Bytes())
+		}
+	}
 
-			if intM.Size != 8 {
-				continue
-			}
+	// The timestamp member, if any, gives the one of the event
+	eventTimestampStart := -1
 
-			mntNsIdstart = member.Offset.Bytes()
-			mountNsIdFound = true
+	// The same same data structure is always sent, so we can precalculate the offsets for
+	// different fields like endpoints, etc.
+	for _, member := range flatMembers {
+		if types.IsEventTimestamp(member) {
+			eventTimestampStart = int(member.Offset.Bytes())
+			continue
+		}
+		switch member.Type.TypeName() {
 		case gadgets.L3EndpointTypeName:
 			typ, ok := member.Type.(*btf.Struct)
 			if !ok {
-				gadgetCtx.Logger().Warn("%s is not a struct", member.Name)
+				logger.Warn("%s is not a struct", member.Name)
 				continue
 			}
 			if typ.Size != uint32(unsafe.Sizeof(l3EndpointT{})) {
-				gadgetCtx.Logger().Warn("%s is not the expected size", member.Name)
+				logger.Warn("%s is not the expected size", member.Name)
 				continue
 			}
 			e := endpointDef{name: member.Name, start: member.Offset.Bytes(), typ: L3}
@@ -321,11 +601,11 @@ func (t *Tracer) processEventFunc(gadgetCtx gadgets.GadgetContext) func(data []b
 		case gadgets.L4EndpointTypeName:
 			typ, ok := member.Type.(*btf.Struct)
 			if !ok {
-				gadgetCtx.Logger().Warn("%s is not a struct", member.Name)
+				logger.Warn("%s is not a struct", member.Name)
 				continue
 			}
 			if typ.Size != uint32(unsafe.Sizeof(l4EndpointT{})) {
-				gadgetCtx.Logger().Warn("%s is not the expected size", member.Name)
+				logger.Warn("%s is not the expected size", member.Name)
 				continue
 			}
 			e := endpointDef{name: member.Name, start: member.Offset.Bytes(), typ: L4}
@@ -334,10 +614,25 @@ func (t *Tracer) processEventFunc(gadgetCtx gadgets.GadgetContext) func(data []b
 	}
 
 	return func(data []byte) *types.Event {
-		// get mnt_ns_id for enriching the event
-		mtn_ns_id := uint64(0)
-		if mountNsIdFound {
-			mtn_ns_id = *(*uint64)(unsafe.Pointer(&data[mntNsIdstart]))
+		for _, start := range timestampStarts {
+			ts := (*uint64)(unsafe.Pointer(&data[start]))
+			if *ts != 0 {
+				*ts = uint64(gadgets.WallTimeFromBootTime(*ts))
+			}
+		}
+
+		var timestamp eventtypes.Time
+		if eventTimestampStart >= 0 {
+			timestamp = gadgets.WallTimeFromBootTime(*(*uint64)(unsafe.Pointer(&data[eventTimestampStart])))
+		}
+
+		var stacks []types.Stack
+		for _, stack := range stackDefs {
+			id := *(*int32)(unsafe.Pointer(&data[stack.start]))
+			stacks = append(stacks, types.Stack{
+				Name:   stack.name,
+				Frames: stackReader.frames(id, stack.user),
+			})
 		}
 
 		// enrich endpoints
@@ -353,7 +648,7 @@ func (t *Tracer) processEventFunc(gadgetCtx gadgets.GadgetContext) func(data []b
 			case 6:
 				size = 16
 			default:
-				gadgetCtx.Logger().Warnf("bad IP version received: %d", endpointC.version)
+				logger.Warnf("bad IP version received: %d", endpointC.version)
 				continue
 			}
 
@@ -388,53 +683,303 @@ func (t *Tracer) processEventFunc(gadgetCtx gadgets.GadgetContext) func(data []b
 
 		return &types.Event{
 			Event: eventtypes.Event{
-				Type: eventtypes.NORMAL,
+				Type:      eventtypes.NORMAL,
+				Timestamp: timestamp,
 			},
-			WithMountNsID: eventtypes.WithMountNsID{MountNsID: mtn_ns_id},
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: nsID(mntnsMember, data)},
+			NetNsID:       nsID(netnsMember, data),
+			Tracer:        tracerName,
 			RawData:       data,
 			L3Endpoints:   l3endpoints,
 			L4Endpoints:   l4endpoints,
+			Stacks:        stacks,
+		}
+	}
+}
+
+// variantsEventFunc returns a callback parsing the events of the tracer
+// tracerName like processEventFunc, with the struct told by variants. The
+// events without a variant, or too short for theirs, are parsed as typ.
+func (t *Tracer) variantsEventFunc(logger logger.Logger, tracerName string, typ *btf.Struct,
+	variants *eventVariants, variantTypes map[string]*btf.Struct,
+) func(data []byte) *types.Event {
+	process := t.processEventFunc(logger, tracerName, typ)
+	if variants == nil {
+		return process
+	}
+
+	processVariants := make(map[string]func(data []byte) *types.Event, len(variantTypes))
+	for structName, variantType := range variantTypes {
+		processVariants[structName] = t.processEventFunc(logger, tracerName, variantType)
+	}
+
+	return func(data []byte) *types.Event {
+		structName := variants.structName(data)
+		processVariant, ok := processVariants[structName]
+		if !ok || uint32(len(data)) < variantTypes[structName].Size {
+			return process(data)
 		}
+		ev := processVariant(data)
+		ev.Variant = structName
+		return ev
 	}
 }
 
-func (t *Tracer) runTracers(gadgetCtx gadgets.GadgetContext) {
-	cb := t.processEventFunc(gadgetCtx)
+// runSnapshotters runs the iterator programs of the snapshotters of the gadget
+// and sends the structures they write as events.
+func (t *Tracer) runSnapshotters(logger logger.Logger) error {
+	if len(t.config.Metadata.Snapshotters) == 0 {
+		return nil
+	}
+
+	eventTypes, err := getEventTypesBTF(t.spec, t.config.Metadata)
+	if err != nil {
+		return err
+	}
+	cols, err := newColumns(t.config.Metadata, eventTypes, false)
+	if err != nil {
+		return fmt.Errorf("getting columns: %w", err)
+	}
+
+	for _, name := range sortedKeys(t.config.Metadata.Snapshotters) {
+		snapshotter := t.config.Metadata.Snapshotters[name]
+		eventType := eventTypes[name]
+		if eventType.Size == 0 {
+			return fmt.Errorf("struct %q of snapshotter %q is empty", eventType.Name, name)
+		}
+		cb := t.processEventFunc(logger, name, eventType)
+
+		sortBy := t.config.SortBy
+		if len(sortBy) == 0 {
+			sortBy = snapshotter.SortBy
+		}
+		entries := []*types.Event{}
+
+		for _, progName := range snapshotter.Programs {
+			prog := t.collection.Programs[progName]
+			if prog == nil {
+				return fmt.Errorf("program %q not found", progName)
+			}
+
+			iter, err := link.AttachIter(link.IterOptions{Program: prog})
+			if err != nil {
+				return fmt.Errorf("attaching iterator %q: %w", progName, err)
+			}
+			buf, err := bpfiterns.Read(iter)
+			iter.Close()
+			if err != nil {
+				return fmt.Errorf("reading iterator %q: %w", progName, err)
+			}
+
+			size := int(eventType.Size)
+			for i := 0; i+size <= len(buf); i += size {
+				entries = append(entries, cb(buf[i:i+size]))
+			}
+		}
+
+		if len(sortBy) > 0 {
+			entries = topEntries(cols.GetColumnMap(), entries, sortBy, 0)
+		}
+		for _, ev := range entries {
+			t.sendEvent(ev)
+		}
+	}
+
+	return nil
+}
+
+// runTracer sends the events of the tracer read by r until it's closed.
+func (t *Tracer) runTracer(logger logger.Logger, r *traceReader) {
+	cb := t.variantsEventFunc(logger, r.name, r.eventType, r.variants, r.variantTypes)
 
 	for {
 		var rawSample []byte
 
-		if t.ringbufReader != nil {
-			record, err := t.ringbufReader.Read()
+		if r.ringbufReader != nil {
+			record, err := r.ringbufReader.Read()
 			if err != nil {
 				if errors.Is(err, ringbuf.ErrClosed) {
 					// nothing to do, we're done
 					return
 				}
-				gadgetCtx.Logger().Errorf("read ring buffer: %w", err)
+				logger.Errorf("read ring buffer: %w", err)
 				return
 			}
 			rawSample = record.RawSample
-		} else if t.perfReader != nil {
-			record, err := t.perfReader.Read()
+		} else if r.perfReader != nil {
+			record, err := r.perfReader.Read()
 			if err != nil {
 				if errors.Is(err, perf.ErrClosed) {
+					if r.lostSamples != 0 {
+						logger.Warnf("tracer %q lost %d samples with a buffer of %s: consider increasing --%s",
+							r.name, r.lostSamples, r.bufferSize, ParamBufferPages)
+					}
 					return
 				}
-				gadgetCtx.Logger().Errorf("read perf ring buffer: %w", err)
+				logger.Errorf("read perf ring buffer: %w", err)
 				return
 			}
 
 			if record.LostSamples != 0 {
-				gadgetCtx.Logger().Warnf("lost %d samples", record.LostSamples)
+				r.lostSamples += record.LostSamples
+				t.sendEvent(&types.Event{Event: eventtypes.LostSamples(record.LostSamples), Tracer: r.name})
 				continue
 			}
 			rawSample = record.RawSample
 		}
 
 		ev := cb(rawSample)
-		t.eventCallback(ev)
+		t.sendEvent(ev)
+	}
+}
+
+// readLostSamples returns the number of events lost by the eBPF program so far,
+// summed over all the CPUs.
+func (t *Tracer) readLostSamples() (uint64, error) {
+	var perCPU []uint64
+	if err := t.lostSamplesMap.Lookup(uint32(0), &perCPU); err != nil {
+		return 0, err
+	}
+
+	total := uint64(0)
+	for _, lost := range perCPU {
+		total += lost
+	}
+	return total, nil
+}
+
+// runLostSamples periodically reports the events counted as lost by the eBPF
+// program until done is closed. The last events lost are reported before
+// returning.
+func (t *Tracer) runLostSamples(logger logger.Logger, done <-chan struct{}) {
+	ticker := time.NewTicker(lostSamplesInterval)
+	defer ticker.Stop()
+
+	reported := uint64(0)
+	report := func() error {
+		total, err := t.readLostSamples()
+		if err != nil {
+			return err
+		}
+		if total > reported {
+			t.sendEvent(&types.Event{Event: eventtypes.LostSamples(total - reported)})
+			reported = total
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-done:
+			report()
+			return
+		case <-ticker.C:
+			if err := report(); err != nil {
+				logger.Warnf("reading lost samples: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// drainMap calls fn with the entries of the hash map m and removes them from
+// it. The key and the value passed to fn are only valid during the call.
+func drainMap(m *ebpf.Map, fn func(key, value []byte)) error {
+	// Collect the keys first, deleting entries while iterating a hash map can
+	// make the iteration restart from the beginning
+	keys := [][]byte{}
+	key := make([]byte, m.KeySize())
+	value := make([]byte, m.ValueSize())
+	iter := m.Iterate()
+	for iter.Next(key, value) {
+		keys = append(keys, bytes.Clone(key))
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("iterating map: %w", err)
+	}
+
+	for _, key := range keys {
+		err := m.LookupAndDelete(key, value)
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			// Kernels before 5.14 don't support it for hash maps. The updates
+			// done between both calls are lost.
+			err = m.Lookup(key, value)
+			if err == nil {
+				err = m.Delete(key)
+			}
+		}
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading entry: %w", err)
+		}
+
+		fn(key, value)
 	}
+	return nil
+}
+
+// flushAggregation sends the events counted in the aggregation map with their
+// count and removes them from the map.
+func (t *Tracer) flushAggregation(cb func(data []byte) *types.Event) error {
+	err := drainMap(t.aggregationMap, func(key, value []byte) {
+		ev := cb(key)
+		ev.Count = *(*uint64)(unsafe.Pointer(&value[0]))
+		t.sendEvent(ev)
+	})
+	if err != nil {
+		return fmt.Errorf("reading aggregation map: %w", err)
+	}
+	return nil
+}
+
+// runAggregation sends the events counted in the kernel on every aggregation
+// interval until done is closed. The last events counted are sent before
+// returning.
+func (t *Tracer) runAggregation(logger logger.Logger, done <-chan struct{}) {
+	ticker := time.NewTicker(t.config.AggregateInterval)
+	defer ticker.Stop()
+
+	r := t.traceReaders[0]
+	cb := t.processEventFunc(logger, r.name, r.eventType)
+
+	for {
+		select {
+		case <-done:
+			if err := t.flushAggregation(cb); err != nil {
+				logger.Warnf("flushing aggregated events: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.flushAggregation(cb); err != nil {
+				logger.Warnf("flushing aggregated events: %v", err)
+				return
+			}
+		}
+	}
+}
+
+func (t *Tracer) sendEvent(ev *types.Event) {
+	t.eventCallbackMu.Lock()
+	defer t.eventCallbackMu.Unlock()
+
+	t.eventCallback(ev)
+}
+
+// pinnedMapsPath returns where the gadget run from image with the given pin key
+// pins its maps: under the repository of image, without its tag or digest, so
+// the state survives the updates of the image. The maps aren't pinned, and the
+// path is empty, without key.
+func pinnedMapsPath(image, key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("parsing image %q: %w", image, err)
+	}
+	return gadgets.PinnedMapsPath(named.Name(), key), nil
 }
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
@@ -453,25 +998,104 @@ func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	}
 
 	t.config.Metadata = info.GadgetMetadata
-
-	if err := t.installTracer(); err != nil {
-		t.Stop()
-		return fmt.Errorf("install tracer: %w", err)
+	t.config.BufferPages = params.Get(ParamBufferPages).AsUint32()
+	t.config.MapEntriesScale = params.Get(ParamMapEntriesScale).AsFloat64()
+	t.config.PinPath, err = pinnedMapsPath(info.ImageRef, params.Get(ParamPinKey).AsString())
+	if err != nil {
+		return err
 	}
+	t.config.AggregateInterval = params.Get(ParamAggregateInterval).AsDuration()
+	t.config.TopInterval = params.Get(gadgets.ParamInterval).AsDuration()
+	t.config.TopMaxRows = params.Get(gadgets.ParamMaxRows).AsInt()
+	t.config.SortBy = params.Get(gadgets.ParamSortBy).AsStringSlice()
+	t.config.HistogramInterval = params.Get(ParamHistogramInterval).AsDuration()
+	t.config.Constants = paramConstants(info.GadgetMetadata, params)
+	t.config.ImageName = info.ImageRef
+
+	stop, err := t.start(gadgetCtx.Logger())
+	if err != nil {
+		return err
+	}
+	defer stop()
 
-	if t.perfReader != nil || t.ringbufReader != nil {
-		go t.runTracers(gadgetCtx)
+	// The gadgets only taking snapshots are done once they are sent
+	if len(t.config.Metadata.Tracers) == 0 && len(t.config.Metadata.Toppers) == 0 &&
+		len(t.config.Metadata.Histograms) == 0 && len(t.config.Metadata.Metrics) == 0 {
+		return nil
 	}
 	gadgetcontext.WaitForTimeoutOrDone(gadgetCtx)
 
 	return nil
 }
 
+// start installs the tracer and starts sending its events. The returned
+// function stops the goroutines reading the counters of the gadget, once they
+// sent their last events. The tracer is stopped if starting it fails.
+func (t *Tracer) start(gadgetLogger logger.Logger) (func(), error) {
+	if err := t.installTracer(gadgetLogger); err != nil {
+		t.Stop()
+		return nil, fmt.Errorf("install tracer: %w", err)
+	}
+
+	if err := t.runSnapshotters(gadgetLogger); err != nil {
+		t.Stop()
+		return nil, fmt.Errorf("running snapshotters: %w", err)
+	}
+
+	for _, r := range t.traceReaders {
+		if r.perfReader != nil || r.ringbufReader != nil {
+			go t.runTracer(gadgetLogger, r)
+		}
+	}
+
+	var stops []func()
+	runUntilStopped := func(run func(logger logger.Logger, done <-chan struct{})) {
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			run(gadgetLogger, done)
+			close(stopped)
+		}()
+		stops = append(stops, func() {
+			close(done)
+			<-stopped
+		})
+	}
+	if t.lostSamplesMap != nil {
+		runUntilStopped(t.runLostSamples)
+	}
+	if t.aggregationMap != nil {
+		runUntilStopped(t.runAggregation)
+	}
+	if len(t.toppers) > 0 {
+		runUntilStopped(t.runToppers)
+	}
+	if len(t.histogramReaders) > 0 {
+		runUntilStopped(t.runHistograms)
+	}
+	if len(t.metricReaders) > 0 {
+		stops = append(stops, t.registerMetrics(gadgetLogger))
+	}
+
+	return func() {
+		// Events lost while flushing the aggregated ones are reported too
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}, nil
+}
+
 func (t *Tracer) AttachContainer(container *containercollection.Container) error {
+	if err := t.uprobeTracer.AttachContainer(container); err != nil {
+		return err
+	}
 	return t.networkTracer.Attach(container.Pid)
 }
 
 func (t *Tracer) DetachContainer(container *containercollection.Container) error {
+	if err := t.uprobeTracer.DetachContainer(container); err != nil {
+		return err
+	}
 	return t.networkTracer.Detach(container.Pid)
 }
 
@@ -0,0 +1,335 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// uprobeProg is a uprobe or uretprobe program of the gadget. The section
+// names are "uprobe/<library>:<symbol>", where library is either an absolute
+// path inside the container, that can be a glob pattern, or the name of a
+// library, like "libc", that is looked up in the libraries mapped by the
+// container. The uprobe target of the metadata replaces the one of the
+// section, and can be a USDT probe.
+type uprobeProg struct {
+	name    string
+	library string
+	symbol  string
+	// Provider and name of the USDT probe the program is attached to instead
+	// of symbol
+	usdtProvider string
+	usdtName     string
+	ret          bool
+	prog         *ebpf.Program
+}
+
+// fileKey identifies a file on the host. The uprobes are attached to inodes,
+// so containers using the same file share the same links.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+type uprobeLinks struct {
+	refs  int
+	links []link.Link
+}
+
+// uprobeTracer attaches the uprobe programs to the libraries used by each
+// container.
+type uprobeTracer struct {
+	mu sync.Mutex
+
+	progs []*uprobeProg
+	// loaded is set once the programs are loaded in the kernel. Containers
+	// added before are attached at that moment.
+	loaded bool
+
+	containers map[string]*containercollection.Container
+	files      map[string][]fileKey
+	links      map[fileKey]*uprobeLinks
+}
+
+func newUprobeTracer() *uprobeTracer {
+	return &uprobeTracer{
+		containers: map[string]*containercollection.Container{},
+		files:      map[string][]fileKey{},
+		links:      map[fileKey]*uprobeLinks{},
+	}
+}
+
+func parseUprobeProg(name string, p *ebpf.ProgramSpec, metadata *types.GadgetMetadata) (*uprobeProg, error) {
+	if !types.IsUprobe(p) {
+		return nil, nil
+	}
+	ret := strings.HasPrefix(p.SectionName, "uretprobe/")
+
+	if target := metadata.Programs[name].Uprobe; target != nil {
+		prog := &uprobeProg{
+			name:    name,
+			library: target.Binary,
+			symbol:  target.Symbol,
+			ret:     ret,
+		}
+		if target.USDT != "" {
+			prog.usdtProvider, prog.usdtName, _ = strings.Cut(target.USDT, ":")
+		}
+		return prog, nil
+	}
+
+	library, symbol, ok := strings.Cut(p.AttachTo, ":")
+	if !ok || library == "" || symbol == "" {
+		return nil, fmt.Errorf("invalid section %q of program %q: expected <library>:<symbol>", p.SectionName, name)
+	}
+
+	return &uprobeProg{
+		name:    name,
+		library: library,
+		symbol:  symbol,
+		ret:     ret,
+	}, nil
+}
+
+// findLibrary returns the paths, from the host, of the given library in the
+// container of the given process: the executables matching it when it's an
+// absolute path.
+func findLibrary(pid uint32, library string) ([]string, error) {
+	root := filepath.Join(host.HostProcFs, fmt.Sprint(pid), "root")
+
+	if filepath.IsAbs(library) {
+		matches, err := filepath.Glob(filepath.Join(root, library))
+		if err != nil {
+			return nil, err
+		}
+		paths := []string{}
+		for _, match := range matches {
+			// Patterns like /usr/bin/python3* also match scripts
+			if isELF(match) {
+				paths = append(paths, match)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no executable matching %q in the container of process %d", library, pid)
+		}
+		return paths, nil
+	}
+
+	file, err := os.Open(filepath.Join(host.HostProcFs, fmt.Sprint(pid), "maps"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+
+		path := fields[5]
+		base := filepath.Base(path)
+		// Handle both libc.so.6 and libc-2.31.so
+		if strings.HasPrefix(base, library+".so") || strings.HasPrefix(base, library+"-") {
+			return []string{filepath.Join(root, path)}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("library %q not mapped by process %d", library, pid)
+}
+
+// isELF tells whether the file at path is an ELF file
+func isELF(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return false
+	}
+	return string(magic) == elf.ELFMAG
+}
+
+// load sets the loaded programs and attaches them to the containers that were
+// added before.
+func (u *uprobeTracer) load(progs []*uprobeProg, collection *ebpf.Collection) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, p := range progs {
+		p.prog = collection.Programs[p.name]
+	}
+	u.progs = progs
+	u.loaded = true
+
+	for _, container := range u.containers {
+		if err := u.attach(container); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *uprobeTracer) attach(container *containercollection.Container) error {
+	libraries := map[string][]*uprobeProg{}
+	for _, p := range u.progs {
+		libraries[p.library] = append(libraries[p.library], p)
+	}
+
+	for library, progs := range libraries {
+		paths, err := findLibrary(container.Pid, library)
+		if err != nil {
+			// The library isn't used by this container: nothing to trace
+			continue
+		}
+
+		for _, path := range paths {
+			if err := u.attachFile(container, path, progs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (u *uprobeTracer) attachFile(container *containercollection.Container, path string, progs []*uprobeProg) error {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return nil
+	}
+	key := fileKey{dev: uint64(stat.Dev), ino: stat.Ino}
+
+	if l, ok := u.links[key]; ok {
+		l.refs++
+		u.files[container.Runtime.ContainerID] = append(u.files[container.Runtime.ContainerID], key)
+		return nil
+	}
+
+	ex, err := link.OpenExecutable(path)
+	if err != nil {
+		return fmt.Errorf("opening %q: %w", path, err)
+	}
+
+	l := &uprobeLinks{refs: 1}
+	for _, p := range progs {
+		var lnk link.Link
+		switch {
+		case p.usdtName != "":
+			probe, findErr := findUSDTProbe(path, p.usdtProvider, p.usdtName)
+			if findErr != nil {
+				// Other versions of the binary matching the same pattern
+				// may not have the probe: nothing to trace
+				continue
+			}
+			lnk, err = ex.Uprobe(p.usdtName, p.prog, &link.UprobeOptions{
+				Address:      probe.offset,
+				RefCtrOffset: probe.semaphoreOffset,
+			})
+		case p.ret:
+			lnk, err = ex.Uretprobe(p.symbol, p.prog, nil)
+		default:
+			lnk, err = ex.Uprobe(p.symbol, p.prog, nil)
+		}
+		if err != nil {
+			for _, lnk := range l.links {
+				lnk.Close()
+			}
+			return fmt.Errorf("attach BPF program %q to %q: %w", p.name, path, err)
+		}
+		l.links = append(l.links, lnk)
+	}
+
+	u.links[key] = l
+	u.files[container.Runtime.ContainerID] = append(u.files[container.Runtime.ContainerID], key)
+	return nil
+}
+
+func (u *uprobeTracer) AttachContainer(container *containercollection.Container) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.containers[container.Runtime.ContainerID] = container
+	if !u.loaded || len(u.progs) == 0 {
+		return nil
+	}
+
+	return u.attach(container)
+}
+
+func (u *uprobeTracer) DetachContainer(container *containercollection.Container) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.containers, container.Runtime.ContainerID)
+
+	for _, key := range u.files[container.Runtime.ContainerID] {
+		l, ok := u.links[key]
+		if !ok {
+			continue
+		}
+		l.refs--
+		if l.refs > 0 {
+			continue
+		}
+		for _, lnk := range l.links {
+			lnk.Close()
+		}
+		delete(u.links, key)
+	}
+	delete(u.files, container.Runtime.ContainerID)
+
+	return nil
+}
+
+func (u *uprobeTracer) Close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, l := range u.links {
+		for _, lnk := range l.links {
+			lnk.Close()
+		}
+	}
+	u.links = map[fileKey]*uprobeLinks{}
+	u.files = map[string][]fileKey{}
+	u.progs = nil
+	u.loaded = false
+}
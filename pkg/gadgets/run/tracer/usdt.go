@@ -0,0 +1,177 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// The USDT probes are described by the notes of the .note.stapsdt section,
+// see https://sourceware.org/systemtap/wiki/UserSpaceProbeImplementation
+const (
+	usdtNotesSection = ".note.stapsdt"
+	usdtBaseSection  = ".stapsdt.base"
+	usdtNoteName     = "stapsdt"
+	usdtNoteType     = 3
+)
+
+// usdtNote is a note describing a USDT probe, with the addresses the binary
+// was linked with
+type usdtNote struct {
+	provider  string
+	name      string
+	pc        uint64
+	base      uint64
+	semaphore uint64
+}
+
+// usdtProbe is where a USDT probe is in its binary
+type usdtProbe struct {
+	// Offset of the probe in the file
+	offset uint64
+	// Offset of the semaphore counting the programs attached to the probe in
+	// the file, 0 if it has none
+	semaphoreOffset uint64
+}
+
+// parseUSDTNotes parses the notes of a .note.stapsdt section, ignoring the
+// ones not describing USDT probes. addrSize is the size of the addresses, 8
+// in 64 bits binaries.
+func parseUSDTNotes(data []byte, byteOrder binary.ByteOrder, addrSize int) ([]usdtNote, error) {
+	align := func(n uint32) int {
+		return int((n + 3) &^ 3)
+	}
+	readAddr := func(b []byte) uint64 {
+		if addrSize == 4 {
+			return uint64(byteOrder.Uint32(b))
+		}
+		return byteOrder.Uint64(b)
+	}
+
+	notes := []usdtNote{}
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, fmt.Errorf("truncated note header")
+		}
+		nameSize := byteOrder.Uint32(data[0:4])
+		descSize := byteOrder.Uint32(data[4:8])
+		noteType := byteOrder.Uint32(data[8:12])
+		data = data[12:]
+
+		if len(data) < align(nameSize)+align(descSize) {
+			return nil, fmt.Errorf("truncated note")
+		}
+		name := strings.TrimRight(string(data[:nameSize]), "\x00")
+		desc := data[align(nameSize) : align(nameSize)+int(descSize)]
+		data = data[align(nameSize)+align(descSize):]
+
+		if name != usdtNoteName || noteType != usdtNoteType {
+			continue
+		}
+		if len(desc) < 3*addrSize {
+			return nil, fmt.Errorf("truncated USDT note")
+		}
+		strs := strings.SplitN(string(desc[3*addrSize:]), "\x00", 3)
+		if len(strs) < 2 {
+			return nil, fmt.Errorf("USDT note without provider or name")
+		}
+
+		notes = append(notes, usdtNote{
+			provider:  strs[0],
+			name:      strs[1],
+			pc:        readAddr(desc[0:]),
+			base:      readAddr(desc[addrSize:]),
+			semaphore: readAddr(desc[2*addrSize:]),
+		})
+	}
+	return notes, nil
+}
+
+// addressToOffset returns the offset in the file of the given address of a
+// loaded segment, of an executable one if exec is set
+func addressToOffset(progs []*elf.Prog, addr uint64, exec bool) (uint64, bool) {
+	for _, prog := range progs {
+		if prog.Type != elf.PT_LOAD || (exec && prog.Flags&elf.PF_X == 0) {
+			continue
+		}
+		if prog.Vaddr <= addr && addr < prog.Vaddr+prog.Memsz {
+			return addr - prog.Vaddr + prog.Off, true
+		}
+	}
+	return 0, false
+}
+
+// findUSDTProbe returns where the USDT probe provider:name is in the binary at
+// path
+func findUSDTProbe(path, provider, name string) (*usdtProbe, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	section := f.Section(usdtNotesSection)
+	if section == nil {
+		return nil, fmt.Errorf("%s has no USDT probes", path)
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s section: %w", usdtNotesSection, err)
+	}
+	addrSize := 8
+	if f.Class == elf.ELFCLASS32 {
+		addrSize = 4
+	}
+	notes, err := parseUSDTNotes(data, f.ByteOrder, addrSize)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s section: %w", usdtNotesSection, err)
+	}
+
+	for _, note := range notes {
+		if note.provider != provider || note.name != name {
+			continue
+		}
+
+		// The addresses are adjusted by the difference between the address
+		// the base section was linked at and the one it got, e.g. when
+		// prelinked
+		pc, semaphore := note.pc, note.semaphore
+		if base := f.Section(usdtBaseSection); base != nil {
+			pc += base.Addr - note.base
+			if semaphore != 0 {
+				semaphore += base.Addr - note.base
+			}
+		}
+
+		probe := &usdtProbe{}
+		var ok bool
+		probe.offset, ok = addressToOffset(f.Progs, pc, true)
+		if !ok {
+			return nil, fmt.Errorf("USDT probe %s:%s of %s isn't in an executable segment", provider, name, path)
+		}
+		if semaphore != 0 {
+			probe.semaphoreOffset, ok = addressToOffset(f.Progs, semaphore, false)
+			if !ok {
+				return nil, fmt.Errorf("semaphore of USDT probe %s:%s of %s isn't in a loaded segment", provider, name, path)
+			}
+		}
+		return probe, nil
+	}
+
+	return nil, fmt.Errorf("USDT probe %s:%s not found in %s", provider, name, path)
+}
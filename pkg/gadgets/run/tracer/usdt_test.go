@@ -0,0 +1,88 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func usdtNoteBytes(name string, noteType uint32, desc []byte) []byte {
+	pad := func(b []byte) []byte {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, uint32(len(name)+1))
+	binary.Write(buf, binary.LittleEndian, uint32(len(desc)))
+	binary.Write(buf, binary.LittleEndian, noteType)
+	buf.Write(pad(append([]byte(name), 0)))
+	buf.Write(pad(desc))
+	return buf.Bytes()
+}
+
+func usdtDesc(pc, base, semaphore uint64, provider, name, args string) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, pc)
+	binary.Write(buf, binary.LittleEndian, base)
+	binary.Write(buf, binary.LittleEndian, semaphore)
+	buf.WriteString(provider + "\x00" + name + "\x00" + args + "\x00")
+	return buf.Bytes()
+}
+
+func TestParseUSDTNotes(t *testing.T) {
+	data := usdtNoteBytes("stapsdt", 3, usdtDesc(0x1130, 0x2004, 0, "python", "function__entry", "8@%rbx 8@%rbp -4@%eax"))
+	// Other notes are ignored
+	data = append(data, usdtNoteBytes("GNU", 3, []byte{1, 2, 3, 4})...)
+	data = append(data, usdtNoteBytes("stapsdt", 3, usdtDesc(0x1150, 0x2004, 0x4010, "myapp", "start", ""))...)
+
+	notes, err := parseUSDTNotes(data, binary.LittleEndian, 8)
+	require.NoError(t, err)
+	require.Equal(t, []usdtNote{
+		{provider: "python", name: "function__entry", pc: 0x1130, base: 0x2004},
+		{provider: "myapp", name: "start", pc: 0x1150, base: 0x2004, semaphore: 0x4010},
+	}, notes)
+
+	_, err = parseUSDTNotes(data[:len(data)-8], binary.LittleEndian, 8)
+	require.Error(t, err)
+	_, err = parseUSDTNotes(usdtNoteBytes("stapsdt", 3, []byte{1, 2, 3, 4}), binary.LittleEndian, 8)
+	require.ErrorContains(t, err, "truncated USDT note")
+}
+
+func TestAddressToOffset(t *testing.T) {
+	progs := []*elf.Prog{
+		{ProgHeader: elf.ProgHeader{Type: elf.PT_LOAD, Flags: elf.PF_R, Vaddr: 0, Off: 0, Memsz: 0x1000}},
+		{ProgHeader: elf.ProgHeader{Type: elf.PT_LOAD, Flags: elf.PF_R | elf.PF_X, Vaddr: 0x401000, Off: 0x1000, Memsz: 0x2000}},
+		{ProgHeader: elf.ProgHeader{Type: elf.PT_LOAD, Flags: elf.PF_R | elf.PF_W, Vaddr: 0x404000, Off: 0x3000, Memsz: 0x1000}},
+	}
+
+	off, ok := addressToOffset(progs, 0x401130, true)
+	require.True(t, ok)
+	require.Equal(t, uint64(0x1130), off)
+
+	// The semaphores are in data segments
+	_, ok = addressToOffset(progs, 0x404010, true)
+	require.False(t, ok)
+	off, ok = addressToOffset(progs, 0x404010, false)
+	require.True(t, ok)
+	require.Equal(t, uint64(0x3010), off)
+}
@@ -0,0 +1,241 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentSchemaVersion is the version of the metadata format of this version
+// of Inspektor Gadget. It must be increased, with an upgrade added to
+// schemaUpgrades, by the changes that the previous versions can't handle, like
+// renaming a key.
+const CurrentSchemaVersion = 1
+
+const schemaVersionKey = "schemaVersion"
+
+// schemaUpgrades migrate the metadata documents from a version to the next one:
+// schemaUpgrades[i] upgrades the mapping of a document of the version i. The
+// documents without schemaVersion, written before it existed, are version 0.
+var schemaUpgrades = []func(mapping *yaml.Node) error{
+	// The version 0 documents are valid version 1 documents
+	0: nil,
+}
+
+// DecodeMetadata decodes the metadata document data, upgrading it to the
+// current schema version first, see UpgradeMetadata. In strict mode, the keys
+// that don't match any field of GadgetMetadata, like a misspelled "elipsis",
+// are errors instead of being ignored, see UnknownKeys.
+func DecodeMetadata(data []byte, strict bool) (*GadgetMetadata, error) {
+	node, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	m := &GadgetMetadata{}
+	if err := node.Decode(m); err != nil {
+		return nil, err
+	}
+	if !strict {
+		return m, nil
+	}
+
+	var result error
+	for _, key := range unknownKeys(nil, node, reflect.TypeOf(GadgetMetadata{}), "") {
+		result = multierror.Append(result, fmt.Errorf("unknown key %s", key))
+	}
+	return m, result
+}
+
+// UpgradeMetadata migrates the metadata document data to CurrentSchemaVersion.
+// It fails if the document has a newer version, that this version of
+// Inspektor Gadget doesn't know how to handle.
+func UpgradeMetadata(data []byte) ([]byte, error) {
+	node, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	if node.Kind == 0 {
+		return data, nil
+	}
+	return yaml.Marshal(node)
+}
+
+// decodeNode decodes the metadata document data and upgrades it to
+// CurrentSchemaVersion
+func decodeNode(data []byte) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	// The other kinds of documents are reported by the decoder
+	if node.Kind != yaml.DocumentNode || len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return &node, nil
+	}
+	if err := upgradeMapping(node.Content[0]); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+func upgradeMapping(mapping *yaml.Node) error {
+	var versionNode *yaml.Node
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == schemaVersionKey {
+			versionNode = mapping.Content[i+1]
+			break
+		}
+	}
+
+	version := 0
+	if versionNode != nil {
+		if err := versionNode.Decode(&version); err != nil {
+			return fmt.Errorf("invalid schema version: %w", err)
+		}
+		if version < 0 {
+			return fmt.Errorf("invalid schema version %d", version)
+		}
+	}
+	if version > CurrentSchemaVersion {
+		return fmt.Errorf("metadata schema version %d is newer than the supported one (%d): update Inspektor Gadget",
+			version, CurrentSchemaVersion)
+	}
+	if version == CurrentSchemaVersion {
+		return nil
+	}
+
+	for ; version < CurrentSchemaVersion; version++ {
+		upgrade := schemaUpgrades[version]
+		if upgrade == nil {
+			continue
+		}
+		if err := upgrade(mapping); err != nil {
+			return fmt.Errorf("upgrading metadata from schema version %d: %w", version, err)
+		}
+	}
+
+	if versionNode == nil {
+		versionNode = &yaml.Node{}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: schemaVersionKey}
+		mapping.Content = append([]*yaml.Node{key, versionNode}, mapping.Content...)
+	}
+	*versionNode = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	return nil
+}
+
+// UnknownKeys returns the keys of the metadata document data that don't match
+// any field of GadgetMetadata, with their path and line, e.g.
+// "structs.event.fields[0].attributes.elipsis (line 12)".
+func UnknownKeys(data []byte) ([]string, error) {
+	node, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	return unknownKeys(nil, node, reflect.TypeOf(GadgetMetadata{}), ""), nil
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+func unknownKeys(result []string, node *yaml.Node, typ reflect.Type, path string) []string {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, content := range node.Content {
+			result = unknownKeys(result, content, typ, path)
+		}
+		return result
+	case yaml.AliasNode:
+		return unknownKeys(result, node.Alias, typ, path)
+	}
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if reflect.PointerTo(typ).Implements(unmarshalerType) {
+		return result
+	}
+
+	// The values of the wrong kind are reported by the decoder
+	switch typ.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return result
+		}
+		fields := yamlFields(typ)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinKeyPath(path, key.Value)
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				result = append(result, fmt.Sprintf("%s (line %d)", keyPath, key.Line))
+				continue
+			}
+			result = unknownKeys(result, value, fieldType, keyPath)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return result
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			result = unknownKeys(result, node.Content[i+1], typ.Elem(), joinKeyPath(path, node.Content[i].Value))
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return result
+		}
+		for i, item := range node.Content {
+			result = unknownKeys(result, item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	return result
+}
+
+// yamlFields returns the types of the fields of the struct by key, named like
+// the YAML decoder does.
+func yamlFields(typ reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, flags, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(flags, "inline") {
+			for key, fieldType := range yamlFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
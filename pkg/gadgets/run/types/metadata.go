@@ -15,27 +15,54 @@
 package types
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math/bits"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
+	"github.com/docker/go-units"
 	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/common/model"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/version"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
 // Keep this aligned with include/gadget/macros.h
 const (
 	// Prefix used to mark trace maps
 	traceMapPrefix = "gadget_trace_map_"
+	// Prefix used to mark snapshotters, followed by their name, struct and
+	// program separated by snapshotterSeparator
+	snapshotterPrefix    = "gadget_snapshotter_"
+	snapshotterSeparator = "___"
+	// Prefix used to mark toppers, followed by their name and map separated
+	// by snapshotterSeparator
+	topperPrefix = "gadget_topper_"
+	// Prefix used to mark histograms, followed by their name and map
+	// separated by snapshotterSeparator
+	histogramPrefix = "gadget_histogram_"
+	// Prefix used to mark the constants set by parameters, followed by the
+	// name of the constant
+	paramPrefix = "gadget_param_"
+	// Prefix of the constants set by Inspektor Gadget itself
+	internalConstPrefix = "gadget_"
 )
 
 const (
 	DefaultColumnWidth = 16
+	// Maximum width of the columns of the strings, paths can be much longer
+	MaxStringColumnWidth = 64
 )
 
 type Alignment string
@@ -55,6 +82,54 @@ const (
 	EllipsisEnd    EllipsisType = "end"
 )
 
+// Unit of the numbers of a field, for them to be shown in a human readable
+// form, e.g. 1.5MiB instead of 1572864 bytes
+type Unit string
+
+const (
+	UnitNone         Unit = ""
+	UnitBytes        Unit = "bytes"
+	UnitNanoseconds  Unit = "ns"
+	UnitMicroseconds Unit = "us"
+	UnitMilliseconds Unit = "ms"
+	UnitPackets      Unit = "packets"
+)
+
+// FieldKind tells what the value of a field means, for it to be shown properly
+// instead of as a raw number
+type FieldKind string
+
+const (
+	KindNone FieldKind = ""
+	// IP address: a gadget_l3endpoint_t, an IPv4 address in a 32 bits integer
+	// or an IPv6 one in an array of 16 bytes, in network byte order
+	KindL3Endpoint FieldKind = "l3endpoint"
+	// IP address and port: a gadget_l4endpoint_t
+	KindL4Endpoint FieldKind = "l4endpoint"
+	// Error number, shown by name, e.g. ENOENT or -ENOENT
+	KindErrno FieldKind = "errno"
+	// Return value of a syscall or of a kernel function, whose negative values
+	// are error numbers: they are shown by name, e.g. -ENOENT, and the others
+	// as numbers
+	KindReturnValue FieldKind = "returnValue"
+	// Time since boot in nanoseconds, as returned by bpf_ktime_get_boot_ns(),
+	// shown as wall time
+	KindTimestampNs FieldKind = "timestampNs"
+	// Number of bytes, shown with a human readable unit, e.g. 1.5MiB
+	KindBytes FieldKind = "bytes"
+	// Duration in nanoseconds, shown with a human readable unit, e.g. 1.5ms
+	KindDuration FieldKind = "duration"
+	// ID of a kernel or user stack in the gadget_stacks map, as returned by
+	// bpf_get_stackid(), shown as the frames of the stack
+	KindKernelStack FieldKind = "kernelStack"
+	KindUserStack   FieldKind = "userStack"
+)
+
+// IsStackKind tells whether the values of the given kind are IDs of stacks
+func IsStackKind(kind FieldKind) bool {
+	return kind == KindKernelStack || kind == KindUserStack
+}
+
 // FieldAttributes describes how to format a field. It's almost 1:1 mapping with columns.Attributes,
 // however we are keeping this separated because we don't want to create a strong coupling with the
 // columns library now. Later on we can consider merging both of them.
@@ -75,6 +150,46 @@ type FieldAttributes struct {
 	// Template defines the template that will be used.
 	// TODO: add a link to existing templates
 	Template string `yaml:"template,omitempty"`
+	// Kind of the value, for it to be shown properly, e.g. the name of an
+	// error number. It's inferred from the types of include/gadget/types.h.
+	Kind FieldKind `yaml:"kind,omitempty"`
+	// RawValue shows the number stored in an enum field instead of the name
+	// of its value
+	RawValue bool `yaml:"rawValue,omitempty"`
+	// Unit of the numbers of the field, for them to be humanized unless the
+	// raw values are asked for. It's implied by the kinds bytes and duration.
+	Unit Unit `yaml:"unit,omitempty"`
+}
+
+// KindUnit returns the unit of the values of the given kind, if it has one
+func KindUnit(kind FieldKind) Unit {
+	switch kind {
+	case KindBytes:
+		return UnitBytes
+	case KindDuration:
+		return UnitNanoseconds
+	}
+	return UnitNone
+}
+
+// GetUnit returns the unit of the numbers of the field, the one of its kind
+// when it isn't set
+func (a *FieldAttributes) GetUnit() Unit {
+	if a.Unit != UnitNone {
+		return a.Unit
+	}
+	return KindUnit(a.Kind)
+}
+
+// Flag is the name of a bit of a field, or of a value of a group of bits
+type Flag struct {
+	Name string `yaml:"name"`
+	// Value of the bits
+	Value uint64 `yaml:"value"`
+	// Bits compared with Value, Value itself when 0. It allows naming the
+	// values of a group of bits, like O_RDONLY (0), O_WRONLY (1) and O_RDWR
+	// (2) with the mask of O_ACCMODE (0x3)
+	Mask uint64 `yaml:"mask,omitempty"`
 }
 
 type Field struct {
@@ -84,14 +199,58 @@ type Field struct {
 	Description string `yaml:"description,omitempty"`
 	// Attributes defines how the field should be formatted
 	Attributes FieldAttributes `yaml:"attributes"`
+	// Flags are the names of the bits of the field. Its value is shown as the
+	// names of the ones set joined with '|', e.g. O_WRONLY|O_CLOEXEC
+	Flags []Flag `yaml:"flags,omitempty"`
+	// FlagsEnum tells that the values of the enum of the field are bits, for
+	// its value to be shown like with Flags
+	FlagsEnum bool `yaml:"flagsEnum,omitempty"`
 	// Annotations represents extra information that is not relevant to Inspektor Gadget, but
 	// for other applications, like color font for instance.
 	Annotations map[string]interface{} `yaml:"annotations,omitempty"`
 }
 
+// Enrichment tells which members of a struct give the namespaces its events
+// are enriched from, with the container and the pod they come from
+type Enrichment struct {
+	// Member giving the ID of the mount namespace, the mnt_ns_id_t one when
+	// empty
+	MntNs string `yaml:"mntns,omitempty"`
+	// Member giving the ID of the network namespace, the gadget_netns_id_t one
+	// when empty
+	NetNs string `yaml:"netns,omitempty"`
+	// KeepRaw adds the members giving the namespaces as fields, showing their
+	// raw values besides the container and pod columns. They are replaced by
+	// those columns otherwise.
+	KeepRaw bool `yaml:"keepRaw,omitempty"`
+	// Disabled doesn't enrich the events, the members giving the namespaces
+	// are added as any other field
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// DefaultMaxDepth is the number of levels of the structures whose members are
+// added as fields when Struct.MaxDepth isn't set: the event, the structures it
+// contains and the ones they contain.
+const DefaultMaxDepth = 3
+
 // Struct describes a type generated by the gadget
 type Struct struct {
-	Fields []Field `yaml:"fields"`
+	// Number of levels of nested structures whose members are added as fields
+	// by Populate, e.g. 1 to only add the direct members of the struct and 2 to
+	// also add "task.comm" for the member comm of its member task.
+	// DefaultMaxDepth when 0.
+	MaxDepth int     `yaml:"maxDepth,omitempty"`
+	Fields   []Field `yaml:"fields"`
+	// How the events are enriched from the namespaces they come from
+	Enrichment Enrichment `yaml:"enrichment,omitempty"`
+}
+
+// Depth returns MaxDepth, or DefaultMaxDepth when it isn't set
+func (s Struct) Depth() int {
+	if s.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return s.MaxDepth
 }
 
 // Tracer describe the behavior of a gadget that collects and sends events to user space
@@ -101,123 +260,1751 @@ type Tracer struct {
 	MapName string `yaml:"mapName"`
 	// Name of the structure generated by this tracer
 	StructName string `yaml:"structName"`
+	// Default number of memory pages of the buffer: per CPU for a perf event
+	// array, in total for a ring buffer. It must be a power of 2.
+	BufferPages uint32 `yaml:"bufferPages,omitempty"`
+	// Default size of the buffer in bytes instead of pages, e.g. 1MiB. It's
+	// rounded up to a power of 2 pages.
+	BufferSize string `yaml:"bufferSize,omitempty"`
+	// Member of StructName telling the structure of each event, when the
+	// tracer sends several of them. It must be an integer or an enum at the
+	// same offset in all of them, e.g. in a common header.
+	Discriminator string `yaml:"discriminator,omitempty"`
+	// Structures of the events by value of the discriminator, given as a
+	// number or as the name of a value of its enum. The events with other
+	// values are decoded as StructName.
+	Variants map[string]string `yaml:"variants,omitempty"`
+}
+
+// Snapshotter describes the behavior of a gadget that collects the current
+// state of the system, like the running processes, with BPF iterators
+type Snapshotter struct {
+	// Name of the structure the iterator programs write with bpf_seq_write()
+	StructName string `yaml:"structName"`
+	// Names of the iterator programs run to take the snapshot
+	Programs []string `yaml:"programs"`
+	// Default fields to sort the entries by, prefixed with '-' for descending
+	// order. The entries are sent in the order they are written when empty.
+	SortBy []string `yaml:"sortBy,omitempty"`
+}
+
+// Topper describes the behavior of a gadget that collects statistics in a hash
+// map, like the bytes read and written per process, and periodically sends the
+// entries with the highest values
+type Topper struct {
+	// Name of the hash map with the statistics
+	MapName string `yaml:"mapName"`
+	// Name of the structure of the keys of the map
+	KeyStructName string `yaml:"keyStructName"`
+	// Name of the structure of the values of the map
+	ValueStructName string `yaml:"valueStructName"`
+	// Default fields to sort the entries by, prefixed with '-' for descending
+	// order. The fields of the values in descending order when empty.
+	SortBy []string `yaml:"sortBy,omitempty"`
+	// Default interval the entries are sent on, e.g. "5s". Every second when
+	// empty.
+	Interval string `yaml:"interval,omitempty"`
+}
+
+// Histogram describes a log2 histogram maintained by the gadget in a map, like
+// the latency of the block device I/O: the values of the map are arrays of
+// counters, the slot i counting the values between 2^i and 2^(i+1)-1. The
+// counters of all the entries of the map are summed.
+type Histogram struct {
+	// Name of the array or hash map with the counters
+	MapName string `yaml:"mapName"`
+	// Unit of the values counted, e.g. "µs"
+	Unit string `yaml:"unit"`
+}
+
+type MetricType string
+
+const (
+	// The value only goes up, like a number of events
+	MetricTypeCounter MetricType = "counter"
+	// The value can go up and down, like a number of open files
+	MetricTypeGauge MetricType = "gauge"
+)
+
+// Metric describes a hash map of the gadget holding counters, exported as a
+// Prometheus metric on the metrics endpoint: the keys are structures whose
+// members label the metric, the values integers or structures with such a
+// member. The values of the entries with the same labels are summed.
+type Metric struct {
+	// Name of the hash map with the counters
+	MapName string `yaml:"mapName"`
+	// Type of the metric, counter or gauge
+	Type MetricType `yaml:"type"`
+	// Help text of the metric
+	Description string `yaml:"description,omitempty"`
+	// Members of the structure of the keys the metric is labelled by, e.g.
+	// "comm". The dots of the members of nested structures are replaced by
+	// underscores in the name of the labels. The values of all the entries
+	// are summed when empty.
+	Labels []string `yaml:"labels,omitempty"`
+	// Member of the structure of the values holding the counter. It must
+	// only be set when the values are structures.
+	ValueField string `yaml:"valueField,omitempty"`
+}
+
+// AttachTarget is a kernel function or a tracepoint a program can be attached
+// to instead of the one of its section, e.g. a function renamed in a kernel
+// version
+type AttachTarget struct {
+	// Function of a kprobe or kretprobe, or category/name of a tracepoint
+	Target string `yaml:"target"`
+	// Versions of the kernel the target is tried with, e.g. "5.8". All of
+	// them when empty.
+	MinKernelVersion string `yaml:"minKernelVersion,omitempty"`
+	MaxKernelVersion string `yaml:"maxKernelVersion,omitempty"`
+}
+
+// UprobeTarget is where a uprobe or uretprobe program is attached in the
+// containers, instead of the library and the symbol of its section
+type UprobeTarget struct {
+	// Absolute path of the binary inside the containers, that can be a glob
+	// pattern like /usr/bin/python3*, or name of a library mapped by their
+	// main process, like "libc"
+	Binary string `yaml:"binary"`
+	// Function the program is attached to
+	Symbol string `yaml:"symbol,omitempty"`
+	// USDT probe the program is attached to instead of a function, as
+	// provider:name, e.g. python:function__entry
+	USDT string `yaml:"usdt,omitempty"`
+}
+
+// Program describes how a program of the gadget is attached
+type Program struct {
+	// Targets tried in order when the one of the section of a kprobe,
+	// kretprobe or tracepoint program isn't in the running kernel
+	Alternatives []AttachTarget `yaml:"alternatives,omitempty"`
+	// Target of a uprobe or uretprobe program
+	Uprobe *UprobeTarget `yaml:"uprobe,omitempty"`
+}
+
+// Map describes how a map of the gadget is sized
+type Map struct {
+	// Maximum number of entries of the map, overriding the one of the eBPF
+	// program when it isn't 0
+	MaxEntries uint32 `yaml:"maxEntries,omitempty"`
+	// Number of distinct entries the map is expected to hold, e.g. the number
+	// of processes of a node for a map keyed by PID. The map is reported as
+	// too small when it can hold less of them.
+	ExpectedEntries uint32 `yaml:"expectedEntries,omitempty"`
+	// Whether the maximum number of entries is multiplied by the factor given
+	// with --map-entries-scale when the gadget is loaded, e.g. for the busy
+	// nodes
+	Scalable bool `yaml:"scalable,omitempty"`
+}
+
+// Param describes a parameter of the gadget: the user gives its value to a
+// constant of the eBPF program, like the PID to filter the events by
+type Param struct {
+	// Name of the const volatile variable of the eBPF program
+	VarName string `yaml:"varName"`
+	// Type of the value, e.g. "uint32". It must match the type of the variable
+	Type params.TypeHint `yaml:"type"`
+	// Value used when the user doesn't give any
+	DefaultValue string `yaml:"defaultValue"`
+	// Description shown to the user
+	Description string `yaml:"description,omitempty"`
+}
+
+// BPFFeatures are the features of the kernel gadgets can require, with the
+// version of Linux that added them
+var BPFFeatures = map[string]string{
+	// BPF_MAP_TYPE_RINGBUF
+	"ringbuf": "5.8",
+	// bpf_loop()
+	"bpf_loop": "5.17",
+	// bpf_ktime_get_boot_ns()
+	"bpf_ktime_get_boot_ns": "5.7",
+	// BTF of the kernel, exposed or from BTFHub, for CO-RE relocations
+	"co-re": "5.4",
+	// fentry, fexit and iterator programs
+	"fentry": "5.5",
+	// BPF_MAP_TYPE_TASK_STORAGE
+	"task_storage": "5.11",
+}
+
+// Requirements are what the gadget needs from the kernel besides its version
+type Requirements struct {
+	// Features the kernel must support, see BPFFeatures
+	Features []string `yaml:"features,omitempty"`
 }
 
 type GadgetMetadata struct {
+	// Version of the metadata format, CurrentSchemaVersion for the documents
+	// written by this version of Inspektor Gadget. The older documents are
+	// upgraded when decoded with DecodeMetadata.
+	SchemaVersion int `yaml:"schemaVersion,omitempty"`
 	// Gadget name
 	Name string `yaml:"name"`
 	// Gadget description
 	Description string `yaml:"description,omitempty"`
+	// Gadget category, e.g. "trace". It's used by the catalogs.
+	Category string `yaml:"category,omitempty"`
+	// Capabilities required to run the gadget, e.g. "CAP_BPF"
+	Capabilities []string `yaml:"capabilities,omitempty"`
+	// Minimum kernel version required to run the gadget, e.g. "5.8"
+	MinKernelVersion string `yaml:"minKernelVersion,omitempty"`
+	// Features of the kernel required to run the gadget, checked with the
+	// minimum kernel version before loading it
+	Requirements Requirements `yaml:"requirements,omitempty"`
 	// Tracers implemented by the gadget
 	// TODO: Rename this field to something that doesn't collide with the opentelemetry concept
 	Tracers map[string]Tracer `yaml:"tracers,omitempty"`
+	// Snapshotters implemented by the gadget
+	Snapshotters map[string]Snapshotter `yaml:"snapshotters,omitempty"`
+	// Toppers implemented by the gadget
+	Toppers map[string]Topper `yaml:"toppers,omitempty"`
+	// Histograms maintained by the gadget
+	Histograms map[string]Histogram `yaml:"histograms,omitempty"`
+	// Metrics exported from the counters maps of the gadget, by name
+	Metrics map[string]Metric `yaml:"metrics,omitempty"`
+	// Parameters of the gadget, by key
+	Params map[string]Param `yaml:"params,omitempty"`
+	// How the programs are attached, by name
+	Programs map[string]Program `yaml:"programs,omitempty"`
+	// How the maps are sized, by name
+	Maps map[string]Map `yaml:"maps,omitempty"`
+	// Filters applied to the events unless the user filters the same fields,
+	// with the syntax of --filter, e.g. "ret:!0" to hide the events whose ret
+	// field is 0
+	DefaultFilters []string `yaml:"defaultFilters,omitempty"`
 	// Types generated by the gadget
 	Structs map[string]Struct `yaml:"structs,omitempty"`
 }
 
-func (m *GadgetMetadata) Validate(spec *ebpf.CollectionSpec) error {
-	var result error
+func (m *GadgetMetadata) Validate(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	if m.SchemaVersion > CurrentSchemaVersion {
+		result = multierror.Append(result, fmt.Errorf("schema version %d is newer than the supported one (%d)",
+			m.SchemaVersion, CurrentSchemaVersion))
+	}
+
+	if m.Name == "" {
+		result = multierror.Append(result, errors.New("gadget name is required"))
+	}
+
+	if _, ok := gadgets.GetCategories()[m.Category]; m.Category != "" && !ok {
+		result = multierror.Append(result, fmt.Errorf("unknown category %q", m.Category))
+	}
+
+	for _, capability := range m.Capabilities {
+		if !strings.HasPrefix(capability, "CAP_") || strings.ToUpper(capability) != capability {
+			result = multierror.Append(result, fmt.Errorf("invalid capability %q: expected e.g. CAP_BPF", capability))
+		}
+	}
+
+	if m.MinKernelVersion != "" {
+		if _, err := version.ParseGeneric(m.MinKernelVersion); err != nil {
+			result = multierror.Append(result, fmt.Errorf("invalid minimum kernel version %q: %w", m.MinKernelVersion, err))
+		}
+	}
+
+	for _, feature := range m.Requirements.Features {
+		if _, ok := BPFFeatures[feature]; !ok {
+			result = multierror.Append(result, fmt.Errorf("unknown required feature %q, expected one of: %s",
+				feature, strings.Join(sortedKeys(BPFFeatures), ", ")))
+		}
+	}
+
+	if err := m.validateTracers(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateSnapshotters(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateToppers(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateHistograms(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateMetrics(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateParams(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validatePrograms(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateMaps(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateStructs(spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := m.validateDefaultFilters(); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	return result
+}
+
+func (m *GadgetMetadata) validateDefaultFilters() error {
+	var result error
+
+	builtinColumns := GetColumns()
+
+	for _, defaultFilter := range m.DefaultFilters {
+		name, _, ok := strings.Cut(defaultFilter, ":")
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("invalid default filter %q: expected field:rule", defaultFilter))
+			continue
+		}
+		if _, ok := builtinColumns.GetColumn(name); ok {
+			continue
+		}
+		if !m.hasField(name) {
+			result = multierror.Append(result, fmt.Errorf("default filter %q: field %q not found", defaultFilter, name))
+		}
+	}
+
+	return result
+}
+
+// hasField tells whether any of the structs has a field with the given name,
+// compared case-insensitively like the columns do
+func (m *GadgetMetadata) hasField(name string) bool {
+	for _, s := range m.Structs {
+		for _, field := range s.Fields {
+			if strings.EqualFold(field.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Filters returns the filters to apply to the events: the given ones, set by
+// the user, and the default filters on the other fields
+func (m *GadgetMetadata) Filters(filters []string) []string {
+	filtered := make(map[string]struct{}, len(filters))
+	for _, f := range filters {
+		name, _, _ := strings.Cut(f, ":")
+		filtered[strings.ToLower(name)] = struct{}{}
+	}
+
+	result := append([]string{}, filters...)
+	for _, defaultFilter := range m.DefaultFilters {
+		name, _, _ := strings.Cut(defaultFilter, ":")
+		if _, ok := filtered[strings.ToLower(name)]; ok {
+			continue
+		}
+		result = append(result, defaultFilter)
+	}
+	return result
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *GadgetMetadata) validateTracers(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	// Each tracer reads its events from its own map
+	mapTracers := make(map[string]string, len(m.Tracers))
+
+	for _, name := range sortedKeys(m.Tracers) {
+		tracer := m.Tracers[name]
+
+		if tracer.MapName == "" {
+			result = multierror.Append(result, fmt.Errorf("tracer %q is missing mapName", name))
+		}
+
+		if tracer.StructName == "" {
+			result = multierror.Append(result, fmt.Errorf("tracer %q is missing structName", name))
+		}
+
+		if tracer.BufferPages != 0 && bits.OnesCount32(tracer.BufferPages) != 1 {
+			result = multierror.Append(result, fmt.Errorf("tracer %q has invalid bufferPages %d: must be a power of 2", name, tracer.BufferPages))
+		}
+
+		if tracer.BufferSize != "" {
+			if tracer.BufferPages != 0 {
+				result = multierror.Append(result, fmt.Errorf("tracer %q can't have both bufferPages and bufferSize", name))
+			}
+			if size, err := units.RAMInBytes(tracer.BufferSize); err != nil || size <= 0 {
+				result = multierror.Append(result, fmt.Errorf("tracer %q has invalid bufferSize %q: expected a positive size, e.g. 1MiB", name, tracer.BufferSize))
+			}
+		}
+
+		_, ok := m.Structs[tracer.StructName]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("tracer %q references unknown struct %q", name, tracer.StructName))
+		}
+
+		if other, ok := mapTracers[tracer.MapName]; ok && tracer.MapName != "" {
+			result = multierror.Append(result, fmt.Errorf("tracers %q and %q use the same map %q", other, name, tracer.MapName))
+			continue
+		}
+		mapTracers[tracer.MapName] = name
+
+		ebpfm, ok := spec.Maps[tracer.MapName]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", tracer.MapName))
+			continue
+		}
+
+		if err := validateTraceMap(ebpfm); err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+
+		if tracer.Discriminator != "" || len(tracer.Variants) > 0 {
+			if err := m.validateVariants(spec, name, tracer, ebpfm.Value.(*btf.Struct)); err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+	}
+
+	return result
+}
+
+// validateVariants checks that the structures of the variants of tracer, whose
+// events are of type eventType, have its discriminator at the same place
+func (m *GadgetMetadata) validateVariants(spec *ebpf.CollectionSpec, name string, tracer Tracer, eventType *btf.Struct) error {
+	var result error
+
+	discriminator, _, err := TracerVariants(tracer, eventType)
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("tracer %q: %w", name, err))
+		if discriminator.Type == nil {
+			return result
+		}
+	}
+
+	for _, value := range sortedKeys(tracer.Variants) {
+		structName := tracer.Variants[value]
+		if _, ok := m.Structs[structName]; !ok {
+			result = multierror.Append(result, fmt.Errorf("tracer %q references unknown struct %q", name, structName))
+		}
+
+		var btfStruct *btf.Struct
+		if spec.Types == nil || spec.Types.TypeByName(structName, &btfStruct) != nil {
+			result = multierror.Append(result, fmt.Errorf("struct %q of tracer %q not found in eBPF object", structName, name))
+			continue
+		}
+		found := false
+		for _, member := range FlattenMembers(btfStruct, 0) {
+			if member.Name != discriminator.Name {
+				continue
+			}
+			found = member.Offset == discriminator.Offset && member.Type.TypeName() == discriminator.Type.TypeName()
+			break
+		}
+		if !found {
+			result = multierror.Append(result, fmt.Errorf("struct %q of tracer %q must have the discriminator %q of struct %q at the same offset and with the same type",
+				structName, name, discriminator.Name, eventType.Name))
+		}
+	}
+
+	return result
+}
+
+// TracerVariants returns the discriminator member of the events of tracer, of
+// type eventType, and the structures of its variants by value of the
+// discriminator. The values of the signed discriminators are sign-extended to
+// 64 bits.
+func TracerVariants(tracer Tracer, eventType *btf.Struct) (btf.Member, map[uint64]string, error) {
+	if tracer.Discriminator == "" {
+		return btf.Member{}, nil, errors.New("variants are set but discriminator isn't")
+	}
+	if len(tracer.Variants) == 0 {
+		return btf.Member{}, nil, errors.New("discriminator is set but variants aren't")
+	}
+
+	var discriminator *btf.Member
+	for _, member := range FlattenMembers(eventType, 0) {
+		if member.Name == tracer.Discriminator {
+			discriminator = &member
+			break
+		}
+	}
+	if discriminator == nil {
+		return btf.Member{}, nil, fmt.Errorf("discriminator %q isn't a member of struct %q", tracer.Discriminator, eventType.Name)
+	}
+
+	var enum *btf.Enum
+	signed := false
+	switch typ := btf.UnderlyingType(discriminator.Type).(type) {
+	case *btf.Int:
+		if typ.Encoding == btf.Bool {
+			return btf.Member{}, nil, fmt.Errorf("discriminator %q must be an integer or an enum", tracer.Discriminator)
+		}
+		signed = typ.Encoding == btf.Signed
+	case *btf.Enum:
+		enum = typ
+		signed = typ.Signed
+	default:
+		return btf.Member{}, nil, fmt.Errorf("discriminator %q must be an integer or an enum", tracer.Discriminator)
+	}
+
+	variants := make(map[uint64]string, len(tracer.Variants))
+	for _, key := range sortedKeys(tracer.Variants) {
+		value, ok := uint64(0), false
+		if enum != nil {
+			for _, v := range enum.Values {
+				if v.Name == key {
+					value, ok = v.Value, true
+					break
+				}
+			}
+		}
+		if !ok && signed {
+			if i, err := strconv.ParseInt(key, 0, 64); err == nil {
+				value, ok = uint64(i), true
+			}
+		} else if !ok {
+			if u, err := strconv.ParseUint(key, 0, 64); err == nil {
+				value, ok = u, true
+			}
+		}
+		if !ok {
+			return *discriminator, nil, fmt.Errorf("invalid value %q of discriminator %q: expected a number or a value of its enum", key, tracer.Discriminator)
+		}
+		variants[value] = tracer.Variants[key]
+	}
+
+	return *discriminator, variants, nil
+}
+
+func (m *GadgetMetadata) validateSnapshotters(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Snapshotters) {
+		snapshotter := m.Snapshotters[name]
+
+		// The events are told apart by the name of what sent them
+		if _, ok := m.Tracers[name]; ok {
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q has the same name as a tracer", name))
+		}
+
+		if snapshotter.StructName == "" {
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q is missing structName", name))
+		} else if _, ok := m.Structs[snapshotter.StructName]; !ok {
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q references unknown struct %q", name, snapshotter.StructName))
+		}
+
+		if len(snapshotter.Programs) == 0 {
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q is missing programs", name))
+		}
+
+		for _, progName := range snapshotter.Programs {
+			prog, ok := spec.Programs[progName]
+			if !ok {
+				result = multierror.Append(result, fmt.Errorf("program %q not found in eBPF object", progName))
+				continue
+			}
+
+			if !IsIterator(prog) {
+				result = multierror.Append(result, fmt.Errorf("program %q of snapshotter %q is not an iterator", progName, name))
+			}
+		}
+
+		for _, sortBy := range snapshotter.SortBy {
+			if field := strings.TrimPrefix(sortBy, "-"); !m.structHasField(snapshotter.StructName, field) {
+				result = multierror.Append(result, fmt.Errorf("snapshotter %q sorts by unknown field %q", name, field))
+			}
+		}
+	}
+
+	return result
+}
+
+func (m *GadgetMetadata) validateToppers(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Toppers) {
+		topper := m.Toppers[name]
+
+		// The events are told apart by the name of what sent them
+		if _, ok := m.Tracers[name]; ok {
+			result = multierror.Append(result, fmt.Errorf("topper %q has the same name as a tracer", name))
+		}
+		if _, ok := m.Snapshotters[name]; ok {
+			result = multierror.Append(result, fmt.Errorf("topper %q has the same name as a snapshotter", name))
+		}
+
+		if topper.MapName == "" {
+			result = multierror.Append(result, fmt.Errorf("topper %q is missing mapName", name))
+		}
+
+		// The key and the value are shown as a single event
+		fields := map[string]bool{}
+		for _, structName := range []string{topper.KeyStructName, topper.ValueStructName} {
+			s, ok := m.Structs[structName]
+			if structName != "" && !ok {
+				result = multierror.Append(result, fmt.Errorf("topper %q references unknown struct %q", name, structName))
+			}
+			for _, field := range s.Fields {
+				if fields[field.Name] {
+					result = multierror.Append(result, fmt.Errorf("field %q of topper %q is both in the key and the value", field.Name, name))
+				}
+				fields[field.Name] = true
+			}
+		}
+		if topper.KeyStructName == "" {
+			result = multierror.Append(result, fmt.Errorf("topper %q is missing keyStructName", name))
+		}
+		if topper.ValueStructName == "" {
+			result = multierror.Append(result, fmt.Errorf("topper %q is missing valueStructName", name))
+		}
+
+		for _, sortBy := range topper.SortBy {
+			if field := strings.TrimPrefix(sortBy, "-"); !fields[field] {
+				result = multierror.Append(result, fmt.Errorf("topper %q sorts by unknown field %q", name, field))
+			}
+		}
+
+		if topper.Interval != "" {
+			if interval, err := time.ParseDuration(topper.Interval); err != nil {
+				result = multierror.Append(result, fmt.Errorf("invalid interval of topper %q: %w", name, err))
+			} else if interval <= 0 {
+				result = multierror.Append(result, fmt.Errorf("invalid interval of topper %q: must be positive", name))
+			}
+		}
+
+		if topper.MapName == "" {
+			continue
+		}
+		statsMap, ok := spec.Maps[topper.MapName]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", topper.MapName))
+			continue
+		}
+
+		if err := validateTopperMap(statsMap, topper.KeyStructName, topper.ValueStructName); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
+}
+
+// structHasField tells whether the struct structName has a field with the
+// given name
+func (m *GadgetMetadata) structHasField(structName, name string) bool {
+	for _, field := range m.Structs[structName].Fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTopperMap checks that statsMap is a hash map with the given
+// structures as keys and values. Empty names match any structure.
+func validateTopperMap(statsMap *ebpf.MapSpec, keyStructName, valueStructName string) error {
+	if statsMap.Type != ebpf.Hash && statsMap.Type != ebpf.LRUHash {
+		return fmt.Errorf("map %q has a wrong type, expected: hash or LRU hash, got: %s",
+			statsMap.Name, statsMap.Type.String())
+	}
+
+	if statsMap.Key == nil || statsMap.Value == nil {
+		return fmt.Errorf("map %q does not have BTF information for its keys and values", statsMap.Name)
+	}
+
+	key, ok := statsMap.Key.(*btf.Struct)
+	if !ok {
+		return fmt.Errorf("key of BPF map %q is not a structure", statsMap.Name)
+	}
+	if keyStructName != "" && key.Name != keyStructName {
+		return fmt.Errorf("key of BPF map %q is struct %q, expected %q", statsMap.Name, key.Name, keyStructName)
+	}
+
+	value, ok := statsMap.Value.(*btf.Struct)
+	if !ok {
+		return fmt.Errorf("value of BPF map %q is not a structure", statsMap.Name)
+	}
+	if valueStructName != "" && value.Name != valueStructName {
+		return fmt.Errorf("value of BPF map %q is struct %q, expected %q", statsMap.Name, value.Name, valueStructName)
+	}
+
+	return nil
+}
+
+func (m *GadgetMetadata) validateHistograms(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Histograms) {
+		histogram := m.Histograms[name]
+
+		// The events are told apart by the name of what sent them
+		if _, ok := m.Tracers[name]; ok {
+			result = multierror.Append(result, fmt.Errorf("histogram %q has the same name as a tracer", name))
+		}
+		if _, ok := m.Snapshotters[name]; ok {
+			result = multierror.Append(result, fmt.Errorf("histogram %q has the same name as a snapshotter", name))
+		}
+		if _, ok := m.Toppers[name]; ok {
+			result = multierror.Append(result, fmt.Errorf("histogram %q has the same name as a topper", name))
+		}
+
+		if histogram.Unit == "" {
+			result = multierror.Append(result, fmt.Errorf("histogram %q is missing unit", name))
+		}
+
+		if histogram.MapName == "" {
+			result = multierror.Append(result, fmt.Errorf("histogram %q is missing mapName", name))
+			continue
+		}
+		histogramMap, ok := spec.Maps[histogram.MapName]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", histogram.MapName))
+			continue
+		}
+
+		if err := validateHistogramMap(histogramMap); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
+}
+
+func validateHistogramMap(histogramMap *ebpf.MapSpec) error {
+	switch histogramMap.Type {
+	case ebpf.Array, ebpf.Hash, ebpf.LRUHash:
+	default:
+		return fmt.Errorf("map %q has a wrong type, expected: array, hash or LRU hash, got: %s",
+			histogramMap.Name, histogramMap.Type.String())
+	}
+
+	if histogramMap.Value == nil {
+		return fmt.Errorf("map %q does not have BTF information for its values", histogramMap.Name)
+	}
+
+	if _, _, err := HistogramSlots(histogramMap.Value); err != nil {
+		return fmt.Errorf("value of BPF map %q: %w", histogramMap.Name, err)
+	}
+
+	return nil
+}
+
+// HistogramSlots returns the size and the number of the counters of a
+// histogram, given the type of the values of its map: an array of unsigned
+// integers of 32 or 64 bits, or a structure with such an array as only member.
+func HistogramSlots(typ btf.Type) (uint32, uint32, error) {
+	if s, ok := typ.(*btf.Struct); ok {
+		if len(s.Members) != 1 {
+			return 0, 0, fmt.Errorf("struct %q must have the array of counters as only member", s.Name)
+		}
+		typ = s.Members[0].Type
+	}
+
+	arr, ok := btf.UnderlyingType(typ).(*btf.Array)
+	if !ok {
+		return 0, 0, errors.New("expected an array of counters")
+	}
+
+	counter, ok := btf.UnderlyingType(arr.Type).(*btf.Int)
+	if !ok || counter.Encoding != btf.Unsigned || (counter.Size != 4 && counter.Size != 8) {
+		return 0, 0, errors.New("the counters must be unsigned integers of 32 or 64 bits")
+	}
+
+	return counter.Size, arr.Nelems, nil
+}
+
+func (m *GadgetMetadata) validateMetrics(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Metrics) {
+		metric := m.Metrics[name]
+
+		if !model.IsValidMetricName(model.LabelValue(name)) {
+			result = multierror.Append(result, fmt.Errorf("invalid metric name %q: expected e.g. syscalls_total", name))
+		}
+
+		switch metric.Type {
+		case MetricTypeCounter, MetricTypeGauge:
+		case "":
+			result = multierror.Append(result, fmt.Errorf("metric %q is missing type", name))
+		default:
+			result = multierror.Append(result, fmt.Errorf("invalid type %q of metric %q: expected counter or gauge", metric.Type, name))
+		}
+
+		if metric.MapName == "" {
+			result = multierror.Append(result, fmt.Errorf("metric %q is missing mapName", name))
+			continue
+		}
+		countersMap, ok := spec.Maps[metric.MapName]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", metric.MapName))
+			continue
+		}
+
+		if err := validateCountersMap(countersMap); err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		if _, err := MetricLabelMembers(metric, countersMap.Key.(*btf.Struct)); err != nil {
+			result = multierror.Append(result, fmt.Errorf("metric %q: %w", name, err))
+		}
+		if _, err := MetricValueMember(metric, countersMap.Value); err != nil {
+			result = multierror.Append(result, fmt.Errorf("metric %q: %w", name, err))
+		}
+	}
+
+	return result
+}
+
+// validateCountersMap checks that countersMap is a hash map with structures as
+// keys
+func validateCountersMap(countersMap *ebpf.MapSpec) error {
+	if countersMap.Type != ebpf.Hash && countersMap.Type != ebpf.LRUHash {
+		return fmt.Errorf("map %q has a wrong type, expected: hash or LRU hash, got: %s",
+			countersMap.Name, countersMap.Type.String())
+	}
+
+	if countersMap.Key == nil || countersMap.Value == nil {
+		return fmt.Errorf("map %q does not have BTF information for its keys and values", countersMap.Name)
+	}
+
+	if _, ok := countersMap.Key.(*btf.Struct); !ok {
+		return fmt.Errorf("key of BPF map %q is not a structure", countersMap.Name)
+	}
+
+	return nil
+}
+
+// MetricLabelName returns the name of the label of metric given by the
+// member of the keys of its map
+func MetricLabelName(member string) string {
+	return strings.ReplaceAll(member, ".", "_")
+}
+
+// MetricLabelMembers returns the members of the keys of the map of metric it's
+// labelled by, in the order of its labels. They must be integers, enums or
+// strings.
+func MetricLabelMembers(metric Metric, key *btf.Struct) ([]btf.Member, error) {
+	members := map[string]btf.Member{}
+	for _, member := range FlattenMembers(key, 0) {
+		members[member.Name] = member
+	}
+
+	var result error
+	labelMembers := []btf.Member{}
+	seen := map[string]bool{}
+	for _, label := range metric.Labels {
+		member, ok := members[label]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("label %q isn't a member of struct %q", label, key.Name))
+			continue
+		}
+		labelName := MetricLabelName(label)
+		if !model.LabelName(labelName).IsValid() || strings.HasPrefix(labelName, "__") {
+			result = multierror.Append(result, fmt.Errorf("label %q isn't a valid Prometheus label name", label))
+			continue
+		}
+		if seen[labelName] {
+			result = multierror.Append(result, fmt.Errorf("label %q is given twice", label))
+			continue
+		}
+		seen[labelName] = true
+
+		switch typ := btf.UnderlyingType(member.Type).(type) {
+		case *btf.Int, *btf.Enum:
+		case *btf.Array:
+			if elem, ok := btf.UnderlyingType(typ.Type).(*btf.Int); ok && elem.Size == 1 {
+				break
+			}
+			result = multierror.Append(result, fmt.Errorf("label %q must be an integer, an enum or a string", label))
+			continue
+		default:
+			result = multierror.Append(result, fmt.Errorf("label %q must be an integer, an enum or a string", label))
+			continue
+		}
+		labelMembers = append(labelMembers, member)
+	}
+
+	return labelMembers, result
+}
+
+// MetricValueMember returns the member holding the counter in the values of
+// the map of metric, of type value. When the values are integers, the member
+// is the whole value and doesn't have a name.
+func MetricValueMember(metric Metric, value btf.Type) (btf.Member, error) {
+	isCounter := func(typ btf.Type) bool {
+		i, ok := btf.UnderlyingType(typ).(*btf.Int)
+		return ok && (i.Size == 4 || i.Size == 8) && i.Encoding != btf.Bool
+	}
+
+	s, ok := btf.UnderlyingType(value).(*btf.Struct)
+	if !ok {
+		if metric.ValueField != "" {
+			return btf.Member{}, fmt.Errorf("valueField is set but the values of map %q aren't structures", metric.MapName)
+		}
+		if !isCounter(value) {
+			return btf.Member{}, fmt.Errorf("the values of map %q must be integers of 32 or 64 bits", metric.MapName)
+		}
+		return btf.Member{Type: value}, nil
+	}
+
+	if metric.ValueField == "" {
+		return btf.Member{}, fmt.Errorf("the values of map %q are structures, valueField must be set", metric.MapName)
+	}
+	for _, member := range FlattenMembers(s, 0) {
+		if member.Name != metric.ValueField {
+			continue
+		}
+		if !isCounter(member.Type) {
+			return btf.Member{}, fmt.Errorf("value field %q must be an integer of 32 or 64 bits", metric.ValueField)
+		}
+		return member, nil
+	}
+	return btf.Member{}, fmt.Errorf("value field %q isn't a member of struct %q", metric.ValueField, s.Name)
+}
+
+func (m *GadgetMetadata) validatePrograms(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Programs) {
+		prog, ok := spec.Programs[name]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("program %q not found in eBPF object", name))
+			continue
+		}
+		program := m.Programs[name]
+
+		if len(program.Alternatives) > 0 {
+			if err := validateAlternatives(name, prog, program.Alternatives); err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+
+		if program.Uprobe != nil {
+			if err := validateUprobeTarget(name, prog, program.Uprobe); err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+	}
+
+	return result
+}
+
+func (m *GadgetMetadata) validateMaps(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Maps) {
+		mapSpec, ok := spec.Maps[name]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", name))
+			continue
+		}
+
+		switch {
+		case mapSpec.Type == ebpf.RingBuf || mapSpec.Type == ebpf.PerfEventArray:
+			result = multierror.Append(result, fmt.Errorf("map %q is a %s, its size is given by the bufferPages or bufferSize of its tracer",
+				name, mapSpec.Type))
+		case strings.HasPrefix(name, "."):
+			result = multierror.Append(result, fmt.Errorf("map %q holds the global variables, it can't be resized", name))
+		}
+	}
+
+	return result
+}
+
+// Thresholds of the checks of MapSizeWarnings
+const (
+	// Minimum number of entries suggested for the maps collecting
+	// statistics, like the ones of the toppers
+	MinStatsMapEntries = 1024
+	// Number of CPUs of the nodes the memory used by the per-CPU maps is
+	// estimated for
+	ReferenceCPUs = 64
+	// Memory used by a per-CPU map on a node with ReferenceCPUs above which
+	// it's reported
+	MaxPerCPUMapSize = 64 * 1024 * 1024
+)
+
+// MapSizeWarning tells that a map of the gadget is likely too small, or too
+// big
+type MapSizeWarning struct {
+	MapName string
+	Message string
+}
+
+// MapMaxEntries returns the maximum number of entries of the map of mapSpec,
+// the one given by the metadata, if any, or by the eBPF program
+func (m *GadgetMetadata) MapMaxEntries(mapSpec *ebpf.MapSpec) uint32 {
+	if maxEntries := m.Maps[mapSpec.Name].MaxEntries; maxEntries != 0 {
+		return maxEntries
+	}
+	return mapSpec.MaxEntries
+}
+
+// MapSizeWarnings checks the sizes of the maps of the gadget against what
+// they are expected to hold: the ExpectedEntries of the metadata for any map,
+// at least MinStatsMapEntries for the maps of the toppers, the metrics, the
+// histograms and the aggregation, and at most MaxPerCPUMapSize for the per-CPU
+// maps. The sizes are approximations, the warnings are only suggestions.
+func (m *GadgetMetadata) MapSizeWarnings(spec *ebpf.CollectionSpec) []MapSizeWarning {
+	warnings := []MapSizeWarning{}
+	warn := func(name, format string, args ...any) {
+		warnings = append(warnings, MapSizeWarning{MapName: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// The maps whose entries are statistics, by what uses them
+	statsMaps := map[string]string{}
+	for name, topper := range m.Toppers {
+		statsMaps[topper.MapName] = fmt.Sprintf("topper %q", name)
+	}
+	for name, metric := range m.Metrics {
+		statsMaps[metric.MapName] = fmt.Sprintf("metric %q", name)
+	}
+	for name, histogram := range m.Histograms {
+		statsMaps[histogram.MapName] = fmt.Sprintf("histogram %q", name)
+	}
+	statsMaps[gadgets.AggregationMapName] = "the aggregation"
+
+	for _, name := range sortedKeys(spec.Maps) {
+		mapSpec := spec.Maps[name]
+		maxEntries := m.MapMaxEntries(mapSpec)
+		expected := m.Maps[name].ExpectedEntries
+
+		// The entries beyond the maximum are dropped, or evict the least
+		// recently used ones
+		consequence := "the new entries are dropped once it's full"
+		if mapSpec.Type == ebpf.LRUHash || mapSpec.Type == ebpf.LRUCPUHash {
+			consequence = "the least recently used entries are evicted once it's full"
+		}
+
+		user, isStats := statsMaps[name]
+		switch {
+		case expected != 0 && maxEntries < expected:
+			warn(name, "map can hold %d entries but %d are expected, %s: increase its max_entries or its maxEntries in the metadata",
+				maxEntries, expected, consequence)
+		case expected == 0 && isStats && mapSpec.Type != ebpf.Array && maxEntries < MinStatsMapEntries:
+			warn(name, "map of %s can only hold %d entries, %s: %d or more are suggested, or set its expectedEntries in the metadata",
+				user, maxEntries, consequence, MinStatsMapEntries)
+		}
+
+		switch mapSpec.Type {
+		case ebpf.PerCPUHash, ebpf.LRUCPUHash, ebpf.PerCPUArray:
+			// The values of each CPU are aligned to 8 bytes
+			size := uint64(maxEntries) * uint64((mapSpec.ValueSize+7)&^7) * ReferenceCPUs
+			if size > MaxPerCPUMapSize {
+				warn(name, "per-CPU map uses %s on a node with %d CPUs: reduce its max_entries or use a map shared by the CPUs",
+					units.BytesSize(float64(size)), ReferenceCPUs)
+			}
+		}
+	}
+
+	return warnings
+}
+
+func validateAlternatives(name string, prog *ebpf.ProgramSpec, alternatives []AttachTarget) error {
+	isTracepoint := IsTracepoint(prog)
+	if !isTracepoint && !IsKprobe(prog) {
+		return fmt.Errorf("program %q has alternative targets but it isn't a kprobe or a tracepoint", name)
+	}
+
+	var result error
+
+	for i, alternative := range alternatives {
+		if alternative.Target == "" {
+			result = multierror.Append(result, fmt.Errorf("alternative %d of program %q is missing target", i, name))
+		} else if category, event, _ := strings.Cut(alternative.Target, "/"); isTracepoint && (category == "" || event == "") {
+			result = multierror.Append(result, fmt.Errorf("invalid alternative %q of program %q: expected category/name", alternative.Target, name))
+		}
+
+		var minVersion, maxVersion *version.Version
+		var err error
+		if alternative.MinKernelVersion != "" {
+			minVersion, err = version.ParseGeneric(alternative.MinKernelVersion)
+			if err != nil {
+				result = multierror.Append(result, fmt.Errorf("invalid minimum kernel version %q of program %q: %w", alternative.MinKernelVersion, name, err))
+			}
+		}
+		if alternative.MaxKernelVersion != "" {
+			maxVersion, err = version.ParseGeneric(alternative.MaxKernelVersion)
+			if err != nil {
+				result = multierror.Append(result, fmt.Errorf("invalid maximum kernel version %q of program %q: %w", alternative.MaxKernelVersion, name, err))
+			}
+		}
+		if minVersion != nil && maxVersion != nil && maxVersion.LessThan(minVersion) {
+			result = multierror.Append(result, fmt.Errorf("alternative %q of program %q: maximum kernel version is lower than the minimum one", alternative.Target, name))
+		}
+	}
+
+	return result
+}
+
+func validateUprobeTarget(name string, prog *ebpf.ProgramSpec, target *UprobeTarget) error {
+	if !IsUprobe(prog) {
+		return fmt.Errorf("program %q has a uprobe target but it isn't a uprobe or a uretprobe", name)
+	}
+
+	var result error
+
+	if target.Binary == "" {
+		result = multierror.Append(result, fmt.Errorf("uprobe target of program %q is missing binary", name))
+	} else if _, err := filepath.Match(target.Binary, ""); err != nil {
+		result = multierror.Append(result, fmt.Errorf("invalid binary %q of program %q: %w", target.Binary, name, err))
+	}
+
+	switch {
+	case target.Symbol == "" && target.USDT == "":
+		result = multierror.Append(result, fmt.Errorf("uprobe target of program %q is missing symbol or usdt", name))
+	case target.Symbol != "" && target.USDT != "":
+		result = multierror.Append(result, fmt.Errorf("uprobe target of program %q has both symbol and usdt", name))
+	case target.USDT != "":
+		if provider, probe, _ := strings.Cut(target.USDT, ":"); provider == "" || probe == "" {
+			result = multierror.Append(result, fmt.Errorf("invalid usdt %q of program %q: expected provider:name", target.USDT, name))
+		}
+		if strings.HasPrefix(prog.SectionName, "uretprobe/") {
+			result = multierror.Append(result, fmt.Errorf("program %q is a uretprobe, it can't be attached to a USDT probe", name))
+		}
+	}
+
+	return result
+}
+
+// IsKprobe tells whether prog is a kprobe or a kretprobe
+func IsKprobe(prog *ebpf.ProgramSpec) bool {
+	return prog.Type == ebpf.Kprobe &&
+		(strings.HasPrefix(prog.SectionName, "kprobe/") || strings.HasPrefix(prog.SectionName, "kretprobe/"))
+}
+
+// IsUprobe tells whether prog is a uprobe or a uretprobe
+func IsUprobe(prog *ebpf.ProgramSpec) bool {
+	return prog.Type == ebpf.Kprobe &&
+		(strings.HasPrefix(prog.SectionName, "uprobe/") || strings.HasPrefix(prog.SectionName, "uretprobe/"))
+}
+
+// IsTracepoint tells whether prog is attached to a tracepoint
+func IsTracepoint(prog *ebpf.ProgramSpec) bool {
+	return prog.Type == ebpf.TracePoint && strings.HasPrefix(prog.SectionName, "tracepoint/")
+}
+
+// Matches tells whether the target is tried with the given kernel version,
+// unknown when nil
+func (a AttachTarget) Matches(kernelVersion *version.Version) bool {
+	// Nothing can be ruled out
+	if kernelVersion == nil {
+		return true
+	}
+	if a.MinKernelVersion != "" {
+		minVersion, err := version.ParseGeneric(a.MinKernelVersion)
+		if err != nil || kernelVersion.LessThan(minVersion) {
+			return false
+		}
+	}
+	if a.MaxKernelVersion != "" {
+		maxVersion, err := version.ParseGeneric(a.MaxKernelVersion)
+		if err != nil || maxVersion.LessThan(kernelVersion) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *GadgetMetadata) validateParams(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	// Each constant is set by a single parameter
+	varParams := make(map[string]string, len(m.Params))
+
+	for _, key := range sortedKeys(m.Params) {
+		param := m.Params[key]
+
+		if key == "" || strings.ContainsAny(key, " =,") {
+			result = multierror.Append(result, fmt.Errorf("invalid param key %q", key))
+		}
+
+		if param.VarName == "" {
+			result = multierror.Append(result, fmt.Errorf("param %q is missing varName", key))
+			continue
+		}
+		if strings.HasPrefix(param.VarName, internalConstPrefix) {
+			result = multierror.Append(result, fmt.Errorf("param %q can't set %q: the constants prefixed with %q are set by Inspektor Gadget",
+				key, param.VarName, internalConstPrefix))
+			continue
+		}
+		if other, ok := varParams[param.VarName]; ok {
+			result = multierror.Append(result, fmt.Errorf("params %q and %q set the same constant %q", other, key, param.VarName))
+			continue
+		}
+		varParams[param.VarName] = key
+
+		v, _, err := findConstant(spec, param.VarName)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: %w", key, err))
+			continue
+		}
+		typeHint, err := paramTypeHint(v.Type)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: constant %q: %w", key, param.VarName, err))
+			continue
+		}
+		if param.Type != typeHint {
+			result = multierror.Append(result, fmt.Errorf("param %q has a wrong type, expected: %s, got: %s", key, typeHint, param.Type))
+			continue
+		}
+
+		desc := params.ParamDesc{Key: key, TypeHint: param.Type}
+		if err := desc.Validate(param.DefaultValue); err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q has an invalid defaultValue: %w", key, err))
+		}
+	}
+
+	return result
+}
+
+// findConstant returns the const volatile variable of the eBPF program with
+// the given name and its value.
+func findConstant(spec *ebpf.CollectionSpec, name string) (*btf.Var, []byte, error) {
+	for mapName, m := range spec.Maps {
+		if !strings.HasPrefix(mapName, ".rodata") {
+			continue
+		}
+		ds, ok := m.Value.(*btf.Datasec)
+		if !ok {
+			continue
+		}
+
+		for _, vsi := range ds.Vars {
+			v, ok := vsi.Type.(*btf.Var)
+			if !ok || v.Name != name {
+				continue
+			}
+
+			var value []byte
+			if len(m.Contents) == 1 {
+				if b, ok := m.Contents[0].Value.([]byte); ok && uint32(len(b)) >= vsi.Offset+vsi.Size {
+					value = b[vsi.Offset : vsi.Offset+vsi.Size]
+				}
+			}
+			return v, value, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("constant %q not found in eBPF object: it must be declared as const volatile", name)
+}
+
+// paramTypeHint returns the type of the parameters setting a constant of the
+// given type. Only the numbers and the booleans are supported.
+func paramTypeHint(typ btf.Type) (params.TypeHint, error) {
+	i, ok := btf.UnderlyingType(typ).(*btf.Int)
+	if !ok {
+		return "", errors.New("only integers and booleans are supported")
+	}
+
+	switch i.Encoding {
+	case btf.Bool:
+		return params.TypeBool, nil
+	case btf.Signed:
+		switch i.Size {
+		case 1:
+			return params.TypeInt8, nil
+		case 2:
+			return params.TypeInt16, nil
+		case 4:
+			return params.TypeInt32, nil
+		case 8:
+			return params.TypeInt64, nil
+		}
+	default:
+		switch i.Size {
+		case 1:
+			return params.TypeUint8, nil
+		case 2:
+			return params.TypeUint16, nil
+		case 4:
+			return params.TypeUint32, nil
+		case 8:
+			return params.TypeUint64, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported integer of %d bytes", i.Size)
+}
+
+// formatConstant returns the value of a constant of the given type as the
+// value of a parameter.
+func formatConstant(typeHint params.TypeHint, value []byte, byteOrder binary.ByteOrder) string {
+	switch typeHint {
+	case params.TypeBool:
+		return strconv.FormatBool(value[0] != 0)
+	case params.TypeInt8:
+		return strconv.FormatInt(int64(int8(value[0])), 10)
+	case params.TypeInt16:
+		return strconv.FormatInt(int64(int16(byteOrder.Uint16(value))), 10)
+	case params.TypeInt32:
+		return strconv.FormatInt(int64(int32(byteOrder.Uint32(value))), 10)
+	case params.TypeInt64:
+		return strconv.FormatInt(int64(byteOrder.Uint64(value)), 10)
+	case params.TypeUint8:
+		return strconv.FormatUint(uint64(value[0]), 10)
+	case params.TypeUint16:
+		return strconv.FormatUint(uint64(byteOrder.Uint16(value)), 10)
+	case params.TypeUint32:
+		return strconv.FormatUint(uint64(byteOrder.Uint32(value)), 10)
+	case params.TypeUint64:
+		return strconv.FormatUint(byteOrder.Uint64(value), 10)
+	}
+	return ""
+}
+
+// ParamDescs returns the descriptions of the parameters of the gadget, sorted
+// by key.
+func (m *GadgetMetadata) ParamDescs() params.ParamDescs {
+	descs := make(params.ParamDescs, 0, len(m.Params))
+	for _, key := range sortedKeys(m.Params) {
+		param := m.Params[key]
+		descs = append(descs, &params.ParamDesc{
+			Key:          key,
+			Description:  param.Description,
+			DefaultValue: param.DefaultValue,
+			TypeHint:     param.Type,
+		})
+	}
+	return descs
+}
+
+// IsIterator tells if the program is a BPF iterator, e.g. in an iter/task
+// section.
+func IsIterator(prog *ebpf.ProgramSpec) bool {
+	return prog.Type == ebpf.Tracing && prog.AttachType == ebpf.AttachTraceIter
+}
+
+func validateTraceMap(traceMap *ebpf.MapSpec) error {
+	if traceMap.Type != ebpf.RingBuf && traceMap.Type != ebpf.PerfEventArray {
+		return fmt.Errorf("map %q has a wrong type, expected: ringbuf or perf event array, got: %s",
+			traceMap.Name, traceMap.Type.String())
+	}
+
+	if traceMap.Value == nil {
+		return fmt.Errorf("map %q does not have BTF information its value", traceMap.Name)
+	}
+
+	if _, ok := traceMap.Value.(*btf.Struct); !ok {
+		return fmt.Errorf("value of BPF map %q is not a structure", traceMap.Name)
+	}
+
+	return nil
+}
+
+func (m *GadgetMetadata) validateStructs(spec *ebpf.CollectionSpec) error {
+	var result error
+
+	tags := getMemberTags(spec.Types)
+
+	for name, mapStruct := range m.Structs {
+		var btfStruct *btf.Struct
+		if err := spec.Types.TypeByName(name, &btfStruct); err != nil {
+			result = multierror.Append(result, fmt.Errorf("looking for struct %q in eBPF object: %w", name, err))
+			continue
+		}
+
+		mapStructFields := make(map[string]Field, len(mapStruct.Fields))
+		for _, f := range mapStruct.Fields {
+			mapStructFields[f.Name] = f
+		}
+
+		// The fields deeper than MaxDepth are accepted, they could have been
+		// added by hand
+		btfStructFields := map[string]btf.Member{}
+		for _, m := range FlattenMembers(btfStruct, 0) {
+			btfStructFields[m.Name] = m
+		}
+
+		for _, fieldName := range sortedKeys(mapStructFields) {
+			member, ok := btfStructFields[fieldName]
+			if !ok {
+				result = multierror.Append(result, fmt.Errorf("field %q not found in eBPF struct %q", fieldName, name))
+				continue
+			}
+
+			attrs := mapStructFields[fieldName].Attributes
+			if err := validateFieldKind(attrs.Kind, member.Type); err != nil {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q has invalid kind %q: %w", fieldName, name, attrs.Kind, err))
+			}
+			if _, isEnum := btf.UnderlyingType(member.Type).(*btf.Enum); attrs.RawValue && !isEnum {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q has rawValue set but isn't an enum", fieldName, name))
+			}
+			if err := validateFieldUnit(attrs, member.Type); err != nil {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q has invalid unit %q: %w", fieldName, name, attrs.Unit, err))
+			}
+			if err := validateFieldFlags(mapStructFields[fieldName], member.Type); err != nil {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q has invalid flags: %w", fieldName, name, err))
+			}
+			if err := applyFieldTags(&Field{}, tags.find(btfStruct, fieldName)); err != nil {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q has invalid decl tags: %w", fieldName, name, err))
+			}
+			if IsStackKind(attrs.Kind) {
+				if err := validateStacksMap(spec); err != nil {
+					result = multierror.Append(result, fmt.Errorf("field %q of struct %q has kind %q: %w", fieldName, name, attrs.Kind, err))
+				}
+			}
+		}
+
+		if err := validateEnrichment(mapStruct, btfStruct); err != nil {
+			result = multierror.Append(result, fmt.Errorf("enrichment of struct %q: %w", name, err))
+		}
+	}
+
+	return result
+}
+
+func validateEnrichment(s Struct, btfStruct *btf.Struct) error {
+	enrichment := s.Enrichment
+	if enrichment.Disabled {
+		if enrichment.MntNs != "" || enrichment.NetNs != "" || enrichment.KeepRaw {
+			return errors.New("mntns, netns and keepRaw can't be set when it's disabled")
+		}
+		return nil
+	}
+	if enrichment.MntNs != "" && enrichment.MntNs == enrichment.NetNs {
+		return fmt.Errorf("member %q can't give both the mount and the network namespaces", enrichment.MntNs)
+	}
+	_, _, err := EnrichmentMembers(s, btfStruct)
+	return err
+}
+
+// EnrichmentMembers returns the members of btfStruct giving the mount and the
+// network namespaces its events are enriched from, as told by the enrichment
+// of s, or nil if it doesn't have them. The namespaces are inode numbers, of
+// 32 or 64 bits.
+func EnrichmentMembers(s Struct, btfStruct *btf.Struct) (mntns, netns *btf.Member, err error) {
+	if s.Enrichment.Disabled {
+		return nil, nil, nil
+	}
 
-	if m.Name == "" {
-		result = multierror.Append(result, errors.New("gadget name is required"))
+	members := FlattenMembers(btfStruct, 0)
+	find := func(name, typeName string) (*btf.Member, error) {
+		for i := range members {
+			member := &members[i]
+			if name != "" && member.Name != name || name == "" && member.Type.TypeName() != typeName {
+				continue
+			}
+			intM, ok := btf.UnderlyingType(member.Type).(*btf.Int)
+			if !ok || intM.Encoding != btf.Unsigned || (intM.Size != 4 && intM.Size != 8) {
+				return nil, fmt.Errorf("member %q must be a 32 or 64 bits unsigned integer", member.Name)
+			}
+			return member, nil
+		}
+		if name != "" {
+			return nil, fmt.Errorf("member %q not found in eBPF struct %q", name, btfStruct.Name)
+		}
+		return nil, nil
 	}
 
-	if err := m.validateTracers(spec); err != nil {
-		result = multierror.Append(result, err)
+	if mntns, err = find(s.Enrichment.MntNs, gadgets.MntNsIdTypeName); err != nil {
+		return nil, nil, err
 	}
+	if netns, err = find(s.Enrichment.NetNs, gadgets.NetNsIdTypeName); err != nil {
+		return nil, nil, err
+	}
+	return mntns, netns, nil
+}
 
-	if err := m.validateStructs(spec); err != nil {
-		result = multierror.Append(result, err)
+// ReplacedByEnrichment returns the names of the members of btfStruct that
+// aren't added as fields, their namespaces being shown by the container and
+// pod columns instead.
+func ReplacedByEnrichment(s Struct, btfStruct *btf.Struct) map[string]bool {
+	replaced := map[string]bool{}
+	if s.Enrichment.KeepRaw {
+		return replaced
+	}
+	// The wrong enrichments are reported by Validate
+	mntns, netns, _ := EnrichmentMembers(s, btfStruct)
+	if mntns != nil {
+		replaced[mntns.Name] = true
+	}
+	if netns != nil {
+		replaced[netns.Name] = true
 	}
+	return replaced
+}
 
-	return result
+// validateStacksMap checks that the stacks referenced by the fields of the
+// stack kinds can be read from the gadget_stacks map.
+func validateStacksMap(spec *ebpf.CollectionSpec) error {
+	m, ok := spec.Maps[gadgets.StacksMapName]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", gadgets.StacksMapName)
+	}
+	if m.Type != ebpf.StackTrace {
+		return fmt.Errorf("map %q has a wrong type, expected: %s, got: %s", gadgets.StacksMapName, ebpf.StackTrace, m.Type)
+	}
+	return nil
 }
 
-func (m *GadgetMetadata) validateTracers(spec *ebpf.CollectionSpec) error {
-	var result error
+// validateFieldUnit checks that the unit of the field is known and that its
+// values are numbers in that unit.
+func validateFieldUnit(attrs FieldAttributes, typ btf.Type) error {
+	switch attrs.Unit {
+	case UnitNone:
+		return nil
+	case UnitBytes, UnitNanoseconds, UnitMicroseconds, UnitMilliseconds, UnitPackets:
+	default:
+		return errors.New("unknown unit")
+	}
 
-	// Temporary limitation
-	if len(m.Tracers) > 1 {
-		result = multierror.Append(result, errors.New("only one tracer is allowed"))
+	if attrs.Kind != KindNone && KindUnit(attrs.Kind) != attrs.Unit {
+		return fmt.Errorf("the values of kind %q aren't in %s", attrs.Kind, attrs.Unit)
+	}
+	switch t := btf.UnderlyingType(typ).(type) {
+	case *btf.Int:
+		if t.Encoding == btf.Bool || t.Encoding == btf.Char {
+			return errors.New("expected a number")
+		}
+	case *btf.Float:
+	default:
+		return errors.New("expected a number")
 	}
+	return nil
+}
 
-	for name, tracer := range m.Tracers {
-		if tracer.MapName == "" {
-			result = multierror.Append(result, fmt.Errorf("tracer %q is missing mapName", name))
+// validateFieldKind checks that a member of the given type can hold a value of
+// the given kind.
+func validateFieldKind(kind FieldKind, typ btf.Type) error {
+	switch kind {
+	case KindNone:
+		return nil
+	case KindL3Endpoint:
+		if s, ok := btf.UnderlyingType(typ).(*btf.Struct); ok && s.Name == gadgets.L3EndpointTypeName {
+			return nil
+		}
+		switch t := btf.UnderlyingType(typ).(type) {
+		case *btf.Int:
+			if t.Size == 4 && t.Encoding != btf.Bool {
+				return nil
+			}
+		case *btf.Array:
+			if i, ok := btf.UnderlyingType(t.Type).(*btf.Int); ok && i.Size == 1 && t.Nelems == 16 {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected a %s, a 32 bits integer or an array of 16 bytes", gadgets.L3EndpointTypeName)
+	case KindL4Endpoint:
+		if s, ok := btf.UnderlyingType(typ).(*btf.Struct); ok && s.Name == gadgets.L4EndpointTypeName {
+			return nil
+		}
+		return fmt.Errorf("expected a %s", gadgets.L4EndpointTypeName)
+	case KindErrno, KindTimestampNs, KindBytes, KindDuration:
+		if i, ok := btf.UnderlyingType(typ).(*btf.Int); ok && i.Encoding != btf.Bool {
+			return nil
 		}
+		return errors.New("expected an integer")
+	case KindReturnValue:
+		if i, ok := btf.UnderlyingType(typ).(*btf.Int); ok && i.Encoding == btf.Signed {
+			return nil
+		}
+		return errors.New("expected a signed integer")
+	case KindKernelStack, KindUserStack:
+		if i, ok := btf.UnderlyingType(typ).(*btf.Int); ok && i.Size == 4 && i.Encoding != btf.Bool {
+			return nil
+		}
+		return errors.New("expected a 32 bits integer")
+	}
 
-		if tracer.StructName == "" {
-			result = multierror.Append(result, fmt.Errorf("tracer %q is missing structName", name))
+	return errors.New("unknown kind")
+}
+
+// validateFieldFlags checks that the flags of the field fit in a member of the
+// given type.
+func validateFieldFlags(field Field, typ btf.Type) error {
+	if field.FlagsEnum {
+		if len(field.Flags) != 0 {
+			return errors.New("flags and flagsEnum can't be used together")
+		}
+		if _, ok := btf.UnderlyingType(typ).(*btf.Enum); !ok {
+			return errors.New("flagsEnum is only supported for enums")
 		}
+		return nil
+	}
 
-		_, ok := m.Structs[tracer.StructName]
-		if !ok {
-			result = multierror.Append(result, fmt.Errorf("tracer %q references unknown struct %q", name, tracer.StructName))
+	if len(field.Flags) == 0 {
+		return nil
+	}
+
+	var size uint32
+	switch t := btf.UnderlyingType(typ).(type) {
+	case *btf.Int:
+		if t.Encoding == btf.Bool {
+			return errors.New("expected an integer or an enum")
 		}
+		size = t.Size
+	case *btf.Enum:
+		size = t.Size
+	default:
+		return errors.New("expected an integer or an enum")
+	}
 
-		ebpfm, ok := spec.Maps[tracer.MapName]
-		if !ok {
-			result = multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", tracer.MapName))
+	var result error
+	for _, flag := range field.Flags {
+		if flag.Name == "" {
+			result = multierror.Append(result, fmt.Errorf("flag with value %#x is missing name", flag.Value))
 			continue
 		}
-
-		if err := validateTraceMap(ebpfm); err != nil {
-			result = multierror.Append(result, err)
+		if bits.Len64(flag.Value) > int(size)*8 || bits.Len64(flag.Mask) > int(size)*8 {
+			result = multierror.Append(result, fmt.Errorf("flag %q doesn't fit in %d bits", flag.Name, size*8))
+			continue
+		}
+		if flag.Mask != 0 && flag.Value&^flag.Mask != 0 {
+			result = multierror.Append(result, fmt.Errorf("flag %q has bits out of its mask %#x", flag.Name, flag.Mask))
 		}
 	}
-
 	return result
 }
 
-func validateTraceMap(traceMap *ebpf.MapSpec) error {
-	if traceMap.Type != ebpf.RingBuf && traceMap.Type != ebpf.PerfEventArray {
-		return fmt.Errorf("map %q has a wrong type, expected: ringbuf or perf event array, got: %s",
-			traceMap.Name, traceMap.Type.String())
+// fieldKindFromType returns the kind of the values of the given type, given by
+// the name of the type or of one of its typedefs.
+func fieldKindFromType(typ btf.Type) FieldKind {
+	for {
+		switch typ.TypeName() {
+		case gadgets.L3EndpointTypeName:
+			return KindL3Endpoint
+		case gadgets.L4EndpointTypeName:
+			return KindL4Endpoint
+		case gadgets.ErrnoTypeName:
+			return KindErrno
+		case gadgets.TimestampTypeName:
+			return KindTimestampNs
+		case gadgets.BytesTypeName:
+			return KindBytes
+		case gadgets.DurationTypeName:
+			return KindDuration
+		case gadgets.KernelStackTypeName:
+			return KindKernelStack
+		case gadgets.UserStackTypeName:
+			return KindUserStack
+		}
+
+		switch t := typ.(type) {
+		case *btf.Typedef:
+			typ = t.Type
+		case *btf.Const:
+			typ = t.Type
+		case *btf.Volatile:
+			typ = t.Type
+		default:
+			return KindNone
+		}
 	}
+}
 
-	if traceMap.Value == nil {
-		return fmt.Errorf("map %q does not have BTF information its value", traceMap.Name)
+// returnValueNames are the names of the members holding return values, whose
+// negative values are error numbers, when their type doesn't tell it
+var returnValueNames = map[string]bool{
+	"ret":   true,
+	"err":   true,
+	"errno": true,
+}
+
+// fieldKindFromMember returns the kind of the values of the member, given by
+// its type or, for the signed integers named like return values, e.g. ret,
+// KindReturnValue.
+func fieldKindFromMember(member btf.Member) FieldKind {
+	if kind := fieldKindFromType(member.Type); kind != KindNone {
+		return kind
+	}
+	name := member.Name[strings.LastIndex(member.Name, ".")+1:]
+	if i, ok := btf.UnderlyingType(member.Type).(*btf.Int); ok && i.Encoding == btf.Signed && returnValueNames[name] {
+		return KindReturnValue
 	}
+	return KindNone
+}
 
-	if _, ok := traceMap.Value.(*btf.Struct); !ok {
-		return fmt.Errorf("value of BPF map %q is not a structure", traceMap.Name)
+// IsEventTimestamp tells whether the given member is the timestamp of the
+// event: a gadget_timestamp_t named "timestamp". It's shown in the built-in
+// timestamp column instead of in a field of its own.
+func IsEventTimestamp(member btf.Member) bool {
+	if member.Name != "timestamp" || fieldKindFromType(member.Type) != KindTimestampNs {
+		return false
 	}
+	intM, ok := btf.UnderlyingType(member.Type).(*btf.Int)
+	return ok && intM.Size == 8
+}
 
-	return nil
+// getKindColumnSize returns the width of the columns showing values of the
+// given kind, 0 if it depends on the type of the values.
+func getKindColumnSize(kind FieldKind) uint {
+	switch kind {
+	case KindTimestampNs:
+		// e.g. 2006-01-02T15:04:05.000000000Z07:00
+		return 35
+	case KindBytes, KindDuration:
+		return 12
+	case KindKernelStack, KindUserStack:
+		return 40
+	}
+	return 0
 }
 
-func (m *GadgetMetadata) validateStructs(spec *ebpf.CollectionSpec) error {
-	var result error
+// GetKindColumnTemplate returns the template of the columns showing values of
+// the given kind, or "" if there is none. The endpoints take the ones of the
+// built-in gadgets.
+func GetKindColumnTemplate(kind FieldKind) string {
+	switch kind {
+	case KindL3Endpoint:
+		return "ipaddr"
+	case KindL4Endpoint:
+		return "ipaddrport"
+	case KindErrno, KindReturnValue:
+		return "errno"
+	}
+	return ""
+}
 
-	for name, mapStruct := range m.Structs {
+// RemoveStaleFields removes the fields that aren't members of their struct in
+// the eBPF object anymore and returns their names, e.g. "event.pid". The
+// structs not found in the object are kept, Validate reports them.
+func (m *GadgetMetadata) RemoveStaleFields(spec *ebpf.CollectionSpec) []string {
+	removed := []string{}
+	for _, name := range sortedKeys(m.Structs) {
 		var btfStruct *btf.Struct
-		if err := spec.Types.TypeByName(name, &btfStruct); err != nil {
-			result = multierror.Append(result, fmt.Errorf("looking for struct %q in eBPF object: %w", name, err))
+		if spec.Types == nil || spec.Types.TypeByName(name, &btfStruct) != nil {
 			continue
 		}
-
-		mapStructFields := make(map[string]Field, len(mapStruct.Fields))
-		for _, f := range mapStruct.Fields {
-			mapStructFields[f.Name] = f
-		}
-
-		btfStructFields := make(map[string]btf.Member, len(btfStruct.Members))
-		for _, m := range btfStruct.Members {
-			btfStructFields[m.Name] = m
+		members := map[string]struct{}{}
+		for _, member := range FlattenMembers(btfStruct, 0) {
+			members[member.Name] = struct{}{}
 		}
 
-		for fieldName := range mapStructFields {
-			if _, ok := btfStructFields[fieldName]; !ok {
-				result = multierror.Append(result, fmt.Errorf("field %q not found in eBPF struct %q", fieldName, name))
+		gadgetStruct := m.Structs[name]
+		fields := []Field{}
+		for _, field := range gadgetStruct.Fields {
+			if _, ok := members[field.Name]; !ok {
+				removed = append(removed, name+"."+field.Name)
+				continue
 			}
+			fields = append(fields, field)
 		}
+		gadgetStruct.Fields = fields
+		m.Structs[name] = gadgetStruct
 	}
-
-	return result
+	return removed
 }
 
 // Populate fills the metadata from its ebpf spec
 func (m *GadgetMetadata) Populate(spec *ebpf.CollectionSpec) error {
+	if m.SchemaVersion == 0 {
+		m.SchemaVersion = CurrentSchemaVersion
+	}
+
 	if m.Name == "" {
 		m.Name = "TODO: Fill the gadget name"
 	}
@@ -238,6 +2025,22 @@ func (m *GadgetMetadata) Populate(spec *ebpf.CollectionSpec) error {
 		return fmt.Errorf("handling trace maps: %w", err)
 	}
 
+	if err := m.populateSnapshotters(spec); err != nil {
+		return fmt.Errorf("handling snapshotters: %w", err)
+	}
+
+	if err := m.populateToppers(spec); err != nil {
+		return fmt.Errorf("handling toppers: %w", err)
+	}
+
+	if err := m.populateHistograms(spec); err != nil {
+		return fmt.Errorf("handling histograms: %w", err)
+	}
+
+	if err := m.populateParams(spec); err != nil {
+		return fmt.Errorf("handling params: %w", err)
+	}
+
 	return nil
 }
 
@@ -250,7 +2053,38 @@ func getUnderlyingType(tf *btf.Typedef) (btf.Type, error) {
 	}
 }
 
+// isCharArray tells if typ is an array of char, like char comm[16], holding a
+// NUL-terminated string.
+func isCharArray(typ btf.Type) bool {
+	arr, ok := btf.UnderlyingType(typ).(*btf.Array)
+	if !ok {
+		return false
+	}
+	// Only skip the qualifiers: the arrays of __u8 hold bytes, not strings
+	elem := arr.Type
+	for {
+		switch qualifier := elem.(type) {
+		case *btf.Const:
+			elem = qualifier.Type
+			continue
+		case *btf.Volatile:
+			elem = qualifier.Type
+			continue
+		}
+		break
+	}
+	elemInt, ok := elem.(*btf.Int)
+	return ok && elemInt.Size == 1 && (elemInt.Encoding == btf.Char || elemInt.Name == "char")
+}
+
 func getColumnSize(typ btf.Type) uint {
+	if isCharArray(typ) {
+		if n := uint(btf.UnderlyingType(typ).(*btf.Array).Nelems); n < MaxStringColumnWidth {
+			return n
+		}
+		return MaxStringColumnWidth
+	}
+
 	switch typedMember := typ.(type) {
 	case *btf.Int:
 		switch typedMember.Encoding {
@@ -282,6 +2116,17 @@ func getColumnSize(typ btf.Type) uint {
 		case btf.Char:
 			return columns.MaxCharsChar
 		}
+	case *btf.Enum:
+		// Wide enough for the names of its values
+		width := uint(0)
+		for _, v := range typedMember.Values {
+			if uint(len(v.Name)) > width {
+				width = uint(len(v.Name))
+			}
+		}
+		if width != 0 {
+			return width
+		}
 	case *btf.Typedef:
 		typ, _ := getUnderlyingType(typedMember)
 		return getColumnSize(typ)
@@ -291,12 +2136,37 @@ func getColumnSize(typ btf.Type) uint {
 }
 
 func (m *GadgetMetadata) populateTracers(spec *ebpf.CollectionSpec) error {
-	traceMap := getTracerMapFromeBPF(spec)
-	if traceMap == nil {
+	traceMaps := getTracerMapsFromeBPF(spec)
+	if len(traceMaps) == 0 {
 		log.Debug("No trace map found")
-		return nil
 	}
 
+	tags := getMemberTags(spec.Types)
+	for _, traceMap := range traceMaps {
+		if err := m.populateTracer(traceMap, tags); err != nil {
+			return err
+		}
+	}
+
+	// The structures of the variants can't be found from the code
+	for _, name := range sortedKeys(m.Tracers) {
+		variants := m.Tracers[name].Variants
+		for _, value := range sortedKeys(variants) {
+			structName := variants[value]
+			var btfStruct *btf.Struct
+			if err := spec.Types.TypeByName(structName, &btfStruct); err != nil {
+				return fmt.Errorf("looking for struct %q of tracer %q: %w", structName, name, err)
+			}
+			if err := m.populateStruct(btfStruct, tags); err != nil {
+				return fmt.Errorf("populating struct: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *GadgetMetadata) populateTracer(traceMap *ebpf.MapSpec, tags memberTags) error {
 	if err := validateTraceMap(traceMap); err != nil {
 		return fmt.Errorf("trace map is invalid: %w", err)
 	}
@@ -324,15 +2194,239 @@ func (m *GadgetMetadata) populateTracers(spec *ebpf.CollectionSpec) error {
 		log.Debugf("Tracer using map %q already defined, skipping", traceMap.Name)
 	}
 
-	if err := m.populateStruct(traceMapStruct); err != nil {
+	if err := m.populateStruct(traceMapStruct, tags); err != nil {
 		return fmt.Errorf("populating struct: %w", err)
 	}
 
 	return nil
 }
 
-// getGadgetIdentByPrefix returns the string generated by GADGET_ macros.
-func getGadgetIdentByPrefix(spec *ebpf.CollectionSpec, prefix string) string {
+// populateSnapshotters adds the snapshotters marked with GADGET_SNAPSHOTTER()
+// and their programs, if they aren't defined yet.
+func (m *GadgetMetadata) populateSnapshotters(spec *ebpf.CollectionSpec) error {
+	for _, ident := range getGadgetIdentsByPrefix(spec, snapshotterPrefix) {
+		parts := strings.Split(ident, snapshotterSeparator)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid snapshotter %q: expected a name, a struct and a program", ident)
+		}
+		name, structName, progName := parts[0], parts[1], parts[2]
+
+		var btfStruct *btf.Struct
+		if err := spec.Types.TypeByName(structName, &btfStruct); err != nil {
+			return fmt.Errorf("looking for struct %q of snapshotter %q: %w", structName, name, err)
+		}
+
+		prog, ok := spec.Programs[progName]
+		if !ok {
+			return fmt.Errorf("program %q of snapshotter %q not found", progName, name)
+		}
+		if !IsIterator(prog) {
+			return fmt.Errorf("program %q of snapshotter %q is not an iterator", progName, name)
+		}
+
+		if m.Snapshotters == nil {
+			m.Snapshotters = make(map[string]Snapshotter)
+		}
+
+		snapshotter, defined := m.Snapshotters[name]
+		if !defined {
+			log.Debugf("Adding snapshotter %q", name)
+			snapshotter.StructName = btfStruct.Name
+		}
+
+		found := false
+		for _, p := range snapshotter.Programs {
+			if p == progName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Debugf("Adding program %q to snapshotter %q", progName, name)
+			snapshotter.Programs = append(snapshotter.Programs, progName)
+		}
+
+		if err := m.populateStruct(btfStruct, getMemberTags(spec.Types)); err != nil {
+			return fmt.Errorf("populating struct: %w", err)
+		}
+
+		if !defined {
+			snapshotter.SortBy = defaultSortBy(m.Structs[snapshotter.StructName].Fields)
+		}
+		m.Snapshotters[name] = snapshotter
+	}
+
+	return nil
+}
+
+// populateToppers adds the toppers marked with GADGET_TOPPER(), if they aren't
+// defined yet.
+func (m *GadgetMetadata) populateToppers(spec *ebpf.CollectionSpec) error {
+	for _, ident := range getGadgetIdentsByPrefix(spec, topperPrefix) {
+		parts := strings.Split(ident, snapshotterSeparator)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid topper %q: expected a name and a map", ident)
+		}
+		name, mapName := parts[0], parts[1]
+
+		statsMap, ok := spec.Maps[mapName]
+		if !ok {
+			return fmt.Errorf("map %q of topper %q not found", mapName, name)
+		}
+		if err := validateTopperMap(statsMap, "", ""); err != nil {
+			return fmt.Errorf("map of topper %q is invalid: %w", name, err)
+		}
+		key := statsMap.Key.(*btf.Struct)
+		value := statsMap.Value.(*btf.Struct)
+
+		if m.Toppers == nil {
+			m.Toppers = make(map[string]Topper)
+		}
+
+		topper, found := m.Toppers[name]
+		if !found {
+			log.Debugf("Adding topper %q", name)
+			topper = Topper{
+				MapName:         mapName,
+				KeyStructName:   key.Name,
+				ValueStructName: value.Name,
+			}
+		} else {
+			log.Debugf("Topper %q already defined, skipping", name)
+		}
+
+		tags := getMemberTags(spec.Types)
+		if err := m.populateStruct(key, tags); err != nil {
+			return fmt.Errorf("populating struct: %w", err)
+		}
+		if err := m.populateStruct(value, tags); err != nil {
+			return fmt.Errorf("populating struct: %w", err)
+		}
+
+		if !found {
+			topper.SortBy = defaultSortBy(m.Structs[topper.ValueStructName].Fields)
+		}
+		m.Toppers[name] = topper
+	}
+
+	return nil
+}
+
+// defaultSortBy returns the fields to sort the entries by when the metadata
+// doesn't tell them: the counters, like "count" and "bytes", in descending
+// order.
+func defaultSortBy(fields []Field) []string {
+	var sortBy []string
+	for _, field := range fields {
+		name := field.Name[strings.LastIndex(field.Name, ".")+1:]
+		if name == "count" || name == "bytes" ||
+			strings.HasSuffix(name, "_count") || strings.HasSuffix(name, "_bytes") ||
+			field.Attributes.GetUnit() == UnitBytes {
+			sortBy = append(sortBy, "-"+field.Name)
+		}
+	}
+	return sortBy
+}
+
+// populateHistograms adds the histograms marked with GADGET_HISTOGRAM(), if
+// they aren't defined yet. Their unit has to be filled by hand.
+func (m *GadgetMetadata) populateHistograms(spec *ebpf.CollectionSpec) error {
+	for _, ident := range getGadgetIdentsByPrefix(spec, histogramPrefix) {
+		parts := strings.Split(ident, snapshotterSeparator)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid histogram %q: expected a name and a map", ident)
+		}
+		name, mapName := parts[0], parts[1]
+
+		histogramMap, ok := spec.Maps[mapName]
+		if !ok {
+			return fmt.Errorf("map %q of histogram %q not found", mapName, name)
+		}
+		if err := validateHistogramMap(histogramMap); err != nil {
+			return fmt.Errorf("map of histogram %q is invalid: %w", name, err)
+		}
+
+		if m.Histograms == nil {
+			m.Histograms = make(map[string]Histogram)
+		}
+
+		if _, found := m.Histograms[name]; found {
+			log.Debugf("Histogram %q already defined, skipping", name)
+			continue
+		}
+		log.Debugf("Adding histogram %q", name)
+		m.Histograms[name] = Histogram{
+			MapName: mapName,
+			Unit:    "TODO: Fill the unit",
+		}
+	}
+
+	return nil
+}
+
+// populateParams adds the parameters setting the constants marked with
+// GADGET_PARAM(), if they aren't defined yet. Their key is the name of the
+// constant with dashes instead of underscores, e.g. "targ-pid", and their
+// default value the one of the constant in the eBPF program.
+func (m *GadgetMetadata) populateParams(spec *ebpf.CollectionSpec) error {
+	for _, varName := range getGadgetIdentsByPrefix(spec, paramPrefix) {
+		if strings.HasPrefix(varName, internalConstPrefix) {
+			return fmt.Errorf("constant %q can't be a param: the constants prefixed with %q are set by Inspektor Gadget",
+				varName, internalConstPrefix)
+		}
+
+		v, value, err := findConstant(spec, varName)
+		if err != nil {
+			return err
+		}
+		typeHint, err := paramTypeHint(v.Type)
+		if err != nil {
+			return fmt.Errorf("constant %q: %w", varName, err)
+		}
+
+		if m.Params == nil {
+			m.Params = make(map[string]Param)
+		}
+
+		found := false
+		for _, p := range m.Params {
+			if p.VarName == varName {
+				found = true
+				break
+			}
+		}
+		if found {
+			log.Debugf("Param setting %q already defined, skipping", varName)
+			continue
+		}
+
+		byteOrder := spec.ByteOrder
+		if byteOrder == nil {
+			byteOrder = binary.LittleEndian
+		}
+		defaultValue := ""
+		if value != nil {
+			defaultValue = formatConstant(typeHint, value, byteOrder)
+		}
+
+		key := strings.ReplaceAll(varName, "_", "-")
+		log.Debugf("Adding param %q", key)
+		m.Params[key] = Param{
+			VarName:      varName,
+			Type:         typeHint,
+			DefaultValue: defaultValue,
+			Description:  "TODO: Fill param description",
+		}
+	}
+
+	return nil
+}
+
+// getGadgetIdentsByPrefix returns the strings generated by GADGET_ macros,
+// sorted.
+func getGadgetIdentsByPrefix(spec *ebpf.CollectionSpec, prefix string) []string {
+	var idents []string
+
 	it := spec.Types.Iterate()
 	for it.Next() {
 		v, ok := it.Type.(*btf.Var)
@@ -341,45 +2435,111 @@ func getGadgetIdentByPrefix(spec *ebpf.CollectionSpec, prefix string) string {
 		}
 
 		if strings.HasPrefix(v.Name, prefix) {
-			return strings.TrimPrefix(v.Name, prefix)
+			idents = append(idents, strings.TrimPrefix(v.Name, prefix))
 		}
 	}
 
-	return ""
+	sort.Strings(idents)
+	return idents
+}
+
+// getTracerMapsFromeBPF returns the tracer maps from the eBPF object, the
+// maps marked with GADGET_TRACE_MAP(), sorted by name.
+func getTracerMapsFromeBPF(spec *ebpf.CollectionSpec) []*ebpf.MapSpec {
+	var traceMaps []*ebpf.MapSpec
+	for _, mapName := range getGadgetIdentsByPrefix(spec, traceMapPrefix) {
+		if traceMap, ok := spec.Maps[mapName]; ok {
+			traceMaps = append(traceMaps, traceMap)
+		}
+	}
+	return traceMaps
+}
+
+// FlattenMembers returns the members of btfStruct with the ones that are
+// structures replaced by their own members, up to maxDepth levels, or all the
+// levels when maxDepth is 0. The members of the nested structures are named
+// after their path, like "task.comm", and their offsets are relative to the
+// start of btfStruct. The members of anonymous structures and unions keep their
+// name, as in C. The endpoints, shown as a whole, and the named unions aren't
+// flattened.
+func FlattenMembers(btfStruct *btf.Struct, maxDepth int) []btf.Member {
+	return flattenMembers(nil, btfStruct.Members, "", 0, maxDepth-1)
 }
 
-// getTracerMapFromeBPF returns the tracer map from the eBPF object.
-// It looks for maps marked with GADGET_TRACE_MAP() and returns the first one.
-func getTracerMapFromeBPF(spec *ebpf.CollectionSpec) *ebpf.MapSpec {
-	mapName := getGadgetIdentByPrefix(spec, traceMapPrefix)
-	return spec.Maps[mapName]
+func flattenMembers(result []btf.Member, members []btf.Member, prefix string, offset btf.Bits, depth int) []btf.Member {
+	for _, member := range members {
+		member.Offset += offset
+
+		var nested []btf.Member
+		switch typ := btf.UnderlyingType(member.Type).(type) {
+		case *btf.Struct:
+			if typ.Name != gadgets.L3EndpointTypeName && typ.Name != gadgets.L4EndpointTypeName {
+				nested = typ.Members
+			}
+		case *btf.Union:
+			if member.Name == "" {
+				nested = typ.Members
+			}
+		}
+
+		switch {
+		case nested != nil && member.Name == "":
+			result = flattenMembers(result, nested, prefix, member.Offset, depth)
+		case nested != nil && depth != 0:
+			result = flattenMembers(result, nested, prefix+member.Name+".", member.Offset, depth-1)
+		default:
+			member.Name = prefix + member.Name
+			result = append(result, member)
+		}
+	}
+	return result
 }
 
-func (m *GadgetMetadata) populateStruct(btfStruct *btf.Struct) error {
+// populateStruct adds the members of btfStruct that aren't in the metadata as
+// fields, with the attributes given by their decl tags if any. The fields
+// without description yet take the one of their tags.
+func (m *GadgetMetadata) populateStruct(btfStruct *btf.Struct, tags memberTags) error {
 	if m.Structs == nil {
 		m.Structs = make(map[string]Struct)
 	}
 
 	gadgetStruct := m.Structs[btfStruct.Name]
-	existingFields := make(map[string]struct{})
-	for _, field := range gadgetStruct.Fields {
-		existingFields[field.Name] = struct{}{}
+	existingFields := make(map[string]int)
+	for i, field := range gadgetStruct.Fields {
+		existingFields[field.Name] = i
 	}
 
-	for _, member := range btfStruct.Members {
+	builtinColumns := GetColumns()
+	replaced := ReplacedByEnrichment(gadgetStruct, btfStruct)
+
+	for _, member := range FlattenMembers(btfStruct, gadgetStruct.Depth()) {
 		// skip some specific members
-		if member.Name == "timestamp" {
-			log.Debug("Ignoring timestamp field: see https://github.com/inspektor-gadget/inspektor-gadget/issues/2000")
+		if IsEventTimestamp(member) {
+			log.Debugf("Field %q fills the built-in timestamp column", member.Name)
 			continue
 		}
-		// TODO: temporary disable mount ns as it'll be duplicated otherwise
-		if member.Type.TypeName() == gadgets.MntNsIdTypeName {
+		if replaced[member.Name] {
+			log.Debugf("Field %q is replaced by the container and pod columns", member.Name)
+			continue
+		}
+		// The names of the nested members could be the ones of the columns of
+		// all the events, like "k8s.node"
+		if _, ok := builtinColumns.GetColumn(member.Name); ok {
+			log.Warnf("Ignoring field %q: it collides with a built-in column", member.Name)
 			continue
 		}
 
 		// check if field already exists
-		if _, ok := existingFields[member.Name]; ok {
+		if i, ok := existingFields[member.Name]; ok {
 			log.Debugf("Field %q already exists, skipping", member.Name)
+			field := &gadgetStruct.Fields[i]
+			for _, tag := range tags.find(btfStruct, member.Name) {
+				if key, _, _ := strings.Cut(tag, "="); key == tagDescription {
+					if err := applyFieldTags(field, []string{tag}); err != nil {
+						return fmt.Errorf("field %q: %w", member.Name, err)
+					}
+				}
+			}
 			continue
 		}
 
@@ -391,8 +2551,30 @@ func (m *GadgetMetadata) populateStruct(btfStruct *btf.Struct) error {
 				Width:     getColumnSize(member.Type),
 				Alignment: AlignmentLeft,
 				Ellipsis:  EllipsisEnd,
+				Kind:      fieldKindFromMember(member),
 			},
 		}
+		if width := getKindColumnSize(field.Attributes.Kind); width != 0 {
+			field.Attributes.Width = width
+		}
+		// The template gives the width
+		if template := GetKindColumnTemplate(field.Attributes.Kind); template != "" {
+			field.Attributes.Template = template
+			field.Attributes.Width = 0
+		}
+		// The stacks are shown after their events, the innermost frames at
+		// the end of the column tell the most
+		if IsStackKind(field.Attributes.Kind) {
+			field.Attributes.Hidden = true
+			field.Attributes.Ellipsis = EllipsisStart
+		}
+		// The end of the long strings, like paths, tells the most
+		if isCharArray(member.Type) && btf.UnderlyingType(member.Type).(*btf.Array).Nelems > MaxStringColumnWidth {
+			field.Attributes.Ellipsis = EllipsisStart
+		}
+		if err := applyFieldTags(&field, tags.find(btfStruct, member.Name)); err != nil {
+			return fmt.Errorf("field %q: %w", member.Name, err)
+		}
 
 		gadgetStruct.Fields = append(gadgetStruct.Fields, field)
 	}
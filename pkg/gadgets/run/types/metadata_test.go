@@ -15,10 +15,16 @@
 package types
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
 func TestValidate(t *testing.T) {
@@ -34,15 +40,53 @@ func TestValidate(t *testing.T) {
 			metadata:          &GadgetMetadata{},
 			expectedErrString: "gadget name is required",
 		},
-		"tracers_more_than_one": {
+		"unknown_category": {
+			metadata: &GadgetMetadata{
+				Name:     "foo",
+				Category: "foo",
+			},
+			expectedErrString: "unknown category",
+		},
+		"invalid_capability": {
+			metadata: &GadgetMetadata{
+				Name:         "foo",
+				Category:     "trace",
+				Capabilities: []string{"CAP_BPF", "sys_admin"},
+			},
+			expectedErrString: "invalid capability \"sys_admin\"",
+		},
+		"invalid_min_kernel_version": {
+			metadata: &GadgetMetadata{
+				Name:             "foo",
+				MinKernelVersion: "five",
+			},
+			expectedErrString: "invalid minimum kernel version \"five\"",
+		},
+		"unknown_required_feature": {
+			metadata: &GadgetMetadata{
+				Name:         "foo",
+				Requirements: Requirements{Features: []string{"ringbuf", "time_travel"}},
+			},
+			expectedErrString: "unknown required feature \"time_travel\"",
+		},
+		"tracers_same_map": {
 			metadata: &GadgetMetadata{
 				Name: "foo",
 				Tracers: map[string]Tracer{
-					"foo": {},
-					"bar": {},
+					"foo": {
+						MapName:    "events",
+						StructName: "event",
+					},
+					"bar": {
+						MapName:    "events",
+						StructName: "event",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
 				},
 			},
-			expectedErrString: "only one tracer is allowed",
+			expectedErrString: "tracers \"bar\" and \"foo\" use the same map \"events\"",
 		},
 		"tracers_missing_map_name": {
 			metadata: &GadgetMetadata{
@@ -78,6 +122,46 @@ func TestValidate(t *testing.T) {
 			},
 			expectedErrString: "references unknown struct",
 		},
+		"tracers_invalid_buffer_pages": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Tracers: map[string]Tracer{
+					"foo": {
+						MapName:     "events",
+						StructName:  "event",
+						BufferPages: 100,
+					},
+				},
+			},
+			expectedErrString: "has invalid bufferPages 100",
+		},
+		"tracers_invalid_buffer_size": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Tracers: map[string]Tracer{
+					"foo": {
+						MapName:    "events",
+						StructName: "event",
+						BufferSize: "lots",
+					},
+				},
+			},
+			expectedErrString: `has invalid bufferSize "lots"`,
+		},
+		"tracers_buffer_pages_and_size": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Tracers: map[string]Tracer{
+					"foo": {
+						MapName:     "events",
+						StructName:  "event",
+						BufferPages: 64,
+						BufferSize:  "1MiB",
+					},
+				},
+			},
+			expectedErrString: "can't have both bufferPages and bufferSize",
+		},
 		"tracers_map_not_found": {
 			metadata: &GadgetMetadata{
 				Name: "foo",
@@ -152,6 +236,306 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		"snapshotters_missing_struct_name": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Snapshotters: map[string]Snapshotter{
+					"foo": {
+						Programs: []string{"enter_openat"},
+					},
+				},
+			},
+			expectedErrString: "snapshotter \"foo\" is missing structName",
+		},
+		"snapshotters_missing_programs": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Snapshotters: map[string]Snapshotter{
+					"foo": {
+						StructName: "event",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "snapshotter \"foo\" is missing programs",
+		},
+		"snapshotters_program_not_found": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Snapshotters: map[string]Snapshotter{
+					"foo": {
+						StructName: "event",
+						Programs:   []string{"nonexistent"},
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "program \"nonexistent\" not found in eBPF object",
+		},
+		"snapshotters_program_not_iterator": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Snapshotters: map[string]Snapshotter{
+					"foo": {
+						StructName: "event",
+						Programs:   []string{"enter_openat"},
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "program \"enter_openat\" of snapshotter \"foo\" is not an iterator",
+		},
+		"snapshotters_same_name_as_tracer": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Tracers: map[string]Tracer{
+					"foo": {
+						MapName:    "events",
+						StructName: "event",
+					},
+				},
+				Snapshotters: map[string]Snapshotter{
+					"foo": {
+						StructName: "event",
+						Programs:   []string{"enter_openat"},
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "snapshotter \"foo\" has the same name as a tracer",
+		},
+		"toppers_missing_map_name": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Toppers: map[string]Topper{
+					"foo": {
+						KeyStructName:   "event",
+						ValueStructName: "event",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "topper \"foo\" is missing mapName",
+		},
+		"toppers_unknown_struct": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Toppers: map[string]Topper{
+					"foo": {
+						MapName:         "myhashmap",
+						KeyStructName:   "event",
+						ValueStructName: "nonexistent",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "topper \"foo\" references unknown struct \"nonexistent\"",
+		},
+		"toppers_same_field_in_key_and_value": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Toppers: map[string]Topper{
+					"foo": {
+						MapName:         "myhashmap",
+						KeyStructName:   "event",
+						ValueStructName: "event",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {
+						Fields: []Field{{Name: "pid"}},
+					},
+				},
+			},
+			expectedErrString: "field \"pid\" of topper \"foo\" is both in the key and the value",
+		},
+		"toppers_unknown_sort_field": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Toppers: map[string]Topper{
+					"foo": {
+						MapName:         "myhashmap",
+						KeyStructName:   "event",
+						ValueStructName: "event",
+						SortBy:          []string{"-nonexistent"},
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "topper \"foo\" sorts by unknown field \"nonexistent\"",
+		},
+		"toppers_wrong_map_type": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Toppers: map[string]Topper{
+					"foo": {
+						MapName:         "events",
+						KeyStructName:   "event",
+						ValueStructName: "event",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "map \"events\" has a wrong type, expected: hash or LRU hash, got: PerfEventArray",
+		},
+		"toppers_key_not_struct": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Toppers: map[string]Topper{
+					"foo": {
+						MapName:         "myhashmap",
+						KeyStructName:   "event",
+						ValueStructName: "event",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "key of BPF map \"myhashmap\" is not a structure",
+		},
+		"toppers_same_name_as_tracer": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Tracers: map[string]Tracer{
+					"foo": {
+						MapName:    "events",
+						StructName: "event",
+					},
+				},
+				Toppers: map[string]Topper{
+					"foo": {
+						MapName:         "myhashmap",
+						KeyStructName:   "event",
+						ValueStructName: "event",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "topper \"foo\" has the same name as a tracer",
+		},
+		"histograms_missing_unit": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Histograms: map[string]Histogram{
+					"foo": {
+						MapName: "myhashmap",
+					},
+				},
+			},
+			expectedErrString: "histogram \"foo\" is missing unit",
+		},
+		"histograms_map_not_found": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Histograms: map[string]Histogram{
+					"foo": {
+						MapName: "nonexistent",
+						Unit:    "ns",
+					},
+				},
+			},
+			expectedErrString: "map \"nonexistent\" not found in eBPF object",
+		},
+		"histograms_wrong_map_type": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Histograms: map[string]Histogram{
+					"foo": {
+						MapName: "events",
+						Unit:    "ns",
+					},
+				},
+			},
+			expectedErrString: "map \"events\" has a wrong type, expected: array, hash or LRU hash, got: PerfEventArray",
+		},
+		"histograms_value_not_array": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Histograms: map[string]Histogram{
+					"foo": {
+						MapName: "myhashmap",
+						Unit:    "ns",
+					},
+				},
+			},
+			expectedErrString: "value of BPF map \"myhashmap\": expected an array of counters",
+		},
+		"histograms_same_name_as_topper": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Toppers: map[string]Topper{
+					"foo": {
+						MapName:         "myhashmap",
+						KeyStructName:   "event",
+						ValueStructName: "event",
+					},
+				},
+				Histograms: map[string]Histogram{
+					"foo": {
+						MapName: "myhashmap",
+						Unit:    "ns",
+					},
+				},
+				Structs: map[string]Struct{
+					"event": {},
+				},
+			},
+			expectedErrString: "histogram \"foo\" has the same name as a topper",
+		},
+		"params_missing_var_name": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Params: map[string]Param{
+					"pid": {
+						Type: "uint32",
+					},
+				},
+			},
+			expectedErrString: "param \"pid\" is missing varName",
+		},
+		"params_internal_constant": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Params: map[string]Param{
+					"mntns": {
+						VarName: "gadget_filter_by_mntns",
+						Type:    "bool",
+					},
+				},
+			},
+			expectedErrString: "the constants prefixed with \"gadget_\" are set by Inspektor Gadget",
+		},
+		"params_constant_not_found": {
+			metadata: &GadgetMetadata{
+				Name: "foo",
+				Params: map[string]Param{
+					"pid": {
+						VarName: "targ_pid",
+						Type:    "uint32",
+					},
+				},
+			},
+			expectedErrString: "constant \"targ_pid\" not found in eBPF object",
+		},
 		"structs_nonexistent": {
 			metadata: &GadgetMetadata{
 				Name: "foo",
@@ -223,8 +607,9 @@ func TestPopulate(t *testing.T) {
 		"1_tracer_1_struct_from_scratch": {
 			objectPath: "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o",
 			expectedMetadata: &GadgetMetadata{
-				Name:        "TODO: Fill the gadget name",
-				Description: "TODO: Fill the gadget description",
+				SchemaVersion: CurrentSchemaVersion,
+				Name:          "TODO: Fill the gadget name",
+				Description:   "TODO: Fill the gadget description",
 				Tracers: map[string]Tracer{
 					"events": {
 						MapName:    "events",
@@ -305,8 +690,9 @@ func TestPopulate(t *testing.T) {
 				},
 			},
 			expectedMetadata: &GadgetMetadata{
-				Name:        "foo",
-				Description: "bar",
+				SchemaVersion: CurrentSchemaVersion,
+				Name:          "foo",
+				Description:   "bar",
 				Tracers: map[string]Tracer{
 					"events": {
 						MapName:    "events",
@@ -351,10 +737,11 @@ func TestPopulate(t *testing.T) {
 		"no_tracers_from_scratch": {
 			objectPath: "../../../../testdata/populate_metadata_no_tracers_from_scratch.o",
 			expectedMetadata: &GadgetMetadata{
-				Name:        "TODO: Fill the gadget name",
-				Description: "TODO: Fill the gadget description",
-				Tracers:     map[string]Tracer{},
-				Structs:     map[string]Struct{},
+				SchemaVersion: CurrentSchemaVersion,
+				Name:          "TODO: Fill the gadget name",
+				Description:   "TODO: Fill the gadget description",
+				Tracers:       map[string]Tracer{},
+				Structs:       map[string]Struct{},
 			},
 		},
 		"tracer_wrong_map_type": {
@@ -395,3 +782,966 @@ func TestPopulate(t *testing.T) {
 		})
 	}
 }
+
+func TestPopulateSnapshotters(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	entry := &btf.Struct{Name: "process_entry", Size: 8, Members: []btf.Member{
+		{Name: "pid", Type: u32, Offset: 0},
+		{Name: "ppid", Type: u32, Offset: 32},
+	}}
+	marker := func(ident string) *btf.Var {
+		return &btf.Var{Name: snapshotterPrefix + ident, Type: &btf.Pointer{Target: &btf.Void{}}}
+	}
+
+	b, err := btf.NewBuilder([]btf.Type{
+		entry,
+		marker("processes___process_entry___ig_snap_proc"),
+		marker("processes___process_entry___ig_snap_thread"),
+	})
+	require.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+	types, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+
+	iterator := &ebpf.ProgramSpec{Type: ebpf.Tracing, AttachType: ebpf.AttachTraceIter, AttachTo: "task"}
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{},
+		Programs: map[string]*ebpf.ProgramSpec{
+			"ig_snap_proc":   iterator,
+			"ig_snap_thread": iterator,
+		},
+		Types: types,
+	}
+
+	m := &GadgetMetadata{Name: "foo", Description: "bar"}
+	require.NoError(t, m.Populate(spec))
+	require.Equal(t, map[string]Snapshotter{
+		"processes": {
+			StructName: "process_entry",
+			Programs:   []string{"ig_snap_proc", "ig_snap_thread"},
+		},
+	}, m.Snapshotters)
+	require.Len(t, m.Structs["process_entry"].Fields, 2)
+	require.NoError(t, m.Validate(spec))
+
+	snapshotter := m.Snapshotters["processes"]
+	snapshotter.SortBy = []string{"-ppid", "pid"}
+	m.Snapshotters["processes"] = snapshotter
+	require.NoError(t, m.Validate(spec))
+	snapshotter.SortBy = []string{"comm"}
+	m.Snapshotters["processes"] = snapshotter
+	require.ErrorContains(t, m.Validate(spec), `snapshotter "processes" sorts by unknown field "comm"`)
+	snapshotter.SortBy = nil
+	m.Snapshotters["processes"] = snapshotter
+
+	// Populating again doesn't duplicate the programs
+	require.NoError(t, m.Populate(spec))
+	require.Equal(t, []string{"ig_snap_proc", "ig_snap_thread"}, m.Snapshotters["processes"].Programs)
+
+	// Only iterators can take snapshots
+	spec.Programs["ig_snap_thread"] = &ebpf.ProgramSpec{Type: ebpf.Kprobe}
+	err = (&GadgetMetadata{}).Populate(spec)
+	require.ErrorContains(t, err, `program "ig_snap_thread" of snapshotter "processes" is not an iterator`)
+}
+
+func TestPopulateToppers(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	key := &btf.Struct{Name: "file_id", Size: 4, Members: []btf.Member{
+		{Name: "pid", Type: u32, Offset: 0},
+	}}
+	value := &btf.Struct{Name: "file_stats", Size: 16, Members: []btf.Member{
+		{Name: "reads", Type: u64, Offset: 0},
+		{Name: "read_bytes", Type: u64, Offset: 64},
+	}}
+
+	b, err := btf.NewBuilder([]btf.Type{
+		key,
+		value,
+		&btf.Var{Name: topperPrefix + "files___stats", Type: &btf.Pointer{Target: &btf.Void{}}},
+	})
+	require.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+	types, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"stats": {Name: "stats", Type: ebpf.Hash, KeySize: 4, ValueSize: 16, Key: key, Value: value},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{},
+		Types:    types,
+	}
+
+	m := &GadgetMetadata{Name: "foo", Description: "bar"}
+	require.NoError(t, m.Populate(spec))
+	require.Equal(t, map[string]Topper{
+		"files": {
+			MapName:         "stats",
+			KeyStructName:   "file_id",
+			ValueStructName: "file_stats",
+			// The counters of bytes are sorted by default
+			SortBy: []string{"-read_bytes"},
+		},
+	}, m.Toppers)
+	require.Len(t, m.Structs["file_id"].Fields, 1)
+	require.Len(t, m.Structs["file_stats"].Fields, 2)
+	require.NoError(t, m.Validate(spec))
+
+	topper := m.Toppers["files"]
+	topper.Interval = "5s"
+	m.Toppers["files"] = topper
+	require.NoError(t, m.Validate(spec))
+	topper.Interval = "-1s"
+	m.Toppers["files"] = topper
+	require.ErrorContains(t, m.Validate(spec), `invalid interval of topper "files": must be positive`)
+	topper.Interval = "5"
+	m.Toppers["files"] = topper
+	require.ErrorContains(t, m.Validate(spec), `invalid interval of topper "files"`)
+
+	// Only hash maps hold statistics
+	spec.Maps["stats"].Type = ebpf.Array
+	err = (&GadgetMetadata{}).Populate(spec)
+	require.ErrorContains(t, err, `map of topper "files" is invalid`)
+}
+
+func TestPopulateHistograms(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	hist := &btf.Struct{Name: "hist", Size: 4 * 27, Members: []btf.Member{
+		{Name: "slots", Type: &btf.Array{Index: u32, Type: u32, Nelems: 27}},
+	}}
+
+	b, err := btf.NewBuilder([]btf.Type{
+		hist,
+		&btf.Var{Name: histogramPrefix + "latency___latencies", Type: &btf.Pointer{Target: &btf.Void{}}},
+	})
+	require.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+	types, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"latencies": {Name: "latencies", Type: ebpf.Array, KeySize: 4, ValueSize: hist.Size, Key: u32, Value: hist},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{},
+		Types:    types,
+	}
+
+	m := &GadgetMetadata{Name: "foo", Description: "bar"}
+	require.NoError(t, m.Populate(spec))
+	require.Equal(t, map[string]Histogram{
+		"latency": {MapName: "latencies", Unit: "TODO: Fill the unit"},
+	}, m.Histograms)
+	require.NoError(t, m.Validate(spec))
+
+	slotSize, nSlots, err := HistogramSlots(hist)
+	require.NoError(t, err)
+	require.Equal(t, uint32(4), slotSize)
+	require.Equal(t, uint32(27), nSlots)
+
+	// The counters must be unsigned
+	hist.Members[0].Type = &btf.Array{Index: u32, Type: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}, Nelems: 27}
+	_, _, err = HistogramSlots(hist)
+	require.ErrorContains(t, err, "the counters must be unsigned integers of 32 or 64 bits")
+}
+
+func TestPopulateParams(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	b, err := btf.NewBuilder([]btf.Type{
+		&btf.Var{Name: paramPrefix + "targ_pid", Type: &btf.Pointer{Target: &btf.Void{}}},
+	})
+	require.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+	types, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+
+	targPid := &btf.Var{Name: "targ_pid", Type: &btf.Volatile{Type: &btf.Const{Type: u32}}, Linkage: btf.GlobalVar}
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			".rodata": {
+				Name:       ".rodata",
+				Type:       ebpf.Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+				Value: &btf.Datasec{Name: ".rodata", Size: 4, Vars: []btf.VarSecinfo{
+					{Type: targPid, Offset: 0, Size: 4},
+				}},
+				Contents: []ebpf.MapKV{{Key: uint32(0), Value: []byte{42, 0, 0, 0}}},
+			},
+		},
+		Programs:  map[string]*ebpf.ProgramSpec{},
+		Types:     types,
+		ByteOrder: binary.LittleEndian,
+	}
+
+	m := &GadgetMetadata{Name: "foo", Description: "bar"}
+	require.NoError(t, m.Populate(spec))
+	require.Equal(t, map[string]Param{
+		"targ-pid": {VarName: "targ_pid", Type: "uint32", DefaultValue: "42", Description: "TODO: Fill param description"},
+	}, m.Params)
+	require.NoError(t, m.Validate(spec))
+
+	descs := m.ParamDescs()
+	require.Len(t, descs, 1)
+	require.Equal(t, "targ-pid", descs[0].Key)
+	require.Equal(t, "42", descs[0].DefaultValue)
+
+	// The type must match the one of the constant
+	m.Params["targ-pid"] = Param{VarName: "targ_pid", Type: "int32", DefaultValue: "42"}
+	require.ErrorContains(t, m.Validate(spec), `param "targ-pid" has a wrong type, expected: uint32, got: int32`)
+
+	m.Params["targ-pid"] = Param{VarName: "targ_pid", Type: "uint32", DefaultValue: "-1"}
+	require.ErrorContains(t, m.Validate(spec), `param "targ-pid" has an invalid defaultValue`)
+}
+
+func TestFieldKinds(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	l3 := &btf.Struct{Name: "gadget_l3endpoint_t", Size: 20}
+
+	// The kinds are inferred from the names of the types
+	require.Equal(t, KindTimestampNs, fieldKindFromType(&btf.Typedef{Name: "gadget_timestamp_t", Type: u64}))
+	require.Equal(t, KindErrno, fieldKindFromType(&btf.Const{Type: &btf.Typedef{Name: "gadget_errno_t", Type: u32}}))
+	require.Equal(t, KindL3Endpoint, fieldKindFromType(l3))
+	require.Equal(t, KindNone, fieldKindFromType(u64))
+
+	// And from the names of the signed integers holding return values
+	s32 := &btf.Int{Name: "__s32", Size: 4, Encoding: btf.Signed}
+	require.Equal(t, KindReturnValue, fieldKindFromMember(btf.Member{Name: "ret", Type: s32}))
+	require.Equal(t, KindReturnValue, fieldKindFromMember(btf.Member{Name: "open.err", Type: &btf.Typedef{Name: "long", Type: s32}}))
+	require.Equal(t, KindErrno, fieldKindFromMember(btf.Member{Name: "ret", Type: &btf.Typedef{Name: "gadget_errno_t", Type: s32}}))
+	require.Equal(t, KindNone, fieldKindFromMember(btf.Member{Name: "ret", Type: u32}))
+	require.Equal(t, KindNone, fieldKindFromMember(btf.Member{Name: "retries", Type: s32}))
+	require.NoError(t, validateFieldKind(KindReturnValue, s32))
+	require.ErrorContains(t, validateFieldKind(KindReturnValue, u32), "expected a signed integer")
+
+	// Only a gadget_timestamp_t named timestamp is the one of the event
+	ts := &btf.Typedef{Name: "gadget_timestamp_t", Type: u64}
+	require.True(t, IsEventTimestamp(btf.Member{Name: "timestamp", Type: ts}))
+	require.False(t, IsEventTimestamp(btf.Member{Name: "timestamp", Type: u64}))
+	require.False(t, IsEventTimestamp(btf.Member{Name: "ts", Type: ts}))
+	require.False(t, IsEventTimestamp(btf.Member{Name: "timestamp", Type: &btf.Typedef{Name: "gadget_timestamp_t", Type: u32}}))
+
+	require.NoError(t, validateFieldKind(KindNone, u64))
+	require.NoError(t, validateFieldKind(KindBytes, &btf.Typedef{Name: "size_t", Type: u64}))
+	require.NoError(t, validateFieldKind(KindL3Endpoint, l3))
+	require.NoError(t, validateFieldKind(KindL3Endpoint, u32))
+	require.NoError(t, validateFieldKind(KindL3Endpoint, &btf.Array{Type: &btf.Int{Name: "__u8", Size: 1}, Nelems: 16}))
+	require.ErrorContains(t, validateFieldKind(KindL3Endpoint, u64), "expected a gadget_l3endpoint_t")
+	require.ErrorContains(t, validateFieldKind(KindL4Endpoint, l3), "expected a gadget_l4endpoint_t")
+	require.ErrorContains(t, validateFieldKind(KindDuration, l3), "expected an integer")
+	require.ErrorContains(t, validateFieldKind("foo", u64), "unknown kind")
+
+	// The endpoints take the templates of the built-in gadgets
+	m := &GadgetMetadata{}
+	require.NoError(t, m.populateStruct(&btf.Struct{Name: "event", Size: 24, Members: []btf.Member{
+		{Name: "dst", Type: &btf.Struct{Name: "gadget_l4endpoint_t", Size: 24}},
+	}}, nil))
+	attrs := m.Structs["event"].Fields[0].Attributes
+	require.Equal(t, KindL4Endpoint, attrs.Kind)
+	require.Equal(t, "ipaddrport", attrs.Template)
+	require.Zero(t, attrs.Width)
+
+	// And the error numbers their own
+	m = &GadgetMetadata{}
+	require.NoError(t, m.populateStruct(&btf.Struct{Name: "event", Size: 8, Members: []btf.Member{
+		{Name: "ret", Type: s32, Offset: 0},
+		{Name: "error", Type: &btf.Typedef{Name: "gadget_errno_t", Type: s32}, Offset: 32},
+	}}, nil))
+	for i, kind := range []FieldKind{KindReturnValue, KindErrno} {
+		attrs := m.Structs["event"].Fields[i].Attributes
+		require.Equal(t, kind, attrs.Kind)
+		require.Equal(t, "errno", attrs.Template)
+		require.Zero(t, attrs.Width)
+	}
+}
+
+func TestValidateEnumRawValue(t *testing.T) {
+	state := &btf.Enum{Name: "state", Size: 4, Values: []btf.EnumValue{{Name: "TCP_ESTABLISHED", Value: 1}}}
+	event := &btf.Struct{Name: "event", Size: 8, Members: []btf.Member{
+		{Name: "state", Type: state, Offset: 0},
+		{Name: "pid", Type: &btf.Int{Name: "__u32", Size: 4}, Offset: 32},
+	}}
+	b, err := btf.NewBuilder([]btf.Type{event})
+	require.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+	types, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+	spec := &ebpf.CollectionSpec{Types: types}
+
+	m := &GadgetMetadata{Name: "foo", Structs: map[string]Struct{}}
+	require.NoError(t, m.populateStruct(event, nil))
+	// Wide enough for the names of the values
+	require.Equal(t, uint(len("TCP_ESTABLISHED")), m.Structs["event"].Fields[0].Attributes.Width)
+
+	m.Structs["event"].Fields[0].Attributes.RawValue = true
+	require.NoError(t, m.Validate(spec))
+
+	m.Structs["event"].Fields[1].Attributes.RawValue = true
+	require.ErrorContains(t, m.Validate(spec), `field "pid" of struct "event" has rawValue set but isn't an enum`)
+}
+
+func TestValidateStacks(t *testing.T) {
+	s32 := &btf.Int{Name: "__s32", Size: 4, Encoding: btf.Signed}
+	event := &btf.Struct{Name: "event", Size: 8, Members: []btf.Member{
+		{Name: "kstack", Type: &btf.Typedef{Name: "gadget_kernel_stack_t", Type: s32}, Offset: 0},
+		{Name: "ustack", Type: &btf.Typedef{Name: "gadget_user_stack_t", Type: s32}, Offset: 32},
+	}}
+	b, err := btf.NewBuilder([]btf.Type{event})
+	require.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+	types, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+	spec := &ebpf.CollectionSpec{Types: types, Maps: map[string]*ebpf.MapSpec{}}
+
+	m := &GadgetMetadata{Name: "foo", Structs: map[string]Struct{}}
+	require.NoError(t, m.populateStruct(event, nil))
+	fields := m.Structs["event"].Fields
+	require.Equal(t, KindKernelStack, fields[0].Attributes.Kind)
+	require.Equal(t, KindUserStack, fields[1].Attributes.Kind)
+	// Shown after the events
+	require.True(t, fields[0].Attributes.Hidden)
+
+	require.ErrorContains(t, m.Validate(spec), `field "kstack" of struct "event" has kind "kernelStack": map "gadget_stacks" not found`)
+
+	spec.Maps["gadget_stacks"] = &ebpf.MapSpec{Name: "gadget_stacks", Type: ebpf.Hash}
+	require.ErrorContains(t, m.Validate(spec), `map "gadget_stacks" has a wrong type`)
+
+	spec.Maps["gadget_stacks"].Type = ebpf.StackTrace
+	require.NoError(t, m.Validate(spec))
+
+	require.ErrorContains(t, validateFieldKind(KindKernelStack, &btf.Int{Name: "__u64", Size: 8}), "expected a 32 bits integer")
+}
+
+func TestValidateFieldFlags(t *testing.T) {
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	enum := &btf.Enum{Name: "sock_flags", Size: 4}
+
+	require.NoError(t, validateFieldFlags(Field{Flags: []Flag{{Name: "O_WRONLY", Value: 1, Mask: 3}}}, u8))
+	require.NoError(t, validateFieldFlags(Field{FlagsEnum: true}, enum))
+
+	require.ErrorContains(t, validateFieldFlags(Field{Flags: []Flag{{Name: "O_CLOEXEC", Value: 0x80000}}}, u8),
+		`flag "O_CLOEXEC" doesn't fit in 8 bits`)
+	require.ErrorContains(t, validateFieldFlags(Field{Flags: []Flag{{Name: "BAD", Value: 4, Mask: 3}}}, u8),
+		`flag "BAD" has bits out of its mask 0x3`)
+	require.ErrorContains(t, validateFieldFlags(Field{Flags: []Flag{{Value: 1}}}, u8), "missing name")
+	require.ErrorContains(t, validateFieldFlags(Field{FlagsEnum: true}, u8), "flagsEnum is only supported for enums")
+	require.ErrorContains(t, validateFieldFlags(Field{Flags: []Flag{{Name: "A", Value: 1}}}, &btf.Struct{}),
+		"expected an integer or an enum")
+}
+
+func TestPopulateNestedStructs(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	creds := &btf.Struct{Name: "creds", Size: 8, Members: []btf.Member{
+		{Name: "uid", Type: u32, Offset: 0},
+		{Name: "gid", Type: u32, Offset: 32},
+	}}
+	task := &btf.Struct{Name: "task_info", Size: 16, Members: []btf.Member{
+		{Name: "tid", Type: u32, Offset: 0},
+		{Name: "creds", Type: &btf.Typedef{Name: "creds_t", Type: creds}, Offset: 32},
+		{Name: "", Type: &btf.Union{Size: 4, Members: []btf.Member{
+			{Name: "flags", Type: u32},
+			{Name: "raw_flags", Type: u32},
+		}}, Offset: 96},
+	}}
+	k8s := &btf.Struct{Name: "k8s_info", Size: 4, Members: []btf.Member{
+		{Name: "node", Type: u32, Offset: 0},
+	}}
+	event := &btf.Struct{Name: "event", Size: 28, Members: []btf.Member{
+		{Name: "pid", Type: u32, Offset: 0},
+		{Name: "task", Type: task, Offset: 32},
+		{Name: "addr", Type: &btf.Struct{Name: "gadget_l3endpoint_t", Size: 20}, Offset: 160},
+		{Name: "k8s", Type: k8s, Offset: 320},
+	}}
+
+	flat := FlattenMembers(event, 0)
+	names := []string{}
+	offsets := map[string]uint32{}
+	for _, member := range flat {
+		names = append(names, member.Name)
+		offsets[member.Name] = member.Offset.Bytes()
+	}
+	require.Equal(t, []string{"pid", "task.tid", "task.creds.uid", "task.creds.gid", "task.flags", "task.raw_flags", "addr", "k8s.node"}, names)
+	require.Equal(t, uint32(12), offsets["task.creds.gid"])
+	require.Equal(t, uint32(16), offsets["task.flags"])
+	require.Equal(t, uint32(40), offsets["k8s.node"])
+
+	fieldNames := func(m *GadgetMetadata) []string {
+		names := []string{}
+		for _, field := range m.Structs["event"].Fields {
+			names = append(names, field.Name)
+		}
+		return names
+	}
+
+	// "k8s.node" collides with the column of the Kubernetes node
+	m := &GadgetMetadata{}
+	require.NoError(t, m.populateStruct(event, nil))
+	require.Equal(t, []string{"pid", "task.tid", "task.creds.uid", "task.creds.gid", "task.flags", "task.raw_flags", "addr"}, fieldNames(m))
+
+	// The nested structures deeper than MaxDepth aren't flattened
+	m = &GadgetMetadata{Structs: map[string]Struct{"event": {MaxDepth: 2}}}
+	require.NoError(t, m.populateStruct(event, nil))
+	require.Equal(t, []string{"pid", "task.tid", "task.creds", "task.flags", "task.raw_flags", "addr"}, fieldNames(m))
+
+	m = &GadgetMetadata{Structs: map[string]Struct{"event": {MaxDepth: 1}}}
+	require.NoError(t, m.populateStruct(event, nil))
+	require.Equal(t, []string{"pid", "task", "addr", "k8s"}, fieldNames(m))
+}
+
+func TestPopulateCharArrays(t *testing.T) {
+	char := &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}
+	u8 := &btf.Typedef{Name: "__u8", Type: &btf.Int{Name: "unsigned char", Size: 1}}
+	event := &btf.Struct{Name: "event", Size: 304, Members: []btf.Member{
+		{Name: "comm", Type: &btf.Array{Type: char, Nelems: 16}, Offset: 0},
+		{Name: "path", Type: &btf.Array{Type: &btf.Const{Type: char}, Nelems: 256}, Offset: 128},
+		{Name: "data", Type: &btf.Array{Type: u8, Nelems: 32}, Offset: 2176},
+	}}
+
+	m := &GadgetMetadata{}
+	require.NoError(t, m.populateStruct(event, nil))
+	fields := m.Structs["event"].Fields
+	require.Len(t, fields, 3)
+
+	require.Equal(t, uint(16), fields[0].Attributes.Width)
+	require.Equal(t, EllipsisEnd, fields[0].Attributes.Ellipsis)
+	// The long strings are truncated from the start, to keep the end of paths
+	require.Equal(t, uint(MaxStringColumnWidth), fields[1].Attributes.Width)
+	require.Equal(t, EllipsisStart, fields[1].Attributes.Ellipsis)
+	// The arrays of bytes aren't strings
+	require.Equal(t, uint(DefaultColumnWidth), fields[2].Attributes.Width)
+	require.Equal(t, EllipsisEnd, fields[2].Attributes.Ellipsis)
+}
+
+func TestValidateFieldUnit(t *testing.T) {
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	require.NoError(t, validateFieldUnit(FieldAttributes{Unit: UnitPackets}, u64))
+	require.NoError(t, validateFieldUnit(FieldAttributes{Unit: UnitMicroseconds}, &btf.Typedef{Name: "latency_t", Type: u64}))
+	require.NoError(t, validateFieldUnit(FieldAttributes{Unit: UnitBytes, Kind: KindBytes}, u64))
+	require.ErrorContains(t, validateFieldUnit(FieldAttributes{Unit: "furlongs"}, u64), "unknown unit")
+	require.ErrorContains(t, validateFieldUnit(FieldAttributes{Unit: UnitBytes, Kind: KindDuration}, u64), `the values of kind "duration" aren't in bytes`)
+	require.ErrorContains(t, validateFieldUnit(FieldAttributes{Unit: UnitBytes}, &btf.Array{Type: u64, Nelems: 2}), "expected a number")
+
+	// The kinds with a unit imply it
+	attrs := FieldAttributes{Kind: KindDuration}
+	require.Equal(t, UnitNanoseconds, attrs.GetUnit())
+	attrs = FieldAttributes{Kind: KindErrno}
+	require.Equal(t, UnitNone, attrs.GetUnit())
+}
+
+func TestDecodeMetadataStrict(t *testing.T) {
+	data := []byte(`name: foo
+descripton: typo
+tracers:
+  events:
+    mapName: events
+    structName: event
+structs:
+  event:
+    fields:
+    - name: pid
+      attributes:
+        width: 10
+        elipsis: end
+      annotations:
+        anything: goes
+`)
+
+	keys, err := UnknownKeys(data)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"descripton (line 2)",
+		"structs.event.fields[0].attributes.elipsis (line 13)",
+	}, keys)
+
+	// The unknown keys are ignored unless in strict mode
+	m, err := DecodeMetadata(data, false)
+	require.NoError(t, err)
+	require.Equal(t, "foo", m.Name)
+	require.Equal(t, uint(10), m.Structs["event"].Fields[0].Attributes.Width)
+
+	_, err = DecodeMetadata(data, true)
+	require.ErrorContains(t, err, "unknown key descripton (line 2)")
+	require.ErrorContains(t, err, "unknown key structs.event.fields[0].attributes.elipsis (line 13)")
+
+	_, err = DecodeMetadata([]byte("name: foo\n"), true)
+	require.NoError(t, err)
+}
+
+func TestUpgradeMetadata(t *testing.T) {
+	// There must be an upgrade from each of the previous versions
+	require.Len(t, schemaUpgrades, CurrentSchemaVersion)
+
+	// The documents without version are upgraded
+	m, err := DecodeMetadata([]byte("name: foo\n"), true)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion, m.SchemaVersion)
+	require.Equal(t, "foo", m.Name)
+
+	upgraded, err := UpgradeMetadata([]byte("name: foo\n"))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("schemaVersion: %d\nname: foo\n", CurrentSchemaVersion), string(upgraded))
+
+	current := []byte(fmt.Sprintf("schemaVersion: %d\nname: foo\n", CurrentSchemaVersion))
+	m, err = DecodeMetadata(current, true)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion, m.SchemaVersion)
+
+	// The newer versions are rejected
+	newer := []byte(fmt.Sprintf("schemaVersion: %d\nname: foo\n", CurrentSchemaVersion+1))
+	_, err = DecodeMetadata(newer, false)
+	require.ErrorContains(t, err, "is newer than the supported one")
+	_, err = UpgradeMetadata(newer)
+	require.ErrorContains(t, err, "is newer than the supported one")
+
+	_, err = DecodeMetadata([]byte("schemaVersion: foo\n"), false)
+	require.ErrorContains(t, err, "invalid schema version")
+	_, err = DecodeMetadata([]byte("schemaVersion: -1\n"), false)
+	require.ErrorContains(t, err, "invalid schema version")
+
+	err = (&GadgetMetadata{SchemaVersion: CurrentSchemaVersion + 1, Name: "foo"}).Validate(&ebpf.CollectionSpec{})
+	require.ErrorContains(t, err, "is newer than the supported one")
+}
+
+func TestDefaultFilters(t *testing.T) {
+	m := &GadgetMetadata{
+		Structs: map[string]Struct{
+			"event": {
+				Fields: []Field{
+					{Name: "pid"},
+					{Name: "ret"},
+				},
+			},
+		},
+		DefaultFilters: []string{"ret:!0", "k8s.namespace:!kube-system"},
+	}
+	require.NoError(t, m.validateDefaultFilters())
+
+	// The user filters replace the default ones on the same fields
+	require.Equal(t, []string{"ret:!0", "k8s.namespace:!kube-system"}, m.Filters(nil))
+	require.Equal(t, []string{"pid:1", "ret:!0", "k8s.namespace:!kube-system"}, m.Filters([]string{"pid:1"}))
+	require.Equal(t, []string{"RET:>=0", "k8s.namespace:!kube-system"}, m.Filters([]string{"RET:>=0"}))
+
+	m.DefaultFilters = []string{"ret", "foo:bar"}
+	err := m.validateDefaultFilters()
+	require.ErrorContains(t, err, `invalid default filter "ret"`)
+	require.ErrorContains(t, err, `field "foo" not found`)
+}
+
+func TestDefaultSortBy(t *testing.T) {
+	require.Nil(t, defaultSortBy([]Field{{Name: "pid"}, {Name: "comm"}}))
+	require.Equal(t, []string{"-count", "-io.bytes", "-rx_bytes", "-size"}, defaultSortBy([]Field{
+		{Name: "pid"},
+		{Name: "count"},
+		{Name: "io.bytes"},
+		{Name: "rx_bytes"},
+		{Name: "size", Attributes: FieldAttributes{Kind: KindBytes}},
+		{Name: "latency", Attributes: FieldAttributes{Kind: KindDuration}},
+	}))
+}
+
+func TestValidatePrograms(t *testing.T) {
+	spec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"ig_open":   {Type: ebpf.Kprobe, SectionName: "kprobe/do_sys_open", AttachTo: "do_sys_open"},
+			"ig_exit":   {Type: ebpf.TracePoint, SectionName: "tracepoint/sched/sched_process_exit", AttachTo: "sched/sched_process_exit"},
+			"ig_socket": {Type: ebpf.SocketFilter, SectionName: "socket1"},
+		},
+	}
+
+	m := &GadgetMetadata{
+		Programs: map[string]Program{
+			"ig_open": {Alternatives: []AttachTarget{{Target: "do_sys_openat2", MinKernelVersion: "5.6"}}},
+			"ig_exit": {Alternatives: []AttachTarget{{Target: "sched/sched_process_free", MaxKernelVersion: "6.0"}}},
+		},
+	}
+	require.NoError(t, m.validatePrograms(spec))
+
+	m.Programs = map[string]Program{
+		"ig_open":    {Alternatives: []AttachTarget{{}, {Target: "foo", MinKernelVersion: "6.0", MaxKernelVersion: "5.0"}}},
+		"ig_exit":    {Alternatives: []AttachTarget{{Target: "sched_process_free", MinKernelVersion: "foo"}}},
+		"ig_socket":  {Alternatives: []AttachTarget{{Target: "foo"}}},
+		"ig_missing": {},
+	}
+	err := m.validatePrograms(spec)
+	require.ErrorContains(t, err, `alternative 0 of program "ig_open" is missing target`)
+	require.ErrorContains(t, err, `alternative "foo" of program "ig_open": maximum kernel version is lower than the minimum one`)
+	require.ErrorContains(t, err, `invalid alternative "sched_process_free" of program "ig_exit": expected category/name`)
+	require.ErrorContains(t, err, `invalid minimum kernel version "foo" of program "ig_exit"`)
+	require.ErrorContains(t, err, `program "ig_socket" has alternative targets but it isn't a kprobe or a tracepoint`)
+	require.ErrorContains(t, err, `program "ig_missing" not found in eBPF object`)
+
+	spec.Programs["ig_call"] = &ebpf.ProgramSpec{Type: ebpf.Kprobe, SectionName: "uprobe/"}
+	spec.Programs["ig_ret"] = &ebpf.ProgramSpec{Type: ebpf.Kprobe, SectionName: "uretprobe/"}
+	m.Programs = map[string]Program{
+		"ig_call": {Uprobe: &UprobeTarget{Binary: "/usr/bin/python3*", USDT: "python:function__entry"}},
+		"ig_ret":  {Uprobe: &UprobeTarget{Binary: "libc", Symbol: "malloc"}},
+	}
+	require.NoError(t, m.validatePrograms(spec))
+
+	m.Programs = map[string]Program{
+		"ig_call": {Uprobe: &UprobeTarget{USDT: "function__entry"}},
+		"ig_ret":  {Uprobe: &UprobeTarget{Binary: "/usr/bin/[", Symbol: "malloc", USDT: "python:function__return"}},
+		"ig_open": {Uprobe: &UprobeTarget{Binary: "libc", Symbol: "open"}},
+	}
+	err = m.validatePrograms(spec)
+	require.ErrorContains(t, err, `uprobe target of program "ig_call" is missing binary`)
+	require.ErrorContains(t, err, `invalid usdt "function__entry" of program "ig_call": expected provider:name`)
+	require.ErrorContains(t, err, `invalid binary "/usr/bin/[" of program "ig_ret"`)
+	require.ErrorContains(t, err, `uprobe target of program "ig_ret" has both symbol and usdt`)
+	require.ErrorContains(t, err, `program "ig_open" has a uprobe target but it isn't a uprobe or a uretprobe`)
+
+	m.Programs = map[string]Program{
+		"ig_ret": {Uprobe: &UprobeTarget{Binary: "/usr/bin/python3", USDT: "python:function__return"}},
+	}
+	require.ErrorContains(t, m.validatePrograms(spec), `program "ig_ret" is a uretprobe, it can't be attached to a USDT probe`)
+
+	v := version.MustParseGeneric("5.15.0-91-generic")
+	require.True(t, AttachTarget{}.Matches(v))
+	require.True(t, AttachTarget{MinKernelVersion: "5.6", MaxKernelVersion: "5.15"}.Matches(v))
+	require.False(t, AttachTarget{MinKernelVersion: "6.0"}.Matches(v))
+	require.False(t, AttachTarget{MaxKernelVersion: "5.10"}.Matches(v))
+	require.True(t, AttachTarget{MinKernelVersion: "6.0"}.Matches(nil))
+}
+
+// btfWithDeclTags returns the BTF of a struct event { __u32 pid; __u32 uid; }
+// whose members have the given decl tags. cilium/ebpf can't build decl tags, it's
+// encoded by hand.
+func btfWithDeclTags(t *testing.T, pidTags, uidTags []string) *btf.Spec {
+	t.Helper()
+
+	strs := []byte{0}
+	addString := func(s string) uint32 {
+		off := uint32(len(strs))
+		strs = append(append(strs, s...), 0)
+		return off
+	}
+
+	const (
+		kindInt     = 1
+		kindStruct  = 4
+		kindDeclTag = 17
+	)
+	var typs []uint32
+	// [1] __u32
+	typs = append(typs, addString("__u32"), kindInt<<24, 4, 32)
+	// [2] struct event
+	typs = append(typs, addString("event"), kindStruct<<24|2, 8,
+		addString("pid"), 1, 0,
+		addString("uid"), 1, 32)
+	for i, tags := range [][]string{pidTags, uidTags} {
+		for _, tag := range tags {
+			typs = append(typs, addString(tag), kindDeclTag<<24, 2, uint32(i))
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	typesLen := uint32(len(typs) * 4)
+	header := []any{uint16(0xeB9F), uint8(1), uint8(0), uint32(24), uint32(0), typesLen, typesLen, uint32(len(strs))}
+	for _, v := range append(header, typs, strs) {
+		require.NoError(t, binary.Write(buf, binary.LittleEndian, v))
+	}
+
+	spec, err := btf.LoadSpecFromReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return spec
+}
+
+func TestPopulateDeclTags(t *testing.T) {
+	types := btfWithDeclTags(t, []string{"description=PID of the process", "width=10", "other-tool"}, []string{"hidden"})
+	var event *btf.Struct
+	require.NoError(t, types.TypeByName("event", &event))
+
+	m := &GadgetMetadata{Name: "foo", Structs: map[string]Struct{}}
+	require.NoError(t, m.populateStruct(event, getMemberTags(types)))
+
+	fields := m.Structs["event"].Fields
+	require.Equal(t, "PID of the process", fields[0].Description)
+	require.Equal(t, uint(10), fields[0].Attributes.Width)
+	require.False(t, fields[0].Attributes.Hidden)
+	require.True(t, strings.HasPrefix(fields[1].Description, "TODO"))
+	require.True(t, fields[1].Attributes.Hidden)
+
+	// The fields of the metadata only take the descriptions they don't have
+	m.Structs["event"].Fields[0].Description = "Process ID"
+	m.Structs["event"].Fields[0].Attributes.Width = 16
+	m.Structs["event"].Fields[1].Attributes.Hidden = false
+	types = btfWithDeclTags(t, []string{"description=PID of the process", "width=10"}, []string{"description=User ID", "hidden"})
+	require.NoError(t, types.TypeByName("event", &event))
+	require.NoError(t, m.populateStruct(event, getMemberTags(types)))
+	fields = m.Structs["event"].Fields
+	require.Equal(t, "Process ID", fields[0].Description)
+	require.Equal(t, uint(16), fields[0].Attributes.Width)
+	require.Equal(t, "User ID", fields[1].Description)
+	require.False(t, fields[1].Attributes.Hidden)
+}
+
+func TestValidateDeclTags(t *testing.T) {
+	types := btfWithDeclTags(t, []string{"width=wide"}, []string{"hidden=yes"})
+	spec := &ebpf.CollectionSpec{Types: types}
+
+	m := &GadgetMetadata{Name: "foo", Structs: map[string]Struct{
+		"event": {Fields: []Field{{Name: "pid"}, {Name: "uid"}}},
+	}}
+	err := m.Validate(spec)
+	require.ErrorContains(t, err, `field "pid" of struct "event" has invalid decl tags`)
+	require.ErrorContains(t, err, `tag "width=wide": expected a positive width`)
+	require.ErrorContains(t, err, `tag "hidden=yes": hidden doesn't take a value`)
+
+	// The invalid tags are reported when populating too
+	var event *btf.Struct
+	require.NoError(t, types.TypeByName("event", &event))
+	require.ErrorContains(t, (&GadgetMetadata{}).populateStruct(event, getMemberTags(types)), `field "pid"`)
+}
+
+func TestValidateMetrics(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4, Encoding: btf.Unsigned}
+	u64 := &btf.Int{Name: "__u64", Size: 8, Encoding: btf.Unsigned}
+	key := &btf.Struct{Name: "key", Size: 24, Members: []btf.Member{
+		{Name: "comm", Type: &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Char}, Nelems: 16}, Offset: 0},
+		{Name: "syscall", Type: u32, Offset: 128},
+		{Name: "ptr", Type: &btf.Pointer{Target: &btf.Void{}}, Offset: 192},
+	}}
+	value := &btf.Struct{Name: "value", Size: 8, Members: []btf.Member{
+		{Name: "count", Type: u64, Offset: 0},
+	}}
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"counts":  {Name: "counts", Type: ebpf.Hash, Key: key, Value: u64},
+			"stats":   {Name: "stats", Type: ebpf.LRUHash, Key: key, Value: value},
+			"array":   {Name: "array", Type: ebpf.Array, Key: u32, Value: u64},
+			"scalars": {Name: "scalars", Type: ebpf.Hash, Key: u32, Value: u64},
+		},
+	}
+
+	m := &GadgetMetadata{
+		Name: "foo",
+		Metrics: map[string]Metric{
+			"syscalls_total": {MapName: "counts", Type: MetricTypeCounter, Labels: []string{"comm", "syscall"}},
+			"stats_count":    {MapName: "stats", Type: MetricTypeGauge, ValueField: "count"},
+		},
+	}
+	require.NoError(t, m.Validate(spec))
+
+	m.Metrics = map[string]Metric{
+		"foo-bar": {MapName: "counts", Type: MetricTypeCounter},
+		"no_type": {MapName: "counts"},
+		"wrong_type": {
+			MapName: "counts",
+			Type:    "histogram",
+			Labels:  []string{"pid", "ptr", "comm", "comm"},
+		},
+		"no_map":        {Type: MetricTypeCounter},
+		"unknown_map":   {MapName: "foo", Type: MetricTypeCounter},
+		"array":         {MapName: "array", Type: MetricTypeCounter},
+		"scalar_key":    {MapName: "scalars", Type: MetricTypeCounter},
+		"value_field":   {MapName: "counts", Type: MetricTypeCounter, ValueField: "count"},
+		"missing_field": {MapName: "stats", Type: MetricTypeCounter},
+		"unknown_field": {MapName: "stats", Type: MetricTypeCounter, ValueField: "bytes"},
+	}
+	err := m.Validate(spec)
+	require.ErrorContains(t, err, `invalid metric name "foo-bar"`)
+	require.ErrorContains(t, err, `metric "no_type" is missing type`)
+	require.ErrorContains(t, err, `invalid type "histogram" of metric "wrong_type": expected counter or gauge`)
+	require.ErrorContains(t, err, `label "pid" isn't a member of struct "key"`)
+	require.ErrorContains(t, err, `label "ptr" must be an integer, an enum or a string`)
+	require.ErrorContains(t, err, `label "comm" is given twice`)
+	require.ErrorContains(t, err, `metric "no_map" is missing mapName`)
+	require.ErrorContains(t, err, `map "foo" not found in eBPF object`)
+	require.ErrorContains(t, err, `map "array" has a wrong type, expected: hash or LRU hash`)
+	require.ErrorContains(t, err, `key of BPF map "scalars" is not a structure`)
+	require.ErrorContains(t, err, `valueField is set but the values of map "counts" aren't structures`)
+	require.ErrorContains(t, err, `the values of map "stats" are structures, valueField must be set`)
+	require.ErrorContains(t, err, `value field "bytes" isn't a member of struct "value"`)
+}
+
+func TestValidateVariants(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4, Encoding: btf.Unsigned}
+	kind := &btf.Enum{Name: "kind", Size: 4, Values: []btf.EnumValue{{Name: "EXEC", Value: 1}, {Name: "EXIT", Value: 2}}}
+	header := &btf.Struct{Name: "header", Size: 8, Members: []btf.Member{
+		{Name: "kind", Type: kind, Offset: 0},
+		{Name: "pid", Type: u32, Offset: 32},
+	}}
+	exec := &btf.Struct{Name: "exec_event", Size: 12, Members: []btf.Member{
+		{Name: "kind", Type: kind, Offset: 0},
+		{Name: "pid", Type: u32, Offset: 32},
+		{Name: "ppid", Type: u32, Offset: 64},
+	}}
+	exit := &btf.Struct{Name: "exit_event", Size: 12, Members: []btf.Member{
+		{Name: "pid", Type: u32, Offset: 0},
+		{Name: "kind", Type: kind, Offset: 32},
+		{Name: "code", Type: u32, Offset: 64},
+	}}
+	b, err := btf.NewBuilder([]btf.Type{header, exec, exit})
+	require.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+	types, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+	spec := &ebpf.CollectionSpec{
+		Types: types,
+		Maps: map[string]*ebpf.MapSpec{
+			"events": {Name: "events", Type: ebpf.RingBuf, Value: header},
+		},
+	}
+
+	m := &GadgetMetadata{
+		Name: "foo",
+		Tracers: map[string]Tracer{
+			"events": {
+				MapName:       "events",
+				StructName:    "header",
+				Discriminator: "kind",
+				Variants:      map[string]string{"EXEC": "exec_event"},
+			},
+		},
+		Structs: map[string]Struct{},
+	}
+	// The structures of the variants are added with the one of the tracer
+	require.NoError(t, m.populateStruct(header, nil))
+	require.NoError(t, m.Populate(spec))
+	require.Contains(t, m.Structs, "exec_event")
+	require.NoError(t, m.Validate(spec))
+
+	_, variants, err := TracerVariants(m.Tracers["events"], header)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64]string{1: "exec_event"}, variants)
+
+	tracer := m.Tracers["events"]
+	tracer.Variants = map[string]string{"2": "exit_event", "foo": "exec_event", "3": "missing"}
+	m.Tracers["events"] = tracer
+	m.Structs["exit_event"] = Struct{}
+	err = m.Validate(spec)
+	require.ErrorContains(t, err, `invalid value "foo" of discriminator "kind"`)
+	require.ErrorContains(t, err, `tracer "events" references unknown struct "missing"`)
+
+	tracer.Variants = map[string]string{"2": "exit_event"}
+	m.Tracers["events"] = tracer
+	require.ErrorContains(t, m.Validate(spec), `struct "exit_event" of tracer "events" must have the discriminator "kind" of struct "header" at the same offset`)
+
+	tracer.Discriminator = "pid"
+	tracer.Variants = nil
+	m.Tracers["events"] = tracer
+	require.ErrorContains(t, m.Validate(spec), `tracer "events": discriminator is set but variants aren't`)
+
+	tracer.Discriminator = "ppid"
+	tracer.Variants = map[string]string{"1": "exec_event"}
+	m.Tracers["events"] = tracer
+	require.ErrorContains(t, m.Validate(spec), `tracer "events": discriminator "ppid" isn't a member of struct "header"`)
+}
+
+func TestValidateMaps(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4, Encoding: btf.Unsigned}
+	u64 := &btf.Int{Name: "__u64", Size: 8, Encoding: btf.Unsigned}
+	key := &btf.Struct{Name: "key", Size: 4, Members: []btf.Member{
+		{Name: "pid", Type: u32, Offset: 0},
+	}}
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"events":  {Name: "events", Type: ebpf.RingBuf},
+			"counts":  {Name: "counts", Type: ebpf.Hash, Key: key, Value: u64, MaxEntries: 256},
+			"pids":    {Name: "pids", Type: ebpf.LRUHash, Key: u32, Value: u64, MaxEntries: 1024},
+			"percpu":  {Name: "percpu", Type: ebpf.PerCPUHash, Key: u32, Value: u64, ValueSize: 8, MaxEntries: 1 << 20},
+			".rodata": {Name: ".rodata", Type: ebpf.Array, MaxEntries: 1},
+		},
+	}
+
+	m := &GadgetMetadata{
+		Name: "foo",
+		Maps: map[string]Map{
+			"counts": {MaxEntries: 4096, Scalable: true},
+			"pids":   {ExpectedEntries: 4096},
+		},
+		Metrics: map[string]Metric{
+			"pids_total": {MapName: "counts", Type: MetricTypeCounter, Labels: []string{"pid"}},
+		},
+	}
+	require.NoError(t, m.Validate(spec))
+	require.Equal(t, uint32(4096), m.MapMaxEntries(spec.Maps["counts"]))
+	require.Equal(t, uint32(1024), m.MapMaxEntries(spec.Maps["pids"]))
+	require.Equal(t, []MapSizeWarning{
+		{"percpu", "per-CPU map uses 512MiB on a node with 64 CPUs: reduce its max_entries or use a map shared by the CPUs"},
+		{"pids", "map can hold 1024 entries but 4096 are expected, the least recently used entries are evicted once it's full: " +
+			"increase its max_entries or its maxEntries in the metadata"},
+	}, m.MapSizeWarnings(spec))
+
+	// The maps of the metrics are expected to hold many entries
+	m.Maps = nil
+	require.Equal(t, []MapSizeWarning{
+		{"counts", `map of metric "pids_total" can only hold 256 entries, the new entries are dropped once it's full: ` +
+			"1024 or more are suggested, or set its expectedEntries in the metadata"},
+		{"percpu", "per-CPU map uses 512MiB on a node with 64 CPUs: reduce its max_entries or use a map shared by the CPUs"},
+	}, m.MapSizeWarnings(spec))
+
+	m.Maps = map[string]Map{
+		"foo":     {MaxEntries: 10},
+		"events":  {MaxEntries: 10},
+		".rodata": {Scalable: true},
+	}
+	err := m.Validate(spec)
+	require.ErrorContains(t, err, `map "foo" not found in eBPF object`)
+	require.ErrorContains(t, err, `map "events" is a RingBuf, its size is given by the bufferPages or bufferSize of its tracer`)
+	require.ErrorContains(t, err, `map ".rodata" holds the global variables, it can't be resized`)
+}
+
+func TestEnrichment(t *testing.T) {
+	u32 := &btf.Int{Name: "__u32", Size: 4, Encoding: btf.Unsigned}
+	u64 := &btf.Int{Name: "__u64", Size: 8, Encoding: btf.Unsigned}
+	event := &btf.Struct{Name: "event", Size: 24, Members: []btf.Member{
+		{Name: "mntns_id", Type: &btf.Typedef{Name: "mnt_ns_id_t", Type: u64}, Offset: 0},
+		{Name: "netns", Type: u32, Offset: 64},
+		{Name: "pid", Type: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}, Offset: 96},
+		{Name: "netns_id", Type: &btf.Typedef{Name: "gadget_netns_id_t", Type: u64}, Offset: 128},
+	}}
+	fieldNames := func(s Struct) []string {
+		m := &GadgetMetadata{Structs: map[string]Struct{"event": s}}
+		require.NoError(t, m.populateStruct(event, nil))
+		names := []string{}
+		for _, field := range m.Structs["event"].Fields {
+			names = append(names, field.Name)
+		}
+		return names
+	}
+
+	// The members of the namespace types are found by default
+	mntns, netns, err := EnrichmentMembers(Struct{}, event)
+	require.NoError(t, err)
+	require.Equal(t, "mntns_id", mntns.Name)
+	require.Equal(t, "netns_id", netns.Name)
+	require.Equal(t, []string{"netns", "pid"}, fieldNames(Struct{}))
+
+	s := Struct{Enrichment: Enrichment{NetNs: "netns"}}
+	mntns, netns, err = EnrichmentMembers(s, event)
+	require.NoError(t, err)
+	require.Equal(t, "mntns_id", mntns.Name)
+	require.Equal(t, "netns", netns.Name)
+	require.Equal(t, []string{"pid", "netns_id"}, fieldNames(s))
+
+	s.Enrichment.KeepRaw = true
+	require.Equal(t, []string{"mntns_id", "netns", "pid", "netns_id"}, fieldNames(s))
+
+	s = Struct{Enrichment: Enrichment{Disabled: true}}
+	mntns, netns, err = EnrichmentMembers(s, event)
+	require.NoError(t, err)
+	require.Nil(t, mntns)
+	require.Nil(t, netns)
+	require.Equal(t, []string{"mntns_id", "netns", "pid", "netns_id"}, fieldNames(s))
+	require.NoError(t, validateEnrichment(s, event))
+
+	require.ErrorContains(t, validateEnrichment(Struct{Enrichment: Enrichment{Disabled: true, NetNs: "netns"}}, event),
+		"mntns, netns and keepRaw can't be set when it's disabled")
+	require.ErrorContains(t, validateEnrichment(Struct{Enrichment: Enrichment{MntNs: "netns", NetNs: "netns"}}, event),
+		`member "netns" can't give both the mount and the network namespaces`)
+	require.ErrorContains(t, validateEnrichment(Struct{Enrichment: Enrichment{MntNs: "foo"}}, event),
+		`member "foo" not found in eBPF struct "event"`)
+	require.ErrorContains(t, validateEnrichment(Struct{Enrichment: Enrichment{NetNs: "pid"}}, event),
+		`member "pid" must be a 32 or 64 bits unsigned integer`)
+}
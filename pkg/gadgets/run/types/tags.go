@@ -0,0 +1,145 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Keys of the BTF decl tags setting the attributes of the fields, see the
+// GADGET_FIELD_* macros of include/gadget/macros.h. The tags with other keys
+// are ignored, they could be meant for other tools.
+const (
+	tagDescription = "description"
+	tagWidth       = "width"
+	tagHidden      = "hidden"
+	tagTemplate    = "template"
+)
+
+// memberTags are the values of the BTF decl tags of the members of the structs
+// and unions, by type and by index of the member
+type memberTags map[btf.Type]map[int][]string
+
+// getMemberTags returns the decl tags of the members of the given types. The
+// decl tags aren't exported by cilium/ebpf, their fields are read with
+// reflection.
+func getMemberTags(spec *btf.Spec) memberTags {
+	tags := memberTags{}
+	if spec == nil {
+		return tags
+	}
+
+	iter := spec.Iterate()
+	for iter.Next() {
+		v := reflect.ValueOf(iter.Type)
+		if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Type().Name() != "declTag" {
+			continue
+		}
+		tag := v.Elem()
+		typ, ok := tag.FieldByName("Type").Interface().(btf.Type)
+		index := int(tag.FieldByName("Index").Int())
+		if !ok || index < 0 {
+			continue
+		}
+		switch typ.(type) {
+		case *btf.Struct, *btf.Union:
+		default:
+			continue
+		}
+		if tags[typ] == nil {
+			tags[typ] = map[int][]string{}
+		}
+		tags[typ][index] = append(tags[typ][index], tag.FieldByName("Value").String())
+	}
+
+	return tags
+}
+
+// find returns the tags of the member of typ with the given name, as flattened
+// by FlattenMembers: the members of nested structures are prefixed by the name
+// of the structure and a dot.
+func (t memberTags) find(typ btf.Type, name string) []string {
+	var members []btf.Member
+	switch typ := btf.UnderlyingType(typ).(type) {
+	case *btf.Struct:
+		members = typ.Members
+	case *btf.Union:
+		members = typ.Members
+	default:
+		return nil
+	}
+	composite := btf.UnderlyingType(typ)
+
+	for i, member := range members {
+		switch {
+		case member.Name == "":
+			if tags := t.find(member.Type, name); tags != nil {
+				return tags
+			}
+		case member.Name == name:
+			return t[composite][i]
+		case strings.HasPrefix(name, member.Name+"."):
+			return t.find(member.Type, strings.TrimPrefix(name, member.Name+"."))
+		}
+	}
+	return nil
+}
+
+// applyFieldTags sets the attributes of the field given by its decl tags. The
+// description is only set if the field doesn't have one yet.
+func applyFieldTags(field *Field, tags []string) error {
+	var result error
+	for _, tag := range tags {
+		key, value, hasValue := strings.Cut(tag, "=")
+		switch key {
+		case tagDescription:
+			if value == "" {
+				result = multierror.Append(result, fmt.Errorf("tag %q: empty description", tag))
+				continue
+			}
+			if field.Description == "" || strings.HasPrefix(field.Description, "TODO") {
+				field.Description = value
+			}
+		case tagWidth:
+			width, err := strconv.ParseUint(value, 10, 0)
+			if err != nil || width == 0 {
+				result = multierror.Append(result, fmt.Errorf("tag %q: expected a positive width", tag))
+				continue
+			}
+			field.Attributes.Width = uint(width)
+			field.Attributes.Template = ""
+		case tagHidden:
+			if hasValue {
+				result = multierror.Append(result, fmt.Errorf("tag %q: hidden doesn't take a value", tag))
+				continue
+			}
+			field.Attributes.Hidden = true
+		case tagTemplate:
+			if value == "" {
+				result = multierror.Append(result, fmt.Errorf("tag %q: empty template", tag))
+				continue
+			}
+			field.Attributes.Template = value
+			field.Attributes.Width = 0
+		}
+	}
+	return result
+}
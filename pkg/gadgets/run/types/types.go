@@ -15,7 +15,11 @@
 package types
 
 import (
+	"strings"
+
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	columns_json "github.com/inspektor-gadget/inspektor-gadget/pkg/columns/formatter/json"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/histogram"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/parser"
@@ -24,6 +28,7 @@ import (
 
 const (
 	ValidateMetadataParam = "validate-metadata"
+	DefaultFiltersParam   = "default-filters"
 )
 
 type L3Endpoint struct {
@@ -36,20 +41,62 @@ type L4Endpoint struct {
 	Name string
 }
 
+// Stack is a kernel or user stack of an event, referenced by one of its fields
+type Stack struct {
+	// Name of the field
+	Name string `json:"name"`
+	// Frames of the stack, from the innermost one
+	Frames []string `json:"frames,omitempty"`
+}
+
 type Event struct {
 	eventtypes.Event
 	eventtypes.WithMountNsID
 
 	L3Endpoints []L3Endpoint `json:"l3endpoints,omitempty"`
 	L4Endpoints []L4Endpoint `json:"l4endpoints,omitempty"`
+	Stacks      []Stack      `json:"stacks,omitempty"`
+
+	// Name of the tracer, or snapshotter, that sent the event, the struct of
+	// its RawData depends on it
+	Tracer string `json:"tracer,omitempty"`
+	// Struct of RawData when the tracer sends several of them, told apart by
+	// their discriminator
+	Variant string `json:"variant,omitempty"`
+
+	// Network namespace the event comes from, the container and pod columns
+	// are filled from it. The mount namespace is in WithMountNsID.
+	NetNsID uint64 `json:"netnsid,omitempty"`
 
 	// Raw event sent by the ebpf program
 	RawData []byte `json:"raw_data,omitempty"`
+
+	// Number of times the event happened during the interval, only set when
+	// the gadget runs in aggregation mode
+	Count uint64 `json:"count,omitempty"`
+
+	// Distribution of the histogram Tracer, only set for the events of the
+	// histograms
+	Histogram *histogram.Histogram `json:"histogram,omitempty"`
 }
 
 type GadgetInfo struct {
+	// ImageRef is the normalized reference of the image the gadget is run
+	// from and ImageDigest the digest it resolved to.
+	ImageRef       string
+	ImageDigest    string
 	GadgetMetadata *GadgetMetadata
 	ProgContent    []byte
+	// Aggregated is set when the events are counted in the kernel and sent
+	// periodically with their count, see the aggregate-interval parameter
+	Aggregated bool
+	// DefaultFilters are the default filters of the metadata applied to the
+	// events, empty if they're disabled with the default-filters parameter
+	DefaultFilters []string
+}
+
+func (ev *Event) GetNetNSID() uint64 {
+	return ev.NetNsID
 }
 
 func (ev *Event) GetEndpoints() []*eventtypes.L3Endpoint {
@@ -65,6 +112,44 @@ func (ev *Event) GetEndpoints() []*eventtypes.L3Endpoint {
 	return endpoints
 }
 
+// ExtraLines shows the distribution of the histograms and the frames of the
+// stacks after the columns of their events.
+func (ev *Event) ExtraLines() []string {
+	lines := []string{}
+	if ev.Histogram != nil {
+		if out := strings.TrimSuffix(ev.Histogram.String(), "\n"); out != "" {
+			lines = append(lines, strings.Split(out, "\n")...)
+		}
+	}
+	for _, stack := range ev.Stacks {
+		lines = append(lines, stack.Name+":")
+		for _, frame := range stack.Frames {
+			lines = append(lines, "\t"+frame)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return lines
+}
+
+// FoldedStack returns the frames of the stack of the given field, from the
+// outermost one, separated by ';', like the folded stacks flame graphs are
+// made from
+func (ev *Event) FoldedStack(name string) string {
+	for _, stack := range ev.Stacks {
+		if stack.Name != name {
+			continue
+		}
+		frames := make([]string, len(stack.Frames))
+		for i, frame := range stack.Frames {
+			frames[len(frames)-1-i] = frame
+		}
+		return strings.Join(frames, ";")
+	}
+	return ""
+}
+
 func GetColumns() *columns.Columns[Event] {
 	return columns.MustCreateColumns[Event]()
 }
@@ -81,11 +166,20 @@ type Printer interface {
 	Logf(severity logger.Level, fmt string, params ...any)
 }
 
+// BytesPrinter is implemented by the printers that can output a buffer reused
+// by the caller, for the events to be written without converting them to
+// strings.
+type BytesPrinter interface {
+	// OutputBytes writes payload, a line ending with '\n', as is. payload
+	// must not be used once it returns.
+	OutputBytes(payload []byte)
+}
+
 // RunGadgetDesc represents the different methods implemented by the run gadget descriptor.
 type RunGadgetDesc interface {
 	GetGadgetInfo(params *params.Params, args []string) (*GadgetInfo, error)
 	CustomParser(info *GadgetInfo) (parser.Parser, error)
-	JSONConverter(info *GadgetInfo, p Printer) func(ev any)
-	JSONPrettyConverter(info *GadgetInfo, p Printer) func(ev any)
-	YAMLConverter(info *GadgetInfo, p Printer) func(ev any)
+	JSONConverter(info *GadgetInfo, p Printer, options ...columns_json.Option) func(ev any)
+	JSONPrettyConverter(info *GadgetInfo, p Printer, options ...columns_json.Option) func(ev any)
+	YAMLConverter(info *GadgetInfo, p Printer, options ...columns_json.Option) func(ev any)
 }
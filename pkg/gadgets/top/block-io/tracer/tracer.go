@@ -109,10 +109,11 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf spec: %w", err)
 	}
 
-	kernelSymbols, err := kallsyms.NewKAllSyms()
+	kernelSymbols, release, err := kallsyms.NewSharedKAllSyms()
 	if err != nil {
 		return fmt.Errorf("loading kernel symbols: %w", err)
 	}
+	defer release()
 
 	// __blk_account_io_start and __blk_account_io_done were inlined in:
 	// be6bfe36db17 ("block: inline hot paths of blk_account_io_*()").
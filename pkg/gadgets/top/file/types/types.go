@@ -49,6 +49,10 @@ type Stats struct {
 	Filename   string `json:"filename,omitempty" column:"file"`
 }
 
+func (s *Stats) GetPid() uint32 {
+	return s.Pid
+}
+
 func GetColumns() *columns.Columns[Stats] {
 	cols := columns.MustCreateColumns[Stats]()
 
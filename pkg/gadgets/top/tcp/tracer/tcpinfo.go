@@ -0,0 +1,179 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/tcp/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/netnsenter"
+)
+
+// connKey identifies a TCP connection inside a network namespace.
+type connKey struct {
+	netns uint64
+	saddr netip.Addr
+	daddr netip.Addr
+	sport uint16
+	dport uint16
+}
+
+func newConnKey(netns uint64, saddr net.IP, sport uint16, daddr net.IP, dport uint16) connKey {
+	src, _ := netip.AddrFromSlice(saddr)
+	dst, _ := netip.AddrFromSlice(daddr)
+
+	return connKey{
+		netns: netns,
+		saddr: src.Unmap(),
+		daddr: dst.Unmap(),
+		sport: sport,
+		dport: dport,
+	}
+}
+
+func statsConnKey(netns uint64, stat *types.Stats) connKey {
+	src, _ := netip.ParseAddr(stat.SrcEndpoint.Addr)
+	dst, _ := netip.ParseAddr(stat.DstEndpoint.Addr)
+
+	return connKey{
+		netns: netns,
+		saddr: src.Unmap(),
+		daddr: dst.Unmap(),
+		sport: stat.SrcEndpoint.Port,
+		dport: stat.DstEndpoint.Port,
+	}
+}
+
+// tcpInfoCollector gets the tcp_info of the connections reported by the
+// tracer using sock_diag. It keeps the total retransmissions of each
+// connection to report the ones that happened during the last interval.
+type tcpInfoCollector struct {
+	prevRetrans map[connKey]uint32
+
+	// getNetNs and dump are replaced by the tests
+	getNetNs func(pid int) (uint64, error)
+	dump     func(pid int, netns uint64) (map[connKey]*netlink.TCPInfo, error)
+}
+
+func newTCPInfoCollector() *tcpInfoCollector {
+	return &tcpInfoCollector{
+		prevRetrans: map[connKey]uint32{},
+		getNetNs:    containerutils.GetNetNs,
+		dump:        dumpTCPInfo,
+	}
+}
+
+// dumpTCPInfo returns the tcp_info of all TCP connections of the network namespace
+// of the given process.
+func dumpTCPInfo(pid int, netns uint64) (map[connKey]*netlink.TCPInfo, error) {
+	infos := map[connKey]*netlink.TCPInfo{}
+
+	err := netnsenter.NetnsEnter(pid, func() error {
+		for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+			resps, err := netlink.SocketDiagTCPInfo(family)
+			if err != nil {
+				return err
+			}
+
+			for _, resp := range resps {
+				if resp.InetDiagMsg == nil || resp.TCPInfo == nil {
+					continue
+				}
+
+				id := resp.InetDiagMsg.ID
+				key := newConnKey(netns, id.Source, id.SourcePort, id.Destination, id.DestinationPort)
+				infos[key] = resp.TCPInfo
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// fill sets the RTT and the retransmissions of the given stats. Connections
+// whose tcp_info can't be retrieved (the process exited, the connection was
+// closed, etc.) are left untouched. The first time a connection is seen its
+// total retransmissions are only taken as the baseline for the next interval,
+// as they could have happened any time since it was established.
+func (c *tcpInfoCollector) fill(stats []*types.Stats) {
+	infosByNetns := map[uint64]map[connKey]*netlink.TCPInfo{}
+	failedNetns := map[uint64]struct{}{}
+
+	for _, stat := range stats {
+		netns, err := c.getNetNs(int(stat.Pid))
+		if err != nil {
+			continue
+		}
+		if _, failed := failedNetns[netns]; failed {
+			// Don't retry the same network namespace if it failed once
+			continue
+		}
+
+		infos, ok := infosByNetns[netns]
+		if !ok {
+			infos, err = c.dump(int(stat.Pid), netns)
+			if err != nil {
+				failedNetns[netns] = struct{}{}
+				continue
+			}
+			infosByNetns[netns] = infos
+		}
+
+		key := statsConnKey(netns, stat)
+		info, ok := infos[key]
+		if !ok {
+			continue
+		}
+
+		stat.RTT = info.Rtt
+		stat.Retransmits = 0
+		if prev, ok := c.prevRetrans[key]; ok {
+			if prev <= info.Total_retrans {
+				stat.Retransmits = info.Total_retrans - prev
+			} else {
+				// A new connection with the same addresses and ports
+				stat.Retransmits = info.Total_retrans
+			}
+		}
+	}
+
+	// Remember the retransmissions of all the connections that were dumped,
+	// including the idle ones, and forget the others: they were closed or
+	// didn't have any traffic. The baselines of the network namespaces that
+	// couldn't be dumped are kept for the next interval.
+	prevRetrans := map[connKey]uint32{}
+	for key, prev := range c.prevRetrans {
+		if _, failed := failedNetns[key.netns]; failed {
+			prevRetrans[key] = prev
+		}
+	}
+	for _, infos := range infosByNetns {
+		for key, info := range infos {
+			prevRetrans[key] = info.Total_retrans
+		}
+	}
+	c.prevRetrans = prevRetrans
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/tcp/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func newStats(pid int32, saddr string, sport uint16, daddr string, dport uint16) *types.Stats {
+	return &types.Stats{
+		Pid: pid,
+		SrcEndpoint: eventtypes.L4Endpoint{
+			L3Endpoint: eventtypes.L3Endpoint{Addr: saddr},
+			Port:       sport,
+		},
+		DstEndpoint: eventtypes.L4Endpoint{
+			L3Endpoint: eventtypes.L3Endpoint{Addr: daddr},
+			Port:       dport,
+		},
+	}
+}
+
+func TestStatsConnKey(t *testing.T) {
+	t.Parallel()
+
+	// sock_diag reports IPv4 connections of dual-stack sockets as IPv4-mapped
+	// IPv6 addresses, they must match the IPv4 ones of the tracer.
+	key := newConnKey(1, net.ParseIP("::ffff:10.0.0.1"), 1234, net.ParseIP("10.0.0.2"), 80)
+	require.Equal(t, key, statsConnKey(1, newStats(0, "10.0.0.1", 1234, "10.0.0.2", 80)))
+
+	key = newConnKey(1, net.ParseIP("fd00::1"), 1234, net.ParseIP("fd00::2"), 80)
+	require.Equal(t, key, statsConnKey(1, newStats(0, "fd00::1", 1234, "fd00::2", 80)))
+
+	// The network namespace and the ports are part of the key
+	require.NotEqual(t, key, statsConnKey(2, newStats(0, "fd00::1", 1234, "fd00::2", 80)))
+	require.NotEqual(t, key, statsConnKey(1, newStats(0, "fd00::1", 1235, "fd00::2", 80)))
+	require.NotEqual(t, key, statsConnKey(1, newStats(0, "fd00::1", 1234, "fd00::2", 81)))
+}
+
+func TestTCPInfoCollectorFill(t *testing.T) {
+	t.Parallel()
+
+	infos := map[connKey]*netlink.TCPInfo{}
+	dumps := 0
+
+	c := newTCPInfoCollector()
+	c.getNetNs = func(pid int) (uint64, error) {
+		if pid == 0 {
+			return 0, errors.New("process exited")
+		}
+		return 1, nil
+	}
+	var dumpErr error
+	c.dump = func(pid int, netns uint64) (map[connKey]*netlink.TCPInfo, error) {
+		dumps++
+		if dumpErr != nil {
+			return nil, dumpErr
+		}
+		return infos, nil
+	}
+
+	first := newStats(10, "10.0.0.1", 1234, "10.0.0.2", 80)
+	second := newStats(10, "10.0.0.1", 1235, "10.0.0.2", 80)
+	infos[statsConnKey(1, first)] = &netlink.TCPInfo{Rtt: 100, Total_retrans: 5}
+	infos[statsConnKey(1, second)] = &netlink.TCPInfo{Rtt: 200, Total_retrans: 3}
+
+	fill := func(stats ...*types.Stats) {
+		for _, stat := range stats {
+			stat.RTT = 0
+			stat.Retransmits = 0
+		}
+		c.fill(stats)
+	}
+
+	// The retransmissions that happened before the first interval aren't
+	// reported
+	fill(first)
+	require.Equal(t, uint32(100), first.RTT)
+	require.Equal(t, uint32(0), first.Retransmits)
+	require.Equal(t, 1, dumps, "the network namespace must be dumped once")
+
+	// The idle connections of the network namespace are remembered too
+	infos[statsConnKey(1, first)].Total_retrans = 7
+	infos[statsConnKey(1, second)].Total_retrans = 4
+	fill(first, second)
+	require.Equal(t, uint32(2), first.Retransmits)
+	require.Equal(t, uint32(200), second.RTT)
+	require.Equal(t, uint32(1), second.Retransmits)
+	require.Equal(t, 2, dumps, "the network namespace must be dumped once")
+
+	// A new connection reusing the same addresses and ports
+	infos[statsConnKey(1, first)].Total_retrans = 1
+	fill(first)
+	require.Equal(t, uint32(1), first.Retransmits)
+
+	// The closed connections are forgotten
+	delete(infos, statsConnKey(1, first))
+	fill(first, second)
+	require.Equal(t, uint32(0), first.RTT)
+	require.NotContains(t, c.prevRetrans, statsConnKey(1, first))
+
+	infos[statsConnKey(1, first)] = &netlink.TCPInfo{Rtt: 100, Total_retrans: 9}
+	fill(first)
+	require.Equal(t, uint32(0), first.Retransmits)
+
+	// The baselines are kept when the network namespace can't be dumped
+	dumpErr = errors.New("dump failed")
+	dumps = 0
+	infos[statsConnKey(1, first)].Total_retrans = 12
+	fill(first, second)
+	require.Equal(t, uint32(0), first.RTT)
+	require.Equal(t, 1, dumps, "a failed network namespace must be dumped once")
+	require.Contains(t, c.prevRetrans, statsConnKey(1, first))
+
+	dumpErr = nil
+	fill(first)
+	require.Equal(t, uint32(3), first.Retransmits)
+
+	// The connections whose network namespace can't be retrieved are left
+	// untouched, and the ones that weren't dumped are forgotten
+	exited := newStats(0, "10.0.0.1", 1234, "10.0.0.2", 80)
+	fill(exited)
+	require.Equal(t, uint32(0), exited.RTT)
+	require.Empty(t, c.prevRetrans)
+}
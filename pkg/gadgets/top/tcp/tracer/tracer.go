@@ -54,6 +54,7 @@ type Tracer struct {
 	eventCallback      func(*top.Event[types.Stats])
 	done               chan bool
 	colMap             columns.ColumnMap[types.Stats]
+	tcpInfo            *tcpInfoCollector
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -64,6 +65,7 @@ func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
 		enricher:      enricher,
 		eventCallback: eventCallback,
 		done:          make(chan bool),
+		tcpInfo:       newTCPInfoCollector(),
 	}
 
 	if err := t.install(); err != nil {
@@ -207,6 +209,15 @@ func (t *Tracer) nextStats() ([]*types.Stats, error) {
 		}
 	}
 
+	if seconds := t.config.Interval.Seconds(); seconds > 0 {
+		for _, stat := range stats {
+			stat.SentRate = uint64(float64(stat.Sent) / seconds)
+			stat.ReceivedRate = uint64(float64(stat.Received) / seconds)
+		}
+	}
+
+	t.tcpInfo.fill(stats)
+
 	top.SortStats(stats, t.config.SortBy, &t.colMap)
 
 	return stats, nil
@@ -289,7 +300,8 @@ func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
 			TargetFamily: -1,
 			TargetPid:    -1,
 		},
-		done: make(chan bool),
+		done:    make(chan bool),
+		tcpInfo: newTCPInfoCollector(),
 	}
 	return tracer, nil
 }
@@ -17,6 +17,7 @@ package types
 import (
 	"fmt"
 	"syscall"
+	"time"
 
 	"github.com/docker/go-units"
 
@@ -56,6 +57,16 @@ type Stats struct {
 
 	Sent     uint64 `json:"sent,omitempty" column:"sent,order:1002"`
 	Received uint64 `json:"received,omitempty" column:"recv,order:1003"`
+
+	// SentRate and ReceivedRate are the throughput of the connection during
+	// the last interval, in bytes per second.
+	SentRate     uint64 `json:"sentRate,omitempty" column:"sentrate,order:1004"`
+	ReceivedRate uint64 `json:"receivedRate,omitempty" column:"recvrate,order:1005"`
+	// Retransmits is the number of segments retransmitted during the last
+	// interval and RTT the smoothed round trip time in microseconds, both
+	// taken from tcp_info.
+	Retransmits uint32 `json:"retransmits,omitempty" column:"retrans,order:1006"`
+	RTT         uint32 `json:"rtt,omitempty" column:"rtt,order:1007"`
 }
 
 func (e *Stats) GetEndpoints() []*eventtypes.L3Endpoint {
@@ -77,6 +88,15 @@ func GetColumns() *columns.Columns[Stats] {
 	cols.MustSetExtractor("recv", func(stats *Stats) any {
 		return fmt.Sprint(units.BytesSize(float64(stats.Received)))
 	})
+	cols.MustSetExtractor("sentrate", func(stats *Stats) any {
+		return fmt.Sprintf("%s/s", units.BytesSize(float64(stats.SentRate)))
+	})
+	cols.MustSetExtractor("recvrate", func(stats *Stats) any {
+		return fmt.Sprintf("%s/s", units.BytesSize(float64(stats.ReceivedRate)))
+	})
+	cols.MustSetExtractor("rtt", func(stats *Stats) any {
+		return fmt.Sprint(time.Duration(stats.RTT) * time.Microsecond)
+	})
 
 	eventtypes.MustAddVirtualL4EndpointColumn(
 		cols,
@@ -29,6 +29,7 @@ import (
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/bind/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
@@ -52,7 +53,7 @@ type Tracer struct {
 	ipv4Exit  link.Link
 	ipv6Entry link.Link
 	ipv6Exit  link.Link
-	reader    *perf.Reader
+	reader    *eventreader.PerfReader
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -139,7 +140,7 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching ipv6 kprobe: %w", err)
 	}
 
-	t.reader, err = perf.NewReader(t.objs.bindsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = eventreader.NewPerfReader(t.objs.bindsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -225,8 +226,7 @@ func (t *Tracer) run() {
 		}
 
 		if record.LostSamples > 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			t.eventCallback(types.Base(eventtypes.LostSamples(record.LostSamples)))
 			continue
 		}
 
@@ -34,6 +34,10 @@ type Event struct {
 	Gid       uint32 `json:"gid" column:"gid,template:gid,hide"`
 }
 
+func (e *Event) GetPid() uint32 {
+	return e.Pid
+}
+
 func GetColumns() *columns.Columns[Event] {
 	return columns.MustCreateColumns[Event]()
 }
@@ -29,6 +29,7 @@ import (
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/capabilities/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/syscalls"
@@ -49,7 +50,7 @@ type Tracer struct {
 	capExitLink   link.Link
 	tpSysEnter    link.Link
 	tpSysExit     link.Link
-	reader        *perf.Reader
+	reader        *eventreader.PerfReader
 	enricher      gadgets.DataEnricherByMntNs
 	eventCallback func(*types.Event)
 }
@@ -181,7 +182,7 @@ func (t *Tracer) install() error {
 	}
 	t.capExitLink = kretprobe
 
-	reader, err := perf.NewReader(t.objs.capabilitiesMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := eventreader.NewPerfReader(t.objs.capabilitiesMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -52,6 +52,10 @@ type Event struct {
 	CapsNames     []string `json:"capsNames,omitempty" column:"capsnames,hide"`
 }
 
+func (e *Event) GetPid() uint32 {
+	return e.Pid
+}
+
 func GetColumns() *columns.Columns[Event] {
 	cols := columns.MustCreateColumns[Event]()
 
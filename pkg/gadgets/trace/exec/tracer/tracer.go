@@ -28,6 +28,7 @@ import (
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/exec/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
@@ -49,7 +50,7 @@ type Tracer struct {
 	objs      execsnoopObjects
 	enterLink link.Link
 	exitLink  link.Link
-	reader    *perf.Reader
+	reader    *eventreader.PerfReader
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -119,7 +120,7 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching exit tracepoint: %w", err)
 	}
 
-	reader, err := perf.NewReader(t.objs.execsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := eventreader.NewPerfReader(t.objs.execsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -143,8 +144,7 @@ func (t *Tracer) run() {
 		}
 
 		if record.LostSamples > 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			t.eventCallback(types.Base(eventtypes.LostSamples(record.LostSamples)))
 			continue
 		}
 
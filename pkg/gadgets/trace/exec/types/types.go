@@ -37,6 +37,10 @@ type Event struct {
 	Cwd       string   `json:"cwd,omitempty" column:"cwd,width:40" columnTags:"param:cwd"`
 }
 
+func (e *Event) GetPid() uint32 {
+	return e.Pid
+}
+
 func GetColumns() *columns.Columns[Event] {
 	execColumns := columns.MustCreateColumns[Event]()
 
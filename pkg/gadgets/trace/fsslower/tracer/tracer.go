@@ -28,6 +28,7 @@ import (
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/fsslower/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
@@ -54,7 +55,7 @@ type Tracer struct {
 	openExitLink   link.Link
 	syncEnterLink  link.Link
 	syncExitLink   link.Link
-	reader         *perf.Reader
+	reader         *eventreader.PerfReader
 }
 
 type fsConf struct {
@@ -202,7 +203,7 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching kretprobe: %w", err)
 	}
 
-	t.reader, err = perf.NewReader(t.objs.fsslowerMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = eventreader.NewPerfReader(t.objs.fsslowerMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -226,8 +227,7 @@ func (t *Tracer) run() {
 		}
 
 		if record.LostSamples > 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			t.eventCallback(types.Base(eventtypes.LostSamples(record.LostSamples)))
 			continue
 		}
 
@@ -36,6 +36,10 @@ type Event struct {
 	File    string `json:"file,omitempty" column:"file,width:24,maxWidth:32"`
 }
 
+func (e *Event) GetPid() uint32 {
+	return e.Pid
+}
+
 func GetColumns() *columns.Columns[Event] {
 	return columns.MustCreateColumns[Event]()
 }
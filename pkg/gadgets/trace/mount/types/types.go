@@ -40,6 +40,10 @@ type Event struct {
 	FlagsRaw  uint64   `json:"flagsRaw,omitempty"`
 }
 
+func (e *Event) GetPid() uint32 {
+	return e.Pid
+}
+
 func GetColumns() *columns.Columns[Event] {
 	cols := columns.MustCreateColumns[Event]()
 
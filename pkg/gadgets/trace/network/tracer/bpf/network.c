@@ -6,6 +6,7 @@
 #include <linux/ip.h>
 #include <linux/in.h>
 #include <linux/udp.h>
+#include <linux/sctp.h>
 
 #include <bpf/bpf_helpers.h>
 #include <bpf/bpf_endian.h>
@@ -77,8 +78,23 @@ int ig_trace_net(struct __sk_buff *skb)
 			port = udph.dest;
 		else
 			return 0;
+	} else if (iph.protocol == IPPROTO_SCTP) {
+		// Read the SCTP common header and the first chunk header.
+		struct sctphdr sctph;
+		struct sctp_chunkhdr chunkh;
+		if (bpf_skb_load_bytes(skb, l4_off, &sctph, sizeof sctph))
+			return 0;
+		if (bpf_skb_load_bytes(skb, l4_off + sizeof sctph, &chunkh,
+				       sizeof chunkh))
+			return 0;
+
+		// Like the TCP-SYN, the INIT chunk starts an association.
+		if (chunkh.type != SCTP_CID_INIT)
+			return 0;
+
+		port = sctph.dest;
 	} else {
-		// Skip packets with IP protocol other than TCP/UDP.
+		// Skip packets with IP protocol other than TCP/UDP/SCTP.
 		return 0;
 	}
 
@@ -28,6 +28,7 @@ import (
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/oomkill/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
@@ -42,7 +43,7 @@ type Tracer struct {
 	config        *Config
 	objs          oomkillObjects
 	oomLink       link.Link
-	reader        *perf.Reader
+	reader        *eventreader.PerfReader
 	enricher      gadgets.DataEnricherByMntNs
 	eventCallback func(*types.Event)
 }
@@ -96,7 +97,7 @@ func (t *Tracer) install() error {
 	}
 	t.oomLink = kprobe
 
-	reader, err := perf.NewReader(t.objs.oomkillMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := eventreader.NewPerfReader(t.objs.oomkillMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -31,6 +31,7 @@ import (
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/signal/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 
@@ -58,7 +59,7 @@ type Tracer struct {
 	enterTgkillLink    link.Link
 	exitTgkillLink     link.Link
 	signalGenerateLink link.Link
-	reader             *perf.Reader
+	reader             *eventreader.PerfReader
 
 	enricher      gadgets.DataEnricherByMntNs
 	eventCallback func(*types.Event)
@@ -170,7 +171,7 @@ func (t *Tracer) install() error {
 		}
 	}
 
-	t.reader, err = perf.NewReader(t.objs.sigsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = eventreader.NewPerfReader(t.objs.sigsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -193,8 +194,7 @@ func (t *Tracer) run() {
 		}
 
 		if record.LostSamples > 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			t.eventCallback(types.Base(eventtypes.LostSamples(record.LostSamples)))
 			continue
 		}
 
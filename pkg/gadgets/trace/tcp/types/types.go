@@ -34,6 +34,10 @@ type Event struct {
 	DstEndpoint eventtypes.L4Endpoint `json:"dst,omitempty" column:"dst"`
 }
 
+func (e *Event) GetPid() uint32 {
+	return e.Pid
+}
+
 func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
 	return []*eventtypes.L3Endpoint{&e.SrcEndpoint.L3Endpoint, &e.DstEndpoint.L3Endpoint}
 }
@@ -37,6 +37,10 @@ type Event struct {
 	Latency time.Duration `json:"latency,omitempty" column:"latency,minWidth:8,align:right,order:4000" columnTags:"param:latency"`
 }
 
+func (e *Event) GetPid() uint32 {
+	return e.Pid
+}
+
 func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
 	return []*eventtypes.L3Endpoint{&e.SrcEndpoint.L3Endpoint, &e.DstEndpoint.L3Endpoint}
 }
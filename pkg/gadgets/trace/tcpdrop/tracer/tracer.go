@@ -31,8 +31,10 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfgen"
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/socketenricher"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/tcpdrop/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kernelbtf"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/tcpbits"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
@@ -46,7 +48,7 @@ type Tracer struct {
 
 	objs         tcpdropObjects
 	kfreeSkbLink link.Link
-	reader       *perf.Reader
+	reader       *eventreader.PerfReader
 }
 
 func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
@@ -88,7 +90,7 @@ func (t *Tracer) close() {
 }
 
 func (t *Tracer) loadDropReasons() error {
-	btfSpec, err := btf.LoadKernelSpec()
+	btfSpec, err := kernelbtf.Spec()
 	if err != nil {
 		return fmt.Errorf("loading kernel spec: %w", err)
 	}
@@ -147,7 +149,7 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching tracepoint kfree_skb: %w", err)
 	}
 
-	reader, err := perf.NewReader(t.objs.tcpdropMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := eventreader.NewPerfReader(t.objs.tcpdropMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -171,8 +173,7 @@ func (t *Tracer) run() {
 		}
 
 		if record.LostSamples > 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			t.eventCallback(types.Base(eventtypes.LostSamples(record.LostSamples)))
 			continue
 		}
 
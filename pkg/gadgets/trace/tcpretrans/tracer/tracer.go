@@ -29,6 +29,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfgen"
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/eventreader"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/internal/socketenricher"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/tcpretrans/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/tcpbits"
@@ -44,7 +45,7 @@ type Tracer struct {
 
 	objs              tcpretransObjects
 	retransmitSkbLink link.Link
-	reader            *perf.Reader
+	reader            *eventreader.PerfReader
 }
 
 func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
@@ -118,7 +119,7 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching tracepoint tcp_retransmit_skb: %w", err)
 	}
 
-	reader, err := perf.NewReader(t.objs.tcpretransMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := eventreader.NewPerfReader(t.objs.tcpretransMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
 	if err != nil {
 		return fmt.Errorf("creating perf ring buffer: %w", err)
 	}
@@ -142,8 +143,7 @@ func (t *Tracer) run() {
 		}
 
 		if record.LostSamples > 0 {
-			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
-			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			t.eventCallback(types.Base(eventtypes.LostSamples(record.LostSamples)))
 			continue
 		}
 
@@ -15,6 +15,9 @@
 package tracer
 
 import (
+	"fmt"
+	"strings"
+
 	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/traceloop/types"
@@ -41,7 +44,35 @@ func (g *GadgetDesc) Description() string {
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return nil
+	return params.ParamDescs{
+		{
+			Key:         types.ParamSyscallFilters,
+			Title:       "Syscall filters",
+			Description: "Only show the given syscalls, e.g. openat,read",
+			TypeHint:    params.TypeString,
+		},
+		{
+			Key:          types.ParamMinLatency,
+			Title:        "Minimum latency",
+			DefaultValue: "0",
+			Description:  "Only show syscalls that took longer than the given duration, e.g. 10ms",
+			TypeHint:     params.TypeDuration,
+		},
+		{
+			Key:          types.ParamSince,
+			Title:        "Since",
+			DefaultValue: "0",
+			Description:  "Only show syscalls issued during the given duration before the trace is read, e.g. 5m",
+			TypeHint:     params.TypeDuration,
+		},
+		{
+			Key:          types.ParamUntil,
+			Title:        "Until",
+			DefaultValue: "0",
+			Description:  "Only show syscalls issued earlier than the given duration before the trace is read, e.g. 1m",
+			TypeHint:     params.TypeDuration,
+		},
+	}
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
@@ -52,6 +83,33 @@ func (g *GadgetDesc) EventPrototype() any {
 	return &types.Event{}
 }
 
+func (g *GadgetDesc) OutputFormats() (gadgets.OutputFormats, string) {
+	return gadgets.OutputFormats{
+		"strace": gadgets.OutputFormat{
+			Name:        "Strace",
+			Description: "A strace like output",
+			Transform: func(data any) ([]byte, error) {
+				var events []*types.Event
+				switch typ := data.(type) {
+				case *types.Event:
+					events = []*types.Event{typ}
+				case []*types.Event:
+					events = typ
+				default:
+					return nil, fmt.Errorf("type must be *types.Event or []*types.Event and is: %T", data)
+				}
+
+				var builder strings.Builder
+				if err := types.WriteStrace(&builder, events); err != nil {
+					return nil, err
+				}
+
+				return []byte(strings.TrimSuffix(builder.String(), "\n")), nil
+			},
+		},
+	}, "columns"
+}
+
 func init() {
 	gadgetregistry.Register(&GadgetDesc{})
 }
@@ -38,6 +38,7 @@ import (
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/traceloop/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/syscalls"
 )
@@ -536,6 +537,12 @@ func (t *Tracer) Read(containerID string) ([]*types.Event, error) {
 				}
 
 				event.Retval = retToStr(exitEvent.retval)
+				// The exit event carries the time the syscall returned
+				// while its monotonic timestamp is the one of the enter
+				// event.
+				if gadgets.DetectBpfKtimeGetBootNs() && exitEvent.bootTimestamp >= enterEvent.bootTimestamp {
+					event.Duration = exitEvent.bootTimestamp - enterEvent.bootTimestamp
+				}
 
 				delete(syscallEnterEventsMap, enterTimestamp)
 				delete(syscallExitEventsMap, enterTimestamp)
@@ -663,12 +670,28 @@ func (t *Tracer) Init(gadgetCtx gadgets.GadgetContext) error {
 		return fmt.Errorf("installing tracer: %w", err)
 	}
 
+	// Validate the filter now, it's created again when reading the events
+	// because the time window is relative to that moment.
+	if _, err := filterFromParams(gadgetCtx.GadgetParams()); err != nil {
+		t.close()
+		return err
+	}
+
 	// Context must be created before the first call to AttachContainer
 	t.gadgetCtx = gadgetCtx
 	t.ctx, t.cancel = gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
 	return nil
 }
 
+func filterFromParams(params *params.Params) (*types.Filter, error) {
+	return types.ParseFilter(
+		params.Get(types.ParamSyscallFilters).AsString(),
+		params.Get(types.ParamMinLatency).AsString(),
+		params.Get(types.ParamSince).AsString(),
+		params.Get(types.ParamUntil).AsString(),
+	)
+}
+
 func (t *Tracer) SetEventHandler(handler any) {
 	nh, ok := handler.(func(ev *types.Event))
 	if !ok {
@@ -687,12 +710,17 @@ func (t *Tracer) AttachContainer(container *containercollection.Container) error
 	go func() {
 		defer t.waitGroup.Done()
 		<-t.ctx.Done()
+		filter, err := filterFromParams(t.gadgetCtx.GadgetParams())
+		if err != nil {
+			t.gadgetCtx.Logger().Debugf("error creating filter: %v", err)
+			return
+		}
 		evs, err := t.Read(container.Runtime.ContainerID)
 		if err != nil {
 			t.gadgetCtx.Logger().Debugf("error reading from container %s: %v", container.Runtime.ContainerID, err)
 			return
 		}
-		for _, ev := range evs {
+		for _, ev := range types.FilterEvents(evs, filter) {
 			ev.SetContainerMetadata(&container.K8s.BasicK8sMetadata, &container.Runtime.BasicRuntimeMetadata)
 			t.eventCallback(ev)
 		}
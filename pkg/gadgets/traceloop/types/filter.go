@@ -0,0 +1,145 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Keys of the parameters used to pass a Filter to the traceloop gadget.
+const (
+	ParamSyscallFilters = "syscall-filters"
+	ParamMinLatency     = "min-latency"
+	ParamSince          = "since"
+	ParamUntil          = "until"
+)
+
+// Filter selects which of the recorded syscalls are reported. The zero value
+// selects all of them.
+type Filter struct {
+	// Syscalls contains the names of the syscalls to report. All syscalls
+	// are reported if it's empty.
+	Syscalls []string
+	// MinLatency is the minimum duration of the syscalls to report. Syscalls
+	// without a known duration (unfinished ones, exit, etc.) are not
+	// reported when it's set.
+	MinLatency time.Duration
+	// Since and Until restrict the reported syscalls to the given time
+	// window. Zero values mean no restriction.
+	Since time.Time
+	Until time.Time
+}
+
+// ParseFilter creates a Filter from the values of the traceloop parameters.
+// since and until are relative to now, e.g. "5m" and "1m" only keep the
+// syscalls issued between five minutes and one minute ago.
+func ParseFilter(syscalls, minLatency, since, until string) (*Filter, error) {
+	f := &Filter{}
+	now := time.Now()
+
+	for _, syscall := range strings.Split(syscalls, ",") {
+		syscall = strings.TrimSpace(syscall)
+		if syscall != "" {
+			f.Syscalls = append(f.Syscalls, syscall)
+		}
+	}
+
+	if minLatency != "" {
+		d, err := time.ParseDuration(minLatency)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s %q: %w", ParamMinLatency, minLatency, err)
+		}
+		f.MinLatency = d
+	}
+
+	if since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s %q: %w", ParamSince, since, err)
+		}
+		if d > 0 {
+			f.Since = now.Add(-d)
+		}
+	}
+
+	if until != "" {
+		d, err := time.ParseDuration(until)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s %q: %w", ParamUntil, until, err)
+		}
+		if d > 0 {
+			f.Until = now.Add(-d)
+		}
+	}
+
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return nil, fmt.Errorf("%s must be longer than %s", ParamSince, ParamUntil)
+	}
+
+	return f, nil
+}
+
+// Match returns true if the event must be reported.
+func (f *Filter) Match(ev *Event) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Syscalls) > 0 {
+		found := false
+		for _, syscall := range f.Syscalls {
+			if ev.Syscall == syscall {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.MinLatency > 0 && time.Duration(ev.Duration) < f.MinLatency {
+		return false
+	}
+
+	if !f.Since.IsZero() && ev.Timestamp != 0 && time.Unix(0, int64(ev.Timestamp)).Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && ev.Timestamp != 0 && time.Unix(0, int64(ev.Timestamp)).After(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// FilterEvents returns the events matching the filter. The order of the
+// events is kept.
+func FilterEvents(events []*Event, f *Filter) []*Event {
+	if f == nil {
+		return events
+	}
+
+	ret := make([]*Event, 0, len(events))
+	for _, ev := range events {
+		if f.Match(ev) {
+			ret = append(ret, ev)
+		}
+	}
+
+	return ret
+}
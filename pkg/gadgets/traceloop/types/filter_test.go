@@ -0,0 +1,109 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	f, err := ParseFilter(" openat, read,,", "10ms", "0", "0")
+	require.NoError(t, err)
+	require.Equal(t, []string{"openat", "read"}, f.Syscalls)
+	require.Equal(t, 10*time.Millisecond, f.MinLatency)
+	require.True(t, f.Since.IsZero())
+	require.True(t, f.Until.IsZero())
+
+	f, err = ParseFilter("", "", "5m", "1m")
+	require.NoError(t, err)
+	require.Equal(t, 4*time.Minute, f.Until.Sub(f.Since))
+
+	_, err = ParseFilter("", "foo", "", "")
+	require.Error(t, err)
+
+	_, err = ParseFilter("", "", "foo", "")
+	require.Error(t, err)
+
+	_, err = ParseFilter("", "", "", "foo")
+	require.Error(t, err)
+
+	// The window must not be empty
+	_, err = ParseFilter("", "", "1m", "5m")
+	require.Error(t, err)
+}
+
+func TestFilterEvents(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	events := []*Event{
+		{
+			Event:    eventtypes.Event{Timestamp: eventtypes.Time(now.Add(-time.Hour).UnixNano())},
+			Syscall:  "openat",
+			Duration: uint64(20 * time.Millisecond),
+		},
+		{
+			Event:    eventtypes.Event{Timestamp: eventtypes.Time(now.UnixNano())},
+			Syscall:  "openat",
+			Duration: uint64(time.Millisecond),
+		},
+		{
+			Event:    eventtypes.Event{Timestamp: eventtypes.Time(now.UnixNano())},
+			Syscall:  "read",
+			Duration: uint64(20 * time.Millisecond),
+		},
+	}
+
+	require.Equal(t, events, FilterEvents(events, nil))
+	require.Equal(t, events, FilterEvents(events, &Filter{}))
+	require.Equal(t, events[:2], FilterEvents(events, &Filter{Syscalls: []string{"openat"}}))
+	require.Equal(t, []*Event{events[0], events[2]}, FilterEvents(events, &Filter{MinLatency: 10 * time.Millisecond}))
+	require.Equal(t, events[1:], FilterEvents(events, &Filter{Since: now.Add(-time.Minute)}))
+	require.Equal(t, events[:1], FilterEvents(events, &Filter{Until: now.Add(-time.Minute)}))
+	require.Equal(t, events[2:], FilterEvents(events, &Filter{
+		Syscalls:   []string{"read"},
+		MinLatency: 10 * time.Millisecond,
+		Since:      now.Add(-time.Minute),
+	}))
+}
+
+func TestStraceLine(t *testing.T) {
+	t.Parallel()
+
+	content := "/etc/passwd"
+	ev := &Event{
+		Event:   eventtypes.Event{Timestamp: 0},
+		Pid:     42,
+		Syscall: "openat",
+		Parameters: []SyscallParam{
+			{Name: "dfd", Value: "4294967196"},
+			{Name: "filename", Value: "140736754175569", Content: &content},
+		},
+		Retval:   "3",
+		Duration: uint64(1500 * time.Microsecond),
+	}
+	require.Equal(t, "42      openat(4294967196, /etc/passwd) = 3 <0.001500>", StraceLine(ev))
+
+	ev.Retval = "X"
+	ev.Duration = 0
+	require.Equal(t, "42      openat(4294967196, /etc/passwd) = ?", StraceLine(ev))
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// StraceLine returns a representation of the event similar to the one used by
+// "strace -f -tt -T": pid, time, syscall with its parameters, return value and
+// duration.
+func StraceLine(ev *Event) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%-7d ", ev.Pid)
+	if ev.Timestamp != 0 {
+		sb.WriteString(time.Unix(0, int64(ev.Timestamp)).Format("15:04:05.000000"))
+		sb.WriteString(" ")
+	}
+
+	sb.WriteString(ev.Syscall)
+	sb.WriteString("(")
+	for idx, p := range ev.Parameters {
+		if idx > 0 {
+			sb.WriteString(", ")
+		}
+		if p.Content != nil {
+			sb.WriteString(*p.Content)
+		} else {
+			sb.WriteString(p.Value)
+		}
+	}
+	sb.WriteString(")")
+
+	switch ev.Retval {
+	case "X":
+		sb.WriteString(" = ?")
+	case "unfinished":
+		sb.WriteString(" <unfinished ...>")
+	default:
+		sb.WriteString(" = ")
+		sb.WriteString(ev.Retval)
+	}
+
+	if ev.Duration != 0 {
+		fmt.Fprintf(&sb, " <%.6f>", time.Duration(ev.Duration).Seconds())
+	}
+
+	return sb.String()
+}
+
+// WriteStrace writes the events to output, one strace-like line per event.
+func WriteStrace(output io.Writer, events []*Event) error {
+	for _, ev := range events {
+		if _, err := fmt.Fprintln(output, StraceLine(ev)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -38,6 +38,9 @@ type Event struct {
 	Syscall    string         `json:"syscall,omitempty" column:"syscall,template:syscall"`
 	Parameters []SyscallParam `json:"parameters,omitempty" column:"params,width:40"`
 	Retval     string         `json:"ret,omitempty" column:"ret,width:3"`
+	// Duration of the syscall in nanoseconds. It's only available if both
+	// the enter and exit events were recorded.
+	Duration uint64 `json:"duration,omitempty" column:"duration,width:12,align:right,hide"`
 }
 
 type TraceloopInfo struct {
@@ -19,12 +19,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/cilium/ebpf"
 	"golang.org/x/sys/unix"
 
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/common"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/shared"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -cflags ${CFLAGS} containersmap ./bpf/containers-map.c -- -I./bpf/ -I../../
@@ -53,17 +55,51 @@ func copyToC(dest *[NameMaxLength]byte, source string) {
 // External tools such as tracee or bpftrace could also benefit from this just
 // by using this "containers" map (other interaction with Inspektor Gadget is
 // not necessary for this).
+//
+// The map is shared by all the ContainersMap of the process using the same pin
+// path, it's removed when the last one is closed.
 type ContainersMap struct {
 	// containersMap is the global map at /sys/fs/bpf/gadget/containers
 	// exposing container details for each mount namespace.
 	containersMap *ebpf.Map
 
-	coll *ebpf.Collection
+	release func()
+}
 
+type loadedMap struct {
+	coll    *ebpf.Collection
 	pinPath string
 }
 
+var (
+	sharedMapsMu sync.Mutex
+	// sharedMaps are the maps loaded, indexed by pin path
+	sharedMaps = map[string]*shared.Value[*loadedMap]{}
+)
+
 func NewContainersMap(pinPath string) (*ContainersMap, error) {
+	sharedMapsMu.Lock()
+	sharedMap, ok := sharedMaps[pinPath]
+	if !ok {
+		sharedMap = shared.New(func() (*loadedMap, error) {
+			return loadMap(pinPath)
+		}, (*loadedMap).close)
+		sharedMaps[pinPath] = sharedMap
+	}
+	sharedMapsMu.Unlock()
+
+	m, release, err := sharedMap.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContainersMap{
+		containersMap: m.coll.Maps[BPFMapName],
+		release:       release,
+	}, nil
+}
+
+func loadMap(pinPath string) (*loadedMap, error) {
 	if pinPath != "" {
 		if err := os.Mkdir(pinPath, 0o700); err != nil && !errors.Is(err, unix.EEXIST) {
 			return nil, fmt.Errorf("creating folder for pinning bpf maps: %w", err)
@@ -88,17 +124,23 @@ func NewContainersMap(pinPath string) (*ContainersMap, error) {
 		return nil, fmt.Errorf("creating BPF collection: %w", err)
 	}
 
-	m, ok := coll.Maps[BPFMapName]
-	if !ok {
+	if _, ok := coll.Maps[BPFMapName]; !ok {
+		coll.Close()
 		return nil, fmt.Errorf("map %s not found", BPFMapName)
 	}
-	return &ContainersMap{
-		containersMap: m,
-		pinPath:       pinPath,
-		coll:          coll,
+	return &loadedMap{
+		coll:    coll,
+		pinPath: pinPath,
 	}, nil
 }
 
+func (m *loadedMap) close() {
+	if m.pinPath != "" {
+		os.Remove(filepath.Join(m.pinPath, BPFMapName))
+	}
+	m.coll.Close()
+}
+
 func (cm *ContainersMap) addContainerInMap(c *containercollection.Container) {
 	if cm.containersMap == nil || c.Mntns == 0 {
 		return
@@ -143,10 +185,10 @@ func (cm *ContainersMap) ContainersMap() *ebpf.Map {
 	return cm.containersMap
 }
 
+// Close releases the map, it's removed if no other ContainersMap uses it.
 func (cm *ContainersMap) Close() {
 	if cm == nil {
 		return
 	}
-	os.Remove(filepath.Join(cm.pinPath, BPFMapName))
-	cm.coll.Close()
+	cm.release()
 }
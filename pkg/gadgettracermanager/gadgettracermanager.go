@@ -30,6 +30,7 @@ import (
 
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	containerhook "github.com/inspektor-gadget/inspektor-gadget/pkg/container-hook"
+	containerutilsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
 	containersmap "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/containers-map"
@@ -267,6 +268,7 @@ func NewServer(conf *Conf) (*GadgetTracerManager, error) {
 		opts = append(opts, containercollection.WithCgroupEnrichment())
 		opts = append(opts, containercollection.WithLinuxNamespaceEnrichment())
 		opts = append(opts, containercollection.WithKubernetesEnrichment(g.nodeName, nil))
+		opts = append(opts, containercollection.WithSandboxDetection())
 		opts = append(opts, containercollection.WithTracerCollection(g.tracerCollection))
 	}
 
@@ -305,6 +307,15 @@ func NewServer(conf *Conf) (*GadgetTracerManager, error) {
 		log.Infof("GadgetTracerManager: hook mode: fanotify+ebpf")
 		opts = append(opts, containercollection.WithContainerFanotifyEbpf())
 		opts = append(opts, containercollection.WithInitialKubernetesContainers(g.nodeName))
+	case "cri-events":
+		log.Infof("GadgetTracerManager: hook mode: cri-events")
+		runtimeName, err := containercollection.GetNodeContainerRuntime(g.nodeName)
+		if err != nil {
+			return nil, fmt.Errorf("getting container runtime of node %q: %w", g.nodeName, err)
+		}
+		opts = append(opts, containercollection.WithCRIEvents(&containerutilsTypes.RuntimeConfig{
+			Name: runtimeName,
+		}))
 	default:
 		return nil, fmt.Errorf("invalid hook mode: %s", conf.HookMode)
 	}
@@ -314,6 +325,11 @@ func NewServer(conf *Conf) (*GadgetTracerManager, error) {
 		opts = append(opts, containercollection.WithFallbackPodInformer(g.nodeName))
 	}
 
+	// After the options providing the initial containers
+	if conf.StateFile != "" && !conf.TestOnly {
+		opts = append(opts, containercollection.WithPersistentState(conf.StateFile))
+	}
+
 	err = g.ContainerCollection.Initialize(opts...)
 	if err != nil {
 		return nil, err
@@ -337,7 +353,10 @@ type Conf struct {
 	NodeName            string
 	HookMode            string
 	FallbackPodInformer bool
-	TestOnly            bool
+	// StateFile is where the containers are saved to be reloaded after a
+	// restart. Empty to disable.
+	StateFile string
+	TestOnly  bool
 }
 
 // Close releases any resource that could be in use by the tracer manager, like
@@ -0,0 +1,89 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package generator generates load at a controlled rate to test the
+// components processing the events of the gadgets, like the sinks, the
+// operators or the streaming API, before using them in production. It either
+// replays recorded events or synthesizes the activity traced by the gadgets,
+// like executing programs, opening files and connecting to servers, in new
+// namespaces to look like containers.
+package generator
+
+import (
+	"context"
+	"time"
+)
+
+// Stats are the statistics of a generation.
+type Stats struct {
+	// Events is the number of events replayed or operations synthesized
+	Events uint64 `json:"events"`
+
+	// Errors is the number of synthesized operations that failed
+	Errors uint64 `json:"errors"`
+
+	// Duration is how long the generation took
+	Duration time.Duration `json:"duration"`
+}
+
+// Rate returns the number of events generated per second.
+func (s Stats) Rate() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Events) / s.Duration.Seconds()
+}
+
+// pacer spaces the events to send them at a given rate. It doesn't sleep
+// after being late, e.g. when the events are slow to generate, so that the
+// average rate is kept.
+type pacer struct {
+	start    time.Time
+	interval time.Duration
+	n        uint64
+}
+
+// newPacer returns a pacer for rate events per second, or for as many events
+// as possible if rate isn't positive.
+func newPacer(rate float64) *pacer {
+	p := &pacer{start: time.Now()}
+	if rate > 0 {
+		p.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return p
+}
+
+// wait waits for the time to send the next event.
+func (p *pacer) wait(ctx context.Context) error {
+	next := p.start.Add(time.Duration(p.n) * p.interval)
+	p.n++
+	return sleepUntil(ctx, next)
+}
+
+// sleepUntil returns once deadline is reached, or with an error if ctx is
+// done before.
+func sleepUntil(ctx context.Context, deadline time.Time) error {
+	d := time.Until(deadline)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
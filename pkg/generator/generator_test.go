@@ -0,0 +1,104 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+)
+
+const recorded = `{"timestamp":1000000000,"comm":"a"}
+
+{"timestamp":1050000000,"comm":"b"}
+{"timestamp":1100000000,"comm":"c"}
+`
+
+func replay(t *testing.T, events string, config ReplayConfig) ([]string, Stats) {
+	t.Helper()
+
+	var replayed []string
+	stats, err := Replay(context.Background(), strings.NewReader(events), func(event []byte) error {
+		replayed = append(replayed, string(event))
+		return nil
+	}, config)
+	require.NoError(t, err)
+	return replayed, stats
+}
+
+func TestReplay(t *testing.T) {
+	// With the recorded timing
+	replayed, stats := replay(t, recorded, ReplayConfig{Speed: 1})
+	require.Equal(t, []string{
+		`{"timestamp":1000000000,"comm":"a"}`,
+		`{"timestamp":1050000000,"comm":"b"}`,
+		`{"timestamp":1100000000,"comm":"c"}`,
+	}, replayed)
+	require.Equal(t, uint64(3), stats.Events)
+	require.GreaterOrEqual(t, stats.Duration, 100*time.Millisecond)
+
+	// Twice as fast
+	_, stats = replay(t, recorded, ReplayConfig{Speed: 2})
+	require.GreaterOrEqual(t, stats.Duration, 50*time.Millisecond)
+	require.Less(t, stats.Duration, 100*time.Millisecond)
+
+	// At a given rate, looping over the events
+	replayed, stats = replay(t, recorded, ReplayConfig{Rate: 100, Count: 5})
+	require.Len(t, replayed, 5)
+	require.Equal(t, replayed[0], replayed[3])
+	require.GreaterOrEqual(t, stats.Duration, 40*time.Millisecond)
+}
+
+func TestReplayErrors(t *testing.T) {
+	_, err := Replay(context.Background(), strings.NewReader(""), func([]byte) error { return nil }, ReplayConfig{})
+	require.Error(t, err)
+
+	_, err = Replay(context.Background(), strings.NewReader("{}\nfoo\n"), func([]byte) error { return nil }, ReplayConfig{})
+	require.ErrorContains(t, err, "line 2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stats, err := Replay(ctx, strings.NewReader(recorded), func([]byte) error { return nil }, ReplayConfig{Rate: 1})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, stats.Events)
+}
+
+func TestSynthesize(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	s, err := NewSynthesizer(SynthesizeConfig{
+		Count:      30,
+		Namespaces: 2,
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	mntns := s.MountNamespaces()
+	require.Len(t, mntns, 2)
+	require.NotEqual(t, mntns[0], mntns[1])
+
+	stats, err := s.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(30), stats.Events)
+	require.Zero(t, stats.Errors)
+
+	_, err = NewSynthesizer(SynthesizeConfig{Activities: []Activity{"foo"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxEventSize is the maximum size of a recorded event
+const maxEventSize = 1 << 20
+
+// ReplayConfig configures how recorded events are replayed.
+type ReplayConfig struct {
+	// Rate is the number of events replayed per second. When it's zero, the
+	// events are replayed with the intervals between their timestamps.
+	Rate float64
+
+	// Speed divides the intervals between the timestamps of the events when
+	// Rate is zero, e.g. 2 replays them twice as fast as recorded. Zero
+	// replays them as fast as possible.
+	Speed float64
+
+	// Count is the number of events to replay, zero to replay them all once.
+	// The recorded events are replayed from the beginning again until Count
+	// events have been replayed.
+	Count uint64
+}
+
+// recordedEvent is an event as printed by the gadgets with `-o json`, one per
+// line.
+type recordedEvent struct {
+	line      []byte
+	timestamp int64
+}
+
+// readEvents reads the events recorded in r.
+func readEvents(r io.Reader) ([]recordedEvent, error) {
+	var events []recordedEvent
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxEventSize)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var ev struct {
+			Timestamp int64 `json:"timestamp"`
+		}
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return nil, fmt.Errorf("parsing event at line %d: %w", n, err)
+		}
+		events = append(events, recordedEvent{
+			line:      bytes.Clone(line),
+			timestamp: ev.Timestamp,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Replay calls handler with the events recorded in r, as printed by the
+// gadgets with `-o json`, at the configured rate. It returns when all the
+// events have been replayed, when ctx is done or when handler fails.
+func Replay(ctx context.Context, r io.Reader, handler func(event []byte) error, config ReplayConfig) (stats Stats, err error) {
+	events, err := readEvents(r)
+	if err != nil {
+		return Stats{}, err
+	}
+	if len(events) == 0 {
+		return Stats{}, errors.New("no events to replay")
+	}
+
+	count := config.Count
+	if count == 0 {
+		count = uint64(len(events))
+	}
+
+	start := time.Now()
+	defer func() {
+		stats.Duration = time.Since(start)
+	}()
+
+	p := newPacer(config.Rate)
+	// Start of the current pass over the recorded events, and timestamp of
+	// its first event, to replay the events with their recorded intervals
+	passStart := start
+	var firstTimestamp int64
+
+	for stats.Events < count {
+		i := int(stats.Events % uint64(len(events)))
+		ev := events[i]
+		if i == 0 {
+			passStart = time.Now()
+			firstTimestamp = ev.timestamp
+		}
+
+		if config.Rate > 0 {
+			err = p.wait(ctx)
+		} else if config.Speed > 0 && ev.timestamp > firstTimestamp {
+			offset := time.Duration(float64(ev.timestamp-firstTimestamp) / config.Speed)
+			err = sleepUntil(ctx, passStart.Add(offset))
+		} else {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		if err := handler(ev.line); err != nil {
+			return stats, err
+		}
+		stats.Events++
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,313 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Activity is a kind of operation synthesized to generate events.
+type Activity string
+
+const (
+	// ActivityExec executes a program, for the exec gadgets
+	ActivityExec Activity = "exec"
+
+	// ActivityOpen opens a file, for the open gadgets
+	ActivityOpen Activity = "open"
+
+	// ActivityConnect connects to a TCP server, for the network gadgets
+	ActivityConnect Activity = "connect"
+)
+
+// Activities are all the activities that can be synthesized.
+var Activities = []Activity{ActivityExec, ActivityOpen, ActivityConnect}
+
+// DefaultExecPath is the program executed by ActivityExec by default.
+const DefaultExecPath = "/bin/true"
+
+// SynthesizeConfig configures the synthesized activity.
+type SynthesizeConfig struct {
+	// Activities are synthesized in turn, all of them if it's empty
+	Activities []Activity
+
+	// Rate is the number of operations per second, zero for as many as
+	// possible
+	Rate float64
+
+	// Count is the number of operations to do, zero to do them until the
+	// context is done
+	Count uint64
+
+	// Namespaces is the number of sets of mount and network namespaces the
+	// operations are spread across, to look like as many containers. Zero
+	// does the operations in the namespaces of the process, with a single
+	// worker.
+	Namespaces int
+
+	// ExecPath is the program executed by ActivityExec, DefaultExecPath if
+	// it's empty
+	ExecPath string
+}
+
+// Synthesizer synthesizes activity, see SynthesizeConfig.
+type Synthesizer struct {
+	config  SynthesizeConfig
+	workers []*worker
+}
+
+// NewSynthesizer creates the namespaces and the resources, like files and
+// servers, used by the activity. Creating namespaces requires CAP_SYS_ADMIN.
+func NewSynthesizer(config SynthesizeConfig) (*Synthesizer, error) {
+	if len(config.Activities) == 0 {
+		config.Activities = Activities
+	}
+	for _, activity := range config.Activities {
+		switch activity {
+		case ActivityExec, ActivityOpen, ActivityConnect:
+		default:
+			return nil, fmt.Errorf("unknown activity %q", activity)
+		}
+	}
+	if config.ExecPath == "" {
+		config.ExecPath = DefaultExecPath
+	}
+	if config.Namespaces < 0 {
+		return nil, fmt.Errorf("invalid number of namespaces %d", config.Namespaces)
+	}
+
+	s := &Synthesizer{config: config}
+
+	workers := config.Namespaces
+	if workers == 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		w, err := newWorker(config.Namespaces > 0, config.ExecPath)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("creating worker: %w", err)
+		}
+		s.workers = append(s.workers, w)
+	}
+
+	return s, nil
+}
+
+// MountNamespaces returns the mount namespaces the activity is done in, e.g.
+// to filter the events of a gadget.
+func (s *Synthesizer) MountNamespaces() []uint64 {
+	ret := make([]uint64, 0, len(s.workers))
+	for _, w := range s.workers {
+		ret = append(ret, w.mntns)
+	}
+	return ret
+}
+
+// Run does the operations at the configured rate. It returns when Count
+// operations have been done, or with the error of ctx when it's done before.
+// The operations that fail are counted in the errors of the stats.
+func (s *Synthesizer) Run(ctx context.Context) (Stats, error) {
+	var events, errs atomic.Uint64
+
+	activities := make(chan Activity)
+	var wg sync.WaitGroup
+	for _, w := range s.workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			for activity := range activities {
+				if err := w.run(activity); err != nil {
+					errs.Add(1)
+				}
+				events.Add(1)
+			}
+		}(w)
+	}
+
+	start := time.Now()
+	p := newPacer(s.config.Rate)
+	var err error
+	for n := uint64(0); s.config.Count == 0 || n < s.config.Count; n++ {
+		if err = p.wait(ctx); err != nil {
+			break
+		}
+		select {
+		case activities <- s.config.Activities[n%uint64(len(s.config.Activities))]:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(activities)
+	wg.Wait()
+
+	stats := Stats{
+		Events:   events.Load(),
+		Errors:   errs.Load(),
+		Duration: time.Since(start),
+	}
+	return stats, err
+}
+
+// Close removes the namespaces and resources of the activity.
+func (s *Synthesizer) Close() {
+	for _, w := range s.workers {
+		w.close()
+	}
+	s.workers = nil
+}
+
+// worker does the operations in its namespaces, from a goroutine locked to a
+// thread that entered them.
+type worker struct {
+	execPath string
+	mntns    uint64
+
+	tasks   chan func() error
+	replies chan error
+
+	file     string
+	listener net.Listener
+}
+
+func newWorker(newNamespaces bool, execPath string) (*worker, error) {
+	w := &worker{
+		execPath: execPath,
+		tasks:    make(chan func() error),
+		replies:  make(chan error),
+	}
+
+	go w.loop(newNamespaces)
+	if err := <-w.replies; err != nil {
+		return nil, err
+	}
+
+	if err := w.do(w.setup); err != nil {
+		w.close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *worker) loop(newNamespaces bool) {
+	runtime.LockOSThread()
+	if newNamespaces {
+		// The thread isn't unlocked to let it die with the namespaces
+		// when the worker is closed.
+		if err := unix.Unshare(syscall.CLONE_NEWNS | syscall.CLONE_NEWNET); err != nil {
+			w.replies <- fmt.Errorf("creating namespaces: %w", err)
+			return
+		}
+		if err := netlink.LinkSetUp(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo"}}); err != nil {
+			w.replies <- fmt.Errorf("setting up loopback interface: %w", err)
+			return
+		}
+	} else {
+		defer runtime.UnlockOSThread()
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(fmt.Sprintf("/proc/self/task/%d/ns/mnt", unix.Gettid()), &st); err != nil {
+		w.replies <- fmt.Errorf("getting mount namespace: %w", err)
+		return
+	}
+	w.mntns = st.Ino
+	w.replies <- nil
+
+	for task := range w.tasks {
+		w.replies <- task()
+	}
+}
+
+// do runs f in the namespaces of the worker.
+func (w *worker) do(f func() error) error {
+	w.tasks <- f
+	return <-w.replies
+}
+
+func (w *worker) setup() error {
+	f, err := os.CreateTemp("", "ig-generator-")
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	w.file = f.Name()
+	f.Close()
+
+	w.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("creating server: %w", err)
+	}
+	go func(l net.Listener) {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}(w.listener)
+
+	return nil
+}
+
+func (w *worker) run(activity Activity) error {
+	return w.do(func() error {
+		switch activity {
+		case ActivityExec:
+			return exec.Command(w.execPath).Run()
+		case ActivityOpen:
+			f, err := os.Open(w.file)
+			if err != nil {
+				return err
+			}
+			return f.Close()
+		case ActivityConnect:
+			conn, err := net.Dial("tcp", w.listener.Addr().String())
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}
+		return fmt.Errorf("unknown activity %q", activity)
+	})
+}
+
+func (w *worker) close() {
+	if w.listener != nil {
+		w.listener.Close()
+	}
+	if w.file != "" {
+		os.Remove(w.file)
+	}
+	if w.tasks != nil {
+		close(w.tasks)
+		w.tasks = nil
+	}
+}
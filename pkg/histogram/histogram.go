@@ -44,8 +44,8 @@ type Histogram struct {
 }
 
 // NewIntervalsFromExp2Slots creates a new Interval array from an exp-2
-// histogram represented in slots.
-func NewIntervalsFromExp2Slots(slots []uint32) []Interval {
+// histogram represented in slots, of 32 or 64 bits counters.
+func NewIntervalsFromExp2Slots[T uint32 | uint64](slots []T) []Interval {
 	if len(slots) == 0 {
 		return nil
 	}
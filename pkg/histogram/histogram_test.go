@@ -230,3 +230,13 @@ func TestHistogram_String(t *testing.T) {
 		})
 	}
 }
+
+func TestHistogram_NewIntervalsFromExp2Slots64(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []Interval{
+		{Count: 1 << 40, Start: 0, End: 1},
+		{Count: 0, Start: 2, End: 3},
+		{Count: 7, Start: 4, End: 7},
+	}, NewIntervalsFromExp2Slots([]uint64{1 << 40, 0, 7, 0}))
+}
@@ -74,7 +74,9 @@ func (l *IGManager) RemoveMountNsMap(id string) error {
 	return l.tracerCollection.RemoveTracer(id)
 }
 
-func NewManager(runtimes []*containerutilsTypes.RuntimeConfig) (*IGManager, error) {
+// NewManager creates a manager using the given container runtimes. The extra
+// options are passed to the container collection after the default ones.
+func NewManager(runtimes []*containerutilsTypes.RuntimeConfig, extraOpts ...containercollection.ContainerCollectionOption) (*IGManager, error) {
 	l := &IGManager{}
 
 	var err error
@@ -99,8 +101,11 @@ func NewManager(runtimes []*containerutilsTypes.RuntimeConfig) (*IGManager, erro
 		containercollection.WithLinuxNamespaceEnrichment(),
 		containercollection.WithMultipleContainerRuntimesEnrichment(runtimes),
 		containercollection.WithContainerFanotifyEbpf(),
+		containercollection.WithMachines(),
+		containercollection.WithSandboxDetection(),
 		containercollection.WithTracerCollection(l.tracerCollection),
 	}
+	opts = append(opts, extraOpts...)
 
 	if !log.IsLevelEnabled(log.DebugLevel) && isDefaultContainerRuntimeConfig(runtimes) {
 		warnings := []containercollection.ContainerCollectionOption{containercollection.WithDisableContainerRuntimeWarnings()}
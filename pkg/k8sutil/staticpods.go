@@ -0,0 +1,78 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MirrorPodAnnotation is set by the kubelet on the mirror pods, the pods it
+// creates in the API server to show the static pods it runs from files, e.g.
+// the ones of the control plane. It contains the UID of the static pod.
+const MirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// IsMirrorPod tells if the pod is the mirror of a static pod
+func IsMirrorPod(pod *v1.Pod) bool {
+	_, ok := pod.Annotations[MirrorPodAnnotation]
+	return ok
+}
+
+// RuntimePodUID returns the UID the container runtime and the cgroups know
+// the pod by. It's the UID of the static pod for mirror pods, as they have a
+// UID of their own in the API server.
+func RuntimePodUID(pod *v1.Pod) string {
+	if uid := pod.Annotations[MirrorPodAnnotation]; uid != "" {
+		return uid
+	}
+	return string(pod.UID)
+}
+
+// NodeOwnerReference returns the owner reference of the mirror pods to the
+// node running them, or nil if there isn't any.
+func NodeOwnerReference(ownerReferences []metav1.OwnerReference) *metav1.OwnerReference {
+	for i, or := range ownerReferences {
+		if or.Kind == "Node" && or.APIVersion == "v1" {
+			return &ownerReferences[i]
+		}
+	}
+	return nil
+}
+
+// PodOwnerName returns the name of the workload the pod belongs to, without
+// the suffix of the names of its pods, or an empty string if it doesn't
+// belong to any. The kubelet names the static pods after their manifest and
+// the node running them.
+func PodOwnerName(pod *v1.Pod) string {
+	if IsMirrorPod(pod) {
+		if pod.Spec.NodeName != "" && strings.HasSuffix(pod.Name, "-"+pod.Spec.NodeName) {
+			return strings.TrimSuffix(pod.Name, "-"+pod.Spec.NodeName)
+		}
+		return pod.Name
+	}
+
+	if pod.OwnerReferences == nil {
+		return ""
+	}
+	// When the pod belongs to Deployment, ReplicaSet or DaemonSet, find the
+	// shorter name without the random suffix.
+	nameItems := strings.Split(pod.Name, "-")
+	if len(nameItems) > 2 {
+		return strings.Join(nameItems[:len(nameItems)-2], "-")
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStaticPods(t *testing.T) {
+	table := []struct {
+		description string
+		pod         *v1.Pod
+		runtimeUID  string
+		owner       string
+		nodeOwner   bool
+	}{
+		{
+			description: "Mirror pod",
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "kube-apiserver-control-plane-1",
+					UID:         "mirror-uid",
+					Annotations: map[string]string{MirrorPodAnnotation: "static-uid"},
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "v1", Kind: "Node", Name: "control-plane-1"},
+					},
+				},
+				Spec: v1.PodSpec{NodeName: "control-plane-1"},
+			},
+			runtimeUID: "static-uid",
+			owner:      "kube-apiserver",
+			nodeOwner:  true,
+		},
+		{
+			description: "Pod of a ReplicaSet",
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "nginx-7c5ddbdf54-x8vvp",
+					UID:  "pod-uid",
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-7c5ddbdf54"},
+					},
+				},
+				Spec: v1.PodSpec{NodeName: "worker-1"},
+			},
+			runtimeUID: "pod-uid",
+			owner:      "nginx",
+		},
+		{
+			description: "Standalone pod",
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-test-pod",
+					UID:  "pod-uid",
+				},
+			},
+			runtimeUID: "pod-uid",
+		},
+	}
+
+	for _, entry := range table {
+		t.Run(entry.description, func(t *testing.T) {
+			if uid := RuntimePodUID(entry.pod); uid != entry.runtimeUID {
+				t.Errorf("RuntimePodUID() = %q, expected %q", uid, entry.runtimeUID)
+			}
+			if owner := PodOwnerName(entry.pod); owner != entry.owner {
+				t.Errorf("PodOwnerName() = %q, expected %q", owner, entry.owner)
+			}
+			if nodeOwner := NodeOwnerReference(entry.pod.OwnerReferences) != nil; nodeOwner != entry.nodeOwner {
+				t.Errorf("NodeOwnerReference() != nil is %t, expected %t", nodeOwner, entry.nodeOwner)
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"sync"
 
 	"github.com/cilium/ebpf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/shared"
 )
 
 type KAllSyms struct {
@@ -51,6 +53,17 @@ func NewKAllSyms() (*KAllSyms, error) {
 	return NewKAllSymsFromReader(file)
 }
 
+var sharedKAllSyms = shared.New(NewKAllSyms, nil)
+
+// NewSharedKAllSyms returns a KAllSyms shared by all the gadgets using it at the
+// same time, /proc/kallsyms is only read if none of them does. The returned
+// function releases it, the KAllSyms must not be used after that. Once all its
+// users released it, /proc/kallsyms is read again for the next one, to get the
+// symbols of the modules loaded meanwhile.
+func NewSharedKAllSyms() (*KAllSyms, func(), error) {
+	return sharedKAllSyms.Get()
+}
+
 // NewKAllSymsFromReader reads a kallsyms file from the given reader and returns
 // a KAllSyms.
 func NewKAllSymsFromReader(reader io.Reader) (*KAllSyms, error) {
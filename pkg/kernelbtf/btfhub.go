@@ -0,0 +1,210 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernelbtf
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf/btf"
+	log "github.com/sirupsen/logrus"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// BTFHubArchiveEnv is the directory with the BTF files of the kernels
+	// that don't expose it, organized like the BTFHub archive
+	// (<distro>/<version>/<arch>/<kernel>.btf[.tar.xz]). It allows to bundle
+	// them at deploy time. The downloaded files are also stored there.
+	BTFHubArchiveEnv = "IG_BTFHUB_ARCHIVE"
+
+	// BTFHubDownloadEnv enables downloading the BTF file of the kernel from
+	// BTFHub when it's not in the archive directory.
+	BTFHubDownloadEnv = "IG_BTFHUB_DOWNLOAD"
+
+	// BTFHubURLEnv is the URL the BTF files are downloaded from, e.g. a
+	// mirror of the BTFHub archive.
+	BTFHubURLEnv = "IG_BTFHUB_URL"
+
+	DefaultBTFHubArchive = "/var/lib/ig/btfhub-archive"
+	DefaultBTFHubURL     = "https://github.com/aquasecurity/btfhub-archive/raw/main"
+
+	downloadTimeout = 2 * time.Minute
+
+	// The biggest BTF files of the archive are about 10MB
+	maxBTFSize = 256 * 1024 * 1024
+)
+
+type btfHubConfig struct {
+	archive  string
+	url      string
+	download bool
+}
+
+func getBTFHubConfig() btfHubConfig {
+	config := btfHubConfig{
+		archive:  DefaultBTFHubArchive,
+		url:      DefaultBTFHubURL,
+		download: os.Getenv(BTFHubDownloadEnv) == "true",
+	}
+	if archive := os.Getenv(BTFHubArchiveEnv); archive != "" {
+		config.archive = archive
+	}
+	if url := os.Getenv(BTFHubURLEnv); url != "" {
+		config.url = strings.TrimSuffix(url, "/")
+	}
+	return config
+}
+
+func loadFromBTFHub(config btfHubConfig) (*btf.Spec, error) {
+	info, err := GetOSInfo()
+	if err != nil {
+		return nil, fmt.Errorf("getting OS info: %w", err)
+	}
+
+	data, err := readBTFHubFile(config, info.BTFHubPath())
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := btf.LoadSpecFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("loading BTF spec: %w", err)
+	}
+	return spec, nil
+}
+
+// readBTFHubFile returns the content of the BTF file at path of the BTFHub
+// archive. It's looked for in the archive directory, uncompressed first, and
+// downloaded if allowed.
+func readBTFHubFile(config btfHubConfig, path string) ([]byte, error) {
+	local := filepath.Join(config.archive, path)
+
+	data, err := os.ReadFile(local)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading BTF file: %w", err)
+	}
+
+	file, err := os.Open(local + ".tar.xz")
+	if err == nil {
+		defer file.Close()
+		return extractBTF(file)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("opening BTF archive: %w", err)
+	}
+
+	if !config.download {
+		return nil, fmt.Errorf("%s not found in %q and downloading it is disabled, set %s=true to enable it",
+			path, config.archive, BTFHubDownloadEnv)
+	}
+
+	url := config.url + "/" + path + ".tar.xz"
+	log.Infof("Downloading the BTF of the kernel from %s", url)
+	data, err = downloadBTF(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep it for the next runs
+	if err := writeFile(local, data); err != nil {
+		log.Warnf("storing BTF file: %v", err)
+	}
+
+	return data, nil
+}
+
+func downloadBTF(url string) ([]byte, error) {
+	client := http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading BTF: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading BTF from %s: %s", url, resp.Status)
+	}
+
+	return extractBTF(resp.Body)
+}
+
+// extractBTF returns the content of the BTF file in the .tar.xz archive r.
+func extractBTF(r io.Reader) ([]byte, error) {
+	xzReader, err := xz.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing BTF archive: %w", err)
+	}
+
+	tarReader := tar.NewReader(xzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, errors.New("no BTF file in archive")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading BTF archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ".btf") {
+			continue
+		}
+		if header.Size > maxBTFSize {
+			return nil, fmt.Errorf("BTF file %s is too big: %d bytes", header.Name, header.Size)
+		}
+
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("reading BTF file %s: %w", header.Name, err)
+		}
+		return data, nil
+	}
+}
+
+// writeFile writes data to path atomically, so a concurrent reader never gets
+// a truncated file.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
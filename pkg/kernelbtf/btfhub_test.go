@@ -0,0 +1,107 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernelbtf
+
+import (
+	"archive/tar"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+const testBTFPath = "ubuntu/20.04/x86_64/5.4.0-0-generic.btf"
+
+// newBTFArchive returns a .tar.xz archive with a file like the ones of BTFHub.
+func newBTFArchive(t *testing.T, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	xzWriter, err := xz.NewWriter(&buf)
+	require.NoError(t, err)
+
+	tarWriter := tar.NewWriter(xzWriter)
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:     "./5.4.0-0-generic.btf",
+		Typeflag: tar.TypeReg,
+		Mode:     0o644,
+		Size:     int64(len(content)),
+	})
+	require.NoError(t, err)
+	_, err = tarWriter.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, xzWriter.Close())
+
+	return buf.Bytes()
+}
+
+func TestReadBTFHubFile(t *testing.T) {
+	content := []byte("fake BTF")
+
+	t.Run("uncompressed", func(t *testing.T) {
+		archive := t.TempDir()
+		require.NoError(t, writeFile(filepath.Join(archive, testBTFPath), content))
+
+		data, err := readBTFHubFile(btfHubConfig{archive: archive}, testBTFPath)
+		require.NoError(t, err)
+		require.Equal(t, content, data)
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		archive := t.TempDir()
+		require.NoError(t, writeFile(filepath.Join(archive, testBTFPath+".tar.xz"), newBTFArchive(t, content)))
+
+		data, err := readBTFHubFile(btfHubConfig{archive: archive}, testBTFPath)
+		require.NoError(t, err)
+		require.Equal(t, content, data)
+	})
+
+	t.Run("download_disabled", func(t *testing.T) {
+		_, err := readBTFHubFile(btfHubConfig{archive: t.TempDir()}, testBTFPath)
+		require.ErrorContains(t, err, "downloading it is disabled")
+	})
+
+	t.Run("download", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/"+testBTFPath+".tar.xz" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(newBTFArchive(t, content))
+		}))
+		defer server.Close()
+
+		archive := t.TempDir()
+		config := btfHubConfig{archive: archive, url: server.URL, download: true}
+
+		data, err := readBTFHubFile(config, testBTFPath)
+		require.NoError(t, err)
+		require.Equal(t, content, data)
+
+		// It's kept for the next runs
+		stored, err := os.ReadFile(filepath.Join(archive, testBTFPath))
+		require.NoError(t, err)
+		require.Equal(t, content, stored)
+
+		_, err = readBTFHubFile(config, "ubuntu/20.04/x86_64/unknown.btf")
+		require.ErrorContains(t, err, "404")
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kernelbtf provides the BTF information of the running kernel to the
+// gadgets. It's parsed once and shared, as the kernel BTF is several MB big and
+// btf.LoadKernelSpec copies all its types on each call. For the kernels that
+// don't expose their BTF, it can be taken from BTFHub, see btfhub.go.
+package kernelbtf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cilium/ebpf/btf"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	kernelSpec    *btf.Spec
+	kernelSpecErr error
+	kernelOnce    sync.Once
+
+	btfHubSpec    *btf.Spec
+	btfHubSpecErr error
+	btfHubOnce    sync.Once
+)
+
+func loadKernelSpec() (*btf.Spec, error) {
+	kernelOnce.Do(func() {
+		kernelSpec, kernelSpecErr = btf.LoadKernelSpec()
+	})
+	return kernelSpec, kernelSpecErr
+}
+
+func loadBTFHubSpec() (*btf.Spec, error) {
+	btfHubOnce.Do(func() {
+		btfHubSpec, btfHubSpecErr = loadFromBTFHub(getBTFHubConfig())
+		if btfHubSpecErr != nil {
+			log.Debugf("getting BTF from BTFHub: %v", btfHubSpecErr)
+		}
+	})
+	return btfHubSpec, btfHubSpecErr
+}
+
+// Exposed returns whether the running kernel exposes its BTF information.
+func Exposed() bool {
+	_, err := loadKernelSpec()
+	return err == nil
+}
+
+// Spec returns the BTF information of the running kernel, the one exposed by
+// the kernel or the one from BTFHub if it doesn't. The returned spec is shared
+// by all the callers, it must not be modified.
+func Spec() (*btf.Spec, error) {
+	spec, err := loadKernelSpec()
+	if err == nil || !errors.Is(err, btf.ErrNotSupported) {
+		return spec, err
+	}
+
+	spec, btfHubErr := loadBTFHubSpec()
+	if btfHubErr != nil {
+		return nil, fmt.Errorf("%w (BTFHub: %s)", err, btfHubErr)
+	}
+	return spec, nil
+}
+
+// KernelTypes returns the BTF information to use as
+// ebpf.ProgramOptions.KernelTypes: the one from BTFHub for the kernels that
+// don't expose their BTF, nil otherwise as cilium/ebpf uses the one of the
+// kernel by default.
+func KernelTypes() *btf.Spec {
+	if Exposed() {
+		return nil
+	}
+	spec, _ := loadBTFHubSpec()
+	return spec
+}
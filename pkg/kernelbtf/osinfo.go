@@ -0,0 +1,31 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernelbtf
+
+import "fmt"
+
+// OSInfo identifies the kernel running on the host the way BTFHub does.
+type OSInfo struct {
+	ID        string
+	VersionID string
+	Arch      string
+	Kernel    string
+}
+
+// BTFHubPath returns the path of the BTF file of the kernel in the BTFHub
+// archive, without the .tar.xz extension.
+func (i *OSInfo) BTFHubPath() string {
+	return fmt.Sprintf("%s/%s/%s/%s.btf", i.ID, i.VersionID, i.Arch, i.Kernel)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package kernelbtf
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// GetOSInfo returns the distribution and kernel running on the host.
+func GetOSInfo() (*OSInfo, error) {
+	osInfo := &OSInfo{}
+
+	file, err := os.Open(filepath.Join(host.HostRoot, "/etc/os-release"))
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "ID":
+			osInfo.ID = parts[1]
+		case "VERSION_ID":
+			osInfo.VersionID = strings.Trim(parts[1], "\"")
+		}
+	}
+
+	if osInfo.ID == "" || osInfo.VersionID == "" {
+		return nil, fmt.Errorf("os-release file is incomplete")
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning file: %w", err)
+	}
+
+	uts := &unix.Utsname{}
+	if err := unix.Uname(uts); err != nil {
+		return nil, fmt.Errorf("calling uname: %w", err)
+	}
+
+	osInfo.Kernel = unix.ByteSliceToString(uts.Release[:])
+	osInfo.Arch = unix.ByteSliceToString(uts.Machine[:])
+	// BTFHub uses arm64 instead of aarch64
+	if osInfo.Arch == "aarch64" {
+		osInfo.Arch = "arm64"
+	}
+
+	return osInfo, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/distribution/reference"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// ExportGadgetImages exports the given images from the local store to a
+// tarball with the OCI image layout. The images keep their names, so they can
+// be imported in another host with ImportGadgetImages.
+func ExportGadgetImages(ctx context.Context, images []string, dstFile string) ([]*GadgetImageDesc, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
+	ociStore, err := getLocalOciStore()
+	if err != nil {
+		return nil, fmt.Errorf("getting oci store: %w", err)
+	}
+
+	return exportImages(ctx, ociStore, images, dstFile)
+}
+
+// ImportGadgetImages imports in the local store all the images of a tarball
+// with the OCI image layout, like the ones created by ExportGadgetImages.
+func ImportGadgetImages(ctx context.Context, srcFile string) ([]*GadgetImageDesc, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
+	ociStore, err := getLocalOciStore()
+	if err != nil {
+		return nil, fmt.Errorf("getting oci store: %w", err)
+	}
+
+	return importImages(ctx, srcFile, ociStore)
+}
+
+func exportImages(ctx context.Context, src oras.ReadOnlyTarget, images []string, dstFile string) ([]*GadgetImageDesc, error) {
+	tmpDir, err := os.MkdirTemp("", "gadget-export-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	layout, err := oci.New(tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("creating oci layout: %w", err)
+	}
+
+	descs := []*GadgetImageDesc{}
+	for _, image := range images {
+		targetImage, err := normalizeImageName(image)
+		if err != nil {
+			return nil, fmt.Errorf("normalizing image %q: %w", image, err)
+		}
+		desc, err := oras.Copy(ctx, src, targetImage.String(), layout, targetImage.String(), oras.DefaultCopyOptions)
+		if err != nil {
+			return nil, fmt.Errorf("copying image %q: %w", image, err)
+		}
+		imageDesc, err := newGadgetImageDesc(image, desc.Digest.String())
+		if err != nil {
+			return nil, err
+		}
+		descs = append(descs, imageDesc)
+	}
+
+	if err := writeTar(tmpDir, dstFile); err != nil {
+		return nil, fmt.Errorf("writing %q: %w", dstFile, err)
+	}
+
+	return descs, nil
+}
+
+func importImages(ctx context.Context, srcFile string, dst oras.Target) ([]*GadgetImageDesc, error) {
+	layout, err := oci.NewFromTar(ctx, srcFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening %q: %w", srcFile, err)
+	}
+
+	tags := []string{}
+	err = layout.Tags(ctx, "", func(t []string) error {
+		tags = append(tags, t...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing images: %w", err)
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no named images found in %q", srcFile)
+	}
+
+	descs := []*GadgetImageDesc{}
+	for _, tag := range tags {
+		desc, err := oras.Copy(ctx, layout, tag, dst, tag, oras.DefaultCopyOptions)
+		if err != nil {
+			return nil, fmt.Errorf("copying image %q: %w", tag, err)
+		}
+		imageDesc, err := newGadgetImageDesc(tag, desc.Digest.String())
+		if err != nil {
+			return nil, err
+		}
+		descs = append(descs, imageDesc)
+	}
+
+	return descs, nil
+}
+
+func newGadgetImageDesc(image, digest string) (*GadgetImageDesc, error) {
+	targetImage, err := normalizeImageName(image)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing image %q: %w", image, err)
+	}
+	imageDesc := &GadgetImageDesc{
+		Repository: targetImage.Name(),
+		Digest:     digest,
+	}
+	if ref, ok := targetImage.(reference.Tagged); ok {
+		imageDesc.Tag = ref.Tag()
+	}
+	return imageDesc, nil
+}
+
+// writeTar writes the content of the directory to a tarball.
+func writeTar(dir, dstFile string) error {
+	f, err := os.Create(dstFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func TestExportImportImages(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	src := memory.New()
+	images := map[string]string{
+		"ghcr.io/inspektor-gadget/gadget/trace_open:latest": "",
+		"mygadget:v1": "",
+	}
+	for image := range images {
+		objectPath := filepath.Join(dir, "object")
+		require.NoError(t, os.WriteFile(objectPath, []byte("object for "+image), 0o644))
+		metadataPath := filepath.Join(dir, "gadget.yaml")
+		require.NoError(t, os.WriteFile(metadataPath, []byte("name: "+image), 0o644))
+		opts := &BuildGadgetImageOpts{
+			EBPFObjectPaths: map[string]string{ArchAmd64: objectPath, ArchArm64: objectPath},
+			MetadataPath:    metadataPath,
+		}
+		desc, err := createImageIndex(ctx, src, opts)
+		require.NoError(t, err)
+
+		ref, err := normalizeImageName(image)
+		require.NoError(t, err)
+		require.NoError(t, src.Tag(ctx, desc, ref.String()))
+		images[image] = desc.Digest.String()
+	}
+
+	archive := filepath.Join(dir, "gadgets.tar")
+	exported, err := exportImages(ctx, src, []string{"ghcr.io/inspektor-gadget/gadget/trace_open", "mygadget:v1"}, archive)
+	require.NoError(t, err)
+	require.Len(t, exported, 2)
+	require.Equal(t, "ghcr.io/inspektor-gadget/gadget/trace_open", exported[0].Repository)
+	require.Equal(t, "latest", exported[0].Tag)
+	require.Equal(t, images["ghcr.io/inspektor-gadget/gadget/trace_open:latest"], exported[0].Digest)
+
+	dst := memory.New()
+	imported, err := importImages(ctx, archive, dst)
+	require.NoError(t, err)
+	require.ElementsMatch(t, exported, imported)
+
+	for image, digest := range images {
+		manifest, err := getImageManifestForArch(ctx, dst, image, nil)
+		require.NoError(t, err)
+		prog, err := getEbpfProgramFromManifest(ctx, dst, manifest)
+		require.NoError(t, err)
+		require.Equal(t, []byte("object for "+image), prog)
+
+		ref, err := normalizeImageName(image)
+		require.NoError(t, err)
+		desc, err := dst.Resolve(ctx, ref.String())
+		require.NoError(t, err)
+		require.Equal(t, digest, desc.Digest.String())
+	}
+
+	_, err = exportImages(ctx, src, []string{"unknown:latest"}, filepath.Join(dir, "unknown.tar"))
+	require.Error(t, err)
+}
@@ -0,0 +1,417 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+const (
+	// attestationArtifactType is the artifact type of the manifests holding
+	// an attestation. They are attached to the image index using the subject
+	// field, so they are found with the referrers API.
+	attestationArtifactType = "application/vnd.in-toto+json"
+	// predicateTypeAnnotation contains the predicate type of the attestation.
+	predicateTypeAnnotation = "in-toto.io/predicate-type"
+	// dsseEnvelopeMediaType is the media type of the layer holding the DSSE
+	// envelope with the signed in-toto statement.
+	dsseEnvelopeMediaType = "application/vnd.dsse.envelope.v1+json"
+
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	inTotoPayloadType   = "application/vnd.in-toto+json"
+	dssePAEPrefix       = "DSSEv1"
+
+	PredicateTypeSLSAProvenance = "https://slsa.dev/provenance/v1"
+	PredicateTypeSPDX           = "https://spdx.dev/Document"
+	PredicateTypeCycloneDX      = "https://cyclonedx.org/bom"
+
+	gadgetBuildType = "https://inspektor-gadget.io/gadget-build/v1"
+)
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// dsseEnvelope is a Dead Simple Signing Envelope, see
+// https://github.com/secure-systems-lab/dsse/blob/master/envelope.md
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// dssePAE returns the pre-authentication encoding of the payload, the data
+// actually signed in a DSSE envelope.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("%s %d %s %d %s", dssePAEPrefix, len(payloadType), payloadType, len(payload), payload))
+}
+
+// LoadSigningKey reads the PEM encoded private key the attestations are signed
+// with. ECDSA, RSA and Ed25519 keys, not encrypted, are supported.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid signing key: no PEM data found")
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %q: encrypted keys, like the cosign ones, must be exported without a password first", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey:
+		return key.(crypto.Signer), nil
+	}
+	return nil, fmt.Errorf("unsupported signing key type %T", key)
+}
+
+// signPayload signs payload with key the way verifySignature() verifies it.
+func signPayload(key crypto.Signer, payload []byte) ([]byte, error) {
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		return key.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+	hash := sha256.Sum256(payload)
+	return key.Sign(rand.Reader, hash[:], crypto.SHA256)
+}
+
+// ProvenanceOpts describes how a gadget image was built. It's recorded in the
+// SLSA provenance attestation attached to the image.
+type ProvenanceOpts struct {
+	// BuilderID identifies the tool that built the image, e.g. "ig image build".
+	BuilderID string
+	// BuilderImage is the container image used to compile the eBPF program,
+	// if any.
+	BuilderImage string
+	// Parameters are the parameters of the build, e.g. the cflags.
+	Parameters map[string]string
+}
+
+type slsaResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition struct {
+		BuildType            string                   `json:"buildType"`
+		ExternalParameters   map[string]string        `json:"externalParameters"`
+		ResolvedDependencies []slsaResourceDescriptor `json:"resolvedDependencies,omitempty"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn string `json:"startedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+func fileDigest(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return map[string]string{"sha256": hex.EncodeToString(sum[:])}, nil
+}
+
+// newProvenance creates the SLSA provenance predicate of a build. The source
+// and metadata files are recorded with their digests.
+func newProvenance(opts *BuildGadgetImageOpts, startedOn time.Time) (json.RawMessage, error) {
+	var provenance slsaProvenance
+
+	provenance.BuildDefinition.BuildType = gadgetBuildType
+	provenance.BuildDefinition.ExternalParameters = map[string]string{}
+	for k, v := range opts.Provenance.Parameters {
+		provenance.BuildDefinition.ExternalParameters[k] = v
+	}
+	provenance.BuildDefinition.ExternalParameters["source"] = opts.EBPFSourcePath
+
+	for _, path := range append([]string{opts.EBPFSourcePath, opts.MetadataPath}, opts.SharedLayerPaths...) {
+		if path == "" {
+			continue
+		}
+		digest, err := fileDigest(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("hashing %q: %w", path, err)
+		}
+		provenance.BuildDefinition.ResolvedDependencies = append(provenance.BuildDefinition.ResolvedDependencies,
+			slsaResourceDescriptor{URI: path, Digest: digest})
+	}
+	if opts.Provenance.BuilderImage != "" {
+		provenance.BuildDefinition.ResolvedDependencies = append(provenance.BuildDefinition.ResolvedDependencies,
+			slsaResourceDescriptor{URI: "docker://" + opts.Provenance.BuilderImage})
+	}
+
+	provenance.RunDetails.Builder.ID = opts.Provenance.BuilderID
+	provenance.RunDetails.Metadata.StartedOn = startedOn.UTC().Format(time.RFC3339)
+
+	return json.Marshal(provenance)
+}
+
+// attachAttestation stores an in-toto statement with the predicate, in a DSSE
+// envelope signed with key, as an artifact referring to the subject.
+func attachAttestation(ctx context.Context, target oras.Target, subject ocispec.Descriptor, name, predicateType string, predicate json.RawMessage, key crypto.Signer) error {
+	statement := inTotoStatement{
+		Type: inTotoStatementType,
+		Subject: []inTotoSubject{{
+			Name:   name,
+			Digest: map[string]string{subject.Digest.Algorithm().String(): subject.Digest.Encoded()},
+		}},
+		PredicateType: predicateType,
+		Predicate:     predicate,
+	}
+	statementBytes, err := json.Marshal(statement)
+	if err != nil {
+		return fmt.Errorf("marshalling statement: %w", err)
+	}
+	signature, err := signPayload(key, dssePAE(inTotoPayloadType, statementBytes))
+	if err != nil {
+		return fmt.Errorf("signing statement: %w", err)
+	}
+	envelope := dsseEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statementBytes),
+		Signatures:  []dsseSignature{{Sig: base64.StdEncoding.EncodeToString(signature)}},
+	}
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("marshalling envelope: %w", err)
+	}
+	envelopeDesc := content.NewDescriptorFromBytes(dsseEnvelopeMediaType, envelopeBytes)
+	envelopeDesc.Annotations = map[string]string{
+		predicateTypeAnnotation: predicateType,
+	}
+	if err := pushDescriptorIfNotExists(ctx, target, envelopeDesc, bytes.NewReader(envelopeBytes)); err != nil {
+		return fmt.Errorf("pushing envelope: %w", err)
+	}
+
+	if err := pushDescriptorIfNotExists(ctx, target, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)); err != nil {
+		return fmt.Errorf("pushing empty config: %w", err)
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2, // historical value. does not pertain to OCI or docker version
+		},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: attestationArtifactType,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{envelopeDesc},
+		Subject:      &subject,
+		Annotations: map[string]string{
+			predicateTypeAnnotation: predicateType,
+		},
+	}
+	manifestJson, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("marshalling manifest: %w", err)
+	}
+	manifestDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJson)
+	manifestDesc.ArtifactType = attestationArtifactType
+	manifestDesc.Annotations = manifest.Annotations
+	if err := pushDescriptorIfNotExists(ctx, target, manifestDesc, bytes.NewReader(manifestJson)); err != nil {
+		return fmt.Errorf("pushing manifest: %w", err)
+	}
+
+	return nil
+}
+
+// attachAttestations attaches the attestations requested in opts to the image
+// index.
+func attachAttestations(ctx context.Context, target oras.Target, indexDesc ocispec.Descriptor, image string, opts *BuildGadgetImageOpts) error {
+	if opts.Provenance == nil && opts.SBOMPath == "" {
+		return nil
+	}
+	if opts.SigningKey == nil {
+		return errors.New("a signing key is required to attach attestations")
+	}
+
+	if opts.Provenance != nil {
+		provenance, err := newProvenance(opts, time.Now())
+		if err != nil {
+			return fmt.Errorf("creating provenance: %w", err)
+		}
+		if err := attachAttestation(ctx, target, indexDesc, image, PredicateTypeSLSAProvenance, provenance, opts.SigningKey); err != nil {
+			return fmt.Errorf("attaching provenance: %w", err)
+		}
+	}
+
+	if opts.SBOMPath != "" {
+		sbom, err := os.ReadFile(opts.SBOMPath)
+		if err != nil {
+			return fmt.Errorf("reading SBOM: %w", err)
+		}
+		predicateType, err := sbomPredicateType(sbom)
+		if err != nil {
+			return err
+		}
+		if err := attachAttestation(ctx, target, indexDesc, image, predicateType, sbom, opts.SigningKey); err != nil {
+			return fmt.Errorf("attaching SBOM: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sbomPredicateType detects the format of an SBOM in JSON format.
+func sbomPredicateType(sbom []byte) (string, error) {
+	var doc struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(sbom, &doc); err != nil {
+		return "", fmt.Errorf("parsing SBOM: only SPDX and CycloneDX JSON documents are supported: %w", err)
+	}
+	switch {
+	case doc.SPDXVersion != "":
+		return PredicateTypeSPDX, nil
+	case doc.BOMFormat == "CycloneDX":
+		return PredicateTypeCycloneDX, nil
+	}
+	return "", fmt.Errorf("unknown SBOM format: only SPDX and CycloneDX JSON documents are supported")
+}
+
+// attestationPredicateTypes returns the predicate types of the attestations
+// attached to the subject. Only the attestations whose statement is about the
+// subject are considered and, if verify isn't nil, whose envelope has a
+// signature it accepts.
+func attestationPredicateTypes(ctx context.Context, target oras.ReadOnlyGraphTarget, subject ocispec.Descriptor, verify func(payload, signature []byte) bool) (map[string]struct{}, error) {
+	referrers, err := target.Predecessors(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	types := map[string]struct{}{}
+	for _, referrer := range referrers {
+		if referrer.MediaType != ocispec.MediaTypeImageManifest {
+			continue
+		}
+		manifestBytes, err := content.FetchAll(ctx, target, referrer)
+		if err != nil {
+			return nil, fmt.Errorf("fetching referrer: %w", err)
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+			continue
+		}
+		if manifest.ArtifactType != attestationArtifactType || manifest.Subject == nil ||
+			manifest.Subject.Digest != subject.Digest || len(manifest.Layers) != 1 ||
+			manifest.Layers[0].MediaType != dsseEnvelopeMediaType {
+			continue
+		}
+
+		envelopeBytes, err := content.FetchAll(ctx, target, manifest.Layers[0])
+		if err != nil {
+			return nil, fmt.Errorf("fetching envelope: %w", err)
+		}
+		statementBytes, ok := verifyEnvelope(envelopeBytes, verify)
+		if !ok {
+			continue
+		}
+		var statement inTotoStatement
+		if err := json.Unmarshal(statementBytes, &statement); err != nil {
+			continue
+		}
+		for _, s := range statement.Subject {
+			if s.Digest[subject.Digest.Algorithm().String()] == subject.Digest.Encoded() {
+				types[statement.PredicateType] = struct{}{}
+				break
+			}
+		}
+	}
+
+	return types, nil
+}
+
+// verifyEnvelope returns the in-toto statement in the DSSE envelope if, when
+// verify isn't nil, one of its signatures is accepted by verify.
+func verifyEnvelope(envelopeBytes []byte, verify func(payload, signature []byte) bool) ([]byte, bool) {
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(envelopeBytes, &envelope); err != nil || envelope.PayloadType != inTotoPayloadType {
+		return nil, false
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, false
+	}
+	if verify == nil {
+		return payload, true
+	}
+
+	pae := dssePAE(envelope.PayloadType, payload)
+	for _, s := range envelope.Signatures {
+		signature, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if verify(pae, signature) {
+			return payload, true
+		}
+	}
+	return nil, false
+}
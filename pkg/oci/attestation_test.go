@@ -0,0 +1,161 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func TestAttachAttestations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	sourcePath := filepath.Join(dir, "program.bpf.c")
+	require.NoError(t, os.WriteFile(sourcePath, []byte("int x;"), 0o644))
+	objectPath := filepath.Join(dir, "program.o")
+	require.NoError(t, os.WriteFile(objectPath, []byte("object"), 0o644))
+	metadataPath := filepath.Join(dir, "gadget.yaml")
+	require.NoError(t, os.WriteFile(metadataPath, []byte("name: test"), 0o644))
+	sbomPath := filepath.Join(dir, "sbom.json")
+	require.NoError(t, os.WriteFile(sbomPath, []byte(`{"spdxVersion":"SPDX-2.3"}`), 0o644))
+
+	opts := &BuildGadgetImageOpts{
+		EBPFSourcePath:  sourcePath,
+		EBPFObjectPaths: map[string]string{ArchAmd64: objectPath},
+		MetadataPath:    metadataPath,
+		Provenance: &ProvenanceOpts{
+			BuilderID:    "ig image build",
+			BuilderImage: "ghcr.io/inspektor-gadget/ebpf-builder:latest",
+			Parameters:   map[string]string{"cflags": "-DFOO"},
+		},
+		SBOMPath: sbomPath,
+	}
+	key, _ := generateKey(t)
+	otherKey, _ := generateKey(t)
+
+	store := memory.New()
+	indexDesc, err := createImageIndex(ctx, store, opts)
+	require.NoError(t, err)
+
+	types, err := attestationPredicateTypes(ctx, store, indexDesc, nil)
+	require.NoError(t, err)
+	require.Empty(t, types)
+
+	require.Error(t, attachAttestations(ctx, store, indexDesc, "mygadget:latest", opts))
+	opts.SigningKey = key
+	require.NoError(t, attachAttestations(ctx, store, indexDesc, "mygadget:latest", opts))
+
+	expected := map[string]struct{}{
+		PredicateTypeSLSAProvenance: {},
+		PredicateTypeSPDX:           {},
+	}
+	types, err = attestationPredicateTypes(ctx, store, indexDesc, nil)
+	require.NoError(t, err)
+	require.Equal(t, expected, types)
+
+	policy := &ImagePolicy{publicKeys: []crypto.PublicKey{&key.PublicKey}}
+	types, err = attestationPredicateTypes(ctx, store, indexDesc, policy.verifySignature)
+	require.NoError(t, err)
+	require.Equal(t, expected, types)
+
+	policy = &ImagePolicy{publicKeys: []crypto.PublicKey{&otherKey.PublicKey}}
+	types, err = attestationPredicateTypes(ctx, store, indexDesc, policy.verifySignature)
+	require.NoError(t, err)
+	require.Empty(t, types)
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	key, _ := generateKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	signer, err := LoadSigningKey(keyPath)
+	require.NoError(t, err)
+	require.Equal(t, &key.PublicKey, signer.Public())
+
+	encryptedPath := filepath.Join(dir, "cosign.key")
+	require.NoError(t, os.WriteFile(encryptedPath, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: []byte("foo")}), 0o600))
+	_, err = LoadSigningKey(encryptedPath)
+	require.Error(t, err)
+}
+
+func TestNewProvenance(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "program.bpf.c")
+	require.NoError(t, os.WriteFile(sourcePath, []byte("int x;"), 0o644))
+
+	opts := &BuildGadgetImageOpts{
+		EBPFSourcePath: sourcePath,
+		MetadataPath:   filepath.Join(dir, "nonexistent.yaml"),
+		Provenance: &ProvenanceOpts{
+			BuilderID:  "ig image build",
+			Parameters: map[string]string{"cflags": "-DFOO"},
+		},
+	}
+
+	startedOn := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	predicate, err := newProvenance(opts, startedOn)
+	require.NoError(t, err)
+
+	var provenance slsaProvenance
+	require.NoError(t, json.Unmarshal(predicate, &provenance))
+	require.Equal(t, gadgetBuildType, provenance.BuildDefinition.BuildType)
+	require.Equal(t, map[string]string{"cflags": "-DFOO", "source": sourcePath}, provenance.BuildDefinition.ExternalParameters)
+	require.Equal(t, []slsaResourceDescriptor{{
+		URI: sourcePath,
+		// sha256sum of "int x;"
+		Digest: map[string]string{"sha256": "e13e332bd08e13cbe2aee094e130ed23878b3b554be5b9a665a83e63caa987ae"},
+	}}, provenance.BuildDefinition.ResolvedDependencies)
+	require.Equal(t, "ig image build", provenance.RunDetails.Builder.ID)
+	require.Equal(t, "2023-10-01T12:00:00Z", provenance.RunDetails.Metadata.StartedOn)
+}
+
+func TestSBOMPredicateType(t *testing.T) {
+	t.Parallel()
+
+	predicateType, err := sbomPredicateType([]byte(`{"spdxVersion":"SPDX-2.3"}`))
+	require.NoError(t, err)
+	require.Equal(t, PredicateTypeSPDX, predicateType)
+
+	predicateType, err = sbomPredicateType([]byte(`{"bomFormat":"CycloneDX","specVersion":"1.5"}`))
+	require.NoError(t, err)
+	require.Equal(t, PredicateTypeCycloneDX, predicateType)
+
+	_, err = sbomPredicateType([]byte(`{}`))
+	require.Error(t, err)
+
+	_, err = sbomPredicateType([]byte(`not json`))
+	require.Error(t, err)
+}
@@ -17,11 +17,14 @@ package oci
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/docker/distribution/reference"
 	"github.com/opencontainers/image-spec/specs-go"
@@ -39,6 +42,12 @@ const (
 const (
 	eBPFObjectMediaType = "application/vnd.gadget.ebpf.program.v1+binary"
 	metadataMediaType   = "application/vnd.gadget.config.v1+yaml"
+
+	// Media types of the shared layers
+	wasmMediaType    = "application/vnd.gadget.wasm.program.v1+binary"
+	btfMediaType     = "application/vnd.gadget.btf.v1+binary"
+	headerMediaType  = "application/vnd.gadget.header.v1+text"
+	genericMediaType = "application/vnd.gadget.layer.v1+binary"
 )
 
 type BuildGadgetImageOpts struct {
@@ -49,16 +58,41 @@ type BuildGadgetImageOpts struct {
 	EBPFObjectPaths map[string]string
 	// Path to the metadata file.
 	MetadataPath string
+	// Paths of files, like WASM decoders or BTF snippets, shared with other
+	// gadgets. Each one is stored in its own layer, so it's stored and
+	// downloaded only once for all the gadgets using it.
+	SharedLayerPaths []string
 	// If true, the metadata is updated to follow changes in the eBPF objects.
 	UpdateMetadata bool
 	// If true, the metadata is validated before creating the image.
 	ValidateMetadata bool
+	// If true, the build fails when the metadata isn't up to date with the
+	// eBPF objects: fields were added to or removed from the code but not
+	// the metadata.
+	CheckMetadata bool
+	// If true, the keys of the metadata file that aren't known, like
+	// misspelled ones, are errors instead of being ignored.
+	StrictMetadata bool
+	// If set, a SLSA provenance attestation describing the build is attached
+	// to the image.
+	Provenance *ProvenanceOpts
+	// Path to a SBOM, in SPDX or CycloneDX JSON format, to attach to the
+	// image.
+	SBOMPath string
+	// Key the attestations are signed with. It's required to attach any.
+	SigningKey crypto.Signer
 }
 
 // BuildGadgetImage creates an OCI image with the objects provided in opts. The image parameter in
 // the "name:tag" format is used to name and tag the created image. If it's empty the image is not
 // named.
 func BuildGadgetImage(ctx context.Context, opts *BuildGadgetImageOpts, image string) (*GadgetImageDesc, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
 	ociStore, err := getLocalOciStore()
 	if err != nil {
 		return nil, fmt.Errorf("getting oci store: %w", err)
@@ -76,6 +110,12 @@ func BuildGadgetImage(ctx context.Context, opts *BuildGadgetImageOpts, image str
 		}
 	}
 
+	if opts.CheckMetadata {
+		if err := checkMetadataFile(ctx, opts); err != nil {
+			return nil, fmt.Errorf("checking metadata file: %w", err)
+		}
+	}
+
 	indexDesc, err := createImageIndex(ctx, ociStore, opts)
 	if err != nil {
 		return nil, fmt.Errorf("creating image index: %w", err)
@@ -85,6 +125,10 @@ func BuildGadgetImage(ctx context.Context, opts *BuildGadgetImageOpts, image str
 		Digest: indexDesc.Digest.String(),
 	}
 
+	if err := attachAttestations(ctx, ociStore, indexDesc, image, opts); err != nil {
+		return nil, fmt.Errorf("attaching attestations: %w", err)
+	}
+
 	if image != "" {
 		targetImage, err := normalizeImageName(image)
 		if err != nil {
@@ -148,7 +192,50 @@ func createMetadataDesc(ctx context.Context, target oras.Target, metadataFilePat
 	return defDesc, nil
 }
 
-func createManifestForTarget(ctx context.Context, target oras.Target, metadataFilePath, progFilePath, arch string) (ocispec.Descriptor, error) {
+// sharedLayerMediaType returns the media type of a shared layer according to
+// the extension of the file.
+func sharedLayerMediaType(path string) string {
+	switch filepath.Ext(path) {
+	case ".wasm":
+		return wasmMediaType
+	case ".btf":
+		return btfMediaType
+	case ".h":
+		return headerMediaType
+	}
+	return genericMediaType
+}
+
+// createSharedLayerDescs pushes the shared layers. Their descriptors only
+// depend on the content and the name of the files, so they are the same in
+// all the gadgets using them.
+func createSharedLayerDescs(ctx context.Context, target oras.Target, paths []string) ([]ocispec.Descriptor, error) {
+	descs := make([]ocispec.Descriptor, 0, len(paths))
+	titles := map[string]struct{}{}
+	for _, path := range paths {
+		title := filepath.Base(path)
+		if _, ok := titles[title]; ok {
+			return nil, fmt.Errorf("several shared layers are named %q", title)
+		}
+		titles[title] = struct{}{}
+
+		layerBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading shared layer: %w", err)
+		}
+		desc := content.NewDescriptorFromBytes(sharedLayerMediaType(path), layerBytes)
+		desc.Annotations = map[string]string{
+			ocispec.AnnotationTitle: title,
+		}
+		if err := pushDescriptorIfNotExists(ctx, target, desc, bytes.NewReader(layerBytes)); err != nil {
+			return nil, fmt.Errorf("pushing shared layer %q: %w", path, err)
+		}
+		descs = append(descs, desc)
+	}
+	return descs, nil
+}
+
+func createManifestForTarget(ctx context.Context, target oras.Target, metadataFilePath, progFilePath, arch string, sharedLayers []ocispec.Descriptor) (ocispec.Descriptor, error) {
 	progDesc, err := createEbpfProgramDesc(ctx, target, progFilePath)
 	if err != nil {
 		return ocispec.Descriptor{}, fmt.Errorf("creating and pushing eBPF descriptor: %w", err)
@@ -170,7 +257,7 @@ func createManifestForTarget(ctx context.Context, target oras.Target, metadataFi
 			SchemaVersion: 2, // historical value. does not pertain to OCI or docker version
 		},
 		Config: defDesc,
-		Layers: []ocispec.Descriptor{progDesc},
+		Layers: append([]ocispec.Descriptor{progDesc}, sharedLayers...),
 	}
 	manifestJson, err := json.Marshal(manifest)
 	if err != nil {
@@ -201,8 +288,21 @@ func createImageIndex(ctx context.Context, target oras.Target, o *BuildGadgetIma
 	// Read the eBPF program files and push them to the memory store
 	layers := []ocispec.Descriptor{}
 
-	for arch, path := range o.EBPFObjectPaths {
-		manifestDesc, err := createManifestForTarget(ctx, target, o.MetadataPath, path, arch)
+	// Sort the architectures to always get the same index for the same objects
+	archs := make([]string, 0, len(o.EBPFObjectPaths))
+	for arch := range o.EBPFObjectPaths {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+
+	sharedLayers, err := createSharedLayerDescs(ctx, target, o.SharedLayerPaths)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("creating shared layers: %w", err)
+	}
+
+	for _, arch := range archs {
+		path := o.EBPFObjectPaths[arch]
+		manifestDesc, err := createManifestForTarget(ctx, target, o.MetadataPath, path, arch, sharedLayers)
 		if err != nil {
 			return ocispec.Descriptor{}, fmt.Errorf("creating %s manifest: %w", arch, err)
 		}
@@ -21,6 +21,7 @@ import (
 	"os"
 
 	"github.com/cilium/ebpf"
+	"github.com/pmezard/go-difflib/difflib"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
@@ -62,14 +63,13 @@ func getAnySpec(opts *BuildGadgetImageOpts) (*ebpf.CollectionSpec, error) {
 }
 
 func validateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts) error {
-	metadataFile, err := os.Open(opts.MetadataPath)
+	content, err := os.ReadFile(opts.MetadataPath)
 	if err != nil {
-		return fmt.Errorf("opening metadata file: %w", err)
+		return fmt.Errorf("reading metadata file: %w", err)
 	}
-	defer metadataFile.Close()
 
-	metadata := &types.GadgetMetadata{}
-	if err := yaml.NewDecoder(metadataFile).Decode(metadata); err != nil {
+	metadata, err := types.DecodeMetadata(content, opts.StrictMetadata)
+	if err != nil {
 		return fmt.Errorf("decoding metadata file: %w", err)
 	}
 
@@ -78,7 +78,74 @@ func validateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts) error
 		return fmt.Errorf("loading spec: %w", err)
 	}
 
-	return metadata.Validate(spec)
+	if err := metadata.Validate(spec); err != nil {
+		return err
+	}
+
+	for _, w := range metadata.MapSizeWarnings(spec) {
+		log.Warnf("map %q: %s", w.MapName, w.Message)
+	}
+
+	return nil
+}
+
+// metadataDiff returns the changes the eBPF code of spec implies to the
+// metadata file content: the fields --update-metadata would add and the ones
+// removed from the code. It's a unified diff, empty when the file is up to
+// date.
+func metadataDiff(content []byte, spec *ebpf.CollectionSpec, strict bool) (string, error) {
+	current, err := types.DecodeMetadata(content, strict)
+	if err != nil {
+		return "", fmt.Errorf("decoding metadata file: %w", err)
+	}
+	expected, err := types.DecodeMetadata(content, strict)
+	if err != nil {
+		return "", fmt.Errorf("decoding metadata file: %w", err)
+	}
+
+	expected.RemoveStaleFields(spec)
+	if err := expected.Populate(spec); err != nil {
+		return "", fmt.Errorf("populating metadata: %w", err)
+	}
+
+	// Both are marshalled for the formatting of the file not to matter
+	currentYAML, err := yaml.Marshal(current)
+	if err != nil {
+		return "", err
+	}
+	expectedYAML, err := yaml.Marshal(expected)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(currentYAML)),
+		B:        difflib.SplitLines(string(expectedYAML)),
+		FromFile: "current",
+		ToFile:   "expected",
+		Context:  3,
+	})
+}
+
+func checkMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts) error {
+	content, err := os.ReadFile(opts.MetadataPath)
+	if err != nil {
+		return fmt.Errorf("reading metadata file: %w", err)
+	}
+
+	spec, err := getAnySpec(opts)
+	if err != nil {
+		return fmt.Errorf("loading spec: %w", err)
+	}
+
+	diff, err := metadataDiff(content, spec, opts.StrictMetadata)
+	if err != nil {
+		return err
+	}
+	if diff != "" {
+		return fmt.Errorf("%s isn't up to date with the eBPF code:\n%s", opts.MetadataPath, diff)
+	}
+	return nil
 }
 
 func createOrUpdateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts) error {
@@ -94,18 +161,23 @@ func createOrUpdateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts)
 
 	if update {
 		// load metadata file
-		metadataFile, err := os.Open(opts.MetadataPath)
+		content, err := os.ReadFile(opts.MetadataPath)
 		if err != nil {
-			return fmt.Errorf("opening metadata file: %w", err)
+			return fmt.Errorf("reading metadata file: %w", err)
 		}
-		defer metadataFile.Close()
 
-		if err := yaml.NewDecoder(metadataFile).Decode(metadata); err != nil {
+		metadata, err = types.DecodeMetadata(content, opts.StrictMetadata)
+		if err != nil {
 			return fmt.Errorf("decoding metadata file: %w", err)
 		}
 
 		log.Debugf("Metadata file found, updating it")
 
+		// The fields removed from the eBPF code would make the validation fail
+		for _, field := range metadata.RemoveStaleFields(spec) {
+			log.Infof("Removing field %q, not in the eBPF code anymore", field)
+		}
+
 		// TODO: this validation could be softer, just printing warnings
 		if err := metadata.Validate(spec); err != nil {
 			return fmt.Errorf("metadata file is wrong, fix it before continuing: %w", err)
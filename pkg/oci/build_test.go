@@ -0,0 +1,202 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	"oras.land/oras-go/v2/content/memory"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+)
+
+func TestCreateImageIndex(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	opts := &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{},
+		MetadataPath:    filepath.Join(dir, "gadget.yaml"),
+	}
+	for _, arch := range []string{ArchAmd64, ArchArm64} {
+		path := filepath.Join(dir, arch+".bpf.o")
+		require.NoError(t, os.WriteFile(path, []byte("object for "+arch), 0o644))
+		opts.EBPFObjectPaths[arch] = path
+	}
+	require.NoError(t, os.WriteFile(opts.MetadataPath, []byte("name: test\n"), 0o644))
+
+	store := memory.New()
+	indexDesc, err := createImageIndex(ctx, store, opts)
+	require.NoError(t, err)
+
+	// The index must not depend on the order of the map
+	for i := 0; i < 10; i++ {
+		desc, err := createImageIndex(ctx, store, opts)
+		require.NoError(t, err)
+		require.Equal(t, indexDesc.Digest, desc.Digest)
+	}
+
+	indexBytes, err := getContentFromDescriptor(ctx, store, indexDesc)
+	require.NoError(t, err)
+	var index ocispec.Index
+	require.NoError(t, json.Unmarshal(indexBytes, &index))
+	require.Len(t, index.Manifests, 2)
+
+	for _, arch := range []string{ArchAmd64, ArchArm64} {
+		manifest, err := getArchManifest(ctx, store, index, arch)
+		require.NoError(t, err)
+
+		prog, err := getEbpfProgramFromManifest(ctx, store, manifest)
+		require.NoError(t, err)
+		require.Equal(t, []byte("object for "+arch), prog)
+
+		metadata, err := getMetadataFromManifest(ctx, store, manifest)
+		require.NoError(t, err)
+		require.Equal(t, []byte("name: test\n"), metadata)
+	}
+
+	_, err = getArchManifest(ctx, store, index, "riscv64")
+	require.Error(t, err)
+}
+
+func TestSharedLayers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	wasmPath := filepath.Join(dir, "decoder.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, []byte("wasm decoder"), 0o644))
+	btfPath := filepath.Join(dir, "types.btf")
+	require.NoError(t, os.WriteFile(btfPath, []byte("btf"), 0o644))
+	metadataPath := filepath.Join(dir, "gadget.yaml")
+	require.NoError(t, os.WriteFile(metadataPath, []byte("name: test\n"), 0o644))
+
+	store := memory.New()
+	layersByGadget := [][]ocispec.Descriptor{}
+	for _, gadget := range []string{"first", "second"} {
+		objectPath := filepath.Join(dir, gadget+".bpf.o")
+		require.NoError(t, os.WriteFile(objectPath, []byte("object for "+gadget), 0o644))
+
+		indexDesc, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+			EBPFObjectPaths:  map[string]string{ArchAmd64: objectPath},
+			MetadataPath:     metadataPath,
+			SharedLayerPaths: []string{wasmPath, btfPath},
+		})
+		require.NoError(t, err)
+
+		indexBytes, err := getContentFromDescriptor(ctx, store, indexDesc)
+		require.NoError(t, err)
+		var index ocispec.Index
+		require.NoError(t, json.Unmarshal(indexBytes, &index))
+		manifest, err := getArchManifest(ctx, store, index, ArchAmd64)
+		require.NoError(t, err)
+		require.Len(t, manifest.Layers, 3)
+
+		prog, err := getEbpfProgramFromManifest(ctx, store, manifest)
+		require.NoError(t, err)
+		require.Equal(t, []byte("object for "+gadget), prog)
+
+		for i, expected := range []struct {
+			title     string
+			mediaType string
+			content   string
+		}{
+			{"decoder.wasm", wasmMediaType, "wasm decoder"},
+			{"types.btf", btfMediaType, "btf"},
+		} {
+			layer := manifest.Layers[i+1]
+			require.Equal(t, expected.title, layer.Annotations[ocispec.AnnotationTitle])
+			require.Equal(t, expected.mediaType, layer.MediaType)
+			layerBytes, err := getContentFromDescriptor(ctx, store, layer)
+			require.NoError(t, err)
+			require.Equal(t, []byte(expected.content), layerBytes)
+		}
+
+		layersByGadget = append(layersByGadget, manifest.Layers[1:])
+	}
+
+	// Both gadgets reference the same blobs
+	require.Equal(t, layersByGadget[0], layersByGadget[1])
+
+	_, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+		EBPFObjectPaths:  map[string]string{ArchAmd64: wasmPath},
+		MetadataPath:     metadataPath,
+		SharedLayerPaths: []string{wasmPath, wasmPath},
+	})
+	require.ErrorContains(t, err, "several shared layers")
+}
+
+func TestMetadataDiff(t *testing.T) {
+	t.Parallel()
+
+	spec, err := ebpf.LoadCollectionSpec("../../testdata/populate_metadata_tracer_add_missing_field.o")
+	require.NoError(t, err)
+
+	outdated := []byte(`name: foo
+description: bar
+tracers:
+  events:
+    mapName: events
+    structName: event
+structs:
+  event:
+    fields:
+    - name: pid
+      description: foo-pid
+    - name: comm
+      description: bar-comm
+    - name: gone
+      description: not in the struct anymore
+`)
+
+	diff, err := metadataDiff(outdated, spec, true)
+	require.NoError(t, err)
+	require.Contains(t, diff, "--- current\n+++ expected\n")
+	require.Contains(t, diff, "\n-    - name: gone\n")
+	require.Contains(t, diff, "\n+    - name: filename\n")
+
+	// A file updated with the expected content doesn't have differences
+	metadata, err := types.DecodeMetadata(outdated, true)
+	require.NoError(t, err)
+	metadata.RemoveStaleFields(spec)
+	require.NoError(t, metadata.Populate(spec))
+	upToDate, err := yaml.Marshal(metadata)
+	require.NoError(t, err)
+
+	diff, err = metadataDiff(upToDate, spec, true)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	// Updating the file removes the stale fields too
+	opts := &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{ArchAmd64: "../../testdata/populate_metadata_tracer_add_missing_field.o"},
+		MetadataPath:    filepath.Join(t.TempDir(), "gadget.yaml"),
+		StrictMetadata:  true,
+	}
+	require.NoError(t, os.WriteFile(opts.MetadataPath, outdated, 0o644))
+	require.NoError(t, createOrUpdateMetadataFile(context.Background(), opts))
+	require.NoError(t, checkMetadataFile(context.Background(), opts))
+}
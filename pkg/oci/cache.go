@@ -0,0 +1,512 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxManifestSize is the size above which the blobs aren't considered to
+	// be manifests. It's the same limit used by oras.
+	maxManifestSize = 4 * 1024 * 1024
+	// danglingBlobsMinAge is the age the blobs not referenced by any image
+	// must have to be removed, as they could belong to an image being pulled
+	// by another process.
+	danglingBlobsMinAge = time.Hour
+)
+
+// defaultUsageFile records the last time each image of the local store was
+// used, in order to evict the least recently used ones.
+var defaultUsageFile = "/var/lib/ig/oci-store-usage.json"
+
+var (
+	// localStoreMu is held for writing while the local store is garbage
+	// collected and for reading by the other operations on the store.
+	localStoreMu sync.RWMutex
+	usageMu      sync.Mutex
+)
+
+// lockLocalStore locks the local store, exclusively while it's garbage
+// collected and shared by the other operations on it. Besides localStoreMu for
+// the goroutines of this process, it takes a flock on the root of the store for
+// the other processes using it, e.g. ig and the daemon on the same host.
+func lockLocalStore(exclusive bool) (func(), error) {
+	unlockMu := localStoreMu.RUnlock
+	if exclusive {
+		localStoreMu.Lock()
+		unlockMu = localStoreMu.Unlock
+	} else {
+		localStoreMu.RLock()
+	}
+
+	if err := os.MkdirAll(defaultOciStore, 0o700); err != nil {
+		unlockMu()
+		return nil, err
+	}
+	unlockFile, err := lockFile(defaultOciStore, exclusive)
+	if err != nil {
+		unlockMu()
+		return nil, fmt.Errorf("locking %q: %w", defaultOciStore, err)
+	}
+
+	return func() {
+		unlockFile()
+		unlockMu()
+	}, nil
+}
+
+// CachedImage describes an image of the local store.
+type CachedImage struct {
+	// References are the tags of the image, e.g.
+	// "ghcr.io/inspektor-gadget/gadget/trace_open:latest". It's empty for
+	// images only pulled by digest.
+	References []string
+	Digest     string
+	// Size is the size in bytes of the blobs of the image, including its
+	// attestations. The blobs shared with other images are counted for each
+	// of them.
+	Size     int64
+	LastUsed time.Time
+}
+
+// CacheUsage describes the content of the local store.
+type CacheUsage struct {
+	// Size is the size in bytes of all the blobs of the store.
+	Size int64
+	// Images are sorted from the most to the least recently used.
+	Images []*CachedImage
+}
+
+// GetCacheUsage returns the images of the local store and the space they use.
+func GetCacheUsage(ctx context.Context) (*CacheUsage, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
+	if _, err := getLocalOciStore(); err != nil {
+		return nil, fmt.Errorf("getting oci store: %w", err)
+	}
+	return getCacheUsage(defaultOciStore, defaultUsageFile)
+}
+
+// GarbageCollect removes the least recently used images of the local store
+// until the size of its blobs is at most maxSize bytes. The most recently used
+// image is always kept. The blobs not referenced by any image are removed
+// too. It returns the removed images.
+func GarbageCollect(ctx context.Context, maxSize int64) ([]*CachedImage, error) {
+	unlock, err := lockLocalStore(true)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
+	if _, err := getLocalOciStore(); err != nil {
+		return nil, fmt.Errorf("getting oci store: %w", err)
+	}
+	return garbageCollect(defaultOciStore, defaultUsageFile, maxSize, time.Now())
+}
+
+// RunGarbageCollector calls GarbageCollect every interval until the context
+// is done. It does nothing if interval isn't positive.
+func RunGarbageCollector(ctx context.Context, maxSize int64, interval time.Duration) {
+	if interval <= 0 {
+		log.Warnf("Not garbage collecting gadget images: invalid interval %s", interval)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		removed, err := GarbageCollect(ctx, maxSize)
+		if err != nil {
+			log.Warnf("Garbage collecting gadget images: %v", err)
+		}
+		for _, image := range removed {
+			log.Infof("Removed gadget image %s %v, last used at %s", image.Digest, image.References,
+				image.LastUsed.Format(time.RFC3339))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// markImageUsed records the image with the given digest was used now. Errors
+// are only logged, they shouldn't prevent running the gadget.
+func markImageUsed(digest string) {
+	if err := setLastUsed(defaultUsageFile, digest, time.Now()); err != nil {
+		log.Debugf("Recording use of image %s: %v", digest, err)
+	}
+}
+
+func readUsage(usagePath string) (map[string]time.Time, error) {
+	usage := map[string]time.Time{}
+	data, err := os.ReadFile(usagePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return usage, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("unmarshaling %q: %w", usagePath, err)
+	}
+	return usage, nil
+}
+
+func writeUsage(usagePath string, usage map[string]time.Time) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(usagePath, data, 0o600)
+}
+
+// writeFileAtomic writes data to a temporary file renamed to path, so the
+// readers never see it partially written.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func setLastUsed(usagePath, digest string, lastUsed time.Time) error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	usage, err := readUsage(usagePath)
+	if err != nil {
+		return err
+	}
+	usage[digest] = lastUsed
+	return writeUsage(usagePath, usage)
+}
+
+// storeContent describes the blobs of an OCI layout and how they reference
+// each other.
+type storeContent struct {
+	root  string
+	index ocispec.Index
+	blobs map[digest.Digest]os.FileInfo
+	// successors are the blobs referenced by a manifest or an index
+	successors map[digest.Digest][]digest.Digest
+	// referrers are the manifests having a blob as subject, e.g. the
+	// attestations of an image
+	referrers map[digest.Digest][]digest.Digest
+}
+
+func blobPath(root string, d digest.Digest) string {
+	return filepath.Join(root, ocispec.ImageBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+// loadStoreContent reads the index and all the blobs of the OCI layout in
+// root. The blobs small enough to be manifests are parsed to find the blobs
+// they reference.
+func loadStoreContent(root string) (*storeContent, error) {
+	c := &storeContent{
+		root:       root,
+		blobs:      map[digest.Digest]os.FileInfo{},
+		successors: map[digest.Digest][]digest.Digest{},
+		referrers:  map[digest.Digest][]digest.Digest{},
+	}
+
+	indexBytes, err := os.ReadFile(filepath.Join(root, ocispec.ImageIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	if err := json.Unmarshal(indexBytes, &c.index); err != nil {
+		return nil, fmt.Errorf("unmarshaling index: %w", err)
+	}
+
+	blobsDir := filepath.Join(root, ocispec.ImageBlobsDir)
+	err = filepath.WalkDir(blobsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(blobsDir, path)
+		if err != nil {
+			return err
+		}
+		d := digest.Digest(filepath.Dir(rel) + ":" + filepath.Base(rel))
+		if d.Validate() != nil {
+			// Not a blob, e.g. a temporary file
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		c.blobs[d] = info
+		if info.Size() <= maxManifestSize {
+			c.parseManifest(d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing blobs: %w", err)
+	}
+
+	return c, nil
+}
+
+// parseManifest records the blobs referenced by d if it's a manifest or an
+// index.
+func (c *storeContent) parseManifest(d digest.Digest) {
+	data, err := os.ReadFile(blobPath(c.root, d))
+	if err != nil || len(data) == 0 || data[0] != '{' {
+		return
+	}
+	var manifest struct {
+		SchemaVersion int                  `json:"schemaVersion"`
+		MediaType     string               `json:"mediaType"`
+		Config        *ocispec.Descriptor  `json:"config"`
+		Layers        []ocispec.Descriptor `json:"layers"`
+		Manifests     []ocispec.Descriptor `json:"manifests"`
+		Subject       *ocispec.Descriptor  `json:"subject"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return
+	}
+	// The media type is optional in the manifests
+	switch manifest.MediaType {
+	case ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex:
+	case "":
+		if manifest.SchemaVersion != 2 || (manifest.Config == nil && manifest.Manifests == nil) {
+			return
+		}
+	default:
+		return
+	}
+
+	descs := append(manifest.Layers, manifest.Manifests...)
+	if manifest.Config != nil {
+		descs = append(descs, *manifest.Config)
+	}
+	for _, desc := range descs {
+		// The config is empty for gadgets without metadata
+		if desc.Digest != "" {
+			c.successors[d] = append(c.successors[d], desc.Digest)
+		}
+	}
+	if manifest.Subject != nil {
+		c.referrers[manifest.Subject.Digest] = append(c.referrers[manifest.Subject.Digest], d)
+	}
+}
+
+// reachable adds to blobs all the blobs reachable from d, including its
+// referrers.
+func (c *storeContent) reachable(d digest.Digest, blobs map[digest.Digest]struct{}) {
+	if _, ok := blobs[d]; ok {
+		return
+	}
+	blobs[d] = struct{}{}
+	for _, successor := range c.successors[d] {
+		c.reachable(successor, blobs)
+	}
+	for _, referrer := range c.referrers[d] {
+		c.reachable(referrer, blobs)
+	}
+}
+
+func (c *storeContent) size(blobs map[digest.Digest]struct{}) int64 {
+	var size int64
+	for d := range blobs {
+		if info, ok := c.blobs[d]; ok {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// images returns the images of the index, sorted from the most to the least
+// recently used. The images never marked as used are considered to be used
+// when their root manifest was stored.
+func (c *storeContent) images(usage map[string]time.Time) []*CachedImage {
+	// The store also lists the manifests of the images, e.g. the ones of each
+	// architecture, skip them.
+	children := map[digest.Digest]struct{}{}
+	for _, desc := range c.index.Manifests {
+		blobs := map[digest.Digest]struct{}{}
+		c.reachable(desc.Digest, blobs)
+		delete(blobs, desc.Digest)
+		for d := range blobs {
+			children[d] = struct{}{}
+		}
+	}
+
+	images := []*CachedImage{}
+	byDigest := map[digest.Digest]*CachedImage{}
+	for _, desc := range c.index.Manifests {
+		if _, ok := children[desc.Digest]; ok {
+			continue
+		}
+		image, ok := byDigest[desc.Digest]
+		if !ok {
+			image = &CachedImage{
+				References: []string{},
+				Digest:     desc.Digest.String(),
+			}
+			if lastUsed, ok := usage[desc.Digest.String()]; ok {
+				image.LastUsed = lastUsed
+			} else if info, ok := c.blobs[desc.Digest]; ok {
+				image.LastUsed = info.ModTime()
+			}
+			blobs := map[digest.Digest]struct{}{}
+			c.reachable(desc.Digest, blobs)
+			image.Size = c.size(blobs)
+			byDigest[desc.Digest] = image
+			images = append(images, image)
+		}
+		if ref := desc.Annotations[ocispec.AnnotationRefName]; ref != "" {
+			image.References = append(image.References, ref)
+		}
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].LastUsed.After(images[j].LastUsed)
+	})
+	return images
+}
+
+func getCacheUsage(root, usagePath string) (*CacheUsage, error) {
+	content, err := loadStoreContent(root)
+	if err != nil {
+		return nil, err
+	}
+	usageMu.Lock()
+	usage, err := readUsage(usagePath)
+	usageMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("reading usage: %w", err)
+	}
+
+	all := map[digest.Digest]struct{}{}
+	for d := range content.blobs {
+		all[d] = struct{}{}
+	}
+	return &CacheUsage{
+		Size:   content.size(all),
+		Images: content.images(usage),
+	}, nil
+}
+
+func garbageCollect(root, usagePath string, maxSize int64, now time.Time) ([]*CachedImage, error) {
+	content, err := loadStoreContent(root)
+	if err != nil {
+		return nil, err
+	}
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usage, err := readUsage(usagePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading usage: %w", err)
+	}
+
+	images := content.images(usage)
+	live := func(images []*CachedImage) map[digest.Digest]struct{} {
+		blobs := map[digest.Digest]struct{}{}
+		for _, image := range images {
+			content.reachable(digest.Digest(image.Digest), blobs)
+		}
+		return blobs
+	}
+
+	kept := images
+	liveBlobs := live(kept)
+	for len(kept) > 1 && content.size(liveBlobs) > maxSize {
+		kept = kept[:len(kept)-1]
+		liveBlobs = live(kept)
+	}
+	removed := images[len(kept):]
+
+	if len(removed) > 0 {
+		manifests := []ocispec.Descriptor{}
+		for _, desc := range content.index.Manifests {
+			if _, ok := liveBlobs[desc.Digest]; ok {
+				manifests = append(manifests, desc)
+			}
+		}
+		content.index.Manifests = manifests
+		indexBytes, err := json.Marshal(content.index)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling index: %w", err)
+		}
+		if err := writeFileAtomic(filepath.Join(root, ocispec.ImageIndexFile), indexBytes, 0o644); err != nil {
+			return nil, fmt.Errorf("writing index: %w", err)
+		}
+	}
+
+	// Blobs of the removed images are removed right away, the other ones
+	// only when they are old enough
+	removedBlobs := live(removed)
+	for d, info := range content.blobs {
+		if _, ok := liveBlobs[d]; ok {
+			continue
+		}
+		if _, ok := removedBlobs[d]; !ok && now.Sub(info.ModTime()) < danglingBlobsMinAge {
+			continue
+		}
+		if err := os.Remove(blobPath(root, d)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("removing blob %s: %w", d, err)
+		}
+	}
+
+	if len(removed) > 0 {
+		for _, image := range removed {
+			delete(usage, image.Digest)
+		}
+		if err := writeUsage(usagePath, usage); err != nil {
+			return nil, fmt.Errorf("writing usage: %w", err)
+		}
+	}
+
+	return removed, nil
+}
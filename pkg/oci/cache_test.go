@@ -0,0 +1,135 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestGarbageCollect(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "oci-store")
+	usagePath := filepath.Join(dir, "usage.json")
+	store, err := oci.New(root)
+	require.NoError(t, err)
+
+	metadataPath := filepath.Join(dir, "gadget.yaml")
+	require.NoError(t, os.WriteFile(metadataPath, []byte("name: test"), 0o644))
+	key, _ := generateKey(t)
+	descs := map[string]ocispec.Descriptor{}
+	for _, name := range []string{"old", "recent", "new"} {
+		objectPath := filepath.Join(dir, name+".o")
+		require.NoError(t, os.WriteFile(objectPath, []byte(strings.Repeat(name, 1000)), 0o644))
+		desc, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+			EBPFObjectPaths: map[string]string{ArchAmd64: objectPath},
+			MetadataPath:    metadataPath,
+			Provenance:      &ProvenanceOpts{BuilderID: "ig image build"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, attachAttestations(ctx, store, desc, name, &BuildGadgetImageOpts{
+			Provenance: &ProvenanceOpts{BuilderID: "ig image build"},
+			SigningKey: key,
+		}))
+		require.NoError(t, store.Tag(ctx, desc, "docker.io/library/"+name+":latest"))
+		descs[name] = desc
+	}
+
+	now := time.Now()
+	require.NoError(t, setLastUsed(usagePath, descs["old"].Digest.String(), now.Add(-2*time.Hour)))
+	require.NoError(t, setLastUsed(usagePath, descs["recent"].Digest.String(), now.Add(-time.Hour)))
+	require.NoError(t, setLastUsed(usagePath, descs["new"].Digest.String(), now))
+
+	// A blob left by an interrupted pull
+	dangling := []byte("dangling")
+	danglingDigest := digest.FromBytes(dangling)
+	require.NoError(t, os.WriteFile(blobPath(root, danglingDigest), dangling, 0o644))
+
+	usage, err := getCacheUsage(root, usagePath)
+	require.NoError(t, err)
+	require.Len(t, usage.Images, 3)
+	require.Equal(t, []string{"docker.io/library/new:latest"}, usage.Images[0].References)
+	require.Equal(t, descs["new"].Digest.String(), usage.Images[0].Digest)
+	require.Equal(t, descs["old"].Digest.String(), usage.Images[2].Digest)
+	// The eBPF object, the metadata and the attestation are counted
+	require.Greater(t, usage.Images[0].Size, int64(3000))
+	// The metadata blob is shared by all the images
+	require.Less(t, usage.Size, usage.Images[0].Size+usage.Images[1].Size+usage.Images[2].Size+int64(len(dangling)))
+
+	// Nothing to remove, the dangling blob is too recent
+	removed, err := garbageCollect(root, usagePath, usage.Size, now)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+	require.FileExists(t, blobPath(root, danglingDigest))
+
+	// Only the least recently used image is removed, the size of its eBPF
+	// object is enough to get below the limit
+	removed, err = garbageCollect(root, usagePath, usage.Size-3000, now)
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	require.Equal(t, descs["old"].Digest.String(), removed[0].Digest)
+	require.NoFileExists(t, blobPath(root, descs["old"].Digest))
+
+	store, err = oci.New(root)
+	require.NoError(t, err)
+	_, err = store.Resolve(ctx, "docker.io/library/old:latest")
+	require.Error(t, err)
+	_, err = store.Resolve(ctx, "docker.io/library/recent:latest")
+	require.NoError(t, err)
+	usageMap, err := readUsage(usagePath)
+	require.NoError(t, err)
+	require.NotContains(t, usageMap, descs["old"].Digest.String())
+
+	// The most recently used image is always kept, the blobs of the removed
+	// ones and the old dangling blobs are removed
+	removed, err = garbageCollect(root, usagePath, 0, now.Add(2*danglingBlobsMinAge))
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	require.Equal(t, descs["recent"].Digest.String(), removed[0].Digest)
+	require.NoFileExists(t, blobPath(root, danglingDigest))
+
+	usage, err = getCacheUsage(root, usagePath)
+	require.NoError(t, err)
+	require.Len(t, usage.Images, 1)
+	require.Equal(t, descs["new"].Digest.String(), usage.Images[0].Digest)
+	require.Equal(t, usage.Images[0].Size, usage.Size)
+
+	store, err = oci.New(root)
+	require.NoError(t, err)
+	manifest, err := getImageManifestForArch(ctx, store, "docker.io/library/new:latest", nil)
+	require.NoError(t, err)
+	_, err = getEbpfProgramFromManifest(ctx, store, manifest)
+	require.NoError(t, err)
+
+	// The index and the usage are written through temporary files
+	tmpFiles, err := filepath.Glob(filepath.Join(root, ocispec.ImageIndexFile+".*"))
+	require.NoError(t, err)
+	require.Empty(t, tmpFiles)
+	tmpFiles, err = filepath.Glob(usagePath + ".*")
+	require.NoError(t, err)
+	require.Empty(t, tmpFiles)
+}
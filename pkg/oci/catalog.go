@@ -0,0 +1,228 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+)
+
+const (
+	// catalogArtifactType is the artifact type of the manifests holding a
+	// catalog. The catalog itself is the only layer of the manifest.
+	catalogArtifactType = "application/vnd.gadget.catalog.v1+json"
+	catalogMediaType    = "application/vnd.gadget.catalog.v1+json"
+)
+
+// DefaultCatalog is the catalog of the official gadgets.
+var DefaultCatalog = "ghcr.io/inspektor-gadget/gadget/catalog:latest"
+
+// CatalogEntry describes a gadget of a catalog. The fields, apart from the
+// image, are taken from the metadata of the gadget.
+type CatalogEntry struct {
+	Name         string   `json:"name" column:"name,width:20"`
+	Image        string   `json:"image" column:"image,width:55"`
+	Digest       string   `json:"digest,omitempty" column:"digest,width:12,fixed,hide"`
+	Category     string   `json:"category,omitempty" column:"category,width:10"`
+	Description  string   `json:"description,omitempty" column:"description,width:50"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Catalog is an index of the gadgets available in a repository. It's stored
+// as an OCI artifact, so it can be hosted in the same registry as the
+// gadgets and fetched without pulling them.
+type Catalog struct {
+	Gadgets []*CatalogEntry `json:"gadgets"`
+}
+
+// GetCatalog fetches the catalog stored in the given reference.
+func GetCatalog(ctx context.Context, catalogRef string, authOpts *AuthOptions) (*Catalog, error) {
+	targetRef, err := normalizeImageName(catalogRef)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing catalog: %w", err)
+	}
+	repo, err := NewRepository(targetRef.String(), authOpts)
+	if err != nil {
+		return nil, fmt.Errorf("creating remote repository: %w", err)
+	}
+	desc, err := repo.Resolve(ctx, targetRef.String())
+	if err != nil {
+		return nil, fmt.Errorf("resolving catalog %q: %w", catalogRef, err)
+	}
+	return fetchCatalog(ctx, repo, desc)
+}
+
+func fetchCatalog(ctx context.Context, target oras.ReadOnlyTarget, desc ocispec.Descriptor) (*Catalog, error) {
+	manifest, err := fetchManifest(ctx, target, desc)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.ArtifactType != catalogArtifactType || len(manifest.Layers) != 1 {
+		return nil, fmt.Errorf("not a gadget catalog: expected an artifact of type %q", catalogArtifactType)
+	}
+	catalogBytes, err := content.FetchAll(ctx, target, manifest.Layers[0])
+	if err != nil {
+		return nil, fmt.Errorf("fetching catalog: %w", err)
+	}
+	catalog := &Catalog{}
+	if err := json.Unmarshal(catalogBytes, catalog); err != nil {
+		return nil, fmt.Errorf("unmarshaling catalog: %w", err)
+	}
+	return catalog, nil
+}
+
+// PushCatalog stores the catalog in the given reference and returns its
+// descriptor.
+func PushCatalog(ctx context.Context, catalogRef string, catalog *Catalog, authOpts *AuthOptions) (*GadgetImageDesc, error) {
+	targetRef, err := normalizeImageName(catalogRef)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing catalog: %w", err)
+	}
+	repo, err := NewRepository(targetRef.String(), authOpts)
+	if err != nil {
+		return nil, fmt.Errorf("creating remote repository: %w", err)
+	}
+	desc, err := storeCatalog(ctx, repo, catalog)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.Tag(ctx, desc, targetRef.String()); err != nil {
+		return nil, fmt.Errorf("tagging catalog: %w", err)
+	}
+	return newGadgetImageDesc(targetRef.String(), desc.Digest.String())
+}
+
+func storeCatalog(ctx context.Context, target oras.Target, catalog *Catalog) (ocispec.Descriptor, error) {
+	catalogBytes, err := json.Marshal(catalog)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("marshalling catalog: %w", err)
+	}
+	catalogDesc := content.NewDescriptorFromBytes(catalogMediaType, catalogBytes)
+	if err := pushDescriptorIfNotExists(ctx, target, catalogDesc, bytes.NewReader(catalogBytes)); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("pushing catalog: %w", err)
+	}
+
+	desc, err := oras.PackManifest(ctx, target, oras.PackManifestVersion1_1_RC4, catalogArtifactType,
+		oras.PackManifestOptions{Layers: []ocispec.Descriptor{catalogDesc}})
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("pushing manifest: %w", err)
+	}
+	return desc, nil
+}
+
+// NewCatalogEntry creates the catalog entry of an image. Only the metadata of
+// the image is fetched from the registry, not the eBPF programs.
+func NewCatalogEntry(ctx context.Context, image string, authOpts *AuthOptions) (*CatalogEntry, error) {
+	targetImage, err := normalizeImageName(image)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing image: %w", err)
+	}
+	repo, err := NewRepository(targetImage.String(), authOpts)
+	if err != nil {
+		return nil, fmt.Errorf("creating remote repository: %w", err)
+	}
+	desc, err := repo.Resolve(ctx, localReference(targetImage))
+	if err != nil {
+		return nil, fmt.Errorf("resolving image %q: %w", image, err)
+	}
+	return newCatalogEntry(ctx, repo, image, desc)
+}
+
+func newCatalogEntry(ctx context.Context, target oras.Target, image string, desc ocispec.Descriptor) (*CatalogEntry, error) {
+	manifest, _, err := getMetadataManifest(ctx, target, image, desc)
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+
+	metadataBytes, err := getMetadataFromManifest(ctx, target, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("getting metadata: %w", err)
+	}
+	metadata, err := types.DecodeMetadata(metadataBytes, false)
+	if err != nil {
+		return nil, fmt.Errorf("decoding metadata: %w", err)
+	}
+
+	if metadata.Name == "" {
+		// The metadata is optional, use the name of the repository instead
+		targetImage, err := normalizeImageName(image)
+		if err != nil {
+			return nil, fmt.Errorf("normalizing image: %w", err)
+		}
+		metadata.Name = path.Base(targetImage.Name())
+	}
+
+	return &CatalogEntry{
+		Name:         metadata.Name,
+		Image:        image,
+		Digest:       desc.Digest.String(),
+		Category:     metadata.Category,
+		Description:  metadata.Description,
+		Capabilities: metadata.Capabilities,
+	}, nil
+}
+
+// NewCatalog creates a catalog with the given images, sorted by name.
+func NewCatalog(ctx context.Context, images []string, authOpts *AuthOptions) (*Catalog, error) {
+	catalog := &Catalog{}
+	for _, image := range images {
+		entry, err := NewCatalogEntry(ctx, image, authOpts)
+		if err != nil {
+			return nil, fmt.Errorf("creating entry of %q: %w", image, err)
+		}
+		catalog.Gadgets = append(catalog.Gadgets, entry)
+	}
+	sort.Slice(catalog.Gadgets, func(i, j int) bool {
+		return catalog.Gadgets[i].Name < catalog.Gadgets[j].Name
+	})
+	return catalog, nil
+}
+
+// Search returns the gadgets whose name, category or description contain the
+// term, ignoring the case. All the gadgets are returned for an empty term.
+func (c *Catalog) Search(term string) []*CatalogEntry {
+	term = strings.ToLower(term)
+	entries := []*CatalogEntry{}
+	for _, entry := range c.Gadgets {
+		if strings.Contains(strings.ToLower(entry.Name), term) ||
+			strings.Contains(strings.ToLower(entry.Category), term) ||
+			strings.Contains(strings.ToLower(entry.Description), term) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Lookup returns the gadget with the given name, or nil if the catalog
+// doesn't contain it.
+func (c *Catalog) Lookup(name string) *CatalogEntry {
+	for _, entry := range c.Gadgets {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
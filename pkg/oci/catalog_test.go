@@ -0,0 +1,101 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := memory.New()
+
+	objectPath := filepath.Join(dir, "object")
+	require.NoError(t, os.WriteFile(objectPath, []byte("object"), 0o644))
+	metadataPath := filepath.Join(dir, "gadget.yaml")
+	require.NoError(t, os.WriteFile(metadataPath, []byte(`
+name: trace_open
+description: Trace the files opened by the processes
+category: trace
+capabilities: [CAP_BPF, CAP_PERFMON]
+`), 0o644))
+	desc, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{ArchArm64: objectPath},
+		MetadataPath:    metadataPath,
+	})
+	require.NoError(t, err)
+
+	entry, err := newCatalogEntry(ctx, store, "ghcr.io/inspektor-gadget/gadget/trace_open:latest", desc)
+	require.NoError(t, err)
+	require.Equal(t, &CatalogEntry{
+		Name:         "trace_open",
+		Image:        "ghcr.io/inspektor-gadget/gadget/trace_open:latest",
+		Digest:       desc.Digest.String(),
+		Category:     "trace",
+		Description:  "Trace the files opened by the processes",
+		Capabilities: []string{"CAP_BPF", "CAP_PERFMON"},
+	}, entry)
+
+	// Without name in the metadata, the name of the repository is used
+	noNamePath := filepath.Join(dir, "noname.yaml")
+	require.NoError(t, os.WriteFile(noNamePath, []byte("description: My gadget"), 0o644))
+	noNameDesc, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{ArchAmd64: objectPath},
+		MetadataPath:    noNamePath,
+	})
+	require.NoError(t, err)
+	noName, err := newCatalogEntry(ctx, store, "myregistry.io/gadgets/mygadget:v1", noNameDesc)
+	require.NoError(t, err)
+	require.Equal(t, "mygadget", noName.Name)
+	require.Equal(t, "My gadget", noName.Description)
+
+	// The metadata of a newer schema can't be understood
+	newerPath := filepath.Join(dir, "newer.yaml")
+	require.NoError(t, os.WriteFile(newerPath, []byte("schemaVersion: 1000\nname: newer"), 0o644))
+	newerDesc, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{ArchAmd64: objectPath},
+		MetadataPath:    newerPath,
+	})
+	require.NoError(t, err)
+	_, err = newCatalogEntry(ctx, store, "myregistry.io/gadgets/newer:v1", newerDesc)
+	require.Error(t, err)
+
+	catalog := &Catalog{Gadgets: []*CatalogEntry{entry, noName}}
+	catalogDesc, err := storeCatalog(ctx, store, catalog)
+	require.NoError(t, err)
+	fetched, err := fetchCatalog(ctx, store, catalogDesc)
+	require.NoError(t, err)
+	require.Equal(t, catalog, fetched)
+
+	_, err = fetchCatalog(ctx, store, desc)
+	require.Error(t, err)
+
+	require.Equal(t, []*CatalogEntry{entry}, catalog.Search("TRACE"))
+	require.Equal(t, []*CatalogEntry{entry}, catalog.Search("opened"))
+	require.Equal(t, []*CatalogEntry{noName}, catalog.Search("my gadget"))
+	require.Len(t, catalog.Search(""), 2)
+	require.Empty(t, catalog.Search("network"))
+	require.Equal(t, entry, catalog.Lookup("trace_open"))
+	require.Nil(t, catalog.Lookup("trace_exec"))
+}
@@ -0,0 +1,229 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+
+	"github.com/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/errdef"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+)
+
+// Status of the signature of an inspected image
+const (
+	SignatureVerified    = "verified"
+	SignatureNotVerified = "signed, not verified"
+	SignatureInvalid     = "invalid"
+	SignatureNotSigned   = "not signed"
+	SignatureUnknown     = "unknown"
+)
+
+// GadgetImageInfo describes a gadget image without running it.
+type GadgetImageInfo struct {
+	Image         string   `json:"image"`
+	Digest        string   `json:"digest"`
+	Architectures []string `json:"architectures"`
+	Signature     string   `json:"signature"`
+	Attestations  []string `json:"attestations"`
+	SharedLayers  []string `json:"sharedLayers"`
+	Local         bool     `json:"local"`
+
+	Metadata *types.GadgetMetadata `json:"-"`
+}
+
+// InspectGadgetImage returns the information of an image. The image is read
+// from the local store if it's present, otherwise from the registry, without
+// pulling it. The signatures and the attestations are always looked for in
+// the registry. The signature is verified if public keys, in PEM format, are
+// given.
+func InspectGadgetImage(ctx context.Context, image string, authOpts *AuthOptions, publicKeys []string) (*GadgetImageInfo, error) {
+	targetImage, err := normalizeImageName(image)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing image: %w", err)
+	}
+
+	policy := &ImagePolicy{}
+	for _, key := range publicKeys {
+		publicKey, err := parsePublicKey([]byte(key))
+		if err != nil {
+			return nil, err
+		}
+		policy.publicKeys = append(policy.publicKeys, publicKey)
+	}
+
+	repo, err := NewRepository(targetImage.String(), authOpts)
+	if err != nil {
+		return nil, fmt.Errorf("creating remote repository: %w", err)
+	}
+
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
+	imageStore, err := getLocalOciStore()
+	if err != nil {
+		return nil, fmt.Errorf("getting local oci store: %w", err)
+	}
+	var target oras.GraphTarget = imageStore
+	local := true
+	desc, err := imageStore.Resolve(ctx, localReference(targetImage))
+	if err != nil {
+		if !errors.Is(err, errdef.ErrNotFound) {
+			return nil, fmt.Errorf("resolving image %q: %w", image, err)
+		}
+		target = repo
+		local = false
+		desc, err = repo.Resolve(ctx, localReference(targetImage))
+		if err != nil {
+			return nil, fmt.Errorf("resolving image %q: %w", image, err)
+		}
+	}
+
+	info, err := inspectGadgetImage(ctx, target, repo, targetImage, desc, policy)
+	if err != nil {
+		return nil, err
+	}
+	info.Local = local
+	return info, nil
+}
+
+func inspectGadgetImage(ctx context.Context, target oras.GraphTarget, remote oras.ReadOnlyGraphTarget,
+	image reference.Named, desc ocispec.Descriptor, policy *ImagePolicy,
+) (*GadgetImageInfo, error) {
+	manifest, architectures, err := getMetadataManifest(ctx, target, image.String(), desc)
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+
+	metadataBytes, err := getMetadataFromManifest(ctx, target, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("getting metadata: %w", err)
+	}
+	metadata, err := types.DecodeMetadata(metadataBytes, false)
+	if err != nil {
+		return nil, fmt.Errorf("decoding metadata: %w", err)
+	}
+
+	info := &GadgetImageInfo{
+		Image:         image.String(),
+		Digest:        desc.Digest.String(),
+		Architectures: architectures,
+		Signature:     signatureStatus(ctx, remote, desc.Digest.String(), policy),
+		Attestations:  []string{},
+		SharedLayers:  []string{},
+		Metadata:      metadata,
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != eBPFObjectMediaType {
+			info.SharedLayers = append(info.SharedLayers, layer.Annotations[ocispec.AnnotationTitle])
+		}
+	}
+
+	// Attestations are attached to the index, they aren't available for
+	// images with a single manifest.
+	if desc.MediaType == ocispec.MediaTypeImageIndex {
+		var verify func(payload, signature []byte) bool
+		if len(policy.publicKeys) > 0 {
+			verify = policy.verifySignature
+		}
+		predicateTypes, err := attestationPredicateTypes(ctx, remote, desc, verify)
+		if err == nil {
+			for predicateType := range predicateTypes {
+				info.Attestations = append(info.Attestations, predicateType)
+			}
+			sort.Strings(info.Attestations)
+		}
+	}
+
+	return info, nil
+}
+
+// signatureStatus returns the status of the cosign signature of the manifest
+// with the given digest. The signature is only verified if the policy has
+// public keys.
+func signatureStatus(ctx context.Context, remote oras.ReadOnlyTarget, digest string, policy *ImagePolicy) string {
+	if _, err := remote.Resolve(ctx, signatureTag(digest)); err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return SignatureNotSigned
+		}
+		return SignatureUnknown
+	}
+	if len(policy.publicKeys) == 0 {
+		return SignatureNotVerified
+	}
+	if err := policy.verifyCosignSignatures(ctx, remote, digest); err != nil {
+		if errors.Is(err, ErrImageNotAllowed) {
+			return SignatureInvalid
+		}
+		return SignatureUnknown
+	}
+	return SignatureVerified
+}
+
+// getMetadataManifest returns a manifest of the image, whose root manifest is
+// desc, to read the metadata from. The metadata is the same for all the
+// architectures, the ones supported by the image are returned too. Images with
+// a single manifest don't declare their architecture.
+func getMetadataManifest(ctx context.Context, target oras.ReadOnlyTarget, image string, desc ocispec.Descriptor) (*ocispec.Manifest, []string, error) {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest:
+		manifest, err := fetchManifest(ctx, target, desc)
+		return manifest, []string{}, err
+	case ocispec.MediaTypeImageIndex:
+	default:
+		return nil, nil, fmt.Errorf("image %q has an unsupported media type %q", image, desc.MediaType)
+	}
+
+	indexBytes, err := getContentFromDescriptor(ctx, target, desc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting image index: %w", err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, nil, fmt.Errorf("unmarshalling image index: %w", err)
+	}
+
+	architectures := []string{}
+	arch := ""
+	for _, indexManifest := range index.Manifests {
+		if indexManifest.Platform == nil {
+			continue
+		}
+		architectures = append(architectures, indexManifest.Platform.Architecture)
+		// Prefer the manifest of the host, it's the one that is likely present
+		if arch == "" || indexManifest.Platform.Architecture == runtime.GOARCH {
+			arch = indexManifest.Platform.Architecture
+		}
+	}
+	sort.Strings(architectures)
+
+	manifest, err := getArchManifest(ctx, target, index, arch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, architectures, nil
+}
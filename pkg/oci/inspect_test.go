@@ -0,0 +1,93 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func TestInspectGadgetImage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	objectPath := filepath.Join(dir, "program.o")
+	require.NoError(t, os.WriteFile(objectPath, []byte("object"), 0o644))
+	metadataPath := filepath.Join(dir, "gadget.yaml")
+	require.NoError(t, os.WriteFile(metadataPath, []byte(`
+name: trace_open
+capabilities: [CAP_BPF]
+minKernelVersion: "5.8"
+tracers:
+  open:
+    mapName: events
+    structName: event
+`), 0o644))
+	btfPath := filepath.Join(dir, "vmlinux.btf")
+	require.NoError(t, os.WriteFile(btfPath, []byte("btf"), 0o644))
+
+	opts := &BuildGadgetImageOpts{
+		EBPFObjectPaths:  map[string]string{ArchAmd64: objectPath, ArchArm64: objectPath},
+		MetadataPath:     metadataPath,
+		SharedLayerPaths: []string{btfPath},
+		Provenance:       &ProvenanceOpts{BuilderID: "ig image build"},
+	}
+	store := memory.New()
+	desc, err := createImageIndex(ctx, store, opts)
+	require.NoError(t, err)
+	image, err := normalizeImageName("ghcr.io/inspektor-gadget/gadget/trace_open:latest")
+	require.NoError(t, err)
+
+	info, err := inspectGadgetImage(ctx, store, store, image, desc, &ImagePolicy{})
+	require.NoError(t, err)
+	require.Equal(t, "ghcr.io/inspektor-gadget/gadget/trace_open:latest", info.Image)
+	require.Equal(t, desc.Digest.String(), info.Digest)
+	require.Equal(t, []string{ArchAmd64, ArchArm64}, info.Architectures)
+	require.Equal(t, SignatureNotSigned, info.Signature)
+	require.Empty(t, info.Attestations)
+	require.Equal(t, []string{"vmlinux.btf"}, info.SharedLayers)
+	require.Equal(t, "trace_open", info.Metadata.Name)
+	require.Equal(t, []string{"CAP_BPF"}, info.Metadata.Capabilities)
+	require.Equal(t, "5.8", info.Metadata.MinKernelVersion)
+	require.Equal(t, "event", info.Metadata.Tracers["open"].StructName)
+
+	key, _ := generateKey(t)
+	otherKey, _ := generateKey(t)
+	opts.SigningKey = key
+	require.NoError(t, attachAttestations(ctx, store, desc, image.String(), opts))
+	pushSignature(t, store, key, desc.Digest.String(), desc.Digest.String())
+
+	info, err = inspectGadgetImage(ctx, store, store, image, desc, &ImagePolicy{})
+	require.NoError(t, err)
+	require.Equal(t, SignatureNotVerified, info.Signature)
+	require.Equal(t, []string{PredicateTypeSLSAProvenance}, info.Attestations)
+
+	info, err = inspectGadgetImage(ctx, store, store, image, desc, &ImagePolicy{publicKeys: []crypto.PublicKey{&key.PublicKey}})
+	require.NoError(t, err)
+	require.Equal(t, SignatureVerified, info.Signature)
+
+	info, err = inspectGadgetImage(ctx, store, store, image, desc, &ImagePolicy{publicKeys: []crypto.PublicKey{&otherKey.PublicKey}})
+	require.NoError(t, err)
+	require.Equal(t, SignatureInvalid, info.Signature)
+	require.Empty(t, info.Attestations)
+}
@@ -15,6 +15,7 @@
 package oci
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -23,6 +24,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/distribution/reference"
 	"github.com/docker/cli/cli/config"
@@ -38,7 +40,14 @@ import (
 
 type AuthOptions struct {
 	AuthFile string
-	Insecure bool
+	// DockerConfigs contains docker config files, like the ones of the
+	// Kubernetes pull secrets. Their credentials take precedence over the
+	// ones of AuthFile.
+	DockerConfigs [][]byte
+	Insecure      bool
+	// RegistriesConfigFile is the path of the file containing the settings
+	// of the registries. See RegistriesConfig.
+	RegistriesConfigFile string
 }
 
 var (
@@ -46,8 +55,28 @@ var (
 	DefaultAuthFile = "/var/lib/ig/config.json"
 )
 
+// Pull policies of the gadget images
+const (
+	// PullImageAlways pulls the image every time, to get the latest version
+	// of its tag.
+	PullImageAlways = "Always"
+	// PullImageIfNotPresent only pulls the image if it isn't in the local
+	// store.
+	PullImageIfNotPresent = "IfNotPresent"
+	// PullImageNever never pulls the image, it has to be in the local store.
+	PullImageNever = "Never"
+)
+
+// PullPolicies contains all the supported pull policies.
+var PullPolicies = []string{PullImageAlways, PullImageIfNotPresent, PullImageNever}
+
 // GadgetImage is the representation of a gadget packaged in an OCI image.
 type GadgetImage struct {
+	// Ref is the normalized reference of the image, e.g.
+	// "ghcr.io/inspektor-gadget/gadget/trace_open:latest".
+	Ref string
+	// Digest is the digest of the image index or manifest Ref resolved to.
+	Digest     string
 	EbpfObject []byte
 	Metadata   []byte
 }
@@ -73,15 +102,28 @@ func getLocalOciStore() (*oci.Store, error) {
 	return oci.New(defaultOciStore)
 }
 
-// GetGadgetImage pulls the gadget image and returns the a structure representing it.
-func GetGadgetImage(ctx context.Context, image string, authOpts *AuthOptions) (*GadgetImage, error) {
+// GetGadgetImage pulls the gadget image according to the pull policy and
+// returns the a structure representing it.
+func GetGadgetImage(ctx context.Context, image string, authOpts *AuthOptions, pullPolicy string) (*GadgetImage, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
 	imageStore, err := getLocalOciStore()
 	if err != nil {
 		return nil, fmt.Errorf("getting local oci store: %w", err)
 	}
 
-	if err := pullIfNotExist(ctx, imageStore, authOpts, image); err != nil {
-		return nil, fmt.Errorf("pulling image %q: %w", image, err)
+	desc, err := ensureImage(ctx, imageStore, authOpts, image, pullPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("ensuring image %q: %w", image, err)
+	}
+	markImageUsed(desc.Digest.String())
+
+	if err := enforceImagePolicy(ctx, imageStore, image, authOpts); err != nil {
+		return nil, err
 	}
 
 	manifest, err := getImageManifestForArch(ctx, imageStore, image, authOpts)
@@ -99,21 +141,41 @@ func GetGadgetImage(ctx context.Context, image string, authOpts *AuthOptions) (*
 		return nil, fmt.Errorf("getting metadata: %w", err)
 	}
 
+	targetImage, err := normalizeImageName(image)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing image: %w", err)
+	}
+
 	return &GadgetImage{
+		Ref:        targetImage.String(),
+		Digest:     desc.Digest.String(),
 		EbpfObject: prog,
 		Metadata:   metadata,
 	}, nil
 }
 
-// GetEbpfObject pulls the gadget image and returns its eBPF object for the current architecture.
-func GetEbpfObject(ctx context.Context, image string, authOpts *AuthOptions) ([]byte, error) {
+// GetEbpfObject pulls the gadget image according to the pull policy and
+// returns its eBPF object for the current architecture.
+func GetEbpfObject(ctx context.Context, image string, authOpts *AuthOptions, pullPolicy string) ([]byte, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
 	imageStore, err := getLocalOciStore()
 	if err != nil {
 		return nil, fmt.Errorf("getting local oci store: %w", err)
 	}
 
-	if err := pullIfNotExist(ctx, imageStore, authOpts, image); err != nil {
-		return nil, fmt.Errorf("pulling image %q: %w", image, err)
+	desc, err := ensureImage(ctx, imageStore, authOpts, image, pullPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("ensuring image %q: %w", image, err)
+	}
+	markImageUsed(desc.Digest.String())
+
+	if err := enforceImagePolicy(ctx, imageStore, image, authOpts); err != nil {
+		return nil, err
 	}
 
 	manifest, err := getImageManifestForArch(ctx, imageStore, image, authOpts)
@@ -124,15 +186,28 @@ func GetEbpfObject(ctx context.Context, image string, authOpts *AuthOptions) ([]
 	return getEbpfProgramFromManifest(ctx, imageStore, manifest)
 }
 
-// GetMetadata pulls the gadget image and returns its metadata file.
-func GetMetadata(ctx context.Context, image string, authOpts *AuthOptions) ([]byte, error) {
+// GetMetadata pulls the gadget image according to the pull policy and returns
+// its metadata file.
+func GetMetadata(ctx context.Context, image string, authOpts *AuthOptions, pullPolicy string) ([]byte, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
 	imageStore, err := getLocalOciStore()
 	if err != nil {
 		return nil, fmt.Errorf("getting local oci store: %w", err)
 	}
 
-	if err := pullIfNotExist(ctx, imageStore, authOpts, image); err != nil {
-		return nil, fmt.Errorf("pulling image %q: %w", image, err)
+	desc, err := ensureImage(ctx, imageStore, authOpts, image, pullPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("ensuring image %q: %w", image, err)
+	}
+	markImageUsed(desc.Digest.String())
+
+	if err := enforceImagePolicy(ctx, imageStore, image, authOpts); err != nil {
+		return nil, err
 	}
 
 	manifest, err := getImageManifestForArch(ctx, imageStore, image, authOpts)
@@ -145,24 +220,26 @@ func GetMetadata(ctx context.Context, image string, authOpts *AuthOptions) ([]by
 
 // PullGadgetImage pulls the gadget image and returns its descriptor.
 func PullGadgetImage(ctx context.Context, image string, authOpts *AuthOptions) (*GadgetImageDesc, error) {
-	ociStore, err := getLocalOciStore()
+	unlock, err := lockLocalStore(false)
 	if err != nil {
-		return nil, fmt.Errorf("getting oci store: %w", err)
+		return nil, fmt.Errorf("locking oci store: %w", err)
 	}
+	defer unlock()
 
-	repo, err := NewRepository(image, authOpts)
+	ociStore, err := getLocalOciStore()
 	if err != nil {
-		return nil, fmt.Errorf("creating remote repository: %w", err)
+		return nil, fmt.Errorf("getting oci store: %w", err)
 	}
+
 	targetImage, err := normalizeImageName(image)
 	if err != nil {
 		return nil, fmt.Errorf("normalizing image: %w", err)
 	}
-	desc, err := oras.Copy(ctx, repo, targetImage.String(), ociStore,
-		targetImage.String(), oras.DefaultCopyOptions)
+	desc, err := copyFromRemote(ctx, targetImage, authOpts, ociStore, targetImage.String())
 	if err != nil {
-		return nil, fmt.Errorf("copying to remote repository: %w", err)
+		return nil, fmt.Errorf("copying from remote repository: %w", err)
 	}
+	markImageUsed(desc.Digest.String())
 
 	imageDesc := &GadgetImageDesc{
 		Repository: targetImage.Name(),
@@ -174,33 +251,95 @@ func PullGadgetImage(ctx context.Context, image string, authOpts *AuthOptions) (
 	return imageDesc, nil
 }
 
-func pullIfNotExist(ctx context.Context, imageStore oras.Target, authOpts *AuthOptions, image string) error {
+// ensureImage makes sure the image is present in the store according to the
+// pull policy and returns the descriptor it resolves to.
+func ensureImage(ctx context.Context, imageStore oras.Target, authOpts *AuthOptions, image, pullPolicy string) (ocispec.Descriptor, error) {
 	targetImage, err := normalizeImageName(image)
 	if err != nil {
-		return fmt.Errorf("normalizing image: %w", err)
+		return ocispec.Descriptor{}, fmt.Errorf("normalizing image: %w", err)
 	}
+	localRef := localReference(targetImage)
 
-	_, err = imageStore.Resolve(ctx, targetImage.String())
-	if err == nil {
-		return nil
+	switch pullPolicy {
+	case PullImageAlways, PullImageIfNotPresent, PullImageNever:
+	default:
+		return ocispec.Descriptor{}, fmt.Errorf("invalid pull policy %q: expected one of %s",
+			pullPolicy, strings.Join(PullPolicies, ", "))
 	}
-	if !errors.Is(err, errdef.ErrNotFound) {
-		return fmt.Errorf("resolving image %q: %w", image, err)
+
+	// The content of images pinned to a digest can't change, there is no need
+	// to pull them again.
+	_, pinned := targetImage.(reference.Digested)
+	if pullPolicy != PullImageAlways || pinned {
+		desc, err := imageStore.Resolve(ctx, localRef)
+		if err == nil {
+			return desc, nil
+		}
+		if !errors.Is(err, errdef.ErrNotFound) {
+			return ocispec.Descriptor{}, fmt.Errorf("resolving image %q: %w", image, err)
+		}
+		if pullPolicy == PullImageNever {
+			return ocispec.Descriptor{}, fmt.Errorf("image %q not found locally and pull policy is %q", image, PullImageNever)
+		}
 	}
 
-	repo, err := NewRepository(image, authOpts)
+	desc, err := copyFromRemote(ctx, targetImage, authOpts, imageStore, localRef)
 	if err != nil {
-		return fmt.Errorf("creating remote repository: %w", err)
+		return ocispec.Descriptor{}, fmt.Errorf("downloading to local repository: %w", err)
 	}
-	_, err = oras.Copy(ctx, repo, image, imageStore, image, oras.DefaultCopyOptions)
+	return desc, nil
+}
+
+// copyFromRemote copies the image to dst from the first of the mirrors of its
+// registry having it, or from the registry itself.
+func copyFromRemote(ctx context.Context, image reference.Named, authOpts *AuthOptions, dst oras.Target, dstRef string) (ocispec.Descriptor, error) {
+	sources, err := getPullSources(image, authOpts)
 	if err != nil {
-		return fmt.Errorf("downloading to local repository: %w", err)
+		return ocispec.Descriptor{}, err
 	}
-	return nil
+
+	var errs []error
+	for _, source := range sources {
+		repo, err := NewRepository(source, authOpts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("creating remote repository %q: %w", source, err))
+			continue
+		}
+		desc, err := oras.Copy(ctx, repo, source, dst, dstRef, oras.DefaultCopyOptions)
+		if err != nil {
+			log.Debugf("Pulling %q from %q: %v", image.String(), source, err)
+			errs = append(errs, fmt.Errorf("pulling from %q: %w", source, err))
+			continue
+		}
+		if source != image.String() {
+			log.Debugf("Pulled %q from mirror %q", image.String(), source)
+		}
+		return desc, nil
+	}
+	if len(errs) == 1 {
+		return ocispec.Descriptor{}, errs[0]
+	}
+	return ocispec.Descriptor{}, errors.Join(errs...)
+}
+
+// localReference returns the reference of the image in the local store. A
+// digest is used for images pinned to one, as it doesn't depend on the tags
+// present in the store.
+func localReference(image reference.Named) string {
+	if digested, ok := image.(reference.Digested); ok {
+		return digested.Digest().String()
+	}
+	return image.String()
 }
 
 // PushGadgetImage pushes the gadget image and returns its descriptor.
 func PushGadgetImage(ctx context.Context, image string, authOpts *AuthOptions) (*GadgetImageDesc, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
 	ociStore, err := getLocalOciStore()
 	if err != nil {
 		return nil, fmt.Errorf("getting oci store: %w", err)
@@ -214,8 +353,9 @@ func PushGadgetImage(ctx context.Context, image string, authOpts *AuthOptions) (
 	if err != nil {
 		return nil, fmt.Errorf("normalizing image: %w", err)
 	}
-	desc, err := oras.Copy(context.TODO(), ociStore, targetImage.String(), repo,
-		targetImage.String(), oras.DefaultCopyOptions)
+	// Use an extended copy to also push the attestations referring to the image
+	desc, err := oras.ExtendedCopy(context.TODO(), ociStore, targetImage.String(), repo,
+		targetImage.String(), oras.DefaultExtendedCopyOptions)
 	if err != nil {
 		return nil, fmt.Errorf("copying to remote repository: %w", err)
 	}
@@ -241,6 +381,12 @@ func TagGadgetImage(ctx context.Context, srcImage, dstImage string) (*GadgetImag
 		return nil, fmt.Errorf("normalizing dst image: %w", err)
 	}
 
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
 	ociStore, err := getLocalOciStore()
 	if err != nil {
 		return nil, fmt.Errorf("getting oci store: %w", err)
@@ -265,6 +411,12 @@ func TagGadgetImage(ctx context.Context, srcImage, dstImage string) (*GadgetImag
 
 // ListGadgetImages lists all the gadget images.
 func ListGadgetImages(ctx context.Context) ([]*GadgetImageDesc, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
 	ociStore, err := getLocalOciStore()
 	if err != nil {
 		return nil, fmt.Errorf("getting oci store: %w", err)
@@ -347,11 +499,12 @@ func getHostString(repository string) (string, error) {
 	return "", fmt.Errorf("image has to be a named reference")
 }
 
-func newAuthClient(repository string, authOptions *AuthOptions) (*oras_auth.Client, error) {
-	log.Debugf("Using auth file %q", authOptions.AuthFile)
+// dockerHubAuthKey is the key used by docker to store the credentials of
+// Docker Hub.
+const dockerHubAuthKey = "https://index.docker.io/v1/"
 
-	var cfg *configfile.ConfigFile
-	var err error
+func loadAuthFile(authOptions *AuthOptions) (*configfile.ConfigFile, error) {
+	log.Debugf("Using auth file %q", authOptions.AuthFile)
 
 	authFileReader, err := os.Open(authOptions.AuthFile)
 	if err != nil {
@@ -365,33 +518,75 @@ func newAuthClient(repository string, authOptions *AuthOptions) (*oras_auth.Clie
 		log.Debugf("Using default docker auth file instead")
 		log.Debugf("$HOME: %q", os.Getenv("HOME"))
 
-		cfg, err = config.Load("")
+		cfg, err := config.Load("")
 		if err != nil {
 			return nil, fmt.Errorf("loading auth config: %w", err)
 		}
-	} else {
-		defer authFileReader.Close()
-		cfg, err = config.LoadFromReader(authFileReader)
+		return cfg, nil
+	}
+	defer authFileReader.Close()
+
+	cfg, err := config.LoadFromReader(authFileReader)
+	if err != nil {
+		return nil, fmt.Errorf("loading auth config: %w", err)
+	}
+	return cfg, nil
+}
+
+// getCredential returns the credential for the given host from the docker
+// configs. The credential helpers and stores configured in them are used.
+func getCredential(hostString string, cfgs []*configfile.ConfigFile) (oras_auth.Credential, error) {
+	authKey := hostString
+	if hostString == "docker.io" {
+		authKey = dockerHubAuthKey
+	}
+
+	for _, cfg := range cfgs {
+		authConfig, err := cfg.GetAuthConfig(authKey)
 		if err != nil {
-			return nil, fmt.Errorf("loading auth config: %w", err)
+			return oras_auth.EmptyCredential, fmt.Errorf("getting auth config: %w", err)
+		}
+		cred := oras_auth.Credential{
+			Username:     authConfig.Username,
+			Password:     authConfig.Password,
+			RefreshToken: authConfig.IdentityToken,
+			AccessToken:  authConfig.RegistryToken,
+		}
+		if cred != oras_auth.EmptyCredential {
+			return cred, nil
+		}
+	}
+
+	return oras_auth.EmptyCredential, nil
+}
+
+func newAuthClient(repository string, authOptions *AuthOptions) (*oras_auth.Client, error) {
+	cfgs := []*configfile.ConfigFile{}
+	for i, dockerConfig := range authOptions.DockerConfigs {
+		cfg, err := config.LoadFromReader(bytes.NewReader(dockerConfig))
+		if err != nil {
+			return nil, fmt.Errorf("loading docker config %d: %w", i, err)
 		}
+		cfgs = append(cfgs, cfg)
 	}
 
+	cfg, err := loadAuthFile(authOptions)
+	if err != nil {
+		return nil, err
+	}
+	cfgs = append(cfgs, cfg)
+
 	hostString, err := getHostString(repository)
 	if err != nil {
 		return nil, fmt.Errorf("getting host string: %w", err)
 	}
-	authConfig, err := cfg.GetAuthConfig(hostString)
+	cred, err := getCredential(hostString, cfgs)
 	if err != nil {
-		return nil, fmt.Errorf("getting auth config: %w", err)
+		return nil, err
 	}
 
 	return &oras_auth.Client{
-		Credential: oras_auth.StaticCredential(hostString, oras_auth.Credential{
-			Username:    authConfig.Username,
-			Password:    authConfig.Password,
-			AccessToken: authConfig.Auth,
-		}),
+		Credential: oras_auth.StaticCredential(hostString, cred),
 	}, nil
 }
 
@@ -415,55 +610,71 @@ func NewRepository(image string, authOpts *AuthOptions) (*remote.Repository, err
 		repo.Client = client
 	}
 
-	return repo, nil
-}
-
-func getImageListDescriptor(ctx context.Context, imageStore oras.ReadOnlyTarget, reference string) (ocispec.Index, error) {
-	imageListDescriptor, err := imageStore.Resolve(ctx, reference)
+	hostString, err := getHostString(repository)
+	if err != nil {
+		return nil, fmt.Errorf("getting host string: %w", err)
+	}
+	registryConfig, err := getRegistryConfig(hostString, authOpts)
 	if err != nil {
-		return ocispec.Index{}, fmt.Errorf("resolving image %q: %w", reference, err)
+		return nil, fmt.Errorf("getting registry config: %w", err)
 	}
-	if imageListDescriptor.MediaType != ocispec.MediaTypeImageIndex {
-		return ocispec.Index{}, fmt.Errorf("image %q is not an image index", reference)
+	if registryConfig != nil {
+		log.Debugf("Using the configuration of registry %q", hostString)
+		if registryConfig.PlainHTTP {
+			repo.PlainHTTP = true
+		}
+		client, ok := repo.Client.(*oras_auth.Client)
+		if !ok {
+			client = &oras_auth.Client{}
+			repo.Client = client
+		}
+		client.Client = registryConfig.httpClient()
 	}
 
-	reader, err := imageStore.Fetch(ctx, imageListDescriptor)
+	return repo, nil
+}
+
+func fetchManifest(ctx context.Context, imageStore oras.ReadOnlyTarget, desc ocispec.Descriptor) (*ocispec.Manifest, error) {
+	reader, err := imageStore.Fetch(ctx, desc)
 	if err != nil {
-		return ocispec.Index{}, fmt.Errorf("fetching image index: %w", err)
+		return nil, fmt.Errorf("fetching manifest: %w", err)
 	}
 	defer reader.Close()
 
-	var index ocispec.Index
-	if err = json.NewDecoder(reader).Decode(&index); err != nil {
-		return ocispec.Index{}, fmt.Errorf("unmarshalling image index: %w", err)
+	var manifest ocispec.Manifest
+	if err = json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("unmarshalling manifest: %w", err)
 	}
-	return index, nil
+	return &manifest, nil
 }
 
-func getHostArchManifest(imageStore oras.ReadOnlyTarget, index ocispec.Index) (*ocispec.Manifest, error) {
+// getArchManifest returns the manifest for the given architecture from the
+// image index.
+func getArchManifest(ctx context.Context, imageStore oras.ReadOnlyTarget, index ocispec.Index, arch string) (*ocispec.Manifest, error) {
 	var manifestDesc ocispec.Descriptor
 	for _, indexManifest := range index.Manifests {
-		// TODO: Check docker code
-		if indexManifest.Platform.Architecture == runtime.GOARCH {
+		if indexManifest.Platform == nil {
+			continue
+		}
+		if indexManifest.Platform.OS != "" && indexManifest.Platform.OS != "linux" {
+			continue
+		}
+		if indexManifest.Platform.Architecture == arch {
 			manifestDesc = indexManifest
 			break
 		}
 	}
 	if manifestDesc.Digest == "" {
-		return nil, fmt.Errorf("no manifest found for architecture %q", runtime.GOARCH)
-	}
-
-	reader, err := imageStore.Fetch(context.TODO(), manifestDesc)
-	if err != nil {
-		return nil, fmt.Errorf("fetching manifest: %w", err)
+		available := []string{}
+		for _, indexManifest := range index.Manifests {
+			if indexManifest.Platform != nil {
+				available = append(available, indexManifest.Platform.Architecture)
+			}
+		}
+		return nil, fmt.Errorf("no manifest found for architecture %q (available: %s)", arch, strings.Join(available, ", "))
 	}
-	defer reader.Close()
 
-	var manifest ocispec.Manifest
-	if err = json.NewDecoder(reader).Decode(&manifest); err != nil {
-		return nil, fmt.Errorf("unmarshalling manifest: %w", err)
-	}
-	return &manifest, nil
+	return fetchManifest(ctx, imageStore, manifestDesc)
 }
 
 func getMetadataFromManifest(ctx context.Context, target oras.Target, manifest *ocispec.Manifest) ([]byte, error) {
@@ -481,10 +692,16 @@ func getMetadataFromManifest(ctx context.Context, target oras.Target, manifest *
 }
 
 func getEbpfProgramFromManifest(ctx context.Context, target oras.Target, manifest *ocispec.Manifest) ([]byte, error) {
-	if len(manifest.Layers) != 1 {
-		return nil, fmt.Errorf("expected exactly one layer, got %d", len(manifest.Layers))
+	var progDescs []ocispec.Descriptor
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == eBPFObjectMediaType {
+			progDescs = append(progDescs, layer)
+		}
 	}
-	prog, err := getContentFromDescriptor(ctx, target, manifest.Layers[0])
+	if len(progDescs) != 1 {
+		return nil, fmt.Errorf("expected exactly one eBPF program layer, got %d", len(progDescs))
+	}
+	prog, err := getContentFromDescriptor(ctx, target, progDescs[0])
 	if err != nil {
 		return nil, fmt.Errorf("getting ebpf program from descriptor: %w", err)
 	}
@@ -507,18 +724,38 @@ func getContentFromDescriptor(ctx context.Context, imageStore oras.ReadOnlyTarge
 	return bytes, nil
 }
 
+// getImageManifestForArch returns the manifest of the image for the
+// architecture of the host. Images with a single manifest instead of an index
+// are considered to be built for the host.
 func getImageManifestForArch(ctx context.Context, target oras.Target, image string, authOpts *AuthOptions) (*ocispec.Manifest, error) {
 	imageRef, err := normalizeImageName(image)
 	if err != nil {
 		return nil, fmt.Errorf("normalizing image: %w", err)
 	}
 
-	index, err := getImageListDescriptor(ctx, target, imageRef.String())
+	desc, err := target.Resolve(ctx, localReference(imageRef))
+	if err != nil {
+		return nil, fmt.Errorf("resolving image %q: %w", imageRef.String(), err)
+	}
+
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest:
+		return fetchManifest(ctx, target, desc)
+	case ocispec.MediaTypeImageIndex:
+	default:
+		return nil, fmt.Errorf("image %q has an unsupported media type %q", imageRef.String(), desc.MediaType)
+	}
+
+	indexBytes, err := getContentFromDescriptor(ctx, target, desc)
 	if err != nil {
-		return nil, fmt.Errorf("getting image list descriptor: %w", err)
+		return nil, fmt.Errorf("getting image index: %w", err)
+	}
+	var index ocispec.Index
+	if err = json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, fmt.Errorf("unmarshalling image index: %w", err)
 	}
 
-	manifest, err := getHostArchManifest(target, index)
+	manifest, err := getArchManifest(ctx, target, index, runtime.GOARCH)
 	if err != nil {
 		return nil, fmt.Errorf("getting arch manifest: %w", err)
 	}
@@ -15,9 +15,17 @@
 package oci
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
 	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/oci"
+	oras_auth "oras.land/oras-go/v2/registry/remote/auth"
 )
 
 func TestGetRepositoryFromImage(t *testing.T) {
@@ -279,3 +287,89 @@ func TestGetHostString(t *testing.T) {
 		})
 	}
 }
+
+func TestGetCredential(t *testing.T) {
+	t.Parallel()
+
+	load := func(content string) *configfile.ConfigFile {
+		cfg, err := config.LoadFromReader(strings.NewReader(content))
+		require.NoError(t, err)
+		return cfg
+	}
+
+	// "dXNlcjpwYXNz" is "user:pass" and "b3RoZXI6c2VjcmV0" is "other:secret"
+	dockerHub := load(`{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpwYXNz"}}}`)
+	ghcr := load(`{"auths":{"ghcr.io":{"auth":"b3RoZXI6c2VjcmV0"}}}`)
+	token := load(`{"auths":{"ghcr.io":{"identitytoken":"token"}}}`)
+
+	cred, err := getCredential("docker.io", []*configfile.ConfigFile{dockerHub})
+	require.NoError(t, err)
+	require.Equal(t, oras_auth.Credential{Username: "user", Password: "pass"}, cred)
+
+	cred, err = getCredential("ghcr.io", []*configfile.ConfigFile{dockerHub})
+	require.NoError(t, err)
+	require.Equal(t, oras_auth.EmptyCredential, cred)
+
+	// The first config with credentials for the registry is used
+	cred, err = getCredential("ghcr.io", []*configfile.ConfigFile{dockerHub, ghcr, token})
+	require.NoError(t, err)
+	require.Equal(t, oras_auth.Credential{Username: "other", Password: "secret"}, cred)
+
+	cred, err = getCredential("ghcr.io", []*configfile.ConfigFile{token, ghcr})
+	require.NoError(t, err)
+	require.Equal(t, oras_auth.Credential{RefreshToken: "token"}, cred)
+}
+
+func TestEnsureImage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := oci.New(filepath.Join(dir, "store"))
+	require.NoError(t, err)
+
+	objectPath := filepath.Join(dir, "object")
+	require.NoError(t, os.WriteFile(objectPath, []byte("object"), 0o644))
+	metadataPath := filepath.Join(dir, "gadget.yaml")
+	require.NoError(t, os.WriteFile(metadataPath, []byte("name: trace_open"), 0o644))
+	desc, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{ArchAmd64: objectPath, ArchArm64: objectPath},
+		MetadataPath:    metadataPath,
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Tag(ctx, desc, "ghcr.io/inspektor-gadget/gadget/trace_open:latest"))
+
+	authOpts := &AuthOptions{}
+	pinned := "ghcr.io/inspektor-gadget/gadget/trace_open@" + desc.Digest.String()
+
+	got, err := ensureImage(ctx, store, authOpts, "ghcr.io/inspektor-gadget/gadget/trace_open", PullImageIfNotPresent)
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, got.Digest)
+
+	got, err = ensureImage(ctx, store, authOpts, "ghcr.io/inspektor-gadget/gadget/trace_open:latest", PullImageNever)
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, got.Digest)
+
+	// Images pinned to a digest are found by digest and never pulled again
+	for _, pullPolicy := range PullPolicies {
+		got, err = ensureImage(ctx, store, authOpts, pinned, pullPolicy)
+		require.NoError(t, err)
+		require.Equal(t, desc.Digest, got.Digest)
+	}
+	got, err = ensureImage(ctx, store, authOpts, "ghcr.io/inspektor-gadget/gadget/trace_open:latest@"+desc.Digest.String(), PullImageNever)
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, got.Digest)
+
+	_, err = ensureImage(ctx, store, authOpts, "ghcr.io/inspektor-gadget/gadget/trace_exec:latest", PullImageNever)
+	require.ErrorContains(t, err, "not found locally")
+
+	_, err = ensureImage(ctx, store, authOpts, "ghcr.io/inspektor-gadget/gadget/trace_open:latest", "Sometimes")
+	require.ErrorContains(t, err, "invalid pull policy")
+
+	manifest, err := getImageManifestForArch(ctx, store, pinned, authOpts)
+	require.NoError(t, err)
+	prog, err := getEbpfProgramFromManifest(ctx, store, manifest)
+	require.NoError(t, err)
+	require.Equal(t, []byte("object"), prog)
+}
@@ -0,0 +1,384 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+const (
+	// cosignSignatureAnnotation is the annotation of the layers of a cosign
+	// signature manifest containing the base64 encoded signature.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// cosignSignatureType is the type of the payload signed by cosign.
+	cosignSignatureType = "cosign container image signature"
+	// cosignCertificateAnnotation is the annotation of the layers of the
+	// keyless signatures containing the certificate issued by Fulcio.
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+)
+
+// ErrImageNotAllowed is returned when the image policy doesn't allow to run
+// an image.
+var ErrImageNotAllowed = errors.New("image not allowed by policy")
+
+// ImagePolicy restricts the gadget images that can be run. All the
+// restrictions that are set must be satisfied.
+type ImagePolicy struct {
+	// AllowedRegistries contains the registries, optionally followed by a
+	// repository prefix, e.g. "ghcr.io/inspektor-gadget", the images can be
+	// pulled from.
+	AllowedRegistries []string `yaml:"allowedRegistries"`
+	// AllowedDigests contains the digests of the images that can be run.
+	AllowedDigests []string `yaml:"allowedDigests"`
+	// CosignPublicKeys contains PEM encoded public keys. The images must have
+	// a cosign signature made with one of them.
+	CosignPublicKeys []string `yaml:"cosignPublicKeys"`
+	// RequiredAttestations contains the predicate types of the attestations,
+	// e.g. "https://slsa.dev/provenance/v1", that must be attached to the
+	// images, signed with one of CosignPublicKeys.
+	RequiredAttestations []string `yaml:"requiredAttestations"`
+	// CosignIdentities contains the identities of the keyless cosign
+	// signatures. They aren't supported: the policies setting them are
+	// rejected, the images have to be signed with a key.
+	CosignIdentities []CosignIdentity `yaml:"cosignIdentities"`
+
+	publicKeys []crypto.PublicKey
+}
+
+// CosignIdentity is the identity a keyless cosign signature is made with.
+type CosignIdentity struct {
+	// Issuer is the OIDC issuer, e.g. "https://token.actions.githubusercontent.com"
+	Issuer string `yaml:"issuer"`
+	// Subject is the identity in the certificate, e.g. an email address
+	Subject string `yaml:"subject"`
+}
+
+var (
+	imagePolicy   *ImagePolicy
+	imagePolicyMu sync.RWMutex
+)
+
+// ParseImagePolicy parses and validates a policy in YAML format.
+func ParseImagePolicy(data []byte) (*ImagePolicy, error) {
+	policy := &ImagePolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("unmarshaling policy: %w", err)
+	}
+
+	if len(policy.CosignIdentities) > 0 {
+		return nil, errors.New("cosignIdentities: keyless cosign signatures aren't supported, sign the images with a key and use cosignPublicKeys")
+	}
+
+	for i, registry := range policy.AllowedRegistries {
+		policy.AllowedRegistries[i] = strings.TrimSuffix(registry, "/")
+	}
+	for _, digest := range policy.AllowedDigests {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("invalid digest %q: expected sha256:<hex>", digest)
+		}
+	}
+	for _, key := range policy.CosignPublicKeys {
+		publicKey, err := parsePublicKey([]byte(key))
+		if err != nil {
+			return nil, err
+		}
+		policy.publicKeys = append(policy.publicKeys, publicKey)
+	}
+	if len(policy.RequiredAttestations) > 0 && len(policy.publicKeys) == 0 {
+		return nil, errors.New("requiredAttestations needs cosignPublicKeys to verify the attestations")
+	}
+
+	return policy, nil
+}
+
+// LoadImagePolicy reads the policy from the given file.
+func LoadImagePolicy(path string) (*ImagePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy: %w", err)
+	}
+	return ParseImagePolicy(data)
+}
+
+// SetImagePolicy sets the policy checked before running any gadget image. A
+// nil policy allows all images.
+func SetImagePolicy(policy *ImagePolicy) {
+	imagePolicyMu.Lock()
+	defer imagePolicyMu.Unlock()
+	imagePolicy = policy
+}
+
+func getImagePolicy() *ImagePolicy {
+	imagePolicyMu.RLock()
+	defer imagePolicyMu.RUnlock()
+	return imagePolicy
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid public key: no PEM data found")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return publicKey, nil
+}
+
+// registryAllowed returns true if the repository, e.g.
+// "ghcr.io/inspektor-gadget/gadget/trace_open", is part of the allowed
+// registries.
+func (p *ImagePolicy) registryAllowed(repository string) bool {
+	if len(p.AllowedRegistries) == 0 {
+		return true
+	}
+	for _, registry := range p.AllowedRegistries {
+		if repository == registry || strings.HasPrefix(repository, registry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ImagePolicy) digestAllowed(digest string) bool {
+	if len(p.AllowedDigests) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedDigests {
+		if digest == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// cosignPayload is the part of the payload signed by cosign that is checked.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// verifySignature verifies the signature of payload with one of the public
+// keys of the policy.
+func (p *ImagePolicy) verifySignature(payload, signature []byte) bool {
+	hash := sha256.Sum256(payload)
+	for _, publicKey := range p.publicKeys {
+		switch key := publicKey.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, hash[:], signature) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, payload, signature) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// signatureTag returns the tag of the cosign signature of the manifest with
+// the given digest.
+func signatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// verifyCosignSignatures looks for a cosign signature of the manifest with the
+// given digest made with one of the public keys of the policy. The signatures
+// are stored by cosign in the same repository as the image, in the
+// "sha256-<hex>.sig" tag.
+func (p *ImagePolicy) verifyCosignSignatures(ctx context.Context, repo oras.ReadOnlyTarget, digest string) error {
+	sigDesc, err := repo.Resolve(ctx, signatureTag(digest))
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return fmt.Errorf("%w: no signature found", ErrImageNotAllowed)
+		}
+		return fmt.Errorf("resolving signature: %w", err)
+	}
+
+	manifestBytes, err := content.FetchAll(ctx, repo, sigDesc)
+	if err != nil {
+		return fmt.Errorf("fetching signature manifest: %w", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return fmt.Errorf("unmarshaling signature manifest: %w", err)
+	}
+
+	keyless := false
+	for _, layer := range manifest.Layers {
+		encoded, ok := layer.Annotations[cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		if _, ok := layer.Annotations[cosignCertificateAnnotation]; ok {
+			keyless = true
+		}
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		payload, err := content.FetchAll(ctx, repo, layer)
+		if err != nil {
+			return fmt.Errorf("fetching signature payload: %w", err)
+		}
+		if !p.verifySignature(payload, signature) {
+			continue
+		}
+
+		// The signature is valid, check it's about this image
+		var signed cosignPayload
+		if err := json.Unmarshal(payload, &signed); err != nil {
+			continue
+		}
+		if signed.Critical.Type == cosignSignatureType && signed.Critical.Image.DockerManifestDigest == digest {
+			return nil
+		}
+	}
+
+	if keyless {
+		return fmt.Errorf("%w: no valid signature found, keyless signatures aren't supported", ErrImageNotAllowed)
+	}
+	return fmt.Errorf("%w: no valid signature found", ErrImageNotAllowed)
+}
+
+// check verifies the image, whose root manifest is desc, can be run. remote
+// is where the signatures and the attestations are looked for.
+func (p *ImagePolicy) check(ctx context.Context, image reference.Named, desc ocispec.Descriptor, remote oras.ReadOnlyGraphTarget) error {
+	digest := desc.Digest.String()
+	if !p.registryAllowed(image.Name()) {
+		return fmt.Errorf("%w: repository %q isn't part of the allowed registries", ErrImageNotAllowed, image.Name())
+	}
+	if !p.digestAllowed(digest) {
+		return fmt.Errorf("%w: digest %q isn't allowed", ErrImageNotAllowed, digest)
+	}
+	if len(p.publicKeys) > 0 {
+		if err := p.verifyCosignSignatures(ctx, remote, digest); err != nil {
+			return err
+		}
+	}
+	if len(p.RequiredAttestations) > 0 {
+		types, err := attestationPredicateTypes(ctx, remote, desc, p.verifySignature)
+		if err != nil {
+			return fmt.Errorf("getting attestations: %w", err)
+		}
+		for _, required := range p.RequiredAttestations {
+			if _, ok := types[required]; !ok {
+				return fmt.Errorf("%w: no signed %q attestation found", ErrImageNotAllowed, required)
+			}
+		}
+	}
+	return nil
+}
+
+// enforceImagePolicy checks the image, already present in the store, against
+// the policy set with SetImagePolicy().
+func enforceImagePolicy(ctx context.Context, imageStore oras.ReadOnlyTarget, image string, authOpts *AuthOptions) error {
+	policy := getImagePolicy()
+	if policy == nil {
+		return nil
+	}
+
+	targetImage, err := normalizeImageName(image)
+	if err != nil {
+		return fmt.Errorf("normalizing image: %w", err)
+	}
+	desc, err := imageStore.Resolve(ctx, localReference(targetImage))
+	if err != nil {
+		return fmt.Errorf("resolving image %q: %w", image, err)
+	}
+
+	if len(policy.publicKeys) == 0 && len(policy.RequiredAttestations) == 0 {
+		if err := policy.check(ctx, targetImage, desc, nil); err != nil {
+			return fmt.Errorf("checking image %q: %w", image, err)
+		}
+		return nil
+	}
+
+	sources, err := getPullSources(targetImage, authOpts)
+	if err != nil {
+		return err
+	}
+	err = policy.checkFromSources(ctx, targetImage, desc, sources, func(source string) (oras.ReadOnlyGraphTarget, error) {
+		return NewRepository(source, authOpts)
+	})
+	if err != nil {
+		return fmt.Errorf("checking image %q: %w", image, err)
+	}
+	return nil
+}
+
+// checkFromSources checks the image, whose root manifest is desc, with the
+// signatures and the attestations of the first of the sources it's pulled
+// from, the mirrors of its registry and the registry itself, satisfying the
+// policy. getRemote returns the repository of a source.
+func (p *ImagePolicy) checkFromSources(ctx context.Context, image reference.Named, desc ocispec.Descriptor,
+	sources []string, getRemote func(source string) (oras.ReadOnlyGraphTarget, error),
+) error {
+	var errs []error
+	for _, source := range sources {
+		remote, err := getRemote(source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("creating remote repository %q: %w", source, err))
+			continue
+		}
+		err = p.check(ctx, image, desc, remote)
+		if err == nil {
+			return nil
+		}
+		if len(sources) == 1 {
+			return err
+		}
+		log.Debugf("Checking %q from %q: %v", image.String(), source, err)
+		errs = append(errs, fmt.Errorf("from %q: %w", source, err))
+	}
+	return errors.Join(errs...)
+}
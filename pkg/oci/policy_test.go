@@ -0,0 +1,311 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func generateKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// pushSignature stores a cosign-like signature of signedDigest in the store,
+// as the signature of the image with imageDigest.
+func pushSignature(t *testing.T, store *memory.Store, key *ecdsa.PrivateKey, imageDigest, signedDigest string) {
+	ctx := context.Background()
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"ghcr.io/inspektor-gadget/gadget/trace_open"},"image":{"docker-manifest-digest":%q},"type":%q},"optional":null}`,
+		signedDigest, cosignSignatureType))
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+
+	layer := content.NewDescriptorFromBytes("application/vnd.dev.cosign.simplesigning.v1+json", payload)
+	layer.Annotations = map[string]string{
+		cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature),
+	}
+	require.NoError(t, store.Push(ctx, layer, bytes.NewReader(payload)))
+
+	manifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.DescriptorEmptyJSON,
+		Layers:    []ocispec.Descriptor{layer},
+	}
+	manifest.SchemaVersion = 2
+	manifestBytes, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	manifestDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestBytes)
+	require.NoError(t, store.Push(ctx, manifestDesc, bytes.NewReader(manifestBytes)))
+
+	require.NoError(t, store.Tag(ctx, manifestDesc, signatureTag(imageDigest)))
+}
+
+func TestParseImagePolicy(t *testing.T) {
+	t.Parallel()
+
+	_, publicKey := generateKey(t)
+
+	policy, err := ParseImagePolicy([]byte(fmt.Sprintf(`
+allowedRegistries:
+  - ghcr.io/inspektor-gadget/
+allowedDigests:
+  - %s
+cosignPublicKeys:
+  - |
+%s`, testDigest, indent(publicKey))))
+	require.NoError(t, err)
+	require.Equal(t, []string{"ghcr.io/inspektor-gadget"}, policy.AllowedRegistries)
+	require.Equal(t, []string{testDigest}, policy.AllowedDigests)
+	require.Len(t, policy.publicKeys, 1)
+
+	_, err = ParseImagePolicy([]byte("allowedDigests: [0123]"))
+	require.Error(t, err)
+
+	_, err = ParseImagePolicy([]byte("cosignPublicKeys: [foo]"))
+	require.Error(t, err)
+
+	_, err = ParseImagePolicy([]byte("unknownField: true"))
+	require.Error(t, err)
+
+	_, err = ParseImagePolicy([]byte("requiredAttestations: [https://slsa.dev/provenance/v1]"))
+	require.Error(t, err)
+
+	_, err = ParseImagePolicy([]byte(`
+cosignIdentities:
+  - issuer: https://token.actions.githubusercontent.com
+    subject: https://github.com/inspektor-gadget/inspektor-gadget/.github/workflows/inspektor-gadget.yml@refs/heads/main
+`))
+	require.ErrorContains(t, err, "keyless cosign signatures aren't supported")
+}
+
+func indent(s string) string {
+	var buf bytes.Buffer
+	for _, line := range bytes.Split([]byte(s), []byte("\n")) {
+		if len(line) > 0 {
+			buf.WriteString("    ")
+			buf.Write(line)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func TestImagePolicyCheck(t *testing.T) {
+	t.Parallel()
+
+	key, publicKey := generateKey(t)
+	otherKey, _ := generateKey(t)
+
+	image, err := normalizeImageName("ghcr.io/inspektor-gadget/gadget/trace_open:latest")
+	require.NoError(t, err)
+
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.Digest(testDigest),
+		Size:      1,
+	}
+
+	type testDefinition struct {
+		policy       string
+		signedBy     *ecdsa.PrivateKey
+		signedFor    string
+		attestations []string
+		attestedBy   *ecdsa.PrivateKey
+		allowed      bool
+	}
+
+	tests := map[string]testDefinition{
+		"empty": {
+			policy:  "{}",
+			allowed: true,
+		},
+		"allowed_registry": {
+			policy:  "allowedRegistries: [ghcr.io]",
+			allowed: true,
+		},
+		"allowed_repository_prefix": {
+			policy:  "allowedRegistries: [ghcr.io/inspektor-gadget]",
+			allowed: true,
+		},
+		"partial_repository_prefix": {
+			policy:  "allowedRegistries: [ghcr.io/inspektor]",
+			allowed: false,
+		},
+		"other_registry": {
+			policy:  "allowedRegistries: [docker.io, quay.io]",
+			allowed: false,
+		},
+		"allowed_digest": {
+			policy:  fmt.Sprintf("allowedDigests: [%s]", testDigest),
+			allowed: true,
+		},
+		"other_digest": {
+			policy:  "allowedDigests: [sha256:fedcba]",
+			allowed: false,
+		},
+		"signed": {
+			policy:    "cosignPublicKeys:\n  - |\n" + indent(publicKey),
+			signedBy:  key,
+			signedFor: testDigest,
+			allowed:   true,
+		},
+		"not_signed": {
+			policy:  "cosignPublicKeys:\n  - |\n" + indent(publicKey),
+			allowed: false,
+		},
+		"signed_by_other_key": {
+			policy:    "cosignPublicKeys:\n  - |\n" + indent(publicKey),
+			signedBy:  otherKey,
+			signedFor: testDigest,
+			allowed:   false,
+		},
+		"signature_for_other_image": {
+			policy:    "cosignPublicKeys:\n  - |\n" + indent(publicKey),
+			signedBy:  key,
+			signedFor: "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210",
+			allowed:   false,
+		},
+		"required_attestations": {
+			policy:       "requiredAttestations: [https://slsa.dev/provenance/v1, https://spdx.dev/Document]\ncosignPublicKeys:\n  - |\n" + indent(publicKey),
+			signedBy:     key,
+			signedFor:    testDigest,
+			attestations: []string{PredicateTypeSLSAProvenance, PredicateTypeSPDX},
+			attestedBy:   key,
+			allowed:      true,
+		},
+		"missing_attestation": {
+			policy:       "requiredAttestations: [https://slsa.dev/provenance/v1, https://spdx.dev/Document]\ncosignPublicKeys:\n  - |\n" + indent(publicKey),
+			signedBy:     key,
+			signedFor:    testDigest,
+			attestations: []string{PredicateTypeSLSAProvenance},
+			attestedBy:   key,
+			allowed:      false,
+		},
+		"attestations_signed_by_other_key": {
+			policy:       "requiredAttestations: [https://slsa.dev/provenance/v1]\ncosignPublicKeys:\n  - |\n" + indent(publicKey),
+			signedBy:     key,
+			signedFor:    testDigest,
+			attestations: []string{PredicateTypeSLSAProvenance},
+			attestedBy:   otherKey,
+			allowed:      false,
+		},
+		"signed_other_registry": {
+			policy:    "allowedRegistries: [docker.io]\ncosignPublicKeys:\n  - |\n" + indent(publicKey),
+			signedBy:  key,
+			signedFor: testDigest,
+			allowed:   false,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy, err := ParseImagePolicy([]byte(test.policy))
+			require.NoError(t, err)
+
+			store := memory.New()
+			if test.signedBy != nil {
+				pushSignature(t, store, test.signedBy, testDigest, test.signedFor)
+			}
+			for _, predicateType := range test.attestations {
+				err := attachAttestation(context.Background(), store, desc, image.String(), predicateType, []byte("{}"), test.attestedBy)
+				require.NoError(t, err)
+			}
+
+			err = policy.check(context.Background(), image, desc, store)
+			if test.allowed {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrImageNotAllowed)
+			}
+		})
+	}
+}
+
+func TestImagePolicyCheckFromSources(t *testing.T) {
+	t.Parallel()
+
+	key, publicKey := generateKey(t)
+
+	image, err := normalizeImageName("ghcr.io/inspektor-gadget/gadget/trace_open:latest")
+	require.NoError(t, err)
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.Digest(testDigest),
+		Size:      1,
+	}
+
+	policy, err := ParseImagePolicy([]byte("cosignPublicKeys:\n  - |\n" + indent(publicKey)))
+	require.NoError(t, err)
+
+	mirror := memory.New()
+	origin := memory.New()
+	remotes := map[string]oras.ReadOnlyGraphTarget{
+		"mirror.local/inspektor-gadget/gadget/trace_open:latest": mirror,
+		image.String(): origin,
+	}
+	getRemote := func(source string) (oras.ReadOnlyGraphTarget, error) {
+		remote, ok := remotes[source]
+		if !ok {
+			return nil, fmt.Errorf("unreachable")
+		}
+		return remote, nil
+	}
+
+	// The signature is only available in the mirror, the origin can't be
+	// reached
+	pushSignature(t, mirror, key, testDigest, testDigest)
+	sources := []string{"mirror.local/inspektor-gadget/gadget/trace_open:latest", "unreachable.local/trace_open:latest"}
+	require.NoError(t, policy.checkFromSources(context.Background(), image, desc, sources, getRemote))
+
+	// The mirror doesn't have it, the origin does
+	sources = []string{"mirror.local/inspektor-gadget/gadget/trace_open:latest", image.String()}
+	mirror = memory.New()
+	remotes["mirror.local/inspektor-gadget/gadget/trace_open:latest"] = mirror
+	pushSignature(t, origin, key, testDigest, testDigest)
+	require.NoError(t, policy.checkFromSources(context.Background(), image, desc, sources, getRemote))
+
+	// None of them has it
+	remotes[image.String()] = memory.New()
+	err = policy.checkFromSources(context.Background(), image, desc, sources, getRemote)
+	require.ErrorIs(t, err, ErrImageNotAllowed)
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/distribution/reference"
+	log "github.com/sirupsen/logrus"
+	"oras.land/oras-go/v2"
+)
+
+// PreloadGadgetImages makes sure the given images are in the local store and
+// can be run on this host, so running them later doesn't have to wait for the
+// registry. The images are processed one after the other, the failures of all
+// of them are returned.
+func PreloadGadgetImages(ctx context.Context, images []string, authOpts *AuthOptions) error {
+	var errs []error
+	for _, image := range images {
+		desc, err := PreloadGadgetImage(ctx, image, authOpts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("preloading image %q: %w", image, err))
+			continue
+		}
+		log.Infof("Preloaded gadget image %s", desc.String())
+	}
+	return errors.Join(errs...)
+}
+
+// PreloadGadgetImage pulls the image, to get the latest version of its tag,
+// and verifies it has a manifest for the architecture of the host and is
+// allowed by the image policy. The local copy of the image is used if it can't
+// be pulled.
+func PreloadGadgetImage(ctx context.Context, image string, authOpts *AuthOptions) (*GadgetImageDesc, error) {
+	unlock, err := lockLocalStore(false)
+	if err != nil {
+		return nil, fmt.Errorf("locking oci store: %w", err)
+	}
+	defer unlock()
+
+	imageStore, err := getLocalOciStore()
+	if err != nil {
+		return nil, fmt.Errorf("getting local oci store: %w", err)
+	}
+
+	imageDesc, err := preloadGadgetImage(ctx, imageStore, image, authOpts)
+	if err != nil {
+		return nil, err
+	}
+	markImageUsed(imageDesc.Digest)
+	return imageDesc, nil
+}
+
+func preloadGadgetImage(ctx context.Context, imageStore oras.Target, image string, authOpts *AuthOptions) (*GadgetImageDesc, error) {
+	targetImage, err := normalizeImageName(image)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing image: %w", err)
+	}
+
+	desc, err := ensureImage(ctx, imageStore, authOpts, image, PullImageAlways)
+	if err != nil {
+		var localErr error
+		desc, localErr = ensureImage(ctx, imageStore, authOpts, image, PullImageNever)
+		if localErr != nil {
+			return nil, err
+		}
+		log.Warnf("Pulling gadget image %q failed, using the local copy: %v", image, err)
+	}
+
+	if err := enforceImagePolicy(ctx, imageStore, image, authOpts); err != nil {
+		return nil, err
+	}
+
+	if _, err := getImageManifestForArch(ctx, imageStore, image, authOpts); err != nil {
+		return nil, fmt.Errorf("getting arch manifest: %w", err)
+	}
+
+	imageDesc := &GadgetImageDesc{
+		Repository: targetImage.Name(),
+		Digest:     desc.Digest.String(),
+	}
+	if ref, ok := targetImage.(reference.Tagged); ok {
+		imageDesc.Tag = ref.Tag()
+	}
+	return imageDesc, nil
+}
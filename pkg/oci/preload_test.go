@@ -0,0 +1,73 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func TestPreloadGadgetImage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	objectPath := filepath.Join(dir, "program.o")
+	require.NoError(t, os.WriteFile(objectPath, []byte("object"), 0o644))
+	metadataPath := filepath.Join(dir, "gadget.yaml")
+	require.NoError(t, os.WriteFile(metadataPath, []byte("name: test"), 0o644))
+
+	store := memory.New()
+	desc, err := createImageIndex(ctx, store, &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{runtime.GOARCH: objectPath},
+		MetadataPath:    metadataPath,
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Tag(ctx, desc, "localhost:1/gadget:latest"))
+	authOpts := &AuthOptions{AuthFile: DefaultAuthFile}
+
+	// The registry can't be reached, the local copy is used
+	imageDesc, err := preloadGadgetImage(ctx, store, "localhost:1/gadget:latest", authOpts)
+	require.NoError(t, err)
+	require.Equal(t, &GadgetImageDesc{
+		Repository: "localhost:1/gadget",
+		Tag:        "latest",
+		Digest:     desc.Digest.String(),
+	}, imageDesc)
+
+	_, err = preloadGadgetImage(ctx, store, "localhost:1/missing:latest", authOpts)
+	require.Error(t, err)
+
+	// The image has to be built for the host
+	otherArch := ArchArm64
+	if runtime.GOARCH == ArchArm64 {
+		otherArch = ArchAmd64
+	}
+	desc, err = createImageIndex(ctx, store, &BuildGadgetImageOpts{
+		EBPFObjectPaths: map[string]string{otherArch: objectPath},
+		MetadataPath:    metadataPath,
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Tag(ctx, desc, "localhost:1/other:latest"))
+	_, err = preloadGadgetImage(ctx, store, "localhost:1/other:latest", authOpts)
+	require.ErrorContains(t, err, "arch manifest")
+}
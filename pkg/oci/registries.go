@@ -0,0 +1,222 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/distribution/reference"
+	"gopkg.in/yaml.v2"
+)
+
+var DefaultRegistriesConfigFile = "/var/lib/ig/registries.yaml"
+
+// RegistryConfig contains the connection settings of a registry.
+type RegistryConfig struct {
+	// PlainHTTP uses HTTP instead of HTTPS, e.g. for lab registries.
+	PlainHTTP bool `yaml:"plainHTTP"`
+	// CAFile is the path of a PEM bundle with the CAs to trust, in addition
+	// to the system ones.
+	CAFile string `yaml:"caFile"`
+	// CA is a PEM bundle with the CAs to trust. It's useful when the
+	// configuration comes from a ConfigMap.
+	CA string `yaml:"ca"`
+	// InsecureSkipTLSVerify disables the verification of the certificate
+	// of the registry.
+	InsecureSkipTLSVerify bool `yaml:"insecureSkipTLSVerify"`
+	// Proxy is the URL of the proxy to use. The HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY environment variables are used if it's not set.
+	Proxy string `yaml:"proxy"`
+	// Mirrors are tried in order, before the registry itself, to pull the
+	// images. They are made of a host, with an optional path prefix, e.g.
+	// "proxy.corp:5000/ghcr". The connection settings of a mirror are the
+	// ones of its host.
+	Mirrors []string `yaml:"mirrors"`
+
+	rootCAs  *x509.CertPool
+	proxyURL *url.URL
+}
+
+// RegistriesConfig contains the settings of the registries, indexed by the
+// host, e.g. "myregistry.io:5000".
+type RegistriesConfig struct {
+	Registries map[string]*RegistryConfig `yaml:"registries"`
+}
+
+var (
+	registriesConfig   *RegistriesConfig
+	registriesConfigMu sync.RWMutex
+)
+
+// ParseRegistriesConfig parses and validates a registries configuration in
+// YAML format.
+func ParseRegistriesConfig(data []byte) (*RegistriesConfig, error) {
+	config := &RegistriesConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("unmarshaling registries config: %w", err)
+	}
+
+	for host, registry := range config.Registries {
+		if registry == nil {
+			config.Registries[host] = &RegistryConfig{}
+			continue
+		}
+
+		ca := []byte(registry.CA)
+		if registry.CAFile != "" {
+			content, err := os.ReadFile(registry.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading CA file of registry %q: %w", host, err)
+			}
+			ca = append(ca, '\n')
+			ca = append(ca, content...)
+		}
+		if len(ca) > 0 {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no valid certificate found in the CA of registry %q", host)
+			}
+			registry.rootCAs = pool
+		}
+
+		for i, mirror := range registry.Mirrors {
+			mirror = strings.TrimSuffix(mirror, "/")
+			if err := validateMirror(mirror); err != nil {
+				return nil, fmt.Errorf("invalid mirror %q of registry %q: %w", mirror, host, err)
+			}
+			registry.Mirrors[i] = mirror
+		}
+
+		if registry.Proxy != "" {
+			proxyURL, err := url.Parse(registry.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("parsing proxy of registry %q: %w", host, err)
+			}
+			registry.proxyURL = proxyURL
+		}
+	}
+
+	return config, nil
+}
+
+// LoadRegistriesConfig reads the registries configuration from the given file.
+func LoadRegistriesConfig(path string) (*RegistriesConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading registries config: %w", err)
+	}
+	return ParseRegistriesConfig(data)
+}
+
+// SetRegistriesConfig sets the registries configuration used for all the
+// connections, ignoring the RegistriesConfigFile of the AuthOptions.
+func SetRegistriesConfig(config *RegistriesConfig) {
+	registriesConfigMu.Lock()
+	defer registriesConfigMu.Unlock()
+	registriesConfig = config
+}
+
+// getRegistryConfig returns the configuration of the registry, or nil if it
+// doesn't have one.
+func getRegistryConfig(host string, authOpts *AuthOptions) (*RegistryConfig, error) {
+	registriesConfigMu.RLock()
+	config := registriesConfig
+	registriesConfigMu.RUnlock()
+
+	if config == nil {
+		path := authOpts.RegistriesConfigFile
+		if path == "" {
+			path = DefaultRegistriesConfigFile
+		}
+		var err error
+		config, err = LoadRegistriesConfig(path)
+		if err != nil {
+			// As for the auth file, the default one is optional
+			if errors.Is(err, os.ErrNotExist) && path == DefaultRegistriesConfigFile {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	return config.Registries[host], nil
+}
+
+// validateMirror checks the mirror is made of a host and an optional path.
+func validateMirror(mirror string) error {
+	if strings.Contains(mirror, "://") {
+		return errors.New("the scheme must not be given, use plainHTTP in the configuration of the mirror instead")
+	}
+	host, _, _ := strings.Cut(mirror, "/")
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return fmt.Errorf("%q isn't a registry host", host)
+	}
+	if _, err := reference.ParseNamed(mirror + "/image"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getPullSources returns the references to pull the image from: the image in
+// the mirrors of its registry followed by the image itself.
+func getPullSources(image reference.Named, authOpts *AuthOptions) ([]string, error) {
+	registry, err := getRegistryConfig(reference.Domain(image), authOpts)
+	if err != nil {
+		return nil, fmt.Errorf("getting registry config: %w", err)
+	}
+
+	suffix := ""
+	if tagged, ok := image.(reference.Tagged); ok {
+		suffix += ":" + tagged.Tag()
+	}
+	if digested, ok := image.(reference.Digested); ok {
+		suffix += "@" + digested.Digest().String()
+	}
+
+	sources := []string{}
+	if registry != nil {
+		for _, mirror := range registry.Mirrors {
+			sources = append(sources, mirror+"/"+reference.Path(image)+suffix)
+		}
+	}
+	return append(sources, image.String()), nil
+}
+
+// httpClient returns a client using the TLS and proxy settings of the
+// registry.
+func (c *RegistryConfig) httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.rootCAs != nil || c.InsecureSkipTLSVerify {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:            c.rootCAs,
+			InsecureSkipVerify: c.InsecureSkipTLSVerify,
+		}
+	}
+	if c.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(c.proxyURL)
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRegistriesConfig(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte(ca), 0o600))
+
+	config, err := ParseRegistriesConfig([]byte(fmt.Sprintf(`
+registries:
+  inline.io:
+    ca: |
+%s
+  file.io:
+    caFile: %s
+    proxy: http://proxy.local:3128
+  lab.local:5000:
+    plainHTTP: true
+  empty.io:
+`, indent(indent(ca)), caFile)))
+	require.NoError(t, err)
+	require.Len(t, config.Registries, 4)
+	require.NotNil(t, config.Registries["inline.io"].rootCAs)
+	require.NotNil(t, config.Registries["file.io"].rootCAs)
+	require.Equal(t, "proxy.local:3128", config.Registries["file.io"].proxyURL.Host)
+	require.True(t, config.Registries["lab.local:5000"].PlainHTTP)
+	require.NotNil(t, config.Registries["empty.io"])
+
+	// The client trusts the configured CA
+	resp, err := config.Registries["inline.io"].httpClient().Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = (&RegistryConfig{}).httpClient().Get(server.URL)
+	require.Error(t, err)
+
+	_, err = ParseRegistriesConfig([]byte("registries: {foo.io: {ca: foo}}"))
+	require.Error(t, err)
+
+	_, err = ParseRegistriesConfig([]byte("registries: {foo.io: {caFile: /does/not/exist}}"))
+	require.Error(t, err)
+
+	_, err = ParseRegistriesConfig([]byte("registries: {foo.io: {unknownField: true}}"))
+	require.Error(t, err)
+
+	for _, mirror := range []string{"https://proxy.corp", "proxy", "proxy.corp/UPPER"} {
+		_, err = ParseRegistriesConfig([]byte(fmt.Sprintf("registries: {ghcr.io: {mirrors: [%q]}}", mirror)))
+		require.Error(t, err, mirror)
+	}
+}
+
+func TestGetPullSources(t *testing.T) {
+	t.Parallel()
+
+	configFile := filepath.Join(t.TempDir(), "registries.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+registries:
+  ghcr.io:
+    mirrors: [proxy.corp:5000/ghcr/, localhost:5000]
+  docker.io:
+    mirrors: [proxy.corp/hub]
+`), 0o600))
+	authOpts := &AuthOptions{RegistriesConfigFile: configFile}
+
+	for image, expected := range map[string][]string{
+		"ghcr.io/inspektor-gadget/gadget/trace_open:latest": {
+			"proxy.corp:5000/ghcr/inspektor-gadget/gadget/trace_open:latest",
+			"localhost:5000/inspektor-gadget/gadget/trace_open:latest",
+			"ghcr.io/inspektor-gadget/gadget/trace_open:latest",
+		},
+		"ghcr.io/inspektor-gadget/gadget/trace_open:latest@" + testDigest: {
+			"proxy.corp:5000/ghcr/inspektor-gadget/gadget/trace_open:latest@" + testDigest,
+			"localhost:5000/inspektor-gadget/gadget/trace_open:latest@" + testDigest,
+			"ghcr.io/inspektor-gadget/gadget/trace_open:latest@" + testDigest,
+		},
+		"mygadget:v1": {
+			"proxy.corp/hub/library/mygadget:v1",
+			"docker.io/library/mygadget:v1",
+		},
+		"quay.io/mygadget:v1": {
+			"quay.io/mygadget:v1",
+		},
+	} {
+		targetImage, err := normalizeImageName(image)
+		require.NoError(t, err)
+		sources, err := getPullSources(targetImage, authOpts)
+		require.NoError(t, err)
+		require.Equal(t, expected, sources, image)
+	}
+}
+
+func TestGetRegistryConfig(t *testing.T) {
+	t.Parallel()
+
+	configFile := filepath.Join(t.TempDir(), "registries.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("registries: {lab.local:5000: {plainHTTP: true}}"), 0o600))
+
+	registry, err := getRegistryConfig("lab.local:5000", &AuthOptions{RegistriesConfigFile: configFile})
+	require.NoError(t, err)
+	require.True(t, registry.PlainHTTP)
+
+	registry, err = getRegistryConfig("ghcr.io", &AuthOptions{RegistriesConfigFile: configFile})
+	require.NoError(t, err)
+	require.Nil(t, registry)
+
+	_, err = getRegistryConfig("ghcr.io", &AuthOptions{RegistriesConfigFile: filepath.Join(t.TempDir(), "missing.yaml")})
+	require.Error(t, err)
+}
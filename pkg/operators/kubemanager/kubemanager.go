@@ -22,6 +22,7 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -32,12 +33,13 @@ import (
 )
 
 const (
-	OperatorName       = "KubeManager"
-	ParamContainerName = "containername"
-	ParamSelector      = "selector"
-	ParamAllNamespaces = "all-namespaces"
-	ParamPodName       = "podname"
-	ParamNamespace     = "namespace"
+	OperatorName        = "KubeManager"
+	ParamContainerName  = "containername"
+	ParamSelector       = "selector"
+	ParamAllNamespaces  = "all-namespaces"
+	ParamPodName        = "podname"
+	ParamNamespace      = "namespace"
+	ParamContainerTypes = "container-types"
 )
 
 type MountNsMapSetter interface {
@@ -118,6 +120,25 @@ func (k *KubeManager) ParamDescs() params.ParamDescs {
 			Description: "Show only data from pods in a given namespace",
 			ValueHint:   gadgets.K8SNamespace,
 		},
+		{
+			Key: ParamContainerTypes,
+			Description: fmt.Sprintf("Show only data from containers of these types separated by comma, or not of the ones prefixed with '!'. Supported values are: %s",
+				strings.Join(types.AllContainerTypes, ", ")),
+			Validator: func(value string) error {
+				if value == "" {
+					return nil
+				}
+
+				for _, t := range strings.Split(value, ",") {
+					if !slices.Contains(types.AllContainerTypes, strings.TrimPrefix(t, "!")) {
+						return fmt.Errorf("invalid container type %q: supported values are %s",
+							t, strings.Join(types.AllContainerTypes, ", "))
+					}
+				}
+
+				return nil
+			},
+		},
 	}
 }
 
@@ -186,6 +207,9 @@ type KubeManagerInstance struct {
 	enrichEvents bool
 	mountnsmap   *ebpf.Map
 	subscribed   bool
+	// sandboxSubscribed is set when subscribed to warn about the sandboxed
+	// containers
+	sandboxSubscribed bool
 
 	attachedContainers map[string]*containercollection.Container
 	attacher           Attacher
@@ -194,6 +218,10 @@ type KubeManagerInstance struct {
 	gadgetCtx          operators.GadgetContext
 }
 
+func (m *KubeManagerInstance) sandboxSubscriptionKey() string {
+	return m.id + "-sandbox"
+}
+
 func (m *KubeManagerInstance) Name() string {
 	return "KubeManagerInstance"
 }
@@ -219,10 +247,22 @@ func (m *KubeManagerInstance) PreGadgetRun() error {
 		},
 	}
 
+	for _, t := range m.params.Get(ParamContainerTypes).AsStringSlice() {
+		if excluded, ok := strings.CutPrefix(t, "!"); ok {
+			containerSelector.K8s.ExcludedContainerTypes = append(containerSelector.K8s.ExcludedContainerTypes, types.ContainerType(excluded))
+			continue
+		}
+		containerSelector.K8s.ContainerTypes = append(containerSelector.K8s.ContainerTypes, types.ContainerType(t))
+	}
+
 	if m.params.Get(ParamAllNamespaces).AsBool() {
 		containerSelector.K8s.Namespace = ""
 	}
 
+	m.sandboxSubscribed = m.manager.gadgetTracerManager.SubscribeToSandboxedContainers(m.sandboxSubscriptionKey(), containerSelector, func(msg string) {
+		log.Warn(msg)
+	})
+
 	if setter, ok := m.gadgetInstance.(MountNsMapSetter); ok {
 		err := m.manager.gadgetTracerManager.AddTracer(m.id, containerSelector)
 		if err != nil {
@@ -304,6 +344,9 @@ func (m *KubeManagerInstance) PreGadgetRun() error {
 }
 
 func (m *KubeManagerInstance) PostGadgetRun() error {
+	if m.sandboxSubscribed {
+		m.manager.gadgetTracerManager.Unsubscribe(m.sandboxSubscriptionKey())
+	}
 	if m.mountnsmap != nil {
 		m.gadgetCtx.Logger().Debugf("calling RemoveTracer()")
 		m.manager.gadgetTracerManager.RemoveTracer(m.id)
@@ -20,9 +20,9 @@ package kubenameresolver
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/common"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/kubemanager"
@@ -119,16 +119,9 @@ func (m *KubeNameResolverInstance) enrich(ev any) {
 	pods := m.manager.k8sInventory.GetPods()
 	for i, pod := range pods.Items {
 		if pod.Namespace == containerInfo.GetNamespace() && pod.Name == containerInfo.GetPod() {
-			owner := ""
-			// When the pod belongs to Deployment, ReplicaSet or DaemonSet, find the
-			// shorter name without the random suffix. That will be used to
-			// generate the network policy name.
-			if pods.Items[i].OwnerReferences != nil {
-				nameItems := strings.Split(pods.Items[i].Name, "-")
-				if len(nameItems) > 2 {
-					owner = strings.Join(nameItems[:len(nameItems)-2], "-")
-				}
-			}
+			// The owner name will be used to generate the network policy
+			// name.
+			owner := k8sutil.PodOwnerName(&pods.Items[i])
 			kubeNameResolver.SetLocalPodDetails(owner, pod.Status.HostIP, pod.Status.PodIP, pod.Labels)
 			return
 		}
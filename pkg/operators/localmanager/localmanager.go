@@ -47,6 +47,10 @@ const (
 	CrioSocketPath       = "crio-socketpath"
 	PodmanSocketPath     = "podman-socketpath"
 	ContainerdNamespace  = "containerd-namespace"
+	Annotations          = "annotations"
+	ContainerStateFile   = "container-state-file"
+	RuntimeLabel         = "runtime-label"
+	RuntimeEnv           = "runtime-env"
 )
 
 type MountNsMapSetter interface {
@@ -80,9 +84,9 @@ func (l *LocalManager) GlobalParamDescs() params.ParamDescs {
 		{
 			Key:          Runtimes,
 			Alias:        "r",
-			DefaultValue: strings.Join(containerutils.AvailableRuntimes, ","),
-			Description: fmt.Sprintf("Container runtimes to be used separated by comma. Supported values are: %s",
-				strings.Join(containerutils.AvailableRuntimes, ", ")),
+			DefaultValue: containerutils.AutoRuntimes,
+			Description: fmt.Sprintf("Container runtimes to be used separated by comma. Supported values are: %s. %q uses all the runtimes whose socket is found",
+				strings.Join(containerutils.AvailableRuntimes, ", "), containerutils.AutoRuntimes),
 			// PossibleValues: containerutils.AvailableRuntimes, // TODO
 		},
 		{
@@ -108,7 +112,15 @@ func (l *LocalManager) GlobalParamDescs() params.ParamDescs {
 		{
 			Key:          ContainerdNamespace,
 			DefaultValue: constants.K8sContainerdNamespace,
-			Description:  "Containerd namespace to use",
+			Description:  "Containerd namespaces to use separated by comma, \"*\" to use all of them",
+		},
+		{
+			Key:         Annotations,
+			Description: "Container and pod annotations to add to the events, as glob patterns separated by comma, e.g. \"example.com/*\"",
+		},
+		{
+			Key:         ContainerStateFile,
+			Description: fmt.Sprintf("File where the containers are saved to keep tracking them across restarts, e.g. %q. Empty to disable", containercollection.DefaultStateFile),
 		},
 	}
 }
@@ -127,7 +139,37 @@ func (l *LocalManager) ParamDescs() params.ParamDescs {
 			DefaultValue: "false",
 			TypeHint:     params.TypeBool,
 		},
+		{
+			Key:         RuntimeLabel,
+			Description: "Show only data from containers whose runtime labels match the selector (e.g. app=payments,tier!=db)",
+			Validator:   validateSelector,
+		},
+		{
+			Key:         RuntimeEnv,
+			Description: "Show only data from containers whose environment variables match the selector (e.g. ENV=prod)",
+			Validator:   validateSelector,
+		},
+	}
+}
+
+func validateSelector(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, err := containercollection.ParseKeyValueSelector(value)
+	return err
+}
+
+// parseSelector returns the selector of the given param, nil if it's empty.
+func parseSelector(p *params.Param) (containercollection.KeyValueSelector, error) {
+	if p.AsString() == "" {
+		return nil, nil
 	}
+	selector, err := containercollection.ParseKeyValueSelector(p.AsString())
+	if err != nil {
+		return nil, commonutils.WrapInErrInvalidArg("--"+p.Key, err)
+	}
+	return selector, nil
 }
 
 func (l *LocalManager) CanOperateOn(gadget gadgets.GadgetDesc) bool {
@@ -161,54 +203,54 @@ func (l *LocalManager) CanOperateOn(gadget gadgets.GadgetDesc) bool {
 }
 
 func (l *LocalManager) Init(operatorParams *params.Params) error {
-	rc := make([]*containerutilsTypes.RuntimeConfig, 0)
-	parts := operatorParams.Get(Runtimes).AsStringSlice()
-
-partsLoop:
-	for _, p := range parts {
-		runtimeName := types.String2RuntimeName(strings.TrimSpace(p))
-		socketPath := ""
-		namespace := ""
-
-		switch runtimeName {
-		case types.RuntimeNameDocker:
-			socketPath = operatorParams.Get(DockerSocketPath).AsString()
-		case types.RuntimeNameContainerd:
-			socketPath = operatorParams.Get(ContainerdSocketPath).AsString()
-			namespace = operatorParams.Get(ContainerdNamespace).AsString()
-		case types.RuntimeNameCrio:
-			socketPath = operatorParams.Get(CrioSocketPath).AsString()
-		case types.RuntimeNamePodman:
-			socketPath = operatorParams.Get(PodmanSocketPath).AsString()
-		default:
-			return commonutils.WrapInErrInvalidArg("--runtime / -r",
-				fmt.Errorf("runtime %q is not supported", p))
-		}
-
-		for _, r := range rc {
-			if r.Name == runtimeName {
-				log.Infof("Ignoring duplicated runtime %q from %v",
-					runtimeName, parts)
-				continue partsLoop
+	rc, err := containerutils.ParseRuntimes(
+		operatorParams.Get(Runtimes).AsStringSlice(),
+		func(runtimeName types.RuntimeName, name string) (*containerutilsTypes.RuntimeConfig, error) {
+			socketPath := ""
+			namespace := ""
+
+			switch runtimeName {
+			case types.RuntimeNameDocker:
+				socketPath = operatorParams.Get(DockerSocketPath).AsString()
+			case types.RuntimeNameContainerd:
+				socketPath = operatorParams.Get(ContainerdSocketPath).AsString()
+				namespace = operatorParams.Get(ContainerdNamespace).AsString()
+			case types.RuntimeNameCrio:
+				socketPath = operatorParams.Get(CrioSocketPath).AsString()
+			case types.RuntimeNamePodman:
+				socketPath = operatorParams.Get(PodmanSocketPath).AsString()
+			default:
+				return nil, commonutils.WrapInErrInvalidArg("--runtime / -r",
+					fmt.Errorf("runtime %q is not supported", name))
 			}
-		}
 
-		r := &containerutilsTypes.RuntimeConfig{
-			Name:       runtimeName,
-			SocketPath: socketPath,
-		}
-		if namespace != "" {
-			r.Extra = &containerutilsTypes.ExtraConfig{
-				Namespace: namespace,
+			r := &containerutilsTypes.RuntimeConfig{
+				Name:       runtimeName,
+				SocketPath: socketPath,
 			}
-		}
-
-		rc = append(rc, r)
+			if namespace != "" {
+				r.Extra = &containerutilsTypes.ExtraConfig{
+					Namespace: namespace,
+				}
+			}
+			return r, nil
+		},
+	)
+	if err != nil {
+		return err
 	}
 
 	l.rc = rc
 
-	igManager, err := igmanager.NewManager(l.rc)
+	var opts []containercollection.ContainerCollectionOption
+	if annotations := operatorParams.Get(Annotations).AsStringSlice(); len(annotations) > 0 {
+		opts = append(opts, containercollection.WithAnnotationsEnrichment(annotations))
+	}
+	if stateFile := operatorParams.Get(ContainerStateFile).AsString(); stateFile != "" {
+		opts = append(opts, containercollection.WithPersistentState(stateFile))
+	}
+
+	igManager, err := igmanager.NewManager(l.rc, opts...)
 	if err != nil {
 		log.Warnf("Failed to create container-collection")
 		log.Debugf("Failed to create container-collection: %s", err)
@@ -250,6 +292,8 @@ type localManagerTrace struct {
 	mountnsmap      *ebpf.Map
 	enrichEvents    bool
 	subscriptionKey string
+	// sandboxSubscriptionKey is used to warn about the sandboxed containers
+	sandboxSubscriptionKey string
 
 	// Keep a map to attached containers, so we can clean up properly
 	attachedContainers map[*containercollection.Container]struct{}
@@ -276,6 +320,25 @@ func (l *localManagerTrace) PreGadgetRun() error {
 		},
 	}
 
+	var err error
+	containerSelector.Runtime.Labels, err = parseSelector(l.params.Get(RuntimeLabel))
+	if err != nil {
+		return err
+	}
+	containerSelector.Runtime.Env, err = parseSelector(l.params.Get(RuntimeEnv))
+	if err != nil {
+		return err
+	}
+
+	if l.manager.igManager != nil {
+		key := id.String() + "-sandbox"
+		if l.manager.igManager.SubscribeToSandboxedContainers(key, containerSelector, func(msg string) {
+			log.Warn(msg)
+		}) {
+			l.sandboxSubscriptionKey = key
+		}
+	}
+
 	// If --host is set, we do not want to create the below map because we do not
 	// want any filtering.
 	if setter, ok := l.gadgetInstance.(MountNsMapSetter); ok {
@@ -374,6 +437,9 @@ func (l *localManagerTrace) PreGadgetRun() error {
 }
 
 func (l *localManagerTrace) PostGadgetRun() error {
+	if l.sandboxSubscriptionKey != "" {
+		l.manager.igManager.Unsubscribe(l.sandboxSubscriptionKey)
+	}
 	if l.mountnsmap != nil {
 		log.Debugf("calling RemoveMountNsMap()")
 		l.manager.igManager.RemoveMountNsMap(l.subscriptionKey)
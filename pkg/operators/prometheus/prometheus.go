@@ -17,6 +17,7 @@ package prometheus
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
@@ -111,18 +112,29 @@ func (p *Prometheus) Init(globalParams *params.Params) error {
 	listenAddress := globalParams.Get(ParamListenAddress).AsString()
 	metricsPath := globalParams.Get(ParamMetricsPath).AsString()
 
-	go func() {
-		mux := http.NewServeMux()
-		mux.Handle(metricsPath, promhttp.Handler())
-		err := http.ListenAndServe(listenAddress, mux)
-		if err != nil {
-			log.Errorf("serving http: %s", err)
-			return
-		}
-	}()
+	ServeMetrics(listenAddress, metricsPath)
 	return nil
 }
 
+var serveOnce sync.Once
+
+// ServeMetrics serves the metrics of the default registry on listenAddress at
+// metricsPath in the background. Only the first call starts the server, so the
+// daemons can serve their own metrics before any gadget uses this operator.
+func ServeMetrics(listenAddress, metricsPath string) {
+	serveOnce.Do(func() {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle(metricsPath, promhttp.Handler())
+			err := http.ListenAndServe(listenAddress, mux)
+			if err != nil {
+				log.Errorf("serving http: %s", err)
+				return
+			}
+		}()
+	})
+}
+
 func (p *Prometheus) CanOperateOn(gadget gadgets.GadgetDesc) bool {
 	inst, ok := gadget.(gadgets.GadgetInstantiate)
 	if !ok {
@@ -0,0 +1,156 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package systemdresolver provides an operator that enriches the events of
+// host processes with the systemd unit and slice they run in.
+package systemdresolver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cgroups"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/localmanager"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	OperatorName = "SystemdResolver"
+
+	// Processes come and go and pids are reused, so only cache the units for
+	// a short time.
+	cacheTTL        = 5 * time.Second
+	cacheMaxEntries = 4096
+)
+
+type SystemdResolverInterface interface {
+	GetPid() uint32
+	GetContainerID() string
+	SetSystemdMetadata(unit, slice string)
+}
+
+type SystemdResolver struct{}
+
+func (s *SystemdResolver) Name() string {
+	return OperatorName
+}
+
+func (s *SystemdResolver) Description() string {
+	return "SystemdResolver resolves the systemd unit of host processes"
+}
+
+func (s *SystemdResolver) GlobalParamDescs() params.ParamDescs {
+	return nil
+}
+
+func (s *SystemdResolver) ParamDescs() params.ParamDescs {
+	return nil
+}
+
+// Dependencies makes sure the events are enriched with the container
+// metadata before, so the events of containers are recognized.
+func (s *SystemdResolver) Dependencies() []string {
+	return []string{localmanager.OperatorName}
+}
+
+func (s *SystemdResolver) CanOperateOn(gadget gadgets.GadgetDesc) bool {
+	_, hasSystemdResolverInterface := gadget.EventPrototype().(SystemdResolverInterface)
+	if !hasSystemdResolverInterface {
+		return false
+	}
+
+	lm := operators.GetRaw(localmanager.OperatorName)
+	return lm != nil && lm.CanOperateOn(gadget)
+}
+
+func (s *SystemdResolver) Init(params *params.Params) error {
+	return nil
+}
+
+func (s *SystemdResolver) Close() error {
+	return nil
+}
+
+func (s *SystemdResolver) Instantiate(gadgetCtx operators.GadgetContext, gadgetInstance any, params *params.Params) (operators.OperatorInstance, error) {
+	return &SystemdResolverInstance{
+		cache: make(map[uint32]cacheEntry),
+	}, nil
+}
+
+type cacheEntry struct {
+	unit      string
+	slice     string
+	timestamp time.Time
+}
+
+type SystemdResolverInstance struct {
+	mu    sync.Mutex
+	cache map[uint32]cacheEntry
+}
+
+func (m *SystemdResolverInstance) Name() string {
+	return "SystemdResolverInstance"
+}
+
+func (m *SystemdResolverInstance) PreGadgetRun() error {
+	return nil
+}
+
+func (m *SystemdResolverInstance) PostGadgetRun() error {
+	return nil
+}
+
+func (m *SystemdResolverInstance) resolve(pid uint32) (string, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := m.cache[pid]; ok && now.Sub(entry.timestamp) < cacheTTL {
+		return entry.unit, entry.slice
+	}
+
+	if len(m.cache) >= cacheMaxEntries {
+		m.cache = make(map[uint32]cacheEntry)
+	}
+
+	// Processes that already exited are also cached to avoid looking for
+	// them again for each of their events.
+	unit, slice, _ := cgroups.GetSystemdUnit(int(pid))
+	m.cache[pid] = cacheEntry{
+		unit:      unit,
+		slice:     slice,
+		timestamp: now,
+	}
+
+	return unit, slice
+}
+
+func (m *SystemdResolverInstance) EnrichEvent(ev any) error {
+	event := ev.(SystemdResolverInterface)
+
+	// Only host processes, container ones are enriched with the container
+	// metadata instead.
+	if event.GetContainerID() != "" || event.GetPid() == 0 {
+		return nil
+	}
+
+	event.SetSystemdMetadata(m.resolve(event.GetPid()))
+	return nil
+}
+
+func init() {
+	operators.Register(&SystemdResolver{})
+}
@@ -42,6 +42,10 @@ type ErrorGetter interface {
 	GetMessage() string
 }
 
+type LostSamplesGetter interface {
+	GetLostSamples() uint64
+}
+
 // outputHelpers hides all information about underlying types from the application
 type outputHelper[T any] struct {
 	parser *parser[T]
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -90,6 +91,12 @@ type Parser interface {
 	// SetEventCallback sets the downstream callback
 	SetEventCallback(eventCallback any)
 
+	// SetEventAdmission sets a function called with every single event, not the
+	// arrays, before it's enriched: the events it returns false for are
+	// dropped. It's called from the goroutine emitting the events, which it may
+	// block.
+	SetEventAdmission(admit func(ev any) bool)
+
 	// SetLogCallback sets the function to use to send log messages
 	SetLogCallback(logCallback LogCallback)
 
@@ -104,6 +111,9 @@ type Parser interface {
 	// Flush sends the events downstream that were collected after EnableCombiner() was called.
 	Flush()
 
+	// LostSamples returns the number of events reported as lost by the events handled so far
+	LostSamples() uint64
+
 	// Things related to Prometheus. TODO: move to a separate interface / file?
 
 	// AttrsGetter returns a function that accepts an instance of type *T and returns a list of
@@ -134,8 +144,10 @@ type parser[T any] struct {
 	eventCallback      func(*T)
 	eventCallbackArray func([]*T)
 	logCallback        LogCallback
+	eventAdmission     func(any) bool
 	snapshotCombiner   *snapshotcombiner.SnapshotCombiner[T]
 	columnFilters      []columns.ColumnFilter
+	lostSamples        atomic.Uint64
 
 	// event combiner related fields
 	eventCombinerEnabled bool
@@ -199,6 +211,10 @@ func (p *parser[T]) Flush() {
 	p.eventCallbackArray(p.combinedEvents)
 }
 
+func (p *parser[T]) LostSamples() uint64 {
+	return p.lostSamples.Load()
+}
+
 func (p *parser[T]) SetColumnFilters(filters ...columns.ColumnFilter) {
 	p.columnFilters = filters
 }
@@ -228,14 +244,28 @@ func (p *parser[T]) SetEventCallback(eventCallback any) {
 	}
 }
 
+func (p *parser[T]) SetEventAdmission(admit func(ev any) bool) {
+	p.eventAdmission = admit
+}
+
 func (p *parser[T]) eventHandler(cb func(*T), enrichers ...func(any) error) func(*T) {
 	if cb == nil {
 		panic("cb can't be nil in eventHandler from parser")
 	}
 	return func(ev *T) {
+		if p.eventAdmission != nil && !p.eventAdmission(ev) {
+			return
+		}
 		for _, enricher := range enrichers {
 			enricher(ev)
 		}
+		if getter, ok := any(ev).(LostSamplesGetter); ok && getter.GetLostSamples() > 0 {
+			// Reports of lost events are never filtered out, they don't
+			// carry the data of the gadget
+			p.lostSamples.Add(getter.GetLostSamples())
+			cb(ev)
+			return
+		}
 		if p.filterSpecs != nil && !p.filterSpecs.MatchAll(ev) {
 			return
 		}
@@ -140,7 +140,14 @@ func checkForDuplicates(subject string) func(value string) error {
 }
 
 func (r *Runtime) ParamDescs() params.ParamDescs {
-	p := params.ParamDescs{}
+	p := params.ParamDescs{
+		{
+			Key:          api.ParamPriority,
+			Description:  "Priority of the gadget when the daemon is over its resource budget: the gadgets with the lowest priority are paused first",
+			DefaultValue: "0",
+			TypeHint:     params.TypeInt,
+		},
+	}
 	switch r.connectionMode {
 	case ConnectionModeDirect:
 		return p
@@ -0,0 +1,204 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgettesting
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"unsafe"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+)
+
+// L4Endpoint is the value to give to the fields of type gadget_l4endpoint_t.
+// The fields of type gadget_l3endpoint_t take a net.IP.
+type L4Endpoint struct {
+	Addr  net.IP
+	Port  uint16
+	Proto uint16
+}
+
+// encodeStruct writes the values of fields, by member name (like "task.comm"
+// for the members of nested structures), to buf laid out
+// as typ. The members without value are left to zero.
+func encodeStruct(buf []byte, typ *btf.Struct, fields map[string]any) error {
+	members := map[string]btf.Member{}
+	for _, member := range types.FlattenMembers(typ, 0) {
+		members[member.Name] = member
+	}
+
+	for name, value := range fields {
+		member, ok := members[name]
+		if !ok {
+			return fmt.Errorf("%s has no member %q", typ.Name, name)
+		}
+		if member.BitfieldSize != 0 {
+			return fmt.Errorf("member %q: bitfields aren't supported", name)
+		}
+		offset := member.Offset.Bytes()
+		if err := encodeValue(buf[offset:], member.Type, value); err != nil {
+			return fmt.Errorf("member %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func encodeValue(buf []byte, typ btf.Type, value any) error {
+	switch typ := btf.UnderlyingType(typ).(type) {
+	case *btf.Int:
+		v, err := integerValue(value)
+		if err != nil {
+			return err
+		}
+		return encodeInteger(buf, typ.Size, v)
+	case *btf.Enum:
+		if name, ok := value.(string); ok {
+			for _, enumValue := range typ.Values {
+				if enumValue.Name == name {
+					return encodeInteger(buf, typ.Size, enumValue.Value)
+				}
+			}
+			return fmt.Errorf("%q isn't a value of enum %s", name, typ.Name)
+		}
+		v, err := integerValue(value)
+		if err != nil {
+			return err
+		}
+		return encodeInteger(buf, typ.Size, v)
+	case *btf.Array:
+		return encodeArray(buf, typ, value)
+	case *btf.Struct:
+		switch typ.Name {
+		case gadgets.L3EndpointTypeName:
+			ip, ok := value.(net.IP)
+			if !ok {
+				return fmt.Errorf("expected a net.IP, got %T", value)
+			}
+			return encodeL3Endpoint(buf, ip)
+		case gadgets.L4EndpointTypeName:
+			endpoint, ok := value.(L4Endpoint)
+			if !ok {
+				return fmt.Errorf("expected a L4Endpoint, got %T", value)
+			}
+			if err := encodeL3Endpoint(buf, endpoint.Addr); err != nil {
+				return err
+			}
+			// Keep aligned with l4EndpointT in pkg/gadgets/run/tracer
+			*(*uint16)(unsafe.Pointer(&buf[20])) = endpoint.Port
+			*(*uint16)(unsafe.Pointer(&buf[22])) = endpoint.Proto
+			return nil
+		}
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected a map[string]any for struct %s, got %T", typ.Name, value)
+		}
+		return encodeStruct(buf, typ, fields)
+	default:
+		return fmt.Errorf("type %s isn't supported", typ.TypeName())
+	}
+}
+
+func encodeArray(buf []byte, typ *btf.Array, value any) error {
+	elemType := btf.UnderlyingType(typ.Type)
+	if elemInt, ok := elemType.(*btf.Int); ok && elemInt.Size == 1 {
+		var data []byte
+		switch v := value.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		}
+		if data != nil {
+			if uint32(len(data)) > typ.Nelems {
+				return fmt.Errorf("%d bytes given for an array of %d", len(data), typ.Nelems)
+			}
+			copy(buf, data)
+			return nil
+		}
+	}
+
+	elems := reflect.ValueOf(value)
+	if elems.Kind() != reflect.Slice && elems.Kind() != reflect.Array {
+		return fmt.Errorf("expected a slice, got %T", value)
+	}
+	if uint32(elems.Len()) > typ.Nelems {
+		return fmt.Errorf("%d elements given for an array of %d", elems.Len(), typ.Nelems)
+	}
+	elemSize, err := btf.Sizeof(elemType)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < elems.Len(); i++ {
+		if err := encodeValue(buf[i*elemSize:], elemType, elems.Index(i).Interface()); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func encodeL3Endpoint(buf []byte, ip net.IP) error {
+	// Keep aligned with l3EndpointT in pkg/gadgets/run/tracer
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(buf[:16], ip4)
+		buf[16] = 4
+		return nil
+	}
+	if len(ip) != net.IPv6len {
+		return fmt.Errorf("invalid IP %v", ip)
+	}
+	copy(buf[:16], ip)
+	buf[16] = 6
+	return nil
+}
+
+// integerValue returns the bits of an integer or boolean value.
+func integerValue(value any) (uint64, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", value)
+	}
+}
+
+// encodeInteger writes the size lowest bytes of v to buf, in the byte order of
+// the host like the eBPF programs do.
+func encodeInteger(buf []byte, size uint32, v uint64) error {
+	switch size {
+	case 1:
+		buf[0] = uint8(v)
+	case 2:
+		*(*uint16)(unsafe.Pointer(&buf[0])) = uint16(v)
+	case 4:
+		*(*uint32)(unsafe.Pointer(&buf[0])) = uint32(v)
+	case 8:
+		*(*uint64)(unsafe.Pointer(&buf[0])) = v
+	default:
+		return fmt.Errorf("integers of %d bytes aren't supported", size)
+	}
+	return nil
+}
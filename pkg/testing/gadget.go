@@ -0,0 +1,376 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+// Package gadgettesting helps writing the tests of containerized gadgets
+// without a cluster. A gadget is loaded from its image or eBPF object, then
+// it's given events, either synthetic ones encoded like its eBPF programs
+// would, sent through the ring buffer or the perf buffer of the gadget, or
+// events generated by running a workload while the gadget is attached to the
+// host. The events are decoded and formatted the way ig and
+// kubectl-gadget do, to compare them to golden files:
+//
+//	func TestMyGadget(t *testing.T) {
+//		g := gadgettesting.LoadObject(t, "program.bpf.o", "gadget.yaml")
+//		g.InjectEvent(map[string]any{"pid": 1234, "comm": "cat"})
+//		gadgettesting.RequireGolden(t, "testdata/events.golden", g.Columns())
+//	}
+package gadgettesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/tracer"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	tracercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/tracer-collection"
+)
+
+// settleTime is how long the events of a workload are waited for: Run returns
+// once no new event arrived during that time
+const settleTime = 200 * time.Millisecond
+
+// injectTimeout is how long an injected event is waited for
+const injectTimeout = 5 * time.Second
+
+// Gadget is a gadget loaded for testing. It keeps the events it was given, in
+// order.
+type Gadget struct {
+	t         testing.TB
+	info      *types.GadgetInfo
+	eventType *btf.Struct
+	// filterByMntns tells if the programs of the gadget can filter their
+	// events by mount namespace
+	filterByMntns bool
+	// injector is the gadget the events are injected through, loaded with
+	// the first one
+	injector *tracer.Standalone
+
+	mu     sync.Mutex
+	events []*types.Event
+}
+
+// LoadObject loads the gadget from the eBPF object at objectPath and its
+// metadata at metadataPath. The metadata is generated from the object, as
+// `ig image build` does, when metadataPath is empty.
+func LoadObject(t testing.TB, objectPath, metadataPath string) *Gadget {
+	t.Helper()
+
+	progContent, err := os.ReadFile(objectPath)
+	require.NoError(t, err, "reading eBPF object")
+
+	spec, err := ebpf.LoadCollectionSpecFromReader(bytes.NewReader(progContent))
+	require.NoError(t, err, "loading eBPF object")
+
+	info := &types.GadgetInfo{
+		ProgContent:    progContent,
+		GadgetMetadata: &types.GadgetMetadata{},
+	}
+	if metadataPath == "" {
+		require.NoError(t, info.GadgetMetadata.Populate(spec), "generating metadata")
+	} else {
+		metadata, err := os.ReadFile(metadataPath)
+		require.NoError(t, err, "reading metadata")
+		info.GadgetMetadata, err = types.DecodeMetadata(metadata, false)
+		require.NoError(t, err, "decoding metadata")
+		require.NoError(t, info.GadgetMetadata.Validate(spec), "validating metadata")
+	}
+
+	return newGadget(t, info, spec)
+}
+
+// LoadImage loads the gadget from the given image, pulling it if it isn't in
+// the local store yet.
+func LoadImage(t testing.TB, image string) *Gadget {
+	t.Helper()
+
+	desc := &tracer.GadgetDesc{}
+	info, err := desc.GetGadgetInfo(desc.ParamDescs().ToParams(), []string{image})
+	require.NoError(t, err, "getting gadget image")
+
+	spec, err := ebpf.LoadCollectionSpecFromReader(bytes.NewReader(info.ProgContent))
+	require.NoError(t, err, "loading eBPF object")
+
+	return newGadget(t, info, spec)
+}
+
+func newGadget(t testing.TB, info *types.GadgetInfo, spec *ebpf.CollectionSpec) *Gadget {
+	t.Helper()
+
+	eventType, err := getEventType(info.GadgetMetadata, spec)
+	require.NoError(t, err, "getting event type")
+
+	return &Gadget{
+		t:             t,
+		info:          info,
+		eventType:     eventType,
+		filterByMntns: hasConstant(spec, gadgets.FilterByMntNsName),
+	}
+}
+
+// getEventType returns the type of the events sent by the tracer of the
+// gadget.
+func getEventType(metadata *types.GadgetMetadata, spec *ebpf.CollectionSpec) (*btf.Struct, error) {
+	for _, tracer := range metadata.Tracers {
+		traceMap := spec.Maps[tracer.MapName]
+		if traceMap == nil {
+			return nil, fmt.Errorf("map %q not found", tracer.MapName)
+		}
+		eventType, ok := traceMap.Value.(*btf.Struct)
+		if !ok {
+			return nil, fmt.Errorf("value of map %q is not a structure", tracer.MapName)
+		}
+		return eventType, nil
+	}
+	return nil, fmt.Errorf("the gadget doesn't have any tracer")
+}
+
+// hasConstant tells if the eBPF object declares the constant name.
+func hasConstant(spec *ebpf.CollectionSpec, name string) bool {
+	rodata := spec.Maps[".rodata"]
+	if rodata == nil {
+		return false
+	}
+	datasec, ok := rodata.Value.(*btf.Datasec)
+	if !ok {
+		return false
+	}
+	for _, v := range datasec.Vars {
+		if v.Type.TypeName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Info returns the information of the gadget, like its metadata.
+func (g *Gadget) Info() *types.GadgetInfo {
+	return g.info
+}
+
+// Encode returns the event with the given values, by field name, the way the
+// eBPF programs of the gadget send it. The fields without value are left to
+// zero. Integer fields take any Go integer or a boolean, enums also the name
+// of one of their values, arrays of bytes a string or a []byte, other arrays a
+// slice, endpoints a net.IP or a L4Endpoint, and nested structures a
+// map[string]any.
+func (g *Gadget) Encode(fields map[string]any) []byte {
+	g.t.Helper()
+
+	data := make([]byte, g.eventType.Size)
+	require.NoError(g.t, encodeStruct(data, g.eventType, fields), "encoding event")
+	return data
+}
+
+// Inject sends data, an event as sent by the eBPF programs of the gadget,
+// through the ring buffer or the perf buffer of its tracer, and waits for it to
+// be read and decoded into the events of the gadget. The gadget is loaded by
+// the first injected event, filtering out the events of the host if its
+// programs filter them by mount namespace. The test is skipped when not
+// running as root.
+func (g *Gadget) Inject(data []byte) {
+	g.t.Helper()
+
+	if g.injector == nil {
+		g.loadInjector()
+	}
+
+	count := len(g.Events())
+	require.NoError(g.t, g.injector.Inject("", data), "injecting event")
+	require.Eventually(g.t, func() bool {
+		return len(g.Events()) > count
+	}, injectTimeout, 10*time.Millisecond, "waiting for the injected event")
+}
+
+// loadInjector loads the gadget the events are injected through, until the
+// end of the test.
+func (g *Gadget) loadInjector() {
+	g.t.Helper()
+
+	utilstest.RequireRoot(g.t)
+
+	config := &tracer.Config{
+		ProgContent: g.info.ProgContent,
+		Metadata:    g.info.GadgetMetadata,
+	}
+	if g.filterByMntns {
+		// No mount namespace is traced
+		mountnsMap, err := ebpf.NewMap(&ebpf.MapSpec{
+			Name:       gadgets.MntNsFilterMapName,
+			Type:       ebpf.Hash,
+			KeySize:    8,
+			ValueSize:  4,
+			MaxEntries: tracercollection.MaxContainersPerNode,
+		})
+		require.NoError(g.t, err, "creating mount namespaces map")
+		g.t.Cleanup(func() { mountnsMap.Close() })
+		config.MountnsMap = mountnsMap
+	}
+
+	var err error
+	g.injector, err = tracer.NewStandalone(config, logger.DefaultLogger(), g.addEvent)
+	require.NoError(g.t, err, "loading gadget")
+	g.t.Cleanup(g.injector.Close)
+}
+
+// InjectEvent adds the event with the given values to the events of the
+// gadget, see Encode.
+func (g *Gadget) InjectEvent(fields map[string]any) {
+	g.t.Helper()
+
+	g.Inject(g.Encode(fields))
+}
+
+func (g *Gadget) addEvent(ev *types.Event) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.events = append(g.events, ev)
+}
+
+// Run attaches the gadget to the host, calls workload and adds the events
+// generated meanwhile to the events of the gadget. The events aren't filtered
+// or enriched with the containers. The test is skipped when not running as
+// root.
+func (g *Gadget) Run(workload func() error) {
+	g.t.Helper()
+
+	utilstest.RequireRoot(g.t)
+
+	pinPath := gadgets.PinnedMapsPath("testing/" + g.t.Name())
+	defer os.RemoveAll(pinPath)
+
+	standalone, err := tracer.NewStandalone(&tracer.Config{
+		ProgContent: g.info.ProgContent,
+		Metadata:    g.info.GadgetMetadata,
+		PinPath:     pinPath,
+	}, logger.DefaultLogger(), g.addEvent)
+	require.NoError(g.t, err, "running gadget")
+
+	workloadErr := workload()
+
+	// Wait for the events to be read
+	count := -1
+	for count != len(g.Events()) {
+		count = len(g.Events())
+		time.Sleep(settleTime)
+	}
+	standalone.Close()
+
+	require.NoError(g.t, workloadErr, "running workload")
+}
+
+// Events returns the events of the gadget so far.
+func (g *Gadget) Events() []*types.Event {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]*types.Event{}, g.events...)
+}
+
+// Reset drops the events of the gadget so far.
+func (g *Gadget) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.events = nil
+}
+
+// printer collects the output of the formatters of the run gadget.
+type printer struct {
+	t     testing.TB
+	lines []string
+}
+
+func (p *printer) Output(payload string) {
+	p.lines = append(p.lines, payload)
+}
+
+func (p *printer) Logf(severity logger.Level, format string, params ...any) {
+	p.t.Logf("%s: %s", severity, fmt.Sprintf(format, params...))
+}
+
+func (p *printer) String() string {
+	if len(p.lines) == 0 {
+		return ""
+	}
+	return strings.Join(p.lines, "\n") + "\n"
+}
+
+// JSON returns the events of the gadget as printed by `ig run -o json`, one
+// per line, with their keys sorted to compare them. The messages, like the
+// reports of lost events, are logged instead.
+func (g *Gadget) JSON() string {
+	g.t.Helper()
+
+	p := &printer{t: g.t}
+	convert := (&tracer.GadgetDesc{}).JSONConverter(g.info, p)
+	require.NotNil(g.t, convert, "creating JSON formatter")
+	for _, ev := range g.Events() {
+		convert(ev)
+	}
+
+	for i, line := range p.lines {
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		var ev any
+		require.NoError(g.t, decoder.Decode(&ev), "decoding JSON event")
+		sorted, err := json.Marshal(ev)
+		require.NoError(g.t, err, "encoding JSON event")
+		p.lines[i] = string(sorted)
+	}
+	return p.String()
+}
+
+// Columns returns the events of the gadget as printed by `ig run`, with the
+// given columns. The columns shown by default, without the Kubernetes and
+// container runtime ones, are used when none are given.
+func (g *Gadget) Columns(cols ...string) string {
+	g.t.Helper()
+
+	parser, err := (&tracer.GadgetDesc{}).CustomParser(g.info)
+	require.NoError(g.t, err, "creating parser")
+	parser.SetLogCallback(func(severity logger.Level, format string, params ...any) {
+		g.t.Logf("%s: %s", severity, fmt.Sprintf(format, params...))
+	})
+
+	if len(cols) == 0 {
+		cols = parser.GetDefaultColumns("kubernetes", "runtime")
+	}
+	formatter := parser.GetTextColumnsFormatter()
+	require.NoError(g.t, formatter.SetShowColumns(cols), "setting columns")
+
+	p := &printer{t: g.t}
+	p.Output(formatter.FormatHeader())
+	formatter.SetEventCallback(p.Output)
+	handler := formatter.EventHandlerFunc().(func(*types.Event))
+	for _, ev := range g.Events() {
+		handler(ev)
+	}
+	return p.String()
+}
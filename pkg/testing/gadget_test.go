@@ -0,0 +1,145 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgettesting
+
+import (
+	"net"
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+)
+
+const testObject = "../../testdata/validate_metadata1.o"
+
+func TestInjectEvent(t *testing.T) {
+	// validate_metadata1.o has maps that can't be created, use an object that
+	// can be loaded to inject the events through it
+	g := LoadObject(t, "../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o", "")
+
+	first := g.Encode(map[string]any{
+		"pid":      1234,
+		"comm":     "cat",
+		"filename": "/etc/passwd",
+	})
+	g.Inject(first)
+	g.InjectEvent(map[string]any{
+		"pid":      5678,
+		"comm":     []byte("ls"),
+		"filename": "/",
+	})
+
+	events := g.Events()
+	require.Len(t, events, 2)
+	// The perf buffers pad the events to 8 bytes
+	require.Equal(t, first, events[0].RawData[:len(first)])
+
+	RequireGolden(t, "testdata/inject_event_columns.golden", g.Columns())
+	RequireGolden(t, "testdata/inject_event_json.golden", g.JSON())
+	RequireGolden(t, "testdata/inject_event_pid.golden", g.Columns("pid"))
+
+	g.Reset()
+	require.Empty(t, g.Events())
+}
+
+func TestEncodeErrors(t *testing.T) {
+	g := LoadObject(t, testObject, "")
+
+	tests := map[string]map[string]any{
+		"unknown field":     {"foo": 1},
+		"string for an int": {"pid": "1234"},
+		"too long string":   {"comm": "a command longer than 16 bytes"},
+	}
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, encodeStruct(make([]byte, g.eventType.Size), g.eventType, fields))
+		})
+	}
+}
+
+func TestEncodeEndpoints(t *testing.T) {
+	u8 := &btf.Int{Size: 1}
+	u16 := &btf.Int{Size: 2}
+	l3 := &btf.Struct{
+		Name: "gadget_l3endpoint_t",
+		Size: 20,
+		Members: []btf.Member{
+			{Name: "addr", Type: &btf.Array{Type: u8, Nelems: 16}},
+			{Name: "version", Type: u8, Offset: 16 * 8},
+		},
+	}
+	l4 := &btf.Struct{
+		Name: "gadget_l4endpoint_t",
+		Size: 24,
+		Members: []btf.Member{
+			{Name: "l3", Type: l3},
+			{Name: "port", Type: u16, Offset: 20 * 8},
+			{Name: "proto", Type: u16, Offset: 22 * 8},
+		},
+	}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 44,
+		Members: []btf.Member{
+			{Name: "src", Type: l3},
+			{Name: "dst", Type: l4, Offset: 20 * 8},
+		},
+	}
+
+	buf := make([]byte, event.Size)
+	err := encodeStruct(buf, event, map[string]any{
+		"src": net.ParseIP("127.0.0.1"),
+		"dst": L4Endpoint{Addr: net.ParseIP("::1"), Port: 80, Proto: 6},
+	})
+	require.NoError(t, err)
+
+	expected := make([]byte, event.Size)
+	copy(expected, []byte{127, 0, 0, 1})
+	expected[16] = 4
+	expected[20+15] = 1
+	expected[20+16] = 6
+	*(*uint16)(unsafe.Pointer(&expected[40])) = 80
+	*(*uint16)(unsafe.Pointer(&expected[42])) = 6
+	require.Equal(t, expected, buf)
+}
+
+func TestRequireGoldenUpdate(t *testing.T) {
+	path := t.TempDir() + "/dir/output.golden"
+
+	t.Setenv(UpdateGoldenEnv, "true")
+	RequireGolden(t, path, "output\n")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "output\n", string(content))
+
+	t.Setenv(UpdateGoldenEnv, "")
+	RequireGolden(t, path, "output\n")
+}
+
+func TestRun(t *testing.T) {
+	// The object doesn't have any program, it checks the gadget can be loaded
+	// and stopped
+	g := LoadObject(t, "../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o", "")
+
+	called := false
+	g.Run(func() error {
+		called = true
+		return nil
+	})
+	require.True(t, called)
+	require.Empty(t, g.Events())
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgettesting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// UpdateGoldenEnv is the environment variable to set to true for RequireGolden
+// to write the golden files instead of comparing them.
+const UpdateGoldenEnv = "IG_UPDATE_GOLDEN"
+
+// RequireGolden fails the test if output differs from the content of the
+// golden file at path. The file is written with output instead when
+// IG_UPDATE_GOLDEN is set to true.
+func RequireGolden(t testing.TB, path string, output string) {
+	t.Helper()
+
+	if os.Getenv(UpdateGoldenEnv) == "true" {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755), "creating golden file directory")
+		require.NoError(t, os.WriteFile(path, []byte(output), 0o644), "writing golden file")
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "reading golden file, set %s=true to create it", UpdateGoldenEnv)
+	require.Equal(t, string(expected), output, "output differs from %s, set %s=true to update it", path, UpdateGoldenEnv)
+}
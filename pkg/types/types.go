@@ -40,6 +40,8 @@ func init() {
 	columns.MustRegisterTemplate("uid", "minWidth:8")
 	columns.MustRegisterTemplate("gid", "minWidth:8")
 	columns.MustRegisterTemplate("ns", "width:12,hide")
+	// e.g. -ENAMETOOLONG
+	columns.MustRegisterTemplate("errno", "width:16")
 
 	// For IPs (IPv4+IPv6):
 	// Min: XXX.XXX.XXX.XXX (IPv4) = 15
@@ -89,6 +91,11 @@ const (
 	RuntimeNameCrio       RuntimeName = "cri-o"
 	RuntimeNamePodman     RuntimeName = "podman"
 	RuntimeNameUnknown    RuntimeName = "unknown"
+
+	// Runtimes of the containers running a full system. They don't have a
+	// runtime client, they are detected from their cgroups.
+	RuntimeNameLXC           RuntimeName = "lxc"
+	RuntimeNameSystemdNspawn RuntimeName = "systemd-nspawn"
 )
 
 func String2RuntimeName(name string) RuntimeName {
@@ -147,13 +154,37 @@ func (b *BasicK8sMetadata) IsEnriched() bool {
 	return b.Namespace != "" && b.PodName != "" && b.ContainerName != ""
 }
 
+// ContainerType is the kind of a container in its pod
+type ContainerType string
+
+const (
+	ContainerTypeMain      ContainerType = "main"
+	ContainerTypeInit      ContainerType = "init"
+	ContainerTypeEphemeral ContainerType = "ephemeral"
+)
+
+// AllContainerTypes are the container types that can be used in filters
+var AllContainerTypes = []string{
+	string(ContainerTypeMain),
+	string(ContainerTypeInit),
+	string(ContainerTypeEphemeral),
+}
+
 type K8sMetadata struct {
 	Node string `json:"node,omitempty" column:"node,template:node"`
 
 	BasicK8sMetadata `json:",inline"`
 
+	// ContainerType tells if the container is a main, init or ephemeral
+	// container of the pod
+	ContainerType ContainerType `json:"containerType,omitempty" column:"containertype,width:10,hide"`
+
 	// HostNetwork is true if the container uses the host network namespace
 	HostNetwork bool `json:"hostNetwork,omitempty" column:"hostnetwork,hide"`
+
+	// Annotations contains the annotations of the container and its pod
+	// selected by the user
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 type CommonData struct {
@@ -164,6 +195,26 @@ type CommonData struct {
 	// K8s contains the Kubernetes metadata of the object that generated the
 	// event
 	K8s K8sMetadata `json:"k8s,omitempty" column:"k8s" columnTags:"kubernetes"`
+
+	// Systemd contains the systemd unit of the host process that generated
+	// the event
+	Systemd *SystemdMetadata `json:"systemd,omitempty" column:"systemd" columnTags:"systemd"`
+}
+
+type SystemdMetadata struct {
+	Unit  string `json:"unit,omitempty" column:"unit,width:24,hide"`
+	Slice string `json:"slice,omitempty" column:"slice,width:24,hide"`
+}
+
+func (c *CommonData) SetSystemdMetadata(unit, slice string) {
+	if unit == "" && slice == "" {
+		c.Systemd = nil
+		return
+	}
+	c.Systemd = &SystemdMetadata{
+		Unit:  unit,
+		Slice: slice,
+	}
 }
 
 func (c *CommonData) SetNode(node string) {
@@ -206,6 +257,10 @@ func (c *CommonData) GetContainer() string {
 	return c.K8s.ContainerName
 }
 
+func (c *CommonData) GetContainerID() string {
+	return c.Runtime.ContainerID
+}
+
 func (c *CommonData) GetContainerImageName() string {
 	return c.Runtime.ContainerImageName
 }
@@ -310,6 +365,10 @@ type Event struct {
 
 	// Message when Type is ERR, WARN, DEBUG or INFO
 	Message string `json:"message,omitempty"`
+
+	// LostSamples is the number of events the kernel couldn't send to the
+	// gadget, when Type is WARN
+	LostSamples uint64 `json:"lostSamples,omitempty"`
 }
 
 // GetBaseEvent is needed to implement commonutils.BaseElement and
@@ -326,6 +385,10 @@ func (e *Event) GetMessage() string {
 	return e.Message
 }
 
+func (e *Event) GetLostSamples() uint64 {
+	return e.LostSamples
+}
+
 func Err(msg string) Event {
 	return Event{
 		CommonData: CommonData{
@@ -350,6 +413,14 @@ func Warn(msg string) Event {
 	}
 }
 
+// LostSamples returns a warning reporting that the kernel dropped n events,
+// because the buffer used to send them was full.
+func LostSamples(n uint64) Event {
+	ev := Warn(fmt.Sprintf("lost %d samples", n))
+	ev.LostSamples = n
+	return ev
+}
+
 func Debug(msg string) Event {
 	return Event{
 		CommonData: CommonData{
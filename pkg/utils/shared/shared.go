@@ -0,0 +1,88 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shared provides values that are created by their first user and
+// shared by all the users until the last one releases them, like the eBPF
+// programs and maps used by several gadgets running at the same time.
+package shared
+
+import "sync"
+
+// Value is a value shared by its users, created when it has none.
+type Value[T any] struct {
+	mu      sync.Mutex
+	create  func() (T, error)
+	destroy func(T)
+
+	value T
+	users int
+}
+
+// New returns a Value created by create when it gets its first user and
+// destroyed by destroy, if not nil, once its last user releases it.
+func New[T any](create func() (T, error), destroy func(T)) *Value[T] {
+	return &Value[T]{
+		create:  create,
+		destroy: destroy,
+	}
+}
+
+// Get returns the value, creating it if it has no user yet, and a function
+// releasing it. The function must be called once the caller doesn't use the
+// value anymore, calling it again does nothing.
+func (v *Value[T]) Get() (T, func(), error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.users == 0 {
+		value, err := v.create()
+		if err != nil {
+			var zero T
+			return zero, func() {}, err
+		}
+		v.value = value
+	}
+	v.users++
+
+	var once sync.Once
+	release := func() {
+		once.Do(v.release)
+	}
+
+	return v.value, release, nil
+}
+
+// Users returns the number of users of the value.
+func (v *Value[T]) Users() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.users
+}
+
+func (v *Value[T]) release() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.users--
+	if v.users > 0 {
+		return
+	}
+
+	if v.destroy != nil {
+		v.destroy(v.value)
+	}
+	var zero T
+	v.value = zero
+}
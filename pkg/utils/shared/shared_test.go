@@ -0,0 +1,111 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValue(t *testing.T) {
+	created := 0
+	destroyed := 0
+	v := New(func() (*int, error) {
+		created++
+		n := created
+		return &n, nil
+	}, func(*int) {
+		destroyed++
+	})
+
+	first, release1, err := v.Get()
+	require.NoError(t, err)
+	second, release2, err := v.Get()
+	require.NoError(t, err)
+	require.Same(t, first, second)
+	require.Equal(t, 1, created)
+	require.Equal(t, 2, v.Users())
+
+	release1()
+	// Releasing twice must not release the value of another user
+	release1()
+	require.Equal(t, 0, destroyed)
+	require.Equal(t, 1, v.Users())
+
+	release2()
+	require.Equal(t, 1, destroyed)
+	require.Equal(t, 0, v.Users())
+
+	// It's created again for the next user
+	third, release3, err := v.Get()
+	require.NoError(t, err)
+	require.Equal(t, 2, *third)
+	release3()
+	require.Equal(t, 2, destroyed)
+}
+
+func TestValueCreateError(t *testing.T) {
+	fail := true
+	v := New(func() (int, error) {
+		if fail {
+			return 0, errors.New("failed")
+		}
+		return 42, nil
+	}, nil)
+
+	_, release, err := v.Get()
+	require.Error(t, err)
+	release()
+	require.Equal(t, 0, v.Users())
+
+	fail = false
+	value, release, err := v.Get()
+	require.NoError(t, err)
+	require.Equal(t, 42, value)
+	release()
+}
+
+func TestValueConcurrent(t *testing.T) {
+	created := 0
+	v := New(func() (int, error) {
+		created++
+		return created, nil
+	}, nil)
+
+	// Keep it alive while the other users come and go
+	_, release, err := v.Get()
+	require.NoError(t, err)
+	defer release()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, release, err := v.Get()
+			if err == nil && value != 1 {
+				t.Errorf("got value %d, expected 1", value)
+			}
+			release()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, created)
+	require.Equal(t, 1, v.Users())
+}